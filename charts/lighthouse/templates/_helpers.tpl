@@ -30,3 +30,34 @@ We truncate at 63 chars because some Kubernetes name fields are limited to this
 {{- $name := default "gc-jobs" .Values.gcJobs.nameOverride -}}
 {{- printf "%s-%s" .Chart.Name $name | trunc 63 | trimSuffix "-" -}}
 {{- end -}}
+
+{{- define "dashboard.name" -}}
+{{- $name := default "dashboard" .Values.dashboard.nameOverride -}}
+{{- printf "%s-%s" .Chart.Name $name | trunc 63 | trimSuffix "-" -}}
+{{- end -}}
+
+{{/*
+The environment variables of the git token, fetched from Vault when vault.gitToken.path is set.
+*/}}
+{{- define "lighthouse.gitToken.env" -}}
+{{- if .Values.vault.gitToken.path }}
+- name: "VAULT_ADDR"
+  value: "{{ .Values.vault.gitToken.address }}"
+- name: "GIT_TOKEN_VAULT_PATH"
+  value: "{{ .Values.vault.gitToken.path }}"
+- name: "GIT_TOKEN_VAULT_KEY"
+  value: "{{ .Values.vault.gitToken.key }}"
+- name: "GIT_TOKEN_VAULT_ROLE"
+  value: "{{ .Values.vault.gitToken.role }}"
+- name: "GIT_TOKEN_VAULT_AUTH_PATH"
+  value: "{{ .Values.vault.gitToken.authPath }}"
+- name: "GIT_TOKEN_REFRESH_INTERVAL"
+  value: "{{ .Values.vault.gitToken.refreshInterval }}"
+{{- else }}
+- name: "GIT_TOKEN"
+  valueFrom:
+    secretKeyRef:
+      name: lighthouse-oauth-token
+      key: oauth
+{{- end }}
+{{- end -}}
@@ -5,34 +5,18 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	jxclient "github.com/jenkins-x/jx-api/pkg/client/clientset/versioned"
-	jxinformers "github.com/jenkins-x/jx-api/pkg/client/informers/externalversions"
 	"github.com/jenkins-x/jx/v2/pkg/jxfactory"
-	clientset "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned"
-	lhinformers "github.com/jenkins-x/lighthouse/pkg/client/informers/externalversions"
-	"github.com/jenkins-x/lighthouse/pkg/foghorn"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/foghorncmd"
 	"github.com/jenkins-x/lighthouse/pkg/interrupts"
 	"github.com/jenkins-x/lighthouse/pkg/logrusutil"
 	"github.com/sirupsen/logrus"
-	"k8s.io/client-go/kubernetes"
 )
 
-type options struct {
-	namespace string
-
-	dryRun bool
-}
-
-func (o *options) Validate() error {
-	return nil
-}
-
-func gatherOptions(fs *flag.FlagSet, args ...string) options {
-	var o options
-	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether to mutate any real-world state.")
-	fs.StringVar(&o.namespace, "namespace", "", "The namespace to listen in")
+func gatherOptions(fs *flag.FlagSet, args ...string) foghorncmd.Options {
+	var o foghorncmd.Options
+	o.AddFlags(fs)
 
 	err := fs.Parse(args)
 	if err != nil {
@@ -70,38 +54,12 @@ func main() {
 		logrus.WithError(err).Fatal("Invalid options")
 	}
 
-	cfg, err := jxfactory.NewFactory().CreateKubeConfig()
-	if err != nil {
-		logrus.WithError(err).Fatal("Could not create kubeconfig")
-	}
-
-	jxClient, err := jxclient.NewForConfig(cfg)
-	if err != nil {
-		logrus.WithError(err).Fatal("Could not create Jenkins X API client")
-	}
-	lhClient, err := clientset.NewForConfig(cfg)
+	kubeClients, err := clients.GetAllClients(jxfactory.NewFactory())
 	if err != nil {
-		logrus.WithError(err).Fatal("Could not create Lighthouse API client")
+		logrus.WithError(err).Fatal("Could not create the Kubernetes clients")
 	}
-	kubeClient, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		logrus.WithError(err).Fatal("Could not create Kubernetes API client")
-	}
-	jxInformerFactory := jxinformers.NewSharedInformerFactoryWithOptions(jxClient, time.Minute*30, jxinformers.WithNamespace(o.namespace))
-	lhInformerFactory := lhinformers.NewSharedInformerFactoryWithOptions(lhClient, time.Minute*30, lhinformers.WithNamespace(o.namespace))
-
-	controller, err := foghorn.NewController(kubeClient,
-		jxClient,
-		lhClient,
-		jxInformerFactory.Jenkins().V1().PipelineActivities(),
-		lhInformerFactory.Lighthouse().V1alpha1().LighthouseJobs(),
-		o.namespace,
-		nil)
-
-	jxInformerFactory.Start(stopCh)
-	lhInformerFactory.Start(stopCh)
 
-	if err = controller.Run(2, stopCh); err != nil {
+	if err = o.Run(kubeClients, nil, nil, nil, nil, stopCh); err != nil {
 		logrus.WithError(err).Fatal("Error running controller")
 	}
 }
@@ -10,14 +10,18 @@ import (
 	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
 	clientset "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned"
 	lhclient "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/typed/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/jobexport"
 	"github.com/jenkins-x/lighthouse/pkg/logrusutil"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
 type options struct {
 	namespace string
 	maxAge    time.Duration
+	exportURL string
 }
 
 func (o *options) Validate() error {
@@ -33,6 +37,7 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	var o options
 	fs.DurationVar(&o.maxAge, "max-age", 7*24*time.Hour, "Maximum age to keep LighthouseJobs.")
 	fs.StringVar(&o.namespace, "namespace", "", "The namespace to listen in")
+	fs.StringVar(&o.exportURL, "export-url", "", "If set, the bucket URL (e.g. gs://bucket/path) to which summaries of LighthouseJobs are exported before they are deleted.")
 
 	err := fs.Parse(args)
 	if err != nil {
@@ -56,9 +61,19 @@ func main() {
 	if err != nil {
 		logrus.WithError(err).Fatal("Could not create Lighthouse API client")
 	}
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not create Kubernetes API client")
+	}
+	clients.ReportMissingPermissions(kubeClient, clients.GCJobs, o.namespace)
 
 	lhInterface := lhClient.LighthouseV1alpha1().LighthouseJobs(o.namespace)
 
+	exporter, err := jobexport.NewBucketExporter(o.exportURL)
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not create LighthouseJob exporter")
+	}
+
 	jobList, err := lhInterface.List(metav1.ListOptions{})
 	if err != nil {
 		logrus.WithError(err).Fatalf("Could not list LighthouseJobs in namespace %s", o.namespace)
@@ -69,19 +84,23 @@ func main() {
 	for _, job := range jobList.Items {
 		j := job
 		completionTime := j.Status.CompletionTime
-		if completionTime != nil && completionTime.Add(o.maxAge).Before(now) {
-			// The job completed at least maxAge ago, so delete it.
-			err = deleteLighthouseJob(lhInterface, &j)
-			if err != nil {
-				logrus.WithError(err).Fatalf("Failed to delete LighthouseJob %s", j.Name)
-			}
-		} else if completionTime == nil && j.Status.StartTime.Add(o.maxAge).Before(now) {
-			// The job never completed, but was created at least maxAge ago, so delete it.
-			err = deleteLighthouseJob(lhInterface, &j)
-			if err != nil {
-				logrus.WithError(err).Fatalf("Failed to delete LighthouseJob %s", j.Name)
+		// The job completed at least maxAge ago, or never completed but was created at least maxAge ago.
+		expired := (completionTime != nil && completionTime.Add(o.maxAge).Before(now)) ||
+			(completionTime == nil && j.Status.StartTime.Add(o.maxAge).Before(now))
+		if !expired {
+			continue
+		}
+		if exporter != nil {
+			if err := exporter.Export(&j); err != nil {
+				// Keep the job so that the export is retried on the next run.
+				logrus.WithError(err).Errorf("Failed to export LighthouseJob %s, not deleting it", j.Name)
+				continue
 			}
 		}
+		err = deleteLighthouseJob(lhInterface, &j)
+		if err != nil {
+			logrus.WithError(err).Fatalf("Failed to delete LighthouseJob %s", j.Name)
+		}
 	}
 }
 
@@ -4,6 +4,11 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/jenkins-x/lighthouse/pkg/cmd/all"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/backfill"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/dashboardcmd"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/dev"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/digest"
 	"github.com/jenkins-x/lighthouse/pkg/version"
 	"github.com/jenkins-x/lighthouse/pkg/webhook"
 )
@@ -13,6 +18,11 @@ func main() {
 	cmds := webhook.NewCmdWebhook()
 	cmds.Version = version.GetVersion()
 	cmds.SetVersionTemplate("{{printf .Version}}\n")
+	cmds.AddCommand(all.NewCmdAll())
+	cmds.AddCommand(dev.NewCmdDev())
+	cmds.AddCommand(backfill.NewCmdBackfill())
+	cmds.AddCommand(digest.NewCmdDigest())
+	cmds.AddCommand(dashboardcmd.NewCmdDashboard())
 
 	err := cmds.Execute()
 	if err != nil {
@@ -23,6 +23,10 @@ const (
 	// PendingState pipeline is pending
 	PendingState PipelineState = "pending"
 
+	// QueuedState for jobs waiting for the running instances of their job to complete, as their
+	// max_concurrency is reached, before their pipeline is launched
+	QueuedState PipelineState = "queued"
+
 	// RunningState pipeline is running
 	RunningState PipelineState = "running"
 
@@ -58,6 +62,22 @@ const (
 	PullNumberEnv = "PULL_NUMBER"
 	// PullPullShaEnv is the pull request's sha
 	PullPullShaEnv = "PULL_PULL_SHA"
+	// PullNumbersEnv is the comma separated numbers of the pull requests tested together by a batch job,
+	// whose shas are merged into the base sha in the order of $PULL_REFS
+	PullNumbersEnv = "PULL_NUMBERS"
+	// ReleaseTagEnv is the tag of the release which triggered the job
+	ReleaseTagEnv = "RELEASE_TAG"
+	// ReleaseNameEnv is the name of the release which triggered the job
+	ReleaseNameEnv = "RELEASE_NAME"
+	// ReleaseURLEnv is the URL of the release which triggered the job
+	ReleaseURLEnv = "RELEASE_URL"
+	// ReleasePrereleaseEnv is "true" if the release which triggered the job is a prerelease
+	ReleasePrereleaseEnv = "RELEASE_PRERELEASE"
+	// ChangedPathsEnv is the newline separated paths of the files changed by the pull requests or the push
+	ChangedPathsEnv = "CHANGED_PATHS"
+	// ChangedModulesEnv is the newline separated modules containing the files changed by the pull
+	// requests or the push
+	ChangedModulesEnv = "CHANGED_MODULES"
 )
 
 // +genclient
@@ -90,6 +110,54 @@ type LighthouseJobStatus struct {
 	LastReportState string `json:"lastReportState,omitempty"`
 	// LastCommitSHA is the commit that will be/has been reported to on the SCM provider
 	LastCommitSHA string `json:"lastCommitSHA,omitempty"`
+	// Stages are the stages of the pipeline of the job, in order
+	Stages []Stage `json:"stages,omitempty"`
+	// CheckRunID is the ID of the check run the job is reported as, if reported as a check run
+	CheckRunID int64 `json:"checkRunID,omitempty"`
+	// ReportAttempts is the number of failed attempts to report the status of the job since it was last reported
+	ReportAttempts int `json:"reportAttempts,omitempty"`
+	// LastReportError is the error of the last failed attempt to report the status of the job
+	LastReportError string `json:"lastReportError,omitempty"`
+	// NextReportTime is when the report of the status of the job is retried after a failed attempt
+	NextReportTime *metav1.Time `json:"nextReportTime,omitempty"`
+	// ReportFailed is true once the status of the job failed to be reported too many times, after which it
+	// is no longer reported
+	ReportFailed bool `json:"reportFailed,omitempty"`
+}
+
+// Stage is the state and timings of a stage of the pipeline of a job
+type Stage struct {
+	// Name is the name of the stage
+	Name string `json:"name"`
+	// State is the state of the stage
+	State PipelineState `json:"state,omitempty"`
+	// StartTime is when the stage started, if it has
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the stage completed, if it has
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// Duration returns how long the stage took, or how long it has been running so far
+// at the given time. It returns zero if the stage has not started.
+func (s *Stage) Duration(now time.Time) time.Duration {
+	if s.StartTime == nil {
+		return 0
+	}
+	if s.CompletionTime != nil {
+		return s.CompletionTime.Sub(s.StartTime.Time)
+	}
+	return now.Sub(s.StartTime.Time)
+}
+
+// RunningStages returns the names of the stages which are currently running
+func (s *LighthouseJobStatus) RunningStages() []string {
+	var running []string
+	for _, stage := range s.Stages {
+		if stage.State == RunningState {
+			running = append(running, stage.Name)
+		}
+	}
+	return running
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -111,6 +179,9 @@ type LighthouseJobSpec struct {
 	Namespace string `json:"namespace,omitempty"`
 	// Job is the name of the job
 	Job string `json:"job,omitempty"`
+	// Agent is the agent launching the pipeline of the job, e.g. tekton. The default agent of
+	// the launcher settings launches it if it is empty.
+	Agent string `json:"agent,omitempty"`
 	// Refs is the code under test, determined at
 	// runtime by Prow itself
 	Refs *Refs `json:"refs,omitempty"`
@@ -123,6 +194,20 @@ type LighthouseJobSpec struct {
 	// MaxConcurrency restricts the total number of instances
 	// of this job that can run in parallel at once
 	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// Release is the release which triggered the job, if any
+	Release *Release `json:"release,omitempty"`
+	// Parameters are the values of the declared job parameters given in the
+	// comment which triggered the job, passed to the pipeline as environment variables
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// ChangedPaths are the paths of the files changed by the pull requests of a presubmit or
+	// batch or by the push of a postsubmit, unset if too many files are changed
+	ChangedPaths []string `json:"changed_paths,omitempty"`
+	// ChangedModules are the modules containing the files changed by the pull requests of a
+	// presubmit or batch or by the push of a postsubmit
+	ChangedModules []string `json:"changed_modules,omitempty"`
+	// SkipReport is true if the job is configured with skip_report, in which case no commit status nor
+	// PR comment is created for it, e.g. for experimental pipelines
+	SkipReport bool `json:"skip_report,omitempty"`
 }
 
 // GetBranch returns the branch name corresponding to the refs on this spec.
@@ -142,10 +227,12 @@ func (s *LighthouseJobSpec) GetBranch() string {
 
 // GetEnvVars gets a map of the environment variables we'll set in the pipeline for this spec.
 func (s *LighthouseJobSpec) GetEnvVars() map[string]string {
-	env := map[string]string{
-		JobNameEnv: s.Job,
-		JobTypeEnv: string(s.Type),
+	env := map[string]string{}
+	for k, v := range s.Parameters {
+		env[k] = v
 	}
+	env[JobNameEnv] = s.Job
+	env[JobTypeEnv] = string(s.Type)
 
 	registry := os.Getenv("DOCKER_REGISTRY")
 	if registry != "" {
@@ -164,7 +251,29 @@ func (s *LighthouseJobSpec) GetEnvVars() map[string]string {
 	env[PullBaseShaEnv] = s.Refs.BaseSHA
 	env[PullRefsEnv] = s.Refs.String()
 
-	if s.Type == config.PostsubmitJob || s.Type == config.BatchJob {
+	if s.Release != nil {
+		env[ReleaseTagEnv] = s.Release.Tag
+		env[ReleaseNameEnv] = s.Release.Name
+		env[ReleaseURLEnv] = s.Release.Link
+		env[ReleasePrereleaseEnv] = strconv.FormatBool(s.Release.Prerelease)
+	}
+	// the changes are newline separated so that paths containing commas are preserved
+	if s.ChangedPaths != nil {
+		env[ChangedPathsEnv] = strings.Join(s.ChangedPaths, "\n")
+	}
+	if s.ChangedModules != nil {
+		env[ChangedModulesEnv] = strings.Join(s.ChangedModules, "\n")
+	}
+
+	if s.Type == config.PostsubmitJob {
+		return env
+	}
+	if s.Type == config.BatchJob {
+		var numbers []string
+		for _, pull := range s.Refs.Pulls {
+			numbers = append(numbers, strconv.Itoa(pull.Number))
+		}
+		env[PullNumbersEnv] = strings.Join(numbers, ",")
 		return env
 	}
 
@@ -325,6 +434,18 @@ func (r *Refs) String() string {
 	return strings.Join(rs, ",")
 }
 
+// Release describes the release which triggered a job.
+type Release struct {
+	// Tag is the name of the tag of the release
+	Tag string `json:"tag"`
+	// Name is the title of the release
+	Name string `json:"name,omitempty"`
+	// Link links to the release page
+	Link string `json:"link,omitempty"`
+	// Prerelease is true if the release is marked as not ready for production
+	Prerelease bool `json:"prerelease,omitempty"`
+}
+
 // ByNum implements sort.Interface for []Pull to sort by ascending PR number.
 type ByNum []Pull
 
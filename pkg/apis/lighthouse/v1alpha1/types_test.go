@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestPipelineOptionsSpec_GetEnvVars(t *testing.T) {
@@ -53,6 +55,40 @@ func TestPipelineOptionsSpec_GetEnvVars(t *testing.T) {
 				v1alpha1.PullRefsEnv:    "master:1234abcd",
 			},
 		},
+		{
+			name: "release",
+			spec: &v1alpha1.LighthouseJobSpec{
+				Type:      config.PostsubmitJob,
+				Namespace: "jx",
+				Job:       "some-publish-job",
+				Refs: &v1alpha1.Refs{
+					Org:     "some-org",
+					Repo:    "some-repo",
+					BaseRef: "v1.2.3",
+					BaseSHA: "1234abcd",
+				},
+				Release: &v1alpha1.Release{
+					Tag:        "v1.2.3",
+					Name:       "Release 1.2.3",
+					Link:       "https://github.com/some-org/some-repo/releases/tag/v1.2.3",
+					Prerelease: true,
+				},
+			},
+			env: map[string]string{
+				v1alpha1.JobNameEnv:           "some-publish-job",
+				v1alpha1.JobTypeEnv:           string(config.PostsubmitJob),
+				v1alpha1.JobSpecEnv:           fmt.Sprintf("type:%s", config.PostsubmitJob),
+				v1alpha1.RepoNameEnv:          "some-repo",
+				v1alpha1.RepoOwnerEnv:         "some-org",
+				v1alpha1.PullBaseRefEnv:       "v1.2.3",
+				v1alpha1.PullBaseShaEnv:       "1234abcd",
+				v1alpha1.PullRefsEnv:          "v1.2.3:1234abcd",
+				v1alpha1.ReleaseTagEnv:        "v1.2.3",
+				v1alpha1.ReleaseNameEnv:       "Release 1.2.3",
+				v1alpha1.ReleaseURLEnv:        "https://github.com/some-org/some-repo/releases/tag/v1.2.3",
+				v1alpha1.ReleasePrereleaseEnv: "true",
+			},
+		},
 		{
 			name: "presubmit",
 			spec: &v1alpha1.LighthouseJobSpec{
@@ -85,6 +121,79 @@ func TestPipelineOptionsSpec_GetEnvVars(t *testing.T) {
 				v1alpha1.PullPullShaEnv: "5678",
 			},
 		},
+		{
+			name: "presubmit with parameters",
+			spec: &v1alpha1.LighthouseJobSpec{
+				Type:      config.PresubmitJob,
+				Namespace: "jx",
+				Job:       "some-perf-job",
+				Refs: &v1alpha1.Refs{
+					Org:     "some-org",
+					Repo:    "some-repo",
+					BaseRef: "master",
+					BaseSHA: "1234abcd",
+					Pulls: []v1alpha1.Pull{
+						{
+							Number: 1,
+							SHA:    "5678",
+						},
+					},
+				},
+				Parameters: map[string]string{
+					"ITERATIONS":        "10",
+					v1alpha1.JobNameEnv: "cannot-override",
+				},
+			},
+			env: map[string]string{
+				"ITERATIONS":            "10",
+				v1alpha1.JobNameEnv:     "some-perf-job",
+				v1alpha1.JobTypeEnv:     string(config.PresubmitJob),
+				v1alpha1.JobSpecEnv:     fmt.Sprintf("type:%s", config.PresubmitJob),
+				v1alpha1.RepoNameEnv:    "some-repo",
+				v1alpha1.RepoOwnerEnv:   "some-org",
+				v1alpha1.PullBaseRefEnv: "master",
+				v1alpha1.PullBaseShaEnv: "1234abcd",
+				v1alpha1.PullRefsEnv:    "master:1234abcd,1:5678",
+				v1alpha1.PullNumberEnv:  "1",
+				v1alpha1.PullPullShaEnv: "5678",
+			},
+		},
+		{
+			name: "presubmit with changes",
+			spec: &v1alpha1.LighthouseJobSpec{
+				Type:      config.PresubmitJob,
+				Namespace: "jx",
+				Job:       "some-pr-job",
+				Refs: &v1alpha1.Refs{
+					Org:     "some-org",
+					Repo:    "some-repo",
+					BaseRef: "master",
+					BaseSHA: "1234abcd",
+					Pulls: []v1alpha1.Pull{
+						{
+							Number: 1,
+							SHA:    "5678",
+						},
+					},
+				},
+				ChangedPaths:   []string{"api/main.go", "web/index.html"},
+				ChangedModules: []string{"api", "web"},
+			},
+			env: map[string]string{
+				v1alpha1.JobNameEnv:        "some-pr-job",
+				v1alpha1.JobTypeEnv:        string(config.PresubmitJob),
+				v1alpha1.JobSpecEnv:        fmt.Sprintf("type:%s", config.PresubmitJob),
+				v1alpha1.RepoNameEnv:       "some-repo",
+				v1alpha1.RepoOwnerEnv:      "some-org",
+				v1alpha1.PullBaseRefEnv:    "master",
+				v1alpha1.PullBaseShaEnv:    "1234abcd",
+				v1alpha1.PullRefsEnv:       "master:1234abcd,1:5678",
+				v1alpha1.PullNumberEnv:     "1",
+				v1alpha1.PullPullShaEnv:    "5678",
+				v1alpha1.ChangedPathsEnv:   "api/main.go\nweb/index.html",
+				v1alpha1.ChangedModulesEnv: "api\nweb",
+			},
+		},
 		{
 			name: "batch",
 			spec: &v1alpha1.LighthouseJobSpec{
@@ -117,6 +226,7 @@ func TestPipelineOptionsSpec_GetEnvVars(t *testing.T) {
 				v1alpha1.PullBaseRefEnv: "master",
 				v1alpha1.PullBaseShaEnv: "1234abcd",
 				v1alpha1.PullRefsEnv:    "master:1234abcd,1:5678,2:0efg",
+				v1alpha1.PullNumbersEnv: "1,2",
 			},
 		},
 	}
@@ -143,3 +253,71 @@ func TestPipelineOptionsSpec_GetEnvVars(t *testing.T) {
 		})
 	}
 }
+
+func TestLighthouseJobStatus_Stages(t *testing.T) {
+	start := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	startTime := metav1.NewTime(start)
+	completionTime := metav1.NewTime(start.Add(90 * time.Second))
+	status := v1alpha1.LighthouseJobStatus{
+		Stages: []v1alpha1.Stage{
+			{Name: "build", State: v1alpha1.SuccessState, StartTime: &startTime, CompletionTime: &completionTime},
+			{Name: "test", State: v1alpha1.RunningState, StartTime: &completionTime},
+			{Name: "lint", State: v1alpha1.RunningState, StartTime: &completionTime},
+			{Name: "deploy", State: v1alpha1.PendingState},
+		},
+	}
+
+	if d := cmp.Diff([]string{"test", "lint"}, status.RunningStages()); d != "" {
+		t.Errorf("Running stages did not match expected: %s", d)
+	}
+	now := start.Add(2 * time.Minute)
+	if d := status.Stages[0].Duration(now); d != 90*time.Second {
+		t.Errorf("Expected the completed stage to take 1m30s but got %s", d)
+	}
+	if d := status.Stages[1].Duration(now); d != 30*time.Second {
+		t.Errorf("Expected the running stage to have taken 30s so far but got %s", d)
+	}
+	if d := status.Stages[3].Duration(now); d != 0 {
+		t.Errorf("Expected the pending stage to have no duration but got %s", d)
+	}
+}
+
+func TestLighthouseJobSpec_Validate(t *testing.T) {
+	pull := v1alpha1.Pull{Number: 1, SHA: "abcdef1234567", Ref: "refs/pull/1/head"}
+	refs := func(pulls ...v1alpha1.Pull) *v1alpha1.Refs {
+		return &v1alpha1.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "1234567890abcdef", Pulls: pulls}
+	}
+	testCases := []struct {
+		name  string
+		spec  v1alpha1.LighthouseJobSpec
+		valid bool
+	}{
+		{name: "presubmit", spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Job: "unit", Refs: refs(pull)}, valid: true},
+		{name: "postsubmit", spec: v1alpha1.LighthouseJobSpec{Type: config.PostsubmitJob, Job: "release", Refs: refs()}, valid: true},
+		{name: "periodic without refs", spec: v1alpha1.LighthouseJobSpec{Type: config.PeriodicJob, Job: "nightly"}, valid: true},
+		{name: "batch", spec: v1alpha1.LighthouseJobSpec{Type: config.BatchJob, Job: "unit", Refs: refs(pull, v1alpha1.Pull{Number: 2, SHA: "7654321fedcba"})}, valid: true},
+		{name: "gerrit ref", spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Job: "unit", Refs: refs(v1alpha1.Pull{Number: 123, SHA: "abcdef1", Ref: "refs/changes/00/123/1"})}, valid: true},
+		{name: "missing type", spec: v1alpha1.LighthouseJobSpec{Job: "unit", Refs: refs(pull)}},
+		{name: "invalid type", spec: v1alpha1.LighthouseJobSpec{Type: "nightly", Job: "unit", Refs: refs(pull)}},
+		{name: "missing job", spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Refs: refs(pull)}},
+		{name: "missing refs", spec: v1alpha1.LighthouseJobSpec{Type: config.PostsubmitJob, Job: "release"}},
+		{name: "missing repo", spec: v1alpha1.LighthouseJobSpec{Type: config.PostsubmitJob, Job: "release", Refs: &v1alpha1.Refs{Org: "org"}}},
+		{name: "presubmit without pull", spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Job: "unit", Refs: refs()}},
+		{name: "batch without pulls", spec: v1alpha1.LighthouseJobSpec{Type: config.BatchJob, Job: "unit", Refs: refs()}},
+		{name: "invalid pull number", spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Job: "unit", Refs: refs(v1alpha1.Pull{SHA: "abcdef1"})}},
+		{name: "duplicate pulls", spec: v1alpha1.LighthouseJobSpec{Type: config.BatchJob, Job: "unit", Refs: refs(pull, pull)}},
+		{name: "missing sha", spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Job: "unit", Refs: refs(v1alpha1.Pull{Number: 1})}},
+		{name: "invalid base sha", spec: v1alpha1.LighthouseJobSpec{Type: config.PostsubmitJob, Job: "release", Refs: &v1alpha1.Refs{Org: "org", Repo: "repo", BaseSHA: "master"}}},
+		{name: "malformed ref", spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Job: "unit", Refs: refs(v1alpha1.Pull{Number: 1, SHA: "abcdef1", Ref: "pull/1/head; rm -rf"})}},
+		{name: "ref escaping", spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Job: "unit", Refs: refs(v1alpha1.Pull{Number: 1, SHA: "abcdef1", Ref: "refs/../heads/master"})}},
+	}
+	for _, tc := range testCases {
+		err := tc.spec.Validate()
+		if tc.valid && err != nil {
+			t.Errorf("%s: expected a valid spec but got %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected an invalid spec", tc.name)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+)
+
+// shaRegex matches the abbreviated and full SHA-1 and SHA-256 commit hashes
+var shaRegex = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// Validate checks that the spec can be launched and reported: its type is known, the jobs of a repository
+// have refs and the pull requests of the presubmits and batches are well formed. Invalid jobs are rejected
+// when they are created so that they fail fast instead of confusing foghorn and the launchers.
+func (s *LighthouseJobSpec) Validate() error {
+	switch s.Type {
+	case config.PresubmitJob, config.PostsubmitJob, config.PeriodicJob, config.BatchJob:
+	case "":
+		return fmt.Errorf("missing type")
+	default:
+		return fmt.Errorf("invalid type %q, it must be one of presubmit, postsubmit, periodic or batch", s.Type)
+	}
+	if s.Job == "" {
+		return fmt.Errorf("missing job")
+	}
+	if s.Refs == nil {
+		if s.Type == config.PeriodicJob {
+			return nil
+		}
+		return fmt.Errorf("missing refs for %s job %s", s.Type, s.Job)
+	}
+	if err := s.Refs.Validate(); err != nil {
+		return fmt.Errorf("invalid refs for job %s: %v", s.Job, err)
+	}
+	switch s.Type {
+	case config.PresubmitJob:
+		if len(s.Refs.Pulls) != 1 {
+			return fmt.Errorf("presubmit job %s must have exactly one pull, it has %d", s.Job, len(s.Refs.Pulls))
+		}
+	case config.BatchJob:
+		if len(s.Refs.Pulls) == 0 {
+			return fmt.Errorf("batch job %s must have pulls", s.Job)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the refs name a repository and that their pulls are well formed
+func (r *Refs) Validate() error {
+	if r.Org == "" {
+		return fmt.Errorf("missing org")
+	}
+	if r.Repo == "" {
+		return fmt.Errorf("missing repo")
+	}
+	if r.BaseSHA != "" && !shaRegex.MatchString(r.BaseSHA) {
+		return fmt.Errorf("invalid base_sha %q", r.BaseSHA)
+	}
+	numbers := map[int]bool{}
+	for _, pull := range r.Pulls {
+		if pull.Number <= 0 {
+			return fmt.Errorf("invalid pull number %d", pull.Number)
+		}
+		if numbers[pull.Number] {
+			return fmt.Errorf("duplicate pull %d", pull.Number)
+		}
+		numbers[pull.Number] = true
+		if !shaRegex.MatchString(pull.SHA) {
+			return fmt.Errorf("invalid sha %q of pull %d", pull.SHA, pull.Number)
+		}
+		if pull.Ref != "" && !validRefName(pull.Ref) {
+			return fmt.Errorf("malformed ref %q of pull %d", pull.Ref, pull.Number)
+		}
+	}
+	return nil
+}
+
+// validRefName returns true if the ref can be fetched by git, following the rules of git check-ref-format
+func validRefName(ref string) bool {
+	if strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "-") || strings.HasSuffix(ref, "/") || strings.HasSuffix(ref, ".") {
+		return false
+	}
+	if strings.Contains(ref, "..") || strings.Contains(ref, "//") || strings.Contains(ref, "@{") {
+		return false
+	}
+	for _, c := range ref {
+		if c <= ' ' || c == 0x7f || strings.ContainsRune(`~^:?*[\`, c) {
+			return false
+		}
+	}
+	for _, part := range strings.Split(ref, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false
+		}
+	}
+	return true
+}
@@ -27,13 +27,9 @@ func GetClientsAndNamespace(factory jxfactory.Factory) (tektonclient.Interface,
 		return nil, nil, nil, nil, "", errors.Wrap(err, "unable to create JX client")
 	}
 
-	kubeClient, ns, err := factory.CreateKubeClient()
-	if err != nil {
-		return nil, nil, nil, nil, "", errors.Wrap(err, "unable to create Kube client")
-	}
-	ns, _, err = kube.GetDevNamespace(kubeClient, ns)
+	kubeClient, ns, err := kubeClientAndNamespace(factory)
 	if err != nil {
-		return nil, nil, nil, nil, "", errors.Wrap(err, "unable to find the dev namespace")
+		return nil, nil, nil, nil, "", err
 	}
 
 	config, err := factory.CreateKubeConfig()
@@ -47,3 +43,70 @@ func GetClientsAndNamespace(factory jxfactory.Factory) (tektonclient.Interface,
 
 	return tektonClient, jxClient, kubeClient, lhClient, ns, nil
 }
+
+// Clients are the clients of the API groups used by a component
+type Clients struct {
+	Tekton     tektonclient.Interface
+	JX         jxclient.Interface
+	Kube       kubeclient.Interface
+	Lighthouse clientset.Interface
+	Namespace  string
+}
+
+// GetClientsForComponent returns the kube client, the clients of the API groups in the
+// rules of the component and the dev namespace. The clients of other API groups are nil.
+func GetClientsForComponent(factory jxfactory.Factory, component Component) (*Clients, error) {
+	if factory == nil {
+		factory = jxfactory.NewFactory()
+	}
+	kubeClient, ns, err := kubeClientAndNamespace(factory)
+	if err != nil {
+		return nil, err
+	}
+	c := &Clients{Kube: kubeClient, Namespace: ns}
+	if usesGroup(component, tektonGroup) {
+		c.Tekton, _, err = factory.CreateTektonClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create Tekton client")
+		}
+	}
+	if usesGroup(component, jxGroup) {
+		c.JX, _, err = factory.CreateJXClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create JX client")
+		}
+	}
+	if usesGroup(component, lighthouseGroup) {
+		config, err := factory.CreateKubeConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create kubeconfig for Lighthouse client")
+		}
+		c.Lighthouse, err = clientset.NewForConfig(config)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create Lighthouse client")
+		}
+	}
+	return c, nil
+}
+
+// GetAllClients returns the clients of all the API groups used by the components and the dev
+// namespace, so that the components running in the same process can share them
+func GetAllClients(factory jxfactory.Factory) (*Clients, error) {
+	tektonClient, jxClient, kubeClient, lhClient, ns, err := GetClientsAndNamespace(factory)
+	if err != nil {
+		return nil, err
+	}
+	return &Clients{Tekton: tektonClient, JX: jxClient, Kube: kubeClient, Lighthouse: lhClient, Namespace: ns}, nil
+}
+
+func kubeClientAndNamespace(factory jxfactory.Factory) (kubeclient.Interface, string, error) {
+	kubeClient, ns, err := factory.CreateKubeClient()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to create Kube client")
+	}
+	ns, _, err = kube.GetDevNamespace(kubeClient, ns)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to find the dev namespace")
+	}
+	return kubeClient, ns, nil
+}
@@ -0,0 +1,35 @@
+package clients
+
+import (
+	"time"
+
+	jxinformers "github.com/jenkins-x/jx-api/pkg/client/informers/externalversions"
+	lhinformers "github.com/jenkins-x/lighthouse/pkg/client/informers/externalversions"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	tektoninformers "github.com/tektoncd/pipeline/pkg/client/informers/externalversions"
+)
+
+// informerResyncPeriod is the resync period of the shared informers
+const informerResyncPeriod = 30 * time.Minute
+
+// Informers are the shared informer factories of the resources watched by the components, so that
+// the components running in the same process share their caches and watches
+type Informers struct {
+	JX         jxinformers.SharedInformerFactory
+	Lighthouse lhinformers.SharedInformerFactory
+	Tekton     tektoninformers.SharedInformerFactory
+}
+
+// NewInformers creates the informer factories of the namespace for the clients. The LighthouseJobs
+// are filtered with the job selector if it is not empty and the initial lists are paginated if the
+// page size is positive.
+func NewInformers(c *Clients, ns, jobSelector string, pageSize int64) *Informers {
+	return &Informers{
+		JX: jxinformers.NewSharedInformerFactoryWithOptions(c.JX, informerResyncPeriod, jxinformers.WithNamespace(ns),
+			jxinformers.WithTweakListOptions(util.TweakListOptions("", pageSize))),
+		Lighthouse: lhinformers.NewSharedInformerFactoryWithOptions(c.Lighthouse, informerResyncPeriod, lhinformers.WithNamespace(ns),
+			lhinformers.WithTweakListOptions(util.TweakListOptions(jobSelector, pageSize))),
+		Tekton: tektoninformers.NewSharedInformerFactoryWithOptions(c.Tekton, informerResyncPeriod, tektoninformers.WithNamespace(ns),
+			tektoninformers.WithTweakListOptions(util.TweakListOptions("", pageSize))),
+	}
+}
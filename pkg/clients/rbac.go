@@ -0,0 +1,200 @@
+package clients
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+)
+
+// Component is a Lighthouse component which runs with its own ServiceAccount
+type Component string
+
+const (
+	// Webhooks is the component handling the webhooks of the git provider and running the plugins
+	Webhooks Component = "webhooks"
+	// Foghorn is the component reporting the status of pipelines to the git provider
+	Foghorn Component = "foghorn"
+	// Keeper is the component merging pull requests, including its status controller
+	Keeper Component = "keeper"
+	// GCJobs is the component garbage collecting old LighthouseJobs
+	GCJobs Component = "gc-jobs"
+	// Dashboard is the component serving the read-only web dashboard of the LighthouseJobs
+	Dashboard Component = "dashboard"
+)
+
+const (
+	jxGroup         = "jenkins.io"
+	tektonGroup     = "tekton.dev"
+	lighthouseGroup = "lighthouse.jenkins.io"
+)
+
+var (
+	readVerbs   = []string{"get", "list", "watch"}
+	writeVerbs  = []string{"create", "get", "list", "watch", "update", "patch"}
+	allVerbs    = []string{"create", "delete", "get", "list", "watch", "update", "patch"}
+	statusVerbs = []string{"get", "update", "patch"}
+)
+
+func rule(group string, resources []string, verbs []string) rbacv1.PolicyRule {
+	return rbacv1.PolicyRule{
+		APIGroups: []string{group},
+		Resources: resources,
+		Verbs:     verbs,
+	}
+}
+
+// componentRules are the minimal rules of the Role of each component in the namespace it runs in
+var componentRules = map[Component][]rbacv1.PolicyRule{
+	Webhooks: {
+		rule("", []string{"namespaces", "configmaps", "secrets"}, readVerbs),
+		// the config and plugins ConfigMaps are updated when repositories are renamed, the activity
+		// of the repositories is saved in a ConfigMap for the adoption report and the build numbers
+		// of the jobs launched by the tekton agent are allocated in a ConfigMap. The queued webhooks
+		// are kept in ConfigMaps until they are processed
+		rule("", []string{"configmaps"}, []string{"create", "update", "delete"}),
+		rule(jxGroup, []string{"pipelineactivities", "pipelinestructures", "sourcerepositories", "environments"}, writeVerbs),
+		rule(jxGroup, []string{"apps", "plugins"}, readVerbs),
+		rule(tektonGroup, []string{"pipelineresources", "tasks", "pipelines", "pipelineruns"}, []string{"create", "get", "list", "update"}),
+		rule(lighthouseGroup, []string{"lighthousejobs"}, allVerbs),
+		rule(lighthouseGroup, []string{"lighthousejobs/status"}, statusVerbs),
+	},
+	Foghorn: {
+		rule("", []string{"namespaces", "configmaps", "secrets"}, readVerbs),
+		rule("", []string{"pods", "pods/log", "events"}, []string{"get", "list"}),
+		// foghorn launches the retries of the errored jobs, whose build numbers are allocated in a
+		// ConfigMap by the tekton agent
+		rule("", []string{"configmaps"}, []string{"create", "update"}),
+		rule(jxGroup, []string{"apps", "environments", "pipelineactivities", "sourcerepositories", "pipelinestructures"}, writeVerbs),
+		rule(tektonGroup, []string{"pipelineresources", "tasks", "pipelines", "pipelineruns"}, []string{"create", "get", "list", "watch", "update"}),
+		rule(tektonGroup, []string{"taskruns"}, readVerbs),
+		rule(lighthouseGroup, []string{"lighthousejobs"}, writeVerbs),
+		rule(lighthouseGroup, []string{"lighthousejobs/status"}, statusVerbs),
+	},
+	Keeper: {
+		rule("", []string{"namespaces", "configmaps"}, readVerbs),
+		// the PRs tracked for the stuck PR escalations are saved in a ConfigMap and the build numbers
+		// of the jobs launched by the tekton agent are allocated in a ConfigMap
+		rule("", []string{"configmaps"}, []string{"create", "update"}),
+		rule(jxGroup, []string{"apps", "environments", "pipelineactivities", "sourcerepositories", "pipelinestructures"}, writeVerbs),
+		rule(tektonGroup, []string{"pipelineresources", "tasks", "pipelines", "pipelineruns"}, allVerbs),
+		rule(lighthouseGroup, []string{"lighthousejobs"}, allVerbs),
+		rule(lighthouseGroup, []string{"lighthousejobs/status"}, statusVerbs),
+	},
+	GCJobs: {
+		rule("", []string{"namespaces", "configmaps"}, readVerbs),
+		rule(lighthouseGroup, []string{"lighthousejobs"}, []string{"delete", "get", "list"}),
+	},
+	Dashboard: {
+		rule(lighthouseGroup, []string{"lighthousejobs"}, readVerbs),
+	},
+}
+
+// Rules returns the minimal rules the Role of the component needs
+func Rules(component Component) []rbacv1.PolicyRule {
+	return componentRules[component]
+}
+
+// Role returns the minimal Role of the component, which is used to generate the
+// Roles of the chart and documentation
+func Role(component Component, name, ns string) *rbacv1.Role {
+	return &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Role",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Rules: Rules(component),
+	}
+}
+
+// usesGroup returns true if the rules of the component include the API group
+func usesGroup(component Component, group string) bool {
+	for _, r := range Rules(component) {
+		for _, g := range r.APIGroups {
+			if g == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckPermissions reviews each permission of the rules of the component with the
+// ServiceAccount of the running process, returning the permissions which are missing
+// in the namespace as "verb resource.group" strings
+func CheckPermissions(kubeClient kubeclient.Interface, component Component, ns string) ([]string, error) {
+	var missing []string
+	for _, r := range Rules(component) {
+		for _, group := range r.APIGroups {
+			for _, resource := range r.Resources {
+				// namespaces are cluster scoped so cannot be reviewed in a namespace
+				if group == "" && resource == "namespaces" {
+					continue
+				}
+				for _, verb := range r.Verbs {
+					allowed, err := canI(kubeClient, ns, group, resource, verb)
+					if err != nil {
+						return missing, err
+					}
+					if !allowed {
+						missing = append(missing, permissionName(group, resource, verb))
+					}
+				}
+			}
+		}
+	}
+	return missing, nil
+}
+
+// ReportMissingPermissions logs the permissions missing from the ServiceAccount of the
+// component so misconfigured Roles are reported clearly on startup
+func ReportMissingPermissions(kubeClient kubeclient.Interface, component Component, ns string) {
+	log := logrus.WithFields(logrus.Fields{"component": string(component), "namespace": ns})
+	missing, err := CheckPermissions(kubeClient, component, ns)
+	if err != nil {
+		log.WithError(err).Warn("Failed to check the permissions of the service account.")
+		return
+	}
+	if len(missing) > 0 {
+		log.Errorf("The service account is missing the permissions: %s. Add them to the Role of the %s component.", strings.Join(missing, ", "), component)
+	}
+}
+
+func canI(kubeClient kubeclient.Interface, ns, group, resource, verb string) (bool, error) {
+	subresource := ""
+	if parts := strings.SplitN(resource, "/", 2); len(parts) == 2 {
+		resource, subresource = parts[0], parts[1]
+	}
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   ns,
+				Group:       group,
+				Resource:    resource,
+				Subresource: subresource,
+				Verb:        verb,
+			},
+		},
+	}
+	result, err := kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to review permission %s", permissionName(group, resource, verb))
+	}
+	return result.Status.Allowed, nil
+}
+
+func permissionName(group, resource, verb string) string {
+	if group == "" {
+		return fmt.Sprintf("%s %s", verb, resource)
+	}
+	return fmt.Sprintf("%s %s.%s", verb, resource, group)
+}
@@ -0,0 +1,68 @@
+package clients
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+)
+
+// TestChartRolesMatchRules checks the Roles of the chart grant the rules of each component
+func TestChartRolesMatchRules(t *testing.T) {
+	for _, component := range []Component{Webhooks, Foghorn, Keeper, GCJobs, Dashboard} {
+		file := filepath.Join("..", "..", "charts", "lighthouse", "templates", string(component)+"-role.yaml")
+		data, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+
+		// drop the templated name so the Role can be parsed
+		var lines []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.Contains(line, "{{") {
+				lines = append(lines, line)
+			}
+		}
+		role := rbacv1.Role{}
+		require.NoError(t, yaml.Unmarshal([]byte(strings.Join(lines, "\n")), &role), file)
+		assert.Equal(t, Rules(component), role.Rules, "the rules of %s do not match the code, regenerate them from clients.Rules", file)
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	var reviewed []string
+	kubeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		reviewed = append(reviewed, attrs.Verb+" "+attrs.Resource+"/"+attrs.Subresource)
+		review.Status.Allowed = attrs.Namespace == "jx" && !(attrs.Resource == "lighthousejobs" && attrs.Verb == "delete")
+		return true, review, nil
+	})
+
+	missing, err := CheckPermissions(kubeClient, GCJobs, "jx")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"delete lighthousejobs.lighthouse.jenkins.io"}, missing)
+	assert.NotContains(t, reviewed, "get namespaces/", "namespaces are cluster scoped")
+
+	reviewed = nil
+	missing, err = CheckPermissions(kubeClient, Foghorn, "jx")
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.Contains(t, reviewed, "get pods/log")
+	assert.Contains(t, reviewed, "update lighthousejobs/status")
+}
+
+func TestUsesGroup(t *testing.T) {
+	assert.True(t, usesGroup(Keeper, tektonGroup))
+	assert.True(t, usesGroup(Foghorn, tektonGroup))
+	assert.False(t, usesGroup(GCJobs, jxGroup))
+	assert.True(t, usesGroup(GCJobs, lighthouseGroup))
+}
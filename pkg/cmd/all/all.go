@@ -0,0 +1,211 @@
+// Package all contains the all command which runs several Lighthouse components in a single
+// process, for small installations and local development.
+package all
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/jenkins-x/jx/v2/pkg/jxfactory"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/dashboardcmd"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/foghorncmd"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/keepercmd"
+	"github.com/jenkins-x/lighthouse/pkg/interrupts"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/jenkins-x/lighthouse/pkg/watcher"
+	"github.com/jenkins-x/lighthouse/pkg/webhook"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// Webhook is the component handling the webhooks of the git provider and running the plugins
+	Webhook = "webhook"
+	// Foghorn is the component reporting the status of the pipelines
+	Foghorn = "foghorn"
+	// Keeper is the component merging the pull requests
+	Keeper = "keeper"
+	// Dashboard is the component serving the read-only web dashboard of the LighthouseJobs
+	Dashboard = "dashboard"
+)
+
+var (
+	// components are the components which can be run
+	components = []string{Webhook, Foghorn, Keeper, Dashboard}
+	// defaultComponents are the components which are run by default
+	defaultComponents = []string{Webhook, Foghorn, Keeper}
+)
+
+// Options are the options of the all command
+type Options struct {
+	Components string
+
+	Webhook   *webhook.Options
+	Foghorn   foghorncmd.Options
+	Keeper    keepercmd.Options
+	Dashboard dashboardcmd.Options
+
+	factory jxfactory.Factory
+}
+
+// NewCmdAll creates the all command
+func NewCmdAll() *cobra.Command {
+	factory := jxfactory.NewFactory()
+	o := &Options{
+		Webhook: webhook.NewWebhook(factory, nil),
+		factory: factory,
+	}
+	cmd := &cobra.Command{
+		Use:   "all",
+		Short: "Runs several Lighthouse components in a single process",
+		Long: `Runs the selected components in a single process sharing the Kubernetes clients, the informers and the
+configuration loaded from the config and plugins ConfigMaps, or from the --config-file and --plugin-file
+files, for small installations and local development.
+
+The webhook flags are the flags of the lighthouse command, the flags of the other components are prefixed
+with their name, e.g. --keeper-port or --foghorn-watch-pipelineruns. The dashboard is only run when it is
+selected. The service account needs the permissions of all the selected components.`,
+		Example: "  lighthouse all --components=webhook,foghorn,keeper",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVar(&o.Components, "components", strings.Join(defaultComponents, ","), "Comma separated components to run, amongst "+strings.Join(components, ", "))
+	o.Webhook.AddFlags(cmd.Flags())
+
+	fs := flag.NewFlagSet(Foghorn, flag.ContinueOnError)
+	o.Foghorn.AddFlags(fs)
+	addPrefixedFlags(cmd.Flags(), Foghorn, fs)
+	fs = flag.NewFlagSet(Keeper, flag.ContinueOnError)
+	o.Keeper.AddFlags(fs)
+	addPrefixedFlags(cmd.Flags(), Keeper, fs)
+	fs = flag.NewFlagSet(Dashboard, flag.ContinueOnError)
+	o.Dashboard.AddFlags(fs)
+	addPrefixedFlags(cmd.Flags(), Dashboard, fs)
+	return cmd
+}
+
+// Run runs the components until one of them fails or they are all stopped
+func (o *Options) Run() error {
+	selected, err := parseComponents(o.Components)
+	if err != nil {
+		return err
+	}
+	if selected.Has(Keeper) {
+		if err := o.Keeper.Validate(); err != nil {
+			return errors.Wrap(err, "invalid keeper options")
+		}
+	}
+	if selected.Has(Foghorn) {
+		if err := o.Foghorn.Validate(); err != nil {
+			return errors.Wrap(err, "invalid foghorn options")
+		}
+	}
+	if selected.Has(Dashboard) {
+		if err := o.Dashboard.Validate(); err != nil {
+			return errors.Wrap(err, "invalid dashboard options")
+		}
+	}
+	stopCh := interrupts.Context().Done()
+
+	kubeClients, err := clients.GetAllClients(o.factory)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the Kubernetes clients")
+	}
+	ns := kubeClients.Namespace
+	configAgent := &config.Agent{}
+	settingsAgent := &settings.Agent{}
+	pluginAgent := &plugins.ConfigAgent{}
+	configFile, pluginFile := o.Webhook.ConfigFiles()
+	fileWatcher, configMapWatcher, err := watcher.NewConfigAgentFileWatchers(kubeClients.Kube, ns, configFile, pluginFile, configAgent, settingsAgent, pluginAgent, stopCh)
+	if err != nil {
+		return err
+	}
+	defer fileWatcher.Stop()
+	defer configMapWatcher.Stop()
+	if configAgent.Config() == nil {
+		if configFile != "" {
+			return errors.Errorf("no configuration found in %s", configFile)
+		}
+		return errors.Errorf("no configuration found in the %s namespace", ns)
+	}
+
+	errs := make(chan error, selected.Len())
+	run := func(component string, fn func() error) {
+		logrus.Infof("starting %s", component)
+		go func() {
+			errs <- errors.Wrapf(fn(), "failed to run %s", component)
+		}()
+	}
+	if selected.Has(Keeper) {
+		run(Keeper, func() error {
+			return o.Keeper.Run(kubeClients, configAgent, settingsAgent)
+		})
+	}
+	if selected.Has(Foghorn) {
+		if o.Foghorn.Namespace == "" {
+			o.Foghorn.Namespace = ns
+		}
+		informers := o.Foghorn.NewInformers(kubeClients)
+		run(Foghorn, func() error {
+			return o.Foghorn.Run(kubeClients, informers, configAgent, settingsAgent, pluginAgent, stopCh)
+		})
+	}
+	if selected.Has(Dashboard) {
+		if o.Dashboard.Namespace == "" {
+			o.Dashboard.Namespace = ns
+		}
+		run(Dashboard, func() error {
+			return o.Dashboard.Run(kubeClients, stopCh)
+		})
+	}
+	if selected.Has(Webhook) {
+		o.Webhook.SetClients(kubeClients)
+		o.Webhook.SetConfigAgents(configAgent, settingsAgent, pluginAgent)
+		run(Webhook, o.Webhook.Run)
+	}
+
+	for range selected {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	interrupts.WaitForGracefulShutdown()
+	return nil
+}
+
+// parseComponents parses the comma separated components to run
+func parseComponents(value string) (sets.String, error) {
+	selected := sets.NewString()
+	for _, c := range strings.Split(value, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			selected.Insert(c)
+		}
+	}
+	if selected.Len() == 0 {
+		return nil, errors.New("no component to run")
+	}
+	if unknown := selected.Difference(sets.NewString(components...)); unknown.Len() > 0 {
+		return nil, errors.Errorf("unknown components %s, expected some of %s", strings.Join(unknown.List(), ", "), strings.Join(components, ", "))
+	}
+	return selected, nil
+}
+
+// addPrefixedFlags adds the flags of a component to the command line flags, prefixed with the
+// name of the component
+func addPrefixedFlags(flags *pflag.FlagSet, prefix string, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		pf := pflag.PFlagFromGoFlag(f)
+		pf.Name = prefix + "-" + f.Name
+		pf.Shorthand = ""
+		flags.AddFlag(pf)
+	})
+}
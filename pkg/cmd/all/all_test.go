@@ -0,0 +1,52 @@
+package all
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseComponents(t *testing.T) {
+	selected, err := parseComponents("webhook, keeper,,")
+	require.NoError(t, err)
+	assert.Equal(t, []string{Keeper, Webhook}, selected.List())
+
+	_, err = parseComponents(" , ")
+	assert.EqualError(t, err, "no component to run")
+
+	_, err = parseComponents("webhook,tide")
+	assert.EqualError(t, err, "unknown components tide, expected some of webhook, foghorn, keeper, dashboard")
+}
+
+func TestFlags(t *testing.T) {
+	cmd := NewCmdAll()
+	require.NoError(t, cmd.ParseFlags([]string{
+		"--components=keeper",
+		"--port=9090",
+		"--keeper-port=9999",
+		"--keeper-merge-interval=1m",
+		"--foghorn-namespace=jx",
+		"--foghorn-dry-run=false",
+		"--dashboard-port=9091",
+	}))
+	components, err := cmd.Flags().GetString("components")
+	require.NoError(t, err)
+	assert.Equal(t, "keeper", components)
+	port, err := cmd.Flags().GetInt("port")
+	require.NoError(t, err)
+	assert.Equal(t, 9090, port)
+	keeperPort, err := cmd.Flags().GetInt("keeper-port")
+	require.NoError(t, err)
+	assert.Equal(t, 9999, keeperPort)
+	interval, err := cmd.Flags().GetDuration("keeper-merge-interval")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, interval)
+	assert.Equal(t, "jx", cmd.Flag("foghorn-namespace").Value.String())
+	assert.Equal(t, "false", cmd.Flag("foghorn-dry-run").Value.String())
+	assert.Equal(t, "9091", cmd.Flag("dashboard-port").Value.String())
+
+	_, err = parseComponents(cmd.Flag("components").DefValue)
+	assert.NoError(t, err)
+}
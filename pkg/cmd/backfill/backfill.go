@@ -0,0 +1,99 @@
+// Package backfill contains the backfill-statuses command which re-reports the final statuses of the
+// LighthouseJobs completed during an outage of the git provider.
+package backfill
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/foghorn"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Options are the options of the backfill-statuses command
+type Options struct {
+	ID       string
+	Since    string
+	Until    string
+	Interval time.Duration
+	Force    bool
+	DryRun   bool
+}
+
+// NewCmdBackfill creates the backfill-statuses command
+func NewCmdBackfill() *cobra.Command {
+	o := &Options{}
+	cmd := &cobra.Command{
+		Use:   "backfill-statuses",
+		Short: "Re-reports the final statuses of the LighthouseJobs completed during an outage of the git provider",
+		Long: `Re-reports the final status of every LighthouseJob completed in the outage window whose status was not
+reported, so that pull requests are not blocked on missing contexts after the git provider was down.
+
+The statuses are reported with the git credentials of the environment, like the ones of the webhooks, one
+report per --interval so that the rate limits of the git provider are not exceeded. Each job reported is
+annotated with the backfill ID, an interrupted backfill is resumed by running it again with the same ID.`,
+		Example: "  lighthouse backfill-statuses --since 2020-06-01T10:00:00Z --until 2020-06-01T12:00:00Z --interval 2s",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVar(&o.ID, "id", "", "The ID of the backfill used to resume it. Defaults to the outage window")
+	cmd.Flags().StringVar(&o.Since, "since", "", "The start of the outage window, in RFC3339 format")
+	cmd.Flags().StringVar(&o.Until, "until", "", "The end of the outage window, in RFC3339 format. Defaults to now")
+	cmd.Flags().DurationVar(&o.Interval, "interval", time.Second, "The minimum duration between two status reports")
+	cmd.Flags().BoolVar(&o.Force, "force", false, "Re-reports the statuses of the jobs which were already reported in their final state")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "Only logs the statuses which would be reported")
+	return cmd
+}
+
+// Run backfills the statuses of the jobs completed in the outage window
+func (o *Options) Run() error {
+	opts := foghorn.BackfillOptions{
+		ID:       o.ID,
+		Interval: o.Interval,
+		Force:    o.Force,
+		DryRun:   o.DryRun,
+	}
+	var err error
+	if o.Since == "" {
+		return errors.New("no --since given")
+	}
+	opts.Since, err = time.Parse(time.RFC3339, o.Since)
+	if err != nil {
+		return errors.Wrapf(err, "invalid --since %q", o.Since)
+	}
+	if o.Until != "" {
+		opts.Until, err = time.Parse(time.RFC3339, o.Until)
+		if err != nil {
+			return errors.Wrapf(err, "invalid --until %q", o.Until)
+		}
+	} else {
+		opts.Until = time.Now()
+	}
+	if opts.ID == "" {
+		opts.ID = fmt.Sprintf("%d-%d", opts.Since.Unix(), opts.Until.Unix())
+	}
+
+	kubeClients, err := clients.GetClientsForComponent(nil, clients.Webhooks)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the Kubernetes clients")
+	}
+	result, err := foghorn.NewBackfiller(kubeClients.Lighthouse, kubeClients.Namespace).Backfill(opts)
+	if result != nil {
+		logrus.WithFields(logrus.Fields{
+			"id":       opts.ID,
+			"reported": result.Reported,
+			"skipped":  result.Skipped,
+			"failed":   result.Failed,
+		}).Info("backfilled the statuses")
+		if err == nil && result.Failed > 0 {
+			err = errors.Errorf("failed to report %d statuses, run the backfill again with --id=%s to retry them", result.Failed, opts.ID)
+		}
+	}
+	return err
+}
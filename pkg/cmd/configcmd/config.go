@@ -0,0 +1,17 @@
+// Package configcmd contains the config commands which check the Lighthouse configuration, e.g. to gate the
+// changes of config.yaml and plugins.yaml in CI before they are applied to the cluster.
+package configcmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// NewCmdConfig creates the config command which groups the configuration commands
+func NewCmdConfig() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Commands for checking the Lighthouse configuration",
+	}
+	cmd.AddCommand(NewCmdValidate())
+	return cmd
+}
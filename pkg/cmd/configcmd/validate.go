@@ -0,0 +1,273 @@
+package configcmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/keeper"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// ValidateOptions are the options of the config validate command
+type ValidateOptions struct {
+	ConfigFile string
+	PluginFile string
+	Namespace  string
+}
+
+// NewCmdValidate creates the config validate command
+func NewCmdValidate() *cobra.Command {
+	o := &ValidateOptions{}
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validates config.yaml and plugins.yaml",
+		Long: `Loads config.yaml and plugins.yaml the way Lighthouse does and reports every problem found: the job
+definitions, the keeper queries and their settings, the templates of the settings, such as the target URL
+and status context templates, and the references between the files, such as the plugins enabled for the
+repositories and the jobs named by the settings.
+
+The files are read from --config-file and --plugin-file, or from the 'config' and 'plugins' ConfigMaps of
+the namespace if they are not given. The command exits with a non-zero status if any problem is found, so
+that it can gate the changes of the configuration in CI.`,
+		Example: "  lighthouse config validate --config-file config.yaml --plugin-file plugins.yaml",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVar(&o.ConfigFile, "config-file", "", "Path to the config.yaml file. If not specified it is loaded from the 'config' ConfigMap")
+	cmd.Flags().StringVar(&o.PluginFile, "plugin-file", "", "Path to the plugins.yaml file. If not specified it is loaded from the 'plugins' ConfigMap")
+	cmd.Flags().StringVar(&o.Namespace, "namespace", "", "The namespace of the ConfigMaps. Defaults to the dev namespace")
+	return cmd
+}
+
+// Run validates the configuration, printing the problems found
+func (o *ValidateOptions) Run() error {
+	configData, pluginData, err := o.load()
+	if err != nil {
+		return err
+	}
+	problems := Validate(configData, pluginData, sets.StringKeySet(plugins.HelpProviders()))
+	if len(problems) == 0 {
+		fmt.Println("the configuration is valid")
+		return nil
+	}
+	for _, problem := range problems {
+		fmt.Printf("  %v\n", problem)
+	}
+	return errors.Errorf("found %d problems in the configuration", len(problems))
+}
+
+// load returns the config.yaml and plugins.yaml data of the files, or of the ConfigMaps for the files which
+// are not given
+func (o *ValidateOptions) load() ([]byte, []byte, error) {
+	var configData, pluginData []byte
+	var err error
+	if o.ConfigFile != "" {
+		if configData, err = ioutil.ReadFile(o.ConfigFile); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read %s", o.ConfigFile)
+		}
+	}
+	if o.PluginFile != "" {
+		if pluginData, err = ioutil.ReadFile(o.PluginFile); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read %s", o.PluginFile)
+		}
+	}
+	if o.ConfigFile != "" && o.PluginFile != "" {
+		return configData, pluginData, nil
+	}
+
+	kubeClients, err := clients.GetClientsForComponent(nil, clients.Webhooks)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create the Kubernetes clients")
+	}
+	ns := o.Namespace
+	if ns == "" {
+		ns = kubeClients.Namespace
+	}
+	configMapData := func(name, key string) ([]byte, error) {
+		cm, err := kubeClients.Kube.CoreV1().ConfigMaps(ns).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the %s ConfigMap of namespace %s", name, ns)
+		}
+		return []byte(cm.Data[key]), nil
+	}
+	if o.ConfigFile == "" {
+		if configData, err = configMapData(util.ProwConfigMapName, util.ProwConfigFilename); err != nil {
+			return nil, nil, err
+		}
+	}
+	if o.PluginFile == "" {
+		if pluginData, err = configMapData(util.ProwPluginsConfigMapName, util.ProwPluginsFilename); err != nil {
+			return nil, nil, err
+		}
+	}
+	return configData, pluginData, nil
+}
+
+// Validate returns the problems of the config.yaml and plugins.yaml data, whose plugins must be among the
+// known plugins. The problems are prefixed with the file they are found in. Empty data is ignored, as it is
+// by Lighthouse.
+func Validate(configData, pluginData []byte, knownPlugins sets.String) []error {
+	v := &validator{}
+	if len(configData) > 0 {
+		v.validateConfig(configData)
+	}
+	if len(pluginData) > 0 {
+		v.validatePlugins(pluginData, knownPlugins)
+	}
+	return v.problems
+}
+
+// validator collects the problems of the configuration
+type validator struct {
+	problems []error
+}
+
+func (v *validator) addf(file, format string, args ...interface{}) {
+	v.problems = append(v.problems, errors.Errorf("%s: %s", file, fmt.Sprintf(format, args...)))
+}
+
+// validateConfig checks the jobs, the keeper queries and the lighthouse settings of config.yaml
+func (v *validator) validateConfig(data []byte) {
+	file := util.ProwConfigFilename
+	cfg, err := config.LoadYAMLConfig(data)
+	if err != nil {
+		v.addf(file, "%v", err)
+		cfg = nil
+	}
+	s, err := settings.Load(data)
+	if err != nil {
+		v.addf(file, "%v", err)
+	}
+	if s != nil {
+		for i, q := range s.Keeper.Queries {
+			if _, err := keeper.NewPoolFilter(q.ExcludedTitles, q.ExcludedPaths); err != nil {
+				v.addf(file, "invalid tide.queries[%d]: %v", i, err)
+			}
+		}
+	}
+	if cfg == nil {
+		return
+	}
+	v.validateKeeperQueries(file, cfg, s)
+	if s == nil {
+		return
+	}
+	if _, err := jobutil.ApplyStatusContexts(cfg, s.StatusContexts); err != nil {
+		v.addf(file, "invalid statusContexts: %v", err)
+	}
+
+	presubmits, postsubmits := sets.NewString(), sets.NewString()
+	for _, jobs := range cfg.Presubmits {
+		for _, job := range jobs {
+			presubmits.Insert(job.Name)
+		}
+	}
+	for _, jobs := range cfg.Postsubmits {
+		for _, job := range jobs {
+			postsubmits.Insert(job.Name)
+		}
+	}
+	all := presubmits.Union(postsubmits)
+	for _, job := range cfg.Periodics {
+		all.Insert(job.Name)
+	}
+	for _, job := range sets.StringKeySet(s.PresubmitFilters.SkipIfOnlyChanged).List() {
+		if !presubmits.Has(job) {
+			v.addf(file, "presubmitFilters.skipIfOnlyChanged names %s which is not a presubmit", job)
+		}
+	}
+	for _, job := range sets.StringKeySet(s.StatusContexts.Jobs).List() {
+		if !presubmits.Has(job) && !postsubmits.Has(job) {
+			v.addf(file, "statusContexts.jobs names %s which is not a presubmit or postsubmit", job)
+		}
+	}
+	for _, job := range sets.StringKeySet(s.Foghorn.ReportURL.Jobs).List() {
+		if !all.Has(job) {
+			v.addf(file, "foghorn.reportURL.jobs names %s which is not a job", job)
+		}
+	}
+}
+
+// validateKeeperQueries checks that the keeper queries name the repositories they match
+func (v *validator) validateKeeperQueries(file string, cfg *config.Config, s *settings.Config) {
+	for i, q := range cfg.Keeper.Queries {
+		if len(q.Orgs) == 0 && len(q.Repos) == 0 {
+			v.addf(file, "tide.queries[%d] names no orgs or repos, so it never matches a pull request", i)
+		}
+		for _, repo := range q.Repos {
+			if parts := strings.Split(repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				v.addf(file, "tide.queries[%d] has the repo %q which is not in org/repo format", i, repo)
+			}
+		}
+		if s != nil && s.Keeper.Query(i).OrgDefault && len(q.Orgs) == 0 {
+			v.addf(file, "tide.queries[%d] is an org default but names no orgs", i)
+		}
+	}
+}
+
+// validatePlugins checks the plugin configuration and the lighthouse plugin settings of plugins.yaml
+func (v *validator) validatePlugins(data []byte, knownPlugins sets.String) {
+	file := util.ProwPluginsFilename
+	pc := &plugins.Configuration{}
+	if err := yaml.Unmarshal(data, pc); err != nil {
+		v.addf(file, "failed to parse: %v", err)
+		return
+	}
+	if err := pc.Validate(); err != nil {
+		v.addf(file, "%v", err)
+	}
+	known := strings.Join(knownPlugins.List(), ", ")
+	for _, key := range sets.StringKeySet(pc.Plugins).List() {
+		for _, name := range pc.Plugins[key] {
+			if !knownPlugins.Has(name) {
+				v.addf(file, "unknown plugin %s enabled for %s, the plugins are %s", name, key, known)
+			}
+		}
+	}
+
+	s, err := plugins.LoadSettings(data)
+	if err != nil {
+		v.addf(file, "%v", err)
+		return
+	}
+	for _, key := range sets.StringKeySet(s.ShadowPlugins).List() {
+		for _, name := range s.ShadowPlugins[key] {
+			if !knownPlugins.Has(name) {
+				v.addf(file, "unknown shadow plugin %s for %s, the plugins are %s", name, key, known)
+			} else if !enabledFor(pc, key, name) {
+				v.addf(file, "shadow plugin %s of %s is not enabled for it in plugins, so it never runs", name, key)
+			}
+		}
+	}
+}
+
+// enabledFor returns true if the plugin is enabled for the org or org/repo key: for an org if it is enabled
+// for the org or one of its repositories, and for a repository if it is enabled for the repository or its org
+func enabledFor(pc *plugins.Configuration, key, plugin string) bool {
+	org := strings.SplitN(key, "/", 2)[0]
+	for k, names := range pc.Plugins {
+		if k != key && k != org && !(key == org && strings.HasPrefix(k, org+"/")) {
+			continue
+		}
+		for _, name := range names {
+			if name == plugin {
+				return true
+			}
+		}
+	}
+	return false
+}
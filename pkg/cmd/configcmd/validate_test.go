@@ -0,0 +1,145 @@
+package configcmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestValidate(t *testing.T) {
+	knownPlugins := sets.NewString("approve", "lgtm", "trigger")
+
+	tests := []struct {
+		name       string
+		configYAML string
+		pluginYAML string
+		expected   []string
+	}{
+		{
+			name: "valid",
+			configYAML: `presubmits:
+  org/repo:
+  - name: lint
+postsubmits:
+  org/repo:
+  - name: release
+tide:
+  queries:
+  - repos:
+    - org/repo
+    excludedTitles:
+    - '^\[WIP\]'
+  - orgs:
+    - org
+    orgDefault: true
+presubmitFilters:
+  skipIfOnlyChanged:
+    lint: '\.md$'
+statusContexts:
+  jobs:
+    release: 'ci/{{ .Context }}'
+`,
+			pluginYAML: `plugins:
+  org/repo:
+  - trigger
+  - lgtm
+shadow_plugins:
+  org:
+  - lgtm
+`,
+		},
+		{
+			name: "invalid keeper queries",
+			configYAML: `tide:
+  queries:
+  - labels:
+    - approved
+    excludedTitles:
+    - '['
+  - repos:
+    - org/repo
+    orgDefault: true
+`,
+			expected: []string{
+				"config.yaml: invalid tide.queries[0]: invalid excluded title regex [",
+				"config.yaml: tide.queries[0] names no orgs or repos, so it never matches a pull request",
+				"config.yaml: tide.queries[1] is an org default but names no orgs",
+			},
+		},
+		{
+			name: "invalid templates",
+			configYAML: `foghorn:
+  reportURL:
+    template: '{{ .Build }}/{{ .Unknown }}'
+`,
+			expected: []string{
+				"config.yaml: invalid foghorn.reportURL: invalid template, the parameters are",
+			},
+		},
+		{
+			name: "unknown jobs",
+			configYAML: `presubmits:
+  org/repo:
+  - name: lint
+postsubmits:
+  org/repo:
+  - name: release
+presubmitFilters:
+  skipIfOnlyChanged:
+    release: '\.md$'
+statusContexts:
+  jobs:
+    unit: 'ci/{{ .Context }}'
+foghorn:
+  reportURL:
+    jobs:
+      lint: '{{ .BaseURL }}/{{ .Build }}'
+      nightly: '{{ .BaseURL }}/{{ .Build }}'
+`,
+			expected: []string{
+				"config.yaml: presubmitFilters.skipIfOnlyChanged names release which is not a presubmit",
+				"config.yaml: statusContexts.jobs names unit which is not a presubmit or postsubmit",
+				"config.yaml: foghorn.reportURL.jobs names nightly which is not a job",
+			},
+		},
+		{
+			name: "unknown plugins",
+			pluginYAML: `plugins:
+  org/repo:
+  - trigger
+  - lgtmm
+shadow_plugins:
+  org/repo:
+  - approve
+  - wip
+`,
+			expected: []string{
+				"plugins.yaml: unknown plugin lgtmm enabled for org/repo, the plugins are approve, lgtm, trigger",
+				"plugins.yaml: shadow plugin approve of org/repo is not enabled for it in plugins, so it never runs",
+				"plugins.yaml: unknown shadow plugin wip for org/repo, the plugins are approve, lgtm, trigger",
+			},
+		},
+		{
+			name: "invalid plugin settings",
+			pluginYAML: `path_labels:
+  org/repo:
+  - label: docs
+`,
+			expected: []string{
+				"plugins.yaml: invalid path_labels",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := Validate([]byte(tc.configYAML), []byte(tc.pluginYAML), knownPlugins)
+			if assert.Len(t, problems, len(tc.expected), "problems %v", problems) {
+				for i, expected := range tc.expected {
+					assert.True(t, strings.HasPrefix(problems[i].Error(), expected), "expected %q to start with %q", problems[i].Error(), expected)
+				}
+			}
+		})
+	}
+}
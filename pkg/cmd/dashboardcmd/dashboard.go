@@ -0,0 +1,118 @@
+// Package dashboardcmd runs the read-only web dashboard of the recent LighthouseJobs
+package dashboardcmd
+
+import (
+	"flag"
+	"net/http"
+	"strconv"
+	"time"
+
+	lhinformers "github.com/jenkins-x/lighthouse/pkg/client/informers/externalversions"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/dashboard"
+	"github.com/jenkins-x/lighthouse/pkg/interrupts"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// resyncPeriod is the resync period of the informer of the LighthouseJobs
+const resyncPeriod = 30 * time.Minute
+
+// Options are the command line options of the dashboard
+type Options struct {
+	Namespace string
+
+	port         int
+	jobSelector  string
+	listPageSize int64
+}
+
+// NewCmdDashboard creates the dashboard command
+func NewCmdDashboard() *cobra.Command {
+	o := &Options{}
+	cmd := &cobra.Command{
+		Use:   "dashboard",
+		Short: "Serves a read-only web dashboard of the recent LighthouseJobs",
+		Long: `Serves a web page listing the recent LighthouseJobs of each repository and branch with their state,
+duration and the link to their report URL. The jobs can be filtered with the repo, branch, type and state
+query parameters and are returned as JSON with format=json. They are read from the cache of an informer,
+so that the page views do not query the API server.`,
+		Example: "  lighthouse dashboard --job-selector lighthouse.jenkins-x.io/refs.org=myorg",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.runCommand()
+			helper.CheckErr(err)
+		},
+	}
+	fs := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	o.AddFlags(fs)
+	cmd.Flags().AddGoFlagSet(fs)
+	return cmd
+}
+
+// AddFlags adds the command line flags of the dashboard
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.port, "port", 8090, "The port the dashboard is served on.")
+	fs.StringVar(&o.Namespace, "namespace", "", "The namespace of the LighthouseJobs, defaults to the namespace of the Kubernetes client.")
+	fs.StringVar(&o.jobSelector, "job-selector", "", "The label selector of the LighthouseJobs shown.")
+	fs.Int64Var(&o.listPageSize, "list-page-size", 0, "The number of objects per page of the initial list of the informer. The list is not paginated if zero.")
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if _, err := labels.Parse(o.jobSelector); err != nil {
+		return errors.Wrapf(err, "invalid --job-selector %q", o.jobSelector)
+	}
+	if o.listPageSize < 0 {
+		return errors.Errorf("--list-page-size must not be negative")
+	}
+	return nil
+}
+
+// runCommand serves the dashboard with the Kubernetes clients of the dashboard component until the
+// process is interrupted
+func (o *Options) runCommand() error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	kubeClients, err := clients.GetClientsForComponent(nil, clients.Dashboard)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the Kubernetes clients")
+	}
+	if o.Namespace == "" {
+		o.Namespace = kubeClients.Namespace
+	}
+	if err := o.Run(kubeClients, interrupts.Context().Done()); err != nil {
+		return err
+	}
+	interrupts.WaitForGracefulShutdown()
+	return nil
+}
+
+// Run serves the dashboard until stopCh is closed
+func (o *Options) Run(kubeClients *clients.Clients, stopCh <-chan struct{}) error {
+	clients.ReportMissingPermissions(kubeClients.Kube, clients.Dashboard, o.Namespace)
+	factory := lhinformers.NewSharedInformerFactoryWithOptions(kubeClients.Lighthouse, resyncPeriod, lhinformers.WithNamespace(o.Namespace),
+		lhinformers.WithTweakListOptions(util.TweakListOptions(o.jobSelector, o.listPageSize)))
+	informer := factory.Lighthouse().V1alpha1().LighthouseJobs()
+	handler := dashboard.NewHandler(informer.Lister(), o.Namespace)
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.Informer().HasSynced) {
+		return errors.New("timed out waiting for the LighthouseJob cache to sync")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+	logrus.WithField("port", o.port).Info("serving the dashboard")
+	interrupts.ListenAndServe(server, 10*time.Second)
+	<-stopCh
+	return nil
+}
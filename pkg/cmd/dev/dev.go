@@ -0,0 +1,194 @@
+// Package dev contains the dev command which runs the webhook handler locally, receiving the events
+// of a test repository through a tunnel such as ngrok, to iterate quickly on plugins.
+package dev
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/factory"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/interrupts"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/jenkins-x/lighthouse/pkg/webhook"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// HookName is the name of the temporary webhooks registered by the dev command
+const HookName = "lighthouse-dev"
+
+// hookClient is the subset of the SCM repository service needed to register the webhook
+type hookClient interface {
+	CreateHook(context.Context, string, *scm.HookInput) (*scm.Hook, *scm.Response, error)
+	DeleteHook(context.Context, string, string) (*scm.Response, error)
+}
+
+// Options are the options of the dev command
+type Options struct {
+	Repo         string
+	TunnelURL    string
+	GitKind      string
+	GitServerURL string
+
+	Webhook *webhook.Options
+
+	hooks hookClient
+	// run runs the webhook handler, it is replaced in tests
+	run func() error
+}
+
+// NewCmdDev creates the dev command
+func NewCmdDev() *cobra.Command {
+	o := &Options{
+		Webhook: &webhook.Options{},
+	}
+	cmd := &cobra.Command{
+		Use:   "dev",
+		Short: "Runs the webhook handler locally, receiving the events of a test repository through a tunnel",
+		Long: `Runs the webhook handler locally against the cluster of the current kubeconfig context, registers a
+temporary webhook on the test repository pointing at the tunnel URL, e.g. the URL given by 'ngrok http 8080',
+and deletes the webhook on exit.
+
+The git token is read from $GIT_TOKEN. The webhook secret is read from $HMAC_TOKEN, a random secret is used
+if it is not set.`,
+		Example: "  lighthouse dev --repo myorg/test-repo --tunnel-url https://7cc3b3ac.ngrok.io",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVar(&o.Repo, "repo", "", "The test repository to register the webhook on, in the form org/name")
+	cmd.Flags().StringVar(&o.TunnelURL, "tunnel-url", "", "The public URL of the tunnel forwarding to the local webhook handler")
+	cmd.Flags().StringVar(&o.GitKind, "git-kind", "", "The git provider kind (e.g. github, gitlab, bitbucketserver). Defaults to $GIT_KIND or github")
+	cmd.Flags().StringVar(&o.GitServerURL, "git-url", "", "The git provider URL. Defaults to $GIT_SERVER or https://github.com")
+	o.Webhook.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// Run registers the webhook and runs the webhook handler until it fails or the process is
+// interrupted, then deletes the webhook
+func (o *Options) Run() error {
+	owner, name := scm.Split(o.Repo)
+	if owner == "" || name == "" {
+		return errors.Errorf("invalid --repo %q, expected org/name", o.Repo)
+	}
+	target, err := o.hookTarget()
+	if err != nil {
+		return err
+	}
+	// the webhook handler reads the git provider from the environment
+	for env, value := range map[string]string{"GIT_KIND": o.GitKind, "GIT_SERVER": o.GitServerURL} {
+		if value == "" {
+			continue
+		}
+		if err := os.Setenv(env, value); err != nil {
+			return errors.Wrapf(err, "failed to set $%s", env)
+		}
+	}
+	if o.hooks == nil {
+		o.hooks, err = createHookClient()
+		if err != nil {
+			return err
+		}
+	}
+	if o.run == nil {
+		o.run = o.Webhook.Run
+	}
+
+	secret := os.Getenv("HMAC_TOKEN")
+	if secret == "" {
+		secret, err = randomSecret()
+		if err != nil {
+			return err
+		}
+		// the webhook handler validates the events with $HMAC_TOKEN
+		if err := os.Setenv("HMAC_TOKEN", secret); err != nil {
+			return errors.Wrap(err, "failed to set $HMAC_TOKEN")
+		}
+	}
+
+	hook, _, err := o.hooks.CreateHook(context.Background(), o.Repo, &scm.HookInput{
+		Name:   HookName,
+		Target: target,
+		Secret: secret,
+		Events: scm.HookEvents{
+			Branch:             true,
+			Issue:              true,
+			IssueComment:       true,
+			PullRequest:        true,
+			PullRequestComment: true,
+			Push:               true,
+			ReviewComment:      true,
+			Tag:                true,
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the webhook of %s", o.Repo)
+	}
+	log := logrus.WithFields(logrus.Fields{"repo": o.Repo, "hook": hook.ID, "target": target})
+	log.Info("Registered the webhook, send events to the test repository to invoke the local plugins.")
+	defer func() {
+		if _, err := o.hooks.DeleteHook(context.Background(), o.Repo, hook.ID); err != nil {
+			log.WithError(err).Error("Failed to delete the webhook, please delete it manually.")
+			return
+		}
+		log.Info("Deleted the webhook.")
+	}()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- o.run()
+	}()
+	select {
+	case err = <-errs:
+		return errors.Wrap(err, "failed to run the webhook handler")
+	case <-interrupts.Context().Done():
+		return nil
+	}
+}
+
+// hookTarget returns the URL the webhook sends the events to
+func (o *Options) hookTarget() (string, error) {
+	u, err := url.Parse(o.TunnelURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.Errorf("invalid --tunnel-url %q, expected an http or https URL", o.TunnelURL)
+	}
+	path := o.Webhook.Path
+	if path == "" {
+		path = "/hook"
+	}
+	return strings.TrimSuffix(o.TunnelURL, "/") + path, nil
+}
+
+func createHookClient() (hookClient, error) {
+	gitKind := os.Getenv("GIT_KIND")
+	if gitKind == "" {
+		gitKind = "github"
+	}
+	serverURL := os.Getenv("GIT_SERVER")
+	if serverURL == "" {
+		serverURL = "https://github.com"
+	}
+	client, err := factory.NewClient(gitKind, serverURL, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create SCM client")
+	}
+	util.AddAuthToSCMClient(client, os.Getenv("GIT_TOKEN"), false)
+	return client.Repositories, nil
+}
+
+// randomSecret returns a random webhook secret
+func randomSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate the webhook secret")
+	}
+	return hex.EncodeToString(b), nil
+}
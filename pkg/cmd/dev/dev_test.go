@@ -0,0 +1,67 @@
+package dev
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/webhook"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHookClient struct {
+	created []*scm.HookInput
+	deleted []string
+}
+
+func (f *fakeHookClient) CreateHook(ctx context.Context, repo string, input *scm.HookInput) (*scm.Hook, *scm.Response, error) {
+	f.created = append(f.created, input)
+	return &scm.Hook{ID: "42", Name: input.Name, Target: input.Target}, nil, nil
+}
+
+func (f *fakeHookClient) DeleteHook(ctx context.Context, repo string, id string) (*scm.Response, error) {
+	f.deleted = append(f.deleted, repo+"#"+id)
+	return nil, nil
+}
+
+func TestRun(t *testing.T) {
+	defer os.Setenv("HMAC_TOKEN", os.Getenv("HMAC_TOKEN"))
+	os.Unsetenv("HMAC_TOKEN")
+
+	hooks := &fakeHookClient{}
+	o := &Options{
+		Repo:      "myorg/test-repo",
+		TunnelURL: "https://7cc3b3ac.ngrok.io/",
+		Webhook:   &webhook.Options{Path: "/hook"},
+		hooks:     hooks,
+		run: func() error {
+			return errors.New("port in use")
+		},
+	}
+	err := o.Run()
+	assert.EqualError(t, err, "failed to run the webhook handler: port in use")
+
+	require.Len(t, hooks.created, 1)
+	input := hooks.created[0]
+	assert.Equal(t, HookName, input.Name)
+	assert.Equal(t, "https://7cc3b3ac.ngrok.io/hook", input.Target)
+	assert.True(t, input.Events.PullRequest)
+	assert.True(t, input.Events.IssueComment)
+	assert.NotEmpty(t, input.Secret)
+	assert.Equal(t, input.Secret, os.Getenv("HMAC_TOKEN"), "the handler validates the events with the generated secret")
+	assert.Equal(t, []string{"myorg/test-repo#42"}, hooks.deleted)
+}
+
+func TestRunValidates(t *testing.T) {
+	hooks := &fakeHookClient{}
+	o := &Options{Repo: "test-repo", TunnelURL: "https://7cc3b3ac.ngrok.io", Webhook: &webhook.Options{}, hooks: hooks}
+	assert.EqualError(t, o.Run(), `invalid --repo "test-repo", expected org/name`)
+
+	o.Repo = "myorg/test-repo"
+	o.TunnelURL = "7cc3b3ac.ngrok.io"
+	assert.EqualError(t, o.Run(), `invalid --tunnel-url "7cc3b3ac.ngrok.io", expected an http or https URL`)
+	assert.Empty(t, hooks.created)
+}
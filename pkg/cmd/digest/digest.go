@@ -0,0 +1,101 @@
+// Package digest contains the postsubmit-digest command which publishes the digests of the results of
+// the postsubmits of the repositories over a period, e.g. daily or weekly from a CronJob.
+package digest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/foghorn"
+	"github.com/jenkins-x/lighthouse/pkg/jobdigest"
+	"github.com/jenkins-x/lighthouse/pkg/jobexport"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Options are the options of the postsubmit-digest command
+type Options struct {
+	Period        time.Duration
+	Repos         []string
+	Issues        []string
+	WebhookURL    string
+	WebhookFormat string
+	ExportURL     string
+	DryRun        bool
+}
+
+// NewCmdDigest creates the postsubmit-digest command
+func NewCmdDigest() *cobra.Command {
+	o := &Options{}
+	cmd := &cobra.Command{
+		Use:   "postsubmit-digest",
+		Short: "Publishes a digest of the results of the postsubmits of each repository over a period",
+		Long: `Aggregates the postsubmits completed over the period, up to now, into a digest per repository of their
+pass rate, slowest jobs and recent failures. The digests are read from the LighthouseJobs which are not
+garbage collected yet and from the summaries the gc-jobs CronJob exported to the --export-url before
+deleting them. Without --export-url the period should not exceed the max age of the gc-jobs CronJob.
+
+The digest of a repository is posted as a comment of the issue given with --issue, and every digest is
+posted to the --webhook-url, either as JSON or as a Slack message. The text of the digests can be
+overridden with the digest.postsubmits message.`,
+		Example: "  lighthouse postsubmit-digest --period 168h --issue myorg/myrepo=42 --webhook-url https://hooks.slack.com/services/... --webhook-format slack",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().DurationVar(&o.Period, "period", 24*time.Hour, "The period of the digest, e.g. 24h for a daily or 168h for a weekly digest")
+	cmd.Flags().StringSliceVar(&o.Repos, "repo", nil, "The repositories to publish digests for, in org/repo format. Defaults to every repository with postsubmits")
+	cmd.Flags().StringSliceVar(&o.Issues, "issue", nil, "The issue to comment the digest of a repository on, in org/repo=number format")
+	cmd.Flags().StringVar(&o.WebhookURL, "webhook-url", "", "The URL of the webhook to post the digests to")
+	cmd.Flags().StringVar(&o.WebhookFormat, "webhook-format", jobdigest.FormatJSON, "The format of the digests posted to the webhook, either json or slack")
+	cmd.Flags().StringVar(&o.ExportURL, "export-url", "", "The bucket URL the gc-jobs CronJob exports the LighthouseJob summaries to, e.g. gs://bucket/path")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "Only logs the digests")
+	return cmd
+}
+
+// Run publishes the digests of the period
+func (o *Options) Run() error {
+	opts := jobdigest.Options{
+		Period:     o.Period,
+		Repos:      o.Repos,
+		Issues:     map[string]int{},
+		WebhookURL: o.WebhookURL,
+		Format:     o.WebhookFormat,
+		DryRun:     o.DryRun,
+	}
+	for _, issue := range o.Issues {
+		parts := strings.SplitN(issue, "=", 2)
+		if len(parts) != 2 || strings.Count(parts[0], "/") != 1 {
+			return errors.Errorf("invalid --issue %q, expected org/repo=number", issue)
+		}
+		number, err := strconv.Atoi(parts[1])
+		if err != nil || number <= 0 {
+			return errors.Errorf("invalid issue number in --issue %q", issue)
+		}
+		opts.Issues[parts[0]] = number
+	}
+	if len(opts.Issues) == 0 && opts.WebhookURL == "" && !opts.DryRun {
+		return errors.New("no --issue or --webhook-url given to publish the digests to")
+	}
+
+	kubeClients, err := clients.GetClientsForComponent(nil, clients.Webhooks)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the Kubernetes clients")
+	}
+	source := jobdigest.NewClusterSource(kubeClients.Lighthouse, kubeClients.Namespace)
+	if o.ExportURL != "" {
+		store, err := jobexport.NewBucketStore(o.ExportURL)
+		if err != nil {
+			return err
+		}
+		source = jobdigest.NewSources(source, jobdigest.NewExportSource(store, o.Repos))
+	}
+	_, err = jobdigest.NewDigester(source, func(owner string) (jobdigest.Commenter, error) {
+		return foghorn.NewSCMClient(owner)
+	}).Run(opts)
+	return err
+}
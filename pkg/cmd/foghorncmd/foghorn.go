@@ -0,0 +1,106 @@
+// Package foghorncmd runs foghorn, the controller reporting the status of the pipelines of the
+// LighthouseJobs to the git providers
+package foghorncmd
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/foghorn"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/providerstatus"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/jenkins-x/lighthouse/pkg/watchdog"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Options are the command line options of foghorn
+type Options struct {
+	Namespace string
+
+	dryRun            bool
+	watchPipelineRuns bool
+	jobSelector       string
+	listPageSize      int64
+	maxReportAttempts int
+	watchdog          watchdog.Options
+	providerStatus    providerstatus.Options
+}
+
+// AddFlags adds the command line flags of foghorn
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether to mutate any real-world state.")
+	fs.StringVar(&o.Namespace, "namespace", "", "The namespace to listen in")
+	fs.BoolVar(&o.watchPipelineRuns, "watch-pipelineruns", false, "Report the status of the pipelines from the Tekton PipelineRuns rather than the PipelineActivities.")
+	fs.StringVar(&o.jobSelector, "job-selector", "", fmt.Sprintf("The label selector of the LighthouseJobs to watch, e.g. %q to only watch the jobs which are not completed yet.", util.ActiveJobsSelector))
+	fs.Int64Var(&o.listPageSize, "list-page-size", 0, "The number of objects per page of the initial lists of the informers. The lists are not paginated if zero.")
+	fs.IntVar(&o.maxReportAttempts, "max-report-attempts", foghorn.DefaultMaxReportAttempts, "The number of failed attempts to report the status of a job, retried with an exponential backoff, after which its report is marked as failed and no longer attempted.")
+	o.watchdog.AddFlags(fs)
+	o.providerStatus.AddFlags(fs)
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if _, err := labels.Parse(o.jobSelector); err != nil {
+		return errors.Wrapf(err, "invalid --job-selector %q", o.jobSelector)
+	}
+	if o.listPageSize < 0 {
+		return errors.Errorf("--list-page-size must not be negative")
+	}
+	if o.maxReportAttempts <= 0 {
+		return errors.Errorf("--max-report-attempts must be positive")
+	}
+	return nil
+}
+
+// NewInformers creates the informer factories foghorn watches the resources of its namespace with
+func (o *Options) NewInformers(kubeClients *clients.Clients) *clients.Informers {
+	return clients.NewInformers(kubeClients, o.Namespace, o.jobSelector, o.listPageSize)
+}
+
+// Run runs foghorn until stopCh is closed. The informers are created from the clients if they are nil.
+// The configuration is read from the given config agents, or from the ConfigMaps of the namespace if
+// they are nil.
+func (o *Options) Run(kubeClients *clients.Clients, informers *clients.Informers, configAgent *config.Agent, settingsAgent *settings.Agent, pluginAgent *plugins.ConfigAgent, stopCh <-chan struct{}) error {
+	clients.ReportMissingPermissions(kubeClients.Kube, clients.Foghorn, o.Namespace)
+	if informers == nil {
+		informers = o.NewInformers(kubeClients)
+	}
+
+	activityInformer := informers.JX.Jenkins().V1().PipelineActivities()
+	lhInformer := informers.Lighthouse.Lighthouse().V1alpha1().LighthouseJobs()
+	var controller *foghorn.Controller
+	var err error
+	if configAgent != nil && settingsAgent != nil && pluginAgent != nil {
+		controller = foghorn.NewControllerWithConfigAgents(kubeClients.Kube, kubeClients.JX, kubeClients.Lighthouse, activityInformer, lhInformer, o.Namespace, configAgent, settingsAgent, pluginAgent, nil)
+	} else {
+		controller, err = foghorn.NewController(kubeClients.Kube, kubeClients.JX, kubeClients.Lighthouse, activityInformer, lhInformer, o.Namespace, nil)
+		if err != nil {
+			return errors.Wrap(err, "could not create controller")
+		}
+	}
+
+	controller.SetTektonClient(kubeClients.Tekton)
+	controller.EnableWatchdog(o.watchdog)
+	controller.SetProviderStatus(providerstatus.New(o.providerStatus))
+	controller.SetMaxReportAttempts(o.maxReportAttempts)
+	controller.EnableNotifications()
+	if err := controller.EnableRetries(kubeClients); err != nil {
+		logrus.WithError(err).Warn("The errored jobs configured with retries will not be retried.")
+	}
+
+	if o.watchPipelineRuns {
+		controller.WatchPipelineRuns(informers.Tekton.Tekton().V1alpha1().PipelineRuns())
+		informers.Tekton.Start(stopCh)
+	} else {
+		informers.JX.Start(stopCh)
+	}
+	informers.Lighthouse.Start(stopCh)
+
+	return errors.Wrap(controller.Run(2, stopCh), "error running controller")
+}
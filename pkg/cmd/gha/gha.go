@@ -0,0 +1,16 @@
+// Package gha contains commands for setting up Lighthouse as a GitHub App.
+package gha
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// NewCmdGHA creates the gha command which groups the GitHub App commands
+func NewCmdGHA() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gha",
+		Short: "Commands for running Lighthouse as a GitHub App",
+	}
+	cmd.AddCommand(NewCmdSetup())
+	return cmd
+}
@@ -0,0 +1,302 @@
+package gha
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// AppIDFilename is the filename inside the GitHub App secrets dir containing the App ID
+	AppIDFilename = util.GitHubAppIDFilename
+	// PrivateKeyFilename is the filename inside the GitHub App secrets dir containing the App private key
+	PrivateKeyFilename = util.GitHubAppPrivateKeyFilename
+	// WebhookSecretFilename is the filename inside the GitHub App secrets dir containing the webhook secret
+	WebhookSecretFilename = "webhook-secret" // #nosec
+)
+
+// SetupOptions the options for the gha setup command
+type SetupOptions struct {
+	Dir        string
+	Org        string
+	AppName    string
+	WebhookURL string
+	GitHubURL  string
+	APIURL     string
+	Port       int
+	Timeout    time.Duration
+
+	Out    io.Writer
+	client *http.Client
+}
+
+// manifestConversion is the response of the GitHub App manifest conversion API
+type manifestConversion struct {
+	ID            int64  `json:"id"`
+	Slug          string `json:"slug"`
+	HTMLURL       string `json:"html_url"`
+	PEM           string `json:"pem"`
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+// installation is a GitHub App installation
+type installation struct {
+	ID      int64 `json:"id"`
+	Account struct {
+		Login string `json:"login"`
+	} `json:"account"`
+}
+
+var manifestPage = template.Must(template.New("manifest").Parse(`<html>
+<body onload="document.forms[0].submit()">
+<form action="{{.Action}}" method="post">
+<input type="hidden" name="manifest" value="{{.Manifest}}">
+<input type="submit" value="Create GitHub App">
+</form>
+</body>
+</html>
+`))
+
+// NewCmdSetup creates the gha setup command
+func NewCmdSetup() *cobra.Command {
+	o := &SetupOptions{
+		Out: os.Stdout,
+	}
+	cmd := &cobra.Command{
+		Use:   "setup",
+		Short: "Creates a GitHub App via the manifest flow and stores its secrets for Lighthouse",
+		Long: `Creates a GitHub App via the GitHub App manifest flow, stores the resulting App ID, private key and
+webhook secret in the directory used by $` + util.GitHubAppSecretDirEnvVar + ` and verifies which
+installations the App can access.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVar(&o.Dir, "dir", util.GetGitHubAppSecretDir(), "The directory to store the GitHub App secrets in. Defaults to $"+util.GitHubAppSecretDirEnvVar)
+	cmd.Flags().StringVar(&o.Org, "org", "", "The organisation to create the GitHub App in. Defaults to the current user")
+	cmd.Flags().StringVar(&o.AppName, "name", "lighthouse", "The name of the GitHub App")
+	cmd.Flags().StringVar(&o.WebhookURL, "webhook-url", "", "The URL of the Lighthouse webhook endpoint")
+	cmd.Flags().StringVar(&o.GitHubURL, "github-url", util.GithubServer, "The GitHub server URL")
+	cmd.Flags().StringVar(&o.APIURL, "api-url", "https://api.github.com", "The GitHub API URL")
+	cmd.Flags().IntVar(&o.Port, "port", 8765, "The local port to listen on for the GitHub redirect")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", 10*time.Minute, "How long to wait for the GitHub App to be created")
+	return cmd
+}
+
+// Run runs the manifest flow
+func (o *SetupOptions) Run() error {
+	if o.Dir == "" {
+		return errors.Errorf("no --dir specified and $%s is not set", util.GitHubAppSecretDirEnvVar)
+	}
+	if o.WebhookURL == "" {
+		return errors.New("no --webhook-url specified")
+	}
+	if o.client == nil {
+		o.client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	state, err := randomState()
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", "localhost:"+strconv.Itoa(o.Port))
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on port %d", o.Port)
+	}
+	localURL := "http://" + listener.Addr().String()
+	codes := make(chan string, 1)
+	server := &http.Server{Handler: o.handler(localURL, state, codes)}
+	go server.Serve(listener) // #nosec
+	defer server.Close()
+
+	fmt.Fprintf(o.Out, "Open %s in your browser to create the GitHub App\n", localURL)
+	var code string
+	select {
+	case code = <-codes:
+	case <-time.After(o.Timeout):
+		return errors.Errorf("timed out after %s waiting for the GitHub App to be created", o.Timeout.String())
+	}
+
+	app, err := o.convertManifest(code)
+	if err != nil {
+		return err
+	}
+	if err := o.writeSecrets(app); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Created GitHub App %s and stored its secrets in %s\n", app.Slug, o.Dir)
+	return o.verifyInstallations(app)
+}
+
+func (o *SetupOptions) handler(localURL, state string, codes chan<- string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		manifest, err := o.manifest(localURL + "/callback")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		action := o.GitHubURL + "/settings/apps/new"
+		if o.Org != "" {
+			action = fmt.Sprintf("%s/organizations/%s/settings/apps/new", o.GitHubURL, o.Org)
+		}
+		data := map[string]string{
+			"Action":   action + "?state=" + state,
+			"Manifest": manifest,
+		}
+		if err := manifestPage.Execute(w, data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") != state {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			http.Error(w, "missing code", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "The GitHub App has been created, you can close this window.")
+		select {
+		case codes <- code:
+		default:
+		}
+	})
+	return mux
+}
+
+func (o *SetupOptions) manifest(redirectURL string) (string, error) {
+	manifest := map[string]interface{}{
+		"name":         o.AppName,
+		"url":          "https://github.com/jenkins-x/lighthouse",
+		"redirect_url": redirectURL,
+		"public":       false,
+		"hook_attributes": map[string]interface{}{
+			"url": o.WebhookURL,
+		},
+		"default_permissions": map[string]string{
+			"checks":        "write",
+			"contents":      "write",
+			"issues":        "write",
+			"metadata":      "read",
+			"pull_requests": "write",
+			"statuses":      "write",
+		},
+		"default_events": []string{
+			"issue_comment",
+			"issues",
+			"pull_request",
+			"pull_request_review",
+			"pull_request_review_comment",
+			"push",
+			"release",
+			"status",
+		},
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal GitHub App manifest")
+	}
+	return string(data), nil
+}
+
+func (o *SetupOptions) convertManifest(code string) (*manifestConversion, error) {
+	u := fmt.Sprintf("%s/app-manifests/%s/conversions", strings.TrimSuffix(o.APIURL, "/"), code)
+	req, err := http.NewRequest(http.MethodPost, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.fury-preview+json")
+	app := &manifestConversion{}
+	if err := o.do(req, app); err != nil {
+		return nil, errors.Wrap(err, "failed to convert GitHub App manifest")
+	}
+	return app, nil
+}
+
+func (o *SetupOptions) writeSecrets(app *manifestConversion) error {
+	if err := os.MkdirAll(o.Dir, 0700); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", o.Dir)
+	}
+	files := map[string]string{
+		util.GitHubAppAPIUserFilename: app.Slug + "[bot]",
+		AppIDFilename:                 strconv.FormatInt(app.ID, 10),
+		PrivateKeyFilename:            app.PEM,
+		WebhookSecretFilename:         app.WebhookSecret,
+	}
+	for name, value := range files {
+		path := filepath.Join(o.Dir, name)
+		if err := ioutil.WriteFile(path, []byte(value), 0600); err != nil {
+			return errors.Wrapf(err, "failed to write %s", path)
+		}
+	}
+	return nil
+}
+
+func (o *SetupOptions) verifyInstallations(app *manifestConversion) error {
+	token, err := util.GitHubAppJWT(app.ID, []byte(app.PEM), time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(o.APIURL, "/")+"/app/installations", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+	var installations []installation
+	if err := o.do(req, &installations); err != nil {
+		return errors.Wrap(err, "failed to list GitHub App installations")
+	}
+	if len(installations) == 0 {
+		fmt.Fprintf(o.Out, "The GitHub App is not installed yet, install it via %s/installations/new\n", app.HTMLURL)
+		return nil
+	}
+	for _, i := range installations {
+		fmt.Fprintf(o.Out, "The GitHub App is installed for %s\n", i.Account.Login)
+	}
+	return nil
+}
+
+func (o *SetupOptions) do(req *http.Request, result interface{}) error {
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("%s %s returned status %d: %s", req.Method, req.URL.String(), resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, result)
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate state")
+	}
+	return hex.EncodeToString(b), nil
+}
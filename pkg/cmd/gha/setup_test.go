@@ -0,0 +1,82 @@
+package gha
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	now := time.Unix(1600000000, 0)
+	token, err := util.GitHubAppJWT(1234, privateKey, now)
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	claims := map[string]int64{}
+	require.NoError(t, json.Unmarshal(claimsJSON, &claims))
+	assert.Equal(t, int64(1234), claims["iss"])
+	assert.Equal(t, now.Add(-time.Minute).Unix(), claims["iat"])
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature))
+}
+
+func TestConvertManifestAndWriteSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/app-manifests/abc/conversions", r.URL.Path)
+		w.Write([]byte(`{"id": 42, "slug": "my-lighthouse", "pem": "the-key", "webhook_secret": "shh"}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "gha-setup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	o := &SetupOptions{
+		Dir:    dir,
+		APIURL: server.URL,
+		client: server.Client(),
+	}
+	app, err := o.convertManifest("abc")
+	require.NoError(t, err)
+	require.NoError(t, o.writeSecrets(app))
+
+	expected := map[string]string{
+		util.GitHubAppAPIUserFilename: "my-lighthouse[bot]",
+		AppIDFilename:                 "42",
+		PrivateKeyFilename:            "the-key",
+		WebhookSecretFilename:         "shh",
+	}
+	for name, value := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(o.Dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, value, string(data), name)
+	}
+}
@@ -0,0 +1,232 @@
+// Package gitcredentials contains a git credential helper which fetches short lived GitHub App
+// installation tokens from Lighthouse, so pipelines do not need long lived tokens mounted as secrets.
+package gitcredentials
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// DefaultTokenFile is the projected ServiceAccount token of the pod, used to authenticate with Lighthouse
+const DefaultTokenFile = "/var/run/secrets/lighthouse/git-credentials/token" // #nosec
+
+// Credential is a git credential as read and written by git credential helpers
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// ParseCredential parses the key=value lines of the git credential helper protocol
+func ParseCredential(r io.Reader) (*Credential, error) {
+	c := &Credential{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			break
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid credential line %q", line)
+		}
+		switch parts[0] {
+		case "protocol":
+			c.Protocol = parts[1]
+		case "host":
+			c.Host = parts[1]
+		case "path":
+			c.Path = parts[1]
+		case "username":
+			c.Username = parts[1]
+		case "password":
+			c.Password = parts[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read credential")
+	}
+	return c, nil
+}
+
+// String returns the credential in the git credential helper protocol
+func (c *Credential) String() string {
+	var b strings.Builder
+	for _, kv := range [][2]string{
+		{"protocol", c.Protocol},
+		{"host", c.Host},
+		{"path", c.Path},
+		{"username", c.Username},
+		{"password", c.Password},
+	} {
+		if kv[1] != "" {
+			fmt.Fprintf(&b, "%s=%s\n", kv[0], kv[1])
+		}
+	}
+	return b.String()
+}
+
+// Repository returns the repository in the path of the credential, e.g. "org/repo" for "org/repo.git",
+// or an empty string if the path is not the one of a repository
+func (c *Credential) Repository() string {
+	repo := strings.TrimSuffix(strings.Trim(c.Path, "/"), ".git")
+	if len(strings.Split(repo, "/")) != 2 {
+		return ""
+	}
+	return repo
+}
+
+// Options holds the command line arguments
+type Options struct {
+	URL       string
+	TokenFile string
+	CAFile    string
+
+	client *http.Client
+	in     io.Reader
+	out    io.Writer
+}
+
+// NewCmdGitCredentials creates the git-credentials command
+func NewCmdGitCredentials() *cobra.Command {
+	o := &Options{}
+	cmd := &cobra.Command{
+		Use:   "git-credentials [get|store|erase]",
+		Short: "Runs as a git credential helper fetching GitHub App tokens from Lighthouse",
+		Long: `Runs as a git credential helper inside pipeline pods, fetching short lived GitHub App installation
+tokens for the owner of each repository from Lighthouse over TLS. The pod authenticates with a projected
+ServiceAccount token issued for the audience of the gitCredentials section of config.yaml:
+
+    volumes:
+    - name: git-credentials
+      projected:
+        sources:
+        - serviceAccountToken:
+            audience: lighthouse-git-credentials
+            expirationSeconds: 600
+            path: token
+
+mounted at /var/run/secrets/lighthouse/git-credentials. Configure git to use it with:
+
+    git config --global credential.helper "lighthouse git-credentials --url https://lighthouse.example.com/git-credentials"
+    git config --global credential.useHttpPath true
+`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run(args[0])
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVar(&o.URL, "url", "", "The URL of the git credentials endpoint of Lighthouse")
+	cmd.Flags().StringVar(&o.TokenFile, "token-file", DefaultTokenFile, "The file containing the projected ServiceAccount token used to authenticate with Lighthouse")
+	cmd.Flags().StringVar(&o.CAFile, "ca-file", "", "The file containing the PEM certificates of the CAs of the git credentials endpoint, if not trusted by the system")
+	return cmd
+}
+
+// Run runs the credential helper operation
+func (o *Options) Run(operation string) error {
+	// the tokens are short lived and fetched on demand so there is nothing to store or erase
+	if operation != "get" {
+		return nil
+	}
+	if o.URL == "" {
+		return errors.New("the --url of the git credentials endpoint is required")
+	}
+	u, err := url.Parse(o.URL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse URL %s", o.URL)
+	}
+	// the ServiceAccount token and the git credentials must not be sent in clear text
+	if u.Scheme != "https" {
+		return errors.Errorf("the git credentials endpoint %s must use https", o.URL)
+	}
+	if o.in == nil {
+		o.in = os.Stdin
+	}
+	if o.out == nil {
+		o.out = os.Stdout
+	}
+	if o.client == nil {
+		o.client, err = o.createClient()
+		if err != nil {
+			return err
+		}
+	}
+
+	c, err := ParseCredential(o.in)
+	if err != nil {
+		return err
+	}
+	repo := c.Repository()
+	if repo == "" {
+		return errors.Errorf("no repository path for host %s, set credential.useHttpPath to true", c.Host)
+	}
+	/* #nosec */
+	token, err := ioutil.ReadFile(o.TokenFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the token file %s", o.TokenFile)
+	}
+
+	q := u.Query()
+	q.Set("repository", repo)
+	q.Set("host", c.Host)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request for %s", o.URL)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch the git credentials for %s", repo)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("failed to fetch the git credentials for %s: %s: %s", repo, resp.Status, strings.TrimSpace(string(body)))
+	}
+	fetched, err := ParseCredential(resp.Body)
+	if err != nil {
+		return err
+	}
+	answer := &Credential{Username: fetched.Username, Password: fetched.Password}
+	_, err = fmt.Fprint(o.out, answer.String())
+	return err
+}
+
+// createClient returns the HTTP client trusting the CAs of the CA file, if any
+func (o *Options) createClient() (*http.Client, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if o.CAFile == "" {
+		return client, nil
+	}
+	/* #nosec */
+	data, err := ioutil.ReadFile(o.CAFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the CA file %s", o.CAFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.Errorf("no PEM certificates in the CA file %s", o.CAFile)
+	}
+	client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}
+	return client, nil
+}
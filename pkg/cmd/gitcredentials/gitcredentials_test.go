@@ -0,0 +1,74 @@
+package gitcredentials
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCredential(t *testing.T) {
+	c, err := ParseCredential(strings.NewReader("protocol=https\nhost=github.com\npath=my-org/my-repo.git\n\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &Credential{Protocol: "https", Host: "github.com", Path: "my-org/my-repo.git"}, c)
+	assert.Equal(t, "my-org/my-repo", c.Repository())
+	assert.Equal(t, "protocol=https\nhost=github.com\npath=my-org/my-repo.git\n", c.String())
+
+	_, err = ParseCredential(strings.NewReader("invalid\n"))
+	assert.Error(t, err)
+
+	assert.Empty(t, (&Credential{Path: "my-org"}).Repository())
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sa-token" || r.URL.Query().Get("repository") != "my-org/my-repo" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "github.com", r.URL.Query().Get("host"))
+		_, _ = w.Write([]byte("username=x-access-token\npassword=installation-token\n"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "git-credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("sa-token\n"), 0600))
+
+	out := &bytes.Buffer{}
+	o := &Options{
+		URL:       server.URL + "/git-credentials",
+		TokenFile: tokenFile,
+		in:        strings.NewReader("protocol=https\nhost=github.com\npath=my-org/my-repo.git\n"),
+		out:       out,
+		client:    server.Client(),
+	}
+	require.NoError(t, o.Run("get"))
+	assert.Equal(t, "username=x-access-token\npassword=installation-token\n", out.String())
+
+	o.in = strings.NewReader("protocol=https\nhost=github.com\npath=other-org/repo.git\n")
+	err = o.Run("get")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403 Forbidden: denied")
+
+	o.in = strings.NewReader("protocol=https\nhost=github.com\n")
+	assert.Error(t, o.Run("get"), "the owner cannot be found without the path")
+
+	assert.NoError(t, o.Run("store"))
+	assert.NoError(t, o.Run("erase"))
+
+	o.URL = "http://lighthouse-webhooks/git-credentials"
+	o.in = strings.NewReader("protocol=https\nhost=github.com\npath=my-org/my-repo.git\n")
+	err = o.Run("get")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must use https")
+}
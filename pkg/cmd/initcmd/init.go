@@ -0,0 +1,329 @@
+// Package initcmd contains the init command which generates a starter Lighthouse
+// configuration for a repository.
+package initcmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/factory"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// languageMarkers maps files in the root of a repository to the language or build tool they indicate
+var languageMarkers = map[string]string{
+	"go.mod":           "go",
+	"Gopkg.toml":       "go",
+	"package.json":     "javascript",
+	"pom.xml":          "maven",
+	"build.gradle":     "gradle",
+	"build.gradle.kts": "gradle",
+	"requirements.txt": "python",
+	"setup.py":         "python",
+	"pyproject.toml":   "python",
+	"Cargo.toml":       "rust",
+	"Gemfile":          "ruby",
+	"composer.json":    "php",
+	"Dockerfile":       "docker",
+	"charts":           "helm",
+}
+
+// languageJobs maps the languages and build tools to the name of the presubmit generated for them
+var languageJobs = map[string]string{
+	"go":         "go-test",
+	"javascript": "npm-test",
+	"maven":      "maven-test",
+	"gradle":     "gradle-test",
+	"python":     "python-test",
+	"rust":       "cargo-test",
+	"ruby":       "ruby-test",
+	"php":        "php-test",
+	"docker":     "docker-build",
+	"helm":       "helm-lint",
+}
+
+// pipelineMarkers maps files in the root of a repository to the pipelines they indicate
+var pipelineMarkers = map[string]string{
+	".lighthouse":    "lighthouse",
+	"jenkins-x.yml":  "jenkins-x",
+	".tekton":        "tekton",
+	"Jenkinsfile":    "jenkins",
+	".github":        "github-actions",
+	".travis.yml":    "travis",
+	".circleci":      "circleci",
+	".gitlab-ci.yml": "gitlab-ci",
+}
+
+// defaultMissingLabels are the labels preventing PRs from being merged by keeper
+var defaultMissingLabels = []string{
+	"do-not-merge",
+	"do-not-merge/hold",
+	"do-not-merge/work-in-progress",
+	"needs-ok-to-test",
+}
+
+// repoScanner is the subset of the SCM provider client used to inspect a repository
+type repoScanner interface {
+	GetRepositoryByFullName(fullName string) (*scm.Repository, error)
+	ListFiles(owner, repo, filepath, commit string) ([]*scm.FileEntry, error)
+	ListProtectedBranches(owner, repo string) ([]string, error)
+}
+
+// RepoInfo is what has been detected about a repository
+type RepoInfo struct {
+	Org               string
+	Name              string
+	DefaultBranch     string
+	ProtectedBranches []string
+	Languages         []string
+	Pipelines         []string
+	HasOwners         bool
+}
+
+// FullName returns the org/name of the repository
+func (r *RepoInfo) FullName() string {
+	return scm.Join(r.Org, r.Name)
+}
+
+// Options the options for the init command
+type Options struct {
+	Repo         string
+	Dir          string
+	GitKind      string
+	GitServerURL string
+
+	Out     io.Writer
+	scanner repoScanner
+}
+
+// configStanza is the part of config.yaml generated for a repository
+type configStanza struct {
+	Presubmits  map[string][]config.Presubmit  `json:"presubmits"`
+	Postsubmits map[string][]config.Postsubmit `json:"postsubmits"`
+	Keeper      keeperStanza                   `json:"tide"`
+}
+
+type keeperStanza struct {
+	Queries config.KeeperQueries `json:"queries"`
+}
+
+// pluginsStanza is the part of plugins.yaml generated for a repository
+type pluginsStanza struct {
+	Plugins map[string][]string `json:"plugins"`
+}
+
+// NewCmdInit creates the init command
+func NewCmdInit() *cobra.Command {
+	o := &Options{
+		Out: os.Stdout,
+	}
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Generates a starter Lighthouse configuration for a repository",
+		Long: `Inspects a repository (languages, existing pipelines, default and protected branches) and generates
+starter presubmit, postsubmit, keeper and plugin configuration for it, to be merged into config.yaml
+and plugins.yaml. The git token is read from $GIT_TOKEN.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVar(&o.Repo, "repo", "", "The repository to generate the configuration for, in the form org/name")
+	cmd.Flags().StringVar(&o.Dir, "dir", "", "The directory to write config.yaml and plugins.yaml to. Defaults to printing them")
+	cmd.Flags().StringVar(&o.GitKind, "git-kind", "", "The git provider kind (e.g. github, gitlab, bitbucketserver). Defaults to $GIT_KIND or github")
+	cmd.Flags().StringVar(&o.GitServerURL, "git-url", "", "The git provider URL. Defaults to $GIT_SERVER or https://github.com")
+	return cmd
+}
+
+// Run generates the configuration
+func (o *Options) Run() error {
+	owner, name := scm.Split(o.Repo)
+	if owner == "" || name == "" {
+		return errors.Errorf("invalid --repo %q, expected org/name", o.Repo)
+	}
+	if o.scanner == nil {
+		scanner, err := o.createScanner()
+		if err != nil {
+			return err
+		}
+		o.scanner = scanner
+	}
+	info, err := Scan(o.scanner, owner, name)
+	if err != nil {
+		return err
+	}
+	configData, pluginsData, err := Generate(info)
+	if err != nil {
+		return err
+	}
+	if o.Dir == "" {
+		fmt.Fprintf(o.Out, "# config.yaml\n%s\n# plugins.yaml\n%s", string(configData), string(pluginsData))
+		return nil
+	}
+	for file, data := range map[string][]byte{"config.yaml": configData, "plugins.yaml": pluginsData} {
+		path := filepath.Join(o.Dir, file)
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			return errors.Wrapf(err, "failed to write %s", path)
+		}
+		fmt.Fprintf(o.Out, "Wrote %s\n", path)
+	}
+	return nil
+}
+
+func (o *Options) createScanner() (repoScanner, error) {
+	gitKind := o.GitKind
+	if gitKind == "" {
+		gitKind = os.Getenv("GIT_KIND")
+	}
+	if gitKind == "" {
+		gitKind = "github"
+	}
+	serverURL := o.GitServerURL
+	if serverURL == "" {
+		serverURL = os.Getenv("GIT_SERVER")
+	}
+	if serverURL == "" {
+		serverURL = "https://github.com"
+	}
+	client, err := factory.NewClient(gitKind, serverURL, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create SCM client")
+	}
+	util.AddAuthToSCMClient(client, os.Getenv("GIT_TOKEN"), false)
+	return scmprovider.ToClient(client, ""), nil
+}
+
+// Scan inspects the repository
+func Scan(scanner repoScanner, owner, name string) (*RepoInfo, error) {
+	info := &RepoInfo{Org: owner, Name: name}
+	repo, err := scanner.GetRepositoryByFullName(info.FullName())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find repository %s", info.FullName())
+	}
+	info.DefaultBranch = repo.Branch
+	if info.DefaultBranch == "" {
+		info.DefaultBranch = "master"
+	}
+
+	protected, err := scanner.ListProtectedBranches(owner, name)
+	if err != nil && errors.Cause(err) != scm.ErrNotSupported {
+		return nil, err
+	}
+	info.ProtectedBranches = sets.NewString(protected...).Insert(info.DefaultBranch).List()
+
+	files, err := scanner.ListFiles(owner, name, "", info.DefaultBranch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the files of %s", info.FullName())
+	}
+	languages := sets.NewString()
+	pipelines := sets.NewString()
+	for _, f := range files {
+		if l, ok := languageMarkers[f.Name]; ok {
+			languages.Insert(l)
+		}
+		if p, ok := pipelineMarkers[f.Name]; ok {
+			pipelines.Insert(p)
+		}
+		if f.Name == "OWNERS" {
+			info.HasOwners = true
+		}
+	}
+	info.Languages = languages.List()
+	info.Pipelines = pipelines.List()
+	return info, nil
+}
+
+// Generate generates the config.yaml and plugins.yaml stanzas for the repository. A presubmit is
+// generated for each detected language, or a single pr-build presubmit if none is detected.
+func Generate(info *RepoInfo) ([]byte, []byte, error) {
+	fullName := info.FullName()
+	var presubmits []config.Presubmit
+	for _, language := range info.Languages {
+		if name, ok := languageJobs[language]; ok {
+			presubmits = append(presubmits, newPresubmit(name))
+		}
+	}
+	if len(presubmits) == 0 {
+		presubmits = append(presubmits, newPresubmit("pr-build"))
+	}
+	postsubmit := config.Postsubmit{
+		JobBase: config.JobBase{
+			Name:  "release",
+			Agent: config.TektonAgent,
+		},
+		Brancher: config.Brancher{Branches: info.ProtectedBranches},
+	}
+
+	mergeLabel := "lgtm"
+	plugins := []string{"assign", "help", "hold", "lgtm", "lifecycle", "size", "trigger", "wip"}
+	if info.HasOwners {
+		// approvals and reviewer suggestions need an OWNERS file
+		mergeLabel = "approved"
+		plugins = append(plugins, "approve", "blunderbuss")
+	}
+	sort.Strings(plugins)
+
+	cfg := configStanza{
+		Presubmits:  map[string][]config.Presubmit{fullName: presubmits},
+		Postsubmits: map[string][]config.Postsubmit{fullName: {postsubmit}},
+		Keeper: keeperStanza{
+			Queries: config.KeeperQueries{
+				{
+					Repos:            []string{fullName},
+					IncludedBranches: info.ProtectedBranches,
+					Labels:           []string{mergeLabel},
+					MissingLabels:    defaultMissingLabels,
+				},
+			},
+		},
+	}
+	configData, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to marshal config")
+	}
+	pluginsData, err := yaml.Marshal(pluginsStanza{Plugins: map[string][]string{fullName: plugins}})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to marshal plugins")
+	}
+	return append([]byte(header(info)), configData...), pluginsData, nil
+}
+
+// newPresubmit returns a presubmit always run and rerun with /test and its name
+func newPresubmit(name string) config.Presubmit {
+	presubmit := config.Presubmit{
+		JobBase: config.JobBase{
+			Name:  name,
+			Agent: config.TektonAgent,
+		},
+		AlwaysRun:    true,
+		Trigger:      fmt.Sprintf(`(?m)^/test( all| %s),?(\s+|$)`, name),
+		RerunCommand: "/test " + name,
+	}
+	presubmit.Context = name
+	return presubmit
+}
+
+// header describes the existing pipelines, as the pipelines themselves are not part of the generated config
+func header(info *RepoInfo) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# generated for %s with default branch %s\n", info.FullName(), info.DefaultBranch))
+	if len(info.Pipelines) > 0 {
+		sb.WriteString(fmt.Sprintf("# existing pipelines: %s\n", strings.Join(info.Pipelines, ", ")))
+	} else {
+		sb.WriteString("# no pipelines found, the generated jobs need a pipeline\n")
+	}
+	return sb.String()
+}
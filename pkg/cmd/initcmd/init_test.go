@@ -0,0 +1,102 @@
+package initcmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+type fakeScanner struct {
+	repo         *scm.Repository
+	protected    []string
+	protectedErr error
+	files        []*scm.FileEntry
+}
+
+func (f *fakeScanner) GetRepositoryByFullName(fullName string) (*scm.Repository, error) {
+	return f.repo, nil
+}
+
+func (f *fakeScanner) ListFiles(owner, repo, filepath, commit string) ([]*scm.FileEntry, error) {
+	return f.files, nil
+}
+
+func (f *fakeScanner) ListProtectedBranches(owner, repo string) ([]string, error) {
+	return f.protected, f.protectedErr
+}
+
+func TestRun(t *testing.T) {
+	scanner := &fakeScanner{
+		repo:      &scm.Repository{Namespace: "myorg", Name: "myrepo", Branch: "main"},
+		protected: []string{"release-1.0"},
+		files: []*scm.FileEntry{
+			{Name: "go.mod"},
+			{Name: "Dockerfile"},
+			{Name: "jenkins-x.yml"},
+			{Name: "OWNERS"},
+			{Name: "README.md"},
+		},
+	}
+
+	info, err := Scan(scanner, "myorg", "myrepo")
+	require.NoError(t, err)
+	assert.Equal(t, "main", info.DefaultBranch)
+	assert.Equal(t, []string{"main", "release-1.0"}, info.ProtectedBranches)
+	assert.Equal(t, []string{"docker", "go"}, info.Languages)
+	assert.Equal(t, []string{"jenkins-x"}, info.Pipelines)
+	assert.True(t, info.HasOwners)
+
+	configData, pluginsData, err := Generate(info)
+	require.NoError(t, err)
+	assert.Contains(t, string(configData), "# existing pipelines: jenkins-x\n")
+
+	cfg := config.Config{}
+	require.NoError(t, yaml.Unmarshal(configData, &cfg))
+	presubmits := cfg.Presubmits["myorg/myrepo"]
+	require.Len(t, presubmits, 2)
+	assert.Equal(t, "docker-build", presubmits[0].Name)
+	assert.Equal(t, "go-test", presubmits[1].Name)
+	assert.True(t, presubmits[1].AlwaysRun)
+	assert.Equal(t, "/test go-test", presubmits[1].RerunCommand)
+	assert.Equal(t, "go-test", presubmits[1].Context)
+	require.Len(t, cfg.Postsubmits["myorg/myrepo"], 1)
+	assert.Equal(t, []string{"main", "release-1.0"}, cfg.Postsubmits["myorg/myrepo"][0].Branches)
+	require.Len(t, cfg.Keeper.Queries, 1)
+	assert.Equal(t, []string{"approved"}, cfg.Keeper.Queries[0].Labels)
+
+	plugins := pluginsStanza{}
+	require.NoError(t, yaml.Unmarshal(pluginsData, &plugins))
+	assert.Contains(t, plugins.Plugins["myorg/myrepo"], "approve")
+
+	out := &bytes.Buffer{}
+	o := &Options{Repo: "myorg/myrepo", Out: out, scanner: scanner}
+	require.NoError(t, o.Run())
+	assert.Contains(t, out.String(), "# plugins.yaml\n")
+
+	o.Repo = "myrepo"
+	assert.Error(t, o.Run())
+
+	scanner.protectedErr = scm.ErrNotSupported
+	info, err = Scan(scanner, "myorg", "myrepo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main"}, info.ProtectedBranches, "only the default branch is protected when the provider can't list them")
+}
+
+func TestGenerateWithoutOwners(t *testing.T) {
+	info := &RepoInfo{Org: "myorg", Name: "myrepo", DefaultBranch: "master", ProtectedBranches: []string{"master"}}
+	configData, pluginsData, err := Generate(info)
+	require.NoError(t, err)
+	assert.Contains(t, string(configData), "# no pipelines found")
+
+	cfg := config.Config{}
+	require.NoError(t, yaml.Unmarshal(configData, &cfg))
+	require.Len(t, cfg.Presubmits["myorg/myrepo"], 1)
+	assert.Equal(t, "pr-build", cfg.Presubmits["myorg/myrepo"][0].Name)
+	assert.Equal(t, []string{"lgtm"}, cfg.Keeper.Queries[0].Labels)
+	assert.NotContains(t, string(pluginsData), "approve")
+}
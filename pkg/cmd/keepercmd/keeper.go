@@ -0,0 +1,376 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package keepercmd runs keeper, the controller merging the pull requests which satisfy the
+// keeper queries once their jobs pass
+package keepercmd
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/identity"
+	"github.com/jenkins-x/lighthouse/pkg/interrupts"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/keeper"
+	"github.com/jenkins-x/lighthouse/pkg/keeper/githubapp"
+	"github.com/jenkins-x/lighthouse/pkg/metrics"
+	"github.com/jenkins-x/lighthouse/pkg/notifier"
+	"github.com/jenkins-x/lighthouse/pkg/provenance"
+	"github.com/jenkins-x/lighthouse/pkg/providerstatus"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/jenkins-x/lighthouse/pkg/watchdog"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// Options are the command line options of keeper
+type Options struct {
+	port int
+
+	configPath    string
+	jobConfigPath string
+	botName       string
+	gitServerURL  string
+	gitKind       string
+
+	syncThrottle   int
+	statusThrottle int
+
+	dryRun  bool
+	runOnce bool
+
+	maxRecordsPerPool int
+	// historyURI where Keeper should store its action history.
+	// Can be a /local/path or gs://path/to/object.
+	// GCS writes will use the bucket's default acl for new objects. Ensure both that
+	// a) the gcs credentials can write to this bucket
+	// b) the default acls do not expose any private info
+	historyURI string
+
+	// statusURI where Keeper store status update state.
+	// Can be a /local/path or gs://path/to/object.
+	// GCS writes will use the bucket's default acl for new objects. Ensure both that
+	// a) the gcs credentials can write to this bucket
+	// b) the default acls do not expose any private info
+	statusURI string
+
+	// mergeInterval is the minimum time between merges into the same repository.
+	// When set, keeper merges PRs one at a time instead of in batches.
+	mergeInterval time.Duration
+	// deployHealthURL is an optional URL which must return a 2xx response before
+	// keeper performs another merge into a repository.
+	deployHealthURL string
+
+	// needsRebaseRepos are the orgs and org/repos for which keeper labels and
+	// comments on PRs which have merge conflicts.
+	needsRebaseRepos    string
+	needsRebaseLabel    string
+	needsRebaseTemplate string
+
+	// maxPendingJobsForBatch is the number of unfinished LighthouseJobs above which
+	// keeper defers triggering batches.
+	maxPendingJobsForBatch int
+	// maxConcurrentBatches is the maximum number of batches tested at the same time.
+	maxConcurrentBatches int
+
+	// mergeAuditRepos are the orgs and org/repos for which keeper comments on merged PRs
+	// with the pool, base SHA, batch and required contexts of the merge.
+	mergeAuditRepos string
+	// mergeAuditHistoryURL is the external URL of the keeper history endpoint linked from merge audit comments.
+	mergeAuditHistoryURL string
+	// maxStatusUpdatesPerRepo is the maximum number of keeper status contexts updated per
+	// repository in a status sync, the other updates are deferred to the next syncs.
+	maxStatusUpdatesPerRepo int
+	// statusUpdateJitter is the maximum random delay between two keeper status context updates.
+	statusUpdateJitter time.Duration
+
+	// stuckPRThreshold is the time after which PRs which are mergeable or pending without
+	// being merged are escalated to the stuckPRWebhookURL.
+	stuckPRThreshold     time.Duration
+	stuckPRWebhookURL    string
+	stuckPRWebhookFormat string
+
+	// checkReviews keeps PRs whose review requirements reported by the git provider are not
+	// satisfied out of the pool, as do fewer than minApprovals approving reviews.
+	checkReviews bool
+	minApprovals int
+
+	// trialMergeRepos are the orgs and org/repos whose PRs are merged into the base SHA in a local
+	// clone before entering the pool, keeping out the PRs with conflicts not reported yet.
+	trialMergeRepos string
+
+	// labelSyncPeriod is how often the labels declared in the lighthouse settings are reconciled onto
+	// the repositories of the keeper queries.
+	labelSyncPeriod time.Duration
+
+	// duplicateJobsWindow is the period the jobs which ran more than once for the same commits
+	// are reported for.
+	duplicateJobsWindow time.Duration
+
+	// minRateLimitBudget is the fraction of the rate limit of the git provider below which the
+	// syncs triggered by webhooks are skipped.
+	minRateLimitBudget float64
+
+	// watchdog reports the syncs which are stuck and optionally exits the process
+	watchdog watchdog.Options
+	// providerStatus pauses the merges while the status page of the git provider reports it is degraded
+	providerStatus providerstatus.Options
+}
+
+// AddFlags adds the command line flags of keeper
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.port, "port", 8888, "Port to listen on.")
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
+	fs.StringVar(&o.botName, "bot-name", "", "The bot name")
+	fs.StringVar(&o.gitServerURL, "git-url", "", "The git provider URL")
+	fs.StringVar(&o.gitKind, "git-kind", "", "The git provider kind (e.g. github, gitlab, bitbucketserver")
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether to mutate any real-world state.")
+	fs.BoolVar(&o.runOnce, "run-once", false, "If true, run only once then quit.")
+	fs.IntVar(&o.syncThrottle, "sync-hourly-tokens", 800, "The maximum number of tokens per hour to be used by the sync controller.")
+	fs.IntVar(&o.statusThrottle, "status-hourly-tokens", 400, "The maximum number of tokens per hour to be used by the status controller.")
+
+	fs.IntVar(&o.maxRecordsPerPool, "max-records-per-pool", 1000, "The maximum number of history records stored for an individual Keeper pool.")
+	fs.StringVar(&o.historyURI, "history-uri", "", "The /local/path or gs://path/to/object to store keeper action history. GCS writes will use the default object ACL for the bucket")
+	fs.StringVar(&o.statusURI, "status-path", "", "The /local/path or gs://path/to/object to store status controller state. GCS writes will use the default object ACL for the bucket.")
+	fs.DurationVar(&o.mergeInterval, "merge-interval", 0, "If set, merge at most one PR per repository in this interval instead of merging batches.")
+	fs.StringVar(&o.deployHealthURL, "deploy-health-url", "", "If set, only merge the next PR into a repository once this URL returns a 2xx status. The org and repo are passed as query parameters.")
+	fs.StringVar(&o.needsRebaseRepos, "needs-rebase-repos", "", "Comma separated orgs or org/repos for which PRs with merge conflicts are labelled and commented on with rebase instructions.")
+	fs.StringVar(&o.needsRebaseLabel, "needs-rebase-label", keeper.DefaultNeedsRebaseLabel, "The label added to PRs with merge conflicts.")
+	fs.StringVar(&o.needsRebaseTemplate, "needs-rebase-template", "", "Path to a Go template used for the comment posted on PRs with merge conflicts. Defaults to a comment with the git commands to rebase.")
+
+	fs.IntVar(&o.maxPendingJobsForBatch, "max-pending-jobs-for-batch", 0, "If set, do not trigger batches while this many LighthouseJobs are pending or running.")
+	fs.IntVar(&o.maxConcurrentBatches, "max-concurrent-batches", 0, "If set, the maximum number of batches tested at the same time across all repositories.")
+
+	fs.StringVar(&o.mergeAuditRepos, "merge-audit-repos", "", "Comma separated orgs or org/repos for which merged PRs are commented on with the pool, base SHA tested, batch members and required contexts of the merge.")
+	fs.StringVar(&o.mergeAuditHistoryURL, "merge-audit-history-url", "", "The external URL of the keeper /history endpoint linked from merge audit comments.")
+	fs.IntVar(&o.maxStatusUpdatesPerRepo, "max-status-updates-per-repo", 0, "If set, the maximum number of keeper status contexts updated per repository in a status sync, the other updates are deferred to the next syncs.")
+	fs.DurationVar(&o.statusUpdateJitter, "status-update-jitter", 0, "If set, the maximum random delay between two keeper status context updates.")
+	fs.DurationVar(&o.stuckPRThreshold, "stuck-pr-threshold", 0, "If set, PRs which have been mergeable or pending for longer than this without being merged are escalated to the stuck PR webhook and to the Slack channels routed in the notifications settings.")
+	fs.StringVar(&o.stuckPRWebhookURL, "stuck-pr-webhook-url", "", "The URL of the webhook, such as a Slack incoming webhook, which stuck PRs are escalated to.")
+	fs.StringVar(&o.stuckPRWebhookFormat, "stuck-pr-webhook-format", keeper.EscalationFormatJSON, "The format of the stuck PR escalations, either json or slack.")
+	fs.BoolVar(&o.checkReviews, "check-reviews", false, "If set, PRs whose required reviews, code owner reviews or changes requested reported by the git provider prevent merging are kept out of the pool.")
+	fs.IntVar(&o.minApprovals, "min-approvals", 0, "If set, the minimum number of approving reviews PRs need to enter the pool.")
+	fs.StringVar(&o.trialMergeRepos, "trial-merge-repos", "", "Comma separated orgs or org/repos whose PRs are merged into the base SHA in a local clone before entering the pool, so that PRs with merge conflicts the git provider does not report yet are not tested.")
+	fs.DurationVar(&o.labelSyncPeriod, "label-sync-period", 0, "If set, how often the labels declared in the labels section of config.yaml are created or updated on the repositories of the keeper queries, e.g. 1h.")
+	fs.DurationVar(&o.duplicateJobsWindow, "duplicate-jobs-window", 0, "If set, the jobs which ran more than once for the same commits during this period are reported at /duplicates and counted in the metrics.")
+	fs.Float64Var(&o.minRateLimitBudget, "min-rate-limit-budget", 0, "If set, the syncs triggered by webhooks are skipped while less than this fraction of the rate limit of the git provider remains, e.g. 0.1. The periodic syncs still run.")
+	o.watchdog.AddFlags(fs)
+	o.providerStatus.AddFlags(fs)
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	return nil
+}
+
+// Run runs keeper with the given Kubernetes clients and config and settings agents, or with the clients
+// of keeper and agents loading the configuration files of the options if they are nil. It blocks until
+// keeper stops serving HTTP or is shut down.
+func (o *Options) Run(kubeClients *clients.Clients, configAgent *config.Agent, settingsAgent *settings.Agent) error {
+	if settingsAgent == nil {
+		settingsAgent = &settings.Agent{}
+		if err := settingsAgent.Start(config.Path(o.configPath)); err != nil {
+			return errors.Wrap(err, "error starting settings agent")
+		}
+	}
+	if configAgent == nil {
+		fileAgent := &config.Agent{}
+		if err := fileAgent.Start(config.Path(o.configPath), o.jobConfigPath); err != nil {
+			return errors.Wrap(err, "error starting config agent")
+		}
+		configAgent = jobutil.NewStatusContextsAgent(fileAgent.Config, settingsAgent.Config)
+	}
+
+	var err error
+	botName := o.botName
+	if botName == "" {
+		botName = os.Getenv("GIT_USER")
+	}
+	if util.GetGitHubAppSecretDir() != "" {
+		botName, err = util.GetGitHubAppAPIUser()
+		if err != nil {
+			return errors.Wrap(err, "unable to read API user for GitHub App integration")
+		}
+	}
+	if botName == "" {
+		return errors.New("no $GIT_USER defined")
+	}
+	serverURL := o.gitServerURL
+	if serverURL == "" {
+		serverURL = os.Getenv("GIT_SERVER")
+	}
+	if serverURL == "" {
+		serverURL = "https://github.com"
+	}
+	gitKind := o.gitKind
+	if gitKind == "" {
+		gitKind = os.Getenv("GIT_KIND")
+	}
+	if gitKind == "" {
+		gitKind = "github"
+	}
+	gitToken := os.Getenv("GIT_TOKEN")
+
+	rebaseAdvisor, err := keeper.NewRebaseAdvisor(splitList(o.needsRebaseRepos), o.needsRebaseLabel, o.needsRebaseTemplate)
+	if err != nil {
+		return errors.Wrap(err, "error creating rebase advisor")
+	}
+
+	slackNotifier := notifier.NewFromEnv(settingsAgent.Config)
+	stuckPRWatcher, err := keeper.NewStuckPRWatcher(o.stuckPRThreshold, o.stuckPRWebhookURL, o.stuckPRWebhookFormat, slackNotifier)
+	if err != nil {
+		return errors.Wrap(err, "error creating stuck PR watcher")
+	}
+	identityMapper, err := identity.NewMapperFromEnv()
+	if err != nil {
+		return errors.Wrap(err, "error creating identity mapper")
+	}
+	stuckPRWatcher.SetIdentityMapper(identityMapper)
+
+	if kubeClients == nil {
+		kubeClients, err = clients.GetClientsForComponent(nil, clients.Keeper)
+		if err != nil {
+			return errors.Wrap(err, "error creating kubernetes resource clients")
+		}
+	}
+	clients.ReportMissingPermissions(kubeClients.Kube, clients.Keeper, kubeClients.Namespace)
+	stuckPRWatcher.SetStore(keeper.NewConfigMapStuckPRStore(kubeClients.Kube, kubeClients.Namespace))
+
+	cfg := configAgent.Config
+	for _, i := range rebaseAdvisor.ConflictingQueries(cfg().Keeper.Queries) {
+		logrus.Warnf("keeper query %d excludes the PRs with the %s label so it is never removed from them, use another --needs-rebase-label", i, rebaseAdvisor.Label)
+	}
+	duplicateJobs := keeper.NewDuplicateJobTracker(o.duplicateJobsWindow)
+	reviewChecker := keeper.NewReviewChecker(o.checkReviews, o.minApprovals)
+	syncWatchdog := watchdog.New("keeper", o.watchdog, nil)
+	providerStatus := providerstatus.New(o.providerStatus)
+	c, err := githubapp.NewKeeperController(configAgent, botName, gitKind, gitToken, serverURL, keeper.ControllerOptions{
+		MaxRecordsPerPool: o.maxRecordsPerPool,
+		HistoryURI:        o.historyURI,
+		StatusURI:         o.statusURI,
+		MergeGate:         keeper.NewMergeGate(o.mergeInterval, o.deployHealthURL),
+		RebaseAdvisor:     rebaseAdvisor,
+		LabelSyncer:       keeper.NewLabelSyncer(o.labelSyncPeriod, settingsAgent.Config),
+		BatchThrottle:     keeper.NewBatchThrottle(o.maxPendingJobsForBatch, o.maxConcurrentBatches),
+		MergeAuditor:      keeper.NewMergeAuditor(splitList(o.mergeAuditRepos), o.mergeAuditHistoryURL),
+		StatusThrottle:    keeper.NewStatusThrottle(o.maxStatusUpdatesPerRepo, o.statusUpdateJitter),
+		Notifier:          slackNotifier,
+		Provenance:        provenance.NewAgent(settingsAgent.Config),
+		ReviewChecker:     reviewChecker,
+		TrialMerger:       keeper.NewTrialMerger(splitList(o.trialMergeRepos), botName),
+		DuplicateJobs:     duplicateJobs,
+		Watchdog:          syncWatchdog,
+		ProviderStatus:    providerStatus,
+		RateLimitThrottle: scmprovider.NewRateLimitThrottle(o.minRateLimitBudget),
+		Settings:          settingsAgent.Config,
+		Clients:           kubeClients,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error creating Keeper controller")
+	}
+	defer c.Shutdown()
+	mux := http.NewServeMux()
+	mux.Handle("/", c)
+	mux.Handle("/history", c.GetHistory())
+	mux.Handle(keeper.PoolStatePath, keeper.NewPoolStateHandler(c))
+	mux.Handle("/metrics", promhttp.Handler())
+	queriesConfig := keeper.OrgDefaultQueries(cfg, settingsAgent.Config)
+	mux.Handle(keeper.EffectiveQueryPath, keeper.NewEffectiveQueryHandler(queriesConfig))
+	mux.Handle(keeper.SimulationPath, util.AdminHandler(util.GetAdminToken(), keeper.NewSimulationHandler(queriesConfig, settingsAgent.Config, reviewChecker)))
+	mux.Handle(keeper.DuplicateJobsPath, util.AdminHandler(util.GetAdminToken(), duplicateJobs))
+	trigger := keeper.NewSyncTrigger(c)
+	mux.Handle(keeper.SyncPath, util.AdminHandler(util.GetAdminToken(), trigger))
+	server := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+
+	// check the status of the git provider before the first sync, the monitor checks it periodically afterwards
+	providerStatus.Check()
+	start := time.Now()
+	sync(c, stuckPRWatcher)
+	if o.runOnce {
+		return nil
+	}
+
+	// re-sync the subpools affected by webhook events as they arrive
+	interrupts.Run(func(ctx context.Context) {
+		trigger.Run(ctx.Done())
+	})
+	interrupts.Run(func(ctx context.Context) {
+		syncWatchdog.Run(ctx.Done())
+	})
+	interrupts.Run(func(ctx context.Context) {
+		providerStatus.Run(ctx.Done())
+	})
+
+	// serve data, and push metrics to the configured prometheus pushgateway endpoint, while waiting for the
+	// first sync period to expire
+	var serveErr chan error
+	gateway := cfg().PushGateway
+	if gateway.Endpoint != "" {
+		logrus.WithField("gateway", gateway.Endpoint).Infof("using push gateway")
+		go metrics.ExposeMetrics("keeper", gateway)
+		interrupts.ListenAndServe(server, 10*time.Second)
+	} else {
+		logrus.Warn("not pushing metrics as there is no push_gateway defined in the config.yaml")
+		serveErr = make(chan error, 1)
+		go func() {
+			serveErr <- server.ListenAndServe()
+		}()
+	}
+
+	// run the controller, but only after one sync period expires after our first run
+	time.Sleep(time.Until(start.Add(cfg().Keeper.SyncPeriod)))
+	interrupts.Tick(func() {
+		sync(c, stuckPRWatcher)
+	}, func() time.Duration {
+		return cfg().Keeper.SyncPeriod
+	})
+
+	if serveErr == nil {
+		interrupts.WaitForGracefulShutdown()
+		return nil
+	}
+	return errors.Wrap(<-serveErr, "failed to serve HTTP")
+}
+
+func sync(c keeper.Controller, stuckPRWatcher *keeper.StuckPRWatcher) {
+	if err := c.Sync(); err != nil {
+		logrus.WithError(err).Error("Error syncing.")
+	}
+	stuckPRWatcher.Check(c.GetPools())
+}
+
+// splitList splits a comma separated flag value, ignoring empty values
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,263 @@
+// Package dashboard serves a read-only web dashboard of the recent LighthouseJobs of each repository and
+// branch. The jobs are read from the cache of an informer so that page views do not query the API server.
+package dashboard
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	listers "github.com/jenkins-x/lighthouse/pkg/client/listers/lighthouse/v1alpha1"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// defaultLimit is the number of most recent jobs shown when the limit query parameter is not set
+	defaultLimit = 200
+	// maxLimit is the maximum number of jobs shown in a page
+	maxLimit = 2000
+)
+
+// Job is a LighthouseJob as shown in the dashboard
+type Job struct {
+	Name        string                 `json:"name"`
+	Job         string                 `json:"job"`
+	Type        string                 `json:"type"`
+	State       v1alpha1.PipelineState `json:"state"`
+	Description string                 `json:"description,omitempty"`
+	ReportURL   string                 `json:"reportURL,omitempty"`
+	Pull        int                    `json:"pull,omitempty"`
+	Author      string                 `json:"author,omitempty"`
+	SHA         string                 `json:"sha,omitempty"`
+	Started     time.Time              `json:"started"`
+	// Duration is how long the job took, or how long it has been running so far, rounded to the second
+	Duration string `json:"duration"`
+}
+
+// Group are the jobs of a branch of a repository, or of its pull requests, most recent first
+type Group struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Jobs   []Job  `json:"jobs"`
+}
+
+// Handler serves the dashboard. The jobs can be filtered with the repo (org/repo, or org for all the
+// repositories of an org), branch, type and state query parameters, and the number of jobs shown is
+// set with the limit query parameter. The groups are returned as JSON with format=json.
+type Handler struct {
+	lister listers.LighthouseJobNamespaceLister
+	now    func() time.Time
+}
+
+// NewHandler creates the handler of the dashboard of the jobs of the namespace
+func NewHandler(lister listers.LighthouseJobLister, ns string) *Handler {
+	return &Handler{
+		lister: lister.LighthouseJobs(ns),
+		now:    time.Now,
+	}
+}
+
+// filter are the query parameters the jobs are filtered with
+type filter struct {
+	Repo   string
+	Branch string
+	Type   string
+	State  string
+	Limit  int
+}
+
+func (f *filter) matches(job *v1alpha1.LighthouseJob, repo, branch string) bool {
+	if f.Repo != "" && !strings.EqualFold(f.Repo, repo) && !strings.HasPrefix(strings.ToLower(repo), strings.ToLower(f.Repo)+"/") {
+		return false
+	}
+	if f.Branch != "" && f.Branch != branch {
+		return false
+	}
+	if f.Type != "" && f.Type != string(job.Spec.Type) {
+		return false
+	}
+	return f.State == "" || f.State == string(job.Status.State)
+}
+
+// ServeHTTP serves the most recent jobs matching the query parameters, grouped by repository and branch
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := filter{
+		Repo:   q.Get("repo"),
+		Branch: q.Get("branch"),
+		Type:   q.Get("type"),
+		State:  q.Get("state"),
+		Limit:  defaultLimit,
+	}
+	if value := q.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			http.Error(w, "the limit query parameter must be a positive number of jobs", http.StatusBadRequest)
+			return
+		}
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+		f.Limit = limit
+	}
+	jobs, err := h.lister.List(labels.Everything())
+	if err != nil {
+		http.Error(w, "failed to list the jobs", http.StatusInternalServerError)
+		logrus.WithError(err).Error("failed to list the LighthouseJobs of the dashboard")
+		return
+	}
+	groups := h.groups(jobs, &f)
+
+	if q.Get("format") == "json" {
+		data, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			http.Error(w, "failed to marshal the jobs", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(data); err != nil {
+			logrus.WithError(err).Debug("failed to write the jobs of the dashboard")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, struct {
+		Filter filter
+		Groups []Group
+	}{f, groups}); err != nil {
+		logrus.WithError(err).Debug("failed to write the dashboard")
+	}
+}
+
+// groups returns the most recent jobs matching the filter grouped by repository and branch, the groups
+// being ordered by their most recent job
+func (h *Handler) groups(jobs []*v1alpha1.LighthouseJob, f *filter) []Group {
+	sort.Slice(jobs, func(i, j int) bool {
+		ti, tj := jobs[i].Status.StartTime.Time, jobs[j].Status.StartTime.Time
+		if ti.Equal(tj) {
+			return jobs[i].Name < jobs[j].Name
+		}
+		return ti.After(tj)
+	})
+	now := h.now()
+	var answer []Group
+	index := map[string]int{}
+	count := 0
+	for _, job := range jobs {
+		if count >= f.Limit {
+			break
+		}
+		repo, branch := "", job.Spec.GetBranch()
+		if refs := job.Spec.Refs; refs != nil {
+			repo = refs.Org + "/" + refs.Repo
+		}
+		if !f.matches(job, repo, branch) {
+			continue
+		}
+		count++
+		key := repo + "\n" + branch
+		i, ok := index[key]
+		if !ok {
+			i = len(answer)
+			index[key] = i
+			answer = append(answer, Group{Repo: repo, Branch: branch})
+		}
+		answer[i].Jobs = append(answer[i].Jobs, toJob(job, now))
+	}
+	return answer
+}
+
+func toJob(job *v1alpha1.LighthouseJob, now time.Time) Job {
+	answer := Job{
+		Name:        job.Name,
+		Job:         job.Spec.Job,
+		Type:        string(job.Spec.Type),
+		State:       job.Status.State,
+		Description: job.Status.Description,
+		ReportURL:   job.Status.ReportURL,
+		Started:     job.Status.StartTime.Time,
+	}
+	if refs := job.Spec.Refs; refs != nil {
+		answer.SHA = refs.BaseSHA
+		if len(refs.Pulls) > 0 {
+			answer.Pull = refs.Pulls[0].Number
+			answer.Author = refs.Pulls[0].Author
+			answer.SHA = refs.Pulls[0].SHA
+		}
+	}
+	var duration time.Duration
+	switch {
+	case job.Status.StartTime.IsZero():
+	case job.Status.CompletionTime != nil:
+		duration = job.Status.CompletionTime.Sub(job.Status.StartTime.Time)
+	case job.Status.State == "" || job.Status.State == v1alpha1.TriggeredState || job.Status.State == v1alpha1.QueuedState || job.Status.State == v1alpha1.PendingState || job.Status.State == v1alpha1.RunningState:
+		duration = now.Sub(job.Status.StartTime.Time)
+	}
+	answer.Duration = duration.Round(time.Second).String()
+	return answer
+}
+
+var page = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"short": func(sha string) string {
+		if len(sha) > 8 {
+			return sha[:8]
+		}
+		return sha
+	},
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02 15:04:05")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lighthouse jobs</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.2em 0.6em; border-bottom: 1px solid #ddd; }
+.success { color: #2a7d2a; } .failure, .error { color: #c62828; } .pending, .running { color: #b8860b; } .aborted { color: #777; }
+</style>
+</head>
+<body>
+<h1>Lighthouse jobs</h1>
+<form method="get">
+<input name="repo" placeholder="org/repo" value="{{.Filter.Repo}}">
+<input name="branch" placeholder="branch or PR-number" value="{{.Filter.Branch}}">
+<input name="type" placeholder="type" value="{{.Filter.Type}}">
+<input name="state" placeholder="state" value="{{.Filter.State}}">
+<input name="limit" size="5" value="{{.Filter.Limit}}">
+<input type="submit" value="Filter">
+</form>
+{{- range .Groups}}
+<h2>{{.Repo}} {{.Branch}}</h2>
+<table>
+<tr><th>Job</th><th>Type</th><th>State</th><th>Started</th><th>Duration</th><th>Commit</th><th>Author</th><th>Description</th></tr>
+{{- range .Jobs}}
+<tr>
+<td>{{if .ReportURL}}<a href="{{.ReportURL}}">{{.Job}}</a>{{else}}{{.Job}}{{end}}</td>
+<td>{{.Type}}</td>
+<td class="{{.State}}">{{.State}}</td>
+<td>{{time .Started}}</td>
+<td>{{.Duration}}</td>
+<td>{{short .SHA}}</td>
+<td>{{.Author}}</td>
+<td>{{.Description}}</td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No jobs found.</p>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,102 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	listers "github.com/jenkins-x/lighthouse/pkg/client/listers/lighthouse/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDashboard(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	newJob := func(name, repo string, pull int, state v1alpha1.PipelineState, started time.Duration) *v1alpha1.LighthouseJob {
+		job := &v1alpha1.LighthouseJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "jx"},
+			Spec: v1alpha1.LighthouseJobSpec{
+				Type: config.PostsubmitJob,
+				Job:  name,
+				Refs: &v1alpha1.Refs{Org: "org", Repo: repo, BaseRef: "master", BaseSHA: "0123456789abcdef"},
+			},
+			Status: v1alpha1.LighthouseJobStatus{
+				State:     state,
+				StartTime: metav1.NewTime(now.Add(-started)),
+				ReportURL: "https://dashboard.example.com/" + name,
+			},
+		}
+		if pull > 0 {
+			job.Spec.Type = config.PresubmitJob
+			job.Spec.Refs.Pulls = []v1alpha1.Pull{{Number: pull, Author: "author", SHA: "fedcba9876543210"}}
+		}
+		if state == v1alpha1.SuccessState || state == v1alpha1.FailureState {
+			completed := metav1.NewTime(now.Add(-started + time.Minute))
+			job.Status.CompletionTime = &completed
+		}
+		return job
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, job := range []*v1alpha1.LighthouseJob{
+		newJob("release", "app", 0, v1alpha1.SuccessState, 3*time.Hour),
+		newJob("unit", "app", 12, v1alpha1.FailureState, 2*time.Hour),
+		newJob("lint", "app", 12, v1alpha1.RunningState, 90*time.Second),
+		newJob("release-lib", "lib", 0, v1alpha1.PendingState, time.Hour),
+	} {
+		require.NoError(t, indexer.Add(job))
+	}
+	other := newJob("other", "app", 0, v1alpha1.SuccessState, time.Minute)
+	other.Namespace = "other"
+	require.NoError(t, indexer.Add(other))
+
+	h := NewHandler(listers.NewLighthouseJobLister(indexer), "jx")
+	h.now = func() time.Time { return now }
+	get := func(query string) []Group {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?format=json&"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var groups []Group
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &groups))
+		return groups
+	}
+
+	groups := get("")
+	require.Len(t, groups, 3, "the jobs of other namespaces are not shown")
+	assert.Equal(t, "org/app", groups[0].Repo)
+	assert.Equal(t, "PR-12", groups[0].Branch)
+	require.Len(t, groups[0].Jobs, 2)
+	assert.Equal(t, "lint", groups[0].Jobs[0].Name, "the most recent jobs come first")
+	assert.Equal(t, "1m30s", groups[0].Jobs[0].Duration, "the duration of running jobs is how long they have been running")
+	assert.Equal(t, "1m0s", groups[0].Jobs[1].Duration)
+	assert.Equal(t, 12, groups[0].Jobs[1].Pull)
+	assert.Equal(t, "fedcba9876543210", groups[0].Jobs[1].SHA)
+	assert.Equal(t, "org/lib", groups[1].Repo)
+	assert.Equal(t, "master", groups[2].Branch)
+	assert.Equal(t, "https://dashboard.example.com/release", groups[2].Jobs[0].ReportURL)
+
+	groups = get("repo=org/lib")
+	require.Len(t, groups, 1)
+	assert.Equal(t, "release-lib", groups[0].Jobs[0].Name)
+	assert.Len(t, get("repo=org"), 3, "the jobs of all the repositories of an org are shown")
+	assert.Len(t, get("branch=master&state=success"), 1)
+	assert.Len(t, get("type=presubmit"), 1)
+	groups = get("limit=1")
+	require.Len(t, groups, 1)
+	assert.Len(t, groups[0].Jobs, 1)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?limit=none", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?repo=org/app", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<a href="https://dashboard.example.com/unit">unit</a>`)
+	assert.Contains(t, w.Body.String(), "fedcba98")
+}
@@ -0,0 +1,94 @@
+package errorutil
+
+import (
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// UserError is an error whose message can be shown to the users, in the comments and statuses of the
+// pull requests and in the HTTP responses, while the internal error it wraps, such as a raw Kubernetes
+// error, is only logged. It also tells whether the operation which failed can be retried.
+type UserError struct {
+	message   string
+	cause     error
+	retryable bool
+}
+
+// NewUserError returns an error with the given message for the users, wrapping the internal error which
+// may be nil. The operation which failed is not retried.
+func NewUserError(message string, cause error) error {
+	return &UserError{message: message, cause: cause}
+}
+
+// NewRetryableError returns an error with the given message for the users, wrapping the internal error
+// which may be nil, for a transient failure of an operation which can be retried.
+func NewRetryableError(message string, cause error) error {
+	return &UserError{message: message, cause: cause, retryable: true}
+}
+
+// FromKubernetesError returns an error with the given message for the users wrapping the error of a
+// Kubernetes API call, which is retryable if the error is transient. Errors which already have a
+// message for the users are returned as is.
+func FromKubernetesError(message string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := findUserError(err); ok {
+		return err
+	}
+	retryable := kubeerrors.IsConflict(err) || kubeerrors.IsServerTimeout(err) || kubeerrors.IsTimeout(err) ||
+		kubeerrors.IsTooManyRequests(err) || kubeerrors.IsServiceUnavailable(err) || kubeerrors.IsInternalError(err)
+	return &UserError{message: message, cause: err, retryable: retryable}
+}
+
+// Error returns the message for the users followed by the internal error, for the logs
+func (e *UserError) Error() string {
+	if e.cause == nil {
+		return e.message
+	}
+	return e.message + ": " + e.cause.Error()
+}
+
+// Cause returns the internal error, so that errors.Cause of github.com/pkg/errors finds its root cause
+func (e *UserError) Cause() error {
+	return e.cause
+}
+
+// UserMessage returns the message for the users of the first UserError wrapped by the error, or the
+// fallback message if there is none, so that internal errors are not shown to the users.
+func UserMessage(err error, fallback string) string {
+	if e, ok := findUserError(err); ok {
+		return e.message
+	}
+	return fallback
+}
+
+// IsRetryable returns true if the error wraps a UserError of an operation which can be retried
+func IsRetryable(err error) bool {
+	e, ok := findUserError(err)
+	return ok && e.retryable
+}
+
+// IsPermanent returns true if the error wraps a UserError of an operation which cannot be retried. The
+// errors without a UserError are neither retryable nor permanent, as nothing is known about them.
+func IsPermanent(err error) bool {
+	e, ok := findUserError(err)
+	return ok && !e.retryable
+}
+
+// findUserError returns the first UserError of the chain of causes of the error
+func findUserError(err error) (*UserError, bool) {
+	type causer interface {
+		Cause() error
+	}
+	for err != nil {
+		if e, ok := err.(*UserError); ok {
+			return e, true
+		}
+		c, ok := err.(causer)
+		if !ok {
+			return nil, false
+		}
+		err = c.Cause()
+	}
+	return nil, false
+}
@@ -0,0 +1,37 @@
+package errorutil
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestUserError(t *testing.T) {
+	internal := errors.New("etcdserver: request timed out")
+	err := errors.Wrap(NewUserError("failed to create the job", internal), "launching")
+
+	assert.Equal(t, "launching: failed to create the job: etcdserver: request timed out", err.Error())
+	assert.Equal(t, "failed to create the job", UserMessage(err, "internal error"))
+	assert.Equal(t, internal, errors.Cause(err))
+	assert.True(t, IsPermanent(err))
+	assert.False(t, IsRetryable(err))
+
+	assert.Equal(t, "internal error", UserMessage(internal, "internal error"))
+	assert.False(t, IsPermanent(internal), "nothing is known about the other errors")
+	assert.False(t, IsRetryable(internal))
+
+	assert.True(t, IsRetryable(NewRetryableError("try again", nil)))
+
+	conflict := kubeerrors.NewConflict(schema.GroupResource{Resource: "lighthousejobs"}, "job", internal)
+	err = FromKubernetesError("failed to create the job", conflict)
+	assert.True(t, IsRetryable(err))
+	assert.Equal(t, "failed to create the job", UserMessage(err, ""))
+	forbidden := kubeerrors.NewForbidden(schema.GroupResource{Resource: "lighthousejobs"}, "job", internal)
+	assert.True(t, IsPermanent(FromKubernetesError("failed to create the job", forbidden)))
+	err = NewUserError("no launcher for the agent", nil)
+	assert.Equal(t, err, FromKubernetesError("failed to create the job", err), "the message for the users is kept")
+	assert.Nil(t, FromKubernetesError("failed to create the job", nil))
+}
@@ -0,0 +1,214 @@
+package foghorn
+
+import (
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	clientset "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BackfillAnnotation is the annotation of the LighthouseJobs whose final status was re-reported by
+// a backfill, containing the ID of the backfill so that an interrupted backfill can be resumed
+const BackfillAnnotation = "lighthouse.jenkins-x.io/backfill"
+
+// backfillPageSize is the number of LighthouseJobs listed per page by a backfill
+const backfillPageSize = 100
+
+// statusCreator is the subset of the SCM client reporting the statuses
+type statusCreator interface {
+	CreateStatus(string, string, string, *scm.StatusInput) (*scm.Status, error)
+}
+
+// BackfillOptions select the completed LighthouseJobs whose final status is re-reported
+type BackfillOptions struct {
+	// ID identifies the backfill. The jobs annotated with the same ID are skipped when the
+	// backfill is resumed.
+	ID string
+	// Since and Until are the outage window in which the jobs completed. Until defaults to now.
+	Since time.Time
+	Until time.Time
+	// Interval is the minimum duration between two reports, so that the backfill stays under the
+	// rate limits of the git provider
+	Interval time.Duration
+	// Force re-reports the jobs whose final status was already reported
+	Force bool
+	// DryRun only logs the statuses which would be reported
+	DryRun bool
+}
+
+// BackfillResult counts the jobs of a backfill
+type BackfillResult struct {
+	Reported int
+	Skipped  int
+	Failed   int
+}
+
+// Backfiller re-reports the final statuses of the LighthouseJobs completed during an outage of the
+// git provider, during which foghorn failed to report them, so that pull requests are not blocked
+// on missing contexts
+type Backfiller struct {
+	lhClient   clientset.Interface
+	namespace  string
+	scmClients func(owner string) (statusCreator, error)
+	logger     *logrus.Entry
+	sleep      func(time.Duration)
+}
+
+// NewBackfiller creates a Backfiller reporting the statuses with the SCM clients created like the
+// ones of the controller, from the environment
+func NewBackfiller(lhClient clientset.Interface, namespace string) *Backfiller {
+	return &Backfiller{
+		lhClient:  lhClient,
+		namespace: namespace,
+		scmClients: func(owner string) (statusCreator, error) {
+			return NewSCMClient(owner)
+		},
+		logger: logrus.WithField("controller", "backfill"),
+		sleep:  time.Sleep,
+	}
+}
+
+// Backfill reports the final status of every job completed in the window which is not reported yet.
+// Failing to report a status is logged and counted so that the other jobs are still reported, the
+// backfill can be resumed with the same ID to retry them.
+func (b *Backfiller) Backfill(opts BackfillOptions) (*BackfillResult, error) {
+	if opts.ID == "" {
+		return nil, errors.New("the backfill requires an ID")
+	}
+	if opts.Until.IsZero() {
+		opts.Until = time.Now()
+	}
+	if !opts.Since.Before(opts.Until) {
+		return nil, errors.Errorf("the start of the window %s is not before its end %s", opts.Since.Format(time.RFC3339), opts.Until.Format(time.RFC3339))
+	}
+	result := &BackfillResult{}
+	scmClients := map[string]statusCreator{}
+	reported := false
+	listOptions := metav1.ListOptions{Limit: backfillPageSize}
+	for {
+		list, err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).List(listOptions)
+		if err != nil {
+			return result, errors.Wrap(err, "unable to list LighthouseJobs")
+		}
+		for i := range list.Items {
+			job := &list.Items[i]
+			sha, status, ok := backfillStatus(job, &opts)
+			if !ok {
+				result.Skipped++
+				continue
+			}
+			l := b.logger.WithFields(logrus.Fields{
+				"LighthouseJob": job.Name,
+				"gitOwner":      job.Spec.Refs.Org,
+				"gitRepo":       job.Spec.Refs.Repo,
+				"gitStatus":     status.State.String(),
+				"context":       status.Label,
+			})
+			if opts.DryRun {
+				l.Info("would report the final status")
+				result.Reported++
+				continue
+			}
+			if reported && opts.Interval > 0 {
+				b.sleep(opts.Interval)
+			}
+			reported = true
+			if err := b.report(job, sha, status, opts.ID, scmClients); err != nil {
+				l.WithError(err).Warn("failed to backfill the final status")
+				result.Failed++
+				continue
+			}
+			l.Info("backfilled the final status")
+			result.Reported++
+		}
+		if list.Continue == "" {
+			return result, nil
+		}
+		listOptions.Continue = list.Continue
+	}
+}
+
+// report reports the status of the commit of the job and annotates the job with the ID of the backfill
+func (b *Backfiller) report(job *v1alpha1.LighthouseJob, sha string, status *scm.StatusInput, id string, scmClients map[string]statusCreator) error {
+	refs := job.Spec.Refs
+	scmClient := scmClients[refs.Org]
+	if scmClient == nil {
+		var err error
+		scmClient, err = b.scmClients(refs.Org)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the SCM client of %s", refs.Org)
+		}
+		scmClients[refs.Org] = scmClient
+	}
+	if _, err := scmClient.CreateStatus(refs.Org, refs.Repo, sha, status); err != nil {
+		return errors.Wrap(err, "failed to report git status")
+	}
+
+	jobs := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace)
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[BackfillAnnotation] = id
+	updated, err := jobs.Update(job)
+	if err != nil {
+		return errors.Wrapf(err, "failed to annotate LighthouseJob %s", job.Name)
+	}
+	updated.Status.LastReportState = status.State.String()
+	updated.Status.Description = status.Desc
+	recordReportSuccess(updated)
+	updated.Status.ReportFailed = false
+	if _, err := jobs.UpdateStatus(updated); err != nil {
+		return errors.Wrapf(err, "failed to update the status of LighthouseJob %s", job.Name)
+	}
+	return nil
+}
+
+// backfillStatus returns the commit of the job and its final status to report, or false if the job
+// is not completed in the window or its final status was already reported
+func backfillStatus(job *v1alpha1.LighthouseJob, opts *BackfillOptions) (string, *scm.StatusInput, bool) {
+	refs := job.Spec.Refs
+	if refs == nil || refs.Org == "" || refs.Repo == "" || job.Annotations[BackfillAnnotation] == opts.ID {
+		return "", nil, false
+	}
+	// batch jobs are not reported on the commits of their PRs, nor the jobs configured with skip_report
+	if job.Spec.Type == config.BatchJob || job.Spec.SkipReport {
+		return "", nil, false
+	}
+	completion := job.Status.CompletionTime
+	if completion == nil || completion.Time.Before(opts.Since) || completion.Time.After(opts.Until) {
+		return "", nil, false
+	}
+	info := toScmStatusDescriptionRunningStages(&record.ActivityRecord{Status: job.Status.State}, "")
+	switch info.scmStatus {
+	case scm.StateSuccess, scm.StateFailure, scm.StateError:
+	default:
+		return "", nil, false
+	}
+	if !opts.Force && scm.ToState(job.Status.LastReportState) == info.scmStatus {
+		return "", nil, false
+	}
+	activity := &record.ActivityRecord{}
+	fillActivityRefs(activity, job)
+	if activity.LastCommitSHA == "" {
+		return "", nil, false
+	}
+	label := job.Spec.Context
+	if label == "" {
+		label = defaultStatusContext
+	}
+	status := &scm.StatusInput{
+		State: info.scmStatus,
+		Label: label,
+		Desc:  info.description,
+	}
+	if job.Status.ReportURL != "" {
+		status.Target = job.Status.ReportURL
+	}
+	return activity.LastCommitSHA, status, true
+}
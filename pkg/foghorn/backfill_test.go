@@ -0,0 +1,96 @@
+package foghorn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	lhfake "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider/fake"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func backfillJob(name, org string, state v1alpha1.PipelineState, lastReport string, completion time.Time) *v1alpha1.LighthouseJob {
+	completed := metav1.NewTime(completion)
+	return &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "jx"},
+		Spec: v1alpha1.LighthouseJobSpec{
+			Context: name,
+			Refs: &v1alpha1.Refs{
+				Org:     org,
+				Repo:    "repo",
+				BaseSHA: "base",
+				Pulls:   []v1alpha1.Pull{{Number: 1, SHA: "head"}},
+			},
+		},
+		Status: v1alpha1.LighthouseJobStatus{
+			State:           state,
+			CompletionTime:  &completed,
+			LastReportState: lastReport,
+			ReportURL:       "https://dashboard/" + name,
+		},
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	since := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	until := since.Add(2 * time.Hour)
+	during := since.Add(time.Hour)
+	lhClient := lhfake.NewSimpleClientset(
+		backfillJob("unit", "org", v1alpha1.SuccessState, "running", during),
+		backfillJob("lint", "org", v1alpha1.FailureState, "", during),
+		backfillJob("reported", "org", v1alpha1.SuccessState, "success", during),
+		backfillJob("running", "org", v1alpha1.RunningState, "running", during),
+		backfillJob("before", "org", v1alpha1.SuccessState, "running", since.Add(-time.Minute)),
+		backfillJob("unreachable", "other", v1alpha1.SuccessState, "running", during),
+	)
+	scmClient := &fake.SCMClient{}
+	var sleeps []time.Duration
+	b := &Backfiller{
+		lhClient:  lhClient,
+		namespace: "jx",
+		scmClients: func(owner string) (statusCreator, error) {
+			if owner == "other" {
+				return nil, errors.New("no token")
+			}
+			return scmClient, nil
+		},
+		logger: logrus.WithField("test", t.Name()),
+		sleep: func(d time.Duration) {
+			sleeps = append(sleeps, d)
+		},
+	}
+
+	_, err := b.Backfill(BackfillOptions{Since: since, Until: until})
+	assert.Error(t, err, "the ID is required")
+	_, err = b.Backfill(BackfillOptions{ID: "outage", Since: until, Until: since})
+	assert.Error(t, err, "the window must not be empty")
+
+	result, err := b.Backfill(BackfillOptions{ID: "outage", Since: since, Until: until, DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, &BackfillResult{Reported: 3, Skipped: 3}, result)
+	assert.Empty(t, scmClient.CreatedStatuses)
+
+	result, err = b.Backfill(BackfillOptions{ID: "outage", Since: since, Until: until, Interval: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, &BackfillResult{Reported: 2, Skipped: 3, Failed: 1}, result)
+	assert.Len(t, sleeps, 2, "the reports are spaced by the interval")
+	assert.ElementsMatch(t, []*scm.StatusInput{
+		{State: scm.StateSuccess, Label: "unit", Desc: "Pipeline successful", Target: "https://dashboard/unit"},
+		{State: scm.StateFailure, Label: "lint", Desc: "Pipeline failed", Target: "https://dashboard/lint"},
+	}, scmClient.CreatedStatuses["head"])
+
+	unit, err := lhClient.LighthouseV1alpha1().LighthouseJobs("jx").Get("unit", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "outage", unit.Annotations[BackfillAnnotation])
+	assert.Equal(t, "success", unit.Status.LastReportState)
+
+	result, err = b.Backfill(BackfillOptions{ID: "outage", Since: since, Until: until, Force: true})
+	require.NoError(t, err)
+	assert.Equal(t, &BackfillResult{Reported: 1, Skipped: 4, Failed: 1}, result, "resuming the backfill skips the jobs it already reported")
+}
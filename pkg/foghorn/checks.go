@@ -0,0 +1,101 @@
+package foghorn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+)
+
+// checkRunClient is the subset of the SCM client needed to report check runs
+type checkRunClient interface {
+	SupportsCheckRuns() bool
+	CreateCheckRun(string, string, *scmprovider.CheckRunInput) (int64, error)
+	UpdateCheckRun(string, string, int64, *scmprovider.CheckRunInput) error
+}
+
+// checkRunsEnabled returns true if pipelines are reported as check runs to the git server, or to the
+// git provider of the environment if it is nil
+func (c *Controller) checkRunsEnabled(server *settings.GitServer) bool {
+	return c.settings.Config().Foghorn.ReportsCheckRuns(gitServerKind(server))
+}
+
+// reportCheckRun creates or updates the check run of the job, unless the provider does not support
+// check runs. The check runs are only reported in addition to the commit statuses, which keeper and
+// the branch protections rely on, so that a check run which cannot be reported does not block a PR.
+func reportCheckRun(scmClient checkRunClient, owner, repo string, activity *record.ActivityRecord, job *v1alpha1.LighthouseJob, status *scm.StatusInput) error {
+	if !scmClient.SupportsCheckRuns() {
+		return nil
+	}
+	input := toCheckRunInput(activity, job, status)
+	if job.Status.CheckRunID != 0 {
+		// the name and commit of a check run cannot change
+		input.Name = ""
+		input.HeadSHA = ""
+		return scmClient.UpdateCheckRun(owner, repo, job.Status.CheckRunID, input)
+	}
+	id, err := scmClient.CreateCheckRun(owner, repo, input)
+	if err != nil {
+		return err
+	}
+	job.Status.CheckRunID = id
+	return nil
+}
+
+// toCheckRunInput converts the activity into a check run with a summary of its stages
+func toCheckRunInput(activity *record.ActivityRecord, job *v1alpha1.LighthouseJob, status *scm.StatusInput) *scmprovider.CheckRunInput {
+	input := &scmprovider.CheckRunInput{
+		Name:       status.Label,
+		HeadSHA:    activity.LastCommitSHA,
+		DetailsURL: status.Target,
+		ExternalID: job.Name,
+		Output: &scmprovider.CheckRunOutput{
+			Title:   status.Desc,
+			Summary: checkRunSummary(activity),
+		},
+		Actions: []*scmprovider.CheckRunAction{
+			{
+				Label:       "Re-run",
+				Description: "Trigger the pipeline again",
+				Identifier:  scmprovider.CheckRunRerunAction,
+			},
+		},
+	}
+	switch activity.Status {
+	case v1alpha1.SuccessState:
+		input.Status = scmprovider.CheckRunStatusCompleted
+		input.Conclusion = "success"
+	case v1alpha1.FailureState:
+		input.Status = scmprovider.CheckRunStatusCompleted
+		input.Conclusion = "failure"
+	case v1alpha1.AbortedState:
+		input.Status = scmprovider.CheckRunStatusCompleted
+		input.Conclusion = "cancelled"
+	case v1alpha1.PendingState, v1alpha1.TriggeredState:
+		input.Status = scmprovider.CheckRunStatusQueued
+	default:
+		input.Status = scmprovider.CheckRunStatusInProgress
+	}
+	if activity.LogURL != "" {
+		input.Output.Text = fmt.Sprintf("[Pipeline logs](%s)", activity.LogURL)
+	}
+	return input
+}
+
+// checkRunSummary returns a markdown table of the stages of the activity
+func checkRunSummary(activity *record.ActivityRecord) string {
+	if len(activity.Stages) == 0 {
+		return fmt.Sprintf("Pipeline %s is %s", activity.Name, activity.Status)
+	}
+	var sb strings.Builder
+	sb.WriteString("| Stage | Status | Duration |\n")
+	sb.WriteString("| --- | --- | --- |\n")
+	for _, stage := range activity.Stages {
+		fmt.Fprintf(&sb, "| %s | %s | %s |\n", stage.Name, stage.Status, durationString(stage.StartTime, stage.CompletionTime))
+	}
+	return sb.String()
+}
@@ -0,0 +1,74 @@
+package foghorn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReportCheckRun(t *testing.T) {
+	start := metav1.NewTime(time.Now())
+	end := metav1.NewTime(start.Add(90 * time.Second))
+	activity := &record.ActivityRecord{
+		Name:          "org-repo-pr-1-1",
+		LastCommitSHA: "abc123",
+		Status:        v1alpha1.RunningState,
+		Stages: []*record.ActivityStageOrStep{
+			{Name: "build", Status: v1alpha1.SuccessState, StartTime: &start, CompletionTime: &end},
+			{Name: "test", Status: v1alpha1.RunningState, StartTime: &end},
+		},
+	}
+	job := &v1alpha1.LighthouseJob{ObjectMeta: metav1.ObjectMeta{Name: "job-1"}}
+	status := &scm.StatusInput{Label: "pr-build", Desc: "Pipeline running stage(s): test", Target: "https://dashboard/job-1"}
+
+	unsupported := &fake.SCMClient{}
+	require.NoError(t, reportCheckRun(unsupported, "org", "repo", activity, job, status))
+	assert.Equal(t, int64(0), job.Status.CheckRunID, "providers without check runs only get statuses")
+
+	scmClient := &fake.SCMClient{CheckRunsSupported: true}
+	require.NoError(t, reportCheckRun(scmClient, "org", "repo", activity, job, status))
+	require.Equal(t, int64(1), job.Status.CheckRunID)
+	created := scmClient.CheckRuns[1]
+	assert.Equal(t, "pr-build", created.Name)
+	assert.Equal(t, "abc123", created.HeadSHA)
+	assert.Equal(t, "job-1", created.ExternalID)
+	assert.Equal(t, "https://dashboard/job-1", created.DetailsURL)
+	assert.Equal(t, scmprovider.CheckRunStatusInProgress, created.Status)
+	assert.Equal(t, "Pipeline running stage(s): test", created.Output.Title)
+	assert.Equal(t, "| Stage | Status | Duration |\n| --- | --- | --- |\n| build | success | 1m30s |\n| test | running |  |\n", created.Output.Summary)
+	require.Len(t, created.Actions, 1)
+	assert.Equal(t, scmprovider.CheckRunRerunAction, created.Actions[0].Identifier)
+
+	activity.Status = v1alpha1.FailureState
+	status.Desc = "Pipeline failed"
+	require.NoError(t, reportCheckRun(scmClient, "org", "repo", activity, job, status))
+	require.Len(t, scmClient.CheckRuns, 1, "the check run should be updated rather than created again")
+	updated := scmClient.CheckRuns[1]
+	assert.Equal(t, scmprovider.CheckRunStatusCompleted, updated.Status)
+	assert.Equal(t, "failure", updated.Conclusion)
+	assert.Equal(t, "Pipeline failed", updated.Output.Title)
+}
+
+func TestCheckRunConclusions(t *testing.T) {
+	job := &v1alpha1.LighthouseJob{}
+	for state, expected := range map[v1alpha1.PipelineState][2]string{
+		v1alpha1.PendingState: {scmprovider.CheckRunStatusQueued, ""},
+		v1alpha1.RunningState: {scmprovider.CheckRunStatusInProgress, ""},
+		v1alpha1.SuccessState: {scmprovider.CheckRunStatusCompleted, "success"},
+		v1alpha1.FailureState: {scmprovider.CheckRunStatusCompleted, "failure"},
+		v1alpha1.AbortedState: {scmprovider.CheckRunStatusCompleted, "cancelled"},
+	} {
+		input := toCheckRunInput(&record.ActivityRecord{Name: "a", Status: state}, job, &scm.StatusInput{})
+		assert.Equal(t, expected[0], input.Status, "status for %s", state)
+		assert.Equal(t, expected[1], input.Conclusion, "conclusion for %s", state)
+		assert.Equal(t, "Pipeline a is "+string(state), input.Output.Summary)
+	}
+}
@@ -0,0 +1,47 @@
+package foghorn
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// completedCheckInterval is how often the completed jobs are labelled
+const completedCheckInterval = time.Minute
+
+// completedLabelPatch is the merge patch adding the completed label to a job
+var completedLabelPatch = []byte(fmt.Sprintf(`{"metadata":{"labels":{%q:"true"}}}`, util.CompletedLabel))
+
+// labelCompletedJobs adds the completed label to the jobs of the informer cache which reached a final
+// state, including the jobs completed before foghorn labelled them, so that the controllers can watch
+// only the jobs which are not completed yet with the ActiveJobsSelector. When foghorn itself watches
+// the active jobs only, the labelled jobs are then dropped from its cache.
+func (c *Controller) labelCompletedJobs() {
+	jobs, err := c.lhLister.LighthouseJobs(c.ns).List(labels.Everything())
+	if err != nil {
+		c.logger.WithError(err).Error("failed to list LighthouseJobs")
+		return
+	}
+	for _, job := range jobs {
+		if !isCompleted(job) || job.Labels[util.CompletedLabel] == "true" || c.awaitingRetry(job) {
+			continue
+		}
+		// the label is merged so that it never conflicts with the status updates of the job
+		if _, err := c.lhClient.LighthouseV1alpha1().LighthouseJobs(job.Namespace).Patch(job.Name, types.MergePatchType, completedLabelPatch); err != nil {
+			c.logger.WithField("job", job.Name).WithError(err).Error("failed to label completed job")
+		}
+	}
+}
+
+// isCompleted returns true if the job reached a final state
+func isCompleted(job *v1alpha1.LighthouseJob) bool {
+	switch job.Status.State {
+	case v1alpha1.SuccessState, v1alpha1.FailureState, v1alpha1.AbortedState:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package foghorn
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	fakelh "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	lhlisters "github.com/jenkins-x/lighthouse/pkg/client/listers/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestLabelCompletedJobs(t *testing.T) {
+	running := &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "jx"},
+		Status:     v1alpha1.LighthouseJobStatus{State: v1alpha1.RunningState},
+	}
+	succeeded := &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "succeeded", Namespace: "jx", Labels: map[string]string{"custom": "label"}},
+		Status:     v1alpha1.LighthouseJobStatus{State: v1alpha1.SuccessState},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, job := range []*v1alpha1.LighthouseJob{running, succeeded} {
+		require.NoError(t, indexer.Add(job))
+	}
+	c := &Controller{
+		lhClient: fakelh.NewSimpleClientset(running, succeeded),
+		lhLister: lhlisters.NewLighthouseJobLister(indexer),
+		logger:   logrus.WithField("controller", controllerName),
+		ns:       "jx",
+	}
+
+	c.labelCompletedJobs()
+
+	jobs := c.lhClient.LighthouseV1alpha1().LighthouseJobs("jx")
+	updated, err := jobs.Get("running", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Labels)
+	updated, err = jobs.Get("succeeded", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"custom": "label", util.CompletedLabel: "true"}, updated.Labels)
+	assert.Empty(t, succeeded.Labels[util.CompletedLabel], "the job of the lister must not be modified")
+}
@@ -1,6 +1,7 @@
 package foghorn
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -22,15 +23,24 @@ import (
 	clientset "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned"
 	lhinformers "github.com/jenkins-x/lighthouse/pkg/client/informers/externalversions/lighthouse/v1alpha1"
 	lhlisters "github.com/jenkins-x/lighthouse/pkg/client/listers/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/errorutil"
+	"github.com/jenkins-x/lighthouse/pkg/gittoken"
 	"github.com/jenkins-x/lighthouse/pkg/jx"
+	"github.com/jenkins-x/lighthouse/pkg/launcher"
+	"github.com/jenkins-x/lighthouse/pkg/notifier"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/providerstatus"
 	"github.com/jenkins-x/lighthouse/pkg/record"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider/reporter"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/jenkins-x/lighthouse/pkg/watchdog"
 	"github.com/jenkins-x/lighthouse/pkg/watcher"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	tektonclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	tektonlisters "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1alpha1"
 	"golang.org/x/time/rate"
 	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -44,7 +54,10 @@ import (
 )
 
 const (
-	controllerName           = "foghorn"
+	controllerName = "foghorn"
+	// defaultStatusContext is the context of the statuses of the pipelines without one
+	defaultStatusContext = "jenkins-x"
+
 	defaultTargetURLTemplate = "{{ .BaseURL }}/teams/{{ .Team }}/projects/{{ .Owner }}/{{ .Repository }}/{{ .Branch }}/{{ .Build }}"
 )
 
@@ -59,6 +72,11 @@ type Controller struct {
 
 	lhLister lhlisters.LighthouseJobLister
 	lhSynced cache.InformerSynced
+
+	// pipelineRunLister is set when the status of jobs is synthesized from the Tekton
+	// PipelineRuns instead of the PipelineActivities, see WatchPipelineRuns
+	pipelineRunLister tektonlisters.PipelineRunLister
+	pipelineRunSynced cache.InformerSynced
 	// queue is a rate limited work queue. This is used to queue work to be
 	// processed instead of performing it as soon as a change happens. This
 	// means we can ensure we only process a fixed amount of resources at a
@@ -69,8 +87,29 @@ type Controller struct {
 	configMapWatcher *watcher.ConfigMapWatcher
 
 	jobConfig    *config.Agent
+	settings     *settings.Agent
 	pluginConfig *plugins.ConfigAgent
 
+	// tektonClient cancels the PipelineRuns of the jobs which time out pending, if set
+	tektonClient tektonclient.Interface
+
+	// launcher launches the retries of the errored jobs, if enabled
+	launcher launcher.PipelineLauncher
+
+	// watchdog reports the workers which are stuck, if enabled
+	watchdog *watchdog.Watchdog
+
+	// providerStatus defers the reports while the git provider is degraded, if enabled
+	providerStatus *providerstatus.Monitor
+
+	// notifier notifies the job failures to Slack, if enabled
+	notifier *notifier.Notifier
+	// emailer emails the owners of the repositories whose postsubmits keep failing, if enabled
+	emailer *notifier.Emailer
+
+	// maxReportAttempts is the number of failed attempts to report a job after which it is no longer reported
+	maxReportAttempts int
+
 	wg     *sync.WaitGroup
 	logger *logrus.Entry
 	ns     string
@@ -79,68 +118,42 @@ type Controller struct {
 // NewController returns a new controller for syncing PipelineActivity updates to LighthouseJobs and commit statuses
 func NewController(kubeClient kubernetes.Interface, jxClient jxclient.Interface, lhClient clientset.Interface, activityInformer jxinformers.PipelineActivityInformer,
 	lhInformer lhinformers.LighthouseJobInformer, ns string, logger *logrus.Entry) (*Controller, error) {
-	if logger == nil {
-		logger = logrus.NewEntry(logrus.StandardLogger()).WithField("controller", controllerName)
-	}
-
 	configAgent := &config.Agent{}
+	settingsAgent := &settings.Agent{}
 	pluginAgent := &plugins.ConfigAgent{}
-
-	onConfigYamlChange := func(text string) {
-		if text != "" {
-			cfg, err := config.LoadYAMLConfig([]byte(text))
-			if err != nil {
-				logrus.WithError(err).Error("Error processing the prow Config YAML")
-			} else {
-				logrus.Info("updating the prow core configuration")
-				configAgent.Set(cfg)
-			}
-		}
-	}
-
-	onPluginsYamlChange := func(text string) {
-		if text != "" {
-			cfg, err := pluginAgent.LoadYAMLConfig([]byte(text))
-			if err != nil {
-				logrus.WithError(err).Error("Error processing the prow Plugins YAML")
-			} else {
-				logrus.Info("updating the prow plugins configuration")
-				pluginAgent.Set(cfg)
-			}
-		}
+	configMapWatcher, err := watcher.NewConfigAgentWatcher(kubeClient, ns, configAgent, settingsAgent, pluginAgent, stopper())
+	if err != nil {
+		return nil, err
 	}
+	controller := NewControllerWithConfigAgents(kubeClient, jxClient, lhClient, activityInformer, lhInformer, ns, configAgent, settingsAgent, pluginAgent, logger)
+	controller.configMapWatcher = configMapWatcher
+	return controller, nil
+}
 
-	callbacks := []watcher.ConfigMapCallback{
-		&watcher.ConfigMapEntryCallback{
-			Name:     util.ProwConfigMapName,
-			Key:      util.ProwConfigFilename,
-			Callback: onConfigYamlChange,
-		},
-		&watcher.ConfigMapEntryCallback{
-			Name:     util.ProwPluginsConfigMapName,
-			Key:      util.ProwPluginsFilename,
-			Callback: onPluginsYamlChange,
-		},
-	}
-	configMapWatcher, err := watcher.NewConfigMapWatcher(kubeClient, ns, callbacks, stopper())
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create ConfigMap watcher")
+// NewControllerWithConfigAgents returns a new controller using the given config agents, which are
+// kept up to date by the caller, rather than watching the configuration ConfigMaps itself
+func NewControllerWithConfigAgents(kubeClient kubernetes.Interface, jxClient jxclient.Interface, lhClient clientset.Interface, activityInformer jxinformers.PipelineActivityInformer,
+	lhInformer lhinformers.LighthouseJobInformer, ns string, configAgent *config.Agent, settingsAgent *settings.Agent, pluginAgent *plugins.ConfigAgent, logger *logrus.Entry) *Controller {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger()).WithField("controller", controllerName)
 	}
 
 	controller := &Controller{
-		jxClient:         jxClient,
-		lhClient:         lhClient,
-		activityLister:   activityInformer.Lister(),
-		activitySynced:   activityInformer.Informer().HasSynced,
-		lhLister:         lhInformer.Lister(),
-		lhSynced:         lhInformer.Informer().HasSynced,
-		logger:           logger,
-		ns:               ns,
-		queue:            RateLimiter(),
-		jobConfig:        configAgent,
-		pluginConfig:     pluginAgent,
-		configMapWatcher: configMapWatcher,
-		kubeClient:       kubeClient,
+		jxClient:       jxClient,
+		lhClient:       lhClient,
+		activityLister: activityInformer.Lister(),
+		activitySynced: activityInformer.Informer().HasSynced,
+		lhLister:       lhInformer.Lister(),
+		lhSynced:       lhInformer.Informer().HasSynced,
+		logger:         logger,
+		ns:             ns,
+		queue:          RateLimiter(),
+		jobConfig:      configAgent,
+		settings:       settingsAgent,
+		pluginConfig:   pluginAgent,
+		kubeClient:     kubeClient,
+
+		maxReportAttempts: DefaultMaxReportAttempts,
 	}
 
 	activityInformer.Informer()
@@ -148,6 +161,9 @@ func NewController(kubeClient kubernetes.Interface, jxClient jxclient.Interface,
 	activityInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {},
 		UpdateFunc: func(oldObj, newObj interface{}) {
+			if controller.pipelineRunLister != nil {
+				return
+			}
 			newAct := newObj.(*jxv1.PipelineActivity)
 			oldAct := oldObj.(*jxv1.PipelineActivity)
 			// Skip updates solely triggered by resyncs. We only care if they're actually different.
@@ -160,6 +176,9 @@ func NewController(kubeClient kubernetes.Interface, jxClient jxclient.Interface,
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
+			if controller.pipelineRunLister != nil {
+				return
+			}
 			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 			if err == nil {
 				controller.queue.AddRateLimited(key)
@@ -169,7 +188,27 @@ func NewController(kubeClient kubernetes.Interface, jxClient jxclient.Interface,
 
 	controller.wg = &sync.WaitGroup{}
 
-	return controller, nil
+	return controller
+}
+
+// EnableWatchdog reports the workers which are stuck processing an item or the queue, and exits if
+// configured to, as set by the watchdog options
+func (c *Controller) EnableWatchdog(opts watchdog.Options) {
+	c.watchdog = watchdog.New(controllerName, opts, c.queue.Len)
+}
+
+// SetProviderStatus defers the reports while the status page of the git provider reports it is degraded,
+// so that they are not lost or reported out of order. The monitor is run by the controller.
+func (c *Controller) SetProviderStatus(providerStatus *providerstatus.Monitor) {
+	c.providerStatus = providerStatus
+}
+
+// EnableNotifications notifies the failures of the jobs to the Slack channels routed in the settings when
+// the Slack token is set in the environment, and emails the owners of the repositories whose postsubmits
+// keep failing when an SMTP server is set in the settings
+func (c *Controller) EnableNotifications() {
+	c.notifier = notifier.NewFromEnv(c.settings.Config)
+	c.emailer = notifier.NewEmailerFromEnv(c.settings.Config)
 }
 
 // Run actually runs the controller
@@ -184,7 +223,12 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) error {
 
 	// Wait for the caches to be synced before starting workers
 	c.logger.Info("Waiting for informer caches to sync")
-	if ok := cache.WaitForCacheSync(stopCh, c.activitySynced, c.lhSynced); !ok {
+	synced := []cache.InformerSynced{c.lhSynced, c.activitySynced}
+	if c.pipelineRunSynced != nil {
+		// the PipelineActivities are not needed, and may not exist, when watching the PipelineRuns
+		synced = []cache.InformerSynced{c.lhSynced, c.pipelineRunSynced}
+	}
+	if ok := cache.WaitForCacheSync(stopCh, synced...); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
 
@@ -194,6 +238,12 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) error {
 		go wait.Until(c.runWorker, time.Second, stopCh)
 	}
 
+	go c.watchdog.Run(stopCh)
+	go c.providerStatus.Run(stopCh)
+	go wait.Until(c.checkPendingJobs, pendingCheckInterval, stopCh)
+	go wait.Until(c.labelCompletedJobs, completedCheckInterval, stopCh)
+	go wait.Until(c.retryErroredJobs, retryCheckInterval, stopCh)
+
 	c.logger.Info("Started workers")
 	<-stopCh
 	c.logger.Info("Shutting down workers")
@@ -227,6 +277,7 @@ func (c *Controller) processNextWorkItem() bool {
 		// put back on the workqueue and attempted again after a back-off
 		// period.
 		defer c.queue.Done(obj)
+		defer c.watchdog.Track()()
 		var key string
 		var ok bool
 		// We expect strings to come off the workqueue. These are of the
@@ -245,6 +296,17 @@ func (c *Controller) processNextWorkItem() bool {
 		// Run the syncHandler, passing it the namespace/name string of the
 		// PipelineActivity resource to be synced.
 		if err := c.syncHandler(key); err != nil {
+			if errorutil.IsPermanent(err) {
+				// syncing again would fail the same way until the resource changes
+				c.queue.Forget(obj)
+				return fmt.Errorf("error syncing '%s': %s", key, err.Error())
+			}
+			if after, ok := reportRetryDelay(err); ok {
+				// the report is retried with the backoff recorded in the job
+				c.queue.Forget(obj)
+				c.queue.AddAfter(key, after)
+				return fmt.Errorf("error syncing '%s': %s", key, err.Error())
+			}
 			// Put the item back on the workqueue to handle any transient errors.
 			c.queue.AddRateLimited(key)
 			return fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
@@ -267,6 +329,10 @@ func (c *Controller) processNextWorkItem() bool {
 // syncHandler compares the actual state with the desired, and attempts to
 // converge the two.
 func (c *Controller) syncHandler(key string) error {
+	if strings.HasPrefix(key, pipelineRunKeyPrefix) {
+		return c.syncPipelineRun(strings.TrimPrefix(key, pipelineRunKeyPrefix))
+	}
+
 	// Convert the namespace/name string into a distinct namespace and name
 	namespace, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
@@ -290,11 +356,25 @@ func (c *Controller) syncHandler(key string) error {
 
 	activityRecord, err := jx.ConvertPipelineActivity(jxActivity)
 	if err != nil {
-		return err
+		return errorutil.NewUserError(fmt.Sprintf("invalid PipelineActivity %s", key), err)
 	}
 
+	return c.syncActivityRecord(namespace, activityRecord, func(j *v1alpha1.LighthouseJob) bool {
+		return j.Status.ActivityName == jxActivity.Name
+	})
+}
+
+// syncActivityRecord updates the status of the LighthouseJob of the activity and reports it to the
+// git provider. The LighthouseJobs with the labels of the activity are narrowed down using matches.
+func (c *Controller) syncActivityRecord(namespace string, activityRecord *record.ActivityRecord, matches func(*v1alpha1.LighthouseJob) bool) error {
 	var job *v1alpha1.LighthouseJob
 
+	// Defer the whole sync while the git provider is degraded, the item is requeued with a backoff so that
+	// the reports of the activity are made in order once the provider recovers.
+	if degraded, reason := c.providerStatus.Degraded(); degraded {
+		return errors.Errorf("deferring the report of %s as the git provider is degraded: %s", activityRecord.Name, reason)
+	}
+
 	// Get all LighthouseJobs with the same owner/repo/branch/build/context
 	labelSelector, err := createLabelSelectorFromActivity(activityRecord)
 	possibleJobs, err := c.lhLister.LighthouseJobs(namespace).List(labelSelector)
@@ -309,7 +389,7 @@ func (c *Controller) syncHandler(key string) error {
 
 	// To be safe, find the job with the activity's name in its status.
 	for _, j := range possibleJobs {
-		if j.Status.ActivityName == jxActivity.Name {
+		if matches(j) {
 			job = j
 		}
 	}
@@ -317,11 +397,12 @@ func (c *Controller) syncHandler(key string) error {
 	if job == nil {
 		return nil
 	}
+	fillActivityRefs(activityRecord, job)
 
 	// Update the job's status for the activity.
 	jobCopy := job.DeepCopy()
 	c.updateJobStatusForActivity(activityRecord, jobCopy)
-	c.reportStatus(namespace, activityRecord, jobCopy)
+	reportErr := c.reportStatus(namespace, activityRecord, jobCopy)
 
 	currentJob, err := c.lhLister.LighthouseJobs(namespace).Get(jobCopy.Name)
 	if err != nil {
@@ -338,7 +419,7 @@ func (c *Controller) syncHandler(key string) error {
 			return err
 		}
 	}
-	return nil
+	return reportErr
 }
 
 func (c *Controller) updateJobStatusForActivity(activity *record.ActivityRecord, job *v1alpha1.LighthouseJob) {
@@ -351,6 +432,7 @@ func (c *Controller) updateJobStatusForActivity(activity *record.ActivityRecord,
 	if activity.CompletionTime != nil && activity.CompletionTime != job.Status.CompletionTime {
 		job.Status.CompletionTime = activity.CompletionTime
 	}
+	job.Status.Stages = activity.StageRecords()
 }
 
 // RateLimiter creates a ratelimiting queue for the foghorn controller.
@@ -384,14 +466,17 @@ func createLabelSelectorFromActivity(activity *record.ActivityRecord) (labels.Se
 	return labels.Parse(strings.Join(selectors, ","))
 }
 
-func (c *Controller) reportStatus(ns string, activity *record.ActivityRecord, job *v1alpha1.LighthouseJob) {
+// reportStatus reports the status of the activity to the git provider. A failed report is recorded in the
+// status of the job and returns a reportRetryError until the job runs out of attempts.
+func (c *Controller) reportStatus(ns string, activity *record.ActivityRecord, job *v1alpha1.LighthouseJob) error {
 	sha := activity.LastCommitSHA
 
 	owner := activity.Owner
 	repo := activity.Repo
 	gitURL := activity.GitURL
 	activityStatus := activity.Status
-	statusInfo := toScmStatusDescriptionRunningStages(activity, c.gitKind())
+	gitServer := c.gitServerOf(job)
+	statusInfo := toScmStatusDescriptionRunningStages(activity, gitServerKind(gitServer))
 
 	fields := map[string]interface{}{
 		"name":        activity.Name,
@@ -405,32 +490,41 @@ func (c *Controller) reportStatus(ns string, activity *record.ActivityRecord, jo
 		"buildNumber": activity.BuildIdentifier,
 		"duration":    durationString(activity.StartTime, activity.CompletionTime),
 	}
+	if job.Spec.Type == config.BatchJob {
+		// batch jobs test several PRs at once: keeper reads their results from the jobs, reporting them on
+		// the commits of the first PR would hide its own results as tide does not
+		c.logger.WithFields(fields).Debugf("Not reporting batch pipeline %s", activity.Name)
+		return nil
+	}
+	if job.Spec.SkipReport {
+		c.logger.WithFields(fields).Debugf("Not reporting pipeline %s of job %s configured with skip_report", activity.Name, job.Spec.Job)
+		return nil
+	}
 	if gitURL == "" {
 		c.logger.WithFields(fields).Debugf("Cannot report pipeline %s as we have no git SHA", activity.Name)
-		return
-
+		return nil
 	}
 	if sha == "" {
 		c.logger.WithFields(fields).Debugf("Cannot report pipeline %s as we have no git SHA", activity.Name)
-		return
+		return nil
 	}
 	if owner == "" {
 		c.logger.WithFields(fields).Debugf("Cannot report pipeline %s as we have no git Owner", activity.Name)
-		return
+		return nil
 	}
 	if repo == "" {
 		c.logger.WithFields(fields).Debugf("Cannot report pipeline %s as we have no git repository name", activity.Name)
-		return
+		return nil
 	}
 
 	if statusInfo.scmStatus == scm.StateUnknown {
-		return
+		return nil
 	}
 
 	switch scm.ToState(job.Status.LastReportState) {
 	// already completed - avoid reporting again if a promotion happens after a PR has merged and the pipeline updates status
 	case scm.StateFailure, scm.StateError, scm.StateSuccess, scm.StateCanceled:
-		return
+		return nil
 	}
 
 	c.logger.WithFields(fields).Warnf("last report: %s, current: %s, last desc: %s, current: %s", job.Status.LastReportState, statusInfo.scmStatus.String(),
@@ -439,7 +533,16 @@ func (c *Controller) reportStatus(ns string, activity *record.ActivityRecord, jo
 	// Check if state and running stages haven't changed and return if they haven't
 	if scm.ToState(job.Status.LastReportState) == statusInfo.scmStatus &&
 		job.Status.Description == statusInfo.description {
-		return
+		return nil
+	}
+
+	if job.Status.ReportFailed {
+		c.logger.WithFields(fields).Debugf("Not reporting pipeline %s as its report failed too many times: %s", activity.Name, job.Status.LastReportError)
+		return nil
+	}
+	now := time.Now()
+	if err := deferredReport(job, now); err != nil {
+		return err
 	}
 
 	// Trigger external plugins if appropriate
@@ -447,51 +550,33 @@ func (c *Controller) reportStatus(ns string, activity *record.ActivityRecord, jo
 		go util.CallExternalPluginsWithActivityRecord(c.logger, external, activity, c.hmacToken(), c.wg)
 	}
 
-	pipelineContext := activity.Context
-	if pipelineContext == "" {
-		pipelineContext = "jenkins-x"
-	}
+	pipelineContext := statusContext(activity, job)
 
 	gitRepoStatus := &scm.StatusInput{
 		State: statusInfo.scmStatus,
 		Label: pipelineContext,
 		Desc:  statusInfo.description,
 	}
-	urlBase := c.getReportURLBase()
-	if urlBase != "" {
-		urlTeam := c.getReportURLTeam()
-		team := ns
-		// override with env var if set
-		if urlTeam != "" {
-			team = urlTeam
-		}
-
-		targetURL := c.createReportTargetURL(defaultTargetURLTemplate, ReportParams{
-			Owner:      owner,
-			Repository: repo,
-			Branch:     activity.Branch,
-			Build:      activity.BuildIdentifier,
-			Context:    pipelineContext,
-			// TODO: Need to get the job URL base in here somehow. (apb)
-			BaseURL: strings.TrimRight(urlBase, "/"),
-			Team:    team,
-		})
-
-		if strings.HasPrefix(targetURL, "http://") || strings.HasPrefix(targetURL, "https://") {
-			gitRepoStatus.Target = targetURL
-		}
+	targetURL := c.reportTargetURL(ns, owner, repo, job.Spec.Job, activity, pipelineContext)
+	if strings.HasPrefix(targetURL, "http://") || strings.HasPrefix(targetURL, "https://") {
+		gitRepoStatus.Target = targetURL
 	}
-	scmClient, _, _, err := c.createSCMClient(owner)
+	scmClient, _, _, err := newSCMClient(owner, gitServer, c.settings.Config)
 	if err != nil {
 		c.logger.WithFields(fields).WithError(err).Warnf("failed to create SCM client")
-		return
+		return c.recordReportFailure(job, errors.Wrap(err, "failed to create SCM client"), now)
 	}
 
 	_, err = scmClient.CreateStatus(owner, repo, sha, gitRepoStatus)
 	if err != nil {
 		c.logger.WithFields(fields).WithError(err).Warnf("failed to report git status with target URL '%s'", gitRepoStatus.Target)
-		// TODO: Need something here to prevent infinite attempts to create status from just bombing us. (apb)
-		return
+		return c.recordReportFailure(job, errors.Wrap(err, "failed to report git status"), now)
+	}
+	recordReportSuccess(job)
+	if c.checkRunsEnabled(gitServer) {
+		if err := reportCheckRun(scmClient, owner, repo, activity, job, gitRepoStatus); err != nil {
+			c.logger.WithFields(fields).WithError(err).Warn("failed to report check run, the pipeline is only reported by its git status")
+		}
 	}
 
 	err = reporter.Report(scmClient, c.jobConfig.Config().Plank.ReportTemplate, job, []config.PipelineKind{config.PresubmitJob})
@@ -499,27 +584,110 @@ func (c *Controller) reportStatus(ns string, activity *record.ActivityRecord, jo
 		// For now, we're just going to ignore failures here.
 		c.logger.WithFields(fields).WithError(err).Warnf("failed to update comments on the PR")
 	}
+	if statusInfo.scmStatus == scm.StateFailure && c.reportFailureLogsEnabled() {
+		err = c.reportFailureLogs(scmClient, ns, activity, job, gitRepoStatus.Target)
+		if err != nil {
+			c.logger.WithFields(fields).WithError(err).Warnf("failed to comment with the failure logs on the PR")
+		}
+	}
+	if statusInfo.scmStatus == scm.StateFailure {
+		failure := jobFailure(activity, job, gitRepoStatus)
+		c.notifier.JobFailed(failure)
+		c.notifyPostsubmitFailures(ns, job, failure)
+	}
 	c.logger.WithFields(fields).Info("reported git status")
 	if gitRepoStatus.Target != "" {
 		job.Status.ReportURL = gitRepoStatus.Target
 	}
 	job.Status.Description = statusInfo.description
 	job.Status.LastReportState = statusInfo.scmStatus.String()
+	return nil
 }
 
-// getReportURLBase gets the base report URL from the environment
+// statusContext returns the context of the status of the pipeline, which is the context of the job, named
+// after the status contexts of the settings when the configuration was loaded. The context of the activity
+// and then defaultStatusContext are only used for the jobs which have none.
+func statusContext(activity *record.ActivityRecord, job *v1alpha1.LighthouseJob) string {
+	if job != nil && job.Spec.Context != "" {
+		return job.Spec.Context
+	}
+	if activity.Context != "" {
+		return activity.Context
+	}
+	return defaultStatusContext
+}
+
+// jobFailure returns the notification of the failure of the job
+func jobFailure(activity *record.ActivityRecord, job *v1alpha1.LighthouseJob, status *scm.StatusInput) notifier.JobFailure {
+	failure := notifier.JobFailure{
+		Org:         activity.Owner,
+		Repo:        activity.Repo,
+		Branch:      activity.Branch,
+		Job:         job.Spec.Job,
+		SHA:         activity.LastCommitSHA,
+		Description: status.Desc,
+		URL:         status.Target,
+	}
+	if failure.Job == "" {
+		failure.Job = status.Label
+	}
+	if job.Spec.Type == config.PresubmitJob && job.Spec.Refs != nil && len(job.Spec.Refs.Pulls) > 0 {
+		pull := job.Spec.Refs.Pulls[0]
+		failure.Number = pull.Number
+		failure.Title = pull.Title
+		failure.Author = pull.Author
+	}
+	return failure
+}
+
+// reportTargetURL returns the target URL of the status of the pipeline, from the template configured in the
+// settings for the job, or from the default template if only a base URL is set. It returns an empty string if
+// neither is configured.
+func (c *Controller) reportTargetURL(ns, owner, repo, jobName string, activity *record.ActivityRecord, pipelineContext string) string {
+	reportURL := c.settings.Config().Foghorn.ReportURL
+	urlBase := reportURL.BaseURL
+	if urlBase == "" {
+		urlBase = c.getReportURLBase()
+	}
+	templateText := reportURL.TemplateFor(owner, repo, jobName)
+	if templateText == "" {
+		if urlBase == "" {
+			return ""
+		}
+		templateText = defaultTargetURLTemplate
+	}
+	team := reportURL.Team
+	if team == "" {
+		team = c.getReportURLTeam()
+	}
+	if team == "" {
+		team = ns
+	}
+	return c.createReportTargetURL(templateText, ReportParams{
+		Owner:      owner,
+		Repository: repo,
+		Branch:     activity.Branch,
+		Build:      activity.BuildIdentifier,
+		Context:    pipelineContext,
+		BaseURL:    strings.TrimRight(urlBase, "/"),
+		Team:       team,
+		Job:        jobName,
+	})
+}
+
+// getReportURLBase gets the base report URL from the environment, if it is not set in the settings
 func (c *Controller) getReportURLBase() string {
 	return os.Getenv("LIGHTHOUSE_REPORT_URL_BASE")
 }
 
-// getReportURLTeam gets the team to construct the report url
+// getReportURLTeam gets the team to construct the report url, if it is not set in the settings
 func (c *Controller) getReportURLTeam() string {
 	return os.Getenv("LIGHTHOUSE_REPORT_URL_TEAM")
 }
 
-// ReportParams contains the parameters for target URL templates
+// ReportParams contains the parameters for target URL templates, which are the settings.ReportURLParams
 type ReportParams struct {
-	BaseURL, Owner, Repository, Branch, Build, Context, Team string
+	BaseURL, Owner, Repository, Branch, Build, Context, Team, Job string
 }
 
 // createReportTargetURL creates the target URL for pipeline results/logs from a template
@@ -607,33 +775,60 @@ func durationString(start *metav1.Time, end *metav1.Time) string {
 	return end.Sub(start.Time).Round(time.Second).String()
 }
 
-func (c *Controller) createSCMClient(owner string) (scmprovider.SCMClient, string, string, error) {
-	kind := c.gitKind()
+// NewSCMClient creates the SCM client of the owner from the environment, like the clients of the
+// controller, using the token of the GitHub App installation of the owner if GitHub App mode is enabled
+func NewSCMClient(owner string) (scmprovider.SCMClient, error) {
+	scmClient, _, _, err := newSCMClient(owner, nil, nil)
+	return scmClient, err
+}
+
+// createSCMClient creates the SCM client of the git server of the job
+func (c *Controller) createSCMClient(job *v1alpha1.LighthouseJob) (scmprovider.SCMClient, string, string, error) {
+	return newSCMClient(job.Spec.Refs.Org, c.gitServerOf(job), c.settings.Config)
+}
+
+// newSCMClient creates the SCM client of the owner on the configured git server, or on the server of the
+// environment if it is nil, writing the comments with the given settings which may be nil
+func newSCMClient(owner string, server *settings.GitServer, settingsGetter settings.Getter) (scmprovider.SCMClient, string, string, error) {
+	kind := gitKind()
 	serverURL := os.Getenv("GIT_SERVER")
 	ghaSecretDir := util.GetGitHubAppSecretDir()
+	bot := botName()
 
 	var token string
 	var err error
-	if ghaSecretDir != "" {
-		tokenFinder := util.NewOwnerTokensDir(serverURL, ghaSecretDir)
+	if server != nil {
+		kind = server.GetKind()
+		serverURL = server.URL
+		if server.User != "" {
+			bot = server.User
+		}
+		token, err = server.Token()
+		if err != nil {
+			return nil, serverURL, token, err
+		}
+	} else if ghaSecretDir != "" {
+		tokenFinder := util.GetGitHubAppTokenManager(serverURL, ghaSecretDir)
 		token, err = tokenFinder.FindToken(owner)
 		if err != nil {
 			logrus.Errorf("failed to read owner token: %s", err.Error())
 			return nil, "", "", errors.Wrapf(err, "failed to read owner token for owner %s", owner)
 		}
 	} else {
-		token, err = c.createSCMToken(kind)
+		token, err = createSCMToken(kind)
 		if err != nil {
 			return nil, serverURL, token, err
 		}
 	}
 
 	client, err := factory.NewClient(kind, serverURL, token)
-	scmClient := scmprovider.ToClient(client, c.GetBotName())
+	scmClient := scmprovider.ToClient(client, bot)
+	scmClient.SetSettings(settingsGetter)
+	scmClient.SetContext(scmprovider.WithAuditReason(context.Background(), "foghorn reporting the jobs"))
 	return scmClient, serverURL, token, err
 }
 
-func (c *Controller) gitKind() string {
+func gitKind() string {
 	kind := os.Getenv("GIT_KIND")
 	if kind == "" {
 		kind = "github"
@@ -643,18 +838,21 @@ func (c *Controller) gitKind() string {
 
 // GetBotName returns the bot name
 func (c *Controller) GetBotName() string {
-	botName := os.Getenv("GIT_USER")
-	if botName == "" {
-		botName = "jenkins-x-bot"
+	return botName()
+}
+
+func botName() string {
+	name := os.Getenv("GIT_USER")
+	if name == "" {
+		name = "jenkins-x-bot"
 	}
-	return botName
+	return name
 }
 
-func (c *Controller) createSCMToken(gitKind string) (string, error) {
-	envName := "GIT_TOKEN"
-	value := os.Getenv(envName)
-	if value == "" {
-		return value, fmt.Errorf("No token available for git kind %s at environment variable $%s", gitKind, envName)
+func createSCMToken(gitKind string) (string, error) {
+	value, err := gittoken.Default().Token()
+	if err != nil {
+		return value, errors.Wrapf(err, "no token available for git kind %s", gitKind)
 	}
 	return value, nil
 }
@@ -0,0 +1,158 @@
+package foghorn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/errorutil"
+	"github.com/jenkins-x/lighthouse/pkg/notifier"
+	"github.com/jenkins-x/lighthouse/pkg/providerstatus"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncActivityRecordDeferredWhileProviderDegraded(t *testing.T) {
+	statusPage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"components": [{"name": "API Requests", "status": "major_outage"}]}`))
+	}))
+	defer statusPage.Close()
+	c := &Controller{logger: logrus.NewEntry(logrus.StandardLogger())}
+	c.SetProviderStatus(providerstatus.New(providerstatus.Options{URL: statusPage.URL, Components: providerstatus.DefaultComponents}))
+	c.providerStatus.Check()
+
+	err := c.syncActivityRecord("jx", &record.ActivityRecord{Name: "org-repo-pr-1-1"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "API Requests is major outage")
+	assert.False(t, errorutil.IsPermanent(err), "the activity is requeued with a backoff")
+}
+
+func TestReportStatusSkipsBatchJobs(t *testing.T) {
+	c := &Controller{logger: logrus.NewEntry(logrus.StandardLogger())}
+	job := &v1alpha1.LighthouseJob{Spec: v1alpha1.LighthouseJobSpec{Type: config.BatchJob}}
+	activity := &record.ActivityRecord{
+		Name:          "org-repo-batch-1",
+		Owner:         "org",
+		Repo:          "repo",
+		GitURL:        "https://github.com/org/repo.git",
+		LastCommitSHA: "abc",
+		Status:        v1alpha1.FailureState,
+	}
+
+	require.NoError(t, c.reportStatus("jx", activity, job))
+	assert.Empty(t, job.Status.LastReportState, "the batch job is not reported on the commit of its first PR")
+	assert.Zero(t, job.Status.ReportAttempts)
+}
+
+func TestReportStatusSkipsSkipReportJobs(t *testing.T) {
+	c := &Controller{logger: logrus.NewEntry(logrus.StandardLogger())}
+	job := &v1alpha1.LighthouseJob{Spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Job: "experimental", SkipReport: true}}
+	activity := &record.ActivityRecord{
+		Name:          "org-repo-pr-1-1",
+		Owner:         "org",
+		Repo:          "repo",
+		GitURL:        "https://github.com/org/repo.git",
+		LastCommitSHA: "abc",
+		Status:        v1alpha1.FailureState,
+	}
+
+	require.NoError(t, c.reportStatus("jx", activity, job))
+	assert.Empty(t, job.Status.LastReportState, "no status is reported for the job")
+	assert.Zero(t, job.Status.ReportAttempts)
+}
+
+func TestJobFailure(t *testing.T) {
+	activity := &record.ActivityRecord{Owner: "org", Repo: "repo", Branch: "PR-12", LastCommitSHA: "abc"}
+	job := &v1alpha1.LighthouseJob{Spec: v1alpha1.LighthouseJobSpec{
+		Type: config.PresubmitJob,
+		Job:  "unit-tests",
+		Refs: &v1alpha1.Refs{Pulls: []v1alpha1.Pull{{Number: 12, Title: "Fix the build", Author: "dev"}}},
+	}}
+	status := &scm.StatusInput{Label: "pr-unit", Desc: "Pipeline failed", Target: "https://dashboard/logs"}
+	assert.Equal(t, notifier.JobFailure{
+		Org:         "org",
+		Repo:        "repo",
+		Branch:      "PR-12",
+		Job:         "unit-tests",
+		Number:      12,
+		Title:       "Fix the build",
+		Author:      "dev",
+		SHA:         "abc",
+		Description: "Pipeline failed",
+		URL:         "https://dashboard/logs",
+	}, jobFailure(activity, job, status))
+
+	job = &v1alpha1.LighthouseJob{Spec: v1alpha1.LighthouseJobSpec{Type: config.PostsubmitJob}}
+	failure := jobFailure(activity, job, status)
+	assert.Equal(t, "pr-unit", failure.Job, "the context names the jobs without a name")
+	assert.Zero(t, failure.Number)
+}
+
+func TestReportTargetURL(t *testing.T) {
+	os.Setenv("LIGHTHOUSE_REPORT_URL_BASE", "")
+	os.Setenv("LIGHTHOUSE_REPORT_URL_TEAM", "")
+	agent := &settings.Agent{}
+	c := &Controller{settings: agent, logger: logrus.NewEntry(logrus.StandardLogger())}
+	activity := &record.ActivityRecord{Branch: "PR-12", BuildIdentifier: "3"}
+
+	assert.Empty(t, c.reportTargetURL("jx", "org", "repo", "unit", activity, "pr-unit"), "no base URL nor template")
+
+	agent.Set(&settings.Config{Foghorn: settings.Foghorn{ReportURL: settings.ReportURL{BaseURL: "https://dashboard/"}}})
+	assert.Equal(t, "https://dashboard/teams/jx/projects/org/repo/PR-12/3", c.reportTargetURL("jx", "org", "repo", "unit", activity, "pr-unit"))
+
+	agent.Set(&settings.Config{Foghorn: settings.Foghorn{ReportURL: settings.ReportURL{
+		BaseURL:  "https://dashboard",
+		Team:     "team",
+		Template: "{{ .BaseURL }}/{{ .Team }}/{{ .Repository }}/{{ .Build }}",
+		Jobs:     map[string]string{"release": "https://release/{{ .Job }}/{{ .Context }}/{{ .Build }}"},
+	}}})
+	assert.Equal(t, "https://dashboard/team/repo/3", c.reportTargetURL("jx", "org", "repo", "unit", activity, "pr-unit"))
+	assert.Equal(t, "https://release/release/release/3", c.reportTargetURL("jx", "org", "repo", "release", activity, "release"))
+}
+
+func TestGitServerOf(t *testing.T) {
+	os.Setenv("GIT_KIND", "")
+	agent := &settings.Agent{}
+	agent.Set(&settings.Config{GitServers: settings.GitServers{
+		{Name: "ghe", URL: "https://github.example.com", TokenEnv: "GHE_TOKEN"},
+		{Name: "gitlab", URL: "https://gitlab.example.com", Kind: "gitlab", TokenEnv: "GITLAB_TOKEN"},
+	}})
+	c := &Controller{settings: agent}
+	job := func(refs *v1alpha1.Refs) *v1alpha1.LighthouseJob {
+		return &v1alpha1.LighthouseJob{Spec: v1alpha1.LighthouseJobSpec{Refs: refs}}
+	}
+
+	server := c.gitServerOf(job(&v1alpha1.Refs{Org: "org", Repo: "repo", RepoLink: "https://github.example.com/org/repo"}))
+	require.NotNil(t, server)
+	assert.Equal(t, "ghe", server.Name)
+	server = c.gitServerOf(job(&v1alpha1.Refs{Org: "org", Repo: "repo", CloneURI: "https://gitlab.example.com/org/repo.git"}))
+	require.NotNil(t, server)
+	assert.Equal(t, "gitlab", gitServerKind(server))
+	assert.Nil(t, c.gitServerOf(job(&v1alpha1.Refs{Org: "org", Repo: "repo", RepoLink: "https://github.com/org/repo"})))
+	assert.Nil(t, c.gitServerOf(job(nil)))
+	assert.Equal(t, "github", gitServerKind(nil), "the kind of the environment")
+}
+
+func TestReportParamsAreTheSettingsParams(t *testing.T) {
+	var names []string
+	paramsType := reflect.TypeOf(ReportParams{})
+	for i := 0; i < paramsType.NumField(); i++ {
+		names = append(names, paramsType.Field(i).Name)
+	}
+	assert.ElementsMatch(t, settings.ReportURLParams, names, "the templates are validated against settings.ReportURLParams")
+}
+
+func TestStatusContext(t *testing.T) {
+	job := &v1alpha1.LighthouseJob{Spec: v1alpha1.LighthouseJobSpec{Context: "ci/pr/unit"}}
+	assert.Equal(t, "ci/pr/unit", statusContext(&record.ActivityRecord{Context: "unit"}, job))
+	assert.Equal(t, "unit", statusContext(&record.ActivityRecord{Context: "unit"}, &v1alpha1.LighthouseJob{}))
+	assert.Equal(t, defaultStatusContext, statusContext(&record.ActivityRecord{}, &v1alpha1.LighthouseJob{}))
+}
@@ -0,0 +1,186 @@
+package foghorn
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultFailureLogLines is the default number of log lines included in failure comments
+	defaultFailureLogLines = 30
+	// maxFailureLogBytes is the maximum size of the log excerpt in a failure comment
+	maxFailureLogBytes = 30000
+	// failureLogCommentTag is used to identify the failure log comment of a context
+	failureLogCommentTag = "<!-- lighthouse failure log: %s -->"
+)
+
+// failureLogClient is the subset of the SCM client needed to post failure log comments
+type failureLogClient interface {
+	BotName() (string, error)
+	ListPullRequestComments(string, string, int) ([]*scm.Comment, error)
+	CreateComment(string, string, int, bool, string) error
+	EditComment(string, string, int, int, string, bool) error
+}
+
+// failedStep is the log excerpt of a failed pipeline step
+type failedStep struct {
+	pod       string
+	container string
+	log       string
+}
+
+// reportFailureLogsEnabled returns true if comments with log excerpts should be posted for failed presubmits
+func (c *Controller) reportFailureLogsEnabled() bool {
+	return os.Getenv("LIGHTHOUSE_REPORT_FAILURE_LOGS") == "true"
+}
+
+// failureLogLines returns the number of log lines to include in failure comments
+func (c *Controller) failureLogLines() int64 {
+	if value := os.Getenv("LIGHTHOUSE_REPORT_FAILURE_LOG_LINES"); value != "" {
+		lines, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && lines > 0 {
+			return lines
+		}
+		c.logger.Warnf("invalid $LIGHTHOUSE_REPORT_FAILURE_LOG_LINES value %q, using %d", value, defaultFailureLogLines)
+	}
+	return defaultFailureLogLines
+}
+
+// reportFailureLogs posts a comment on the pull request with the tail of the log of the failed step
+func (c *Controller) reportFailureLogs(scmClient failureLogClient, ns string, activity *record.ActivityRecord, job *v1alpha1.LighthouseJob, targetURL string) error {
+	if job.Spec.Type != config.PresubmitJob || job.Spec.Refs == nil || len(job.Spec.Refs.Pulls) != 1 {
+		return nil
+	}
+	step, err := findFailedStep(c.kubeClient, ns, activity, c.failureLogLines())
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the logs of the failed step of %s", activity.Name)
+	}
+	if step == nil {
+		return nil
+	}
+	link := activity.LogURL
+	if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
+		link = targetURL
+	}
+	comment := failureLogComment(job.Spec.Context, step, link)
+
+	// each context has a single failure log comment which is updated by the later failures
+	refs := job.Spec.Refs
+	number := refs.Pulls[0].Number
+	comments, err := scmClient.ListPullRequestComments(refs.Org, refs.Repo, number)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the comments of %s/%s#%d", refs.Org, refs.Repo, number)
+	}
+	botName, err := scmClient.BotName()
+	if err != nil {
+		return errors.Wrap(err, "failed to get the bot name")
+	}
+	tag := failureLogTag(job.Spec.Context)
+	for _, existing := range comments {
+		if existing.Author.Login == botName && strings.HasPrefix(existing.Body, tag) {
+			if existing.Body == comment {
+				return nil
+			}
+			return scmClient.EditComment(refs.Org, refs.Repo, number, existing.ID, comment, true)
+		}
+	}
+	return scmClient.CreateComment(refs.Org, refs.Repo, number, true, comment)
+}
+
+// findFailedStep finds the first terminated step container with a non zero exit code in the pods of the activity
+func findFailedStep(kubeClient kubernetes.Interface, ns string, activity *record.ActivityRecord, lines int64) (*failedStep, error) {
+	selector := labels.Set{
+		util.ActivityOwnerLabel:      activity.Owner,
+		util.ActivityRepositoryLabel: activity.Repo,
+		util.ActivityBranchLabel:     activity.Branch,
+		util.ActivityBuildLabel:      activity.BuildIdentifier,
+	}
+	if activity.Context != "" {
+		selector[util.ActivityContextLabel] = activity.Context
+	}
+	pods, err := kubeClient.CoreV1().Pods(ns).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pods matching %s", selector.String())
+	}
+	var failedPod *corev1.Pod
+	var failed *corev1.ContainerStatus
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		for j := range pod.Status.ContainerStatuses {
+			cs := &pod.Status.ContainerStatuses[j]
+			terminated := cs.State.Terminated
+			if terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+			if failed == nil || terminated.FinishedAt.Before(&failed.State.Terminated.FinishedAt) {
+				failedPod = pod
+				failed = cs
+			}
+		}
+	}
+	if failed == nil {
+		return nil, nil
+	}
+	data, err := getPodLogs(kubeClient, ns, failedPod.Name, failed.Name, lines)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the logs of container %s in pod %s", failed.Name, failedPod.Name)
+	}
+	return &failedStep{
+		pod:       failedPod.Name,
+		container: failed.Name,
+		log:       string(data),
+	}, nil
+}
+
+// getPodLogs returns the last lines of the logs of the container, overridden in tests
+// as the fake clientset does not support fetching logs
+var getPodLogs = func(kubeClient kubernetes.Interface, ns, pod, container string, lines int64) ([]byte, error) {
+	return kubeClient.CoreV1().Pods(ns).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		TailLines: &lines,
+	}).Do().Raw()
+}
+
+// failureLogTag returns the tag identifying the failure log comment of the context
+func failureLogTag(context string) string {
+	return fmt.Sprintf(failureLogCommentTag, context)
+}
+
+// failureLogComment creates the collapsible comment body for a failed step
+func failureLogComment(context string, step *failedStep, link string) string {
+	log := step.log
+	if len(log) > maxFailureLogBytes {
+		// keep the tail of the log without splitting a multi-byte character
+		start := len(log) - maxFailureLogBytes
+		for start < len(log) && !utf8.RuneStart(log[start]) {
+			start++
+		}
+		log = "...\n" + log[start:]
+	}
+	stepName := strings.TrimPrefix(step.container, "step-")
+
+	var sb strings.Builder
+	sb.WriteString(failureLogTag(context) + "\n")
+	sb.WriteString(fmt.Sprintf("**%s** failed in step `%s`.\n\n", context, stepName))
+	sb.WriteString("<details>\n<summary>Log excerpt</summary>\n\n```\n")
+	sb.WriteString(strings.TrimRight(log, "\n"))
+	sb.WriteString("\n```\n</details>\n")
+	if link != "" {
+		sb.WriteString(fmt.Sprintf("\n[Full log](%s)\n", link))
+	}
+	return sb.String()
+}
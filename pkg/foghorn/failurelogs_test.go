@@ -0,0 +1,163 @@
+package foghorn
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFindFailedStep(t *testing.T) {
+	now := time.Now()
+	terminated := func(exitCode int32, finished time.Time) corev1.ContainerState {
+		return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, FinishedAt: metav1.NewTime(finished)}}
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-pod",
+			Namespace: "jx",
+			Labels: map[string]string{
+				"owner":      "org",
+				"repository": "repo",
+				"branch":     "PR-1",
+				"build":      "2",
+				"context":    "unit",
+			},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "step-setup", State: terminated(0, now)},
+				{Name: "step-teardown", State: terminated(1, now.Add(time.Minute))},
+				{Name: "step-test", State: terminated(2, now.Add(time.Second))},
+			},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(pod)
+	defer func(f func(kubernetes.Interface, string, string, string, int64) ([]byte, error)) { getPodLogs = f }(getPodLogs)
+	getPodLogs = func(_ kubernetes.Interface, ns, pod, container string, lines int64) ([]byte, error) {
+		return []byte(ns + "/" + pod + "/" + container), nil
+	}
+	activity := &record.ActivityRecord{
+		Owner:           "org",
+		Repo:            "repo",
+		Branch:          "PR-1",
+		BuildIdentifier: "2",
+		Context:         "unit",
+	}
+
+	step, err := findFailedStep(kubeClient, "jx", activity, 10)
+	require.NoError(t, err)
+	require.NotNil(t, step)
+	assert.Equal(t, "my-pod", step.pod)
+	assert.Equal(t, "step-test", step.container)
+	assert.Equal(t, "jx/my-pod/step-test", step.log)
+
+	activity.BuildIdentifier = "3"
+	step, err = findFailedStep(kubeClient, "jx", activity, 10)
+	require.NoError(t, err)
+	assert.Nil(t, step)
+}
+
+func TestFailureLogComment(t *testing.T) {
+	step := &failedStep{container: "step-test", log: strings.Repeat("x", maxFailureLogBytes+10) + "\n"}
+	comment := failureLogComment("unit", step, "https://example.com/logs")
+
+	assert.True(t, strings.HasPrefix(comment, "<!-- lighthouse failure log: unit -->"))
+	assert.Contains(t, comment, "**unit** failed in step `test`.")
+	assert.Contains(t, comment, "<details>")
+	assert.Contains(t, comment, "...\n"+strings.Repeat("x", maxFailureLogBytes-1))
+	assert.NotContains(t, comment, strings.Repeat("x", maxFailureLogBytes+1))
+	assert.Contains(t, comment, "[Full log](https://example.com/logs)")
+}
+
+func TestFailureLogCommentTruncatesRunes(t *testing.T) {
+	step := &failedStep{container: "step-test", log: strings.Repeat("€", maxFailureLogBytes/3+1) + "x"}
+	comment := failureLogComment("unit", step, "")
+
+	assert.True(t, utf8.ValidString(comment))
+	assert.Contains(t, comment, "...\n"+strings.Repeat("€", maxFailureLogBytes/3-1)+"x\n```")
+}
+
+type fakeFailureLogClient struct {
+	comments []*scm.Comment
+	created  int
+	edited   int
+}
+
+func (f *fakeFailureLogClient) BotName() (string, error) {
+	return "bot", nil
+}
+
+func (f *fakeFailureLogClient) ListPullRequestComments(string, string, int) ([]*scm.Comment, error) {
+	return f.comments, nil
+}
+
+func (f *fakeFailureLogClient) CreateComment(_, _ string, _ int, _ bool, body string) error {
+	f.created++
+	f.comments = append(f.comments, &scm.Comment{ID: len(f.comments) + 1, Body: body, Author: scm.User{Login: "bot"}})
+	return nil
+}
+
+func (f *fakeFailureLogClient) EditComment(_, _ string, _ int, id int, body string, _ bool) error {
+	f.edited++
+	for _, c := range f.comments {
+		if c.ID == id {
+			c.Body = body
+		}
+	}
+	return nil
+}
+
+func TestReportFailureLogs(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-pod",
+			Namespace: "jx",
+			Labels:    map[string]string{"owner": "org", "repository": "repo", "branch": "PR-1", "build": "1", "context": "unit"},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "step-test", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+			},
+		},
+	}
+	defer func(f func(kubernetes.Interface, string, string, string, int64) ([]byte, error)) { getPodLogs = f }(getPodLogs)
+	logs := "first failure"
+	getPodLogs = func(kubernetes.Interface, string, string, string, int64) ([]byte, error) {
+		return []byte(logs), nil
+	}
+	c := &Controller{kubeClient: fake.NewSimpleClientset(pod), logger: logrus.NewEntry(logrus.StandardLogger())}
+	activity := &record.ActivityRecord{Name: "a", Owner: "org", Repo: "repo", Branch: "PR-1", BuildIdentifier: "1", Context: "unit"}
+	job := &v1alpha1.LighthouseJob{Spec: v1alpha1.LighthouseJobSpec{
+		Type:    config.PresubmitJob,
+		Context: "unit",
+		Refs:    &v1alpha1.Refs{Org: "org", Repo: "repo", Pulls: []v1alpha1.Pull{{Number: 1}}},
+	}}
+	client := &fakeFailureLogClient{comments: []*scm.Comment{
+		{ID: 1, Body: failureLogTag("unit") + "\nquoted by a user", Author: scm.User{Login: "user"}},
+	}}
+
+	require.NoError(t, c.reportFailureLogs(client, "jx", activity, job, ""))
+	require.NoError(t, c.reportFailureLogs(client, "jx", activity, job, ""))
+	assert.Equal(t, 1, client.created)
+	assert.Equal(t, 0, client.edited, "the same failure should not be commented again")
+
+	logs = "second failure"
+	require.NoError(t, c.reportFailureLogs(client, "jx", activity, job, ""))
+	assert.Equal(t, 1, client.created)
+	assert.Equal(t, 1, client.edited)
+	require.Len(t, client.comments, 2)
+	assert.Contains(t, client.comments[1].Body, "second failure")
+}
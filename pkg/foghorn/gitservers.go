@@ -0,0 +1,30 @@
+package foghorn
+
+import (
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+)
+
+// gitServerOf returns the configured git server hosting the repository of the job, or nil if the repository
+// is on the git server of the environment
+func (c *Controller) gitServerOf(job *v1alpha1.LighthouseJob) *settings.GitServer {
+	refs := job.Spec.Refs
+	if refs == nil {
+		return nil
+	}
+	servers := c.settings.Config().GitServers
+	for _, link := range []string{refs.RepoLink, refs.CloneURI} {
+		if server := servers.ForURL(link); server != nil {
+			return server
+		}
+	}
+	return nil
+}
+
+// gitServerKind returns the kind of the git server, or the kind of the environment if it is nil
+func gitServerKind(server *settings.GitServer) string {
+	if server == nil {
+		return gitKind()
+	}
+	return server.GetKind()
+}
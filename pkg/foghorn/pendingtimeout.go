@@ -0,0 +1,233 @@
+package foghorn
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/launcher/tekton"
+	"github.com/jenkins-x/lighthouse/pkg/messages"
+	"github.com/pkg/errors"
+	tektonclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// pendingCheckInterval is how often jobs are checked for the pending timeout
+	pendingCheckInterval = time.Minute
+	// maxPendingTimeoutEvents is the maximum number of warning events included in nudge comments
+	maxPendingTimeoutEvents = 5
+	// pendingTimeoutCommentTag is used to identify the pending timeout comment of a job
+	pendingTimeoutCommentTag = "<!-- lighthouse pending timeout %s -->"
+	// pendingTimeoutMessage is the message catalog ID of the pending timeout comment
+	pendingTimeoutMessage = "foghorn.pendingTimeout"
+
+	defaultPendingTimeoutComment = `**{{ .Context }}** has been {{ .State }} for more than {{ .Timeout }} without starting and has been marked as errored.
+
+Diagnostics:
+- job: ` + "`{{ .Job }}`" + `
+- created: {{ .Created }}
+{{- if .Activity }}
+- pipeline activity: ` + "`{{ .Activity }}`" + `
+{{- else }}
+- no pipeline activity was created, the pipeline probably failed to start
+{{- end }}
+{{- range .Events }}
+- event: {{ . }}
+{{- end }}
+{{- if .RerunCommand }}
+
+Comment ` + "`{{ .RerunCommand }}`" + ` to run it again.
+{{- end }}
+`
+)
+
+// pendingTimeoutClient is the subset of the SCM client needed to report jobs which never started
+type pendingTimeoutClient interface {
+	CreateStatus(string, string, string, *scm.StatusInput) (*scm.Status, error)
+	CreateComment(string, string, int, bool, string) error
+	ListPullRequestComments(string, string, int) ([]*scm.Comment, error)
+}
+
+// SetTektonClient sets the client used to cancel the PipelineRuns of the jobs which time out pending
+func (c *Controller) SetTektonClient(tektonClient tektonclient.Interface) {
+	c.tektonClient = tektonClient
+}
+
+// pendingTimeout returns how long a presubmit may stay triggered or pending before
+// it is marked as errored, or zero if pending jobs never time out
+func (c *Controller) pendingTimeout() time.Duration {
+	timeout := c.settings.Config().Foghorn.PendingTimeout
+	if timeout == nil || timeout.Duration < 0 {
+		return 0
+	}
+	return timeout.Duration
+}
+
+// checkPendingJobs reports the presubmits which have not started within the configured timeout
+func (c *Controller) checkPendingJobs() {
+	timeout := c.pendingTimeout()
+	if timeout == 0 {
+		return
+	}
+	if degraded, _ := c.providerStatus.Degraded(); degraded {
+		// the jobs may be pending because the git provider did not deliver the events
+		return
+	}
+	jobs, err := c.lhLister.LighthouseJobs(c.ns).List(labels.Everything())
+	if err != nil {
+		c.logger.WithError(err).Error("failed to list LighthouseJobs")
+		return
+	}
+	now := time.Now()
+	for _, job := range jobs {
+		if !pendingTooLong(job, timeout, now) {
+			continue
+		}
+		log := c.logger.WithField("job", job.Name)
+		scmClient, _, _, err := c.createSCMClient(job)
+		if err != nil {
+			log.WithError(err).Warn("failed to create SCM client")
+			continue
+		}
+		if err := c.timeoutPendingJob(scmClient, job.DeepCopy(), timeout, now); err != nil {
+			log.WithError(err).Error("failed to time out pending job")
+		}
+	}
+}
+
+// pendingTooLong returns true if the job is a presubmit which has not started within the timeout
+func pendingTooLong(job *v1alpha1.LighthouseJob, timeout time.Duration, now time.Time) bool {
+	if job.Spec.Type != config.PresubmitJob || job.Spec.Refs == nil || len(job.Spec.Refs.Pulls) != 1 {
+		return false
+	}
+	switch job.Status.State {
+	case "", v1alpha1.TriggeredState, v1alpha1.PendingState:
+	default:
+		return false
+	}
+	created := job.Status.StartTime
+	if created.IsZero() {
+		created = job.CreationTimestamp
+	}
+	return now.Sub(created.Time) > timeout
+}
+
+// timeoutPendingJob completes the job as errored so it is only reported once, cancels its pipeline,
+// marks its context as errored and posts a nudge comment with diagnostics on the pull request unless
+// one was already posted for the job
+func (c *Controller) timeoutPendingJob(scmClient pendingTimeoutClient, job *v1alpha1.LighthouseJob, timeout time.Duration, now time.Time) error {
+	refs := job.Spec.Refs
+	pull := refs.Pulls[0]
+	context := job.Spec.Context
+	if context == "" {
+		context = defaultStatusContext
+	}
+	description := fmt.Sprintf("Did not start within %s", timeout.String())
+	// the diagnostics describe the job before it is completed
+	comment := pendingTimeoutComment(job, context, timeout, c.pendingDiagnostics(job))
+
+	completed := metav1.NewTime(now)
+	job.Status.State = v1alpha1.FailureState
+	job.Status.CompletionTime = &completed
+	job.Status.Description = description
+	job.Status.LastReportState = scm.StateError.String()
+	updated, err := c.lhClient.LighthouseV1alpha1().LighthouseJobs(job.Namespace).UpdateStatus(job)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the status of %s", job.Name)
+	}
+	if err := c.cancelPipelineRuns(updated); err != nil {
+		c.logger.WithError(err).WithField("job", job.Name).Warn("failed to cancel the pipeline of the job")
+	}
+	if job.Spec.SkipReport {
+		c.logger.WithField("job", job.Name).Infof("marked skip_report job as errored as it did not start within %s", timeout.String())
+		return nil
+	}
+
+	_, err = scmClient.CreateStatus(refs.Org, refs.Repo, pull.SHA, &scm.StatusInput{
+		State:  scm.StateError,
+		Label:  context,
+		Desc:   description,
+		Target: job.Status.ReportURL,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to report the error status of %s", job.Name)
+	}
+
+	comments, err := scmClient.ListPullRequestComments(refs.Org, refs.Repo, pull.Number)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the comments of pull request %d", pull.Number)
+	}
+	tag := fmt.Sprintf(pendingTimeoutCommentTag, job.Name)
+	for _, existing := range comments {
+		if strings.Contains(existing.Body, tag) {
+			return nil
+		}
+	}
+	if err := scmClient.CreateComment(refs.Org, refs.Repo, pull.Number, true, comment); err != nil {
+		return errors.Wrapf(err, "failed to comment on pull request %d", pull.Number)
+	}
+	c.logger.WithField("job", job.Name).Infof("marked job as errored as it did not start within %s", timeout.String())
+	return nil
+}
+
+// cancelPipelineRuns cancels the PipelineRuns of the job, so that a pipeline starting after the
+// job timed out does not run for nothing
+func (c *Controller) cancelPipelineRuns(job *v1alpha1.LighthouseJob) error {
+	cancelled, err := tekton.CancelPipelineRuns(c.tektonClient, job)
+	for _, name := range cancelled {
+		c.logger.WithField("job", job.Name).Infof("cancelled PipelineRun %s", name)
+	}
+	return err
+}
+
+// pendingDiagnostics returns the latest warning events of the job
+func (c *Controller) pendingDiagnostics(job *v1alpha1.LighthouseJob) []string {
+	events, err := c.kubeClient.CoreV1().Events(job.Namespace).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.name", job.Name).String(),
+	})
+	if err != nil {
+		c.logger.WithError(err).WithField("job", job.Name).Warn("failed to list the events of the job")
+		return nil
+	}
+	var diagnostics []string
+	for _, event := range events.Items {
+		if event.Type != corev1.EventTypeWarning || event.InvolvedObject.Name != job.Name {
+			continue
+		}
+		diagnostics = append(diagnostics, fmt.Sprintf("%s: %s", event.Reason, strings.TrimSpace(event.Message)))
+	}
+	if len(diagnostics) > maxPendingTimeoutEvents {
+		diagnostics = diagnostics[len(diagnostics)-maxPendingTimeoutEvents:]
+	}
+	return diagnostics
+}
+
+// pendingTimeoutComment creates the nudge comment for a job which has not started
+func pendingTimeoutComment(job *v1alpha1.LighthouseJob, context string, timeout time.Duration, events []string) string {
+	state := job.Status.State
+	if state == "" {
+		state = v1alpha1.TriggeredState
+	}
+	created := job.Status.StartTime
+	if created.IsZero() {
+		created = job.CreationTimestamp
+	}
+	data := map[string]interface{}{
+		"Context":      context,
+		"State":        string(state),
+		"Timeout":      timeout.String(),
+		"Job":          job.Name,
+		"Created":      created.UTC().Format(time.RFC3339),
+		"Activity":     job.Status.ActivityName,
+		"Events":       events,
+		"RerunCommand": job.Spec.RerunCommand,
+	}
+	return fmt.Sprintf(pendingTimeoutCommentTag, job.Name) + "\n" + messages.Render(pendingTimeoutMessage, defaultPendingTimeoutComment, data)
+}
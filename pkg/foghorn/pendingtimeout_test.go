@@ -0,0 +1,149 @@
+package foghorn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	fakelh "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakePendingTimeoutClient struct {
+	statuses []*scm.StatusInput
+	comments []string
+}
+
+func (f *fakePendingTimeoutClient) ListPullRequestComments(org, repo string, number int) ([]*scm.Comment, error) {
+	var comments []*scm.Comment
+	for _, c := range f.comments {
+		comments = append(comments, &scm.Comment{Body: c})
+	}
+	return comments, nil
+}
+
+func (f *fakePendingTimeoutClient) CreateStatus(org, repo, ref string, s *scm.StatusInput) (*scm.Status, error) {
+	f.statuses = append(f.statuses, s)
+	return &scm.Status{}, nil
+}
+
+func (f *fakePendingTimeoutClient) CreateComment(org, repo string, number int, pr bool, comment string) error {
+	f.comments = append(f.comments, comment)
+	return nil
+}
+
+func TestPendingTooLong(t *testing.T) {
+	now := time.Now()
+	job := &v1alpha1.LighthouseJob{
+		Spec: v1alpha1.LighthouseJobSpec{
+			Type: config.PresubmitJob,
+			Refs: &v1alpha1.Refs{Org: "org", Repo: "repo", Pulls: []v1alpha1.Pull{{Number: 1, SHA: "abc"}}},
+		},
+		Status: v1alpha1.LighthouseJobStatus{
+			State:     v1alpha1.PendingState,
+			StartTime: metav1.NewTime(now.Add(-time.Hour)),
+		},
+	}
+	assert.True(t, pendingTooLong(job, 30*time.Minute, now))
+	assert.False(t, pendingTooLong(job, 2*time.Hour, now))
+
+	job.Status.State = v1alpha1.RunningState
+	assert.False(t, pendingTooLong(job, 30*time.Minute, now), "running jobs have started")
+
+	job.Status = v1alpha1.LighthouseJobStatus{}
+	job.CreationTimestamp = metav1.NewTime(now.Add(-time.Hour))
+	assert.True(t, pendingTooLong(job, 30*time.Minute, now), "jobs without a status use their creation time")
+
+	job.Spec.Type = config.PostsubmitJob
+	assert.False(t, pendingTooLong(job, 30*time.Minute, now))
+}
+
+func TestTimeoutPendingJob(t *testing.T) {
+	now := time.Now()
+	job := &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-job", Namespace: "jx", Labels: map[string]string{util.BuildNumLabel: "3"}},
+		Spec: v1alpha1.LighthouseJobSpec{
+			Type:         config.PresubmitJob,
+			Context:      "unit",
+			RerunCommand: "/test unit",
+			Refs:         &v1alpha1.Refs{Org: "org", Repo: "repo", Pulls: []v1alpha1.Pull{{Number: 1, SHA: "abc"}}},
+		},
+		Status: v1alpha1.LighthouseJobStatus{
+			State:     v1alpha1.TriggeredState,
+			StartTime: metav1.NewTime(now.Add(-time.Hour)),
+		},
+	}
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "my-job.1", Namespace: "jx"},
+		InvolvedObject: corev1.ObjectReference{Name: "my-job"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "FailedCreate",
+		Message:        "quota exceeded",
+	}
+	run := &pipelinev1alpha1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "org-repo-pr-1-unit-3", Namespace: "jx", Labels: map[string]string{
+			util.ActivityOwnerLabel:      "org",
+			util.ActivityRepositoryLabel: "repo",
+			util.ActivityBuildLabel:      "3",
+			util.ActivityContextLabel:    "unit",
+		}},
+	}
+	c := &Controller{
+		kubeClient:   fake.NewSimpleClientset(event),
+		lhClient:     fakelh.NewSimpleClientset(job),
+		tektonClient: tektonfake.NewSimpleClientset(run),
+		logger:       logrus.WithField("controller", controllerName),
+		ns:           "jx",
+	}
+	scmClient := &fakePendingTimeoutClient{}
+
+	require.NoError(t, c.timeoutPendingJob(scmClient, job.DeepCopy(), 30*time.Minute, now))
+
+	require.Len(t, scmClient.statuses, 1)
+	assert.Equal(t, scm.StateError, scmClient.statuses[0].State)
+	assert.Equal(t, "unit", scmClient.statuses[0].Label)
+	assert.Equal(t, "Did not start within 30m0s", scmClient.statuses[0].Desc)
+
+	require.Len(t, scmClient.comments, 1)
+	comment := scmClient.comments[0]
+	assert.Contains(t, comment, "<!-- lighthouse pending timeout my-job -->")
+	assert.Contains(t, comment, "**unit** has been triggered for more than 30m0s")
+	assert.Contains(t, comment, "no pipeline activity was created")
+	assert.Contains(t, comment, "- event: FailedCreate: quota exceeded")
+	assert.Contains(t, comment, "Comment `/test unit` to run it again.")
+
+	updated, err := c.lhClient.LighthouseV1alpha1().LighthouseJobs("jx").Get("my-job", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.FailureState, updated.Status.State)
+	assert.Equal(t, "error", updated.Status.LastReportState)
+	assert.NotNil(t, updated.Status.CompletionTime)
+	assert.False(t, pendingTooLong(updated, 30*time.Minute, now))
+
+	cancelled, err := c.tektonClient.TektonV1alpha1().PipelineRuns("jx").Get(run.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, pipelinev1alpha1.PipelineRunSpecStatusCancelled, cancelled.Spec.Status)
+
+	require.NoError(t, c.timeoutPendingJob(scmClient, job.DeepCopy(), 30*time.Minute, now))
+	assert.Len(t, scmClient.comments, 1, "the pull request is only nudged once per job")
+}
+
+func TestPendingTimeout(t *testing.T) {
+	c := &Controller{settings: &settings.Agent{}}
+	assert.Equal(t, time.Duration(0), c.pendingTimeout())
+
+	cfg, err := settings.Load([]byte("foghorn:\n  pendingTimeout: 45m\n"))
+	require.NoError(t, err)
+	c.settings.Set(cfg)
+	assert.Equal(t, 45*time.Minute, c.pendingTimeout())
+}
@@ -0,0 +1,188 @@
+package foghorn
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	tektoninformers "github.com/tektoncd/pipeline/pkg/client/informers/externalversions/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+)
+
+// pipelineRunKeyPrefix distinguishes the PipelineRun keys from the PipelineActivity keys in the work queue
+const pipelineRunKeyPrefix = "pipelinerun:"
+
+// WatchPipelineRuns makes the controller synthesize the status of the LighthouseJobs, including
+// the status of their stages, from the Tekton PipelineRuns and their TaskRuns rather than from
+// the PipelineActivities, removing the dependency on the jx PipelineActivity controller.
+// The PipelineActivity updates are ignored once it is called.
+func (c *Controller) WatchPipelineRuns(informer tektoninformers.PipelineRunInformer) {
+	c.pipelineRunLister = informer.Lister()
+	c.pipelineRunSynced = informer.Informer().HasSynced
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err == nil {
+				c.queue.AddRateLimited(pipelineRunKeyPrefix + key)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			newRun := newObj.(*pipelinev1alpha1.PipelineRun)
+			oldRun := oldObj.(*pipelinev1alpha1.PipelineRun)
+			// Skip updates solely triggered by resyncs. We only care if they're actually different.
+			if oldRun.ResourceVersion == newRun.ResourceVersion {
+				return
+			}
+			key, err := cache.MetaNamespaceKeyFunc(newObj)
+			if err == nil {
+				c.queue.AddRateLimited(pipelineRunKeyPrefix + key)
+			}
+		},
+	})
+}
+
+// syncPipelineRun updates the LighthouseJob of the PipelineRun with the given namespace/name key
+func (c *Controller) syncPipelineRun(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		c.logger.Warnf("invalid resource key: %s", key)
+		return nil
+	}
+	run, err := c.pipelineRunLister.PipelineRuns(namespace).Get(name)
+	if err != nil {
+		if kubeerrors.IsNotFound(err) {
+			c.logger.Warnf("PipelineRun '%s' in work queue no longer exists", key)
+			return nil
+		}
+		return err
+	}
+	activityRecord := ConvertPipelineRun(run)
+	if activityRecord.Owner == "" || activityRecord.Repo == "" || activityRecord.BuildIdentifier == "" {
+		c.logger.Debugf("ignoring PipelineRun %s without the labels of a Lighthouse pipeline", key)
+		return nil
+	}
+	// the labels of the PipelineRun identify a single LighthouseJob
+	return c.syncActivityRecord(namespace, activityRecord, func(*v1alpha1.LighthouseJob) bool {
+		return true
+	})
+}
+
+// ConvertPipelineRun converts a PipelineRun, using the status of its TaskRuns as the stages,
+// to an ActivityRecord. The git details missing from the PipelineRun are filled in from the
+// LighthouseJob when the record is synced.
+func ConvertPipelineRun(run *pipelinev1alpha1.PipelineRun) *record.ActivityRecord {
+	labels := run.Labels
+	ar := &record.ActivityRecord{
+		Name:            run.Name,
+		Owner:           labels[util.ActivityOwnerLabel],
+		Repo:            labels[util.ActivityRepositoryLabel],
+		Branch:          labels[util.ActivityBranchLabel],
+		BuildIdentifier: labels[util.ActivityBuildLabel],
+		Context:         labels[util.ActivityContextLabel],
+		Status:          conditionState(run.Status.GetCondition(apis.ConditionSucceeded), run.Status.StartTime, run.Spec.Status == pipelinev1alpha1.PipelineRunSpecStatusCancelled),
+		StartTime:       run.Status.StartTime,
+		CompletionTime:  run.Status.CompletionTime,
+		Stages:          []*record.ActivityStageOrStep{},
+	}
+
+	for _, taskRun := range run.Status.TaskRuns {
+		if taskRun == nil || taskRun.Status == nil {
+			continue
+		}
+		status := taskRun.Status
+		stage := &record.ActivityStageOrStep{
+			Name:           taskRun.PipelineTaskName,
+			Status:         conditionState(status.GetCondition(apis.ConditionSucceeded), status.StartTime, false),
+			StartTime:      status.StartTime,
+			CompletionTime: status.CompletionTime,
+			Steps:          []*record.ActivityStageOrStep{},
+		}
+		for _, step := range status.Steps {
+			stage.Steps = append(stage.Steps, convertStepState(step.Name, step.ContainerState))
+		}
+		ar.Stages = append(ar.Stages, stage)
+	}
+	sort.SliceStable(ar.Stages, func(i, j int) bool {
+		si, sj := ar.Stages[i].StartTime, ar.Stages[j].StartTime
+		if si == nil || sj == nil {
+			return si != nil || (sj == nil && ar.Stages[i].Name < ar.Stages[j].Name)
+		}
+		if si.Equal(sj) {
+			return ar.Stages[i].Name < ar.Stages[j].Name
+		}
+		return si.Before(sj)
+	})
+	return ar
+}
+
+// conditionState converts the Succeeded condition of a PipelineRun or TaskRun to a PipelineState
+func conditionState(condition *apis.Condition, startTime *metav1.Time, cancelled bool) v1alpha1.PipelineState {
+	switch {
+	case condition == nil:
+		if startTime != nil {
+			return v1alpha1.RunningState
+		}
+		return v1alpha1.PendingState
+	case condition.Status == corev1.ConditionTrue:
+		return v1alpha1.SuccessState
+	case condition.Status == corev1.ConditionFalse:
+		if cancelled || strings.Contains(condition.Reason, "Cancelled") {
+			return v1alpha1.AbortedState
+		}
+		return v1alpha1.FailureState
+	case condition.Reason == "Pending":
+		return v1alpha1.PendingState
+	default:
+		return v1alpha1.RunningState
+	}
+}
+
+func convertStepState(name string, state corev1.ContainerState) *record.ActivityStageOrStep {
+	step := &record.ActivityStageOrStep{Name: name}
+	switch {
+	case state.Terminated != nil:
+		step.Status = v1alpha1.SuccessState
+		if state.Terminated.ExitCode != 0 {
+			step.Status = v1alpha1.FailureState
+		}
+		step.StartTime = &state.Terminated.StartedAt
+		step.CompletionTime = &state.Terminated.FinishedAt
+	case state.Running != nil:
+		step.Status = v1alpha1.RunningState
+		step.StartTime = &state.Running.StartedAt
+	default:
+		step.Status = v1alpha1.PendingState
+	}
+	return step
+}
+
+// fillActivityRefs fills in the git details of the activity which are missing from the
+// details of the LighthouseJob
+func fillActivityRefs(activity *record.ActivityRecord, job *v1alpha1.LighthouseJob) {
+	refs := job.Spec.Refs
+	if refs == nil {
+		return
+	}
+	if activity.BaseSHA == "" {
+		activity.BaseSHA = refs.BaseSHA
+	}
+	if activity.LastCommitSHA == "" {
+		activity.LastCommitSHA = refs.BaseSHA
+		if len(refs.Pulls) > 0 {
+			activity.LastCommitSHA = refs.Pulls[0].SHA
+		}
+	}
+	if activity.GitURL == "" {
+		activity.GitURL = refs.CloneURI
+		if activity.GitURL == "" && refs.RepoLink != "" {
+			activity.GitURL = refs.RepoLink + ".git"
+		}
+	}
+}
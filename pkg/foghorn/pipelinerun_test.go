@@ -0,0 +1,118 @@
+package foghorn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+func succeeded(status corev1.ConditionStatus, reason string) duckv1beta1.Status {
+	return duckv1beta1.Status{Conditions: duckv1beta1.Conditions{{Type: apis.ConditionSucceeded, Status: status, Reason: reason}}}
+}
+
+func TestConvertPipelineRun(t *testing.T) {
+	start := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	later := metav1.NewTime(start.Add(time.Minute))
+	run := &pipelinev1alpha1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "org-repo-pr-1-1",
+			Labels: map[string]string{
+				"owner":      "org",
+				"repository": "repo",
+				"branch":     "PR-1",
+				"build":      "1",
+				"context":    "lint",
+			},
+		},
+		Status: pipelinev1alpha1.PipelineRunStatus{
+			Status: succeeded(corev1.ConditionUnknown, "Running"),
+			PipelineRunStatusFields: v1beta1.PipelineRunStatusFields{
+				StartTime: &start,
+				TaskRuns: map[string]*v1beta1.PipelineRunTaskRunStatus{
+					"run-test": {
+						PipelineTaskName: "test",
+						Status: &v1beta1.TaskRunStatus{
+							Status: succeeded(corev1.ConditionUnknown, "Running"),
+							TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+								StartTime: &later,
+								Steps: []v1beta1.StepState{
+									{Name: "unit", ContainerState: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: later}}},
+									{Name: "e2e", ContainerState: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}},
+								},
+							},
+						},
+					},
+					"run-build": {
+						PipelineTaskName: "build",
+						Status: &v1beta1.TaskRunStatus{
+							Status: succeeded(corev1.ConditionTrue, "Succeeded"),
+							TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+								StartTime:      &start,
+								CompletionTime: &later,
+								Steps: []v1beta1.StepState{
+									{Name: "compile", ContainerState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{StartedAt: start, FinishedAt: later}}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	ar := ConvertPipelineRun(run)
+	assert.Equal(t, "org", ar.Owner)
+	assert.Equal(t, "repo", ar.Repo)
+	assert.Equal(t, "PR-1", ar.Branch)
+	assert.Equal(t, "1", ar.BuildIdentifier)
+	assert.Equal(t, "lint", ar.Context)
+	assert.Equal(t, v1alpha1.RunningState, ar.Status)
+	require.Len(t, ar.Stages, 2)
+	assert.Equal(t, "build", ar.Stages[0].Name)
+	assert.Equal(t, v1alpha1.SuccessState, ar.Stages[0].Status)
+	assert.Equal(t, v1alpha1.SuccessState, ar.Stages[0].Steps[0].Status)
+	assert.Equal(t, "test", ar.Stages[1].Name)
+	assert.Equal(t, v1alpha1.RunningState, ar.Stages[1].Status)
+	require.Len(t, ar.Stages[1].Steps, 2)
+	assert.Equal(t, v1alpha1.RunningState, ar.Stages[1].Steps[0].Status)
+	assert.Equal(t, v1alpha1.PendingState, ar.Stages[1].Steps[1].Status)
+
+	run.Status.Status = succeeded(corev1.ConditionFalse, "Failed")
+	assert.Equal(t, v1alpha1.FailureState, ConvertPipelineRun(run).Status)
+	run.Status.Status = succeeded(corev1.ConditionFalse, "PipelineRunCancelled")
+	assert.Equal(t, v1alpha1.AbortedState, ConvertPipelineRun(run).Status)
+	run.Status.Status = succeeded(corev1.ConditionTrue, "Succeeded")
+	assert.Equal(t, v1alpha1.SuccessState, ConvertPipelineRun(run).Status)
+}
+
+func TestFillActivityRefs(t *testing.T) {
+	job := &v1alpha1.LighthouseJob{
+		Spec: v1alpha1.LighthouseJobSpec{
+			Refs: &v1alpha1.Refs{
+				BaseSHA:  "base",
+				RepoLink: "https://github.com/org/repo",
+				Pulls:    []v1alpha1.Pull{{Number: 1, SHA: "head"}},
+			},
+		},
+	}
+	ar := &record.ActivityRecord{}
+	fillActivityRefs(ar, job)
+	assert.Equal(t, "base", ar.BaseSHA)
+	assert.Equal(t, "head", ar.LastCommitSHA)
+	assert.Equal(t, "https://github.com/org/repo.git", ar.GitURL)
+
+	ar = &record.ActivityRecord{LastCommitSHA: "sha", GitURL: "https://example.com/repo.git"}
+	fillActivityRefs(ar, job)
+	assert.Equal(t, "sha", ar.LastCommitSHA)
+	assert.Equal(t, "https://example.com/repo.git", ar.GitURL)
+}
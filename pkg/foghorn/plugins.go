@@ -15,10 +15,12 @@ import (
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/label"
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/lgtm"
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/lifecycle"
+	_ "github.com/jenkins-x/lighthouse/pkg/plugins/mergewhenready"
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/milestone"
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/milestonestatus"
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/override"
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/owners-label"
+	_ "github.com/jenkins-x/lighthouse/pkg/plugins/pathlabel"
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/pony"
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/shrug"
 	_ "github.com/jenkins-x/lighthouse/pkg/plugins/sigmention"
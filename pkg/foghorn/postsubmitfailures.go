@@ -0,0 +1,79 @@
+package foghorn
+
+import (
+	"sort"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/notifier"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// postsubmitLabels are the labels identifying the runs of a postsubmit job on a branch
+var postsubmitLabels = []string{config.LighthouseJobTypeLabel, util.LighthouseJobAnnotation, util.OrgLabel, util.RepoLabel, util.BranchLabel}
+
+// notifyPostsubmitFailures emails the owners of the repository when the failed postsubmit job reaches the
+// configured number of consecutive failures on its branch. The failures are counted from the LighthouseJobs of
+// the previous runs, so that the owners are emailed once per series of failures, even if foghorn restarts.
+func (c *Controller) notifyPostsubmitFailures(ns string, job *v1alpha1.LighthouseJob, failure notifier.JobFailure) {
+	if job.Spec.Type != config.PostsubmitJob {
+		return
+	}
+	threshold := c.emailer.Threshold(failure.Org, failure.Repo)
+	if threshold <= 0 {
+		return
+	}
+	count, err := c.consecutiveFailures(ns, job)
+	if err != nil {
+		c.logger.WithField("job", job.Name).WithError(err).Warn("failed to count the consecutive failures of the postsubmit")
+		return
+	}
+	if count != threshold {
+		return
+	}
+	c.emailer.PostsubmitFailed(notifier.PostsubmitFailures{JobFailure: failure, Count: count})
+}
+
+// consecutiveFailures returns the number of times the postsubmit job failed in a row on its branch, including
+// the given failed run
+func (c *Controller) consecutiveFailures(ns string, job *v1alpha1.LighthouseJob) (int, error) {
+	selector := map[string]string{}
+	for _, key := range postsubmitLabels {
+		if value, ok := job.Labels[key]; ok {
+			selector[key] = value
+		}
+	}
+	list, err := c.lhClient.LighthouseV1alpha1().LighthouseJobs(ns).List(metav1.ListOptions{LabelSelector: labels.SelectorFromSet(selector).String()})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list the runs of the postsubmit")
+	}
+	return countConsecutiveFailures(job, list.Items), nil
+}
+
+// countConsecutiveFailures counts the failures of the previous completed runs of the job, from the most recent
+// one until one succeeded, plus the given failed run. The aborted runs are ignored.
+func countConsecutiveFailures(job *v1alpha1.LighthouseJob, runs []v1alpha1.LighthouseJob) int {
+	var previous []v1alpha1.LighthouseJob
+	for i := range runs {
+		run := runs[i]
+		if run.Name != job.Name && isCompleted(&run) && run.CreationTimestamp.Before(&job.CreationTimestamp) {
+			previous = append(previous, run)
+		}
+	}
+	sort.Slice(previous, func(i, j int) bool {
+		return previous[j].CreationTimestamp.Before(&previous[i].CreationTimestamp)
+	})
+	count := 1
+	for _, run := range previous {
+		switch run.Status.State {
+		case v1alpha1.FailureState:
+			count++
+		case v1alpha1.SuccessState:
+			return count
+		}
+	}
+	return count
+}
@@ -0,0 +1,62 @@
+package foghorn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	lhfake "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func postsubmitRun(name, branch string, state v1alpha1.PipelineState, created time.Time) *v1alpha1.LighthouseJob {
+	return &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "jx",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels: map[string]string{
+				config.LighthouseJobTypeLabel: string(config.PostsubmitJob),
+				util.LighthouseJobAnnotation:  "release",
+				util.OrgLabel:                 "org",
+				util.RepoLabel:                "repo",
+				util.BranchLabel:              branch,
+			},
+		},
+		Spec:   v1alpha1.LighthouseJobSpec{Type: config.PostsubmitJob, Job: "release"},
+		Status: v1alpha1.LighthouseJobStatus{State: state},
+	}
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	current := postsubmitRun("run-6", "master", v1alpha1.FailureState, now)
+	objects := []runtime.Object{
+		postsubmitRun("run-1", "master", v1alpha1.FailureState, now.Add(-5*time.Hour)),
+		postsubmitRun("run-2", "master", v1alpha1.SuccessState, now.Add(-4*time.Hour)),
+		postsubmitRun("run-3", "master", v1alpha1.FailureState, now.Add(-3*time.Hour)),
+		postsubmitRun("run-4", "master", v1alpha1.AbortedState, now.Add(-2*time.Hour)),
+		postsubmitRun("run-5", "master", v1alpha1.FailureState, now.Add(-time.Hour)),
+		postsubmitRun("other-branch", "release", v1alpha1.FailureState, now.Add(-time.Hour)),
+		postsubmitRun("running", "master", v1alpha1.PendingState, now.Add(-time.Minute)),
+		current,
+	}
+	c := &Controller{lhClient: lhfake.NewSimpleClientset(objects...), logger: logrus.NewEntry(logrus.StandardLogger())}
+
+	count, err := c.consecutiveFailures("jx", current)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count, "the aborted, running and other branch runs are ignored and the success ends the series")
+
+	assert.Equal(t, 1, countConsecutiveFailures(current, nil))
+
+	// without an emailer the runs are not even listed
+	c.notifyPostsubmitFailures("jx", current, jobFailure(&record.ActivityRecord{}, current, &scm.StatusInput{}))
+}
@@ -0,0 +1,115 @@
+package foghorn
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultMaxReportAttempts is the number of failed attempts to report the status of a job after which
+	// foghorn gives up reporting it
+	DefaultMaxReportAttempts = 10
+
+	// minReportBackoff is the delay before the first retry of a failed report, doubled for each attempt
+	minReportBackoff = 10 * time.Second
+	// maxReportBackoff is the longest delay between two attempts to report the status of a job
+	maxReportBackoff = 10 * time.Minute
+)
+
+// reportRetryError is returned when the report of a job failed or is deferred, so that its item is
+// requeued once the backoff recorded in the job elapsed
+type reportRetryError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *reportRetryError) Error() string {
+	return fmt.Sprintf("%s, retrying the report in %s", e.err.Error(), e.after)
+}
+
+func (e *reportRetryError) Cause() error {
+	return e.err
+}
+
+// reportRetryDelay returns the delay before the report of the job is retried if the error is a failed or
+// deferred report
+func reportRetryDelay(err error) (time.Duration, bool) {
+	type causer interface {
+		Cause() error
+	}
+	for err != nil {
+		if e, ok := err.(*reportRetryError); ok {
+			return e.after, true
+		}
+		c, ok := err.(causer)
+		if !ok {
+			return 0, false
+		}
+		err = c.Cause()
+	}
+	return 0, false
+}
+
+// SetMaxReportAttempts sets the number of failed attempts to report the status of a job after which
+// foghorn gives up reporting it
+func (c *Controller) SetMaxReportAttempts(attempts int) {
+	c.maxReportAttempts = attempts
+}
+
+// reportBackoff returns the delay before the attempt following the given number of failed attempts
+func reportBackoff(attempts int) time.Duration {
+	backoff := minReportBackoff
+	for i := 1; i < attempts && backoff < maxReportBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxReportBackoff {
+		backoff = maxReportBackoff
+	}
+	return backoff
+}
+
+// deferredReport returns a reportRetryError if the previous report of the job failed and its backoff has
+// not elapsed yet
+func deferredReport(job *v1alpha1.LighthouseJob, now time.Time) error {
+	next := job.Status.NextReportTime
+	if next == nil || !now.Before(next.Time) {
+		return nil
+	}
+	return &reportRetryError{
+		err:   errors.Errorf("the report of job %s failed %d times", job.Name, job.Status.ReportAttempts),
+		after: next.Sub(now),
+	}
+}
+
+// recordReportFailure records the failed attempt to report the status of the job in its status. It returns
+// a reportRetryError with the backoff of the attempt, or nil once the job ran out of attempts and its
+// report failed for good.
+func (c *Controller) recordReportFailure(job *v1alpha1.LighthouseJob, err error, now time.Time) error {
+	maxAttempts := c.maxReportAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxReportAttempts
+	}
+	job.Status.ReportAttempts++
+	job.Status.LastReportError = err.Error()
+	if job.Status.ReportAttempts >= maxAttempts {
+		job.Status.ReportFailed = true
+		job.Status.NextReportTime = nil
+		c.logger.WithError(err).WithField("job", job.Name).Errorf("giving up reporting the status of job %s after %d attempts", job.Name, job.Status.ReportAttempts)
+		return nil
+	}
+	backoff := reportBackoff(job.Status.ReportAttempts)
+	next := metav1.NewTime(now.Add(backoff))
+	job.Status.NextReportTime = &next
+	return &reportRetryError{err: err, after: backoff}
+}
+
+// recordReportSuccess clears the failed attempts of the job once its status is reported
+func recordReportSuccess(job *v1alpha1.LighthouseJob) {
+	job.Status.ReportAttempts = 0
+	job.Status.LastReportError = ""
+	job.Status.NextReportTime = nil
+}
@@ -0,0 +1,51 @@
+package foghorn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReportFailure(t *testing.T) {
+	c := &Controller{logger: logrus.NewEntry(logrus.StandardLogger())}
+	c.SetMaxReportAttempts(3)
+	job := &v1alpha1.LighthouseJob{}
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	err := c.recordReportFailure(job, errors.New("502 Bad Gateway"), now)
+	require.Error(t, err)
+	after, ok := reportRetryDelay(errors.Wrap(err, "failed to sync"))
+	require.True(t, ok)
+	assert.Equal(t, minReportBackoff, after)
+	assert.Equal(t, 1, job.Status.ReportAttempts)
+	assert.Equal(t, "502 Bad Gateway", job.Status.LastReportError)
+	require.NotNil(t, job.Status.NextReportTime)
+	assert.Equal(t, now.Add(minReportBackoff), job.Status.NextReportTime.Time)
+
+	deferred := deferredReport(job, now.Add(4*time.Second))
+	after, ok = reportRetryDelay(deferred)
+	require.True(t, ok, "the report is deferred until the backoff elapsed")
+	assert.Equal(t, 6*time.Second, after)
+	assert.NoError(t, deferredReport(job, now.Add(minReportBackoff)))
+
+	err = c.recordReportFailure(job, errors.New("502 Bad Gateway"), now)
+	after, _ = reportRetryDelay(err)
+	assert.Equal(t, 2*minReportBackoff, after, "the backoff is doubled for each attempt")
+
+	assert.NoError(t, c.recordReportFailure(job, errors.New("502 Bad Gateway"), now), "the job ran out of attempts")
+	assert.True(t, job.Status.ReportFailed)
+	assert.Nil(t, job.Status.NextReportTime)
+
+	recordReportSuccess(job)
+	assert.Equal(t, 0, job.Status.ReportAttempts)
+	assert.Empty(t, job.Status.LastReportError)
+
+	assert.Equal(t, maxReportBackoff, reportBackoff(20))
+	_, ok = reportRetryDelay(errors.New("not found"))
+	assert.False(t, ok)
+}
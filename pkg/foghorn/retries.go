@@ -0,0 +1,110 @@
+package foghorn
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/launcher"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// retryCheckInterval is how often the errored jobs are checked for retries
+	retryCheckInterval = 15 * time.Second
+	// retryBackoff is how long after the first attempt errored the job is retried, which doubles
+	// with each attempt up to maxRetryBackoff
+	retryBackoff    = 30 * time.Second
+	maxRetryBackoff = 10 * time.Minute
+)
+
+// EnableRetries launches again the jobs configured with retries whose pipeline errored, with the launchers
+// of the agents created from the clients
+func (c *Controller) EnableRetries(kubeClients *clients.Clients) error {
+	l, err := launcher.NewAgentLauncher(launcher.Options{Clients: kubeClients, Config: c.jobConfig.Config, Settings: c.settings.Config})
+	if err != nil {
+		return errors.Wrap(err, "failed to create the launcher of the retries")
+	}
+	c.launcher = l
+	return nil
+}
+
+// awaitingRetry returns true if the job is retried once its backoff elapsed, in which case it is not labelled
+// as completed so that it stays in the informer cache until then
+func (c *Controller) awaitingRetry(job *v1alpha1.LighthouseJob) bool {
+	return c.launcher != nil && jobutil.CanRetry(job)
+}
+
+// retryErroredJobs launches the next attempt of the errored jobs whose backoff elapsed
+func (c *Controller) retryErroredJobs() {
+	if c.launcher == nil {
+		return
+	}
+	jobs, err := c.lhLister.LighthouseJobs(c.ns).List(labels.Everything())
+	if err != nil {
+		c.logger.WithError(err).Error("failed to list LighthouseJobs")
+		return
+	}
+	now := time.Now()
+	for _, job := range jobs {
+		if !jobutil.CanRetry(job) || !retryDue(job, now) {
+			continue
+		}
+		if err := c.retry(job); err != nil {
+			c.logger.WithField("job", job.Name).WithError(err).Error("failed to retry the errored job")
+		}
+	}
+}
+
+// retry launches the next attempt of the job and links the job to it
+func (c *Controller) retry(job *v1alpha1.LighthouseJob) error {
+	next := jobutil.NewRetry(job)
+	log := c.logger.WithFields(jobutil.LighthouseJobFields(&next))
+	if _, err := c.launcher.Launch(&next, retryRepository(job)); err != nil {
+		return errors.Wrapf(err, "failed to launch %s", next.Name)
+	}
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, jobutil.RetriedByAnnotation, next.Name))
+	if _, err := c.lhClient.LighthouseV1alpha1().LighthouseJobs(job.Namespace).Patch(job.Name, types.MergePatchType, patch); err != nil {
+		return errors.Wrapf(err, "failed to link %s to its retry", job.Name)
+	}
+	log.Infof("Retried errored LighthouseJob %s, attempt %d of %d.", job.Name, jobutil.Attempt(&next), jobutil.Retries(job)+1)
+	return nil
+}
+
+// retryDue returns true if the backoff of the errored job elapsed
+func retryDue(job *v1alpha1.LighthouseJob, now time.Time) bool {
+	if job.Status.CompletionTime == nil {
+		return true
+	}
+	backoff := retryBackoff << uint(jobutil.Attempt(job)-1)
+	if backoff <= 0 || backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return now.Sub(job.Status.CompletionTime.Time) >= backoff
+}
+
+// retryRepository returns the repository of the job, whose clone URL defaults to the link of the repository
+func retryRepository(job *v1alpha1.LighthouseJob) scm.Repository {
+	refs := job.Spec.Refs
+	if refs == nil {
+		return scm.Repository{}
+	}
+	clone := refs.CloneURI
+	if clone == "" && refs.RepoLink != "" {
+		clone = strings.TrimSuffix(refs.RepoLink, "/") + ".git"
+	}
+	return scm.Repository{
+		Namespace: refs.Org,
+		Name:      refs.Repo,
+		FullName:  scm.Join(refs.Org, refs.Repo),
+		Branch:    refs.BaseRef,
+		Clone:     clone,
+		Link:      refs.RepoLink,
+	}
+}
@@ -0,0 +1,37 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// BinaryEnvVar is the environment variable containing the path of the git binary, for
+// images where git is not in the PATH
+const BinaryEnvVar = "LIGHTHOUSE_GIT_BINARY"
+
+// findBinary returns the path of the git binary
+func findBinary() (string, error) {
+	if path := os.Getenv(BinaryEnvVar); path != "" {
+		g, err := exec.LookPath(path)
+		if err != nil {
+			return "", errors.Wrapf(err, "the git binary $%s=%s is not executable", BinaryEnvVar, path)
+		}
+		return g, nil
+	}
+	g, err := exec.LookPath("git")
+	if err != nil {
+		return "", errors.Wrapf(err, "git is not in the PATH, install it or set $%s to its path", BinaryEnvVar)
+	}
+	return g, nil
+}
+
+// command returns the git command with the given arguments. Git never prompts for
+// credentials so that commands fail rather than hang when the remote cannot be reached
+// or rejects the credentials, e.g. in air-gapped clusters.
+func command(git string, arg ...string) *exec.Cmd {
+	cmd := exec.Command(git, arg...) // #nosec
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
@@ -0,0 +1,69 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-binary")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pathDir := filepath.Join(dir, "bin")
+	require.NoError(t, os.Mkdir(pathDir, 0700))
+	pathGit := filepath.Join(pathDir, "git")
+	require.NoError(t, ioutil.WriteFile(pathGit, []byte("#!/bin/sh\n"), 0700)) // #nosec
+	customGit := filepath.Join(dir, "custom-git")
+	require.NoError(t, ioutil.WriteFile(customGit, []byte("#!/bin/sh\n"), 0700)) // #nosec
+	notExecutable := filepath.Join(dir, "not-executable")
+	require.NoError(t, ioutil.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0600))
+
+	for _, name := range []string{BinaryEnvVar, "PATH"} {
+		defer os.Setenv(name, os.Getenv(name))
+	}
+
+	tests := []struct {
+		name     string
+		binary   string
+		path     string
+		expected string
+		err      bool
+	}{
+		{name: "in the PATH", path: pathDir, expected: pathGit},
+		{name: "not in the PATH", path: dir, err: true},
+		{name: "set", binary: customGit, path: pathDir, expected: customGit},
+		{name: "set without git in the PATH", binary: customGit, path: dir, expected: customGit},
+		{name: "set to a missing binary", binary: filepath.Join(dir, "missing"), path: pathDir, err: true},
+		{name: "set to a file which is not executable", binary: notExecutable, path: pathDir, err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.binary == "" {
+				os.Unsetenv(BinaryEnvVar)
+			} else {
+				os.Setenv(BinaryEnvVar, tc.binary)
+			}
+			os.Setenv("PATH", tc.path)
+
+			g, err := findBinary()
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, g)
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	cmd := command("/usr/bin/git", "fetch", "origin")
+	assert.Equal(t, []string{"/usr/bin/git", "fetch", "origin"}, cmd.Args)
+	assert.Contains(t, cmd.Env, "GIT_TERMINAL_PROMPT=0", "git never prompts for credentials")
+}
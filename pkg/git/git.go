@@ -25,6 +25,7 @@ type Client interface {
 	SetRemote(remote string)
 	SetCredentials(user string, tokenGenerator func() []byte)
 	Clone(repo string) (*Repo, error)
+	Rename(from, to string) error
 }
 
 // client can clone repos. It keeps a local cache, so successive clones of the
@@ -64,7 +65,7 @@ func (c *client) Clean() error {
 }
 
 // NewClient returns a client that talks to GitHub. It will fail if git is not
-// in the PATH.
+// in the PATH or at the path of $LIGHTHOUSE_GIT_BINARY.
 func NewClient(serverURL string, gitKind string) (Client, error) {
 	t, err := ioutil.TempDir("", "git")
 	if err != nil {
@@ -75,7 +76,7 @@ func NewClient(serverURL string, gitKind string) (Client, error) {
 
 // NewClientWithDir uses an existing directory for creating the client.
 func NewClientWithDir(serverURL string, gitKind string, dir string) (Client, error) {
-	g, err := exec.LookPath("git")
+	g, err := findBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -150,18 +151,7 @@ func (c *client) Clone(repo string) (*Repo, error) {
 		if err := os.Mkdir(filepath.Dir(cache), os.ModePerm); err != nil && !os.IsExist(err) {
 			return nil, err
 		}
-		prefix := ""
-		repoText := repo
-		if c.gitKind == kindBitbucketServer {
-			prefix = "scm/"
-			idx := strings.Index(repo, "/")
-
-			// to clone on bitbucket we need to lower case the projectKey owner
-			if idx > 0 {
-				repoText = fmt.Sprintf("%s/%s", strings.ToLower(repo[0:idx]), repo[idx+1:])
-			}
-		}
-		remote := fmt.Sprintf("%s/%s%s", base, prefix, repoText)
+		remote := c.remote(base, repo)
 		if b, err := retryCmd(c.logger, "", c.git, "clone", "--mirror", remote, cache); err != nil {
 			return nil, fmt.Errorf("git cache clone error: %v. output: %s", err, string(b))
 		}
@@ -178,7 +168,7 @@ func (c *client) Clone(repo string) (*Repo, error) {
 	if err != nil {
 		return nil, err
 	}
-	b, err := exec.Command(c.git, "clone", cache, t).CombinedOutput() // #nosec
+	b, err := command(c.git, "clone", cache, t).CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("git repo clone error: %v. output: %s", err, string(b))
 	}
@@ -193,6 +183,64 @@ func (c *client) Clone(repo string) (*Repo, error) {
 	}, nil
 }
 
+// Rename moves the local cache of a repository which has been renamed or transferred
+// to another owner, so the next clone of the new name fetches instead of cloning again.
+func (c *client) Rename(from, to string) error {
+	// Always lock in the same order to avoid deadlocks with concurrent renames.
+	first, second := from, to
+	if second < first {
+		first, second = second, first
+	}
+	c.lockRepo(first)
+	defer c.unlockRepo(first)
+	c.lockRepo(second)
+	defer c.unlockRepo(second)
+
+	oldCache := filepath.Join(c.dir, from) + ".git"
+	newCache := filepath.Join(c.dir, to) + ".git"
+	if _, err := os.Stat(oldCache); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := os.Stat(newCache); err == nil {
+		// The new name has already been cloned so the old cache is stale.
+		return os.RemoveAll(oldCache)
+	}
+	if err := os.MkdirAll(filepath.Dir(newCache), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(oldCache, newCache); err != nil {
+		return err
+	}
+	base := c.base
+	user, pass := c.getCredentials()
+	if user != "" && pass != "" {
+		base = fmt.Sprintf("https://%s:%s@%s", user, pass, gitHost(c.base))
+	}
+	if b, err := command(c.git, "-C", newCache, "remote", "set-url", "origin", c.remote(base, to)).CombinedOutput(); err != nil {
+		return fmt.Errorf("git remote set-url error: %v. output: %s", err, string(b))
+	}
+	c.logger.Infof("Moved the cache of %s to %s.", from, to)
+	return nil
+}
+
+// remote returns the URL of the repository on the git server at base
+func (c *client) remote(base, repo string) string {
+	prefix := ""
+	repoText := repo
+	if c.gitKind == kindBitbucketServer {
+		prefix = "scm/"
+		idx := strings.Index(repo, "/")
+
+		// to clone on bitbucket we need to lower case the projectKey owner
+		if idx > 0 {
+			repoText = fmt.Sprintf("%s/%s", strings.ToLower(repo[0:idx]), repo[idx+1:])
+		}
+	}
+	return fmt.Sprintf("%s/%s%s", base, prefix, repoText)
+}
+
 func gitHost(s string) string {
 	u, err := url.Parse(s)
 	if err == nil {
@@ -227,7 +275,7 @@ func (r *Repo) Clean() error {
 }
 
 func (r *Repo) gitCommand(arg ...string) *exec.Cmd {
-	cmd := exec.Command(r.git, arg...) // #nosec
+	cmd := command(r.git, arg...)
 	cmd.Dir = r.Dir
 	return cmd
 }
@@ -347,7 +395,7 @@ func retryCmd(l *logrus.Entry, dir, cmd string, arg ...string) ([]byte, error) {
 	var err error
 	sleepyTime := time.Second
 	for i := 0; i < 3; i++ {
-		c := exec.Command(cmd, arg...) // #nosec
+		c := command(cmd, arg...)
 		c.Dir = dir
 		b, err = c.CombinedOutput()
 		if err != nil {
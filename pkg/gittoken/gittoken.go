@@ -0,0 +1,99 @@
+// Package gittoken provides the git token the components authenticate to the git provider with, either
+// read from the environment or fetched from HashiCorp Vault and refreshed at runtime, so that the token
+// can be rotated without redeploying the components.
+package gittoken
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// TokenEnvVar is the environment variable of the git token when it is not fetched from Vault
+	TokenEnvVar = "GIT_TOKEN"
+	// VaultPathEnvVar is the environment variable of the path of the Vault secret containing the git token,
+	// e.g. secret/data/lighthouse/git for a KV version 2 secrets engine mounted at secret. The token is
+	// read from the environment if it is not set.
+	VaultPathEnvVar = "GIT_TOKEN_VAULT_PATH"
+	// VaultKeyEnvVar is the environment variable of the key of the git token in the Vault secret, token by default
+	VaultKeyEnvVar = "GIT_TOKEN_VAULT_KEY"
+	// VaultRoleEnvVar is the environment variable of the Vault role to log in with the Kubernetes auth method
+	// as, used when there is no VAULT_TOKEN
+	VaultRoleEnvVar = "GIT_TOKEN_VAULT_ROLE"
+	// VaultAuthPathEnvVar is the environment variable of the mount path of the Kubernetes auth method,
+	// kubernetes by default
+	VaultAuthPathEnvVar = "GIT_TOKEN_VAULT_AUTH_PATH"
+	// RefreshIntervalEnvVar is the environment variable of how long the token fetched from Vault is used
+	// before being fetched again, e.g. 5m, unless the secret has a shorter lease
+	RefreshIntervalEnvVar = "GIT_TOKEN_REFRESH_INTERVAL"
+
+	// defaultRefreshInterval is how long the token fetched from Vault is used by default
+	defaultRefreshInterval = 5 * time.Minute
+)
+
+// Provider provides the git token
+type Provider interface {
+	// Token returns the current git token
+	Token() (string, error)
+}
+
+// EnvProvider reads the git token from an environment variable
+type EnvProvider struct {
+	// EnvVar is the environment variable of the token
+	EnvVar string
+}
+
+// Token returns the value of the environment variable, failing if it is empty
+func (p *EnvProvider) Token() (string, error) {
+	value := os.Getenv(p.EnvVar)
+	if value == "" {
+		return value, fmt.Errorf("no token available at environment variable $%s", p.EnvVar)
+	}
+	return value, nil
+}
+
+var (
+	defaultProvider Provider
+	defaultOnce     sync.Once
+)
+
+// Default returns the provider configured by the environment, which is shared by the process so that the
+// token fetched from Vault is cached. The token is fetched from Vault if $GIT_TOKEN_VAULT_PATH is set,
+// otherwise it is read from $GIT_TOKEN.
+func Default() Provider {
+	defaultOnce.Do(func() {
+		defaultProvider = NewProviderFromEnv()
+	})
+	return defaultProvider
+}
+
+// NewProviderFromEnv creates the provider configured by the environment
+func NewProviderFromEnv() Provider {
+	path := os.Getenv(VaultPathEnvVar)
+	if path == "" {
+		return &EnvProvider{EnvVar: TokenEnvVar}
+	}
+	refresh := defaultRefreshInterval
+	if value := os.Getenv(RefreshIntervalEnvVar); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			logrus.WithError(err).Warnf("invalid $%s %q, refreshing the git token every %s", RefreshIntervalEnvVar, value, defaultRefreshInterval)
+		} else {
+			refresh = d
+		}
+	}
+	return NewVaultProvider(VaultOptions{
+		Address:         os.Getenv("VAULT_ADDR"),
+		Token:           os.Getenv("VAULT_TOKEN"),
+		Namespace:       os.Getenv("VAULT_NAMESPACE"),
+		Role:            os.Getenv(VaultRoleEnvVar),
+		AuthPath:        os.Getenv(VaultAuthPathEnvVar),
+		Path:            path,
+		Key:             os.Getenv(VaultKeyEnvVar),
+		RefreshInterval: refresh,
+	})
+}
@@ -0,0 +1,214 @@
+package gittoken
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultVaultKey is the key of the git token in the Vault secret by default
+	defaultVaultKey = "token"
+	// defaultVaultAuthPath is the mount path of the Kubernetes auth method by default
+	defaultVaultAuthPath = "kubernetes"
+	// serviceAccountTokenPath is the token of the service account of the pod, which the Kubernetes auth
+	// method logs in with
+	serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token" // #nosec
+)
+
+// errVaultForbidden is returned when Vault rejects the Vault token, which is renewed with the Kubernetes auth method
+var errVaultForbidden = errors.New("permission denied by Vault")
+
+// VaultOptions configure the fetching of the git token from Vault
+type VaultOptions struct {
+	// Address is the address of Vault, e.g. https://vault.example.com:8200
+	Address string
+	// Token is the Vault token. If empty, the provider logs in with the Kubernetes auth method as the Role.
+	Token string
+	// Namespace is the Vault Enterprise namespace, if any
+	Namespace string
+	// Role is the Vault role of the Kubernetes auth method
+	Role string
+	// AuthPath is the mount path of the Kubernetes auth method, kubernetes if empty
+	AuthPath string
+	// Path is the path of the secret, e.g. secret/data/lighthouse/git. Both the KV version 1 and 2 secrets
+	// engines are supported.
+	Path string
+	// Key is the key of the git token in the secret, token if empty
+	Key string
+	// RefreshInterval is how long the token is used before being fetched again, unless the secret has a
+	// shorter lease
+	RefreshInterval time.Duration
+}
+
+// VaultProvider fetches the git token from a Vault secret and caches it until it has to be refreshed.
+// The cached token keeps being used if it cannot be refreshed, so that an unavailable Vault does not
+// stop the components.
+type VaultProvider struct {
+	opts        VaultOptions
+	client      *http.Client
+	now         func() time.Time
+	readJWTFile func(string) ([]byte, error)
+
+	lock       sync.Mutex
+	vaultToken string
+	token      string
+	expires    time.Time
+}
+
+// NewVaultProvider creates a provider fetching the git token from Vault
+func NewVaultProvider(opts VaultOptions) *VaultProvider {
+	if opts.Key == "" {
+		opts.Key = defaultVaultKey
+	}
+	if opts.AuthPath == "" {
+		opts.AuthPath = defaultVaultAuthPath
+	}
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = defaultRefreshInterval
+	}
+	return &VaultProvider{
+		opts:        opts,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+		readJWTFile: ioutil.ReadFile,
+		vaultToken:  opts.Token,
+	}
+}
+
+// Token returns the cached git token, fetching it from Vault when it has to be refreshed
+func (p *VaultProvider) Token() (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := p.now()
+	if p.token != "" && now.Before(p.expires) {
+		return p.token, nil
+	}
+	token, lease, err := p.fetch()
+	if err != nil {
+		if p.token != "" {
+			logrus.WithError(err).WithField("path", p.opts.Path).Warn("failed to refresh the git token from Vault, using the previous token")
+			return p.token, nil
+		}
+		return "", errors.Wrapf(err, "failed to fetch the git token from Vault secret %s", p.opts.Path)
+	}
+	refresh := p.opts.RefreshInterval
+	if lease > 0 && lease < refresh {
+		refresh = lease
+	}
+	if token != p.token && p.token != "" {
+		logrus.WithField("path", p.opts.Path).Info("the git token was rotated in Vault")
+	}
+	p.token = token
+	p.expires = now.Add(refresh)
+	return token, nil
+}
+
+// vaultResponse is the response of the Vault API to the reads of secrets and the logins
+type vaultResponse struct {
+	Data          map[string]interface{} `json:"data"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Auth          *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// fetch reads the git token and the lease of the secret from Vault, logging in again if the Vault token
+// is missing or rejected
+func (p *VaultProvider) fetch() (string, time.Duration, error) {
+	if p.vaultToken == "" {
+		if err := p.login(); err != nil {
+			return "", 0, err
+		}
+	}
+	resp, err := p.readSecret()
+	if errors.Cause(err) == errVaultForbidden && p.opts.Role != "" {
+		if err := p.login(); err != nil {
+			return "", 0, err
+		}
+		resp, err = p.readSecret()
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	data := resp.Data
+	// the KV version 2 secrets engine nests the secret in the data along with its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	token, _ := data[p.opts.Key].(string)
+	if token == "" {
+		return "", 0, errors.Errorf("the secret has no %s key", p.opts.Key)
+	}
+	return token, time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+func (p *VaultProvider) readSecret() (*vaultResponse, error) {
+	return p.do(http.MethodGet, "/v1/"+strings.TrimPrefix(p.opts.Path, "/"), nil)
+}
+
+// login logs in to Vault with the Kubernetes auth method as the role, with the token of the service account
+func (p *VaultProvider) login() error {
+	if p.opts.Role == "" {
+		return errors.Errorf("neither a Vault token nor a role of the Kubernetes auth method is configured")
+	}
+	jwt, err := p.readJWTFile(serviceAccountTokenPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the service account token")
+	}
+	body, err := json.Marshal(map[string]string{"role": p.opts.Role, "jwt": strings.TrimSpace(string(jwt))})
+	if err != nil {
+		return err
+	}
+	p.vaultToken = ""
+	resp, err := p.do(http.MethodPost, fmt.Sprintf("/v1/auth/%s/login", strings.Trim(p.opts.AuthPath, "/")), body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to log in to Vault as role %s", p.opts.Role)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return errors.Errorf("no Vault token returned by the login as role %s", p.opts.Role)
+	}
+	p.vaultToken = resp.Auth.ClientToken
+	return nil
+}
+
+// do calls the Vault API
+func (p *VaultProvider) do(method, path string, body []byte) (*vaultResponse, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(p.opts.Address, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if p.vaultToken != "" {
+		req.Header.Set("X-Vault-Token", p.vaultToken)
+	}
+	if p.opts.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.opts.Namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	result := &vaultResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, errors.Wrap(err, "failed to parse the response of Vault")
+	}
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, errors.Wrap(errVaultForbidden, strings.Join(result.Errors, ", "))
+	case resp.StatusCode != http.StatusOK:
+		return nil, errors.Errorf("Vault returned status %d: %s", resp.StatusCode, strings.Join(result.Errors, ", "))
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package gittoken
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProvider(t *testing.T) {
+	gitToken := "token-1"
+	vaultToken := "initial"
+	logins := 0
+	reads := 0
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case !available:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/v1/auth/k8s/login":
+			body := map[string]string{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "lighthouse", body["role"])
+			assert.Equal(t, "jwt", body["jwt"])
+			logins++
+			vaultToken = "renewed"
+			_, _ = w.Write([]byte(`{"auth": {"client_token": "renewed", "lease_duration": 3600}}`))
+		case r.URL.Path == "/v1/secret/data/lighthouse/git":
+			if r.Header.Get("X-Vault-Token") != vaultToken {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+				return
+			}
+			reads++
+			_, _ = w.Write([]byte(`{"data": {"data": {"token": "` + gitToken + `"}, "metadata": {"version": 1}}, "lease_duration": 0}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	p := NewVaultProvider(VaultOptions{
+		Address:         server.URL,
+		Token:           "initial",
+		Role:            "lighthouse",
+		AuthPath:        "k8s",
+		Path:            "secret/data/lighthouse/git",
+		RefreshInterval: time.Minute,
+	})
+	p.now = func() time.Time { return now }
+	p.readJWTFile = func(string) ([]byte, error) { return []byte("jwt\n"), nil }
+
+	token, err := p.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, 0, logins, "the configured Vault token is used")
+
+	gitToken = "token-2"
+	now = now.Add(30 * time.Second)
+	token, err = p.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token, "the token is cached until it is refreshed")
+	assert.Equal(t, 1, reads)
+
+	vaultToken = "expired"
+	now = now.Add(time.Minute)
+	token, err = p.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token, "the rotated token is fetched once the refresh interval elapsed")
+	assert.Equal(t, 1, logins, "the rejected Vault token is renewed with the Kubernetes auth method")
+
+	available = false
+	now = now.Add(time.Hour)
+	token, err = p.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token, "the previous token is used while Vault is unavailable")
+
+	p = NewVaultProvider(VaultOptions{Address: server.URL, Path: "secret/data/lighthouse/git"})
+	_, err = p.Token()
+	assert.Error(t, err, "there is no token to fall back on")
+}
+
+func TestNewProviderFromEnv(t *testing.T) {
+	for _, name := range []string{TokenEnvVar, VaultPathEnvVar} {
+		value, ok := os.LookupEnv(name)
+		if ok {
+			defer os.Setenv(name, value) // #nosec
+		} else {
+			defer os.Unsetenv(name) // #nosec
+		}
+	}
+
+	os.Unsetenv(VaultPathEnvVar)  // #nosec
+	os.Setenv(TokenEnvVar, "abc") // #nosec
+	p := NewProviderFromEnv()
+	token, err := p.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token)
+	os.Unsetenv(TokenEnvVar) // #nosec
+	_, err = p.Token()
+	assert.Error(t, err)
+
+	os.Setenv(VaultPathEnvVar, "secret/git") // #nosec
+	assert.IsType(t, &VaultProvider{}, NewProviderFromEnv())
+}
@@ -0,0 +1,245 @@
+// Package identity maps the logins of the git provider to the identities of an internal directory,
+// such as LDAP or an SSO provider, so that enterprises can require the users trusted to run jobs,
+// approve changes or be assigned to be active employees, and route notifications to their
+// corporate handles.
+package identity
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// MappingFileEnvVar is the environment variable containing the path of a YAML file listing the
+	// identities, e.g. exported from the directory
+	MappingFileEnvVar = "LIGHTHOUSE_IDENTITY_MAPPING_FILE"
+	// MappingURLEnvVar is the environment variable containing the URL of a service looking up the
+	// identities in the directory. The login is given as the login query parameter.
+	MappingURLEnvVar = "LIGHTHOUSE_IDENTITY_MAPPING_URL"
+	// MappingCacheTTLEnvVar is the environment variable containing how long the identities looked
+	// up by URL are cached, e.g. 10m. Defaults to 5m.
+	MappingCacheTTLEnvVar = "LIGHTHOUSE_IDENTITY_MAPPING_CACHE_TTL"
+
+	defaultCacheTTL = 5 * time.Minute
+)
+
+// Identity is the identity of a login of the git provider in the internal directory
+type Identity struct {
+	Login string `json:"login"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	// Handle is the corporate handle notifications about the user are routed to, e.g. a chat handle
+	Handle string `json:"handle,omitempty"`
+	// Active is false if the user is no longer an employee
+	Active bool `json:"active"`
+}
+
+// Mapper maps the logins of the git provider to the identities of the internal directory. It is
+// implemented by the file and URL mappers, other directories can be plugged in by implementing it.
+type Mapper interface {
+	// Lookup returns the identity of the login, or nil if the login is not in the directory
+	Lookup(login string) (*Identity, error)
+}
+
+// NewMapperFromEnv creates the mapper configured by the environment variables. It returns nil if
+// none is configured, which disables the identity checks.
+func NewMapperFromEnv() (Mapper, error) {
+	if path := os.Getenv(MappingFileEnvVar); path != "" {
+		m, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	if u := os.Getenv(MappingURLEnvVar); u != "" {
+		ttl := defaultCacheTTL
+		if value := os.Getenv(MappingCacheTTLEnvVar); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid $%s", MappingCacheTTLEnvVar)
+			}
+			ttl = d
+		}
+		m, err := NewURLMapper(u, ttl)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, nil
+}
+
+// IsActive returns true if the login maps to an active identity, or if there is no mapper. Logins
+// which cannot be looked up are considered inactive.
+func IsActive(m Mapper, login string) bool {
+	if m == nil {
+		return true
+	}
+	identity, err := m.Lookup(login)
+	if err != nil {
+		logrus.WithError(err).WithField("login", login).Warn("failed to look up the identity, considering it inactive")
+		return false
+	}
+	return identity != nil && identity.Active
+}
+
+// Handle returns the corporate handle of the login, or an empty string if it has none
+func Handle(m Mapper, login string) string {
+	if m == nil || login == "" {
+		return ""
+	}
+	identity, err := m.Lookup(login)
+	if err != nil {
+		logrus.WithError(err).WithField("login", login).Warn("failed to look up the identity")
+		return ""
+	}
+	if identity == nil {
+		return ""
+	}
+	return identity.Handle
+}
+
+// Inactive returns the logins which do not map to active identities
+func Inactive(m Mapper, logins []string) []string {
+	var answer []string
+	for _, login := range logins {
+		if !IsActive(m, login) {
+			answer = append(answer, login)
+		}
+	}
+	return answer
+}
+
+// FileMapper maps the logins to the identities listed in a file
+type FileMapper struct {
+	identities map[string]*Identity
+}
+
+// fileMapping is the format of the identity mapping files
+type fileMapping struct {
+	Identities []Identity `json:"identities"`
+}
+
+// LoadFile loads the identities listed in the YAML file, e.g.
+//
+//	identities:
+//	- login: octocat
+//	  email: octo.cat@example.com
+//	  handle: "@octo.cat"
+//	  active: true
+func LoadFile(path string) (*FileMapper, error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the identity mapping %s", path)
+	}
+	mapping := fileMapping{}
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the identity mapping %s", path)
+	}
+	return NewFileMapper(mapping.Identities...)
+}
+
+// NewFileMapper creates a mapper of the given identities
+func NewFileMapper(identities ...Identity) (*FileMapper, error) {
+	m := &FileMapper{identities: map[string]*Identity{}}
+	for i := range identities {
+		identity := identities[i]
+		if identity.Login == "" {
+			return nil, errors.Errorf("identity %d has no login", i)
+		}
+		key := scmprovider.NormLogin(identity.Login)
+		if _, ok := m.identities[key]; ok {
+			return nil, errors.Errorf("duplicate identity for login %s", identity.Login)
+		}
+		m.identities[key] = &identity
+	}
+	return m, nil
+}
+
+// Lookup returns the identity of the login
+func (m *FileMapper) Lookup(login string) (*Identity, error) {
+	return m.identities[scmprovider.NormLogin(login)], nil
+}
+
+// URLMapper looks up the identities with a service of the directory, e.g. a small service in front of
+// LDAP. The service returns the identity as JSON, or 404 if the login is not in the directory.
+type URLMapper struct {
+	url    *url.URL
+	ttl    time.Duration
+	client *http.Client
+	now    func() time.Time
+
+	lock  sync.Mutex
+	cache map[string]cachedIdentity
+}
+
+type cachedIdentity struct {
+	identity *Identity
+	expires  time.Time
+}
+
+// NewURLMapper creates a mapper looking up the identities with the service at the URL, caching
+// them for the given duration
+func NewURLMapper(rawURL string, ttl time.Duration) (*URLMapper, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid identity mapping URL %s", rawURL)
+	}
+	return &URLMapper{
+		url:    u,
+		ttl:    ttl,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+		cache:  map[string]cachedIdentity{},
+	}, nil
+}
+
+// Lookup returns the identity of the login, from the cache if it was looked up recently
+func (m *URLMapper) Lookup(login string) (*Identity, error) {
+	key := scmprovider.NormLogin(login)
+	m.lock.Lock()
+	cached, ok := m.cache[key]
+	m.lock.Unlock()
+	if ok && m.now().Before(cached.expires) {
+		return cached.identity, nil
+	}
+
+	u := *m.url
+	query := u.Query()
+	query.Set("login", key)
+	u.RawQuery = query.Encode()
+	resp, err := m.client.Get(u.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to look up the identity of %s", login)
+	}
+	defer resp.Body.Close()
+	var identity *Identity
+	switch resp.StatusCode {
+	case http.StatusOK:
+		identity = &Identity{}
+		if err := json.NewDecoder(resp.Body).Decode(identity); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the identity of %s", login)
+		}
+		if identity.Login == "" {
+			identity.Login = login
+		}
+	case http.StatusNotFound:
+	default:
+		return nil, errors.Errorf("looking up the identity of %s returned status %d", login, resp.StatusCode)
+	}
+
+	m.lock.Lock()
+	m.cache[key] = cachedIdentity{identity: identity, expires: m.now().Add(m.ttl)}
+	m.lock.Unlock()
+	return identity, nil
+}
@@ -0,0 +1,118 @@
+package identity
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "identity")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "identities.yaml")
+	err = ioutil.WriteFile(path, []byte(`identities:
+- login: OctoCat
+  email: octo.cat@example.com
+  handle: "@octo.cat"
+  active: true
+- login: leaver
+`), 0600)
+	require.NoError(t, err)
+	m, err := LoadFile(path)
+	require.NoError(t, err)
+
+	octocat, err := m.Lookup("octocat")
+	require.NoError(t, err)
+	require.NotNil(t, octocat, "the logins are case insensitive")
+	assert.Equal(t, "@octo.cat", octocat.Handle)
+
+	assert.True(t, IsActive(m, "octocat"))
+	assert.False(t, IsActive(m, "leaver"))
+	assert.False(t, IsActive(m, "stranger"))
+	assert.True(t, IsActive(nil, "stranger"), "all logins are active without a mapper")
+	assert.Equal(t, []string{"leaver", "stranger"}, Inactive(m, []string{"octocat", "leaver", "stranger"}))
+	assert.Equal(t, "@octo.cat", Handle(m, "OCTOCAT"))
+	assert.Equal(t, "", Handle(m, "leaver"))
+	assert.Equal(t, "", Handle(nil, "octocat"))
+
+	_, err = NewFileMapper(Identity{Login: "a"}, Identity{Login: "A"})
+	assert.Error(t, err, "duplicate logins are rejected")
+	_, err = NewFileMapper(Identity{Name: "No Login"})
+	assert.Error(t, err, "identities require a login")
+	_, err = LoadFile(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestURLMapper(t *testing.T) {
+	lookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		switch r.URL.Query().Get("login") {
+		case "octocat":
+			_ = json.NewEncoder(w).Encode(Identity{Handle: "@octo.cat", Active: true})
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	m, err := NewURLMapper(server.URL, time.Minute)
+	require.NoError(t, err)
+	now := time.Now()
+	m.now = func() time.Time {
+		return now
+	}
+
+	octocat, err := m.Lookup("OctoCat")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Login: "OctoCat", Handle: "@octo.cat", Active: true}, octocat)
+	stranger, err := m.Lookup("stranger")
+	require.NoError(t, err)
+	assert.Nil(t, stranger)
+	_, err = m.Lookup("broken")
+	assert.Error(t, err)
+	assert.False(t, IsActive(m, "broken"), "the logins which cannot be looked up are inactive")
+	assert.Equal(t, 4, lookups)
+
+	assert.True(t, IsActive(m, "octocat"))
+	assert.False(t, IsActive(m, "stranger"))
+	assert.Equal(t, 4, lookups, "the identities are cached")
+
+	now = now.Add(2 * time.Minute)
+	assert.True(t, IsActive(m, "octocat"))
+	assert.Equal(t, 5, lookups, "the identities are looked up again once expired")
+}
+
+func TestNewMapperFromEnv(t *testing.T) {
+	for _, env := range []string{MappingFileEnvVar, MappingURLEnvVar, MappingCacheTTLEnvVar} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	m, err := NewMapperFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, m, "no mapper is created without configuration")
+
+	os.Setenv(MappingURLEnvVar, "http://identities.example.com/lookup")
+	os.Setenv(MappingCacheTTLEnvVar, "10m")
+	m, err = NewMapperFromEnv()
+	require.NoError(t, err)
+	require.IsType(t, &URLMapper{}, m)
+	assert.Equal(t, 10*time.Minute, m.(*URLMapper).ttl)
+
+	os.Setenv(MappingCacheTTLEnvVar, "forever")
+	m, err = NewMapperFromEnv()
+	assert.Error(t, err)
+	assert.Nil(t, m)
+}
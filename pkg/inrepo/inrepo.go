@@ -0,0 +1,151 @@
+// Package inrepo loads the presubmits and postsubmits which repositories configure themselves in their
+// .lighthouse directory, and merges them with the central configuration.
+package inrepo
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// Dir is the directory of the repositories the job configuration files are read from
+const Dir = ".lighthouse"
+
+// FileBrowser is the subset of the SCM provider client used to read the job configuration files
+type FileBrowser interface {
+	GetFile(owner, repo, filepath, commit string) ([]byte, error)
+	ListFiles(owner, repo, filepath, commit string) ([]*scm.FileEntry, error)
+}
+
+// JobConfig is the content of a job configuration file of the .lighthouse directory
+type JobConfig struct {
+	Presubmits  []config.Presubmit  `json:"presubmits,omitempty"`
+	Postsubmits []config.Postsubmit `json:"postsubmits,omitempty"`
+}
+
+// Load reads the jobs of the *.yaml and *.yml files of the .lighthouse directory of the repository at
+// the given ref. There are no jobs if the repository has no .lighthouse directory.
+func Load(fb FileBrowser, owner, repo, ref string) (*JobConfig, error) {
+	answer := &JobConfig{}
+	files, err := fb.ListFiles(owner, repo, Dir, ref)
+	if err != nil {
+		if err.Error() == scm.ErrNotFound.Error() {
+			return answer, nil
+		}
+		return nil, errors.Wrapf(err, "failed to list the files of %s", Dir)
+	}
+	var names []string
+	for _, f := range files {
+		if f == nil || f.Type == "dir" {
+			continue
+		}
+		if strings.HasSuffix(f.Name, ".yaml") || strings.HasSuffix(f.Name, ".yml") {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filepath := path.Join(Dir, name)
+		data, err := fb.GetFile(owner, repo, filepath, ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", filepath)
+		}
+		jobs := JobConfig{}
+		if err := yaml.Unmarshal(data, &jobs); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", filepath)
+		}
+		answer.Presubmits = append(answer.Presubmits, jobs.Presubmits...)
+		answer.Postsubmits = append(answer.Postsubmits, jobs.Postsubmits...)
+	}
+	return answer, nil
+}
+
+// Merge returns a copy of the configuration with the jobs of the org/repo repository added. The jobs
+// cannot have the name of a job the central configuration already has for the repository or its org,
+// so that repositories cannot override the jobs of the cluster admins.
+func Merge(cfg *config.Config, owner, repo string, jobs *JobConfig) (*config.Config, error) {
+	if jobs == nil || (len(jobs.Presubmits) == 0 && len(jobs.Postsubmits) == 0) {
+		return cfg, nil
+	}
+	fullName := scm.Join(owner, repo)
+	repository := scm.Repository{Namespace: owner, Name: repo, FullName: fullName}
+	merged := *cfg
+
+	names := sets.NewString()
+	for _, ps := range cfg.GetPresubmits(repository) {
+		names.Insert(ps.Name)
+	}
+	for _, ps := range cfg.Presubmits[owner] {
+		names.Insert(ps.Name)
+	}
+	presubmits := map[string][]config.Presubmit{}
+	for k, v := range cfg.Presubmits {
+		presubmits[k] = v
+	}
+	repoPresubmits := append([]config.Presubmit{}, presubmits[fullName]...)
+	for _, ps := range jobs.Presubmits {
+		if err := checkName(names, ps.Name, "presubmit"); err != nil {
+			return nil, err
+		}
+		if ps.Context == "" {
+			ps.Context = ps.Name
+		}
+		if ps.Trigger == "" {
+			ps.Trigger = fmt.Sprintf(`(?m)^/test( all| %s),?(\s+|$)`, ps.Name)
+		}
+		if ps.RerunCommand == "" {
+			ps.RerunCommand = "/test " + ps.Name
+		}
+		repoPresubmits = append(repoPresubmits, ps)
+	}
+	presubmits[fullName] = repoPresubmits
+	if err := merged.SetPresubmits(presubmits); err != nil {
+		return nil, errors.Wrapf(err, "invalid presubmits in %s", Dir)
+	}
+
+	names = sets.NewString()
+	for _, ps := range cfg.GetPostsubmits(repository) {
+		names.Insert(ps.Name)
+	}
+	for _, ps := range cfg.Postsubmits[owner] {
+		names.Insert(ps.Name)
+	}
+	postsubmits := map[string][]config.Postsubmit{}
+	for k, v := range cfg.Postsubmits {
+		postsubmits[k] = v
+	}
+	repoPostsubmits := append([]config.Postsubmit{}, postsubmits[fullName]...)
+	for _, ps := range jobs.Postsubmits {
+		if err := checkName(names, ps.Name, "postsubmit"); err != nil {
+			return nil, err
+		}
+		if ps.Context == "" {
+			ps.Context = ps.Name
+		}
+		repoPostsubmits = append(repoPostsubmits, ps)
+	}
+	postsubmits[fullName] = repoPostsubmits
+	if err := merged.SetPostsubmits(postsubmits); err != nil {
+		return nil, errors.Wrapf(err, "invalid postsubmits in %s", Dir)
+	}
+	return &merged, nil
+}
+
+// checkName checks the job has a name which is not used yet, and records it
+func checkName(names sets.String, name, kind string) error {
+	if name == "" {
+		return errors.Errorf("a %s of %s has no name", kind, Dir)
+	}
+	if names.Has(name) {
+		return errors.Errorf("the %s %s of %s is already configured", kind, name, Dir)
+	}
+	names.Insert(name)
+	return nil
+}
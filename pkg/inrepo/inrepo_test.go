@@ -0,0 +1,84 @@
+package inrepo
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFileBrowser struct {
+	files map[string]string
+}
+
+func (f *fakeFileBrowser) GetFile(owner, repo, filepath, commit string) ([]byte, error) {
+	data, ok := f.files[commit+":"+filepath]
+	if !ok {
+		return nil, scm.ErrNotFound
+	}
+	return []byte(data), nil
+}
+
+func (f *fakeFileBrowser) ListFiles(owner, repo, filepath, commit string) ([]*scm.FileEntry, error) {
+	var answer []*scm.FileEntry
+	for key := range f.files {
+		if dir := commit + ":" + filepath + "/"; len(key) > len(dir) && key[:len(dir)] == dir {
+			answer = append(answer, &scm.FileEntry{Name: key[len(dir):], Type: "file"})
+		}
+	}
+	if len(answer) == 0 {
+		return nil, scm.ErrNotFound
+	}
+	return answer, nil
+}
+
+func TestLoadAndMerge(t *testing.T) {
+	fb := &fakeFileBrowser{files: map[string]string{
+		"abc:.lighthouse/jobs.yaml": `
+presubmits:
+- name: lint
+  always_run: true
+postsubmits:
+- name: release
+`,
+		"abc:.lighthouse/more.yml": `
+presubmits:
+- name: integration
+  context: it
+`,
+		"abc:.lighthouse/README.md": "not a job",
+	}}
+
+	jobs, err := Load(fb, "myorg", "myrepo", "abc")
+	require.NoError(t, err)
+	require.Len(t, jobs.Presubmits, 2)
+	require.Len(t, jobs.Postsubmits, 1)
+
+	cfg := &config.Config{}
+	require.NoError(t, cfg.SetPresubmits(map[string][]config.Presubmit{
+		"myorg/myrepo": {{JobBase: config.JobBase{Name: "unit"}, Trigger: "(?m)^/test unit", RerunCommand: "/test unit"}},
+	}))
+	merged, err := Merge(cfg, "myorg", "myrepo", jobs)
+	require.NoError(t, err)
+
+	presubmits := merged.Presubmits["myorg/myrepo"]
+	require.Len(t, presubmits, 3)
+	assert.Equal(t, "unit", presubmits[0].Name)
+	assert.Equal(t, "lint", presubmits[1].Name)
+	assert.Equal(t, "lint", presubmits[1].Context)
+	assert.Equal(t, "/test lint", presubmits[1].RerunCommand)
+	assert.True(t, presubmits[1].TriggerMatches("/test lint"))
+	assert.Equal(t, "it", presubmits[2].Context)
+	assert.Equal(t, "release", merged.Postsubmits["myorg/myrepo"][0].Name)
+	assert.Len(t, cfg.Presubmits["myorg/myrepo"], 1, "the central configuration is not modified")
+
+	_, err = Merge(cfg, "myorg", "myrepo", &JobConfig{Presubmits: []config.Presubmit{{JobBase: config.JobBase{Name: "unit"}}}})
+	assert.Error(t, err, "the jobs of the central configuration cannot be overridden")
+
+	jobs, err = Load(fb, "myorg", "myrepo", "def")
+	require.NoError(t, err)
+	assert.Empty(t, jobs.Presubmits)
+	assert.Empty(t, jobs.Postsubmits)
+}
@@ -0,0 +1,421 @@
+// Package jobdigest aggregates the results of the postsubmits of each repository over a period, such as
+// a day or a week, into a digest of their pass rate, slowest jobs and recent failures which is posted as
+// an issue comment or sent to a webhook, so that teams notice when their default branches degrade.
+package jobdigest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	clientset "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/lighthouse/pkg/jobexport"
+	"github.com/jenkins-x/lighthouse/pkg/messages"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// FormatJSON posts the digest as JSON
+	FormatJSON = "json"
+	// FormatSlack posts the text of the digest as a Slack incoming webhook message
+	FormatSlack = "slack"
+
+	// digestMessage is the message catalog ID of the digest text
+	digestMessage = "digest.postsubmits"
+
+	// maxSlowest is the number of slowest jobs listed in a digest
+	maxSlowest = 3
+	// maxFailures is the number of recent failures listed in a digest
+	maxFailures = 5
+	// pageSize is the number of LighthouseJobs listed per page
+	pageSize = 100
+
+	defaultDigestText = `**Postsubmits of {{ .Org }}/{{ .Repo }} from {{ .Since.Format "2006-01-02" }} to {{ .Until.Format "2006-01-02" }}**
+
+{{ .Passed }} of {{ .Runs }} runs passed ({{ .PassRate }}).
+{{- if .Slowest }}
+
+Slowest jobs:
+{{- range .Slowest }}
+- {{ .Job }}: {{ .AverageDuration }} on average, {{ .MaxDuration }} at most
+{{- end }}
+{{- end }}
+{{- if .RecentFailures }}
+
+Recent failures:
+{{- range .RecentFailures }}
+- {{ .Job }} at {{ .SHA }} {{ .State }} on {{ .Completed.Format "2006-01-02 15:04" }}{{ if .URL }} {{ .URL }}{{ end }}
+{{- end }}
+{{- end }}`
+)
+
+// Source lists the history of the jobs, e.g. the LighthouseJobs which are not garbage collected yet
+type Source interface {
+	// Summaries returns the summaries of the jobs completed in the window
+	Summaries(since, until time.Time) ([]jobexport.Summary, error)
+}
+
+// JobStats are the statistics of the runs of a postsubmit
+type JobStats struct {
+	Job             string `json:"job"`
+	Runs            int    `json:"runs"`
+	Passed          int    `json:"passed"`
+	Failed          int    `json:"failed"`
+	AverageDuration string `json:"averageDuration"`
+	MaxDuration     string `json:"maxDuration"`
+
+	total time.Duration
+	max   time.Duration
+}
+
+// Failure is a failed run of a postsubmit
+type Failure struct {
+	Job       string    `json:"job"`
+	SHA       string    `json:"sha"`
+	State     string    `json:"state"`
+	URL       string    `json:"url,omitempty"`
+	Completed time.Time `json:"completed"`
+}
+
+// Digest summarises the results of the postsubmits of a repository over a period
+type Digest struct {
+	Org      string    `json:"org"`
+	Repo     string    `json:"repo"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Runs     int       `json:"runs"`
+	Passed   int       `json:"passed"`
+	Failed   int       `json:"failed"`
+	PassRate string    `json:"passRate"`
+	// Jobs are the statistics of every postsubmit, sorted by name
+	Jobs []JobStats `json:"jobs"`
+	// Slowest are the postsubmits with the longest average duration
+	Slowest []JobStats `json:"slowest,omitempty"`
+	// RecentFailures are the latest failed runs, most recent first
+	RecentFailures []Failure `json:"recentFailures,omitempty"`
+	Text           string    `json:"text"`
+}
+
+// Build aggregates the completed postsubmits of the summaries into a digest per repository, sorted by
+// repository. The aborted and unfinished runs are ignored.
+func Build(summaries []jobexport.Summary, since, until time.Time) []Digest {
+	type repoKey struct{ org, repo string }
+	digests := map[repoKey]*Digest{}
+	stats := map[repoKey]map[string]*JobStats{}
+	for i := range summaries {
+		s := &summaries[i]
+		refs := s.Spec.Refs
+		completion := s.Status.CompletionTime
+		if s.Spec.Type != config.PostsubmitJob || refs == nil || completion == nil {
+			continue
+		}
+		if completion.Time.Before(since) || completion.Time.After(until) {
+			continue
+		}
+		passed := s.Status.State == v1alpha1.SuccessState
+		if !passed && s.Status.State != v1alpha1.FailureState {
+			continue
+		}
+		key := repoKey{org: refs.Org, repo: refs.Repo}
+		digest := digests[key]
+		if digest == nil {
+			digest = &Digest{Org: refs.Org, Repo: refs.Repo, Since: since, Until: until}
+			digests[key] = digest
+			stats[key] = map[string]*JobStats{}
+		}
+		job := s.Spec.Job
+		js := stats[key][job]
+		if js == nil {
+			js = &JobStats{Job: job}
+			stats[key][job] = js
+		}
+		duration := completion.Time.Sub(s.Status.StartTime.Time)
+		js.Runs++
+		js.total += duration
+		if duration > js.max {
+			js.max = duration
+		}
+		digest.Runs++
+		if passed {
+			js.Passed++
+			digest.Passed++
+			continue
+		}
+		js.Failed++
+		digest.Failed++
+		digest.RecentFailures = append(digest.RecentFailures, Failure{
+			Job:       job,
+			SHA:       refs.BaseSHA,
+			State:     string(s.Status.State),
+			URL:       s.Status.ReportURL,
+			Completed: completion.Time,
+		})
+	}
+
+	var answer []Digest
+	for key, digest := range digests {
+		for _, js := range stats[key] {
+			js.AverageDuration = (js.total / time.Duration(js.Runs)).Round(time.Second).String()
+			js.MaxDuration = js.max.Round(time.Second).String()
+			digest.Jobs = append(digest.Jobs, *js)
+		}
+		sort.Slice(digest.Jobs, func(i, j int) bool {
+			return digest.Jobs[i].Job < digest.Jobs[j].Job
+		})
+		digest.Slowest = append([]JobStats(nil), digest.Jobs...)
+		sort.SliceStable(digest.Slowest, func(i, j int) bool {
+			a, b := digest.Slowest[i], digest.Slowest[j]
+			return a.total/time.Duration(a.Runs) > b.total/time.Duration(b.Runs)
+		})
+		if len(digest.Slowest) > maxSlowest {
+			digest.Slowest = digest.Slowest[:maxSlowest]
+		}
+		sort.SliceStable(digest.RecentFailures, func(i, j int) bool {
+			return digest.RecentFailures[i].Completed.After(digest.RecentFailures[j].Completed)
+		})
+		if len(digest.RecentFailures) > maxFailures {
+			digest.RecentFailures = digest.RecentFailures[:maxFailures]
+		}
+		digest.PassRate = fmt.Sprintf("%.1f%%", 100*float64(digest.Passed)/float64(digest.Runs))
+		digest.Text = messages.Render(digestMessage, defaultDigestText, digest)
+		answer = append(answer, *digest)
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		if answer[i].Org != answer[j].Org {
+			return answer[i].Org < answer[j].Org
+		}
+		return answer[i].Repo < answer[j].Repo
+	})
+	return answer
+}
+
+// clusterSource lists the LighthouseJobs of the cluster
+type clusterSource struct {
+	lhClient  clientset.Interface
+	namespace string
+}
+
+// NewClusterSource creates a Source listing the LighthouseJobs of the namespace, which hold the history
+// of the jobs until they are garbage collected
+func NewClusterSource(lhClient clientset.Interface, namespace string) Source {
+	return &clusterSource{lhClient: lhClient, namespace: namespace}
+}
+
+// Summaries returns the summaries of the LighthouseJobs completed in the window
+func (s *clusterSource) Summaries(since, until time.Time) ([]jobexport.Summary, error) {
+	var answer []jobexport.Summary
+	listOptions := metav1.ListOptions{Limit: pageSize}
+	for {
+		list, err := s.lhClient.LighthouseV1alpha1().LighthouseJobs(s.namespace).List(listOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to list LighthouseJobs")
+		}
+		for i := range list.Items {
+			job := &list.Items[i]
+			completion := job.Status.CompletionTime
+			if completion == nil || completion.Time.Before(since) || completion.Time.After(until) {
+				continue
+			}
+			answer = append(answer, jobexport.Summary{
+				Name:        job.Name,
+				Namespace:   job.Namespace,
+				Labels:      job.Labels,
+				Annotations: job.Annotations,
+				Spec:        job.Spec,
+				Status:      job.Status,
+			})
+		}
+		if list.Continue == "" {
+			return answer, nil
+		}
+		listOptions.Continue = list.Continue
+	}
+}
+
+// exportSource reads the summaries exported by the gc-jobs CronJob before it deleted the LighthouseJobs
+type exportSource struct {
+	store jobexport.Store
+	repos []string
+}
+
+// NewExportSource creates a Source reading the summaries exported to the store, which hold the history
+// of the jobs once they are garbage collected. Only the summaries of the given repositories are read,
+// or of every repository if there are none.
+func NewExportSource(store jobexport.Store, repos []string) Source {
+	return &exportSource{store: store, repos: repos}
+}
+
+// Summaries returns the exported summaries of the jobs completed in the window
+func (s *exportSource) Summaries(since, until time.Time) ([]jobexport.Summary, error) {
+	return jobexport.ReadSummaries(s.store, s.repos, since, until)
+}
+
+// sources merges the summaries of several sources
+type sources []Source
+
+// NewSources creates a Source merging the summaries of the given sources, such as the LighthouseJobs
+// of the cluster and the summaries exported once they are garbage collected. The jobs of the same name
+// are only returned once, from the first source.
+func NewSources(s ...Source) Source {
+	return sources(s)
+}
+
+// Summaries returns the summaries of the jobs completed in the window of every source
+func (s sources) Summaries(since, until time.Time) ([]jobexport.Summary, error) {
+	var answer []jobexport.Summary
+	names := map[string]bool{}
+	for _, source := range s {
+		summaries, err := source.Summaries(since, until)
+		if err != nil {
+			return nil, err
+		}
+		for _, summary := range summaries {
+			if !names[summary.Name] {
+				names[summary.Name] = true
+				answer = append(answer, summary)
+			}
+		}
+	}
+	return answer, nil
+}
+
+// Commenter is the subset of the SCM client posting the digests as issue comments
+type Commenter interface {
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+}
+
+// Options select the digests generated and where they are published
+type Options struct {
+	// Period is the duration covered by the digest, up to now, e.g. 24h for a daily digest
+	Period time.Duration
+	// Repos limits the digests to these repositories, in org/repo format. All repositories are
+	// included if empty.
+	Repos []string
+	// Issues are the issues the digests of the repositories are posted to as comments, keyed by
+	// repository in org/repo format
+	Issues map[string]int
+	// WebhookURL is the URL the digests are posted to, in Format
+	WebhookURL string
+	Format     string
+	// DryRun only logs the digests
+	DryRun bool
+}
+
+// Digester generates the digests of the postsubmits from the job history and publishes them
+type Digester struct {
+	source     Source
+	commenters func(owner string) (Commenter, error)
+	client     *http.Client
+	now        func() time.Time
+	logger     *logrus.Entry
+}
+
+// NewDigester creates a Digester reading the job history from the source and commenting with the SCM
+// clients created by the given function
+func NewDigester(source Source, commenters func(owner string) (Commenter, error)) *Digester {
+	return &Digester{
+		source:     source,
+		commenters: commenters,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+		logger:     logrus.WithField("controller", "digest"),
+	}
+}
+
+// Run generates the digests of the period and publishes them. Failing to publish a digest is logged so
+// that the other digests are still published, and reported once all digests were published.
+func (d *Digester) Run(opts Options) ([]Digest, error) {
+	if opts.Period <= 0 {
+		return nil, errors.New("the digest requires a period")
+	}
+	if opts.Format == "" {
+		opts.Format = FormatJSON
+	}
+	if opts.Format != FormatJSON && opts.Format != FormatSlack {
+		return nil, errors.Errorf("unsupported digest format %q, must be %s or %s", opts.Format, FormatJSON, FormatSlack)
+	}
+	until := d.now()
+	since := until.Add(-opts.Period)
+	summaries, err := d.source.Summaries(since, until)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the job history")
+	}
+	repos := map[string]bool{}
+	for _, repo := range opts.Repos {
+		repos[strings.ToLower(repo)] = true
+	}
+	issues := map[string]int{}
+	for repo, number := range opts.Issues {
+		issues[strings.ToLower(repo)] = number
+	}
+
+	var answer []Digest
+	failed := 0
+	for _, digest := range Build(summaries, since, until) {
+		fullName := strings.ToLower(digest.Org + "/" + digest.Repo)
+		if len(repos) > 0 && !repos[fullName] {
+			continue
+		}
+		answer = append(answer, digest)
+		l := d.logger.WithFields(logrus.Fields{"org": digest.Org, "repo": digest.Repo, "runs": digest.Runs, "passRate": digest.PassRate})
+		if opts.DryRun {
+			l.Infof("would publish the digest:\n%s", digest.Text)
+			continue
+		}
+		if number := issues[fullName]; number > 0 {
+			if err := d.comment(digest, number); err != nil {
+				l.WithError(err).Warnf("failed to comment the digest on issue %d", number)
+				failed++
+			}
+		}
+		if opts.WebhookURL != "" {
+			if err := d.post(digest, opts.WebhookURL, opts.Format); err != nil {
+				l.WithError(err).Warn("failed to post the digest to the webhook")
+				failed++
+			}
+		}
+		l.Info("published the digest")
+	}
+	if failed > 0 {
+		return answer, errors.Errorf("failed to publish %d digests", failed)
+	}
+	return answer, nil
+}
+
+// comment posts the digest as a comment of the issue
+func (d *Digester) comment(digest Digest, number int) error {
+	c, err := d.commenters(digest.Org)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the SCM client of %s", digest.Org)
+	}
+	return c.CreateComment(digest.Org, digest.Repo, number, false, digest.Text)
+}
+
+// post posts the digest to the webhook
+func (d *Digester) post(digest Digest, url, format string) error {
+	var payload interface{} = digest
+	if format == FormatSlack {
+		payload = map[string]string{"text": digest.Text}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal digest")
+	}
+	resp, err := d.client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to post digest")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("digest webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package jobdigest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	lhfake "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/lighthouse/pkg/jobexport"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var now = time.Date(2020, 6, 8, 9, 0, 0, 0, time.UTC)
+
+func digestJob(name, repo, job string, jobType config.PipelineKind, state v1alpha1.PipelineState, completion time.Time, duration time.Duration) *v1alpha1.LighthouseJob {
+	completed := metav1.NewTime(completion)
+	return &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "jx"},
+		Spec: v1alpha1.LighthouseJobSpec{
+			Type: jobType,
+			Job:  job,
+			Refs: &v1alpha1.Refs{Org: "org", Repo: repo, BaseRef: "master", BaseSHA: name + "-sha"},
+		},
+		Status: v1alpha1.LighthouseJobStatus{
+			State:          state,
+			StartTime:      metav1.NewTime(completion.Add(-duration)),
+			CompletionTime: &completed,
+			ReportURL:      "https://dashboard/" + name,
+		},
+	}
+}
+
+type fakeCommenter struct {
+	comments map[string]string
+}
+
+func (c *fakeCommenter) CreateComment(owner, repo string, number int, pr bool, comment string) error {
+	c.comments[owner+"/"+repo] = comment
+	return nil
+}
+
+func TestDigest(t *testing.T) {
+	day := now.Add(-12 * time.Hour)
+	lhClient := lhfake.NewSimpleClientset(
+		digestJob("build-1", "repo", "build", config.PostsubmitJob, v1alpha1.SuccessState, day, 10*time.Minute),
+		digestJob("build-2", "repo", "build", config.PostsubmitJob, v1alpha1.FailureState, day.Add(time.Hour), 20*time.Minute),
+		digestJob("lint-1", "repo", "lint", config.PostsubmitJob, v1alpha1.SuccessState, day, time.Minute),
+		digestJob("lint-2", "repo", "lint", config.PostsubmitJob, v1alpha1.AbortedState, day, time.Minute),
+		digestJob("unit-1", "repo", "unit", config.PresubmitJob, v1alpha1.FailureState, day, time.Minute),
+		digestJob("old-1", "repo", "build", config.PostsubmitJob, v1alpha1.FailureState, now.Add(-48*time.Hour), time.Minute),
+		digestJob("other-1", "other", "build", config.PostsubmitJob, v1alpha1.SuccessState, day, time.Minute),
+	)
+	commenter := &fakeCommenter{comments: map[string]string{}}
+	var posted []Digest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digest := Digest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&digest))
+		posted = append(posted, digest)
+	}))
+	defer server.Close()
+
+	d := NewDigester(NewClusterSource(lhClient, "jx"), func(owner string) (Commenter, error) {
+		return commenter, nil
+	})
+	d.now = func() time.Time {
+		return now
+	}
+	d.logger = logrus.WithField("test", t.Name())
+
+	_, err := d.Run(Options{})
+	assert.Error(t, err, "the period is required")
+	_, err = d.Run(Options{Period: 24 * time.Hour, Format: "xml"})
+	assert.Error(t, err, "the format must be supported")
+
+	digests, err := d.Run(Options{
+		Period:     24 * time.Hour,
+		Issues:     map[string]int{"Org/Repo": 42},
+		WebhookURL: server.URL,
+	})
+	require.NoError(t, err)
+	require.Len(t, digests, 2)
+	assert.Equal(t, "other", digests[0].Repo)
+
+	digest := digests[1]
+	assert.Equal(t, "repo", digest.Repo)
+	assert.Equal(t, 3, digest.Runs, "presubmits, aborted runs and runs outside the period are ignored")
+	assert.Equal(t, 2, digest.Passed)
+	assert.Equal(t, 1, digest.Failed)
+	assert.Equal(t, "66.7%", digest.PassRate)
+	require.Len(t, digest.Jobs, 2)
+	build := digest.Jobs[0]
+	assert.Equal(t, "build", build.Job)
+	assert.Equal(t, 2, build.Runs)
+	assert.Equal(t, 1, build.Failed)
+	assert.Equal(t, "15m0s", build.AverageDuration)
+	assert.Equal(t, "20m0s", build.MaxDuration)
+	assert.Equal(t, "build", digest.Slowest[0].Job)
+	assert.Equal(t, []Failure{{
+		Job:       "build",
+		SHA:       "build-2-sha",
+		State:     "failure",
+		URL:       "https://dashboard/build-2",
+		Completed: day.Add(time.Hour),
+	}}, digest.RecentFailures)
+	assert.Contains(t, digest.Text, "2 of 3 runs passed (66.7%)")
+	assert.Contains(t, digest.Text, "- build: 15m0s on average, 20m0s at most")
+	assert.Contains(t, digest.Text, "- build at build-2-sha failure on 2020-06-07 22:00 https://dashboard/build-2")
+
+	assert.Equal(t, map[string]string{"org/repo": digest.Text}, commenter.comments, "only the repositories with an issue are commented")
+	assert.Len(t, posted, 2, "every digest is posted to the webhook")
+
+	digests, err = d.Run(Options{Period: 24 * time.Hour, Repos: []string{"org/other"}, DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	assert.Equal(t, "other", digests[0].Repo)
+	assert.Len(t, posted, 2, "dry runs do not publish the digests")
+}
+
+// exportStore is a jobexport.Store of the summaries exported by the gc-jobs CronJob
+type exportStore map[string][]byte
+
+func (s exportStore) List(prefix string) ([]string, error) {
+	var keys []string
+	for key := range s {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s exportStore) Read(key string) ([]byte, error) {
+	return s[key], nil
+}
+
+func TestExportSource(t *testing.T) {
+	day := now.Add(-12 * time.Hour)
+	store := exportStore{}
+	exporter := jobexport.NewExporter(func(key string, data []byte) error {
+		store[key] = data
+		return nil
+	})
+	// build-1 is exported but not garbage collected yet
+	for _, job := range []*v1alpha1.LighthouseJob{
+		digestJob("build-1", "repo", "build", config.PostsubmitJob, v1alpha1.SuccessState, day, time.Minute),
+		digestJob("build-0", "repo", "build", config.PostsubmitJob, v1alpha1.FailureState, day.Add(-time.Hour), time.Minute),
+		digestJob("old-0", "repo", "build", config.PostsubmitJob, v1alpha1.FailureState, now.Add(-48*time.Hour), time.Minute),
+	} {
+		require.NoError(t, exporter.Export(job))
+	}
+	lhClient := lhfake.NewSimpleClientset(
+		digestJob("build-1", "repo", "build", config.PostsubmitJob, v1alpha1.SuccessState, day, time.Minute),
+		digestJob("build-2", "repo", "build", config.PostsubmitJob, v1alpha1.SuccessState, day.Add(time.Hour), time.Minute),
+	)
+
+	d := NewDigester(NewSources(NewClusterSource(lhClient, "jx"), NewExportSource(store, nil)), nil)
+	d.now = func() time.Time {
+		return now
+	}
+	d.logger = logrus.WithField("test", t.Name())
+	digests, err := d.Run(Options{Period: 24 * time.Hour, DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	assert.Equal(t, 3, digests[0].Runs, "the jobs of the cluster and the store should be counted once")
+	assert.Equal(t, 1, digests[0].Failed)
+}
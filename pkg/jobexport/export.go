@@ -0,0 +1,109 @@
+// Package jobexport exports summaries of completed LighthouseJobs to object
+// storage so that CI outcomes can still be analysed after the jobs have been
+// garbage collected from the cluster.
+package jobexport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx/v2/pkg/cloud/buckets"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+// defaultTimeout is the timeout for writing a single job summary
+const defaultTimeout = 30 * time.Second
+
+// Summary is the exported summary of a LighthouseJob
+type Summary struct {
+	Name        string                       `json:"name"`
+	Namespace   string                       `json:"namespace,omitempty"`
+	Labels      map[string]string            `json:"labels,omitempty"`
+	Annotations map[string]string            `json:"annotations,omitempty"`
+	Spec        v1alpha1.LighthouseJobSpec   `json:"spec"`
+	Status      v1alpha1.LighthouseJobStatus `json:"status"`
+}
+
+// Writer writes the data to the given key
+type Writer func(key string, data []byte) error
+
+// Exporter writes job summaries using a Writer
+type Exporter struct {
+	write Writer
+}
+
+// NewExporter creates an Exporter writing summaries with the given Writer
+func NewExporter(write Writer) *Exporter {
+	return &Exporter{write: write}
+}
+
+// NewBucketExporter creates an Exporter writing summaries below the given bucket URL,
+// e.g. gs://my-bucket/lighthouse-jobs or s3://my-bucket/jobs?region=us-east-1.
+// It returns nil if the URL is empty, which disables exporting.
+func NewBucketExporter(bucketURL string) (*Exporter, error) {
+	if bucketURL == "" {
+		return nil, nil
+	}
+	write, err := NewBucketWriter(bucketURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewExporter(write), nil
+}
+
+// NewBucketWriter creates a Writer writing the keys below the given bucket URL, e.g.
+// gs://my-bucket/lighthouse-jobs or s3://my-bucket/jobs?region=us-east-1
+func NewBucketWriter(bucketURL string) (Writer, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid bucket URL %s", bucketURL)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid bucket URL %s, expected a URL like gs://bucket/path", bucketURL)
+	}
+	return func(key string, data []byte) error {
+		target := *u
+		target.Path = path.Join("/", u.Path, key)
+		return buckets.WriteBucketURL(&target, data, defaultTimeout)
+	}, nil
+}
+
+// Export writes the summary of the job
+func (e *Exporter) Export(job *v1alpha1.LighthouseJob) error {
+	data, err := json.Marshal(Summary{
+		Name:        job.Name,
+		Namespace:   job.Namespace,
+		Labels:      job.Labels,
+		Annotations: job.Annotations,
+		Spec:        job.Spec,
+		Status:      job.Status,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal LighthouseJob %s", job.Name)
+	}
+	key := Key(job)
+	if err := e.write(key, data); err != nil {
+		return errors.Wrapf(err, "failed to export LighthouseJob %s to %s", job.Name, key)
+	}
+	return nil
+}
+
+// Key returns the key of the summary of the job, partitioned by the lower case org and
+// repo and the date of completion, e.g. myorg/myrepo/2020/06/01/<job name>.json. Periodic
+// jobs which have no repository are stored below periodic/<job>.
+func Key(job *v1alpha1.LighthouseJob) string {
+	t := job.Status.StartTime.Time
+	if job.Status.CompletionTime != nil {
+		t = job.Status.CompletionTime.Time
+	}
+	partition := []string{"periodic", job.Spec.Job}
+	if refs := job.Spec.Refs; refs != nil && refs.Org != "" {
+		partition = []string{strings.ToLower(refs.Org), strings.ToLower(refs.Repo)}
+	}
+	return path.Join(path.Join(partition...), t.UTC().Format("2006/01/02"), job.Name+".json")
+}
@@ -0,0 +1,115 @@
+package jobexport
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExport(t *testing.T) {
+	completed := metav1.NewTime(time.Date(2020, 6, 1, 23, 30, 0, 0, time.UTC))
+	job := &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Labels: map[string]string{"foo": "bar"}},
+		Spec: v1alpha1.LighthouseJobSpec{
+			Type: config.PresubmitJob,
+			Job:  "lint",
+			Refs: &v1alpha1.Refs{Org: "MyOrg", Repo: "MyRepo"},
+		},
+		Status: v1alpha1.LighthouseJobStatus{
+			State:          v1alpha1.SuccessState,
+			StartTime:      metav1.NewTime(completed.Add(-time.Hour)),
+			CompletionTime: &completed,
+		},
+	}
+
+	written := map[string][]byte{}
+	exporter := NewExporter(func(key string, data []byte) error {
+		written[key] = data
+		return nil
+	})
+	require.NoError(t, exporter.Export(job))
+
+	data, ok := written["myorg/myrepo/2020/06/01/abc.json"]
+	require.True(t, ok, "written keys: %v", written)
+	summary := Summary{}
+	require.NoError(t, json.Unmarshal(data, &summary))
+	assert.Equal(t, "abc", summary.Name)
+	assert.Equal(t, "bar", summary.Labels["foo"])
+	assert.Equal(t, "lint", summary.Spec.Job)
+	assert.Equal(t, v1alpha1.SuccessState, summary.Status.State)
+
+	periodic := &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "def"},
+		Spec:       v1alpha1.LighthouseJobSpec{Type: config.PeriodicJob, Job: "nightly"},
+		Status:     v1alpha1.LighthouseJobStatus{StartTime: completed},
+	}
+	assert.Equal(t, "periodic/nightly/2020/06/01/def.json", Key(periodic))
+}
+
+func TestNewBucketExporter(t *testing.T) {
+	exporter, err := NewBucketExporter("")
+	assert.NoError(t, err)
+	assert.Nil(t, exporter)
+
+	_, err = NewBucketExporter("not-a-bucket")
+	assert.Error(t, err)
+}
+
+// mapStore is a Store of the keys written by an Exporter
+type mapStore map[string][]byte
+
+func (s mapStore) List(prefix string) ([]string, error) {
+	var keys []string
+	for key := range s {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s mapStore) Read(key string) ([]byte, error) {
+	return s[key], nil
+}
+
+func TestReadSummaries(t *testing.T) {
+	since := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := mapStore{}
+	exporter := NewExporter(func(key string, data []byte) error {
+		store[key] = data
+		return nil
+	})
+	for i, completion := range []time.Time{since.Add(-time.Hour), since.Add(time.Hour), since.Add(25 * time.Hour), since.Add(72 * time.Hour)} {
+		for _, repo := range []string{"Repo", "other"} {
+			completed := metav1.NewTime(completion)
+			require.NoError(t, exporter.Export(&v1alpha1.LighthouseJob{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%d", repo, i)},
+				Spec:       v1alpha1.LighthouseJobSpec{Job: "build", Refs: &v1alpha1.Refs{Org: "org", Repo: repo}},
+				Status:     v1alpha1.LighthouseJobStatus{StartTime: completed, CompletionTime: &completed},
+			}))
+		}
+	}
+	until := since.Add(48 * time.Hour)
+
+	summaries, err := ReadSummaries(store, []string{"org/Repo"}, since, until)
+	require.NoError(t, err)
+	var names []string
+	for _, s := range summaries {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"Repo-1", "Repo-2"}, names)
+
+	summaries, err = ReadSummaries(store, nil, since, until)
+	require.NoError(t, err)
+	assert.Len(t, summaries, 4, "every partition should be read")
+}
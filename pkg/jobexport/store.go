@@ -0,0 +1,161 @@
+package jobexport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gocloud.dev/blob"
+
+	// the drivers of the bucket URLs supported by the exporter
+	_ "gocloud.dev/blob/gcsblob"
+	_ "gocloud.dev/blob/s3blob"
+)
+
+// listTimeout is the timeout for listing the keys of a partition
+const listTimeout = 2 * time.Minute
+
+// Store lists and reads the exported summaries
+type Store interface {
+	// List returns the keys starting with the prefix
+	List(prefix string) ([]string, error)
+	// Read returns the data of the key
+	Read(key string) ([]byte, error)
+}
+
+// bucketStore reads the keys below the path of a bucket
+type bucketStore struct {
+	bucketURL string
+	prefix    string
+}
+
+// NewBucketStore creates a Store reading the keys below the given bucket URL, which is the URL the
+// summaries are exported to, e.g. gs://my-bucket/lighthouse-jobs or s3://my-bucket/jobs?region=us-east-1
+func NewBucketStore(bucketURL string) (Store, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid bucket URL %s", bucketURL)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid bucket URL %s, expected a URL like gs://bucket/path", bucketURL)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	u.Path = ""
+	return &bucketStore{bucketURL: u.String(), prefix: prefix}, nil
+}
+
+// List returns the keys starting with the prefix, relative to the path of the bucket URL
+func (s *bucketStore) List(prefix string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+	bucket, err := blob.OpenBucket(ctx, s.bucketURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open the bucket %s", s.bucketURL)
+	}
+	defer bucket.Close()
+
+	var keys []string
+	it := bucket.List(&blob.ListOptions{Prefix: s.key(prefix)})
+	for {
+		obj, err := it.Next(ctx)
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the keys of %s", prefix)
+		}
+		keys = append(keys, strings.TrimPrefix(strings.TrimPrefix(obj.Key, s.prefix), "/"))
+	}
+}
+
+// Read returns the data of the key, relative to the path of the bucket URL
+func (s *bucketStore) Read(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	bucket, err := blob.OpenBucket(ctx, s.bucketURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open the bucket %s", s.bucketURL)
+	}
+	defer bucket.Close()
+	data, err := bucket.ReadAll(ctx, s.key(key))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", key)
+	}
+	return data, nil
+}
+
+// key returns the key of the bucket below the path of the bucket URL
+func (s *bucketStore) key(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+// ReadSummaries reads the summaries of the jobs completed in the window from the store. Only the
+// given partitions, such as org/repo or periodic/<job>, are read, or every partition if there are
+// none, which lists all the keys of the store.
+func ReadSummaries(store Store, partitions []string, since, until time.Time) ([]Summary, error) {
+	var keys []string
+	if len(partitions) == 0 {
+		all, err := store.List("")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range all {
+			if inWindow(key, since, until) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	for _, partition := range partitions {
+		// the keys are partitioned by the day of completion, so only the days of the window are listed
+		for day := since.UTC().Truncate(24 * time.Hour); !day.After(until); day = day.Add(24 * time.Hour) {
+			dayKeys, err := store.List(path.Join(strings.ToLower(partition), day.Format("2006/01/02")) + "/")
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, dayKeys...)
+		}
+	}
+
+	var answer []Summary
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".json") {
+			continue
+		}
+		data, err := store.Read(key)
+		if err != nil {
+			return nil, err
+		}
+		summary := Summary{}
+		if err := json.Unmarshal(data, &summary); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the summary %s", key)
+		}
+		completion := summary.Status.CompletionTime
+		if completion == nil || completion.Time.Before(since) || completion.Time.After(until) {
+			continue
+		}
+		answer = append(answer, summary)
+	}
+	return answer, nil
+}
+
+// inWindow returns true if the day of completion of the key overlaps the window
+func inWindow(key string, since, until time.Time) bool {
+	parts := strings.Split(key, "/")
+	if len(parts) < 4 {
+		return false
+	}
+	day, err := time.Parse("2006/01/02", strings.Join(parts[len(parts)-4:len(parts)-1], "/"))
+	if err != nil {
+		return false
+	}
+	return !day.Add(24*time.Hour).Before(since) && !day.After(until)
+}
@@ -0,0 +1,85 @@
+package jobutil
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+)
+
+// MaxChangedPaths is the maximum number of changed paths passed to a job. The changed paths of
+// larger changes are not passed, so that their pipelines run all their tests.
+const MaxChangedPaths = 500
+
+// rootModule is the module of the files matching no pattern
+const rootModule = "."
+
+// ModulePatterns validates the module patterns configured in the changedModules settings of a
+// repository, which default to the top level directories
+func ModulePatterns(patterns []string) ([]string, error) {
+	var answer []string
+	for _, pattern := range patterns {
+		pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid module pattern %q", pattern)
+		}
+		answer = append(answer, pattern)
+	}
+	if len(answer) == 0 {
+		return []string{"*"}, nil
+	}
+	return answer, nil
+}
+
+// ChangedModules returns the sorted modules containing the changed files
+func ChangedModules(patterns []string, changes []string) []string {
+	modules := map[string]bool{}
+	for _, change := range changes {
+		modules[moduleOf(patterns, change)] = true
+	}
+	var answer []string
+	for module := range modules {
+		answer = append(answer, module)
+	}
+	sort.Strings(answer)
+	return answer
+}
+
+// moduleOf returns the module containing the file
+func moduleOf(patterns []string, file string) string {
+	dirs := strings.Split(path.Dir(strings.TrimPrefix(file, "/")), "/")
+	for _, pattern := range patterns {
+		depth := strings.Count(pattern, "/") + 1
+		if depth > len(dirs) {
+			continue
+		}
+		dir := strings.Join(dirs[:depth], "/")
+		if dir == rootModule {
+			continue
+		}
+		if match, _ := path.Match(pattern, dir); match {
+			return dir
+		}
+	}
+	return rootModule
+}
+
+// SetChanges sets the paths changed by the pull requests or the push of a job and the modules
+// containing them, grouped with the given module patterns
+func SetChanges(spec *v1alpha1.LighthouseJobSpec, modulePatterns []string, changes []string) error {
+	patterns, err := ModulePatterns(modulePatterns)
+	if err != nil {
+		return err
+	}
+	spec.ChangedModules = ChangedModules(patterns, changes)
+	if len(changes) <= MaxChangedPaths {
+		spec.ChangedPaths = append([]string{}, changes...)
+		sort.Strings(spec.ChangedPaths)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package jobutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedModules(t *testing.T) {
+	changes := []string{
+		"go.mod",
+		"services/billing/api/handler.go",
+		"services/billing/main.go",
+		"services/README.md",
+		"libs/log/log.go",
+		"docs/index.md",
+	}
+	tests := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{
+			name:     "top level directories by default",
+			expected: []string{".", "docs", "libs", "services"},
+		},
+		{
+			name:     "patterns",
+			patterns: []string{"services/*", " /libs/*/ ", ""},
+			expected: []string{".", "libs/log", "services/billing"},
+		},
+		{
+			name:     "first matching pattern wins",
+			patterns: []string{"services", "services/*"},
+			expected: []string{".", "services"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns, err := ModulePatterns(tt.patterns)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ChangedModules(patterns, changes))
+		})
+	}
+
+	_, err := ModulePatterns([]string{"services/["})
+	assert.EqualError(t, err, `invalid module pattern "services/["`)
+}
+
+func TestSetChanges(t *testing.T) {
+	spec := v1alpha1.LighthouseJobSpec{}
+	require.NoError(t, SetChanges(&spec, nil, []string{"web/index.html", "api/main.go"}))
+	assert.Equal(t, []string{"api/main.go", "web/index.html"}, spec.ChangedPaths)
+	assert.Equal(t, []string{"api", "web"}, spec.ChangedModules)
+
+	var many []string
+	for i := 0; i <= MaxChangedPaths; i++ {
+		many = append(many, "api/"+strings.Repeat("a", i%10)+".go")
+	}
+	spec = v1alpha1.LighthouseJobSpec{}
+	require.NoError(t, SetChanges(&spec, nil, many))
+	assert.Nil(t, spec.ChangedPaths, "the paths of large pull requests are not passed")
+	assert.Equal(t, []string{"api"}, spec.ChangedModules)
+}
@@ -0,0 +1,103 @@
+package jobutil
+
+import (
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// statusContextsSyncPeriod is how often NewStatusContextsAgent checks for a reloaded configuration
+const statusContextsSyncPeriod = 10 * time.Second
+
+// ApplyStatusContexts returns a copy of the configuration with the contexts of the presubmits and
+// postsubmits named after the status contexts of the settings, or the configuration itself if the
+// contexts are not renamed. The jobs are renamed by the key they are configured with, so the jobs of
+// an org are named with the templates of the org.
+func ApplyStatusContexts(cfg *config.Config, contexts settings.StatusContexts) (*config.Config, error) {
+	if cfg == nil || contexts.IsEmpty() {
+		return cfg, nil
+	}
+	renamed := *cfg
+	presubmits := map[string][]config.Presubmit{}
+	for key, jobs := range cfg.Presubmits {
+		org, repo := splitJobKey(key)
+		for _, ps := range jobs {
+			context, err := statusContext(contexts, org, repo, config.PresubmitJob, ps.Name, ps.Context)
+			if err != nil {
+				return nil, err
+			}
+			ps.Context = context
+			presubmits[key] = append(presubmits[key], ps)
+		}
+	}
+	if err := renamed.SetPresubmits(presubmits); err != nil {
+		return nil, errors.Wrap(err, "invalid presubmits with renamed contexts")
+	}
+	postsubmits := map[string][]config.Postsubmit{}
+	for key, jobs := range cfg.Postsubmits {
+		org, repo := splitJobKey(key)
+		for _, ps := range jobs {
+			context, err := statusContext(contexts, org, repo, config.PostsubmitJob, ps.Name, ps.Context)
+			if err != nil {
+				return nil, err
+			}
+			ps.Context = context
+			postsubmits[key] = append(postsubmits[key], ps)
+		}
+	}
+	if err := renamed.SetPostsubmits(postsubmits); err != nil {
+		return nil, errors.Wrap(err, "invalid postsubmits with renamed contexts")
+	}
+	return &renamed, nil
+}
+
+// statusContext returns the context of the job, which defaults to its name
+func statusContext(contexts settings.StatusContexts, org, repo string, jobType config.PipelineKind, name, context string) (string, error) {
+	if context == "" {
+		context = name
+	}
+	return contexts.Context(org, repo, string(jobType), name, context)
+}
+
+// splitJobKey splits the org/repo key of jobs, whose repo is empty for the jobs of a whole org
+func splitJobKey(key string) (string, string) {
+	org, repo := scm.Split(key)
+	if repo == "" {
+		return key, ""
+	}
+	return org, repo
+}
+
+// NewStatusContextsAgent returns an agent holding the configuration of the getter with the contexts of its
+// jobs renamed after the settings, which is kept up to date when the configuration or the settings are
+// reloaded. It is used when the configuration is loaded from files by an agent of lighthouse-config, which
+// cannot rename the contexts itself.
+func NewStatusContextsAgent(cfg config.Getter, settingsGetter settings.Getter) *config.Agent {
+	agent := &config.Agent{}
+	var lastConfig *config.Config
+	var lastSettings *settings.Config
+	update := func() {
+		c, s := cfg(), settingsGetter()
+		if c == lastConfig && s == lastSettings {
+			return
+		}
+		lastConfig, lastSettings = c, s
+		renamed, err := ApplyStatusContexts(c, s.StatusContexts)
+		if err != nil {
+			logrus.WithError(err).Error("Error renaming the status contexts of the jobs, keeping the configured contexts.")
+			renamed = c
+		}
+		agent.Set(renamed)
+	}
+	update()
+	go func() {
+		for range time.Tick(statusContextsSyncPeriod) {
+			update()
+		}
+	}()
+	return agent
+}
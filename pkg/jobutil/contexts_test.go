@@ -0,0 +1,42 @@
+package jobutil
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyStatusContexts(t *testing.T) {
+	cfg := &config.Config{}
+	require.NoError(t, cfg.SetPresubmits(map[string][]config.Presubmit{
+		"org": {
+			{JobBase: config.JobBase{Name: "lint"}, Reporter: config.Reporter{Context: "pr-lint"}},
+		},
+		"org/repo": {
+			{JobBase: config.JobBase{Name: "unit"}, Reporter: config.Reporter{Context: "pr-unit"}},
+		},
+	}))
+	require.NoError(t, cfg.SetPostsubmits(map[string][]config.Postsubmit{
+		"org/repo": {
+			{JobBase: config.JobBase{Name: "release"}},
+		},
+	}))
+
+	same, err := ApplyStatusContexts(cfg, settings.StatusContexts{})
+	require.NoError(t, err)
+	assert.True(t, same == cfg, "the configuration is not copied if the contexts are not renamed")
+
+	renamed, err := ApplyStatusContexts(cfg, settings.StatusContexts{
+		Presubmit:  "ci/pr/{{ .Context }}",
+		Postsubmit: "ci/{{ .Repo }}/{{ .Job }}",
+		Jobs:       map[string]string{"lint": "lint"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "lint", renamed.Presubmits["org"][0].Context)
+	assert.Equal(t, "ci/pr/pr-unit", renamed.Presubmits["org/repo"][0].Context)
+	assert.Equal(t, "ci/repo/release", renamed.Postsubmits["org/repo"][0].Context, "the context defaults to the job name")
+	assert.Equal(t, "pr-unit", cfg.Presubmits["org/repo"][0].Context, "the configuration is not modified")
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -71,8 +72,8 @@ func AggregateFilter(filters []Filter) Filter {
 }
 
 // FilterPresubmits determines which presubmits should run and which should be skipped
-// by evaluating the user-provided filter.
-func FilterPresubmits(filter Filter, changes config.ChangedFilesProvider, branch string, presubmits []config.Presubmit, logger *logrus.Entry) ([]config.Presubmit, []config.Presubmit, error) {
+// by evaluating the user-provided filter and the presubmit settings, which may be nil.
+func FilterPresubmits(filter Filter, changes config.ChangedFilesProvider, branch string, presubmits []config.Presubmit, s *settings.Config, logger *logrus.Entry) ([]config.Presubmit, []config.Presubmit, error) {
 
 	var toTrigger []config.Presubmit
 	var namesToTrigger []string
@@ -86,6 +87,13 @@ func FilterPresubmits(filter Filter, changes config.ChangedFilesProvider, branch
 		if err != nil {
 			return nil, nil, err
 		}
+		if shouldRun && !forced {
+			skipped, err := SkipsChanges(s, presubmit, changes)
+			if err != nil {
+				return nil, nil, err
+			}
+			shouldRun = !skipped
+		}
 		if shouldRun {
 			toTrigger = append(toTrigger, presubmit)
 			namesToTrigger = append(namesToTrigger, presubmit.Name)
@@ -104,6 +112,22 @@ func FilterPresubmits(filter Filter, changes config.ChangedFilesProvider, branch
 	return toTrigger, toSkip, nil
 }
 
+// SkipsChanges returns true if the presubmit is skipped as the changed files all match its
+// skipIfOnlyChanged regex in the settings, which may be nil
+func SkipsChanges(s *settings.Config, presubmit config.Presubmit, changes config.ChangedFilesProvider) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	if _, ok := s.PresubmitFilters.SkipIfOnlyChanged[presubmit.Name]; !ok {
+		return false, nil
+	}
+	files, err := changes()
+	if err != nil {
+		return false, err
+	}
+	return s.PresubmitFilters.SkipsChanges(presubmit.Name, files), nil
+}
+
 // determineSkippedPresubmits identifies the largest set of contexts we can actually
 // post skipped contexts for, given a set of presubmits we're triggering. We don't
 // want to skip a job that posts a context that will be written to by a job we just
@@ -28,6 +28,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"k8s.io/apimachinery/pkg/util/diff"
 )
 
@@ -331,7 +332,7 @@ func TestFilterPresubmits(t *testing.T) {
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			actualToTrigger, actualToSkip, err := FilterPresubmits(testCase.filter, fakeChangedFilesProvider(testCase.changesError), branch, testCase.presubmits, logrus.WithField("test-case", testCase.name))
+			actualToTrigger, actualToSkip, err := FilterPresubmits(testCase.filter, fakeChangedFilesProvider(testCase.changesError), branch, testCase.presubmits, nil, logrus.WithField("test-case", testCase.name))
 			if testCase.expectErr && err == nil {
 				t.Errorf("%s: expected an error filtering presubmits, but got none", testCase.name)
 			}
@@ -859,3 +860,44 @@ func TestPresubmitFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterPresubmitsSkipIfOnlyChanged(t *testing.T) {
+	s, err := settings.Load([]byte("presubmitFilters:\n  skipIfOnlyChanged:\n    unit: \"^docs/|\\\\.md$\"\n"))
+	if err != nil {
+		t.Fatalf("failed to load the settings: %v", err)
+	}
+	presubmits := []config.Presubmit{
+		{JobBase: config.JobBase{Name: "unit"}, Reporter: config.Reporter{Context: "unit"}, AlwaysRun: true},
+		{JobBase: config.JobBase{Name: "lint"}, Reporter: config.Reporter{Context: "lint"}, AlwaysRun: true},
+	}
+	changes := func(files ...string) config.ChangedFilesProvider {
+		return func() ([]string, error) {
+			return files, nil
+		}
+	}
+	logger := logrus.WithField("test", t.Name())
+
+	toTrigger, toSkip, err := FilterPresubmits(TestAllFilter(), changes("docs/index.md", "README.md"), "master", presubmits, s, logger)
+	if err != nil {
+		t.Fatalf("failed to filter the presubmits: %v", err)
+	}
+	if len(toTrigger) != 1 || toTrigger[0].Name != "lint" || len(toSkip) != 1 || toSkip[0].Name != "unit" {
+		t.Errorf("expected to trigger lint and skip unit, got %v and %v", toTrigger, toSkip)
+	}
+
+	toTrigger, toSkip, err = FilterPresubmits(TestAllFilter(), changes("docs/index.md", "main.go"), "master", presubmits, s, logger)
+	if err != nil {
+		t.Fatalf("failed to filter the presubmits: %v", err)
+	}
+	if len(toTrigger) != 2 || len(toSkip) != 0 {
+		t.Errorf("expected to trigger both presubmits, got %v and %v", toTrigger, toSkip)
+	}
+
+	toTrigger, _, err = FilterPresubmits(CommandFilter("/test unit"), changes("README.md"), "master", presubmits, s, logger)
+	if err != nil {
+		t.Fatalf("failed to filter the presubmits: %v", err)
+	}
+	if len(toTrigger) != 1 || toTrigger[0].Name != "unit" {
+		t.Errorf("expected /test unit to trigger unit, got %v", toTrigger)
+	}
+}
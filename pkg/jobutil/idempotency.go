@@ -0,0 +1,30 @@
+package jobutil
+
+import (
+	"crypto/sha1" // #nosec
+	"encoding/hex"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+)
+
+// IdempotencyKey returns a deterministic key for the job created for the spec in reaction to
+// the webhook event with the given GUID, derived from the repository, the SHAs under test, the
+// job name and the event GUID. Redeliveries of the same event produce the same key, which allows
+// launchers to detect that an equivalent job already exists, while the replays of an event are
+// given a new GUID so that they create new jobs. It returns an empty string if there is no event
+// GUID, e.g. for jobs created by keeper.
+func IdempotencyKey(spec v1alpha1.LighthouseJobSpec, eventGUID string) string {
+	if eventGUID == "" {
+		return ""
+	}
+	parts := []string{string(spec.Type), spec.Job, eventGUID}
+	if spec.Refs != nil {
+		parts = append(parts, spec.Refs.Org, spec.Refs.Repo, spec.Refs.BaseRef, spec.Refs.BaseSHA)
+		for _, pull := range spec.Refs.Pulls {
+			parts = append(parts, pull.SHA)
+		}
+	}
+	hash := sha1.Sum([]byte(strings.Join(parts, "\n"))) // #nosec
+	return hex.EncodeToString(hash[:])
+}
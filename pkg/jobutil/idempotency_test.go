@@ -0,0 +1,39 @@
+package jobutil
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	pr := &scm.PullRequest{
+		Number: 1,
+		Head:   scm.PullRequestBranch{Sha: "abcdef"},
+		Base: scm.PullRequestBranch{
+			Ref:  "master",
+			Repo: scm.Repository{Namespace: "org", Name: "repo"},
+		},
+	}
+	job := config.Presubmit{JobBase: config.JobBase{Name: "lint"}, Reporter: config.Reporter{Context: "lint"}}
+
+	first := NewPresubmit(pr, "123456", job, "guid-1")
+	redelivered := NewPresubmit(pr, "123456", job, "guid-1")
+	other := NewPresubmit(pr, "123456", job, "guid-2")
+
+	key := first.Labels[util.IdempotencyKeyLabel]
+	assert.NotEmpty(t, key)
+	assert.Equal(t, key, first.Name)
+	assert.Equal(t, first.Name, redelivered.Name)
+	assert.NotEqual(t, first.Name, other.Name)
+
+	pr.Head.Sha = "fedcba"
+	assert.NotEqual(t, first.Name, NewPresubmit(pr, "123456", job, "guid-1").Name)
+
+	assert.Empty(t, IdempotencyKey(first.Spec, ""))
+	noGUID := NewLighthouseJob(first.Spec, nil, nil)
+	assert.NotContains(t, noGUID.Labels, util.IdempotencyKeyLabel)
+}
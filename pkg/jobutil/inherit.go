@@ -0,0 +1,70 @@
+package jobutil
+
+import (
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Presubmits returns the presubmits for the given repository.
+//
+// Presubmits can be declared at the org level by using the org name as the key
+// instead of org/repo. They are inherited by every repository in the org unless
+// the repository declares a presubmit with the same name, which overrides it.
+func Presubmits(cfg *config.Config, repository scm.Repository) []config.Presubmit {
+	answer := cfg.GetPresubmits(repository)
+	names := sets.NewString()
+	for _, ps := range answer {
+		names.Insert(ps.Name)
+	}
+	for _, org := range orgKeys(repository) {
+		for _, ps := range cfg.Presubmits[org] {
+			if names.Has(ps.Name) {
+				continue
+			}
+			names.Insert(ps.Name)
+			answer = append(answer, ps)
+		}
+	}
+	return answer
+}
+
+// Postsubmits returns the postsubmits for the given repository, including
+// those inherited from the org level. See Presubmits for the inheritance rules.
+func Postsubmits(cfg *config.Config, repository scm.Repository) []config.Postsubmit {
+	answer := cfg.GetPostsubmits(repository)
+	names := sets.NewString()
+	for _, ps := range answer {
+		names.Insert(ps.Name)
+	}
+	for _, org := range orgKeys(repository) {
+		for _, ps := range cfg.Postsubmits[org] {
+			if names.Has(ps.Name) {
+				continue
+			}
+			names.Insert(ps.Name)
+			answer = append(answer, ps)
+		}
+	}
+	return answer
+}
+
+// orgKeys returns the org level keys to look up for the repository. On
+// bitbucket server the owner can be an upper case project key, so we also
+// check the lower case owner.
+func orgKeys(repository scm.Repository) []string {
+	owner := repository.Namespace
+	if owner == "" {
+		owner, _ = scm.Split(repository.FullName)
+	}
+	if owner == "" {
+		return nil
+	}
+	keys := []string{owner}
+	if lowerOwner := strings.ToLower(owner); lowerOwner != owner {
+		keys = append(keys, lowerOwner)
+	}
+	return keys
+}
@@ -0,0 +1,49 @@
+package jobutil
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresubmitsInheritedFromOrg(t *testing.T) {
+	cfg := &config.Config{
+		JobConfig: config.JobConfig{
+			Presubmits: map[string][]config.Presubmit{
+				"org": {
+					{JobBase: config.JobBase{Name: "lint"}},
+					{JobBase: config.JobBase{Name: "unit", Agent: "org-agent"}},
+				},
+				"org/repo": {
+					{JobBase: config.JobBase{Name: "unit", Agent: "repo-agent"}},
+					{JobBase: config.JobBase{Name: "integration"}},
+				},
+			},
+			Postsubmits: map[string][]config.Postsubmit{
+				"org": {
+					{JobBase: config.JobBase{Name: "release"}},
+				},
+			},
+		},
+	}
+
+	presubmits := Presubmits(cfg, scm.Repository{Namespace: "org", Name: "repo"})
+	agents := map[string]string{}
+	for _, ps := range presubmits {
+		agents[ps.Name] = ps.Agent
+	}
+	assert.Equal(t, map[string]string{"unit": "repo-agent", "integration": "", "lint": ""}, agents)
+
+	presubmits = Presubmits(cfg, scm.Repository{FullName: "org/other"})
+	assert.Len(t, presubmits, 2)
+
+	presubmits = Presubmits(cfg, scm.Repository{Namespace: "another", Name: "repo"})
+	assert.Empty(t, presubmits)
+
+	postsubmits := Postsubmits(cfg, scm.Repository{Namespace: "org", Name: "repo"})
+	if assert.Len(t, postsubmits, 1) {
+		assert.Equal(t, "release", postsubmits[0].Name)
+	}
+}
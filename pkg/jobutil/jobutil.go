@@ -39,6 +39,13 @@ import (
 func NewLighthouseJob(spec v1alpha1.LighthouseJobSpec, extraLabels, extraAnnotations map[string]string) v1alpha1.LighthouseJob {
 	labels, annotations := LabelsAndAnnotationsForSpec(spec, extraLabels, extraAnnotations)
 	newID, _ := uuid.NewV1()
+	name := newID.String()
+
+	// jobs created for webhook events get a deterministic name so that redeliveries don't create duplicates
+	if key := IdempotencyKey(spec, extraLabels[scmprovider.EventGUID]); key != "" {
+		labels[util.IdempotencyKeyLabel] = key
+		name = key
+	}
 
 	return v1alpha1.LighthouseJob{
 		TypeMeta: metav1.TypeMeta{
@@ -46,7 +53,7 @@ func NewLighthouseJob(spec v1alpha1.LighthouseJobSpec, extraLabels, extraAnnotat
 			Kind:       "LighthouseJob",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        newID.String(),
+			Name:        name,
 			Labels:      labels,
 			Annotations: annotations,
 		},
@@ -54,6 +61,20 @@ func NewLighthouseJob(spec v1alpha1.LighthouseJobSpec, extraLabels, extraAnnotat
 	}
 }
 
+// ApplyDefaultEnv adds the default environment variables to the parameters of the job, which are
+// passed to its pipeline, unless the job sets them itself
+func ApplyDefaultEnv(spec *v1alpha1.LighthouseJobSpec, defaults map[string]string) {
+	for k, v := range defaults {
+		if _, ok := spec.Parameters[k]; ok {
+			continue
+		}
+		if spec.Parameters == nil {
+			spec.Parameters = map[string]string{}
+		}
+		spec.Parameters[k] = v
+	}
+}
+
 func createRefs(pr *scm.PullRequest, baseSHA string) v1alpha1.Refs {
 	org := pr.Base.Repo.Namespace
 	repo := pr.Base.Repo.Name
@@ -102,6 +123,7 @@ func PresubmitSpec(p config.Presubmit, refs v1alpha1.Refs) v1alpha1.LighthouseJo
 	pjs := specFromJobBase(p.JobBase)
 	pjs.Type = config.PresubmitJob
 	pjs.Context = p.Context
+	pjs.SkipReport = p.SkipReport
 	pjs.RerunCommand = p.RerunCommand
 	pjs.Refs = completePrimaryRefs(refs, p.JobBase)
 
@@ -113,6 +135,7 @@ func PostsubmitSpec(p config.Postsubmit, refs v1alpha1.Refs) v1alpha1.Lighthouse
 	pjs := specFromJobBase(p.JobBase)
 	pjs.Type = config.PostsubmitJob
 	pjs.Context = p.Context
+	pjs.SkipReport = p.SkipReport
 	pjs.Refs = completePrimaryRefs(refs, p.JobBase)
 
 	return pjs
@@ -131,6 +154,7 @@ func BatchSpec(p config.Presubmit, refs v1alpha1.Refs) v1alpha1.LighthouseJobSpe
 	pjs := specFromJobBase(p.JobBase)
 	pjs.Type = config.BatchJob
 	pjs.Context = p.Context
+	pjs.SkipReport = p.SkipReport
 	pjs.Refs = completePrimaryRefs(refs, p.JobBase)
 
 	return pjs
@@ -143,6 +167,7 @@ func specFromJobBase(jb config.JobBase) v1alpha1.LighthouseJobSpec {
 	}
 	return v1alpha1.LighthouseJobSpec{
 		Job:            jb.Name,
+		Agent:          jb.Agent,
 		Namespace:      namespace,
 		MaxConcurrency: jb.MaxConcurrency,
 	}
@@ -17,6 +17,7 @@ limitations under the License.
 package jobutil
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 	"text/template"
@@ -163,6 +164,18 @@ func TestPresubmitSpec(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "skip_report is passed to the job",
+			p: config.Presubmit{
+				Reporter: config.Reporter{Context: "experimental", SkipReport: true},
+			},
+			expected: v1alpha1.LighthouseJobSpec{
+				Type:       config.PresubmitJob,
+				Context:    "experimental",
+				SkipReport: true,
+				Refs:       &v1alpha1.Refs{},
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -576,6 +589,16 @@ func TestSpecFromJobBase(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name:    "Verify agent gets copied",
+			jobBase: config.JobBase{Agent: "tekton"},
+			verify: func(pj v1alpha1.LighthouseJobSpec) error {
+				if pj.Agent != "tekton" {
+					return fmt.Errorf("Expected pj.Agent to be \"tekton\", was %q", pj.Agent)
+				}
+				return nil
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -587,3 +610,33 @@ func TestSpecFromJobBase(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyDefaultEnv(t *testing.T) {
+	spec := v1alpha1.LighthouseJobSpec{
+		Type:       config.PeriodicJob,
+		Job:        "some-job",
+		Parameters: map[string]string{"MIRROR": "other.example.com"},
+	}
+	ApplyDefaultEnv(&spec, map[string]string{
+		"ARTIFACT_BUCKET":   "gs://artifacts",
+		"MIRROR":            "mirror.example.com",
+		v1alpha1.JobNameEnv: "ignored",
+	})
+
+	env := spec.GetEnvVars()
+	if env["ARTIFACT_BUCKET"] != "gs://artifacts" {
+		t.Errorf("Expected the default ARTIFACT_BUCKET to be added but got %q", env["ARTIFACT_BUCKET"])
+	}
+	if env["MIRROR"] != "other.example.com" {
+		t.Errorf("Expected the job parameter to override the default MIRROR but got %q", env["MIRROR"])
+	}
+	if env[v1alpha1.JobNameEnv] != "some-job" {
+		t.Errorf("Expected the job name not to be overridden by the defaults but got %q", env[v1alpha1.JobNameEnv])
+	}
+
+	spec = v1alpha1.LighthouseJobSpec{}
+	ApplyDefaultEnv(&spec, map[string]string{"ARTIFACT_BUCKET": "gs://artifacts"})
+	if spec.Parameters["ARTIFACT_BUCKET"] != "gs://artifacts" {
+		t.Errorf("Expected the default to be added to a job without parameters but got %v", spec.Parameters)
+	}
+}
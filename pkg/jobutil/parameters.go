@@ -0,0 +1,135 @@
+package jobutil
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/pkg/errors"
+)
+
+// ParametersAnnotation is the annotation which declares the parameters a presubmit
+// accepts from `/test job-name key=value` comments. The value is a comma separated
+// list of `NAME:type` entries where type is one of string, int or bool, e.g.
+// `ITERATIONS:int,TARGET:string,DEBUG:bool`. The type defaults to string.
+const ParametersAnnotation = "lighthouse.jenkins-x.io/parameters"
+
+const (
+	// ParameterTypeString is a parameter accepting any value
+	ParameterTypeString = "string"
+	// ParameterTypeInt is a parameter accepting integer values
+	ParameterTypeInt = "int"
+	// ParameterTypeBool is a parameter accepting true or false
+	ParameterTypeBool = "bool"
+)
+
+var (
+	testCommandRe    = regexp.MustCompile(`^/(?:lh-)?test\s+(.*)$`)
+	parameterNameRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	parameterTypeSet = map[string]bool{ParameterTypeString: true, ParameterTypeInt: true, ParameterTypeBool: true}
+)
+
+// JobParameters returns the declared parameters of a job keyed by name, with the type as value
+func JobParameters(base config.JobBase) (map[string]string, error) {
+	value := strings.TrimSpace(base.Annotations[ParametersAnnotation])
+	if value == "" {
+		return nil, nil
+	}
+	params := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, kind := entry, ParameterTypeString
+		if i := strings.Index(entry, ":"); i >= 0 {
+			name, kind = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+		if !parameterNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid parameter name %q for job %s", name, base.Name)
+		}
+		if !parameterTypeSet[kind] {
+			return nil, fmt.Errorf("invalid type %q of parameter %s for job %s", kind, name, base.Name)
+		}
+		params[name] = kind
+	}
+	return params, nil
+}
+
+// ParsePresubmitParameters returns the `key=value` parameters given to the presubmit
+// in the `/test` commands of the comment body. An error is returned if a parameter
+// is not declared by the job or if its value does not match the declared type.
+func ParsePresubmitParameters(p config.Presubmit, body string) (map[string]string, error) {
+	var values map[string]string
+	for _, line := range strings.Split(body, "\n") {
+		match := testCommandRe.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		args := strings.Fields(match[1])
+		if !namesJob(args, p.Name) {
+			continue
+		}
+		for _, arg := range args {
+			i := strings.Index(arg, "=")
+			if i <= 0 {
+				continue
+			}
+			if values == nil {
+				values = map[string]string{}
+			}
+			values[arg[:i]] = arg[i+1:]
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	declared, err := JobParameters(p.JobBase)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for name, value := range values {
+		kind, ok := declared[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is not a parameter of job %s", name, p.Name))
+			continue
+		}
+		if err := checkParameterType(kind, value); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value %q of parameter %s: %v", value, name, err))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, errors.New(strings.Join(problems, ", "))
+	}
+	return values, nil
+}
+
+// namesJob returns true if the /test command arguments contain the job name
+func namesJob(args []string, name string) bool {
+	for _, arg := range args {
+		if strings.TrimSuffix(arg, ",") == name {
+			return true
+		}
+	}
+	return false
+}
+
+func checkParameterType(kind, value string) error {
+	switch kind {
+	case ParameterTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return errors.New("expected an integer")
+		}
+	case ParameterTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("expected true or false")
+		}
+	}
+	return nil
+}
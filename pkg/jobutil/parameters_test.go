@@ -0,0 +1,73 @@
+package jobutil
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePresubmitParameters(t *testing.T) {
+	job := config.Presubmit{
+		JobBase: config.JobBase{
+			Name: "perf",
+			Annotations: map[string]string{
+				ParametersAnnotation: "ITERATIONS:int, TARGET, DEBUG:bool",
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected map[string]string
+		err      string
+	}{
+		{
+			name: "no parameters",
+			body: "/test perf",
+		},
+		{
+			name:     "typed parameters",
+			body:     "/test perf ITERATIONS=10 TARGET=staging DEBUG=true",
+			expected: map[string]string{"ITERATIONS": "10", "TARGET": "staging", "DEBUG": "true"},
+		},
+		{
+			name:     "lh prefix and other lines",
+			body:     "looks good\n/lh-test perf TARGET=prod\n/test other ITERATIONS=abc",
+			expected: map[string]string{"TARGET": "prod"},
+		},
+		{
+			name: "parameters for other jobs are ignored",
+			body: "/test other ITERATIONS=3",
+		},
+		{
+			name: "undeclared parameter",
+			body: "/test perf FOO=bar",
+			err:  "FOO is not a parameter of job perf",
+		},
+		{
+			name: "invalid type",
+			body: "/test perf ITERATIONS=many",
+			err:  `invalid value "many" of parameter ITERATIONS: expected an integer`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParsePresubmitParameters(job, tc.body)
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestJobParametersInvalid(t *testing.T) {
+	_, err := JobParameters(config.JobBase{Name: "perf", Annotations: map[string]string{ParametersAnnotation: "COUNT:float"}})
+	assert.Error(t, err)
+}
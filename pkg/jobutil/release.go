@@ -0,0 +1,23 @@
+package jobutil
+
+import (
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+)
+
+// ReleaseJobAnnotation is the annotation which marks a postsubmit as a release job.
+// Release jobs are triggered when a release is published instead of on every push,
+// with the branches of the postsubmit matched against the tag of the release.
+const ReleaseJobAnnotation = "lighthouse.jenkins-x.io/release"
+
+// IsReleaseJob returns true if the postsubmit should only be triggered by releases
+func IsReleaseJob(p config.Postsubmit) bool {
+	return p.Annotations[ReleaseJobAnnotation] == "true"
+}
+
+// ReleaseSpec initializes a PipelineOptionsSpec for a release job triggered by the given release.
+func ReleaseSpec(p config.Postsubmit, refs v1alpha1.Refs, release v1alpha1.Release) v1alpha1.LighthouseJobSpec {
+	pjs := PostsubmitSpec(p, refs)
+	pjs.Release = &release
+	return pjs
+}
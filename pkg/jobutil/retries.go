@@ -0,0 +1,85 @@
+package jobutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+)
+
+const (
+	// RetriesAnnotation is the annotation of the jobs configuring how many times foghorn launches them again
+	// when their pipeline errors, e.g. "2". Failed pipelines, whose tests failed, are not retried.
+	RetriesAnnotation = "lighthouse.jenkins-x.io/retries"
+	// AttemptAnnotation is the annotation of the retries containing their attempt number, 2 for the first retry
+	AttemptAnnotation = "lighthouse.jenkins-x.io/attempt"
+	// RetryOfAnnotation is the annotation of the retries naming the LighthouseJob of the previous attempt
+	RetryOfAnnotation = "lighthouse.jenkins-x.io/retryOf"
+	// RetriedByAnnotation is the annotation of the retried jobs naming the LighthouseJob of the next attempt
+	RetriedByAnnotation = "lighthouse.jenkins-x.io/retriedBy"
+
+	// PullRequestClosedDescription is the description of the jobs aborted as their pull request was closed,
+	// which are not retried
+	PullRequestClosedDescription = "Aborted as the pull request was closed"
+)
+
+// Retries returns the number of times the job is retried when its pipeline errors, 0 if it is not configured
+func Retries(job *v1alpha1.LighthouseJob) int {
+	retries, err := strconv.Atoi(strings.TrimSpace(job.Annotations[RetriesAnnotation]))
+	if err != nil || retries < 0 {
+		return 0
+	}
+	return retries
+}
+
+// Attempt returns the attempt number of the job, which is 1 unless it is a retry
+func Attempt(job *v1alpha1.LighthouseJob) int {
+	attempt, err := strconv.Atoi(job.Annotations[AttemptAnnotation])
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return attempt
+}
+
+// CanRetry returns true if the pipeline of the job errored and it has attempts left. Only the pipelines which
+// were aborted, such as the pipelines cancelled or which could not run, count as errored, unless they were
+// aborted as their pull request was closed.
+func CanRetry(job *v1alpha1.LighthouseJob) bool {
+	if job.Status.State != v1alpha1.AbortedState || job.Status.Description == PullRequestClosedDescription {
+		return false
+	}
+	return Attempt(job) <= Retries(job) && job.Annotations[RetriedByAnnotation] == ""
+}
+
+// NewRetry returns the next attempt of the job, which is named after the first attempt and the attempt number
+// so that the job is retried once even if the retry is launched several times
+func NewRetry(job *v1alpha1.LighthouseJob) v1alpha1.LighthouseJob {
+	labels := make(map[string]string)
+	for k, v := range job.Labels {
+		labels[k] = v
+	}
+	// the retry must not be mistaken for a redelivery of the event which created the job
+	delete(labels, scmprovider.EventGUID)
+	delete(labels, util.IdempotencyKeyLabel)
+	delete(labels, util.CompletedLabel)
+	delete(labels, util.BuildNumLabel)
+	annotations := make(map[string]string)
+	for k, v := range job.Annotations {
+		annotations[k] = v
+	}
+	delete(annotations, RetriedByAnnotation)
+	attempt := Attempt(job) + 1
+	annotations[AttemptAnnotation] = strconv.Itoa(attempt)
+	annotations[RetryOfAnnotation] = job.Name
+
+	first := job.Name
+	if attempt > 2 {
+		first = strings.TrimSuffix(job.Name, fmt.Sprintf("-attempt-%d", attempt-1))
+	}
+	answer := NewLighthouseJob(job.Spec, labels, annotations)
+	answer.Name = fmt.Sprintf("%s-attempt-%d", first, attempt)
+	return answer
+}
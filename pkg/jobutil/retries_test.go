@@ -0,0 +1,75 @@
+package jobutil
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCanRetry(t *testing.T) {
+	job := func(state v1alpha1.PipelineState, annotations map[string]string) *v1alpha1.LighthouseJob {
+		return &v1alpha1.LighthouseJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "job", Annotations: annotations},
+			Status:     v1alpha1.LighthouseJobStatus{State: state},
+		}
+	}
+	closed := job(v1alpha1.AbortedState, map[string]string{RetriesAnnotation: "1"})
+	closed.Status.Description = PullRequestClosedDescription
+
+	testCases := []struct {
+		name     string
+		job      *v1alpha1.LighthouseJob
+		expected bool
+	}{
+		{name: "errored", job: job(v1alpha1.AbortedState, map[string]string{RetriesAnnotation: "1"}), expected: true},
+		{name: "no retries", job: job(v1alpha1.AbortedState, nil)},
+		{name: "invalid retries", job: job(v1alpha1.AbortedState, map[string]string{RetriesAnnotation: "many"})},
+		{name: "failed", job: job(v1alpha1.FailureState, map[string]string{RetriesAnnotation: "1"})},
+		{name: "running", job: job(v1alpha1.RunningState, map[string]string{RetriesAnnotation: "1"})},
+		{name: "closed pull request", job: closed},
+		{name: "last attempt", job: job(v1alpha1.AbortedState, map[string]string{RetriesAnnotation: "1", AttemptAnnotation: "2"})},
+		{name: "attempts left", job: job(v1alpha1.AbortedState, map[string]string{RetriesAnnotation: "2", AttemptAnnotation: "2"}), expected: true},
+		{name: "already retried", job: job(v1alpha1.AbortedState, map[string]string{RetriesAnnotation: "1", RetriedByAnnotation: "job-attempt-2"})},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, CanRetry(tc.job), tc.name)
+	}
+}
+
+func TestNewRetry(t *testing.T) {
+	job := &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "abc123",
+			Labels: map[string]string{
+				"custom":                 "label",
+				scmprovider.EventGUID:    "guid",
+				util.IdempotencyKeyLabel: "abc123",
+				util.BuildNumLabel:       "4",
+			},
+			Annotations: map[string]string{RetriesAnnotation: "2"},
+		},
+		Spec: v1alpha1.LighthouseJobSpec{Type: v1alpha1.PresubmitJob, Job: "lint", Context: "lint"},
+	}
+
+	retry := NewRetry(job)
+	assert.Equal(t, "abc123-attempt-2", retry.Name)
+	assert.Equal(t, "label", retry.Labels["custom"])
+	for _, label := range []string{scmprovider.EventGUID, util.IdempotencyKeyLabel, util.BuildNumLabel} {
+		assert.NotContains(t, retry.Labels, label)
+	}
+	assert.Equal(t, "2", retry.Annotations[AttemptAnnotation])
+	assert.Equal(t, "abc123", retry.Annotations[RetryOfAnnotation])
+	assert.Equal(t, "2", retry.Annotations[RetriesAnnotation])
+	assert.Equal(t, job.Spec, retry.Spec)
+
+	retry.Annotations[RetriedByAnnotation] = "abc123-attempt-3"
+	again := NewRetry(&retry)
+	assert.Equal(t, "abc123-attempt-3", again.Name, "the attempts are named after the first attempt")
+	assert.Equal(t, "3", again.Annotations[AttemptAnnotation])
+	assert.Equal(t, "abc123-attempt-2", again.Annotations[RetryOfAnnotation])
+	assert.NotContains(t, again.Annotations, RetriedByAnnotation)
+}
@@ -0,0 +1,129 @@
+package jobutil
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+)
+
+// UnknownJob is a job named by a `/test` command which matches no presubmit
+type UnknownJob struct {
+	Name string
+	// Suggestions are the names of the presubmits close to the name, if any
+	Suggestions []string
+}
+
+// HasTestCommand returns true if the comment body contains a `/test` command, whether it names
+// existing jobs or not
+func HasTestCommand(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if testCommandRe.MatchString(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveTestCommands rewrites the `/test <job>` commands of the comment body whose job names match no
+// presubmit trigger with the names of the presubmits they fuzzily match: ignoring the case, being part of
+// a single job name or being a close misspelling of it. The names matching no presubmit, or more than one,
+// are returned as unknown along with suggestions.
+func ResolveTestCommands(body string, presubmits []config.Presubmit) (string, []UnknownJob) {
+	var unknown []UnknownJob
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		match := testCommandRe.FindStringSubmatch(trimmed)
+		if match == nil || TestAllRe.MatchString(trimmed) || triggersAny(trimmed, presubmits) {
+			continue
+		}
+		args := strings.Fields(match[1])
+		for j, arg := range args {
+			name := strings.TrimSuffix(arg, ",")
+			if name == "" || strings.Contains(name, "=") {
+				continue
+			}
+			resolved, suggestions := resolveJobName(name, presubmits)
+			if resolved == "" {
+				unknown = append(unknown, UnknownJob{Name: name, Suggestions: suggestions})
+				continue
+			}
+			args[j] = resolved
+		}
+		lines[i] = strings.Fields(trimmed)[0] + " " + strings.Join(args, " ")
+	}
+	return strings.Join(lines, "\n"), unknown
+}
+
+func triggersAny(line string, presubmits []config.Presubmit) bool {
+	for _, p := range presubmits {
+		if p.TriggerMatches(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveJobName returns the name of the single presubmit matching the requested name, or the
+// names of the presubmits close to it
+func resolveJobName(name string, presubmits []config.Presubmit) (string, []string) {
+	requested := strings.ToLower(name)
+	candidates := map[string]bool{}
+	for _, p := range presubmits {
+		candidate := strings.ToLower(p.Name)
+		if candidate == requested {
+			return p.Name, nil
+		}
+		if strings.Contains(candidate, requested) || isMisspelling(requested, candidate) {
+			candidates[p.Name] = true
+		}
+	}
+	var names []string
+	for n := range candidates {
+		names = append(names, n)
+	}
+	if len(names) == 1 {
+		return names[0], nil
+	}
+	sort.Strings(names)
+	return "", names
+}
+
+// isMisspelling returns true if the requested name is a couple of edits away from the candidate,
+// and not so short that any name would be
+func isMisspelling(requested, candidate string) bool {
+	d := editDistance(requested, candidate)
+	return d <= 2 && 2*d < len(requested)
+}
+
+// editDistance returns the Levenshtein distance between the strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minOf(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minOf(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
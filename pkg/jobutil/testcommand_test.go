@@ -0,0 +1,76 @@
+package jobutil
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTestCommands(t *testing.T) {
+	cfg := &config.Config{}
+	require.NoError(t, cfg.SetPresubmits(map[string][]config.Presubmit{
+		"org/repo": {
+			{JobBase: config.JobBase{Name: "unit-tests"}, Reporter: config.Reporter{Context: "pull-unit"}},
+			{JobBase: config.JobBase{Name: "integration-tests"}, Reporter: config.Reporter{Context: "pull-integration"}},
+			{JobBase: config.JobBase{Name: "lint"}, Reporter: config.Reporter{Context: "pull-lint"}},
+		},
+	}))
+	presubmits := Presubmits(cfg, scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo"})
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+		unknown  []UnknownJob
+	}{
+		{
+			name:     "exact names and other commands are kept",
+			body:     "/test lint\n/test all\n/retest",
+			expected: "/test lint\n/test all\n/retest",
+		},
+		{
+			name:     "case",
+			body:     "/test LINT",
+			expected: "/test lint",
+		},
+		{
+			name:     "part of a name with parameters",
+			body:     "looks good\n/lh-test integration DEBUG=true",
+			expected: "looks good\n/lh-test integration-tests DEBUG=true",
+		},
+		{
+			name:     "misspelling",
+			body:     "/test lnt",
+			expected: "/test lint",
+		},
+		{
+			name:     "ambiguous name",
+			body:     "/test tests",
+			expected: "/test tests",
+			unknown:  []UnknownJob{{Name: "tests", Suggestions: []string{"integration-tests", "unit-tests"}}},
+		},
+		{
+			name:     "unknown name",
+			body:     "/test e2e, lint",
+			expected: "/test e2e, lint",
+			unknown:  []UnknownJob{{Name: "e2e"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, unknown := ResolveTestCommands(tc.body, presubmits)
+			assert.Equal(t, tc.expected, body)
+			assert.Equal(t, tc.unknown, unknown)
+		})
+	}
+}
+
+func TestHasTestCommand(t *testing.T) {
+	assert.True(t, HasTestCommand("please\n/test anything"))
+	assert.True(t, HasTestCommand("/lh-test all"))
+	assert.False(t, HasTestCommand("/retest"))
+	assert.False(t, HasTestCommand("I will /test it later"))
+}
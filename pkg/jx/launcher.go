@@ -1,6 +1,7 @@
 package jx
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/jenkins-x/lighthouse/pkg/util"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -25,6 +27,12 @@ type launcher struct {
 	namespace          string
 }
 
+func init() {
+	launcher2.Register(launcher2.DefaultAgent, func(launcher2.Options) (launcher2.PipelineLauncher, error) {
+		return NewLauncher()
+	})
+}
+
 // NewLauncher creates a new builder
 func NewLauncher() (launcher2.PipelineLauncher, error) {
 	factory := jxfactory.NewFactory()
@@ -83,6 +91,15 @@ func (b *launcher) Launch(request *v1alpha1.LighthouseJob, repository scm.Reposi
 		"PullRefs":  pullRefs,
 		"Job":       job,
 	}))
+	existing, err := b.findExistingJob(request)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		l.WithField("LighthouseJob", existing.Name).Info("an equivalent LighthouseJob already exists, not starting a duplicate pipeline")
+		return existing, nil
+	}
+
 	l.Info("about to start Jenkinx X meta pipeline")
 
 	sa := os.Getenv("JX_SERVICE_ACCOUNT")
@@ -103,17 +120,35 @@ func (b *launcher) Launch(request *v1alpha1.LighthouseJob, repository scm.Reposi
 		EnvVariables: spec.GetEnvVars(),
 	}
 
+	// create the job first so that a concurrent delivery of the same event is detected before
+	// the metapipeline consumes a build number
+	appliedJob, err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).Create(request)
+	if err != nil {
+		if kubeerrors.IsAlreadyExists(err) {
+			// a concurrent delivery of the same event won the race
+			l.WithField("LighthouseJob", request.Name).Info("an equivalent LighthouseJob was created concurrently, not starting a duplicate pipeline")
+			return b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).Get(request.Name, metav1.GetOptions{})
+		}
+		return nil, errors.Wrap(err, "unable to apply LighthouseJob")
+	}
+
 	activityKey, tektonCRDs, err := b.metapipelineClient.Create(pipelineCreateParam)
 	if err != nil {
+		// remove the job so that the event can be retried
+		if deleteErr := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).Delete(appliedJob.Name, &metav1.DeleteOptions{}); deleteErr != nil {
+			l.WithError(deleteErr).Warnf("failed to delete LighthouseJob %s", appliedJob.Name)
+		}
 		return nil, errors.Wrap(err, "unable to create Tekton CRDs")
 	}
 
 	// Add the build number from the activity key to the labels on the job
-	request.Labels[util.BuildNumLabel] = activityKey.Build
-
-	appliedJob, err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).Create(request)
+	if appliedJob.Labels == nil {
+		appliedJob.Labels = map[string]string{}
+	}
+	appliedJob.Labels[util.BuildNumLabel] = activityKey.Build
+	appliedJob, err = b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).Update(appliedJob)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to apply LighthouseJob")
+		return nil, errors.Wrapf(err, "unable to set the build number of LighthouseJob %s", request.Name)
 	}
 
 	// Set status on the job
@@ -134,6 +169,24 @@ func (b *launcher) Launch(request *v1alpha1.LighthouseJob, repository scm.Reposi
 	return fullyCreatedJob, nil
 }
 
+// findExistingJob returns the LighthouseJob with the same idempotency key as the request, if any
+func (b *launcher) findExistingJob(request *v1alpha1.LighthouseJob) (*v1alpha1.LighthouseJob, error) {
+	key := request.Labels[util.IdempotencyKeyLabel]
+	if key == "" {
+		return nil, nil
+	}
+	list, err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", util.IdempotencyKeyLabel, key),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list LighthouseJobs with idempotency key %s", key)
+	}
+	if len(list.Items) == 0 {
+		return nil, nil
+	}
+	return &list.Items[0], nil
+}
+
 func (b *launcher) getPullRefs(sourceURL string, spec *v1alpha1.LighthouseJobSpec) metapipeline.PullRef {
 	var pullRef metapipeline.PullRef
 	if len(spec.Refs.Pulls) > 0 {
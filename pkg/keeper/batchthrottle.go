@@ -0,0 +1,105 @@
+package keeper
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// BatchThrottle defers triggering batch jobs while the cluster is saturated, so
+// that batch testing does not starve the capacity needed for presubmits.
+//
+// The number of unfinished LighthouseJobs and batches is observed at the start of
+// every sync and updated as batches are triggered during the sync. A single
+// BatchThrottle is meant to be shared between all the keeper controllers of a process.
+type BatchThrottle struct {
+	// MaxPendingJobs is the number of unfinished LighthouseJobs of any type above
+	// which no new batches are triggered. Zero means no limit.
+	MaxPendingJobs int
+	// MaxConcurrentBatches is the maximum number of batches being tested at the
+	// same time across all repositories. Zero means no limit.
+	MaxConcurrentBatches int
+
+	pendingJobs    int
+	pendingBatches int
+	sync.Mutex
+}
+
+// NewBatchThrottle creates a BatchThrottle. It returns nil if neither limit is
+// specified, which disables throttling.
+func NewBatchThrottle(maxPendingJobs, maxConcurrentBatches int) *BatchThrottle {
+	if maxPendingJobs <= 0 && maxConcurrentBatches <= 0 {
+		return nil
+	}
+	return &BatchThrottle{
+		MaxPendingJobs:       maxPendingJobs,
+		MaxConcurrentBatches: maxConcurrentBatches,
+	}
+}
+
+// Observe counts the unfinished jobs and batches in the given LighthouseJobs
+func (t *BatchThrottle) Observe(jobs []v1alpha1.LighthouseJob) {
+	pendingJobs := 0
+	batches := sets.NewString()
+	for _, job := range jobs {
+		if !isUnfinished(job.Status.State) {
+			continue
+		}
+		pendingJobs++
+		if job.Spec.Type == config.BatchJob && job.Spec.Refs != nil {
+			batches.Insert(job.Spec.Refs.String())
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.pendingJobs = pendingJobs
+	t.pendingBatches = batches.Len()
+}
+
+// Acquire returns nil and reserves capacity if a batch of the given number of
+// jobs can be triggered now, otherwise an error describing why it is deferred.
+func (t *BatchThrottle) Acquire(jobs int) error {
+	t.Lock()
+	defer t.Unlock()
+	if t.MaxPendingJobs > 0 && t.pendingJobs >= t.MaxPendingJobs {
+		return fmt.Errorf("%d LighthouseJobs are pending, the limit for triggering batches is %d", t.pendingJobs, t.MaxPendingJobs)
+	}
+	if t.MaxConcurrentBatches > 0 && t.pendingBatches >= t.MaxConcurrentBatches {
+		return fmt.Errorf("%d batches are pending, the limit is %d", t.pendingBatches, t.MaxConcurrentBatches)
+	}
+	t.pendingJobs += jobs
+	t.pendingBatches++
+	return nil
+}
+
+// isUnfinished returns true if a job in the given state is waiting for or using cluster capacity
+func isUnfinished(state v1alpha1.PipelineState) bool {
+	switch state {
+	case v1alpha1.TriggeredState, v1alpha1.QueuedState, v1alpha1.PendingState, v1alpha1.RunningState, "":
+		return true
+	}
+	return false
+}
+
+// batchThrottleAllows returns true if there is no batch throttle or the batch
+// throttle allows triggering the batch.
+func (c *DefaultController) batchThrottleAllows(sp subpool, batch []PullRequest) bool {
+	if c.batchThrottle == nil {
+		return true
+	}
+	contexts := sets.NewString()
+	for _, pr := range batch {
+		for _, ps := range sp.presubmits[int(pr.Number)] {
+			contexts.Insert(ps.Context)
+		}
+	}
+	if err := c.batchThrottle.Acquire(contexts.Len()); err != nil {
+		sp.log.WithError(err).Info("Cluster saturated, deferring batch.")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchThrottle(t *testing.T) {
+	assert.Nil(t, NewBatchThrottle(0, 0))
+
+	batchRefs := &v1alpha1.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "123", Pulls: []v1alpha1.Pull{{Number: 1, SHA: "a"}, {Number: 2, SHA: "b"}}}
+	jobs := []v1alpha1.LighthouseJob{
+		{Spec: v1alpha1.LighthouseJobSpec{Type: config.BatchJob, Refs: batchRefs}, Status: v1alpha1.LighthouseJobStatus{State: v1alpha1.RunningState}},
+		{Spec: v1alpha1.LighthouseJobSpec{Type: config.BatchJob, Refs: batchRefs}, Status: v1alpha1.LighthouseJobStatus{State: v1alpha1.PendingState}},
+		{Spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob}, Status: v1alpha1.LighthouseJobStatus{State: v1alpha1.TriggeredState}},
+		{Spec: v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob}, Status: v1alpha1.LighthouseJobStatus{State: v1alpha1.SuccessState}},
+	}
+
+	throttle := NewBatchThrottle(0, 2)
+	throttle.Observe(jobs)
+	assert.NoError(t, throttle.Acquire(2))
+	assert.Error(t, throttle.Acquire(2), "the second batch reaches the limit")
+
+	throttle = NewBatchThrottle(5, 0)
+	throttle.Observe(jobs)
+	assert.NoError(t, throttle.Acquire(2))
+	assert.Error(t, throttle.Acquire(1), "3 pending jobs plus the 2 just triggered reach the limit")
+
+	throttle.Observe(jobs[3:])
+	assert.NoError(t, throttle.Acquire(1), "capacity is recalculated on every sync")
+}
@@ -24,6 +24,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 	githubql "github.com/shurcooL/githubv4"
 
 	"github.com/sirupsen/logrus"
@@ -37,6 +39,10 @@ type scmProviderClient interface {
 	Query(context.Context, interface{}, map[string]interface{}) error
 }
 
+type searchClient interface {
+	SearchAll(scmprovider.SearchQuery) ([]*scm.SearchIssue, error)
+}
+
 // Blocker specifies an issue number that should block keeper from merging.
 type Blocker struct {
 	Number     int
@@ -87,6 +93,37 @@ func FindAll(spc scmProviderClient, log *logrus.Entry, label, orgRepoTokens stri
 	return fromIssues(issues, log), nil
 }
 
+// FindAllInRepos finds issues with label in the specified repos that should block keeper, using the
+// search of the providers without a GraphQL API
+func FindAllInRepos(spc searchClient, log *logrus.Entry, label string, repos []OrgRepo) (Blockers, error) {
+	var issues []Issue
+	for _, repo := range repos {
+		results, err := spc.SearchAll(scmprovider.SearchQuery{
+			Open:   true,
+			Org:    repo.Org,
+			Repo:   repo.Repo,
+			Labels: []string{label},
+		})
+		if err != nil {
+			return Blockers{}, fmt.Errorf("error searching for blocker issues of %s/%s: %v", repo.Org, repo.Repo, err)
+		}
+		for _, result := range results {
+			if result.PullRequest {
+				continue
+			}
+			issue := Issue{
+				Number: githubql.Int(result.Number),
+				Title:  githubql.String(result.Title),
+				URL:    githubql.String(result.Link),
+			}
+			issue.Repository.Name = githubql.String(repo.Repo)
+			issue.Repository.Owner.Login = githubql.String(repo.Org)
+			issues = append(issues, issue)
+		}
+	}
+	return fromIssues(issues, log), nil
+}
+
 func fromIssues(issues []Issue, log *logrus.Entry) Blockers {
 	log.Debugf("Finding blockers from %d issues.", len(issues))
 	res := Blockers{Repo: make(map[OrgRepo][]Blocker), Branch: make(map[OrgRepoBranch][]Blocker)}
@@ -22,6 +22,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 	githubql "github.com/shurcooL/githubv4"
 
 	"github.com/sirupsen/logrus"
@@ -430,3 +432,38 @@ func TestBlockers(t *testing.T) {
 		}
 	}
 }
+
+type fakeSearchClient struct {
+	queries []scmprovider.SearchQuery
+	results map[string][]*scm.SearchIssue
+}
+
+func (f *fakeSearchClient) SearchAll(q scmprovider.SearchQuery) ([]*scm.SearchIssue, error) {
+	f.queries = append(f.queries, q)
+	return f.results[q.Org+"/"+q.Repo], nil
+}
+
+func TestFindAllInRepos(t *testing.T) {
+	client := &fakeSearchClient{
+		results: map[string][]*scm.SearchIssue{
+			"k/t-i": {
+				{Issue: scm.Issue{Number: 5, Title: "BLOCK THE WHOLE REPO!"}},
+				{Issue: scm.Issue{Number: 6, Title: "a pull request", PullRequest: true}},
+				{Issue: scm.Issue{Number: 7, Title: "branch:release-1.0"}},
+			},
+		},
+	}
+	b, err := FindAllInRepos(client, logrus.WithField("test", t.Name()), "blocker", []OrgRepo{{Org: "k", Repo: "t-i"}, {Org: "k", Repo: "other"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(client.queries) != 2 || !client.queries[0].Open || !reflect.DeepEqual(client.queries[0].Labels, []string{"blocker"}) {
+		t.Errorf("unexpected queries %v", client.queries)
+	}
+	if got := b.GetApplicable("k", "t-i", "master"); len(got) != 1 || got[0].Number != 5 {
+		t.Errorf("expected issue 5 to block master, got %v", got)
+	}
+	if got := b.GetApplicable("k", "t-i", "release-1.0"); len(got) != 2 {
+		t.Errorf("expected issues 5 and 7 to block release-1.0, got %v", got)
+	}
+}
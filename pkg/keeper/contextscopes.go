@@ -0,0 +1,145 @@
+package keeper
+
+import (
+	"sort"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/messages"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// The IDs of the status descriptions of the context scopes in the message catalog.
+	scopeSucceededMessage = "keeper.status.scopeSucceeded"
+	scopeFailedMessage    = "keeper.status.scopeFailed"
+	scopeUnchangedMessage = "keeper.status.scopeUnchanged"
+)
+
+// scopedContextChecker returns the context checker of the repository, which scopes the contexts of
+// PRs to the files they change, or cc if the repository has no scopes
+func scopedContextChecker(scopes settings.ContextScopes, org, repo string, cc contextChecker, changes func(*PullRequest) config.ChangedFilesProvider) contextChecker {
+	repoScopes := scopes.ForRepo(org, repo)
+	if len(repoScopes) == 0 || cc == nil {
+		return cc
+	}
+	return &repoScopedContextChecker{contextChecker: cc, scopes: repoScopes, changes: changes}
+}
+
+// prContextChecker is implemented by the context checkers which depend on the files changed by a PR
+type prContextChecker interface {
+	forPR(pr *PullRequest) contextChecker
+}
+
+// contextCheckerForPR returns the context checker for the PR, scoped to the files it changes if
+// context scopes are configured for its repository
+func contextCheckerForPR(cc contextChecker, pr *PullRequest) contextChecker {
+	if scoped, ok := cc.(prContextChecker); ok {
+		return scoped.forPR(pr)
+	}
+	return cc
+}
+
+// repoScopedContextChecker is the context checker of a repository with context scopes. Used directly,
+// without a PR, it behaves like the context checker it wraps.
+type repoScopedContextChecker struct {
+	contextChecker
+	scopes  []settings.ContextScope
+	changes func(*PullRequest) config.ChangedFilesProvider
+}
+
+func (c *repoScopedContextChecker) forPR(pr *PullRequest) contextChecker {
+	answer := &prScopedContextChecker{contextChecker: c.contextChecker}
+	files, err := c.changes(pr)()
+	if err != nil {
+		// without the changed files all the scopes remain required
+		logrus.WithFields(pr.logFields()).WithError(err).Warn("Failed to get the changed files to scope the contexts, requiring the contexts of all the scopes.")
+		return answer
+	}
+	for i := range c.scopes {
+		scope := &c.scopes[i]
+		if scope.MatchesFiles(files) {
+			answer.inScope = append(answer.inScope, scope)
+		} else {
+			answer.outOfScope = append(answer.outOfScope, scope)
+		}
+	}
+	return answer
+}
+
+// prScopedContextChecker treats the contexts of the scopes a PR does not touch as optional
+type prScopedContextChecker struct {
+	contextChecker
+	inScope    []*settings.ContextScope
+	outOfScope []*settings.ContextScope
+}
+
+// isOutOfScope returns true if the context only belongs to scopes the PR does not touch
+func (c *prScopedContextChecker) isOutOfScope(context string) bool {
+	for _, s := range c.inScope {
+		if s.HasContext(context) {
+			return false
+		}
+	}
+	for _, s := range c.outOfScope {
+		if s.HasContext(context) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *prScopedContextChecker) IsOptional(context string) bool {
+	return c.isOutOfScope(context) || c.contextChecker.IsOptional(context)
+}
+
+func (c *prScopedContextChecker) MissingRequiredContexts(contexts []string) []string {
+	var missing []string
+	for _, context := range c.contextChecker.MissingRequiredContexts(contexts) {
+		if !c.isOutOfScope(context) {
+			missing = append(missing, context)
+		}
+	}
+	return missing
+}
+
+// scopeStatuses returns the statuses of the context scopes of the PR, named after the status context
+// of keeper and the scope, e.g. keeper/service-a. The scopes the PR changes are pending until their
+// required contexts succeeded, the other scopes are successful as their contexts are not required.
+// No statuses are returned if the repository has no scopes or if the files changed by the PR are unknown.
+func scopeStatuses(cc contextChecker, pr *PullRequest, contexts []Context, log *logrus.Entry) []*scmprovider.Status {
+	scoped, ok := contextCheckerForPR(cc, pr).(*prScopedContextChecker)
+	if !ok || (len(scoped.inScope) == 0 && len(scoped.outOfScope) == 0) {
+		return nil
+	}
+	unsuccessful := unsuccessfulContexts(contexts, scoped, log)
+	var statuses []*scmprovider.Status
+	for _, scope := range scoped.inScope {
+		var failed []string
+		for _, ctx := range unsuccessful {
+			if scope.HasContext(string(ctx.Context)) {
+				failed = append(failed, string(ctx.Context))
+			}
+		}
+		status := &scmprovider.Status{
+			Context:     scope.StatusContext(GetStatusContextLabel()),
+			State:       scmprovider.StatusSuccess,
+			Description: messages.Render(scopeSucceededMessage, "The required jobs of the scope succeeded.", nil),
+		}
+		if len(failed) > 0 {
+			sort.Strings(failed)
+			status.State = scmprovider.StatusPending
+			status.Description = messages.Render(scopeFailedMessage, "{{ if gt .Count 1 }}Jobs {{ .Jobs }} have{{ else }}Job {{ .Jobs }} has{{ end }} not succeeded.", listData("Jobs", truncate(failed)))
+		}
+		statuses = append(statuses, status)
+	}
+	for _, scope := range scoped.outOfScope {
+		statuses = append(statuses, &scmprovider.Status{
+			Context:     scope.StatusContext(GetStatusContextLabel()),
+			State:       scmprovider.StatusSuccess,
+			Description: messages.Render(scopeUnchangedMessage, "Not required as the pull request does not change the scope.", nil),
+		})
+	}
+	return statuses
+}
@@ -0,0 +1,104 @@
+package keeper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopedContextChecker(t *testing.T) {
+	scopes := settings.ContextScopes{
+		"org/monorepo": {
+			{Name: "service-a", Paths: []string{"services/a/**"}},
+			{Name: "service-b", Paths: []string{"services/b/**", "go.mod"}, Contexts: []string{"unit-b"}},
+		},
+	}
+	policy := &config.KeeperContextPolicy{
+		RequiredContexts: []string{"lint", "service-a/unit", "unit-b"},
+	}
+	files := map[int][]string{}
+	changes := func(pr *PullRequest) config.ChangedFilesProvider {
+		return func() ([]string, error) {
+			if f, ok := files[int(pr.Number)]; ok {
+				return f, nil
+			}
+			return nil, errors.New("unknown PR")
+		}
+	}
+
+	assert.Equal(t, policy, scopedContextChecker(scopes, "org", "other", policy, changes), "repositories without scopes use the policy")
+	cc := scopedContextChecker(scopes, "org", "monorepo", policy, changes)
+	assert.False(t, cc.IsOptional("unit-b"), "without a PR all the scopes are required")
+
+	files[1] = []string{"services/a/main.go"}
+	files[2] = []string{"services/a/main.go", "go.mod"}
+	files[3] = []string{"README.md"}
+
+	pr1 := contextCheckerForPR(cc, &PullRequest{Number: 1})
+	assert.False(t, pr1.IsOptional("lint"))
+	assert.False(t, pr1.IsOptional("service-a/unit"))
+	assert.True(t, pr1.IsOptional("unit-b"))
+	assert.Equal(t, []string{"service-a/unit"}, pr1.MissingRequiredContexts([]string{"lint"}))
+
+	pr2 := contextCheckerForPR(cc, &PullRequest{Number: 2})
+	assert.False(t, pr2.IsOptional("unit-b"))
+	assert.ElementsMatch(t, []string{"service-a/unit", "unit-b"}, pr2.MissingRequiredContexts([]string{"lint"}))
+
+	pr3 := contextCheckerForPR(cc, &PullRequest{Number: 3})
+	assert.True(t, pr3.IsOptional("service-a/unit"))
+	assert.True(t, pr3.IsOptional("unit-b"))
+	assert.Equal(t, []string{"lint"}, pr3.MissingRequiredContexts(nil))
+	assert.False(t, pr3.IsOptional("lint"))
+
+	unknown := contextCheckerForPR(cc, &PullRequest{Number: 4})
+	assert.False(t, unknown.IsOptional("unit-b"), "the scopes of PRs whose changes are unknown are required")
+}
+
+func TestScopeStatuses(t *testing.T) {
+	scopes := settings.ContextScopes{
+		"org/monorepo": {
+			{Name: "service-a", Paths: []string{"services/a/**"}},
+			{Name: "service-b", Paths: []string{"services/b/**"}},
+		},
+	}
+	policy := &config.KeeperContextPolicy{
+		RequiredContexts: []string{"lint", "service-a/unit", "service-a/e2e", "service-b/unit"},
+	}
+	changes := func(pr *PullRequest) config.ChangedFilesProvider {
+		return func() ([]string, error) {
+			if pr.Number == 1 {
+				return []string{"services/a/main.go"}, nil
+			}
+			return nil, errors.New("unknown PR")
+		}
+	}
+	log := logrus.WithField("test", t.Name())
+	contexts := []Context{
+		{Context: "lint", State: githubql.StatusStateFailure},
+		{Context: "service-a/unit", State: githubql.StatusStateSuccess},
+	}
+
+	assert.Nil(t, scopeStatuses(policy, &PullRequest{Number: 1}, contexts, log), "repositories without scopes have no scope statuses")
+	cc := scopedContextChecker(scopes, "org", "monorepo", policy, changes)
+	assert.Nil(t, scopeStatuses(cc, &PullRequest{Number: 2}, contexts, log), "the scopes of PRs whose changes are unknown are not reported")
+
+	statuses := scopeStatuses(cc, &PullRequest{Number: 1}, contexts, log)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, GetStatusContextLabel()+"/service-a", statuses[0].Context)
+	assert.Equal(t, scmprovider.StatusPending, statuses[0].State, "the failing lint context does not belong to the scope")
+	assert.Equal(t, "Job service-a/e2e has not succeeded.", statuses[0].Description)
+	assert.Equal(t, GetStatusContextLabel()+"/service-b", statuses[1].Context)
+	assert.Equal(t, scmprovider.StatusSuccess, statuses[1].State)
+
+	contexts = append(contexts, Context{Context: "service-a/e2e", State: githubql.StatusStateSuccess})
+	statuses = scopeStatuses(cc, &PullRequest{Number: 1}, contexts, log)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, scmprovider.StatusSuccess, statuses[0].State)
+}
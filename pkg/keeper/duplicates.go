@@ -0,0 +1,222 @@
+package keeper
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/sirupsen/logrus"
+)
+
+// DuplicateJobsPath is the path of the duplicate jobs report endpoint, which requires the admin token
+const DuplicateJobsPath = "/duplicates"
+
+// DuplicateJobTracker tracks how often the same job is run more than once for the same
+// commits of a repository, e.g. because of retests or duplicate events, so that platform
+// teams can quantify the CI capacity spent on reruns and tune their retest policies.
+//
+// The LighthouseJobs are observed at every sync and remembered for a configurable window,
+// so that runs are still counted once their jobs have been garbage collected. A single
+// DuplicateJobTracker is meant to be shared between all the keeper controllers of a process.
+type DuplicateJobTracker struct {
+	window time.Duration
+	now    func() time.Time
+	runs   map[jobRunKey]map[string]*jobRun
+	sync.Mutex
+}
+
+// jobRunKey identifies the runs of a job for the same commits
+type jobRunKey struct {
+	org  string
+	repo string
+	job  string
+	typ  string
+	shas string
+}
+
+// jobRun is a run of a job
+type jobRun struct {
+	created  time.Time
+	duration time.Duration
+}
+
+// DuplicateJobReport summarizes the jobs which ran more than once for the same commits
+type DuplicateJobReport struct {
+	// Window is the period the report covers
+	Window string `json:"window"`
+	// Runs is the number of job runs observed
+	Runs int `json:"runs"`
+	// DuplicateRuns is the number of runs of jobs which already ran for the same commits
+	DuplicateRuns int `json:"duplicateRuns"`
+	// DuplicateSeconds is the total duration of the duplicate runs
+	DuplicateSeconds float64 `json:"duplicateSeconds"`
+	// Jobs are the jobs which ran more than once for the same commits, most run first
+	Jobs []DuplicateJob `json:"jobs"`
+}
+
+// DuplicateJob is a job which ran more than once for the same commits
+type DuplicateJob struct {
+	Org  string `json:"org"`
+	Repo string `json:"repo"`
+	Job  string `json:"job"`
+	Type string `json:"type"`
+	// SHAs are the pull request commits the job ran for, or the base commit if there are none
+	SHAs string `json:"shas"`
+	// Runs is the number of times the job ran
+	Runs int `json:"runs"`
+	// DuplicateSeconds is the total duration of the runs after the first one
+	DuplicateSeconds float64   `json:"duplicateSeconds"`
+	LastRun          time.Time `json:"lastRun"`
+}
+
+// NewDuplicateJobTracker creates a DuplicateJobTracker remembering the runs of the given
+// window. It returns nil if the window is not positive, which disables tracking.
+func NewDuplicateJobTracker(window time.Duration) *DuplicateJobTracker {
+	if window <= 0 {
+		return nil
+	}
+	return &DuplicateJobTracker{
+		window: window,
+		now:    time.Now,
+		runs:   map[jobRunKey]map[string]*jobRun{},
+	}
+}
+
+// Observe records the runs of the given LighthouseJobs, counting the new duplicate runs
+// in the metrics, and forgets the runs older than the window
+func (t *DuplicateJobTracker) Observe(jobs []v1alpha1.LighthouseJob) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	cutoff := t.now().Add(-t.window)
+	for i := range jobs {
+		job := &jobs[i]
+		key, ok := jobRunKeyFor(job)
+		if !ok || job.CreationTimestamp.Time.Before(cutoff) {
+			continue
+		}
+		runs := t.runs[key]
+		if runs == nil {
+			runs = map[string]*jobRun{}
+			t.runs[key] = runs
+		}
+		run := runs[job.Name]
+		if run == nil {
+			run = &jobRun{created: job.CreationTimestamp.Time}
+			runs[job.Name] = run
+			if len(runs) > 1 {
+				keeperMetrics.duplicateJobRuns.WithLabelValues(key.org, key.repo, key.job).Inc()
+			}
+		}
+		if job.Status.StartTime.IsZero() || job.Status.CompletionTime == nil {
+			continue
+		}
+		run.duration = job.Status.CompletionTime.Sub(job.Status.StartTime.Time)
+	}
+
+	for key, runs := range t.runs {
+		for name, run := range runs {
+			if run.created.Before(cutoff) {
+				delete(runs, name)
+			}
+		}
+		if len(runs) == 0 {
+			delete(t.runs, key)
+		}
+	}
+}
+
+// Report returns the report of the jobs which ran more than once for the same commits.
+// If org is not empty, only the jobs of its repositories, or of repo if not empty, are included.
+func (t *DuplicateJobTracker) Report(org, repo string) DuplicateJobReport {
+	t.Lock()
+	defer t.Unlock()
+	report := DuplicateJobReport{Window: t.window.String(), Jobs: []DuplicateJob{}}
+	for key, runs := range t.runs {
+		if (org != "" && key.org != org) || (repo != "" && key.repo != repo) {
+			continue
+		}
+		report.Runs += len(runs)
+		if len(runs) < 2 {
+			continue
+		}
+		sorted := make([]*jobRun, 0, len(runs))
+		for _, run := range runs {
+			sorted = append(sorted, run)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].created.Before(sorted[j].created)
+		})
+		duplicate := DuplicateJob{
+			Org:     key.org,
+			Repo:    key.repo,
+			Job:     key.job,
+			Type:    key.typ,
+			SHAs:    key.shas,
+			Runs:    len(runs),
+			LastRun: sorted[len(sorted)-1].created,
+		}
+		for _, run := range sorted[1:] {
+			duplicate.DuplicateSeconds += run.duration.Seconds()
+		}
+		report.DuplicateRuns += len(runs) - 1
+		report.DuplicateSeconds += duplicate.DuplicateSeconds
+		report.Jobs = append(report.Jobs, duplicate)
+	}
+	sort.Slice(report.Jobs, func(i, j int) bool {
+		a, b := report.Jobs[i], report.Jobs[j]
+		if a.Runs != b.Runs {
+			return a.Runs > b.Runs
+		}
+		return a.LastRun.After(b.LastRun)
+	})
+	return report
+}
+
+// ServeHTTP serves the report of the duplicate jobs as JSON, filtered by the optional
+// org and repo query parameters
+func (t *DuplicateJobTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if t == nil {
+		http.Error(w, "duplicate job tracking is not enabled", http.StatusNotFound)
+		return
+	}
+	report := t.Report(r.URL.Query().Get("org"), r.URL.Query().Get("repo"))
+	b, err := json.Marshal(report)
+	if err != nil {
+		logrus.WithError(err).Error("Error marshaling the duplicate jobs report.")
+		b = []byte("{}")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(b); err != nil {
+		logrus.WithError(err).Debug("Error writing the duplicate jobs report.")
+	}
+}
+
+// jobRunKeyFor returns the key of the runs of the job, if it has refs
+func jobRunKeyFor(job *v1alpha1.LighthouseJob) (jobRunKey, bool) {
+	refs := job.Spec.Refs
+	if refs == nil || refs.Org == "" || refs.Repo == "" {
+		return jobRunKey{}, false
+	}
+	// the pull request commits are tested whatever the base commit they are merged into
+	shas := []string{refs.BaseSHA}
+	if len(refs.Pulls) > 0 {
+		shas = nil
+		for _, pull := range refs.Pulls {
+			shas = append(shas, pull.SHA)
+		}
+	}
+	return jobRunKey{
+		org:  refs.Org,
+		repo: refs.Repo,
+		job:  job.Spec.Job,
+		typ:  string(job.Spec.Type),
+		shas: strings.Join(shas, ","),
+	}, true
+}
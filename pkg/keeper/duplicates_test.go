@@ -0,0 +1,92 @@
+package keeper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func duplicateTestJob(name, repo, sha string, created time.Time, duration time.Duration) v1alpha1.LighthouseJob {
+	job := v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)},
+		Spec: v1alpha1.LighthouseJobSpec{
+			Type: config.PresubmitJob,
+			Job:  "unit",
+			Refs: &v1alpha1.Refs{Org: "org", Repo: repo, BaseSHA: "base-" + name, Pulls: []v1alpha1.Pull{{Number: 1, SHA: sha}}},
+		},
+	}
+	if duration > 0 {
+		job.Status.StartTime = metav1.NewTime(created)
+		completion := metav1.NewTime(created.Add(duration))
+		job.Status.CompletionTime = &completion
+	}
+	return job
+}
+
+func TestDuplicateJobTracker(t *testing.T) {
+	var disabled *DuplicateJobTracker
+	assert.Nil(t, NewDuplicateJobTracker(0))
+	disabled.Observe([]v1alpha1.LighthouseJob{{}})
+	rr := httptest.NewRecorder()
+	disabled.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, DuplicateJobsPath, nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	now := time.Now()
+	tracker := NewDuplicateJobTracker(time.Hour)
+	tracker.now = func() time.Time { return now }
+	counter := keeperMetrics.duplicateJobRuns.WithLabelValues("org", "dupes", "unit")
+	before := testutil.ToFloat64(counter)
+
+	jobs := []v1alpha1.LighthouseJob{
+		duplicateTestJob("first", "dupes", "abc", now.Add(-30*time.Minute), 10*time.Minute),
+		duplicateTestJob("retest", "dupes", "abc", now.Add(-20*time.Minute), 0),
+		duplicateTestJob("other", "dupes", "def", now.Add(-20*time.Minute), 5*time.Minute),
+		duplicateTestJob("expired", "dupes", "abc", now.Add(-2*time.Hour), time.Minute),
+		duplicateTestJob("single", "another", "abc", now.Add(-time.Minute), 0),
+	}
+	tracker.Observe(jobs)
+	// the retest completes and is observed again
+	jobs[1] = duplicateTestJob("retest", "dupes", "abc", now.Add(-20*time.Minute), 8*time.Minute)
+	tracker.Observe(jobs)
+	assert.Equal(t, before+1, testutil.ToFloat64(counter), "each duplicate run is counted once")
+
+	report := tracker.Report("", "")
+	assert.Equal(t, "1h0m0s", report.Window)
+	assert.Equal(t, 4, report.Runs)
+	assert.Equal(t, 1, report.DuplicateRuns)
+	assert.Equal(t, 480.0, report.DuplicateSeconds)
+	require.Len(t, report.Jobs, 1)
+	assert.Equal(t, DuplicateJob{
+		Org:              "org",
+		Repo:             "dupes",
+		Job:              "unit",
+		Type:             "presubmit",
+		SHAs:             "abc",
+		Runs:             2,
+		DuplicateSeconds: 480,
+		LastRun:          now.Add(-20 * time.Minute),
+	}, report.Jobs[0])
+	assert.Empty(t, tracker.Report("org", "another").Jobs)
+
+	rr = httptest.NewRecorder()
+	tracker.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, DuplicateJobsPath+"?org=org&repo=dupes", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	served := DuplicateJobReport{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &served))
+	assert.Equal(t, 3, served.Runs)
+	assert.Len(t, served.Jobs, 1)
+
+	// runs are forgotten once they are older than the window
+	now = now.Add(time.Hour)
+	tracker.Observe(nil)
+	assert.Equal(t, 0, tracker.Report("", "").Runs)
+}
@@ -0,0 +1,131 @@
+package keeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// EffectiveQueryPath is the path of the keeper endpoint describing the effective
+// keeper configuration of a repository
+const EffectiveQueryPath = "/effective"
+
+// EffectiveQuery describes how keeper manages the pull requests of a repository and
+// branch so that repository maintainers can find out why keeper does, or does not,
+// merge their pull requests without reading the central configuration
+type EffectiveQuery struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch,omitempty"`
+	// Managed is true if at least one keeper query applies to the repository and branch
+	Managed bool `json:"managed"`
+	// Reason explains why keeper does not manage the repository and branch
+	Reason string `json:"reason,omitempty"`
+	// Queries are the keeper queries which apply to the repository and branch
+	Queries config.KeeperQueries `json:"queries,omitempty"`
+	// RequiredLabels are the labels a pull request needs for one of the queries to match it
+	RequiredLabels [][]string `json:"requiredLabels,omitempty"`
+	// ForbiddenLabels are the labels which prevent the queries from matching a pull request
+	ForbiddenLabels [][]string `json:"forbiddenLabels,omitempty"`
+	// RequiredContexts are the status contexts which must succeed before merging
+	RequiredContexts []string `json:"requiredContexts,omitempty"`
+	// RequiredIfPresentContexts are the status contexts which must succeed if they are reported
+	RequiredIfPresentContexts []string `json:"requiredIfPresentContexts,omitempty"`
+	// OptionalContexts are the status contexts which are ignored
+	OptionalContexts []string `json:"optionalContexts,omitempty"`
+	// MergeMethod is the method used to merge the pull requests
+	MergeMethod config.PullRequestMergeType `json:"mergeMethod"`
+}
+
+// NewEffectiveQuery returns the effective keeper configuration of the repository and branch.
+// An empty branch returns the queries applying to any branch of the repository.
+func NewEffectiveQuery(cfg *config.Config, org, repo, branch string) (*EffectiveQuery, error) {
+	answer := &EffectiveQuery{
+		Org:         org,
+		Repo:        repo,
+		Branch:      branch,
+		MergeMethod: cfg.Keeper.MergeMethod(org, repo),
+	}
+	repoQueries := cfg.Keeper.Queries.QueryMap().ForRepo(org, repo)
+	for _, q := range repoQueries {
+		if branch != "" && !queryAppliesToBranch(q, branch) {
+			continue
+		}
+		answer.Queries = append(answer.Queries, q)
+		answer.RequiredLabels = append(answer.RequiredLabels, q.Labels)
+		answer.ForbiddenLabels = append(answer.ForbiddenLabels, q.MissingLabels)
+	}
+	answer.Managed = len(answer.Queries) > 0
+	switch {
+	case len(repoQueries) == 0:
+		answer.Reason = fmt.Sprintf("no keeper query includes the repository %s", scm.Join(org, repo))
+	case !answer.Managed:
+		answer.Reason = fmt.Sprintf("the keeper queries of the repository %s exclude the branch %s", scm.Join(org, repo), branch)
+	}
+	if branch != "" {
+		policy, err := cfg.GetKeeperContextPolicy(org, repo, branch)
+		if err != nil {
+			return answer, err
+		}
+		answer.RequiredContexts = policy.RequiredContexts
+		answer.RequiredIfPresentContexts = policy.RequiredIfPresentContexts
+		answer.OptionalContexts = policy.OptionalContexts
+	}
+	return answer, nil
+}
+
+// queryAppliesToBranch returns true if the included and excluded branches of the query allow the branch
+func queryAppliesToBranch(q config.KeeperQuery, branch string) bool {
+	if len(q.IncludedBranches) > 0 {
+		return sets.NewString(q.IncludedBranches...).Has(branch)
+	}
+	return !sets.NewString(q.ExcludedBranches...).Has(branch)
+}
+
+// EffectiveQueryHandler serves the effective keeper configuration of the repository given
+// by the org, repo and branch query parameters, where repo may also be given as org/repo
+type EffectiveQueryHandler struct {
+	config config.Getter
+	logger *logrus.Entry
+}
+
+// NewEffectiveQueryHandler creates an EffectiveQueryHandler using the latest configuration
+func NewEffectiveQueryHandler(cfg config.Getter) *EffectiveQueryHandler {
+	return &EffectiveQueryHandler{
+		config: cfg,
+		logger: logrus.WithField("controller", "effective-query"),
+	}
+}
+
+func (h *EffectiveQueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	org, repo, branch := params.Get("org"), params.Get("repo"), params.Get("branch")
+	if org == "" && strings.Contains(repo, "/") {
+		org, repo = scm.Split(repo)
+	}
+	if org == "" || repo == "" {
+		http.Error(w, "the org and repo query parameters are required, e.g. ?repo=org/repo&branch=master", http.StatusBadRequest)
+		return
+	}
+	cfg := h.config()
+	if cfg == nil {
+		http.Error(w, "no configuration loaded", http.StatusServiceUnavailable)
+		return
+	}
+	answer, err := NewEffectiveQuery(cfg, org, repo, branch)
+	if err != nil {
+		h.logger.WithError(err).Errorf("Error getting the context policy of %s/%s:%s.", org, repo, branch)
+		http.Error(w, fmt.Sprintf("failed to get the context policy: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(answer); err != nil {
+		h.logger.WithError(err).Error("Writing JSON response.")
+	}
+}
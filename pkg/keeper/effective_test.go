@@ -0,0 +1,73 @@
+package keeper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func effectiveTestConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Keeper.Queries = config.KeeperQueries{
+		{Orgs: []string{"org"}, ExcludedRepos: []string{"org/excluded"}, ExcludedBranches: []string{"gh-pages"}, Labels: []string{"approved"}, MissingLabels: []string{"do-not-merge/hold"}},
+		{Repos: []string{"org/repo"}, IncludedBranches: []string{"release"}, Labels: []string{"lgtm"}},
+	}
+	cfg.Keeper.MergeType = map[string]config.PullRequestMergeType{"org/repo": config.MergeSquash}
+	required := []string{"ci/build"}
+	cfg.Keeper.ContextOptions.RequiredContexts = required
+	return cfg
+}
+
+func TestNewEffectiveQuery(t *testing.T) {
+	cfg := effectiveTestConfig()
+
+	actual, err := NewEffectiveQuery(cfg, "org", "repo", "master")
+	require.NoError(t, err)
+	assert.True(t, actual.Managed)
+	assert.Empty(t, actual.Reason)
+	assert.Len(t, actual.Queries, 1)
+	assert.Equal(t, [][]string{{"approved"}}, actual.RequiredLabels)
+	assert.Equal(t, [][]string{{"do-not-merge/hold"}}, actual.ForbiddenLabels)
+	assert.Equal(t, []string{"ci/build"}, actual.RequiredContexts)
+	assert.Equal(t, config.MergeSquash, actual.MergeMethod)
+
+	actual, err = NewEffectiveQuery(cfg, "org", "repo", "release")
+	require.NoError(t, err)
+	assert.Len(t, actual.Queries, 2)
+
+	actual, err = NewEffectiveQuery(cfg, "org", "repo", "gh-pages")
+	require.NoError(t, err)
+	assert.False(t, actual.Managed)
+	assert.Contains(t, actual.Reason, "exclude the branch gh-pages")
+
+	actual, err = NewEffectiveQuery(cfg, "org", "excluded", "master")
+	require.NoError(t, err)
+	assert.False(t, actual.Managed)
+	assert.Contains(t, actual.Reason, "no keeper query includes the repository org/excluded")
+	assert.Equal(t, config.MergeMerge, actual.MergeMethod)
+}
+
+func TestEffectiveQueryHandler(t *testing.T) {
+	cfg := effectiveTestConfig()
+	handler := NewEffectiveQueryHandler(func() *config.Config {
+		return cfg
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, EffectiveQueryPath+"?repo=org/repo&branch=master", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	actual := EffectiveQuery{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &actual))
+	assert.Equal(t, "org", actual.Org)
+	assert.Equal(t, "repo", actual.Repo)
+	assert.True(t, actual.Managed)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, EffectiveQueryPath+"?repo=repo", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,484 @@
+package keeper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/identity"
+	"github.com/jenkins-x/lighthouse/pkg/messages"
+	"github.com/jenkins-x/lighthouse/pkg/notifier"
+	"github.com/pkg/errors"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// StuckMergeable is the state of PRs with passing tests which keeper has not merged
+	StuckMergeable = "mergeable"
+	// StuckPending is the state of PRs waiting for their tests or contexts
+	StuckPending = "pending"
+
+	// EscalationFormatJSON posts the StuckPR as JSON
+	EscalationFormatJSON = "json"
+	// EscalationFormatSlack posts the text of the StuckPR as a Slack incoming webhook message
+	EscalationFormatSlack = "slack"
+
+	// stuckPRMessage is the message catalog ID of the escalation text
+	stuckPRMessage = "keeper.stuckPR"
+
+	defaultStuckPRText = `{{ .Org }}/{{ .Repo }}#{{ .Number }} ({{ .Title }}) by {{ if .AuthorHandle }}{{ .AuthorHandle }}{{ else }}{{ .Author }}{{ end }} has been {{ .State }} on {{ .Branch }} for {{ .Duration }} without being merged.
+{{- if .URL }}
+{{ .URL }}
+{{- end }}
+{{- range .Blocking }}
+- {{ . }}
+{{- end }}`
+)
+
+// StuckPR is the escalation sent for a PR which has been mergeable or pending for too long
+type StuckPR struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	// AuthorHandle is the corporate handle of the author in the identity mapping, if any
+	AuthorHandle string    `json:"authorHandle,omitempty"`
+	URL          string    `json:"url,omitempty"`
+	State        string    `json:"state"`
+	Since        time.Time `json:"since"`
+	Duration     string    `json:"duration"`
+	// Blocking describes what keeper is waiting for before it merges the PR
+	Blocking []string `json:"blocking,omitempty"`
+	Text     string   `json:"text"`
+
+	key stuckKey
+}
+
+// stuckKey identifies a PR in a state, so the PR is tracked again if it changes state or is updated
+type stuckKey struct {
+	pool   string
+	number int
+	sha    string
+	state  string
+}
+
+// StuckPRWatcher escalates PRs which keeper has considered mergeable, or which have
+// been pending, for longer than a threshold by posting a notification to a webhook,
+// and the stuck PRs of each pool to the Slack notifier, so teams notice when the
+// automation silently fails to merge their PRs.
+// Each PR is escalated once per state and head commit.
+type StuckPRWatcher struct {
+	threshold time.Duration
+	url       string
+	format    string
+	client    *http.Client
+	now       func() time.Time
+	logger    *logrus.Entry
+	mapper    identity.Mapper
+	store     StuckPRStore
+	notifier  *notifier.Notifier
+
+	lock      sync.Mutex
+	loaded    bool
+	since     map[stuckKey]time.Time
+	escalated map[stuckKey]bool
+}
+
+// NewStuckPRWatcher creates a StuckPRWatcher posting to the webhook URL in the given
+// format and to the notifier, either of which may be empty. It returns nil if either
+// the threshold or both the URL and the notifier are not specified, which disables
+// the escalations.
+func NewStuckPRWatcher(threshold time.Duration, url, format string, n *notifier.Notifier) (*StuckPRWatcher, error) {
+	if threshold <= 0 || (url == "" && n == nil) {
+		return nil, nil
+	}
+	if format == "" {
+		format = EscalationFormatJSON
+	}
+	if format != EscalationFormatJSON && format != EscalationFormatSlack {
+		return nil, errors.Errorf("unsupported escalation format %q, must be %s or %s", format, EscalationFormatJSON, EscalationFormatSlack)
+	}
+	return &StuckPRWatcher{
+		threshold: threshold,
+		url:       url,
+		format:    format,
+		notifier:  n,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		now:       time.Now,
+		logger:    logrus.WithField("controller", "stuck-prs"),
+		since:     make(map[stuckKey]time.Time),
+		escalated: make(map[stuckKey]bool),
+	}, nil
+}
+
+// SetIdentityMapper sets the mapper used to address the escalations to the corporate handles of the authors
+func (w *StuckPRWatcher) SetIdentityMapper(m identity.Mapper) {
+	if w == nil {
+		return
+	}
+	w.mapper = m
+}
+
+// SetStore sets the store persisting the tracked PRs, so that they are neither escalated again
+// nor tracked from scratch when keeper restarts
+func (w *StuckPRWatcher) SetStore(store StuckPRStore) {
+	if w == nil {
+		return
+	}
+	w.store = store
+}
+
+// Check records the state of the PRs of the pools after a sync and escalates the PRs
+// which have been in the same state for longer than the threshold. PRs whose escalation
+// fails are escalated again after the next sync.
+func (w *StuckPRWatcher) Check(pools []Pool) {
+	if w == nil {
+		return
+	}
+	w.load()
+	var escalated []StuckPR
+	for _, stuck := range w.stuck(pools) {
+		if w.url != "" {
+			if err := w.notify(stuck); err != nil {
+				w.logger.WithError(err).Errorf("Error escalating stuck PR %s/%s#%d.", stuck.Org, stuck.Repo, stuck.Number)
+				continue
+			}
+		}
+		w.markEscalated(stuck)
+		escalated = append(escalated, stuck)
+	}
+	for _, pool := range stuckPools(escalated) {
+		w.notifier.Stuck(pool)
+	}
+	w.save()
+}
+
+// stuckPools groups the escalated PRs, which are sorted by repository, into the notifications of their pools
+func stuckPools(escalated []StuckPR) []notifier.StuckPool {
+	var answer []notifier.StuckPool
+	index := map[string]int{}
+	for _, stuck := range escalated {
+		key := poolKey(stuck.Org, stuck.Repo, stuck.Branch)
+		i, ok := index[key]
+		if !ok {
+			i = len(answer)
+			index[key] = i
+			answer = append(answer, notifier.StuckPool{Org: stuck.Org, Repo: stuck.Repo, Branch: stuck.Branch})
+		}
+		answer[i].PRs = append(answer[i].PRs, notifier.StuckPR{
+			Number:   stuck.Number,
+			Title:    stuck.Title,
+			URL:      stuck.URL,
+			State:    stuck.State,
+			Duration: stuck.Duration,
+			Blocking: stuck.Blocking,
+		})
+	}
+	return answer
+}
+
+// markEscalated records that the PR was escalated in its current state
+func (w *StuckPRWatcher) markEscalated(stuck StuckPR) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.escalated[stuck.key] = true
+}
+
+// stuck updates the tracked PRs and returns the PRs to escalate
+func (w *StuckPRWatcher) stuck(pools []Pool) []StuckPR {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	now := w.now()
+	seen := make(map[stuckKey]bool)
+	var answer []StuckPR
+	track := func(pool Pool, prs []PullRequest, state string) {
+		for _, pr := range prs {
+			key := stuckKey{
+				pool:   poolKey(pool.Org, pool.Repo, pool.Branch),
+				number: int(pr.Number),
+				sha:    string(pr.HeadRefOID),
+				state:  state,
+			}
+			seen[key] = true
+			since, ok := w.since[key]
+			if !ok {
+				w.since[key] = now
+				continue
+			}
+			if w.escalated[key] || now.Sub(since) < w.threshold {
+				continue
+			}
+			stuck := newStuckPR(pool, pr, state, since, now, w.mapper)
+			stuck.key = key
+			answer = append(answer, stuck)
+		}
+	}
+	for _, pool := range pools {
+		track(pool, pool.SuccessPRs, StuckMergeable)
+		track(pool, pool.PendingPRs, StuckPending)
+	}
+	// forget the PRs which were merged, closed or changed state
+	for key := range w.since {
+		if !seen[key] {
+			delete(w.since, key)
+			delete(w.escalated, key)
+		}
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		if answer[i].Org+"/"+answer[i].Repo != answer[j].Org+"/"+answer[j].Repo {
+			return answer[i].Org+"/"+answer[i].Repo < answer[j].Org+"/"+answer[j].Repo
+		}
+		return answer[i].Number < answer[j].Number
+	})
+	return answer
+}
+
+func newStuckPR(pool Pool, pr PullRequest, state string, since, now time.Time, mapper identity.Mapper) StuckPR {
+	stuck := StuckPR{
+		Org:      pool.Org,
+		Repo:     pool.Repo,
+		Branch:   pool.Branch,
+		Number:   int(pr.Number),
+		Title:    string(pr.Title),
+		Author:   string(pr.Author.Login),
+		State:    state,
+		Since:    since,
+		Duration: now.Sub(since).Round(time.Minute).String(),
+		Blocking: blockingAnalysis(pool, pr, state),
+	}
+	stuck.AuthorHandle = identity.Handle(mapper, stuck.Author)
+	if pr.Repository.URL != "" {
+		stuck.URL = fmt.Sprintf("%s/pull/%d", strings.TrimSuffix(string(pr.Repository.URL), "/"), pr.Number)
+	}
+	stuck.Text = messages.Render(stuckPRMessage, defaultStuckPRText, stuck)
+	return stuck
+}
+
+// blockingAnalysis describes why keeper has not merged the PR yet
+func blockingAnalysis(pool Pool, pr PullRequest, state string) []string {
+	var answer []string
+	for _, b := range pool.Blockers {
+		answer = append(answer, fmt.Sprintf("the pool is blocked by issue #%d: %s", b.Number, b.Title))
+	}
+	if pool.Error != "" {
+		answer = append(answer, fmt.Sprintf("the last keeper action failed: %s", pool.Error))
+	}
+	if state == StuckPending {
+		contexts := pendingContexts(pr)
+		if len(contexts) == 0 {
+			answer = append(answer, "waiting for the LighthouseJobs of the PR to complete")
+		}
+		for _, ctx := range contexts {
+			answer = append(answer, fmt.Sprintf("context %s is %s", ctx.Context, strings.ToLower(string(ctx.State))))
+		}
+	}
+	for _, target := range pool.Target {
+		if target.Number == pr.Number {
+			answer = append(answer, fmt.Sprintf("the last keeper action on the PR was %s", pool.Action))
+			return answer
+		}
+	}
+	if pool.Action != "" {
+		answer = append(answer, fmt.Sprintf("the last keeper action of the pool was %s on %s", pool.Action, prNumbersText(pool.Target)))
+	}
+	return answer
+}
+
+// pendingContexts returns the contexts of the head commit of the PR which have not succeeded
+func pendingContexts(pr PullRequest) []Context {
+	var answer []Context
+	for _, node := range pr.Commits.Nodes {
+		if node.Commit.OID != pr.HeadRefOID {
+			continue
+		}
+		for _, ctx := range node.Commit.Status.Contexts {
+			if ctx.State != githubql.StatusStateSuccess && string(ctx.Context) != GetStatusContextLabel() {
+				answer = append(answer, ctx)
+			}
+		}
+	}
+	return answer
+}
+
+func prNumbersText(prs []PullRequest) string {
+	if len(prs) == 0 {
+		return "no PRs"
+	}
+	var numbers []string
+	for _, pr := range prs {
+		numbers = append(numbers, fmt.Sprintf("#%d", pr.Number))
+	}
+	return strings.Join(numbers, ", ")
+}
+
+// notify posts the escalation to the webhook
+func (w *StuckPRWatcher) notify(stuck StuckPR) error {
+	var payload interface{} = stuck
+	if w.format == EscalationFormatSlack {
+		payload = map[string]string{"text": stuck.Text}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal escalation")
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to post escalation")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("escalation webhook returned status %d", resp.StatusCode)
+	}
+	w.logger.WithFields(logrus.Fields{"org": stuck.Org, "repo": stuck.Repo, "pr": stuck.Number, "state": stuck.State}).Info("Escalated stuck PR.")
+	return nil
+}
+
+// StuckPRStore persists the PRs tracked by the StuckPRWatcher
+type StuckPRStore interface {
+	// Load returns the tracked PRs, which are empty if none were saved
+	Load() ([]TrackedPR, error)
+	// Save replaces the tracked PRs
+	Save([]TrackedPR) error
+}
+
+// TrackedPR is a PR tracked by the StuckPRWatcher in a state
+type TrackedPR struct {
+	Pool      string    `json:"pool"`
+	Number    int       `json:"number"`
+	SHA       string    `json:"sha"`
+	State     string    `json:"state"`
+	Since     time.Time `json:"since"`
+	Escalated bool      `json:"escalated,omitempty"`
+}
+
+// load loads the tracked PRs from the store the first time it is called. The PRs are tracked
+// from scratch if the store cannot be read.
+func (w *StuckPRWatcher) load() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.loaded || w.store == nil {
+		return
+	}
+	w.loaded = true
+	tracked, err := w.store.Load()
+	if err != nil {
+		w.logger.WithError(err).Error("Error loading the tracked stuck PRs.")
+		return
+	}
+	for _, t := range tracked {
+		key := stuckKey{pool: t.Pool, number: t.Number, sha: t.SHA, state: t.State}
+		w.since[key] = t.Since
+		if t.Escalated {
+			w.escalated[key] = true
+		}
+	}
+}
+
+// save saves the tracked PRs to the store
+func (w *StuckPRWatcher) save() {
+	if w.store == nil {
+		return
+	}
+	w.lock.Lock()
+	tracked := make([]TrackedPR, 0, len(w.since))
+	for key, since := range w.since {
+		tracked = append(tracked, TrackedPR{
+			Pool:      key.pool,
+			Number:    key.number,
+			SHA:       key.sha,
+			State:     key.state,
+			Since:     since,
+			Escalated: w.escalated[key],
+		})
+	}
+	w.lock.Unlock()
+	sort.Slice(tracked, func(i, j int) bool {
+		if tracked[i].Pool != tracked[j].Pool {
+			return tracked[i].Pool < tracked[j].Pool
+		}
+		if tracked[i].Number != tracked[j].Number {
+			return tracked[i].Number < tracked[j].Number
+		}
+		return tracked[i].State < tracked[j].State
+	})
+	if err := w.store.Save(tracked); err != nil {
+		w.logger.WithError(err).Error("Error saving the tracked stuck PRs.")
+	}
+}
+
+const (
+	// StuckPRsConfigMapName is the name of the ConfigMap where the tracked stuck PRs are saved
+	StuckPRsConfigMapName = "lighthouse-keeper-stuck-prs"
+
+	stuckPRsConfigMapKey = "tracked.json"
+)
+
+// configMapStuckPRStore saves the tracked PRs as JSON in a ConfigMap
+type configMapStuckPRStore struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+}
+
+// NewConfigMapStuckPRStore returns a store saving the tracked PRs in the StuckPRsConfigMapName
+// ConfigMap of the namespace, which is created when the PRs are first saved
+func NewConfigMapStuckPRStore(kubeClient kubernetes.Interface, namespace string) StuckPRStore {
+	return &configMapStuckPRStore{kubeClient: kubeClient, namespace: namespace}
+}
+
+func (s *configMapStuckPRStore) Load() ([]TrackedPR, error) {
+	cm, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(StuckPRsConfigMapName, metav1.GetOptions{})
+	if kubeerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get ConfigMap %s", StuckPRsConfigMapName)
+	}
+	var tracked []TrackedPR
+	if data := cm.Data[stuckPRsConfigMapKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &tracked); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse ConfigMap %s", StuckPRsConfigMapName)
+		}
+	}
+	return tracked, nil
+}
+
+func (s *configMapStuckPRStore) Save(tracked []TrackedPR) error {
+	data, err := json.Marshal(tracked)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the tracked PRs")
+	}
+	configMaps := s.kubeClient.CoreV1().ConfigMaps(s.namespace)
+	cm, err := configMaps.Get(StuckPRsConfigMapName, metav1.GetOptions{})
+	if kubeerrors.IsNotFound(err) {
+		_, err = configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: StuckPRsConfigMapName},
+			Data:       map[string]string{stuckPRsConfigMapKey: string(data)},
+		})
+		return errors.Wrapf(err, "failed to create ConfigMap %s", StuckPRsConfigMapName)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get ConfigMap %s", StuckPRsConfigMapName)
+	}
+	if cm.Data[stuckPRsConfigMapKey] == string(data) {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[stuckPRsConfigMapKey] = string(data)
+	_, err = configMaps.Update(cm)
+	return errors.Wrapf(err, "failed to update ConfigMap %s", StuckPRsConfigMapName)
+}
@@ -0,0 +1,201 @@
+package keeper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/notifier"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func stuckTestPR(number int, sha string) PullRequest {
+	pr := PullRequest{}
+	pr.Number = githubql.Int(number)
+	pr.HeadRefOID = githubql.String(sha)
+	pr.Title = "A change"
+	pr.Author.Login = "author"
+	pr.Repository.URL = "https://github.com/org/repo"
+	return pr
+}
+
+func TestNewStuckPRWatcher(t *testing.T) {
+	w, err := NewStuckPRWatcher(0, "http://example.com", "", nil)
+	require.NoError(t, err)
+	assert.Nil(t, w)
+	w, err = NewStuckPRWatcher(time.Hour, "", "", nil)
+	require.NoError(t, err)
+	assert.Nil(t, w)
+	_, err = NewStuckPRWatcher(time.Hour, "http://example.com", "email", nil)
+	assert.Error(t, err)
+
+	// a disabled watcher does nothing
+	w.Check([]Pool{{Org: "org", Repo: "repo"}})
+}
+
+func TestStuckPRWatcherStuck(t *testing.T) {
+	w, err := NewStuckPRWatcher(time.Hour, "http://example.com", "", nil)
+	require.NoError(t, err)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+
+	pending := stuckTestPR(2, "def")
+	node := struct{ Commit Commit }{}
+	node.Commit.OID = "def"
+	node.Commit.Status.Contexts = []Context{
+		{Context: "ci/build", State: githubql.StatusStateSuccess},
+		{Context: "ci/e2e", State: githubql.StatusStatePending},
+	}
+	pending.Commits.Nodes = append(pending.Commits.Nodes, node)
+	pool := Pool{
+		Org:        "org",
+		Repo:       "repo",
+		Branch:     "master",
+		SuccessPRs: []PullRequest{stuckTestPR(1, "abc")},
+		PendingPRs: []PullRequest{pending},
+		Action:     Wait,
+	}
+
+	assert.Empty(t, w.stuck([]Pool{pool}))
+	now = now.Add(30 * time.Minute)
+	assert.Empty(t, w.stuck([]Pool{pool}))
+
+	now = now.Add(time.Hour)
+	actual := w.stuck([]Pool{pool})
+	require.Len(t, actual, 2)
+	assert.Equal(t, 1, actual[0].Number)
+	assert.Equal(t, StuckMergeable, actual[0].State)
+	assert.Equal(t, "https://github.com/org/repo/pull/1", actual[0].URL)
+	assert.Equal(t, "1h30m0s", actual[0].Duration)
+	assert.Contains(t, actual[0].Text, "org/repo#1 (A change) by author has been mergeable on master for 1h30m0s")
+	assert.Equal(t, 2, actual[1].Number)
+	assert.Equal(t, StuckPending, actual[1].State)
+	assert.Contains(t, actual[1].Blocking, "context ci/e2e is pending")
+
+	// PRs are only escalated once per state
+	for _, stuck := range actual {
+		w.markEscalated(stuck)
+	}
+	now = now.Add(time.Hour)
+	assert.Empty(t, w.stuck([]Pool{pool}))
+
+	// a new commit restarts the tracking of the PR
+	pool.SuccessPRs = []PullRequest{stuckTestPR(1, "abc2")}
+	assert.Empty(t, w.stuck([]Pool{pool}))
+	now = now.Add(2 * time.Hour)
+	actual = w.stuck([]Pool{pool})
+	require.Len(t, actual, 1)
+	assert.Equal(t, 1, actual[0].Number)
+}
+
+func TestStuckPRWatcherCheck(t *testing.T) {
+	status := http.StatusInternalServerError
+	var escalations int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		escalations++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	kubeClient := kubefake.NewSimpleClientset()
+	newWatcher := func() *StuckPRWatcher {
+		w, err := NewStuckPRWatcher(time.Hour, server.URL, EscalationFormatJSON, nil)
+		require.NoError(t, err)
+		w.SetStore(NewConfigMapStuckPRStore(kubeClient, "jx"))
+		return w
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	pools := []Pool{{Org: "org", Repo: "repo", Branch: "master", SuccessPRs: []PullRequest{stuckTestPR(1, "abc")}}}
+
+	w := newWatcher()
+	w.now = func() time.Time { return now }
+	w.Check(pools)
+	assert.Equal(t, 0, escalations)
+
+	// the tracking survives restarts
+	w = newWatcher()
+	now = now.Add(2 * time.Hour)
+	w.now = func() time.Time { return now }
+	w.Check(pools)
+	assert.Equal(t, 1, escalations)
+
+	// a failed escalation is sent again after the next sync
+	status = http.StatusOK
+	w.Check(pools)
+	assert.Equal(t, 2, escalations)
+	w.Check(pools)
+	assert.Equal(t, 2, escalations)
+
+	// a successful escalation is not sent again, even after a restart
+	w = newWatcher()
+	w.now = func() time.Time { return now }
+	w.Check(pools)
+	assert.Equal(t, 2, escalations)
+
+	cm, err := kubeClient.CoreV1().ConfigMaps("jx").Get(StuckPRsConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, cm.Data[stuckPRsConfigMapKey], `"escalated":true`)
+}
+
+func TestStuckPRWatcherNotify(t *testing.T) {
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	stuck := StuckPR{Org: "org", Repo: "repo", Number: 1, State: StuckMergeable, Text: "stuck"}
+
+	w, err := NewStuckPRWatcher(time.Hour, server.URL, EscalationFormatJSON, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.notify(stuck))
+
+	w, err = NewStuckPRWatcher(time.Hour, server.URL, EscalationFormatSlack, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.notify(stuck))
+
+	require.Len(t, payloads, 2)
+	assert.Equal(t, "org", payloads[0]["org"])
+	assert.Equal(t, float64(1), payloads[0]["number"])
+	assert.Equal(t, map[string]interface{}{"text": "stuck"}, payloads[1])
+}
+
+func TestStuckPRWatcherSlackOnly(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message := map[string]string{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		texts = append(texts, message["text"])
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	s := &settings.Config{Notifications: settings.Notifications{Slack: []settings.SlackRoute{{Channel: "#ci"}}}}
+
+	w, err := NewStuckPRWatcher(time.Hour, "", "", notifier.New("token", server.URL, func() *settings.Config { return s }))
+	require.NoError(t, err)
+	require.NotNil(t, w, "the notifier enables the watcher without a webhook")
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	pools := []Pool{
+		{Org: "org", Repo: "repo", Branch: "master", SuccessPRs: []PullRequest{stuckTestPR(1, "abc"), stuckTestPR(2, "def")}},
+		{Org: "org", Repo: "other", Branch: "master", SuccessPRs: []PullRequest{stuckTestPR(3, "ghi")}},
+	}
+	w.Check(pools)
+	now = now.Add(2 * time.Hour)
+	w.Check(pools)
+	w.Check(pools)
+
+	require.Len(t, texts, 2, "the stuck PRs are notified once per pool")
+	assert.Contains(t, texts[0], "org/other master")
+	assert.Contains(t, texts[1], "#1 (A change) has been mergeable for 2h0m0s https://github.com/org/repo/pull/1")
+	assert.Contains(t, texts[1], "#2 (A change)")
+}
@@ -1,23 +1,26 @@
 package githubapp
 
 import (
+	"context"
+
 	"github.com/jenkins-x/go-scm/scm/factory"
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse/pkg/clients"
 	"github.com/jenkins-x/lighthouse/pkg/git"
-	"github.com/jenkins-x/lighthouse/pkg/jx"
 	"github.com/jenkins-x/lighthouse/pkg/keeper"
+	"github.com/jenkins-x/lighthouse/pkg/launcher"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"github.com/jenkins-x/lighthouse/pkg/util"
 	"github.com/pkg/errors"
 )
 
 // NewKeeperController creates a new controller; either regular or a GitHub App flavour
 // depending on the $GITHUB_APP_SECRET_DIR environment variable
-func NewKeeperController(configAgent *config.Agent, botName string, gitKind string, gitToken string, serverURL string, maxRecordsPerPool int, historyURI string, statusURI string) (keeper.Controller, error) {
+func NewKeeperController(configAgent *config.Agent, botName string, gitKind string, gitToken string, serverURL string, opts keeper.ControllerOptions) (keeper.Controller, error) {
 	githubAppSecretDir := util.GetGitHubAppSecretDir()
 	if githubAppSecretDir != "" {
-		return NewGitHubAppKeeperController(githubAppSecretDir, configAgent, botName, gitKind, maxRecordsPerPool, historyURI, statusURI)
+		return NewGitHubAppKeeperController(githubAppSecretDir, configAgent, botName, gitKind, opts)
 	}
 
 	scmClient, err := factory.NewClient(gitKind, serverURL, "")
@@ -26,6 +29,8 @@ func NewKeeperController(configAgent *config.Agent, botName string, gitKind stri
 	}
 	util.AddAuthToSCMClient(scmClient, gitToken, false)
 	gitproviderClient := scmprovider.ToClient(scmClient, botName)
+	gitproviderClient.SetSettings(opts.Settings)
+	gitproviderClient.SetContext(scmprovider.WithAuditReason(context.Background(), "keeper"))
 	gitClient, err := git.NewClient(serverURL, botName)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating git client")
@@ -34,14 +39,31 @@ func NewKeeperController(configAgent *config.Agent, botName string, gitKind stri
 		return []byte(gitToken)
 	})
 
-	tektonClient, _, _, lhClient, ns, err := clients.GetClientsAndNamespace(nil)
+	kubeClients, err := keeperClients(opts)
 	if err != nil {
-		return nil, errors.Wrap(err, "Error creating kubernetes resource clients.")
+		return nil, err
 	}
-	launcherClient, err := jx.NewLauncher()
+	launcherClient, err := newLauncher(kubeClients, configAgent.Config, opts.Settings)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error getting PipelineLauncher client.")
 	}
-	c, err := keeper.NewController(gitproviderClient, gitproviderClient, launcherClient, tektonClient, lhClient, ns, configAgent.Config, gitClient, maxRecordsPerPool, historyURI, statusURI, nil)
+	c, err := keeper.NewController(gitproviderClient, gitproviderClient, launcherClient, kubeClients.Tekton, kubeClients.Lighthouse, kubeClients.Namespace, configAgent.Config, gitClient, opts)
 	return c, err
 }
+
+// keeperClients returns the Kubernetes clients of the options, or creates the clients of keeper
+func keeperClients(opts keeper.ControllerOptions) (*clients.Clients, error) {
+	if opts.Clients != nil {
+		return opts.Clients, nil
+	}
+	kubeClients, err := clients.GetClientsForComponent(nil, clients.Keeper)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating kubernetes resource clients.")
+	}
+	return kubeClients, nil
+}
+
+// newLauncher returns the launcher of the pipelines of the agents of the jobs
+func newLauncher(kubeClients *clients.Clients, configGetter config.Getter, settingsGetter settings.Getter) (launcher.PipelineLauncher, error) {
+	return launcher.NewAgentLauncher(launcher.Options{Clients: kubeClients, Config: configGetter, Settings: settingsGetter})
+}
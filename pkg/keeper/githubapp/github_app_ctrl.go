@@ -1,6 +1,7 @@
 package githubapp
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"sync"
@@ -10,9 +11,7 @@ import (
 	"github.com/jenkins-x/go-scm/scm/transport"
 	"github.com/jenkins-x/jx/v2/pkg/errorutil"
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
-	"github.com/jenkins-x/lighthouse/pkg/clients"
 	"github.com/jenkins-x/lighthouse/pkg/git"
-	"github.com/jenkins-x/lighthouse/pkg/jx"
 	"github.com/jenkins-x/lighthouse/pkg/keeper"
 	"github.com/jenkins-x/lighthouse/pkg/keeper/history"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
@@ -23,39 +22,39 @@ import (
 
 type gitHubAppKeeperController struct {
 	controllers        []keeper.Controller
-	ownerTokenFinder   *util.OwnerTokensDir
+	ownerTokenFinder   *util.GitHubAppTokenManager
 	gitServer          string
 	githubAppSecretDir string
 	configAgent        *config.Agent
 	botName            string
 	gitKind            string
-	maxRecordsPerPool  int
-	historyURI         string
-	statusURI          string
+	opts               keeper.ControllerOptions
 	logger             *logrus.Entry
 	m                  sync.Mutex
+	syncLock           sync.Mutex
 }
 
 // NewGitHubAppKeeperController creates a GitHub App style controller which needs to process each github owner
 // using a separate git provider client due to the way GitHub App tokens work
-func NewGitHubAppKeeperController(githubAppSecretDir string, configAgent *config.Agent, botName string, gitKind string, maxRecordsPerPool int, historyURI string, statusURI string) (keeper.Controller, error) {
+func NewGitHubAppKeeperController(githubAppSecretDir string, configAgent *config.Agent, botName string, gitKind string, opts keeper.ControllerOptions) (keeper.Controller, error) {
 
 	gitServer := util.GithubServer
 	return &gitHubAppKeeperController{
-		ownerTokenFinder:  util.NewOwnerTokensDir(gitServer, githubAppSecretDir),
-		gitServer:         gitServer,
-		configAgent:       configAgent,
-		botName:           botName,
-		gitKind:           gitKind,
-		maxRecordsPerPool: maxRecordsPerPool,
-		historyURI:        historyURI,
-		statusURI:         statusURI,
-		logger:            logrus.NewEntry(logrus.StandardLogger()),
+		ownerTokenFinder: util.GetGitHubAppTokenManager(gitServer, githubAppSecretDir),
+		gitServer:        gitServer,
+		configAgent:      configAgent,
+		botName:          botName,
+		gitKind:          gitKind,
+		opts:             opts,
+		logger:           logrus.NewEntry(logrus.StandardLogger()),
 	}, nil
 
 }
 
 func (g *gitHubAppKeeperController) Sync() error {
+	g.syncLock.Lock()
+	defer g.syncLock.Unlock()
+
 	// lets iterate through the config and create a controller for each
 	err := g.createOwnerControllers()
 	if err != nil {
@@ -72,6 +71,21 @@ func (g *gitHubAppKeeperController) Sync() error {
 	return errorutil.CombineErrors(errs...)
 }
 
+func (g *gitHubAppKeeperController) SyncRepo(org, repo, branch string) error {
+	g.syncLock.Lock()
+	defer g.syncLock.Unlock()
+
+	// the controllers only get created by a full sync
+	errs := []error{}
+	for _, c := range g.controllers {
+		err := c.SyncRepo(org, repo, branch)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errorutil.CombineErrors(errs...)
+}
+
 func (g *gitHubAppKeeperController) Shutdown() {
 	for _, c := range g.controllers {
 		c.Shutdown()
@@ -103,7 +117,7 @@ func (g *gitHubAppKeeperController) ServeHTTP(w http.ResponseWriter, r *http.Req
 }
 
 func (g *gitHubAppKeeperController) GetHistory() *history.History {
-	answer, err := history.New(g.maxRecordsPerPool, g.historyURI)
+	answer, err := history.New(g.opts.MaxRecordsPerPool, g.opts.HistoryURI)
 	if err != nil {
 		return answer
 	}
@@ -160,6 +174,8 @@ func (g *gitHubAppKeeperController) createOwnerController(owner string, configGe
 	}
 	util.AddAuthToSCMClient(scmClient, token, true)
 	gitproviderClient := scmprovider.ToClient(scmClient, g.botName)
+	gitproviderClient.SetSettings(g.opts.Settings)
+	gitproviderClient.SetContext(scmprovider.WithAuditReason(context.Background(), "keeper"))
 	gitClient, err := git.NewClient(g.gitServer, g.gitKind)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating git client")
@@ -167,15 +183,15 @@ func (g *gitHubAppKeeperController) createOwnerController(owner string, configGe
 	gitClient.SetCredentials(util.GitHubAppGitRemoteUsername, func() []byte {
 		return []byte(token)
 	})
-	tektonClient, _, _, lhClient, ns, err := clients.GetClientsAndNamespace(nil)
+	kubeClients, err := keeperClients(g.opts)
 	if err != nil {
-		return nil, errors.Wrap(err, "Error creating kubernetes resource clients.")
+		return nil, err
 	}
-	launcherClient, err := jx.NewLauncher()
+	launcherClient, err := newLauncher(kubeClients, configGetter, g.opts.Settings)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error getting PipelineLauncher client.")
 	}
-	c, err := keeper.NewController(gitproviderClient, gitproviderClient, launcherClient, tektonClient, lhClient, ns, configGetter, gitClient, g.maxRecordsPerPool, g.historyURI, g.statusURI, nil)
+	c, err := keeper.NewController(gitproviderClient, gitproviderClient, launcherClient, kubeClients.Tekton, kubeClients.Lighthouse, kubeClients.Namespace, configGetter, gitClient, g.opts)
 	return c, err
 }
 
@@ -0,0 +1,8 @@
+package githubapp
+
+// We need to empty import all the launchers so that they register their agents in
+// any binary launching pipelines.
+import (
+	_ "github.com/jenkins-x/lighthouse/pkg/jx" // Import all launchers.
+	_ "github.com/jenkins-x/lighthouse/pkg/launcher/tekton"
+)
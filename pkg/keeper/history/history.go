@@ -113,8 +113,13 @@ func (h *History) addRecord(poolKey string, rec *Record) {
 }
 
 // ServeHTTP serves a JSON mapping from pool key -> sorted records for the pool.
+// If the pool query parameter is set only the records of that pool are served.
 func (h *History) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	b, err := json.Marshal(h.AllRecords())
+	records := h.AllRecords()
+	if pool := r.URL.Query().Get("pool"); pool != "" {
+		records = map[string][]*Record{pool: records[pool]}
+	}
+	b, err := json.Marshal(records)
 	if err != nil {
 		logrus.WithError(err).Error("Encoding JSON history.")
 		b = []byte("{}")
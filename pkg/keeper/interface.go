@@ -10,6 +10,7 @@ import (
 // whether regular or the GitHub App flavour which has to handle tokens differently
 type Controller interface {
 	Sync() error
+	SyncRepo(org, repo, branch string) error
 	Shutdown()
 	GetPools() []Pool
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
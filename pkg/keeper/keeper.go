@@ -34,13 +34,19 @@ import (
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
 	clientset "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
 	"github.com/jenkins-x/lighthouse/pkg/errorutil"
 	"github.com/jenkins-x/lighthouse/pkg/git"
 	"github.com/jenkins-x/lighthouse/pkg/jobutil"
 	"github.com/jenkins-x/lighthouse/pkg/keeper/blockers"
 	"github.com/jenkins-x/lighthouse/pkg/keeper/history"
+	"github.com/jenkins-x/lighthouse/pkg/notifier"
+	"github.com/jenkins-x/lighthouse/pkg/provenance"
+	"github.com/jenkins-x/lighthouse/pkg/providerstatus"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/jenkins-x/lighthouse/pkg/watchdog"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	githubql "github.com/shurcooL/githubv4"
@@ -61,6 +67,7 @@ type scmProviderClient interface {
 	CreateGraphQLStatus(string, string, string, *scmprovider.Status) (*scm.Status, error)
 	GetCombinedStatus(org, repo, ref string) (*scm.CombinedStatus, error)
 	CreateStatus(org, repo, ref string, s *scm.StatusInput) (*scm.Status, error)
+	GetPullRequest(org, repo string, number int) (*scm.PullRequest, error)
 	GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error)
 	GetRef(string, string, string) (string, error)
 	Merge(string, string, int, scmprovider.MergeDetails) error
@@ -68,7 +75,15 @@ type scmProviderClient interface {
 	SupportsGraphQL() bool
 	ProviderType() string
 	GetRepositoryByFullName(string) (*scm.Repository, error)
-	ListAllPullRequestsForFullNameRepo(string, scm.PullRequestListOptions) ([]*scm.PullRequest, error)
+	ListPullRequests(string, scmprovider.PullRequestListOptions) ([]*scm.PullRequest, error)
+	AddLabel(owner, repo string, number int, label string, pr bool) error
+	RemoveLabel(owner, repo string, number int, label string, pr bool) error
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+	ListReviews(org, repo string, number int) ([]*scm.Review, error)
+	SearchAll(scmprovider.SearchQuery) ([]*scm.SearchIssue, error)
+	GetRepoLabels(owner, repo string) ([]*scm.Label, error)
+	CreateRepoLabel(owner, repo string, label *scm.Label) error
+	UpdateRepoLabel(owner, repo string, label *scm.Label) error
 }
 
 type contextChecker interface {
@@ -94,10 +109,49 @@ type DefaultController struct {
 	m     sync.Mutex
 	pools []Pool
 
+	// syncLock prevents full and partial syncs from running concurrently.
+	syncLock sync.Mutex
+
 	// changedFiles caches the names of files changed by PRs.
 	// Cache entries expire if they are not used during a sync loop.
 	changedFiles *changedFilesAgent
 
+	// mergeGate serializes merges per repository when configured.
+	mergeGate *MergeGate
+
+	// rebaseAdvisor labels and comments on PRs with merge conflicts when configured.
+	rebaseAdvisor *RebaseAdvisor
+	// labelSyncer reconciles the declared labels onto the repositories when configured.
+	labelSyncer *LabelSyncer
+
+	// batchThrottle defers batches while the cluster is saturated when configured.
+	batchThrottle *BatchThrottle
+
+	// settings are the lighthouse settings of the keeper queries, such as their pool filters.
+	settings settings.Getter
+
+	// mergeAuditor comments on merged PRs with the details of the merge when configured.
+	mergeAuditor *MergeAuditor
+	// notifier notifies the merges to Slack when configured.
+	notifier *notifier.Notifier
+
+	// provenance signs the provenance of the jobs triggered and the PRs merged when configured.
+	provenance *provenance.Agent
+	// reviewChecker keeps PRs whose review requirements are not satisfied out of the pool when configured.
+	reviewChecker *ReviewChecker
+	// trialMerger keeps PRs which do not merge cleanly into the base SHA out of the pool when configured.
+	trialMerger *TrialMerger
+	// duplicateJobs tracks the jobs which ran more than once for the same commits when configured.
+	duplicateJobs *DuplicateJobTracker
+	// watchdog reports the syncs which are stuck when configured.
+	watchdog *watchdog.Watchdog
+	// providerStatus pauses the merges while the git provider is degraded when configured.
+	providerStatus *providerstatus.Monitor
+	// rateLimit skips the syncs triggered by webhooks while the rate limit budget of the git provider is low when configured.
+	rateLimit *scmprovider.RateLimitThrottle
+	// batchResults counts the results of the batch jobs in the metrics.
+	batchResults *batchResultTracker
+
 	History *history.History
 }
 
@@ -146,6 +200,9 @@ type Pool struct {
 	Target   []PullRequest
 	Blockers []blockers.Blocker
 	Error    string
+
+	// MissingContexts are the contexts of the required presubmits which are missing or failed, keyed by PR number
+	MissingContexts map[int][]string
 }
 
 // Prometheus Metrics
@@ -156,6 +213,19 @@ var (
 		updateTime *prometheus.GaugeVec
 		merges     *prometheus.HistogramVec
 
+		poolSyncDuration *prometheus.HistogramVec
+		mergedPRs        *prometheus.CounterVec
+		mergeFailures    *prometheus.CounterVec
+		lastMergeTime    *prometheus.GaugeVec
+		batchResults     *prometheus.CounterVec
+
+		// Per org
+		consideredPRs *prometheus.GaugeVec
+		scmCalls      *prometheus.CounterVec
+
+		// Per job
+		duplicateJobRuns *prometheus.CounterVec
+
 		// Singleton
 		syncDuration         prometheus.Gauge
 		statusUpdateDuration prometheus.Gauge
@@ -187,6 +257,78 @@ var (
 			"branch",
 		}),
 
+		poolSyncDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "lighthouse_keeper_pool_sync_duration_seconds",
+			Help:    "Histogram of the duration of the syncs of each Keeper pool.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+		}, []string{
+			"org",
+			"repo",
+			"branch",
+		}),
+
+		mergedPRs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lighthouse_keeper_merged_prs_total",
+			Help: "Number of PRs merged by Keeper.",
+		}, []string{
+			"org",
+			"repo",
+			"branch",
+		}),
+
+		mergeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lighthouse_keeper_merge_failures_total",
+			Help: "Number of PRs Keeper failed to merge.",
+		}, []string{
+			"org",
+			"repo",
+			"branch",
+		}),
+
+		lastMergeTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "lighthouse_keeper_last_merge_timestamp_seconds",
+			Help: "The last time Keeper merged a PR into each pool.",
+		}, []string{
+			"org",
+			"repo",
+			"branch",
+		}),
+
+		batchResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lighthouse_keeper_batch_results_total",
+			Help: "Number of batch jobs which completed, by result.",
+		}, []string{
+			"org",
+			"repo",
+			"branch",
+			"result",
+		}),
+
+		consideredPRs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "lighthouse_keeper_considered_prs",
+			Help: "Number of PRs matching the Keeper queries of each org in the last full sync.",
+		}, []string{
+			"org",
+		}),
+
+		scmCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lighthouse_keeper_scm_calls_total",
+			Help: "Number of calls Keeper made to the git provider, by org, call and result.",
+		}, []string{
+			"org",
+			"call",
+			"result",
+		}),
+
+		duplicateJobRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lighthouse_keeper_duplicate_job_runs_total",
+			Help: "Number of runs of jobs which already ran for the same commits, e.g. because of retests or duplicate events.",
+		}, []string{
+			"org",
+			"repo",
+			"job",
+		}),
+
 		syncDuration: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "syncdur",
 			Help: "The duration of the last loop of the sync controller.",
@@ -203,31 +345,84 @@ func init() {
 	prometheus.MustRegister(keeperMetrics.pooledPRs)
 	prometheus.MustRegister(keeperMetrics.updateTime)
 	prometheus.MustRegister(keeperMetrics.merges)
+	prometheus.MustRegister(keeperMetrics.poolSyncDuration)
+	prometheus.MustRegister(keeperMetrics.mergedPRs)
+	prometheus.MustRegister(keeperMetrics.mergeFailures)
+	prometheus.MustRegister(keeperMetrics.lastMergeTime)
+	prometheus.MustRegister(keeperMetrics.batchResults)
+	prometheus.MustRegister(keeperMetrics.consideredPRs)
+	prometheus.MustRegister(keeperMetrics.scmCalls)
+	prometheus.MustRegister(keeperMetrics.duplicateJobRuns)
 	prometheus.MustRegister(keeperMetrics.syncDuration)
 	prometheus.MustRegister(keeperMetrics.statusUpdateDuration)
 }
 
+// ControllerOptions are the settings and optional features of the keeper controllers. The
+// features left nil are disabled.
+type ControllerOptions struct {
+	// MaxRecordsPerPool is the number of actions recorded in the history of each pool
+	MaxRecordsPerPool int
+	// HistoryURI is where the action history is stored
+	HistoryURI string
+	// StatusURI is where the status update state is stored
+	StatusURI string
+
+	MergeGate      *MergeGate
+	RebaseAdvisor  *RebaseAdvisor
+	LabelSyncer    *LabelSyncer
+	BatchThrottle  *BatchThrottle
+	MergeAuditor   *MergeAuditor
+	StatusThrottle *StatusThrottle
+	Notifier       *notifier.Notifier
+	Provenance     *provenance.Agent
+	ReviewChecker  *ReviewChecker
+	TrialMerger    *TrialMerger
+	DuplicateJobs  *DuplicateJobTracker
+	// Watchdog tracks the syncs of the controllers and of their status controllers
+	Watchdog *watchdog.Watchdog
+	// ProviderStatus pauses the merges while the status page of the git provider reports it is degraded
+	ProviderStatus *providerstatus.Monitor
+	// RateLimitThrottle skips the syncs triggered by webhooks while the rate limit budget of the git provider is low
+	RateLimitThrottle *scmprovider.RateLimitThrottle
+
+	// Settings are the lighthouse settings of the keeper queries, none are used if it is nil
+	Settings settings.Getter
+	// Clients are the Kubernetes clients of the controllers, the clients of keeper are created if it is nil
+	Clients *clients.Clients
+
+	Logger *logrus.Entry
+}
+
 // NewController makes a DefaultController out of the given clients.
-func NewController(spcSync, spcStatus *scmprovider.Client, launcherClient launcher, tektonClient tektonclient.Interface, lighthouseClient clientset.Interface, ns string, cfg config.Getter, gc git.Client, maxRecordsPerPool int, historyURI, statusURI string, logger *logrus.Entry) (*DefaultController, error) {
+func NewController(spcSync, spcStatus *scmprovider.Client, launcherClient launcher, tektonClient tektonclient.Interface, lighthouseClient clientset.Interface, ns string, cfg config.Getter, gc git.Client, opts ControllerOptions) (*DefaultController, error) {
+	logger := opts.Logger
 	if logger == nil {
 		logger = logrus.NewEntry(logrus.StandardLogger())
 	}
-	hist, err := history.New(maxRecordsPerPool, historyURI)
+	cfg = OrgDefaultQueries(cfg, opts.Settings)
+	hist, err := history.New(opts.MaxRecordsPerPool, opts.HistoryURI)
 	if err != nil {
-		return nil, fmt.Errorf("error initializing history client from %q: %v", historyURI, err)
+		return nil, fmt.Errorf("error initializing history client from %q: %v", opts.HistoryURI, err)
 	}
 	sc := &statusController{
 		logger:         logger.WithField("controller", "status-update"),
-		spc:            spcStatus,
+		spc:            instrumentSCMClient(spcStatus),
 		config:         cfg,
 		newPoolPending: make(chan bool, 1),
 		shutDown:       make(chan bool),
-		path:           statusURI,
+		path:           opts.StatusURI,
+		throttle:       opts.StatusThrottle,
+		settings:       opts.Settings,
+		watchdog:       opts.Watchdog,
+		changedFiles: &changedFilesAgent{
+			spc:             spcStatus,
+			nextChangeCache: make(map[changeCacheKey][]string),
+		},
 	}
 	go sc.run()
 	return &DefaultController{
 		logger:         logger.WithField("controller", "sync"),
-		spc:            spcSync,
+		spc:            instrumentSCMClient(spcSync),
 		launcherClient: launcherClient,
 		tektonClient:   tektonClient,
 		lhClient:       lighthouseClient,
@@ -239,7 +434,22 @@ func NewController(spcSync, spcStatus *scmprovider.Client, launcherClient launch
 			spc:             spcSync,
 			nextChangeCache: make(map[changeCacheKey][]string),
 		},
-		History: hist,
+		mergeGate:      opts.MergeGate,
+		rebaseAdvisor:  opts.RebaseAdvisor,
+		labelSyncer:    opts.LabelSyncer,
+		batchThrottle:  opts.BatchThrottle,
+		settings:       opts.Settings,
+		mergeAuditor:   opts.MergeAuditor,
+		notifier:       opts.Notifier,
+		provenance:     opts.Provenance,
+		reviewChecker:  opts.ReviewChecker,
+		trialMerger:    opts.TrialMerger,
+		duplicateJobs:  opts.DuplicateJobs,
+		watchdog:       opts.Watchdog,
+		providerStatus: opts.ProviderStatus,
+		rateLimit:      opts.RateLimitThrottle,
+		batchResults:   newBatchResultTracker(),
+		History:        hist,
 	}, nil
 }
 
@@ -293,20 +503,77 @@ func contextsToStrings(contexts []Context) []string {
 	return names
 }
 
+// listPageSize is the number of LighthouseJobs per page when listing them, so that clusters
+// retaining many jobs are not listed in a single response
+const listPageSize = 500
+
+// listLighthouseJobs lists the LighthouseJobs of the namespace matching the label selector page by
+// page, so that only the jobs kept by visit are held in memory
+func (c *DefaultController) listLighthouseJobs(selector string, visit func([]v1alpha1.LighthouseJob)) error {
+	options := metav1.ListOptions{LabelSelector: selector, Limit: listPageSize}
+	for {
+		lhjList, err := c.lhClient.LighthouseV1alpha1().LighthouseJobs(c.ns).List(options)
+		if err != nil {
+			return err
+		}
+		visit(lhjList.Items)
+		if lhjList.Continue == "" {
+			return nil
+		}
+		options.Continue = lhjList.Continue
+	}
+}
+
 // Sync runs one sync iteration.
 func (c *DefaultController) Sync() error {
+	return c.sync(nil)
+}
+
+// SyncRepo runs one sync iteration for the subpools of the given repository only.
+// If branch is empty all the subpools of the repository are synced.
+func (c *DefaultController) SyncRepo(org, repo, branch string) error {
+	return c.sync(&SyncRequest{Org: org, Repo: repo, Branch: branch})
+}
+
+// sync runs one sync iteration. If a request is given only the matching subpools are synced.
+func (c *DefaultController) sync(request *SyncRequest) error {
+	c.syncLock.Lock()
+	defer c.syncLock.Unlock()
+	defer c.watchdog.Track()()
+
 	start := time.Now()
 	defer func() {
 		duration := time.Since(start)
+		if request != nil {
+			c.logger.WithField("duration", duration.String()).Infof("Synced %s", request.String())
+			return
+		}
 		c.logger.WithField("duration", duration.String()).Info("Synced")
 		keeperMetrics.syncDuration.Set(duration.Seconds())
 	}()
-	defer c.changedFiles.prune()
+	if request == nil {
+		defer c.changedFiles.prune()
+		defer c.trialMerger.prune()
+	}
+
+	queries := c.config().Keeper.Queries
+	if request != nil {
+		// the periodic syncs still run, so the PRs of the skipped syncs are synced eventually
+		if err := c.rateLimit.Allow("keeper-sync-repo"); err != nil {
+			c.logger.WithError(err).Infof("Skipping the sync of %s.", request.String())
+			return nil
+		}
+		queries = request.queries(queries)
+		if len(queries) == 0 {
+			c.logger.Debugf("No keeper queries match %s.", request.String())
+			return nil
+		}
+	}
 
 	c.logger.Debug("Building keeper pool.")
 	prs := make(map[string]PullRequest)
 	if c.spc.SupportsGraphQL() {
-		for _, query := range c.config().Keeper.Queries {
+		for _, query := range queries {
 			q := query.Query()
 			results, err := graphQLSearch(c.spc.Query, c.logger, q, time.Time{}, time.Now())
 			if err != nil && len(results) == 0 {
@@ -322,7 +589,7 @@ func (c *DefaultController) Sync() error {
 			}
 		}
 	} else {
-		results, err := restAPISearch(c.spc, c.logger, c.config().Keeper.Queries, time.Time{}, time.Now())
+		results, err := restAPISearch(c.spc, c.logger, queries, time.Time{}, time.Now())
 		if err != nil {
 			c.logger.WithError(err).Warnf("failed to perform REST query for PRs")
 			return errors.Wrapf(err, "failed to perform REST query for PRs")
@@ -333,27 +600,59 @@ func (c *DefaultController) Sync() error {
 			prs[prKey(&p)] = pr
 		}
 	}
+	if request != nil {
+		for key, pr := range prs {
+			if !request.matches(string(pr.Repository.Owner.Login), string(pr.Repository.Name), string(pr.BaseRef.Name)) {
+				delete(prs, key)
+			}
+		}
+	}
 	c.logger.WithField(
 		"duration", time.Since(start).String(),
 	).Debugf("Found %d (unfiltered) pool PRs.", len(prs))
+	if request == nil {
+		recordConsideredPRs(prs)
+		c.labelSyncer.Sync(c.spc, labelSyncRepos(queries, prs), c.logger)
+	}
 
-	var lhjs []v1alpha1.LighthouseJob
-	var blocks blockers.Blockers
-	var err error
-	if len(prs) > 0 {
+	if c.rebaseAdvisor != nil {
+		c.adviseRebase(prs)
+	}
+
+	// Partition PRs into subpools and filter out non-pool PRs.
+	rawPools, err := c.dividePool(prs, nil)
+	if err != nil {
+		return err
+	}
+	// the jobs are also listed without pool PRs to track the duplicate jobs
+	if len(prs) > 0 || c.duplicateJobs != nil {
 		start := time.Now()
-		lhjList, err := c.lhClient.LighthouseV1alpha1().LighthouseJobs(c.ns).List(metav1.ListOptions{})
+		err = c.listLighthouseJobs("", func(lhjs []v1alpha1.LighthouseJob) {
+			c.duplicateJobs.Observe(lhjs)
+			addSubpoolJobs(rawPools, lhjs)
+		})
 		if err != nil {
 			c.logger.WithField("duration", time.Since(start).String()).Debug("Failed to list LighthouseJobs from the cluster.")
 			return err
 		}
 		c.logger.WithField("duration", time.Since(start).String()).Debug("Listed LighthouseJobs from the cluster.")
-		lhjs = lhjList.Items
-
-		// TODO: Support blockers with non-graphql
-		if c.spc.SupportsGraphQL() {
-			if label := c.config().Keeper.BlockerLabel; label != "" {
-				c.logger.Debugf("Searching for blocking issues (label %q).", label)
+	}
+	if len(prs) > 0 && c.batchThrottle != nil {
+		// the completed jobs are labelled by foghorn, and the throttle only counts the unfinished ones
+		var active []v1alpha1.LighthouseJob
+		err = c.listLighthouseJobs(util.ActiveJobsSelector, func(lhjs []v1alpha1.LighthouseJob) {
+			active = append(active, lhjs...)
+		})
+		if err != nil {
+			return err
+		}
+		c.batchThrottle.Observe(active)
+	}
+	var blocks blockers.Blockers
+	if len(prs) > 0 {
+		if label := c.config().Keeper.BlockerLabel; label != "" {
+			c.logger.Debugf("Searching for blocking issues (label %q).", label)
+			if c.spc.SupportsGraphQL() {
 				orgExcepts, repos := c.config().Keeper.Queries.OrgExceptionsAndRepos()
 				orgs := make([]string, 0, len(orgExcepts))
 				for org := range orgExcepts {
@@ -361,28 +660,29 @@ func (c *DefaultController) Sync() error {
 				}
 				orgRepoQuery := orgRepoQueryString(orgs, repos.UnsortedList(), orgExcepts)
 				blocks, err = blockers.FindAll(c.spc, c.logger, label, orgRepoQuery)
-				if err != nil {
-					return err
-				}
+			} else {
+				// providers without GraphQL can only search the issues of the repositories of the PRs
+				blocks, err = blockers.FindAllInRepos(c.spc, c.logger, label, prRepos(prs))
+			}
+			if err != nil {
+				return err
 			}
 		}
 	}
-	// Partition PRs into subpools and filter out non-pool PRs.
-	rawPools, err := c.dividePool(prs, lhjs)
-	if err != nil {
-		return err
-	}
 	filteredPools := c.filterSubpools(c.config().Keeper.MaxGoroutines, rawPools)
 
-	// Notify statusController about the new pool.
-	c.sc.Lock()
-	c.sc.blocks = blocks
-	c.sc.poolPRs = poolPRMap(filteredPools)
-	select {
-	case c.sc.newPoolPending <- true:
-	default:
+	// Notify statusController about the new pool. Partial syncs leave the
+	// status of the other subpools to the next full sync.
+	if request == nil {
+		c.sc.Lock()
+		c.sc.blocks = blocks
+		c.sc.poolPRs = poolPRMap(filteredPools)
+		select {
+		case c.sc.newPoolPending <- true:
+		default:
+		}
+		c.sc.Unlock()
 	}
-	c.sc.Unlock()
 
 	// Sync subpools in parallel.
 	poolChan := make(chan Pool, len(filteredPools))
@@ -403,8 +703,21 @@ func (c *DefaultController) Sync() error {
 	for pool := range poolChan {
 		pools = append(pools, pool)
 	}
-	sortPools(pools)
 	c.m.Lock()
+	if request != nil {
+		// Keep the last known state of the subpools which were not synced.
+		for _, pool := range c.pools {
+			if !request.matches(pool.Org, pool.Repo, pool.Branch) {
+				pools = append(pools, pool)
+			}
+		}
+		sortPools(pools)
+		c.pools = pools
+		c.m.Unlock()
+		c.History.Flush()
+		return nil
+	}
+	sortPools(pools)
 	c.pools = pools
 	// While we're locked, rerun failed-but-rerunnable PipelineRuns.
 	c.logger.WithField("duration", time.Since(start).String()).Debug("Rerunning PipelineRuns failed due to race condition.")
@@ -483,7 +796,16 @@ func (c *DefaultController) filterSubpools(goroutines int, raw map[string]*subpo
 				return
 			}
 			key := poolKey(sp.org, sp.repo, sp.branch)
-			if spFiltered := filterSubpool(c.spc, sp); spFiltered != nil {
+			c.filterExcludedPRs(sp)
+			c.filterUnreviewedPRs(sp)
+			spFiltered := filterSubpool(c.spc, sp)
+			if spFiltered != nil {
+				c.trialMerger.Filter(c.gc, spFiltered)
+				if len(spFiltered.prs) == 0 {
+					spFiltered = nil
+				}
+			}
+			if spFiltered != nil {
 				sp.log.WithField("key", key).WithField("pool", spFiltered).Debug("filtered sub-pool")
 
 				lock.Lock()
@@ -503,10 +825,11 @@ func (c *DefaultController) initSubpoolData(sp *subpool) error {
 	if err != nil {
 		return fmt.Errorf("error determining required presubmit PipelineActivitys: %v", err)
 	}
-	sp.cc, err = c.config().GetKeeperContextPolicy(sp.org, sp.repo, sp.branch)
+	cc, err := c.config().GetKeeperContextPolicy(sp.org, sp.repo, sp.branch)
 	if err != nil {
 		return fmt.Errorf("error setting up context checker: %v", err)
 	}
+	sp.cc = scopedContextChecker(currentSettings(c.settings).Keeper.ContextScopes, sp.org, sp.repo, cc, c.changedFiles.prChanges)
 	return nil
 }
 
@@ -560,7 +883,7 @@ func filterPR(spc scmProviderClient, sp *subpool, pr *PullRequest) bool {
 		}
 		return false
 	}
-	for _, ctx := range unsuccessfulContexts(contexts, sp.cc, log) {
+	for _, ctx := range unsuccessfulContexts(contexts, contextCheckerForPR(sp.cc, pr), log) {
 		if ctx.State != githubql.StatusStatePending {
 			log.WithField("context", ctx.Context).Debug("filtering out PR as unsuccessful context is not pending")
 			return true
@@ -595,7 +918,7 @@ const (
 )
 
 func toSimpleState(s v1alpha1.PipelineState) simpleState {
-	if s == v1alpha1.TriggeredState || s == v1alpha1.PendingState || s == v1alpha1.RunningState {
+	if s == v1alpha1.TriggeredState || s == v1alpha1.QueuedState || s == v1alpha1.PendingState || s == v1alpha1.RunningState {
 		return pendingState
 	} else if s == v1alpha1.SuccessState {
 		return successState
@@ -613,7 +936,7 @@ func isPassingTests(log *logrus.Entry, spc scmProviderClient, pr PullRequest, cc
 		// If we can't get the status of the commit, assume that it is failing.
 		return false
 	}
-	unsuccessful := unsuccessfulContexts(contexts, cc, log)
+	unsuccessful := unsuccessfulContexts(contexts, contextCheckerForPR(cc, &pr), log)
 	return len(unsuccessful) == 0
 }
 
@@ -632,6 +955,10 @@ func unsuccessfulContexts(contexts []Context, cc contextChecker, log *logrus.Ent
 		if string(ctx.Context) == "keeper" || string(ctx.Context) == "tide" {
 			continue
 		}
+		// Ignore the contexts keeper reports for the scopes of the PR
+		if strings.HasPrefix(string(ctx.Context), GetStatusContextLabel()+"/") {
+			continue
+		}
 		if cc.IsOptional(string(ctx.Context)) {
 			continue
 		}
@@ -907,6 +1234,8 @@ func checkMergeLabels(pr PullRequest, squash, rebase, merge string, method confi
 	return method, nil
 }
 
+// prepareMergeDetails renders the title and body of the merge commit with the merge commit templates of the
+// repository, leaving them to the git provider if there are none or they fail
 func (c *DefaultController) prepareMergeDetails(commitTemplates config.KeeperMergeCommitTemplate, pr PullRequest, mergeMethod config.PullRequestMergeType) scmprovider.MergeDetails {
 	ghMergeDetails := scmprovider.MergeDetails{
 		SHA:         string(pr.HeadRefOID),
@@ -919,7 +1248,8 @@ func (c *DefaultController) prepareMergeDetails(commitTemplates config.KeeperMer
 		if err := commitTemplates.Title.Execute(&b, pr); err != nil {
 			c.logger.Errorf("error executing commit title template: %v", err)
 		} else {
-			ghMergeDetails.CommitTitle = b.String()
+			// the templates are often YAML block scalars ending with a new line
+			ghMergeDetails.CommitTitle = strings.TrimSpace(b.String())
 		}
 	}
 
@@ -929,7 +1259,7 @@ func (c *DefaultController) prepareMergeDetails(commitTemplates config.KeeperMer
 		if err := commitTemplates.Body.Execute(&b, pr); err != nil {
 			c.logger.Errorf("error executing commit body template: %v", err)
 		} else {
-			ghMergeDetails.CommitMessage = b.String()
+			ghMergeDetails.CommitMessage = strings.TrimSpace(b.String())
 		}
 	}
 
@@ -939,10 +1269,15 @@ func (c *DefaultController) prepareMergeDetails(commitTemplates config.KeeperMer
 func (c *DefaultController) mergePRs(sp subpool, prs []PullRequest) error {
 	var merged, failed []int
 	defer func() {
+		if len(failed) > 0 {
+			keeperMetrics.mergeFailures.WithLabelValues(sp.org, sp.repo, sp.branch).Add(float64(len(failed)))
+		}
 		if len(merged) == 0 {
 			return
 		}
 		keeperMetrics.merges.WithLabelValues(sp.org, sp.repo, sp.branch).Observe(float64(len(merged)))
+		keeperMetrics.mergedPRs.WithLabelValues(sp.org, sp.repo, sp.branch).Add(float64(len(merged)))
+		keeperMetrics.lastMergeTime.WithLabelValues(sp.org, sp.repo, sp.branch).SetToCurrentTime()
 	}()
 
 	var errs []error
@@ -976,6 +1311,14 @@ func (c *DefaultController) mergePRs(sp subpool, prs []PullRequest) error {
 		} else {
 			log.Info("Merged.")
 			merged = append(merged, int(pr.Number))
+			if c.mergeGate != nil {
+				c.mergeGate.RecordMerge(sp.org, sp.repo)
+			}
+			if c.mergeAuditor.Enabled(sp.org, sp.repo) {
+				c.auditMerge(sp, pr, prs)
+			}
+			c.recordMergeProvenance(sp, pr)
+			c.notifier.Merged(mergeNotification(sp, pr))
 		}
 		if !keepTrying {
 			break
@@ -1002,6 +1345,23 @@ func (c *DefaultController) mergePRs(sp subpool, prs []PullRequest) error {
 	return fmt.Errorf("failed merging %v%s: %v", failed, batch, errorutil.NewAggregate(errs...))
 }
 
+// mergeNotification returns the notification of the merge of the PR
+func mergeNotification(sp subpool, pr PullRequest) notifier.Merge {
+	merge := notifier.Merge{
+		Org:    sp.org,
+		Repo:   sp.repo,
+		Branch: sp.branch,
+		Number: int(pr.Number),
+		Title:  string(pr.Title),
+		Author: string(pr.Author.Login),
+		SHA:    string(pr.HeadRefOID),
+	}
+	if pr.Repository.URL != "" {
+		merge.URL = fmt.Sprintf("%s/pull/%d", strings.TrimSuffix(string(pr.Repository.URL), "/"), pr.Number)
+	}
+	return merge
+}
+
 // tryMerge attempts 1 merge and returns a bool indicating if we should try
 // to merge the remaining PRs and possibly an error.
 func tryMerge(mergeFunc func() error) (bool, error) {
@@ -1063,6 +1423,27 @@ func tryMerge(mergeFunc func() error) (bool, error) {
 	return true, err
 }
 
+// setChanges passes the files changed by the PRs to the job as test selection hints, the job is
+// still run without them
+func (c *DefaultController) setChanges(spec *v1alpha1.LighthouseJobSpec, sp subpool, prs []PullRequest) {
+	if c.changedFiles == nil {
+		return
+	}
+	changed := sets.NewString()
+	for i := range prs {
+		files, err := c.changedFiles.prChanges(&prs[i])()
+		if err != nil {
+			sp.log.WithError(err).Warn("failed to list the changed files of the PRs")
+			return
+		}
+		changed.Insert(files...)
+	}
+	patterns := currentSettings(c.settings).ChangedModules.PatternsFor(sp.org, sp.repo)
+	if err := jobutil.SetChanges(spec, patterns, changed.List()); err != nil {
+		sp.log.WithError(err).Warn("failed to compute the changed modules")
+	}
+}
+
 func (c *DefaultController) trigger(sp subpool, presubmits map[int][]config.Presubmit, prs []PullRequest) error {
 	refs := v1alpha1.Refs{
 		Org:     sp.org,
@@ -1085,6 +1466,7 @@ func (c *DefaultController) trigger(sp subpool, presubmits map[int][]config.Pres
 	// If multiple required jobs have the same context, we assume the
 	// same shard will be run to provide those contexts
 	triggeredContexts := sets.NewString()
+	launcherClient := c.provenanceLauncher()
 	for _, pr := range prs {
 		for _, ps := range presubmits[int(pr.Number)] {
 			if triggeredContexts.Has(string(ps.Context)) {
@@ -1097,6 +1479,7 @@ func (c *DefaultController) trigger(sp subpool, presubmits map[int][]config.Pres
 			} else {
 				spec = jobutil.BatchSpec(ps, refs)
 			}
+			c.setChanges(&spec, sp, prs)
 			pj := jobutil.NewLighthouseJob(spec, ps.Labels, ps.Annotations)
 			start := time.Now()
 			cloneURL := string(pr.Repository.URL)
@@ -1113,10 +1496,20 @@ func (c *DefaultController) trigger(sp subpool, presubmits map[int][]config.Pres
 				Branch:    string(pr.BaseRef.Name),
 				Clone:     cloneURL,
 			}
-			if _, err := c.launcherClient.Launch(&pj, repo); err != nil {
+			if _, err := launcherClient.Launch(&pj, repo); err != nil {
 				c.logger.WithField("duration", time.Since(start).String()).Debug("Failed to create pipeline on the cluster.")
 				return fmt.Errorf("failed to create a pipeline for job: %q, PRs: %v: %v", spec.Job, prNumbers(prs), err)
 			}
+			if spec.Type == config.BatchJob {
+				// the results of the batch jobs are read from the jobs by accumulateBatch, they are not
+				// reported on the commits of the PRs which keep the results of their own jobs
+				c.logger.WithField("duration", time.Since(start).String()).WithField("batch", prNumbers(prs)).Debug("Created batch pipeline on the cluster.")
+				continue
+			}
+			if spec.SkipReport {
+				c.logger.WithField("duration", time.Since(start).String()).Debug("Created pipeline of a skip_report job on the cluster.")
+				continue
+			}
 			sha := refs.BaseSHA
 			if len(refs.Pulls) > 0 {
 				sha = refs.Pulls[0].SHA
@@ -1138,13 +1531,19 @@ func (c *DefaultController) trigger(sp subpool, presubmits map[int][]config.Pres
 }
 
 func (c *DefaultController) takeAction(sp subpool, batchPending, successes, pendings, missings, batchMerges []PullRequest, missingSerialTests map[int][]config.Presubmit) (Action, []PullRequest, error) {
+	// When merges are serialized we never merge or trigger batches, and only
+	// merge a single PR once the merge gate allows it.
+	serialized := c.mergeGate != nil
+	// Do not attempt merges while the git provider is degraded, they would fail or leave the pool
+	// half merged.
+	providerHealthy := c.providerHealthy(sp)
 	// Merge the batch!
-	if len(batchMerges) > 0 {
+	if len(batchMerges) > 0 && !serialized && providerHealthy {
 		return MergeBatch, batchMerges, c.mergePRs(sp, batchMerges)
 	}
 	// Do not merge PRs while waiting for a batch to complete. We don't want to
 	// invalidate the old batch result.
-	if len(successes) > 0 && len(batchPending) == 0 {
+	if len(successes) > 0 && len(batchPending) == 0 && providerHealthy && c.mergeGateAllows(sp) {
 		if ok, pr := pickSmallestPassingNumber(sp.log, c.spc, successes, sp.cc); ok {
 			return Merge, []PullRequest{pr}, c.mergePRs(sp, []PullRequest{pr})
 		}
@@ -1154,12 +1553,12 @@ func (c *DefaultController) takeAction(sp subpool, batchPending, successes, pend
 		return Wait, nil, nil
 	}
 	// If we have no batch, trigger one.
-	if len(sp.prs) > 1 && len(batchPending) == 0 {
+	if len(sp.prs) > 1 && len(batchPending) == 0 && !serialized {
 		batch, err := c.pickBatch(sp, sp.cc)
 		if err != nil {
 			return Wait, nil, err
 		}
-		if len(batch) > 1 {
+		if len(batch) > 1 && c.batchThrottleAllows(sp, batch) {
 			return TriggerBatch, batch, c.trigger(sp, sp.presubmits, batch)
 		}
 	}
@@ -1172,6 +1571,28 @@ func (c *DefaultController) takeAction(sp subpool, batchPending, successes, pend
 	return Wait, nil, nil
 }
 
+// mergeGateAllows returns true if there is no merge gate or the merge gate
+// allows merging into the subpool's repository.
+func (c *DefaultController) mergeGateAllows(sp subpool) bool {
+	if c.mergeGate == nil {
+		return true
+	}
+	if err := c.mergeGate.Allow(sp.org, sp.repo); err != nil {
+		sp.log.WithError(err).Info("Merge gate closed, waiting before merging.")
+		return false
+	}
+	return true
+}
+
+// providerHealthy returns true unless the status page of the git provider reports it is degraded.
+func (c *DefaultController) providerHealthy(sp subpool) bool {
+	degraded, reason := c.providerStatus.Degraded()
+	if degraded {
+		sp.log.WithField("reason", reason).Info("Git provider degraded, pausing merges.")
+	}
+	return !degraded
+}
+
 // changedFilesAgent queries and caches the names of files changed by PRs.
 // Cache entries expire if they are not used during a sync loop.
 type changedFilesAgent struct {
@@ -1254,16 +1675,22 @@ func (c *DefaultController) presubmitsByPull(sp *subpool) (map[int][]config.Pres
 		}
 	}
 
-	for _, ps := range c.config().Presubmits[sp.org+"/"+sp.repo] {
+	for _, ps := range jobutil.Presubmits(c.config(), scm.Repository{Namespace: sp.org, Name: sp.repo}) {
 		if !ps.ContextRequired() {
 			continue
 		}
 
 		for _, pr := range sp.prs {
 			p := pr
-			if shouldRun, err := ps.ShouldRun(sp.branch, c.changedFiles.prChanges(&p), false, false); err != nil {
+			changes := c.changedFiles.prChanges(&p)
+			if shouldRun, err := ps.ShouldRun(sp.branch, changes, false, false); err != nil {
+				return nil, err
+			} else if !shouldRun {
+				continue
+			}
+			if skipped, err := jobutil.SkipsChanges(currentSettings(c.settings), ps, changes); err != nil {
 				return nil, err
-			} else if shouldRun {
+			} else if !skipped {
 				record(int(pr.Number), ps)
 			}
 		}
@@ -1272,6 +1699,10 @@ func (c *DefaultController) presubmitsByPull(sp *subpool) (map[int][]config.Pres
 }
 
 func (c *DefaultController) syncSubpool(sp subpool, blocks []blockers.Blocker) (Pool, error) {
+	start := time.Now()
+	defer func() {
+		keeperMetrics.poolSyncDuration.WithLabelValues(sp.org, sp.repo, sp.branch).Observe(time.Since(start).Seconds())
+	}()
 	sp.log.Infof("Syncing subpool: %d PRs, %d PJs.", len(sp.prs), len(sp.pjs))
 	successes, pendings, missings, missingSerialTests := accumulate(sp.presubmits, sp.prs, sp.pjs, sp.log)
 	batchMerge, batchPending := accumulateBatch(sp.presubmits, sp.prs, sp.pjs, sp.log)
@@ -1309,6 +1740,7 @@ func (c *DefaultController) syncSubpool(sp subpool, blocks []blockers.Blocker) (
 		"action":  string(act),
 		"targets": prNumbers(targets),
 	}).Info("Subpool synced.")
+	c.batchResults.observe(&sp)
 	keeperMetrics.pooledPRs.WithLabelValues(sp.org, sp.repo, sp.branch).Set(float64(len(sp.prs)))
 	keeperMetrics.updateTime.WithLabelValues(sp.org, sp.repo, sp.branch).Set(float64(time.Now().Unix()))
 	return Pool{
@@ -1326,10 +1758,24 @@ func (c *DefaultController) syncSubpool(sp subpool, blocks []blockers.Blocker) (
 			Target:   targets,
 			Blockers: blocks,
 			Error:    errorString,
+
+			MissingContexts: missingContexts(missingSerialTests),
 		},
 		err
 }
 
+// missingContexts returns the contexts of the missing or failed presubmits of the PRs
+func missingContexts(missingTests map[int][]config.Presubmit) map[int][]string {
+	answer := map[int][]string{}
+	for number, presubmits := range missingTests {
+		for _, ps := range presubmits {
+			answer[number] = append(answer[number], ps.Context)
+		}
+		sort.Strings(answer[number])
+	}
+	return answer
+}
+
 func prMeta(prs ...PullRequest) []v1alpha1.Pull {
 	var res []v1alpha1.Pull
 	for _, pr := range prs {
@@ -1388,6 +1834,26 @@ func poolKey(org, repo, branch string) string {
 	return fmt.Sprintf("%s/%s:%s", org, repo, branch)
 }
 
+// prRepos returns the repositories of the PRs, sorted by name
+func prRepos(prs map[string]PullRequest) []blockers.OrgRepo {
+	seen := map[blockers.OrgRepo]bool{}
+	var repos []blockers.OrgRepo
+	for _, pr := range prs {
+		repo := blockers.OrgRepo{Org: string(pr.Repository.Owner.Login), Repo: string(pr.Repository.Name)}
+		if !seen[repo] {
+			seen[repo] = true
+			repos = append(repos, repo)
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].Org != repos[j].Org {
+			return repos[i].Org < repos[j].Org
+		}
+		return repos[i].Repo < repos[j].Repo
+	})
+	return repos
+}
+
 // dividePool splits up the list of pull requests and prow jobs into a group
 // per repo and branch. It only keeps PipelineActivitys that match the latest branch.
 func (c *DefaultController) dividePool(pool map[string]PullRequest, pjs []v1alpha1.LighthouseJob) (map[string]*subpool, error) {
@@ -1418,6 +1884,12 @@ func (c *DefaultController) dividePool(pool map[string]PullRequest, pjs []v1alph
 		}
 		sps[fn].prs = append(sps[fn].prs, pr)
 	}
+	addSubpoolJobs(sps, pjs)
+	return sps, nil
+}
+
+// addSubpoolJobs adds the presubmits and batches of the current base commit of the subpools to them
+func addSubpoolJobs(sps map[string]*subpool, pjs []v1alpha1.LighthouseJob) {
 	for _, pj := range pjs {
 		if pj.Spec.Type != config.PresubmitJob && pj.Spec.Type != config.BatchJob {
 			continue
@@ -1428,7 +1900,6 @@ func (c *DefaultController) dividePool(pool map[string]PullRequest, pjs []v1alph
 		}
 		sps[fn].pjs = append(sps[fn].pjs, pj)
 	}
-	return sps, nil
 }
 
 // PullRequest holds graphql data about a PR, including its commits and their contexts.
@@ -1466,6 +1937,9 @@ type PullRequest struct {
 	Body      githubql.String
 	Title     githubql.String
 	UpdatedAt githubql.DateTime
+	// ReviewDecision is the review decision of the PR on providers supporting GraphQL, which is
+	// used by the ReviewChecker
+	ReviewDecision githubql.String
 }
 
 // Repository holds graphql/query data about repositories
@@ -1536,7 +2010,7 @@ func (pr *PullRequest) logFields() logrus.Fields {
 func headContexts(log *logrus.Entry, spc scmProviderClient, pr *PullRequest) ([]Context, error) {
 	for _, node := range pr.Commits.Nodes {
 		if node.Commit.OID == pr.HeadRefOID {
-			return node.Commit.Status.Contexts, nil
+			return trimContextsPrefix(node.Commit.Status.Contexts), nil
 		}
 	}
 	// We didn't get the head commit from the query (the commits must not be
@@ -1573,6 +2047,30 @@ func headContexts(log *logrus.Entry, spc scmProviderClient, pr *PullRequest) ([]
 	return contexts, nil
 }
 
+// trimContextsPrefix removes the status context prefix from the contexts queried via GraphQL, dropping any
+// contexts from other systems which collide with the Lighthouse contexts.
+func trimContextsPrefix(contexts []Context) []Context {
+	if scmprovider.GetStatusContextPrefix() == "" {
+		return contexts
+	}
+	ours := sets.NewString()
+	for _, c := range contexts {
+		if context, ok := scmprovider.TrimStatusContextPrefix(string(c.Context)); ok {
+			ours.Insert(context)
+		}
+	}
+	answer := make([]Context, 0, len(contexts))
+	for _, c := range contexts {
+		context, ok := scmprovider.TrimStatusContextPrefix(string(c.Context))
+		if !ok && ours.Has(context) {
+			continue
+		}
+		c.Context = githubql.String(context)
+		answer = append(answer, c)
+	}
+	return answer
+}
+
 func orgRepoQueryString(orgs, repos []string, orgExceptions map[string]sets.String) string {
 	toks := make([]string, 0, len(orgs))
 	for _, o := range orgs {
@@ -1599,6 +2097,19 @@ func reposToQueries(queries config.KeeperQueries) map[string][]config.KeeperQuer
 	return queryMap
 }
 
+// queriesBaseBranch returns the branch all the queries are restricted to, if any, so that only the pull
+// requests targeting it are listed
+func queriesBaseBranch(queries []config.KeeperQuery) string {
+	branch := ""
+	for _, q := range queries {
+		if len(q.IncludedBranches) != 1 || (branch != "" && q.IncludedBranches[0] != branch) {
+			return ""
+		}
+		branch = q.IncludedBranches[0]
+	}
+	return branch
+}
+
 func restAPISearch(spc scmProviderClient, log *logrus.Entry, queries config.KeeperQueries, start, end time.Time) ([]PullRequest, error) {
 	var relevantPRs []PullRequest
 
@@ -1606,20 +2117,25 @@ func restAPISearch(spc scmProviderClient, log *logrus.Entry, queries config.Keep
 
 	// Iterate over the repo list and query them
 	for repo := range queryMap {
-		searchOpts := scm.PullRequestListOptions{
-			Page:   1,
-			Size:   100,
-			Open:   true,
-			Closed: false,
+		searchOpts := scmprovider.PullRequestListOptions{
+			PullRequestListOptions: scm.PullRequestListOptions{
+				Page:   1,
+				Size:   100,
+				Open:   true,
+				Closed: false,
+			},
+			BaseBranch: queriesBaseBranch(queryMap[repo]),
 		}
 		if !start.Equal(time.Time{}) {
+			// the most recently updated pull requests are listed first, so that the listing stops at start
 			searchOpts.UpdatedAfter = &start
+			searchOpts.SortByUpdated = true
 		}
 		if !end.Equal(time.Time{}) {
 			searchOpts.UpdatedBefore = &end
 		}
 
-		prs, err := spc.ListAllPullRequestsForFullNameRepo(repo, searchOpts)
+		prs, err := spc.ListPullRequests(repo, searchOpts)
 		if err != nil {
 			return nil, errors.Wrapf(err, "listing all open pull requests for %s", repo)
 		}
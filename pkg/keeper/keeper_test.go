@@ -32,6 +32,7 @@ import (
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
 	"github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/lighthouse/pkg/providerstatus"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
@@ -39,12 +40,16 @@ import (
 	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/diff"
+	clienttesting "k8s.io/client-go/testing"
 
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse/pkg/git/localgit"
 	"github.com/jenkins-x/lighthouse/pkg/keeper/history"
 	launcherfake "github.com/jenkins-x/lighthouse/pkg/launcher/fake"
+	"github.com/jenkins-x/lighthouse/pkg/util"
 )
 
 func testPullsMatchList(t *testing.T, test string, actual []PullRequest, expected []int) {
@@ -495,11 +500,27 @@ type fgc struct {
 	refs      map[string]string
 	merged    int
 	setStatus bool
-	mergeErrs map[int]error
+	// statusesSet are the org/repo@ref of the statuses set
+	statusesSet []string
+	mergeErrs   map[int]error
 
 	expectedSHA    string
 	ignoreExpected bool
 	combinedStatus map[string]map[string]commitStatus
+
+	labelsAdded   []string
+	labelsRemoved []string
+	comments      []string
+
+	// repoLabels are the labels of the repositories by org/repo
+	repoLabels    map[string][]*scm.Label
+	labelsCreated []string
+	labelsUpdated []string
+
+	// pullRequests are the PRs returned by GetPullRequest by number
+	pullRequests map[int]*scm.PullRequest
+	// reviewsErr is the error returned by ListReviews
+	reviewsErr error
 }
 
 type commitStatus struct {
@@ -526,7 +547,7 @@ func (f *fgc) GetRepositoryByFullName(string) (*scm.Repository, error) {
 	return nil, scm.ErrNotSupported
 }
 
-func (f *fgc) ListAllPullRequestsForFullNameRepo(string, scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
+func (f *fgc) ListPullRequests(string, scmprovider.PullRequestListOptions) ([]*scm.PullRequest, error) {
 	return nil, scm.ErrNotSupported
 }
 
@@ -562,6 +583,7 @@ func (f *fgc) CreateGraphQLStatus(org, repo, ref string, s *scmprovider.Status)
 	switch s.State {
 	case scmprovider.StatusSuccess, scmprovider.StatusError, scmprovider.StatusPending, scmprovider.StatusFailure:
 		f.setStatus = true
+		f.statusesSet = append(f.statusesSet, fmt.Sprintf("%s/%s@%s", org, repo, ref))
 		return nil, nil
 	}
 	return nil, fmt.Errorf("invalid 'state' value: %q", s.State)
@@ -599,6 +621,28 @@ func (f *fgc) CreateStatus(org, repo, ref string, s *scm.StatusInput) (*scm.Stat
 	return scm.ConvertStatusInputToStatus(s), nil
 }
 
+func (f *fgc) AddLabel(owner, repo string, number int, label string, pr bool) error {
+	f.labelsAdded = append(f.labelsAdded, fmt.Sprintf("%s/%s#%d:%s", owner, repo, number, label))
+	return nil
+}
+
+func (f *fgc) RemoveLabel(owner, repo string, number int, label string, pr bool) error {
+	f.labelsRemoved = append(f.labelsRemoved, fmt.Sprintf("%s/%s#%d:%s", owner, repo, number, label))
+	return nil
+}
+
+func (f *fgc) CreateComment(owner, repo string, number int, pr bool, comment string) error {
+	f.comments = append(f.comments, comment)
+	return nil
+}
+
+func (f *fgc) GetPullRequest(org, repo string, number int) (*scm.PullRequest, error) {
+	if pr, ok := f.pullRequests[number]; ok {
+		return pr, nil
+	}
+	return nil, scm.ErrNotFound
+}
+
 func (f *fgc) GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error) {
 	if number != 100 {
 		return nil, nil
@@ -611,6 +655,28 @@ func (f *fgc) GetPullRequestChanges(org, repo string, number int) ([]*scm.Change
 		nil
 }
 
+func (f *fgc) SearchAll(q scmprovider.SearchQuery) ([]*scm.SearchIssue, error) {
+	return nil, nil
+}
+
+func (f *fgc) GetRepoLabels(owner, repo string) ([]*scm.Label, error) {
+	return f.repoLabels[owner+"/"+repo], nil
+}
+
+func (f *fgc) CreateRepoLabel(owner, repo string, label *scm.Label) error {
+	f.labelsCreated = append(f.labelsCreated, fmt.Sprintf("%s/%s:%s:%s", owner, repo, label.Name, label.Color))
+	return nil
+}
+
+func (f *fgc) UpdateRepoLabel(owner, repo string, label *scm.Label) error {
+	f.labelsUpdated = append(f.labelsUpdated, fmt.Sprintf("%s/%s:%s:%s", owner, repo, label.Name, label.Color))
+	return nil
+}
+
+func (f *fgc) ListReviews(org, repo string, number int) ([]*scm.Review, error) {
+	return nil, f.reviewsErr
+}
+
 // TestDividePool ensures that subpools returned by dividePool satisfy a few
 // important invariants.
 func TestDividePool(t *testing.T) {
@@ -981,6 +1047,8 @@ func TestTakeAction(t *testing.T) {
 		batchMerges  []int
 		presubmits   map[int][]config.Presubmit
 		mergeErrs    map[int]error
+		// providerDegraded is true if the status page of the git provider reports it is degraded
+		providerDegraded bool
 
 		merged           int
 		triggered        int
@@ -1133,6 +1201,43 @@ func TestTakeAction(t *testing.T) {
 			triggered: 0,
 			action:    MergeBatch,
 		},
+		{
+			name: "successful batch, provider degraded, should not merge",
+
+			batchPending: true,
+			successes:    []int{0, 1},
+			pendings:     []int{2, 3},
+			nones:        []int{4, 5},
+			batchMerges:  []int{6, 7, 8},
+			presubmits: map[int][]config.Presubmit{
+				100: {
+					{Reporter: config.Reporter{Context: "foo"}},
+					{Reporter: config.Reporter{Context: "if-changed"}},
+				},
+			},
+			providerDegraded: true,
+			merged:           0,
+			triggered:        0,
+			action:           Wait,
+		},
+		{
+			name: "successful serial, provider degraded, should not merge",
+
+			batchPending: false,
+			successes:    []int{1},
+			pendings:     []int{},
+			nones:        []int{},
+			batchMerges:  []int{},
+			presubmits: map[int][]config.Presubmit{
+				100: {
+					{Reporter: config.Reporter{Context: "foo"}},
+				},
+			},
+			providerDegraded: true,
+			merged:           0,
+			triggered:        0,
+			action:           Wait,
+		},
 		{
 			name: "one PR that triggers RunIfChangedJob",
 
@@ -1359,6 +1464,14 @@ func TestTakeAction(t *testing.T) {
 				launcherClient: fakeLauncher,
 				lhClient:       fakeLighthouseClient,
 			}
+			if tc.providerDegraded {
+				statusPage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}))
+				defer statusPage.Close()
+				c.providerStatus = providerstatus.New(providerstatus.Options{URL: statusPage.URL})
+				c.providerStatus.Check()
+			}
 			var batchPending []PullRequest
 			if tc.batchPending {
 				batchPending = []PullRequest{{}}
@@ -1376,7 +1489,11 @@ func TestTakeAction(t *testing.T) {
 			var batchJobs []*v1alpha1.LighthouseJob
 			for _, activity := range fakeLauncher.Pipelines {
 				pjSha := activity.Spec.Refs.Pulls[0].SHA
-				if scm.StatePending.String() != fgc.combinedStatus[pjSha][activity.Spec.Context].status {
+				if activity.Spec.Type == config.BatchJob {
+					if status, ok := fgc.combinedStatus[pjSha][activity.Spec.Context]; ok {
+						t.Errorf("Batch status reported as %s on the first PR for context %s", status.status, activity.Spec.Context)
+					}
+				} else if scm.StatePending.String() != fgc.combinedStatus[pjSha][activity.Spec.Context].status {
 					t.Errorf("Status not set to %s for context %s, is %s instead", scm.StatePending.String(), activity.Spec.Context,
 						fgc.combinedStatus[pjSha][activity.Spec.Context].status)
 				}
@@ -2465,6 +2582,20 @@ func TestPrepareMergeDetails(t *testing.T) {
 			CommitTitle:   "1: my commit title",
 			CommitMessage: "SHA - my commit body",
 		},
+	}, {
+		name: "Conventional commit template in YAML block scalars",
+		tpl: config.KeeperMergeCommitTemplate{
+			Title: getTemplate("CommitTitle", "feat: {{ .Title }} (#{{ .Number }})\n"),
+			Body:  getTemplate("CommitBody", "{{ .Body }}\n\nMerged-by: keeper\n"),
+		},
+		pr:          pr,
+		mergeMethod: "squash",
+		expected: scmprovider.MergeDetails{
+			SHA:           "SHA",
+			MergeMethod:   "squash",
+			CommitTitle:   "feat: my commit title (#1)",
+			CommitMessage: "my commit body\n\nMerged-by: keeper",
+		},
 	}, {
 		name: "Commit template uses nonexistent fields",
 		tpl: config.KeeperMergeCommitTemplate{
@@ -2782,3 +2913,57 @@ func TestReposToQueries(t *testing.T) {
 	assert.Equal(t, 1, len(queryMap["c"]))
 	assert.Equal(t, secondQuery, queryMap["c"][0])
 }
+
+func TestListLighthouseJobsPaginates(t *testing.T) {
+	fakeLighthouseClient := fake.NewSimpleClientset()
+	calls := 0
+	fakeLighthouseClient.PrependReactor("list", "lighthousejobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		assert.Equal(t, util.ActiveJobsSelector, action.(clienttesting.ListAction).GetListRestrictions().Labels.String())
+		list := &v1alpha1.LighthouseJobList{Items: []v1alpha1.LighthouseJob{{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("job-%d", calls)}}}}
+		if calls == 0 {
+			list.Continue = "next"
+		}
+		calls++
+		return true, list, nil
+	})
+	c := &DefaultController{lhClient: fakeLighthouseClient, ns: "jx"}
+
+	var pages [][]string
+	err := c.listLighthouseJobs(util.ActiveJobsSelector, func(lhjs []v1alpha1.LighthouseJob) {
+		var names []string
+		for _, lhj := range lhjs {
+			names = append(names, lhj.Name)
+		}
+		pages = append(pages, names)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"job-0"}, {"job-1"}}, pages)
+	assert.Equal(t, 2, calls)
+}
+
+func TestQueriesBaseBranch(t *testing.T) {
+	master := config.KeeperQuery{IncludedBranches: []string{"master"}}
+	assert.Equal(t, "master", queriesBaseBranch([]config.KeeperQuery{master, master}))
+	assert.Equal(t, "", queriesBaseBranch([]config.KeeperQuery{master, {IncludedBranches: []string{"release"}}}))
+	assert.Equal(t, "", queriesBaseBranch([]config.KeeperQuery{master, {}}), "the pull requests of every branch are needed")
+	assert.Equal(t, "", queriesBaseBranch([]config.KeeperQuery{{IncludedBranches: []string{"master", "release"}}}))
+}
+
+func TestSetChanges(t *testing.T) {
+	c := &DefaultController{
+		changedFiles: &changedFilesAgent{
+			spc:             &fgc{},
+			changeCache:     map[changeCacheKey][]string{},
+			nextChangeCache: map[changeCacheKey][]string{},
+		},
+	}
+	sp := subpool{log: logrus.WithField("test", t.Name()), org: "o", repo: "r"}
+	spec := v1alpha1.LighthouseJobSpec{}
+	c.setChanges(&spec, sp, []PullRequest{{Number: 100}, {Number: 101}})
+	if expected := []string{"CHANGED"}; !reflect.DeepEqual(spec.ChangedPaths, expected) {
+		t.Errorf("expected changed paths %v, got %v", expected, spec.ChangedPaths)
+	}
+	if expected := []string{"."}; !reflect.DeepEqual(spec.ChangedModules, expected) {
+		t.Errorf("expected changed modules %v, got %v", expected, spec.ChangedModules)
+	}
+}
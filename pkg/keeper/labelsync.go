@@ -0,0 +1,109 @@
+package keeper
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// LabelSyncer reconciles the labels declared in the labels section of the lighthouse settings onto the
+// repositories keeper merges into, so that the labels required by the plugins and queries, such as lgtm,
+// approved or hold, exist with the same color and description everywhere.
+//
+// The repositories are the ones named by the keeper queries and the ones of the PRs found by the queries of
+// orgs. Each repository is reconciled at most once per period, after a full sync. The missing labels are
+// created and the color and description of the existing ones updated, the other labels are left alone.
+// A single LabelSyncer is meant to be shared between all the keeper controllers of a process.
+type LabelSyncer struct {
+	period   time.Duration
+	settings settings.Getter
+	now      func() time.Time
+	// synced are the times the repositories were last reconciled, keyed by org/repo
+	synced map[string]time.Time
+	sync.Mutex
+}
+
+// NewLabelSyncer creates a LabelSyncer reconciling the labels of the settings every period. It returns nil
+// if the period is not positive, which disables the label sync.
+func NewLabelSyncer(period time.Duration, settings settings.Getter) *LabelSyncer {
+	if period <= 0 || settings == nil {
+		return nil
+	}
+	return &LabelSyncer{
+		period:   period,
+		settings: settings,
+		now:      time.Now,
+		synced:   map[string]time.Time{},
+	}
+}
+
+// Sync reconciles the labels of the repositories which were not reconciled during the last period
+func (s *LabelSyncer) Sync(spc scmProviderClient, repos sets.String, log *logrus.Entry) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	labels := s.settings().Labels
+	for _, fullName := range repos.List() {
+		if last, ok := s.synced[fullName]; ok && s.now().Sub(last) < s.period {
+			continue
+		}
+		org, repo := scm.Split(fullName)
+		declared := labels.For(org, repo)
+		if len(declared) == 0 {
+			continue
+		}
+		if err := syncRepoLabels(spc, org, repo, declared); err != nil {
+			log.WithError(err).WithField("repo", fullName).Warn("Failed to sync the labels.")
+			continue
+		}
+		s.synced[fullName] = s.now()
+	}
+}
+
+// syncRepoLabels creates the missing labels of the repository and updates the ones which differ
+func syncRepoLabels(spc scmProviderClient, org, repo string, declared []settings.Label) error {
+	existing, err := spc.GetRepoLabels(org, repo)
+	if err != nil {
+		return err
+	}
+	byName := map[string]*scm.Label{}
+	for _, l := range existing {
+		byName[strings.ToLower(l.Name)] = l
+	}
+	for _, label := range declared {
+		color := strings.ToLower(strings.TrimPrefix(label.Color, "#"))
+		current, ok := byName[strings.ToLower(label.Name)]
+		if !ok {
+			if err := spc.CreateRepoLabel(org, repo, &scm.Label{Name: label.Name, Color: color, Description: label.Description}); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.ToLower(strings.TrimPrefix(current.Color, "#")) == color && current.Description == label.Description {
+			continue
+		}
+		if err := spc.UpdateRepoLabel(org, repo, &scm.Label{ID: current.ID, Name: current.Name, Color: color, Description: label.Description}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// labelSyncRepos returns the repositories named by the queries and the ones of the PRs they found
+func labelSyncRepos(queries config.KeeperQueries, prs map[string]PullRequest) sets.String {
+	_, repos := queries.OrgExceptionsAndRepos()
+	answer := sets.NewString(repos.List()...)
+	for _, pr := range prs {
+		answer.Insert(string(pr.Repository.NameWithOwner))
+	}
+	answer.Delete("")
+	return answer
+}
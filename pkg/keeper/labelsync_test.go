@@ -0,0 +1,63 @@
+package keeper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestLabelSyncer(t *testing.T) {
+	var disabled *LabelSyncer
+	assert.Nil(t, NewLabelSyncer(0, nil))
+	disabled.Sync(&fgc{}, sets.NewString("org/repo"), logrus.NewEntry(logrus.StandardLogger()))
+
+	s := &settings.Config{Labels: settings.LabelSync{
+		Default: []settings.Label{
+			{Name: "lgtm", Color: "15dd18", Description: "Looks good to me"},
+			{Name: "approved", Color: "#0FFA16"},
+			{Name: "hold", Color: "e11d21"},
+		},
+		Repos: map[string][]settings.Label{
+			"org/repo": {{Name: "area/docs", Color: "0052cc"}},
+		},
+	}}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	syncer := NewLabelSyncer(time.Hour, func() *settings.Config { return s })
+	syncer.now = func() time.Time { return now }
+	spc := &fgc{repoLabels: map[string][]*scm.Label{
+		"org/repo": {
+			{Name: "LGTM", Color: "15dd18", Description: "Looks good to me"},
+			{Name: "approved", Color: "ededed"},
+			{Name: "custom", Color: "ffffff"},
+		},
+	}}
+	log := logrus.NewEntry(logrus.StandardLogger())
+
+	syncer.Sync(spc, sets.NewString("org/repo", "org/other"), log)
+	assert.Equal(t, []string{"org/other:lgtm:15dd18", "org/other:approved:0ffa16", "org/other:hold:e11d21", "org/repo:hold:e11d21", "org/repo:area/docs:0052cc"}, spc.labelsCreated)
+	assert.Equal(t, []string{"org/repo:approved:0ffa16"}, spc.labelsUpdated)
+
+	spc.labelsCreated, spc.labelsUpdated = nil, nil
+	now = now.Add(30 * time.Minute)
+	syncer.Sync(spc, sets.NewString("org/repo", "org/other"), log)
+	assert.Empty(t, spc.labelsCreated, "the repositories are reconciled once per period")
+
+	now = now.Add(time.Hour)
+	syncer.Sync(spc, sets.NewString("org/repo"), log)
+	assert.Len(t, spc.labelsCreated, 2, "the labels are reconciled again after a period")
+}
+
+func TestLabelSyncRepos(t *testing.T) {
+	queries := config.KeeperQueries{{Orgs: []string{"org"}}, {Repos: []string{"another/repo"}}}
+	prs := map[string]PullRequest{
+		"org/repo#1": {Repository: Repository{NameWithOwner: githubql.String("org/repo")}},
+	}
+	assert.Equal(t, []string{"another/repo", "org/repo"}, labelSyncRepos(queries, prs).List())
+}
@@ -0,0 +1,154 @@
+package keeper
+
+import (
+	"net/url"
+	"sort"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/messages"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// mergeAuditCommentTag is used to identify merge audit comments
+	mergeAuditCommentTag = "<!-- keeper merge audit -->"
+	// mergeAuditMessage is the message catalog ID of the merge audit comment
+	mergeAuditMessage = "keeper.mergeAudit"
+
+	defaultMergeAuditComment = `Merged by keeper.
+
+- pool: ` + "`{{ .Pool }}`" + `
+- base SHA tested: ` + "`{{ .BaseSHA }}`" + `
+{{- if .Batch }}
+- batch: {{ range $i, $n := .Batch }}{{ if $i }}, {{ end }}#{{ $n }}{{ end }}
+{{- end }}
+{{- if .Contexts }}
+- required contexts:
+{{- range .Contexts }}
+  - ` + "`{{ .Name }}`" + `: {{ .State }}
+{{- end }}
+{{- end }}
+{{- if .HistoryURL }}
+- history: {{ .HistoryURL }}
+{{- end }}
+`
+)
+
+// MergeAuditor posts a comment on each PR merged by keeper recording what was
+// tested before the merge, so merges can be traced for compliance.
+type MergeAuditor struct {
+	repos      sets.String
+	historyURL string
+}
+
+// auditContext is a required context and its final state when the PR was merged
+type auditContext struct {
+	Name  string
+	State string
+}
+
+// NewMergeAuditor creates a MergeAuditor for the given orgs and org/repos. It
+// returns nil if no repositories are specified, which disables the audit comments.
+// If historyURL is not empty it is the external URL of the keeper history endpoint.
+func NewMergeAuditor(repos []string, historyURL string) *MergeAuditor {
+	if len(repos) == 0 {
+		return nil
+	}
+	return &MergeAuditor{
+		repos:      sets.NewString(repos...),
+		historyURL: historyURL,
+	}
+}
+
+// Enabled returns true if merge audit comments are enabled for the given repository
+func (a *MergeAuditor) Enabled(org, repo string) bool {
+	return a != nil && (a.repos.Has(org) || a.repos.Has(org+"/"+repo))
+}
+
+// Comment returns the merge audit comment of a PR merged from the given pool
+func (a *MergeAuditor) Comment(pool, baseSHA string, batch []int, contexts []auditContext) string {
+	historyURL := ""
+	if a.historyURL != "" {
+		historyURL = a.historyURL + "?pool=" + url.QueryEscape(pool)
+	}
+	data := map[string]interface{}{
+		"Pool":       pool,
+		"BaseSHA":    baseSHA,
+		"Batch":      batch,
+		"Contexts":   contexts,
+		"HistoryURL": historyURL,
+	}
+	return mergeAuditCommentTag + "\n" + messages.Render(mergeAuditMessage, defaultMergeAuditComment, data)
+}
+
+// auditMerge comments on a merged PR with the details of the merge
+func (c *DefaultController) auditMerge(sp subpool, pr PullRequest, prs []PullRequest) {
+	var batch []int
+	var contexts []auditContext
+	if len(prs) > 1 {
+		for _, p := range prs {
+			batch = append(batch, int(p.Number))
+		}
+		contexts = batchAuditContexts(sp, prs)
+	} else {
+		contexts = c.headAuditContexts(sp, pr)
+	}
+	comment := c.mergeAuditor.Comment(poolKey(sp.org, sp.repo, sp.branch), sp.sha, batch, contexts)
+	if err := c.spc.CreateComment(sp.org, sp.repo, int(pr.Number), true, comment); err != nil {
+		sp.log.WithFields(pr.logFields()).WithError(err).Error("Error commenting on merged PR with the merge audit.")
+	}
+}
+
+// headAuditContexts returns the required contexts of the head commit of the PR
+func (c *DefaultController) headAuditContexts(sp subpool, pr PullRequest) []auditContext {
+	contexts, err := headContexts(sp.log, c.spc, &pr)
+	if err != nil {
+		sp.log.WithFields(pr.logFields()).WithError(err).Warn("Error getting the contexts of the merged PR.")
+		return nil
+	}
+	cc := contextCheckerForPR(sp.cc, &pr)
+	var result []auditContext
+	for _, ctx := range contexts {
+		name := string(ctx.Context)
+		if name == GetStatusContextLabel() || (cc != nil && cc.IsOptional(name)) {
+			continue
+		}
+		result = append(result, auditContext{Name: name, State: string(ctx.State)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// batchAuditContexts returns the required contexts of the batch jobs which tested the PRs
+func batchAuditContexts(sp subpool, prs []PullRequest) []auditContext {
+	shas := map[int]string{}
+	for _, pr := range prs {
+		shas[int(pr.Number)] = string(pr.HeadRefOID)
+	}
+	states := map[string]simpleState{}
+	for _, pj := range sp.pjs {
+		if pj.Spec.Type != config.BatchJob || pj.Spec.Refs == nil || len(pj.Spec.Refs.Pulls) != len(prs) {
+			continue
+		}
+		matches := true
+		for _, pull := range pj.Spec.Refs.Pulls {
+			if sha, ok := shas[pull.Number]; !ok || sha != pull.SHA {
+				matches = false
+				break
+			}
+		}
+		if !matches || (sp.cc != nil && sp.cc.IsOptional(pj.Spec.Context)) {
+			continue
+		}
+		state := toSimpleState(pj.Status.State)
+		if s, ok := states[pj.Spec.Context]; !ok || s == failureState || state == successState {
+			states[pj.Spec.Context] = state
+		}
+	}
+	var result []auditContext
+	for name, state := range states {
+		result = append(result, auditContext{Name: name, State: string(state)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,89 @@
+package keeper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeAudit(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	cfg := &config.Config{}
+	cfgAgent := &config.Agent{}
+	cfgAgent.Set(cfg)
+
+	single := testPR("org", "repo", "master", 1, githubql.MergeableStateMergeable)
+	batchA := testPR("org", "repo", "master", 2, githubql.MergeableStateMergeable)
+	batchB := testPR("org", "repo", "master", 3, githubql.MergeableStateMergeable)
+	batchPulls := []v1alpha1.Pull{{Number: 2, SHA: "SHA"}, {Number: 3, SHA: "SHA"}}
+	batchJob := func(context string, state v1alpha1.PipelineState) v1alpha1.LighthouseJob {
+		return v1alpha1.LighthouseJob{
+			Spec: v1alpha1.LighthouseJobSpec{
+				Type:    config.BatchJob,
+				Context: context,
+				Refs:    &v1alpha1.Refs{Org: "org", Repo: "repo", BaseRef: "master", Pulls: batchPulls},
+			},
+			Status: v1alpha1.LighthouseJobStatus{State: state},
+		}
+	}
+	otherBatch := batchJob("other", v1alpha1.SuccessState)
+	otherBatch.Spec.Refs = &v1alpha1.Refs{Pulls: []v1alpha1.Pull{{Number: 2, SHA: "SHA"}}}
+
+	sp := subpool{
+		log:    logrus.WithField("test", t.Name()),
+		org:    "org",
+		repo:   "repo",
+		branch: "master",
+		sha:    "base",
+		pjs: []v1alpha1.LighthouseJob{
+			batchJob("unit", v1alpha1.FailureState),
+			batchJob("unit", v1alpha1.SuccessState),
+			batchJob("lint", v1alpha1.SuccessState),
+			otherBatch,
+		},
+	}
+
+	fgc := &fgc{}
+	c := &DefaultController{
+		config:       cfgAgent.Config,
+		spc:          fgc,
+		logger:       logrus.WithField("controller", "sync"),
+		mergeAuditor: NewMergeAuditor([]string{"org/repo"}, "https://keeper.example.com/history"),
+	}
+	require.NoError(t, c.mergePRs(sp, []PullRequest{single}))
+	require.Len(t, fgc.comments, 1)
+	comment := fgc.comments[0]
+	assert.Contains(t, comment, mergeAuditCommentTag)
+	assert.Contains(t, comment, "- pool: `org/repo:master`")
+	assert.Contains(t, comment, "- base SHA tested: `base`")
+	assert.NotContains(t, comment, "- batch:")
+	assert.Contains(t, comment, "  - `context`: SUCCESS")
+	assert.Contains(t, comment, "- history: https://keeper.example.com/history?pool=org%2Frepo%3Amaster")
+
+	fgc.comments = nil
+	require.NoError(t, c.mergePRs(sp, []PullRequest{batchA, batchB}))
+	require.Len(t, fgc.comments, 2)
+	comment = fgc.comments[1]
+	assert.Contains(t, comment, "- batch: #2, #3")
+	assert.Contains(t, comment, "- required contexts:\n  - `lint`: success\n  - `unit`: success\n")
+	assert.NotContains(t, comment, "`other`")
+}
+
+func TestMergeAuditorDisabled(t *testing.T) {
+	auditor := NewMergeAuditor(nil, "")
+	assert.Nil(t, auditor)
+	assert.False(t, auditor.Enabled("org", "repo"))
+
+	auditor = NewMergeAuditor([]string{"org"}, "")
+	assert.True(t, auditor.Enabled("org", "repo"))
+	assert.False(t, auditor.Enabled("other", "repo"))
+	assert.NotContains(t, auditor.Comment("org/repo:master", "base", nil, nil), "history")
+}
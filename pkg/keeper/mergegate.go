@@ -0,0 +1,91 @@
+package keeper
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MergeGate serializes merges so that at most one PR is merged per repository
+// within a configurable interval, optionally waiting for an external deploy
+// health check to pass before allowing the next merge.
+//
+// A single MergeGate is meant to be shared between all the keeper controllers
+// of a process so that the last merge time survives controllers being recreated.
+type MergeGate struct {
+	// Interval is the minimum time between two merges into the same repository.
+	Interval time.Duration
+	// DeployHealthURL is an optional URL which must return a 2xx response before
+	// another merge is performed. The org and repo are passed as query parameters.
+	DeployHealthURL string
+
+	client    *http.Client
+	now       func() time.Time
+	lastMerge map[string]time.Time
+	lock      sync.Mutex
+}
+
+// NewMergeGate creates a MergeGate. It returns nil if neither an interval nor a
+// deploy health URL is specified, which disables serialized merges.
+func NewMergeGate(interval time.Duration, deployHealthURL string) *MergeGate {
+	if interval <= 0 && deployHealthURL == "" {
+		return nil
+	}
+	return &MergeGate{
+		Interval:        interval,
+		DeployHealthURL: deployHealthURL,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		now:             time.Now,
+		lastMerge:       make(map[string]time.Time),
+	}
+}
+
+// Allow returns nil if a merge into the given repository can proceed now,
+// otherwise an error describing why the merge has to wait.
+func (g *MergeGate) Allow(org, repo string) error {
+	key := org + "/" + repo
+	g.lock.Lock()
+	last, ok := g.lastMerge[key]
+	g.lock.Unlock()
+	if ok && g.Interval > 0 {
+		if next := last.Add(g.Interval); g.now().Before(next) {
+			return fmt.Errorf("last merge into %s was at %s, next merge allowed after %s", key, last.Format(time.RFC3339), next.Format(time.RFC3339))
+		}
+	}
+	if g.DeployHealthURL == "" {
+		return nil
+	}
+	return g.checkDeployHealth(org, repo)
+}
+
+// RecordMerge records that a PR has just been merged into the given repository.
+func (g *MergeGate) RecordMerge(org, repo string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.lastMerge[org+"/"+repo] = g.now()
+}
+
+func (g *MergeGate) checkDeployHealth(org, repo string) error {
+	u, err := url.Parse(g.DeployHealthURL)
+	if err != nil {
+		return errors.Wrapf(err, "invalid deploy health URL %s", g.DeployHealthURL)
+	}
+	q := u.Query()
+	q.Set("org", org)
+	q.Set("repo", repo)
+	u.RawQuery = q.Encode()
+
+	resp, err := g.client.Get(u.String())
+	if err != nil {
+		return errors.Wrapf(err, "failed to check deploy health for %s/%s", org, repo)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("deploy for %s/%s is not healthy: %s returned status %d", org, repo, g.DeployHealthURL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package keeper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMergeGateDisabled(t *testing.T) {
+	assert.Nil(t, NewMergeGate(0, ""))
+}
+
+func TestMergeGateInterval(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := NewMergeGate(10*time.Minute, "")
+	g.now = func() time.Time { return now }
+
+	assert.NoError(t, g.Allow("org", "repo"), "no previous merge")
+
+	g.RecordMerge("org", "repo")
+	now = now.Add(5 * time.Minute)
+	assert.Error(t, g.Allow("org", "repo"), "merged 5 minutes ago")
+	assert.NoError(t, g.Allow("org", "other"), "different repo")
+
+	now = now.Add(6 * time.Minute)
+	assert.NoError(t, g.Allow("org", "repo"), "merged 11 minutes ago")
+}
+
+func TestMergeGateDeployHealth(t *testing.T) {
+	healthy := false
+	var gotOrg, gotRepo string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotOrg = r.URL.Query().Get("org")
+		gotRepo = r.URL.Query().Get("repo")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	g := NewMergeGate(0, server.URL+"/health")
+	assert.Error(t, g.Allow("org", "repo"))
+	assert.Equal(t, "org", gotOrg)
+	assert.Equal(t, "repo", gotRepo)
+
+	healthy = true
+	assert.NoError(t, g.Allow("org", "repo"))
+}
@@ -0,0 +1,177 @@
+package keeper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/pkg/errors"
+	githubql "github.com/shurcooL/githubv4"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DefaultNeedsRebaseLabel is the default label added to PRs which have merge conflicts. It differs from
+	// the needs-rebase label of the external plugin which is commonly used in the missingLabels of the queries
+	DefaultNeedsRebaseLabel = "merge-conflict"
+
+	// defaultRebaseCommentTemplate is the default comment posted on PRs when they start to have merge conflicts
+	defaultRebaseCommentTemplate = `@{{ .Author }}: this PR has merge conflicts with the ` + "`{{ .BaseRef }}`" + ` branch and needs to be rebased.
+
+You can rebase it by running:
+
+` + "```sh" + `
+git fetch {{ .CloneURL }} {{ .BaseRef }}
+git checkout {{ .HeadRef }}
+git rebase FETCH_HEAD
+git push --force-with-lease
+` + "```" + `
+
+The ` + "`{{ .Label }}`" + ` label will be removed automatically once the conflicts are resolved.
+`
+)
+
+// RebaseAdvisor adds a label to the PRs in the keeper pool which have merge
+// conflicts with their base branch, posts a comment explaining how to rebase
+// them and removes the label again once the PR can be merged.
+//
+// Note that PRs are only seen by keeper if they match one of the keeper queries,
+// so the label should not be used in the missingLabels of a query.
+type RebaseAdvisor struct {
+	// Label is the label added to PRs which need to be rebased
+	Label string
+
+	repos    sets.String
+	template *template.Template
+}
+
+// rebaseCommentData is the data passed to the rebase comment template
+type rebaseCommentData struct {
+	Org      string
+	Repo     string
+	Number   int
+	Author   string
+	BaseRef  string
+	HeadRef  string
+	CloneURL string
+	Label    string
+}
+
+// NewRebaseAdvisor creates a RebaseAdvisor for the given orgs and org/repos. It
+// returns nil if no repositories are specified, which disables the advisories.
+// If templateFile is not empty it contains the Go template of the comment.
+func NewRebaseAdvisor(repos []string, label, templateFile string) (*RebaseAdvisor, error) {
+	if len(repos) == 0 {
+		return nil, nil
+	}
+	if label == "" {
+		label = DefaultNeedsRebaseLabel
+	}
+	text := defaultRebaseCommentTemplate
+	if templateFile != "" {
+		data, err := ioutil.ReadFile(templateFile) // #nosec
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read rebase comment template %s", templateFile)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("rebase").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse rebase comment template")
+	}
+	return &RebaseAdvisor{
+		Label:    label,
+		repos:    sets.NewString(repos...),
+		template: tmpl,
+	}, nil
+}
+
+// ConflictingQueries returns the indexes of the keeper queries which exclude the PRs with the
+// label. Keeper stops seeing the PRs of those queries once they are labeled, so the label would
+// never be removed again.
+func (a *RebaseAdvisor) ConflictingQueries(queries config.KeeperQueries) []int {
+	if a == nil {
+		return nil
+	}
+	var answer []int
+	for i, q := range queries {
+		for _, l := range q.MissingLabels {
+			if strings.EqualFold(l, a.Label) {
+				answer = append(answer, i)
+				break
+			}
+		}
+	}
+	return answer
+}
+
+// Enabled returns true if rebase advisories are enabled for the given repository
+func (a *RebaseAdvisor) Enabled(org, repo string) bool {
+	return a != nil && (a.repos.Has(org) || a.repos.Has(org+"/"+repo))
+}
+
+// Comment returns the comment posted on a PR which needs to be rebased
+func (a *RebaseAdvisor) Comment(pr *PullRequest) (string, error) {
+	data := rebaseCommentData{
+		Org:      string(pr.Repository.Owner.Login),
+		Repo:     string(pr.Repository.Name),
+		Number:   int(pr.Number),
+		Author:   string(pr.Author.Login),
+		BaseRef:  string(pr.BaseRef.Name),
+		HeadRef:  string(pr.HeadRefName),
+		CloneURL: strings.TrimSuffix(string(pr.Repository.URL), "/") + ".git",
+		Label:    a.Label,
+	}
+	var buf bytes.Buffer
+	if err := a.template.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "failed to render rebase comment for %s", prKey(pr))
+	}
+	return buf.String(), nil
+}
+
+// adviseRebase adds or removes the needs rebase label of the pool PRs as their
+// mergeable state changes, commenting on the PR when the label is added
+func (c *DefaultController) adviseRebase(prs map[string]PullRequest) {
+	for _, pr := range prs {
+		org := string(pr.Repository.Owner.Login)
+		repo := string(pr.Repository.Name)
+		if !c.rebaseAdvisor.Enabled(org, repo) {
+			continue
+		}
+		p := pr
+		label := c.rebaseAdvisor.Label
+		labeled := hasLabel(&p, label)
+		log := c.logger.WithField("pr", prKey(&p))
+		switch {
+		case pr.Mergeable == githubql.MergeableStateConflicting && !labeled:
+			comment, err := c.rebaseAdvisor.Comment(&p)
+			if err != nil {
+				log.WithError(err).Error("Error creating rebase comment.")
+				continue
+			}
+			if err := c.spc.AddLabel(org, repo, int(pr.Number), label, true); err != nil {
+				log.WithError(err).Errorf("Error adding label %s.", label)
+				continue
+			}
+			if err := c.spc.CreateComment(org, repo, int(pr.Number), true, comment); err != nil {
+				log.WithError(err).Error("Error commenting on PR which needs a rebase.")
+			}
+		case pr.Mergeable == githubql.MergeableStateMergeable && labeled:
+			if err := c.spc.RemoveLabel(org, repo, int(pr.Number), label, true); err != nil {
+				log.WithError(err).Errorf("Error removing label %s.", label)
+			}
+		}
+	}
+}
+
+// hasLabel returns true if the PR has the given label
+func hasLabel(pr *PullRequest, label string) bool {
+	for _, l := range pr.Labels.Nodes {
+		if strings.EqualFold(string(l.Name), label) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdviseRebase(t *testing.T) {
+	advisor, err := NewRebaseAdvisor([]string{"org", "other/enabled"}, "", "")
+	require.NoError(t, err)
+	require.NotNil(t, advisor)
+
+	conflicting := testPR("org", "repo", "master", 1, githubql.MergeableStateConflicting)
+	conflicting.Author.Login = "author"
+	conflicting.HeadRefName = "feature"
+	conflicting.Repository.URL = "https://github.com/org/repo"
+
+	alreadyLabeled := testPR("org", "repo", "master", 2, githubql.MergeableStateConflicting)
+	alreadyLabeled.Labels.Nodes = append(alreadyLabeled.Labels.Nodes, struct{ Name githubql.String }{Name: DefaultNeedsRebaseLabel})
+
+	resolved := testPR("other", "enabled", "master", 3, githubql.MergeableStateMergeable)
+	resolved.Labels.Nodes = append(resolved.Labels.Nodes, struct{ Name githubql.String }{Name: DefaultNeedsRebaseLabel})
+
+	unknown := testPR("other", "enabled", "master", 4, githubql.MergeableStateUnknown)
+	unknown.Labels.Nodes = append(unknown.Labels.Nodes, struct{ Name githubql.String }{Name: DefaultNeedsRebaseLabel})
+
+	disabled := testPR("other", "disabled", "master", 5, githubql.MergeableStateConflicting)
+
+	fgc := &fgc{}
+	c := &DefaultController{
+		spc:           fgc,
+		logger:        logrus.WithField("controller", "sync"),
+		rebaseAdvisor: advisor,
+	}
+	c.adviseRebase(byRepoAndNumber([]PullRequest{conflicting, alreadyLabeled, resolved, unknown, disabled}))
+
+	assert.Equal(t, []string{"org/repo#1:merge-conflict"}, fgc.labelsAdded)
+	assert.Equal(t, []string{"other/enabled#3:merge-conflict"}, fgc.labelsRemoved)
+	if assert.Len(t, fgc.comments, 1) {
+		assert.Contains(t, fgc.comments[0], "@author")
+		assert.Contains(t, fgc.comments[0], "git fetch https://github.com/org/repo.git master")
+		assert.Contains(t, fgc.comments[0], "git checkout feature")
+	}
+}
+
+func TestNewRebaseAdvisorDisabled(t *testing.T) {
+	advisor, err := NewRebaseAdvisor(nil, "", "")
+	require.NoError(t, err)
+	assert.Nil(t, advisor)
+	assert.False(t, advisor.Enabled("org", "repo"))
+}
+
+func TestRebaseAdvisorConflictingQueries(t *testing.T) {
+	advisor, err := NewRebaseAdvisor([]string{"org"}, "", "")
+	require.NoError(t, err)
+	queries := config.KeeperQueries{
+		{Orgs: []string{"org"}, MissingLabels: []string{"do-not-merge", "needs-rebase"}},
+		{Orgs: []string{"org"}, MissingLabels: []string{"Merge-Conflict"}},
+	}
+	assert.Equal(t, []int{1}, advisor.ConflictingQueries(queries))
+
+	var disabled *RebaseAdvisor
+	assert.Empty(t, disabled.ConflictingQueries(queries))
+}
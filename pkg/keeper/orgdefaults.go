@@ -0,0 +1,96 @@
+package keeper
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+)
+
+// OrgDefaultQueries returns a getter of the configuration in which the keeper queries marked as org
+// defaults in the settings exclude the repositories of their orgs named by the other queries, so that an
+// org query is inherited by all the repositories of the org except those which define their own queries.
+func OrgDefaultQueries(cfg config.Getter, settingsGetter settings.Getter) config.Getter {
+	if settingsGetter == nil {
+		return cfg
+	}
+	o := &orgDefaults{config: cfg, settings: settingsGetter}
+	return o.get
+}
+
+// orgDefaults caches the configuration with the org default queries applied until either the
+// configuration or the settings are reloaded
+type orgDefaults struct {
+	config   config.Getter
+	settings settings.Getter
+
+	lock        sync.Mutex
+	rawConfig   *config.Config
+	rawSettings *settings.Config
+	applied     *config.Config
+}
+
+func (o *orgDefaults) get() *config.Config {
+	cfg := o.config()
+	s := o.settings()
+	if cfg == nil || s == nil {
+		return cfg
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if cfg != o.rawConfig || s != o.rawSettings {
+		o.rawConfig = cfg
+		o.rawSettings = s
+		o.applied = applyOrgDefaultQueries(cfg, &s.Keeper)
+	}
+	return o.applied
+}
+
+// applyOrgDefaultQueries returns a copy of the configuration where the org default queries exclude the
+// repositories the other queries name, or the configuration itself if there are no org default queries
+func applyOrgDefaultQueries(cfg *config.Config, k *settings.Keeper) *config.Config {
+	defaults := false
+	for i := range cfg.Keeper.Queries {
+		defaults = defaults || k.Query(i).OrgDefault
+	}
+	if !defaults {
+		return cfg
+	}
+	answer := *cfg
+	answer.Keeper.Queries = make(config.KeeperQueries, len(cfg.Keeper.Queries))
+	for i, q := range cfg.Keeper.Queries {
+		if k.Query(i).OrgDefault {
+			q.ExcludedRepos = overriddenRepos(cfg.Keeper.Queries, k, q)
+		}
+		answer.Keeper.Queries[i] = q
+	}
+	return &answer
+}
+
+// overriddenRepos returns the excluded repositories of the org default query along with the
+// repositories of its orgs which the queries which are not org defaults name
+func overriddenRepos(queries config.KeeperQueries, k *settings.Keeper, q config.KeeperQuery) []string {
+	answer := append([]string{}, q.ExcludedRepos...)
+	has := func(values []string, value string) bool {
+		for _, v := range values {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	}
+	for j, other := range queries {
+		if k.Query(j).OrgDefault {
+			continue
+		}
+		for _, repo := range other.Repos {
+			org, _ := scm.Split(repo)
+			if has(q.Orgs, org) && !has(answer, repo) {
+				answer = append(answer, repo)
+			}
+		}
+	}
+	return answer
+}
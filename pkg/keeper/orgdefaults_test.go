@@ -0,0 +1,31 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrgDefaultQueries(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Keeper.Queries = config.KeeperQueries{
+		{Orgs: []string{"org"}, ExcludedRepos: []string{"org/excluded"}, Labels: []string{"approved"}},
+		{Repos: []string{"org/special", "other/repo"}, Labels: []string{"approved", "lgtm"}},
+		{Orgs: []string{"other"}, Labels: []string{"approved"}},
+	}
+	s := &settings.Config{}
+
+	getter := OrgDefaultQueries(func() *config.Config { return cfg }, func() *settings.Config { return s })
+	assert.Equal(t, cfg, getter(), "the queries are unchanged without org defaults")
+
+	s = &settings.Config{Keeper: settings.Keeper{Queries: []settings.KeeperQuery{{OrgDefault: true}}}}
+	queries := getter().Keeper.Queries
+	assert.Equal(t, []string{"org/excluded", "org/special"}, queries[0].ExcludedRepos)
+	assert.Empty(t, queries[2].ExcludedRepos, "the queries which are not org defaults are unchanged")
+	assert.Equal(t, []string{"org/excluded"}, cfg.Keeper.Queries[0].ExcludedRepos, "the configuration is not modified")
+	assert.False(t, queries.QueryMap().ForRepo("org", "special")[0].Orgs != nil, "the repository only has its own query")
+	assert.Len(t, queries.QueryMap().ForRepo("org", "new"), 1)
+	assert.True(t, getter() == getter(), "the configuration is cached")
+}
@@ -0,0 +1,183 @@
+package keeper
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// PoolFilter excludes PRs from the keeper pool which would otherwise match a keeper
+// query, giving admins finer control over what enters the merge pool.
+//
+// PRs are excluded if their title matches one of the title regexes, e.g. `^\[WIP\]`,
+// or if all the files they change match one of the excluded paths, e.g. `docs/**`,
+// so that such PRs can be left to a separate process.
+type PoolFilter struct {
+	titles []*regexp.Regexp
+	paths  []string
+}
+
+// NewPoolFilter creates a PoolFilter. Paths are either `dir/**`, matching all files
+// below dir, or patterns as supported by path.Match. It returns nil if neither
+// titles nor paths are specified, which disables filtering.
+func NewPoolFilter(titles, paths []string) (*PoolFilter, error) {
+	if len(titles) == 0 && len(paths) == 0 {
+		return nil, nil
+	}
+	f := &PoolFilter{}
+	for _, t := range titles {
+		re, err := regexp.Compile(t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid excluded title regex %s", t)
+		}
+		f.titles = append(f.titles, re)
+	}
+	for _, p := range paths {
+		if _, err := path.Match(strings.TrimSuffix(p, "/**"), ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid excluded path %s", p)
+		}
+		f.paths = append(f.paths, p)
+	}
+	return f, nil
+}
+
+// ExcludesTitle returns true if the PR title matches one of the excluded title regexes
+func (f *PoolFilter) ExcludesTitle(title string) bool {
+	if f == nil {
+		return false
+	}
+	for _, re := range f.titles {
+		if re.MatchString(title) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludesFiles returns true if the changed files are all matched by the excluded paths
+func (f *PoolFilter) ExcludesFiles(files []string) bool {
+	if f == nil || len(f.paths) == 0 || len(files) == 0 {
+		return false
+	}
+	for _, file := range files {
+		if !f.matchesPath(file) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *PoolFilter) matchesPath(file string) bool {
+	for _, p := range f.paths {
+		if dir := strings.TrimSuffix(p, "/**"); dir != p {
+			if strings.HasPrefix(file, dir+"/") {
+				return true
+			}
+			continue
+		}
+		if match, _ := path.Match(p, file); match {
+			return true
+		}
+	}
+	return false
+}
+
+// The reasons why the pool filters exclude a PR.
+const (
+	ExcludedByTitle = "title"
+	ExcludedByPaths = "paths"
+)
+
+// PoolFilters are the pool filters of the keeper queries including the base branch of a PR. The
+// filter of a query which excludes nothing is nil.
+type PoolFilters []*PoolFilter
+
+// NewPoolFilters returns the pool filters of the keeper queries of the configuration which include
+// the branch of the repository. The filters of a query are configured by the lighthouse settings
+// of the query of the same index, if any. Invalid filters are logged and exclude nothing.
+func NewPoolFilters(cfg *config.Config, s *settings.Config, org, repo, branch string, log *logrus.Entry) PoolFilters {
+	if s == nil {
+		s = &settings.Config{}
+	}
+	var filters PoolFilters
+	for i, q := range cfg.Keeper.Queries {
+		if !q.ForRepo(org, repo) || (branch != "" && !queryAppliesToBranch(q, branch)) {
+			continue
+		}
+		qs := s.Keeper.Query(i)
+		f, err := NewPoolFilter(qs.ExcludedTitles, qs.ExcludedPaths)
+		if err != nil {
+			log.WithError(err).Errorf("Invalid pool filter of keeper query %d.", i)
+		}
+		filters = append(filters, f)
+	}
+	return filters
+}
+
+// Exclusion returns why the PR is excluded by the filters of all the queries, ExcludedByTitle or
+// ExcludedByPaths, or an empty string if one of the queries does not exclude it. The changed files
+// are only fetched when a query excludes paths.
+func (fs PoolFilters) Exclusion(title string, changedFiles config.ChangedFilesProvider) (string, error) {
+	if len(fs) == 0 {
+		return "", nil
+	}
+	reason := ExcludedByTitle
+	var files []string
+	fetched := false
+	for _, f := range fs {
+		if f.ExcludesTitle(title) {
+			continue
+		}
+		if f == nil || len(f.paths) == 0 {
+			return "", nil
+		}
+		if !fetched {
+			var err error
+			files, err = changedFiles()
+			if err != nil {
+				return "", err
+			}
+			fetched = true
+		}
+		if !f.ExcludesFiles(files) {
+			return "", nil
+		}
+		reason = ExcludedByPaths
+	}
+	return reason, nil
+}
+
+// filterExcludedPRs removes the PRs excluded by the pool filters of all the keeper queries from the
+// subpool. PRs whose changed files can't be fetched are kept, so that an error doesn't silently keep
+// them out of the pool.
+func (c *DefaultController) filterExcludedPRs(sp *subpool) {
+	filters := NewPoolFilters(c.config(), currentSettings(c.settings), sp.org, sp.repo, sp.branch, sp.log)
+	var toKeep []PullRequest
+	for _, pr := range sp.prs {
+		p := pr
+		log := sp.log.WithFields(p.logFields())
+		reason, err := filters.Exclusion(string(p.Title), c.changedFiles.prChanges(&p))
+		if err != nil {
+			log.WithError(err).Error("Getting changed files, keeping the PR in the pool.")
+		}
+		if reason != "" {
+			log.WithField("reason", reason).Debug("filtering out PR excluded from the pool")
+			continue
+		}
+		toKeep = append(toKeep, pr)
+	}
+	sp.prs = toKeep
+}
+
+// currentSettings returns the current lighthouse settings, which are empty without a getter
+func currentSettings(getter settings.Getter) *settings.Config {
+	if getter == nil {
+		return &settings.Config{}
+	}
+	return getter()
+}
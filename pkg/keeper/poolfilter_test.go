@@ -0,0 +1,71 @@
+package keeper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolFilter(t *testing.T) {
+	f, err := NewPoolFilter(nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, f)
+	assert.False(t, f.ExcludesTitle("[WIP] anything"))
+	assert.False(t, f.ExcludesFiles([]string{"docs/README.md"}))
+
+	_, err = NewPoolFilter([]string{"[WIP"}, nil)
+	assert.Error(t, err)
+
+	f, err = NewPoolFilter([]string{`^\[WIP\]`, `(?i)do not merge`}, []string{"docs/**", "*.md"})
+	require.NoError(t, err)
+
+	assert.True(t, f.ExcludesTitle("[WIP] add feature"))
+	assert.True(t, f.ExcludesTitle("Add feature - DO NOT MERGE"))
+	assert.False(t, f.ExcludesTitle("Add feature [WIP]"))
+
+	assert.True(t, f.ExcludesFiles([]string{"docs/guide/setup.md", "CHANGELOG.md"}))
+	assert.False(t, f.ExcludesFiles([]string{"docs/guide/setup.md", "pkg/keeper/keeper.go"}))
+	assert.False(t, f.ExcludesFiles([]string{"pkg/README.md"}), "patterns without a directory only match top level files")
+	assert.False(t, f.ExcludesFiles(nil))
+}
+
+func TestPoolFiltersExclusion(t *testing.T) {
+	cfg := effectiveTestConfig()
+	s := &settings.Config{}
+	s.Keeper.Queries = []settings.KeeperQuery{{ExcludedTitles: []string{`^\[WIP\]`}, ExcludedPaths: []string{"docs/**"}}}
+	log := logrus.WithField("test", "pool filters")
+	files := func() ([]string, error) {
+		return []string{"docs/README.md"}, nil
+	}
+	failing := func() ([]string, error) {
+		return nil, errors.New("rate limited")
+	}
+
+	filters := NewPoolFilters(cfg, s, "org", "repo", "master", log)
+	require.Len(t, filters, 1)
+	reason, err := filters.Exclusion("[WIP] add docs", failing)
+	require.NoError(t, err, "the changed files are not needed to exclude the title")
+	assert.Equal(t, ExcludedByTitle, reason)
+	reason, err = filters.Exclusion("add docs", files)
+	require.NoError(t, err)
+	assert.Equal(t, ExcludedByPaths, reason)
+	reason, err = filters.Exclusion("add docs", failing)
+	assert.Error(t, err)
+	assert.Empty(t, reason, "a PR whose changed files can't be fetched is not excluded")
+
+	filters = NewPoolFilters(cfg, s, "org", "repo", "release", log)
+	require.Len(t, filters, 2)
+	reason, err = filters.Exclusion("[WIP] add docs", files)
+	require.NoError(t, err)
+	assert.Empty(t, reason, "the release query excludes nothing")
+
+	s.Keeper.Queries[0].ExcludedTitles = []string{"[WIP"}
+	filters = NewPoolFilters(cfg, s, "org", "repo", "master", log)
+	reason, err = filters.Exclusion("[WIP add docs", files)
+	require.NoError(t, err)
+	assert.Empty(t, reason, "an invalid filter excludes nothing")
+}
@@ -0,0 +1,190 @@
+package keeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/keeper/history"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// PoolStatePath is the path of the keeper endpoint describing the state of the merge pools
+	PoolStatePath = "/pools"
+	// PoolStateVersion is the version of the schema of the pool state. Fields may be added to the
+	// schema without changing its version, but not renamed or removed.
+	PoolStateVersion = "v1"
+
+	// PoolPRSuccess is the state of the PRs whose tests passed
+	PoolPRSuccess = "success"
+	// PoolPRPending is the state of the PRs whose tests are running
+	PoolPRPending = "pending"
+	// PoolPRMissing is the state of the PRs whose tests are missing or failed
+	PoolPRMissing = "missing"
+)
+
+// PoolState is the state of the merge pools of keeper, in a schema which does not depend on its
+// internal types, so that teams can build dashboards of their merge queues on top of it
+type PoolState struct {
+	Version string `json:"version"`
+	// Pools are sorted by org, repo and branch
+	Pools []PoolStatus `json:"pools"`
+}
+
+// PoolStatus is the state of the merge pool of a branch
+type PoolStatus struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	// PRs are the PRs of the pool, sorted by number
+	PRs []PoolPR `json:"prs"`
+	// Action is the last action keeper took on the pool, such as MERGE or TRIGGER_BATCH
+	Action string `json:"action"`
+	// Targets are the numbers of the PRs of the last action
+	Targets []int `json:"targets,omitempty"`
+	// Blockers are the issues blocking the merges into the branch
+	Blockers []PoolBlocker `json:"blockers,omitempty"`
+	// Error is the error of the last action, if it failed
+	Error string `json:"error,omitempty"`
+	// History are the recorded actions on the pool, the most recent first
+	History []PoolRecord `json:"history,omitempty"`
+}
+
+// PoolPR is a PR of a merge pool
+type PoolPR struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	SHA    string `json:"sha"`
+	URL    string `json:"url,omitempty"`
+	// State is either success, pending or missing
+	State string `json:"state"`
+	// Batch is true if the PR is part of the pending batch
+	Batch bool `json:"batch,omitempty"`
+	// MissingContexts are the contexts of the required presubmits which are missing or failed
+	MissingContexts []string `json:"missingContexts,omitempty"`
+}
+
+// PoolBlocker is an issue blocking the merges into a branch
+type PoolBlocker struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"url,omitempty"`
+}
+
+// PoolRecord is an action keeper took on a pool
+type PoolRecord struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	BaseSHA string    `json:"baseSHA,omitempty"`
+	Targets []int     `json:"targets,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// NewPoolState returns the state of the pools and of their history
+func NewPoolState(pools []Pool, records map[string][]*history.Record) PoolState {
+	answer := PoolState{Version: PoolStateVersion, Pools: []PoolStatus{}}
+	for _, pool := range pools {
+		answer.Pools = append(answer.Pools, newPoolStatus(pool, records[poolKey(pool.Org, pool.Repo, pool.Branch)]))
+	}
+	sort.Slice(answer.Pools, func(i, j int) bool {
+		return poolKey(answer.Pools[i].Org, answer.Pools[i].Repo, answer.Pools[i].Branch) < poolKey(answer.Pools[j].Org, answer.Pools[j].Repo, answer.Pools[j].Branch)
+	})
+	return answer
+}
+
+func newPoolStatus(pool Pool, records []*history.Record) PoolStatus {
+	status := PoolStatus{
+		Org:    pool.Org,
+		Repo:   pool.Repo,
+		Branch: pool.Branch,
+		PRs:    []PoolPR{},
+		Action: string(pool.Action),
+		Error:  pool.Error,
+	}
+	batch := map[int]bool{}
+	for _, pr := range pool.BatchPending {
+		batch[int(pr.Number)] = true
+	}
+	add := func(prs []PullRequest, state string) {
+		for _, pr := range prs {
+			status.PRs = append(status.PRs, PoolPR{
+				Number:          int(pr.Number),
+				Title:           string(pr.Title),
+				Author:          string(pr.Author.Login),
+				SHA:             string(pr.HeadRefOID),
+				URL:             poolPRURL(pr),
+				State:           state,
+				Batch:           batch[int(pr.Number)],
+				MissingContexts: pool.MissingContexts[int(pr.Number)],
+			})
+		}
+	}
+	add(pool.SuccessPRs, PoolPRSuccess)
+	add(pool.PendingPRs, PoolPRPending)
+	add(pool.MissingPRs, PoolPRMissing)
+	sort.Slice(status.PRs, func(i, j int) bool { return status.PRs[i].Number < status.PRs[j].Number })
+	for _, pr := range pool.Target {
+		status.Targets = append(status.Targets, int(pr.Number))
+	}
+	for _, b := range pool.Blockers {
+		status.Blockers = append(status.Blockers, PoolBlocker{Number: b.Number, Title: b.Title, URL: b.URL})
+	}
+	for _, r := range records {
+		record := PoolRecord{Time: r.Time, Action: r.Action, BaseSHA: r.BaseSHA, Error: r.Err}
+		for _, target := range r.Target {
+			record.Targets = append(record.Targets, target.Number)
+		}
+		status.History = append(status.History, record)
+	}
+	return status
+}
+
+func poolPRURL(pr PullRequest) string {
+	if pr.Repository.URL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/pull/%d", strings.TrimSuffix(string(pr.Repository.URL), "/"), pr.Number)
+}
+
+// PoolStateHandler serves the PoolState of a keeper controller as JSON. The pools can be filtered with
+// the org, repo and branch query parameters, e.g. ?repo=org/repo
+type PoolStateHandler struct {
+	controller Controller
+	logger     *logrus.Entry
+}
+
+// NewPoolStateHandler creates a PoolStateHandler serving the state of the pools of the controller
+func NewPoolStateHandler(c Controller) *PoolStateHandler {
+	return &PoolStateHandler{
+		controller: c,
+		logger:     logrus.WithField("controller", "pool-state"),
+	}
+}
+
+func (h *PoolStateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	org, repo, branch := params.Get("org"), params.Get("repo"), params.Get("branch")
+	if org == "" && strings.Contains(repo, "/") {
+		org, repo = scm.Split(repo)
+	}
+	var pools []Pool
+	for _, pool := range h.controller.GetPools() {
+		if (org == "" || strings.EqualFold(pool.Org, org)) && (repo == "" || strings.EqualFold(pool.Repo, repo)) && (branch == "" || pool.Branch == branch) {
+			pools = append(pools, pool)
+		}
+	}
+	var records map[string][]*history.Record
+	if hist := h.controller.GetHistory(); hist != nil {
+		records = hist.AllRecords()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(NewPoolState(pools, records)); err != nil {
+		h.logger.WithError(err).Error("Writing JSON response.")
+	}
+}
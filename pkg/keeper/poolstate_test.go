@@ -0,0 +1,82 @@
+package keeper
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/keeper/blockers"
+	"github.com/jenkins-x/lighthouse/pkg/keeper/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePoolController struct {
+	fakeSyncController
+	pools   []Pool
+	history *history.History
+}
+
+func (f *fakePoolController) GetPools() []Pool {
+	return f.pools
+}
+
+func (f *fakePoolController) GetHistory() *history.History {
+	return f.history
+}
+
+func TestPoolStateHandler(t *testing.T) {
+	hist, err := history.New(10, "")
+	require.NoError(t, err)
+	hist.Record(poolKey("org", "repo", "master"), string(Trigger), "base1", "", []v1alpha1.Pull{{Number: 2}})
+	hist.Record(poolKey("org", "repo", "master"), string(Merge), "base2", "", []v1alpha1.Pull{{Number: 1}})
+	pools := []Pool{
+		{
+			Org:        "org",
+			Repo:       "repo",
+			Branch:     "master",
+			SuccessPRs: []PullRequest{stuckTestPR(3, "ccc")},
+			PendingPRs: []PullRequest{stuckTestPR(2, "bbb")},
+			MissingPRs: []PullRequest{stuckTestPR(1, "aaa")},
+			Action:     Trigger,
+			Target:     []PullRequest{stuckTestPR(1, "aaa")},
+			Blockers:   []blockers.Blocker{{Number: 10, Title: "Release freeze", URL: "https://github.com/org/repo/issues/10"}},
+
+			BatchPending:    []PullRequest{stuckTestPR(2, "bbb")},
+			MissingContexts: map[int][]string{1: {"ci/e2e", "ci/unit"}},
+		},
+		{Org: "another", Repo: "repo", Branch: "master", Action: Wait},
+	}
+	handler := NewPoolStateHandler(&fakePoolController{pools: pools, history: hist})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", PoolStatePath, nil))
+	state := PoolState{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, PoolStateVersion, state.Version)
+	require.Len(t, state.Pools, 2)
+	assert.Equal(t, "another", state.Pools[0].Org, "the pools are sorted")
+	assert.Empty(t, state.Pools[0].PRs)
+
+	pool := state.Pools[1]
+	assert.Equal(t, string(Trigger), pool.Action)
+	assert.Equal(t, []int{1}, pool.Targets)
+	assert.Equal(t, []PoolBlocker{{Number: 10, Title: "Release freeze", URL: "https://github.com/org/repo/issues/10"}}, pool.Blockers)
+	assert.Equal(t, []PoolPR{
+		{Number: 1, Title: "A change", Author: "author", SHA: "aaa", URL: "https://github.com/org/repo/pull/1", State: PoolPRMissing, MissingContexts: []string{"ci/e2e", "ci/unit"}},
+		{Number: 2, Title: "A change", Author: "author", SHA: "bbb", URL: "https://github.com/org/repo/pull/2", State: PoolPRPending, Batch: true},
+		{Number: 3, Title: "A change", Author: "author", SHA: "ccc", URL: "https://github.com/org/repo/pull/3", State: PoolPRSuccess},
+	}, pool.PRs)
+	require.Len(t, pool.History, 2)
+	assert.Equal(t, string(Merge), pool.History[0].Action, "the most recent action comes first")
+	assert.Equal(t, []int{1}, pool.History[0].Targets)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", PoolStatePath+"?repo=org/repo&branch=master", nil))
+	state = PoolState{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	require.Len(t, state.Pools, 1)
+	assert.Equal(t, "org", state.Pools[0].Org)
+}
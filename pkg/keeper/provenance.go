@@ -0,0 +1,49 @@
+package keeper
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/provenance"
+)
+
+// provenanceTriggeredBy identifies keeper as the trigger of the jobs it launches and the PRs it merges
+const provenanceTriggeredBy = "keeper"
+
+// provenanceTrigger returns the provenance trigger of the current keeper action
+func (c *DefaultController) provenanceTrigger() provenance.Trigger {
+	return provenance.Trigger{
+		TriggeredBy:   provenanceTriggeredBy,
+		ConfigVersion: currentSettings(c.settings).Version,
+	}
+}
+
+// provenanceLauncher returns the launcher annotating the jobs it launches with their provenance
+func (c *DefaultController) provenanceLauncher() launcher {
+	recorder := c.provenance.Recorder()
+	if recorder == nil {
+		return c.launcherClient
+	}
+	return provenance.NewLauncher(c.launcherClient, recorder, c.provenanceTrigger())
+}
+
+// recordMergeProvenance signs and stores the provenance of a PR merged from the subpool
+// when provenance is enabled
+func (c *DefaultController) recordMergeProvenance(sp subpool, pr PullRequest) {
+	recorder := c.provenance.Recorder()
+	if recorder == nil {
+		return
+	}
+	refs := &v1alpha1.Refs{
+		Org:     sp.org,
+		Repo:    sp.repo,
+		BaseRef: sp.branch,
+		BaseSHA: sp.sha,
+		Pulls:   prMeta(pr),
+	}
+	statement := recorder.Statement(provenance.ActionMerge, c.provenanceTrigger(), refs)
+	name := fmt.Sprintf("%s-%s-%d-%s.%s", sp.org, sp.repo, pr.Number, pr.HeadRefOID, provenance.ActionMerge)
+	if _, err := recorder.Record(name, statement); err != nil {
+		sp.log.WithFields(pr.logFields()).WithError(err).Error("Error recording the provenance of the merged PR.")
+	}
+}
@@ -0,0 +1,118 @@
+package keeper
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+)
+
+const (
+	reviewDecisionChangesRequested = "CHANGES_REQUESTED"
+	reviewDecisionReviewRequired   = "REVIEW_REQUIRED"
+)
+
+// ReviewChecker keeps PRs out of the keeper pool until the review requirements reported by
+// the git provider are satisfied, rather than relying only on the labels of the keeper
+// queries, so that merges are not rejected by the provider.
+//
+// On providers supporting GraphQL the review decision of the PR returned by the keeper search
+// query is used, which accounts for the required number of approving reviews, the code owner
+// reviews and the changes requested by the reviewers. Otherwise the latest review of each reviewer is used: PRs are kept out
+// while a reviewer requests changes. PRs can also be required to have a minimum number of
+// approving reviews on all providers.
+type ReviewChecker struct {
+	minApprovals int
+}
+
+// reviewClient is the subset of the SCM client needed to check the reviews of PRs
+type reviewClient interface {
+	SupportsGraphQL() bool
+	ListReviews(org, repo string, number int) ([]*scm.Review, error)
+}
+
+// NewReviewChecker creates a ReviewChecker. It returns nil if reviews are not checked and no
+// minimum number of approvals is specified, which disables the check.
+func NewReviewChecker(enabled bool, minApprovals int) *ReviewChecker {
+	if !enabled && minApprovals <= 0 {
+		return nil
+	}
+	return &ReviewChecker{minApprovals: minApprovals}
+}
+
+// Check returns a description of the missing reviews of the PR, which is empty if its review
+// requirements are satisfied. The review decision is the one returned by the keeper search
+// query on providers supporting GraphQL. An error is returned if the reviews cannot be listed.
+func (r *ReviewChecker) Check(spc reviewClient, pr *PullRequest) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	if spc.SupportsGraphQL() {
+		switch pr.ReviewDecision {
+		case reviewDecisionChangesRequested:
+			return "changes are requested", nil
+		case reviewDecisionReviewRequired:
+			return "the required reviews are missing", nil
+		}
+		if r.minApprovals <= 0 {
+			return "", nil
+		}
+	}
+
+	org := string(pr.Repository.Owner.Login)
+	repo := string(pr.Repository.Name)
+	number := int(pr.Number)
+	reviews, err := spc.ListReviews(org, repo, number)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list the reviews of %s/%s#%d", org, repo, number)
+	}
+	approvals := 0
+	for reviewer, state := range latestReviewStates(reviews) {
+		switch state {
+		case scm.ReviewStateChangesRequested:
+			return fmt.Sprintf("%s requested changes", reviewer), nil
+		case scm.ReviewStateApproved:
+			approvals++
+		}
+	}
+	if approvals < r.minApprovals {
+		return fmt.Sprintf("%d approving reviews of the %d required", approvals, r.minApprovals), nil
+	}
+	return "", nil
+}
+
+// latestReviewStates returns the state of the latest review of each reviewer which approved,
+// requested changes or had their review dismissed
+func latestReviewStates(reviews []*scm.Review) map[string]string {
+	states := map[string]string{}
+	for _, review := range reviews {
+		if review == nil {
+			continue
+		}
+		switch review.State {
+		case scm.ReviewStateApproved, scm.ReviewStateChangesRequested, scm.ReviewStateDismissed:
+			states[review.Author.Login] = review.State
+		}
+	}
+	return states
+}
+
+// filterUnreviewedPRs removes the PRs whose review requirements are not satisfied from the subpool
+func (c *DefaultController) filterUnreviewedPRs(sp *subpool) {
+	if c.reviewChecker == nil {
+		return
+	}
+	var toKeep []PullRequest
+	for _, pr := range sp.prs {
+		p := pr
+		missing, err := c.reviewChecker.Check(c.spc, &p)
+		if err != nil {
+			sp.log.WithFields(p.logFields()).WithError(err).Warn("failed to check the review requirements of the PR, keeping it")
+		} else if missing != "" {
+			sp.log.WithFields(p.logFields()).WithField("reviews", missing).Debug("filtering out PR as its review requirements are not satisfied")
+			continue
+		}
+		toKeep = append(toKeep, pr)
+	}
+	sp.prs = toKeep
+}
@@ -0,0 +1,104 @@
+package keeper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReviewClient struct {
+	graphQL bool
+	reviews []*scm.Review
+	err     error
+}
+
+func (f *fakeReviewClient) SupportsGraphQL() bool {
+	return f.graphQL
+}
+
+func (f *fakeReviewClient) ListReviews(org, repo string, number int) ([]*scm.Review, error) {
+	return f.reviews, f.err
+}
+
+func review(login, state string) *scm.Review {
+	return &scm.Review{Author: scm.User{Login: login}, State: state}
+}
+
+// check returns the missing reviews of the PR, or the error of the check
+func check(r *ReviewChecker, client reviewClient, pr *PullRequest) string {
+	missing, err := r.Check(client, pr)
+	if err != nil {
+		return err.Error()
+	}
+	return missing
+}
+
+func TestReviewChecker(t *testing.T) {
+	assert.Nil(t, NewReviewChecker(false, 0))
+	var disabled *ReviewChecker
+	assert.Equal(t, "", check(disabled, &fakeReviewClient{}, &PullRequest{}))
+
+	pr := &PullRequest{Number: 5, ReviewDecision: "APPROVED"}
+	pr.Repository.Name = "repo"
+	pr.Repository.Owner.Login = "org"
+
+	r := NewReviewChecker(true, 0)
+	require.NotNil(t, r)
+	client := &fakeReviewClient{graphQL: true, err: errors.New("no reviews are listed when the review decision is used")}
+	assert.Equal(t, "", check(r, client, pr))
+	pr.ReviewDecision = ""
+	assert.Equal(t, "", check(r, client, pr), "no review is required")
+	pr.ReviewDecision = reviewDecisionReviewRequired
+	assert.Equal(t, "the required reviews are missing", check(r, client, pr))
+	pr.ReviewDecision = reviewDecisionChangesRequested
+	assert.Equal(t, "changes are requested", check(r, client, pr))
+
+	client = &fakeReviewClient{reviews: []*scm.Review{
+		review("alice", scm.ReviewStateChangesRequested),
+		review("bob", scm.ReviewStateApproved),
+	}}
+	assert.Equal(t, "alice requested changes", check(r, client, pr))
+	client.reviews = append(client.reviews, review("alice", scm.ReviewStateCommented))
+	assert.Equal(t, "alice requested changes", check(r, client, pr), "comments do not resolve requested changes")
+	client.reviews = append(client.reviews, review("alice", scm.ReviewStateDismissed))
+	assert.Equal(t, "", check(r, client, pr))
+
+	r = NewReviewChecker(false, 2)
+	assert.Equal(t, "1 approving reviews of the 2 required", check(r, client, pr))
+	client.reviews = append(client.reviews, review("carol", scm.ReviewStateApproved))
+	assert.Equal(t, "", check(r, client, pr))
+	client.graphQL = true
+	pr.ReviewDecision = "APPROVED"
+	assert.Equal(t, "", check(r, client, pr))
+
+	client.err = errors.New("unavailable")
+	_, err := r.Check(client, pr)
+	assert.EqualError(t, err, "failed to list the reviews of org/repo#5: unavailable")
+}
+
+func TestFilterUnreviewedPRs(t *testing.T) {
+	spc := &fgc{}
+	c := &DefaultController{spc: spc, reviewChecker: NewReviewChecker(true, 0)}
+	sp := &subpool{
+		log: logrus.WithField("test", t.Name()),
+		prs: []PullRequest{
+			{Number: 1, ReviewDecision: "APPROVED"},
+			{Number: 2, ReviewDecision: reviewDecisionChangesRequested},
+			{Number: 3},
+		},
+	}
+	c.filterUnreviewedPRs(sp)
+	require.Len(t, sp.prs, 2)
+	assert.Equal(t, 1, int(sp.prs[0].Number))
+	assert.Equal(t, 3, int(sp.prs[1].Number))
+
+	// PRs whose reviews cannot be listed are kept
+	c.reviewChecker = NewReviewChecker(true, 1)
+	spc.reviewsErr = errors.New("unavailable")
+	c.filterUnreviewedPRs(sp)
+	assert.Len(t, sp.prs, 2)
+}
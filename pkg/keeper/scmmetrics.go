@@ -0,0 +1,220 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+)
+
+// queryOrgRegex matches the org of the first org: or repo: qualifier of a search query
+var queryOrgRegex = regexp.MustCompile(`\b(?:org|repo):"?([^\s/"]+)`)
+
+// instrumentedSCMClient counts the calls keeper makes to the git provider per org and call in the
+// lighthouse_keeper_scm_calls_total metric
+type instrumentedSCMClient struct {
+	scmProviderClient
+}
+
+// instrumentSCMClient wraps the client so that its calls are counted in the metrics
+func instrumentSCMClient(spc scmProviderClient) scmProviderClient {
+	if spc == nil {
+		return nil
+	}
+	return &instrumentedSCMClient{scmProviderClient: spc}
+}
+
+func countSCMCall(org, call string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	keeperMetrics.scmCalls.WithLabelValues(strings.ToLower(org), call, result).Inc()
+}
+
+// orgOfFullName returns the org of the org/repo full name
+func orgOfFullName(fullName string) string {
+	org, _ := scm.Split(fullName)
+	return org
+}
+
+func (c *instrumentedSCMClient) CreateGraphQLStatus(org, repo, ref string, s *scmprovider.Status) (*scm.Status, error) {
+	status, err := c.scmProviderClient.CreateGraphQLStatus(org, repo, ref, s)
+	countSCMCall(org, "CreateGraphQLStatus", err)
+	return status, err
+}
+
+func (c *instrumentedSCMClient) GetCombinedStatus(org, repo, ref string) (*scm.CombinedStatus, error) {
+	status, err := c.scmProviderClient.GetCombinedStatus(org, repo, ref)
+	countSCMCall(org, "GetCombinedStatus", err)
+	return status, err
+}
+
+func (c *instrumentedSCMClient) CreateStatus(org, repo, ref string, s *scm.StatusInput) (*scm.Status, error) {
+	status, err := c.scmProviderClient.CreateStatus(org, repo, ref, s)
+	countSCMCall(org, "CreateStatus", err)
+	return status, err
+}
+
+func (c *instrumentedSCMClient) GetPullRequest(org, repo string, number int) (*scm.PullRequest, error) {
+	pr, err := c.scmProviderClient.GetPullRequest(org, repo, number)
+	countSCMCall(org, "GetPullRequest", err)
+	return pr, err
+}
+
+func (c *instrumentedSCMClient) GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error) {
+	changes, err := c.scmProviderClient.GetPullRequestChanges(org, repo, number)
+	countSCMCall(org, "GetPullRequestChanges", err)
+	return changes, err
+}
+
+func (c *instrumentedSCMClient) GetRef(org, repo, ref string) (string, error) {
+	sha, err := c.scmProviderClient.GetRef(org, repo, ref)
+	countSCMCall(org, "GetRef", err)
+	return sha, err
+}
+
+func (c *instrumentedSCMClient) Merge(org, repo string, number int, details scmprovider.MergeDetails) error {
+	err := c.scmProviderClient.Merge(org, repo, number, details)
+	countSCMCall(org, "Merge", err)
+	return err
+}
+
+func (c *instrumentedSCMClient) Query(ctx context.Context, q interface{}, vars map[string]interface{}) error {
+	err := c.scmProviderClient.Query(ctx, q, vars)
+	var org string
+	if query, ok := vars["query"]; ok {
+		if m := queryOrgRegex.FindStringSubmatch(fmt.Sprint(query)); m != nil {
+			org = m[1]
+		}
+	}
+	countSCMCall(org, "Query", err)
+	return err
+}
+
+func (c *instrumentedSCMClient) GetRepositoryByFullName(fullName string) (*scm.Repository, error) {
+	repo, err := c.scmProviderClient.GetRepositoryByFullName(fullName)
+	countSCMCall(orgOfFullName(fullName), "GetRepositoryByFullName", err)
+	return repo, err
+}
+
+func (c *instrumentedSCMClient) ListPullRequests(fullName string, opts scmprovider.PullRequestListOptions) ([]*scm.PullRequest, error) {
+	prs, err := c.scmProviderClient.ListPullRequests(fullName, opts)
+	countSCMCall(orgOfFullName(fullName), "ListPullRequests", err)
+	return prs, err
+}
+
+func (c *instrumentedSCMClient) AddLabel(org, repo string, number int, label string, pr bool) error {
+	err := c.scmProviderClient.AddLabel(org, repo, number, label, pr)
+	countSCMCall(org, "AddLabel", err)
+	return err
+}
+
+func (c *instrumentedSCMClient) RemoveLabel(org, repo string, number int, label string, pr bool) error {
+	err := c.scmProviderClient.RemoveLabel(org, repo, number, label, pr)
+	countSCMCall(org, "RemoveLabel", err)
+	return err
+}
+
+func (c *instrumentedSCMClient) CreateComment(org, repo string, number int, pr bool, comment string) error {
+	err := c.scmProviderClient.CreateComment(org, repo, number, pr, comment)
+	countSCMCall(org, "CreateComment", err)
+	return err
+}
+
+func (c *instrumentedSCMClient) ListReviews(org, repo string, number int) ([]*scm.Review, error) {
+	reviews, err := c.scmProviderClient.ListReviews(org, repo, number)
+	countSCMCall(org, "ListReviews", err)
+	return reviews, err
+}
+
+func (c *instrumentedSCMClient) SearchAll(q scmprovider.SearchQuery) ([]*scm.SearchIssue, error) {
+	issues, err := c.scmProviderClient.SearchAll(q)
+	org := q.Org
+	if org == "" {
+		org = orgOfFullName(q.Repo)
+	}
+	countSCMCall(org, "SearchAll", err)
+	return issues, err
+}
+
+func (c *instrumentedSCMClient) GetRepoLabels(org, repo string) ([]*scm.Label, error) {
+	labels, err := c.scmProviderClient.GetRepoLabels(org, repo)
+	countSCMCall(org, "GetRepoLabels", err)
+	return labels, err
+}
+
+func (c *instrumentedSCMClient) CreateRepoLabel(org, repo string, label *scm.Label) error {
+	err := c.scmProviderClient.CreateRepoLabel(org, repo, label)
+	countSCMCall(org, "CreateRepoLabel", err)
+	return err
+}
+
+func (c *instrumentedSCMClient) UpdateRepoLabel(org, repo string, label *scm.Label) error {
+	err := c.scmProviderClient.UpdateRepoLabel(org, repo, label)
+	countSCMCall(org, "UpdateRepoLabel", err)
+	return err
+}
+
+// batchResultTracker counts the results of the batch jobs of the subpools in the
+// lighthouse_keeper_batch_results_total metric, once per job
+type batchResultTracker struct {
+	lock sync.Mutex
+	// completed are the names of the completed batch jobs of each subpool as of its last sync, the
+	// subpools synced for the first time since keeper started have none so that their past batches
+	// are not counted again
+	completed map[string]map[string]bool
+}
+
+func newBatchResultTracker() *batchResultTracker {
+	return &batchResultTracker{completed: map[string]map[string]bool{}}
+}
+
+// observe counts the batch jobs of the subpool which completed since its last sync
+func (t *batchResultTracker) observe(sp *subpool) {
+	if t == nil {
+		return
+	}
+	key := poolKey(sp.org, sp.repo, sp.branch)
+	completed := map[string]bool{}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	previous, synced := t.completed[key]
+	for i := range sp.pjs {
+		pj := &sp.pjs[i]
+		if pj.Spec.Type != config.BatchJob {
+			continue
+		}
+		var result string
+		switch toSimpleState(pj.Status.State) {
+		case successState:
+			result = "success"
+		case failureState:
+			result = "failure"
+		default:
+			continue
+		}
+		completed[pj.Name] = true
+		if synced && !previous[pj.Name] {
+			keeperMetrics.batchResults.WithLabelValues(sp.org, sp.repo, sp.branch, result).Inc()
+		}
+	}
+	t.completed[key] = completed
+}
+
+// recordConsideredPRs sets the number of PRs matching the queries of each org in the metrics
+func recordConsideredPRs(prs map[string]PullRequest) {
+	counts := map[string]int{}
+	for _, pr := range prs {
+		counts[strings.ToLower(string(pr.Repository.Owner.Login))]++
+	}
+	keeperMetrics.consideredPRs.Reset()
+	for org, count := range counts {
+		keeperMetrics.consideredPRs.WithLabelValues(org).Set(float64(count))
+	}
+}
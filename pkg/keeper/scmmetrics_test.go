@@ -0,0 +1,65 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInstrumentedSCMClient(t *testing.T) {
+	spc := instrumentSCMClient(&fgc{mergeErrs: map[int]error{2: errors.New("conflict")}})
+	merged := keeperMetrics.scmCalls.WithLabelValues("instrumented", "Merge", "success")
+	failed := keeperMetrics.scmCalls.WithLabelValues("instrumented", "Merge", "error")
+	queries := keeperMetrics.scmCalls.WithLabelValues("instrumented", "Query", "success")
+	mergedBefore, failedBefore, queriesBefore := testutil.ToFloat64(merged), testutil.ToFloat64(failed), testutil.ToFloat64(queries)
+
+	assert.NoError(t, spc.Merge("Instrumented", "repo", 1, scmprovider.MergeDetails{}))
+	assert.Error(t, spc.Merge("instrumented", "repo", 2, scmprovider.MergeDetails{}))
+	assert.NoError(t, spc.Query(context.Background(), &searchQuery{}, map[string]interface{}{"query": githubql.String(`is:pr state:open repo:"instrumented/repo" label:approved`)}))
+
+	assert.Equal(t, mergedBefore+1, testutil.ToFloat64(merged))
+	assert.Equal(t, failedBefore+1, testutil.ToFloat64(failed))
+	assert.Equal(t, queriesBefore+1, testutil.ToFloat64(queries), "the org of a query is the one of its first qualifier")
+}
+
+func TestBatchResultTracker(t *testing.T) {
+	batch := func(name string, state v1alpha1.PipelineState) v1alpha1.LighthouseJob {
+		return v1alpha1.LighthouseJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.LighthouseJobSpec{Type: config.BatchJob},
+			Status:     v1alpha1.LighthouseJobStatus{State: state},
+		}
+	}
+	successes := keeperMetrics.batchResults.WithLabelValues("org", "batches", "master", "success")
+	failures := keeperMetrics.batchResults.WithLabelValues("org", "batches", "master", "failure")
+	successesBefore, failuresBefore := testutil.ToFloat64(successes), testutil.ToFloat64(failures)
+
+	tracker := newBatchResultTracker()
+	sp := &subpool{org: "org", repo: "batches", branch: "master", pjs: []v1alpha1.LighthouseJob{
+		batch("old", v1alpha1.FailureState),
+		batch("running", v1alpha1.RunningState),
+	}}
+	tracker.observe(sp)
+	assert.Equal(t, failuresBefore, testutil.ToFloat64(failures), "the batches completed before the first sync are not counted")
+
+	sp.pjs = []v1alpha1.LighthouseJob{
+		batch("old", v1alpha1.FailureState),
+		batch("running", v1alpha1.SuccessState),
+		batch("new", v1alpha1.FailureState),
+	}
+	tracker.observe(sp)
+	tracker.observe(sp)
+	assert.Equal(t, successesBefore+1, testutil.ToFloat64(successes))
+	assert.Equal(t, failuresBefore+1, testutil.ToFloat64(failures), "each batch is counted once")
+
+	var disabled *batchResultTracker
+	disabled.observe(sp)
+}
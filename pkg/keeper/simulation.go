@@ -0,0 +1,337 @@
+package keeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/keeper/blockers"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+)
+
+// SimulationPath is the path of the keeper endpoint simulating the decisions of keeper for a
+// hypothetical pull request
+const SimulationPath = "/simulate"
+
+// SimulatedPullRequest describes a hypothetical pull request
+type SimulatedPullRequest struct {
+	Org       string   `json:"org"`
+	Repo      string   `json:"repo"`
+	Branch    string   `json:"branch"`
+	Title     string   `json:"title,omitempty"`
+	Labels    []string `json:"labels,omitempty"`
+	Milestone string   `json:"milestone,omitempty"`
+	// Contexts are the states of the status contexts of the head commit, e.g. success, pending, failure or error
+	Contexts map[string]string `json:"contexts,omitempty"`
+	// Mergeable is false if the pull request has merge conflicts, it defaults to true
+	Mergeable *bool `json:"mergeable,omitempty"`
+	// ReviewApproved is true if the reviews of the pull request are approved
+	ReviewApproved bool `json:"reviewApproved,omitempty"`
+	// ReviewDecision is the review decision of the pull request on providers supporting GraphQL, e.g.
+	// APPROVED, CHANGES_REQUESTED or REVIEW_REQUIRED. The Reviews are checked instead if it is not given.
+	ReviewDecision string `json:"reviewDecision,omitempty"`
+	// Reviews are the states of the latest review of each reviewer, e.g. APPROVED or CHANGES_REQUESTED
+	Reviews map[string]string `json:"reviews,omitempty"`
+	// Files are the files changed by the pull request, used by the pool filters and the context scopes
+	Files []string `json:"files,omitempty"`
+}
+
+// SimulatedQuery is the evaluation of a keeper query for the simulated pull request
+type SimulatedQuery struct {
+	Query   config.KeeperQuery `json:"query"`
+	Matches bool               `json:"matches"`
+	// Missing are the requirements of the query the pull request does not satisfy
+	Missing []string `json:"missing,omitempty"`
+
+	// diff weights the unmet requirements like the keeper status does
+	diff int
+}
+
+// Simulation is the decision trace of keeper for a simulated pull request
+type Simulation struct {
+	PullRequest SimulatedPullRequest `json:"pullRequest"`
+	// Queries are the evaluations of the keeper queries of the repository
+	Queries []SimulatedQuery `json:"queries,omitempty"`
+	// MatchedQuery is the index in Queries of the first query matching the pull request, if any
+	MatchedQuery *int `json:"matchedQuery,omitempty"`
+	// Mergeable is true if keeper would merge the pull request
+	Mergeable bool `json:"mergeable"`
+	// Missing is what the pull request needs to be merged by keeper
+	Missing []string `json:"missing,omitempty"`
+	// Trace are the steps of the decision of keeper
+	Trace []string `json:"trace"`
+	// Status and Description are the keeper status context keeper would set on the pull request
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+// Simulate returns the decision trace of keeper for the hypothetical pull request, so that maintainers
+// can find out what a pull request needs to be merged by keeper before opening it. The review
+// requirements are checked by the ReviewChecker of keeper, if any.
+func Simulate(cfg *config.Config, s *settings.Config, reviewChecker *ReviewChecker, spr SimulatedPullRequest) (*Simulation, error) {
+	if s == nil {
+		s = &settings.Config{}
+	}
+	answer := &Simulation{PullRequest: spr}
+	pr := spr.toPullRequest()
+	trace := func(format string, args ...interface{}) {
+		answer.Trace = append(answer.Trace, fmt.Sprintf(format, args...))
+	}
+
+	queryMap := cfg.Keeper.Queries.QueryMap()
+	queries := queryMap.ForRepo(spr.Org, spr.Repo)
+	if len(queries) == 0 {
+		trace("no keeper query includes the repository %s", scm.Join(spr.Org, spr.Repo))
+		answer.Missing = append(answer.Missing, "a keeper query including the repository")
+	}
+	closest := -1
+	for i, q := range queries {
+		sq := simulateQuery(q, pr, spr.ReviewApproved)
+		answer.Queries = append(answer.Queries, sq)
+		if sq.Matches {
+			if answer.MatchedQuery == nil {
+				index := i
+				answer.MatchedQuery = &index
+				trace("query %d matches: %s", i, q.Query())
+			}
+			continue
+		}
+		trace("query %d does not match: %s", i, strings.Join(sq.Missing, ", "))
+		if closest == -1 || sq.diff < answer.Queries[closest].diff {
+			closest = i
+		}
+	}
+	if answer.MatchedQuery == nil && closest != -1 {
+		answer.Missing = append(answer.Missing, answer.Queries[closest].Missing...)
+	}
+
+	log := logrus.WithField("controller", "simulation")
+	filters := NewPoolFilters(cfg, s, spr.Org, spr.Repo, spr.Branch, log)
+	exclusion, err := filters.Exclusion(spr.Title, spr.changedFiles(pr))
+	if err != nil {
+		trace("no files given, the excluded paths are not evaluated")
+	}
+	switch exclusion {
+	case ExcludedByTitle:
+		trace("the title is excluded from the merge pool")
+		answer.Missing = append(answer.Missing, "a title which is not excluded from the merge pool")
+	case ExcludedByPaths:
+		trace("all the changed files are excluded from the merge pool")
+		answer.Missing = append(answer.Missing, "changes to files which are not excluded from the merge pool")
+	}
+	if pr.Mergeable == githubql.MergeableStateConflicting {
+		trace("the pull request has merge conflicts")
+		answer.Missing = append(answer.Missing, "no merge conflicts")
+	}
+	missingReviews, err := reviewChecker.Check(&spr, pr)
+	if err != nil {
+		return answer, err
+	}
+	if missingReviews != "" {
+		trace("the review requirements are not satisfied: %s", missingReviews)
+		answer.Missing = append(answer.Missing, fmt.Sprintf("reviews satisfying the review requirements (%s)", missingReviews))
+	} else if reviewChecker != nil {
+		trace("the review requirements are satisfied")
+	}
+
+	var cc contextChecker
+	if spr.Branch != "" {
+		policy, err := cfg.GetKeeperContextPolicy(spr.Org, spr.Repo, spr.Branch)
+		if err != nil {
+			return answer, err
+		}
+		cc = scopedContextChecker(s.Keeper.ContextScopes, spr.Org, spr.Repo, policy, spr.changedFiles)
+		unsuccessful := unsuccessfulContexts(pr.Commits.Nodes[0].Commit.Status.Contexts, contextCheckerForPR(cc, pr), log)
+		if len(unsuccessful) == 0 {
+			trace("all the required contexts succeeded")
+		}
+		for _, ctx := range unsuccessful {
+			state := strings.ToLower(string(ctx.State))
+			if ctx.State == githubql.StatusStateExpected {
+				state = "missing"
+			}
+			trace("the required context %s is %s", ctx.Context, state)
+			answer.Missing = append(answer.Missing, fmt.Sprintf("context %s to succeed", ctx.Context))
+		}
+	} else {
+		trace("no base branch given, the contexts are not evaluated")
+		answer.Missing = append(answer.Missing, "a base branch")
+	}
+
+	answer.Mergeable = len(answer.Missing) == 0
+	pool := map[string]PullRequest{}
+	if answer.Mergeable {
+		trace("keeper would add the pull request to the merge pool and merge it")
+		pool[prKey(pr)] = *pr
+	}
+	if cc == nil {
+		cc = &config.KeeperContextPolicy{}
+	}
+	answer.Status, answer.Description = expectedStatus(queryMap, pr, pool, cc, blockers.Blockers{}, exclusion, "")
+	return answer, nil
+}
+
+// simulateQuery evaluates the keeper query for the simulated pull request like the keeper status
+// does, except for the status contexts which do not depend on the query
+func simulateQuery(q config.KeeperQuery, pr *PullRequest, reviewApproved bool) SimulatedQuery {
+	answer := SimulatedQuery{Query: q}
+	unmet := unmetRequirements(pr, &q, nil)
+	if pr.BaseRef.Name != "" && unmet.forbiddenBranch {
+		if len(q.IncludedBranches) > 0 {
+			answer.Missing = append(answer.Missing, fmt.Sprintf("one of the base branches %s", strings.Join(q.IncludedBranches, ", ")))
+		} else {
+			answer.Missing = append(answer.Missing, fmt.Sprintf("a base branch other than %s", strings.Join(q.ExcludedBranches, ", ")))
+		}
+		answer.diff += 1000
+	}
+	if unmet.milestone != "" {
+		answer.Missing = append(answer.Missing, fmt.Sprintf("the milestone %s", unmet.milestone))
+		answer.diff += 100
+	}
+	for _, l := range unmet.missingLabels {
+		answer.Missing = append(answer.Missing, fmt.Sprintf("the label %s", l))
+	}
+	for _, l := range unmet.presentLabels {
+		answer.Missing = append(answer.Missing, fmt.Sprintf("no label %s", l))
+	}
+	answer.diff += len(unmet.missingLabels) + len(unmet.presentLabels)
+	if q.ReviewApprovedRequired && !reviewApproved {
+		answer.Missing = append(answer.Missing, "approved reviews")
+		answer.diff++
+	}
+	answer.Matches = len(answer.Missing) == 0
+	return answer
+}
+
+// changedFiles returns the files changed by the simulated pull request, or an error if they are
+// not given so that the contexts of all the scopes are required
+func (spr *SimulatedPullRequest) changedFiles(*PullRequest) config.ChangedFilesProvider {
+	return func() ([]string, error) {
+		if spr.Files == nil {
+			return nil, errors.New("no files given")
+		}
+		return spr.Files, nil
+	}
+}
+
+// SupportsGraphQL returns true if the review decision of the simulated pull request is given, so that
+// the ReviewChecker uses it like on providers supporting GraphQL
+func (spr *SimulatedPullRequest) SupportsGraphQL() bool {
+	return spr.ReviewDecision != ""
+}
+
+// ListReviews returns the latest review of each reviewer of the simulated pull request
+func (spr *SimulatedPullRequest) ListReviews(string, string, int) ([]*scm.Review, error) {
+	var reviewers []string
+	for reviewer := range spr.Reviews {
+		reviewers = append(reviewers, reviewer)
+	}
+	sort.Strings(reviewers)
+	var reviews []*scm.Review
+	for _, reviewer := range reviewers {
+		reviews = append(reviews, &scm.Review{
+			State:  strings.ToUpper(spr.Reviews[reviewer]),
+			Author: scm.User{Login: reviewer},
+		})
+	}
+	return reviews, nil
+}
+
+// toPullRequest converts the simulated pull request to the pull requests returned by the keeper queries
+func (spr *SimulatedPullRequest) toPullRequest() *PullRequest {
+	pr := &PullRequest{
+		Mergeable:  githubql.MergeableStateMergeable,
+		HeadRefOID: "simulated",
+		Title:      githubql.String(spr.Title),
+		// the review decision is only used by the ReviewChecker
+		ReviewDecision: githubql.String(strings.ToUpper(spr.ReviewDecision)),
+	}
+	if spr.Mergeable != nil && !*spr.Mergeable {
+		pr.Mergeable = githubql.MergeableStateConflicting
+	}
+	pr.BaseRef.Name = githubql.String(spr.Branch)
+	pr.Repository.Name = githubql.String(spr.Repo)
+	pr.Repository.NameWithOwner = githubql.String(scm.Join(spr.Org, spr.Repo))
+	pr.Repository.Owner.Login = githubql.String(spr.Org)
+	if spr.Milestone != "" {
+		pr.Milestone = &struct{ Title githubql.String }{Title: githubql.String(spr.Milestone)}
+	}
+	for _, l := range spr.Labels {
+		pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(l)})
+	}
+	var names []string
+	for name := range spr.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	commit := Commit{OID: pr.HeadRefOID}
+	for _, name := range names {
+		commit.Status.Contexts = append(commit.Status.Contexts, Context{
+			Context: githubql.String(name),
+			State:   githubql.StatusState(strings.ToUpper(spr.Contexts[name])),
+		})
+	}
+	pr.Commits.Nodes = append(pr.Commits.Nodes, struct{ Commit Commit }{Commit: commit})
+	return pr
+}
+
+// SimulationHandler serves the decision trace of keeper for the hypothetical pull request
+// posted as JSON
+type SimulationHandler struct {
+	config        config.Getter
+	settings      settings.Getter
+	reviewChecker *ReviewChecker
+	logger        *logrus.Entry
+}
+
+// NewSimulationHandler creates a SimulationHandler using the latest configuration and the
+// ReviewChecker of keeper, if any
+func NewSimulationHandler(cfg config.Getter, settingsGetter settings.Getter, reviewChecker *ReviewChecker) *SimulationHandler {
+	return &SimulationHandler{
+		config:        cfg,
+		settings:      settingsGetter,
+		reviewChecker: reviewChecker,
+		logger:        logrus.WithField("controller", "simulation"),
+	}
+}
+
+func (h *SimulationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a JSON pull request, e.g. {\"org\": \"org\", \"repo\": \"repo\", \"branch\": \"master\", \"labels\": [\"approved\"]}", http.StatusMethodNotAllowed)
+		return
+	}
+	spr := SimulatedPullRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&spr); err != nil {
+		http.Error(w, fmt.Sprintf("invalid pull request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if spr.Org == "" && strings.Contains(spr.Repo, "/") {
+		spr.Org, spr.Repo = scm.Split(spr.Repo)
+	}
+	if spr.Org == "" || spr.Repo == "" {
+		http.Error(w, "the org and repo of the pull request are required", http.StatusBadRequest)
+		return
+	}
+	cfg := h.config()
+	if cfg == nil {
+		http.Error(w, "no configuration loaded", http.StatusServiceUnavailable)
+		return
+	}
+	answer, err := Simulate(cfg, currentSettings(h.settings), h.reviewChecker, spr)
+	if err != nil {
+		h.logger.WithError(err).Errorf("Error simulating a pull request of %s/%s:%s.", spr.Org, spr.Repo, spr.Branch)
+		http.Error(w, fmt.Sprintf("failed to simulate the pull request: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(answer); err != nil {
+		h.logger.WithError(err).Error("Writing JSON response.")
+	}
+}
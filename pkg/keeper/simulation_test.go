@@ -0,0 +1,168 @@
+package keeper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate(t *testing.T) {
+	cfg := effectiveTestConfig()
+	no := false
+
+	actual, err := Simulate(cfg, nil, nil, SimulatedPullRequest{
+		Org:      "org",
+		Repo:     "repo",
+		Branch:   "master",
+		Labels:   []string{"approved"},
+		Contexts: map[string]string{"ci/build": "success"},
+	})
+	require.NoError(t, err)
+	assert.True(t, actual.Mergeable)
+	assert.Empty(t, actual.Missing)
+	require.NotNil(t, actual.MatchedQuery)
+	assert.Equal(t, 0, *actual.MatchedQuery)
+	assert.Equal(t, scmprovider.StatusSuccess, actual.Status)
+
+	actual, err = Simulate(cfg, nil, nil, SimulatedPullRequest{
+		Org:       "org",
+		Repo:      "repo",
+		Branch:    "master",
+		Labels:    []string{"do-not-merge/hold"},
+		Contexts:  map[string]string{"lint": "failure"},
+		Mergeable: &no,
+	})
+	require.NoError(t, err)
+	assert.False(t, actual.Mergeable)
+	assert.Nil(t, actual.MatchedQuery)
+	assert.Equal(t, []string{"the label approved", "no label do-not-merge/hold", "no merge conflicts", "context lint to succeed", "context ci/build to succeed"}, actual.Missing)
+	assert.Contains(t, actual.Trace, "the required context ci/build is missing")
+	assert.Contains(t, actual.Trace, "the required context lint is failure")
+	assert.Equal(t, scmprovider.StatusPending, actual.Status)
+	assert.Contains(t, actual.Description, "Needs approved label")
+
+	actual, err = Simulate(cfg, nil, nil, SimulatedPullRequest{Org: "org", Repo: "repo", Branch: "release"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"the label approved", "context ci/build to succeed"}, actual.Missing, "the missing requirements are the ones of the closest query")
+
+	actual, err = Simulate(cfg, nil, nil, SimulatedPullRequest{Org: "org", Repo: "excluded", Branch: "master"})
+	require.NoError(t, err)
+	assert.False(t, actual.Mergeable)
+	assert.Contains(t, actual.Missing, "a keeper query including the repository")
+}
+
+func TestSimulateBranchesAndReviews(t *testing.T) {
+	cfg := effectiveTestConfig()
+	spr := SimulatedPullRequest{
+		Org:      "org",
+		Repo:     "repo",
+		Branch:   "gh-pages",
+		Labels:   []string{"approved", "lgtm"},
+		Contexts: map[string]string{"ci/build": "success"},
+	}
+
+	actual, err := Simulate(cfg, nil, nil, spr)
+	require.NoError(t, err)
+	require.Len(t, actual.Queries, 2)
+	assert.Equal(t, []string{"a base branch other than gh-pages"}, actual.Queries[0].Missing)
+	assert.Equal(t, []string{"one of the base branches release"}, actual.Queries[1].Missing)
+	assert.Contains(t, actual.Description, "Merging to branch gh-pages is forbidden")
+
+	spr.Branch = "master"
+	spr.Reviews = map[string]string{"alice": "approved", "bob": "changes_requested"}
+	actual, err = Simulate(cfg, nil, NewReviewChecker(true, 0), spr)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reviews satisfying the review requirements (bob requested changes)"}, actual.Missing)
+
+	delete(spr.Reviews, "bob")
+	actual, err = Simulate(cfg, nil, NewReviewChecker(true, 2), spr)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reviews satisfying the review requirements (1 approving reviews of the 2 required)"}, actual.Missing)
+
+	spr.ReviewDecision = "review_required"
+	actual, err = Simulate(cfg, nil, NewReviewChecker(true, 0), spr)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reviews satisfying the review requirements (the required reviews are missing)"}, actual.Missing)
+
+	spr.ReviewDecision = "approved"
+	actual, err = Simulate(cfg, nil, NewReviewChecker(true, 0), spr)
+	require.NoError(t, err)
+	assert.True(t, actual.Mergeable)
+}
+
+func TestSimulateFiltersAndScopes(t *testing.T) {
+	cfg := effectiveTestConfig()
+	cfg.Keeper.ContextOptions.RequiredContexts = []string{"ci/build", "service-b/unit"}
+	s := &settings.Config{}
+	s.Keeper.Queries = []settings.KeeperQuery{{ExcludedTitles: []string{`^\[WIP\]`}, ExcludedPaths: []string{"docs/**"}}}
+	s.Keeper.ContextScopes = settings.ContextScopes{"org/repo": {{Name: "service-b", Paths: []string{"services/b/**"}}}}
+	spr := SimulatedPullRequest{
+		Org:      "org",
+		Repo:     "repo",
+		Branch:   "master",
+		Title:    "[WIP] change service A",
+		Labels:   []string{"approved"},
+		Contexts: map[string]string{"ci/build": "success", "service-b/unit": "failure"},
+		Files:    []string{"services/a/main.go"},
+	}
+
+	actual, err := Simulate(cfg, s, nil, spr)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a title which is not excluded from the merge pool"}, actual.Missing)
+
+	spr.Title = "change service A"
+	actual, err = Simulate(cfg, s, nil, spr)
+	require.NoError(t, err)
+	assert.True(t, actual.Mergeable, "the failing context of service B is out of the scope of the PR")
+
+	spr.Files = nil
+	actual, err = Simulate(cfg, s, nil, spr)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"context service-b/unit to succeed"}, actual.Missing, "all the scopes are required without the changed files")
+
+	spr.Files = []string{"docs/README.md"}
+	actual, err = Simulate(cfg, s, nil, spr)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"changes to files which are not excluded from the merge pool"}, actual.Missing)
+	assert.Equal(t, "Not mergeable. The changed files are excluded from the merge pool.", actual.Description)
+
+	spr.Branch = "release"
+	actual, err = Simulate(cfg, s, nil, spr)
+	require.NoError(t, err)
+	assert.NotContains(t, actual.Missing, "changes to files which are not excluded from the merge pool", "the release query does not exclude any path")
+}
+
+func TestSimulationHandler(t *testing.T) {
+	cfg := effectiveTestConfig()
+	handler := NewSimulationHandler(func() *config.Config {
+		return cfg
+	}, nil, nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, SimulationPath, strings.NewReader(`{"repo": "org/repo", "branch": "master", "labels": ["approved"], "contexts": {"ci/build": "success"}}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	actual := &Simulation{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), actual))
+	assert.Equal(t, "org", actual.PullRequest.Org)
+	assert.True(t, actual.Mergeable)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, SimulationPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, SimulationPath, strings.NewReader(`{"branch": "master"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, SimulationPath, strings.NewReader(`{`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
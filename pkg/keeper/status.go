@@ -26,9 +26,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse/pkg/keeper/blockers"
+	"github.com/jenkins-x/lighthouse/pkg/messages"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/jenkins-x/lighthouse/pkg/watchdog"
 	"github.com/pkg/errors"
 	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
@@ -39,10 +43,22 @@ import (
 const (
 	statusContext string = "keeper"
 	statusInPool         = "In merge pool."
-	// statusNotInPool is a format string used when a PR is not in a keeper pool.
-	// The '%s' field is populated with the reason why the PR is not in a
+	// statusNotInPool is the template used when a PR is not in a keeper pool.
+	// The Reason field is populated with the reason why the PR is not in a
 	// keeper pool or the empty string if the reason is unknown. See requirementDiff.
-	statusNotInPool = "Not mergeable.%s"
+	statusNotInPool = "Not mergeable.{{ .Reason }}"
+
+	// The IDs of the status descriptions in the message catalog.
+	inPoolMessage          = "keeper.status.inPool"
+	notInPoolMessage       = "keeper.status.notInPool"
+	blockedMessage         = "keeper.status.blocked"
+	forbiddenBranchMessage = "keeper.status.forbiddenBranch"
+	milestoneMessage       = "keeper.status.milestone"
+	missingLabelsMessage   = "keeper.status.missingLabels"
+	presentLabelsMessage   = "keeper.status.presentLabels"
+	failedJobsMessage      = "keeper.status.failedJobs"
+	excludedTitleMessage   = "keeper.status.excludedTitle"
+	excludedPathsMessage   = "keeper.status.excludedPaths"
 
 	// StatusContextLabelEnvVar is the environment variable we look to for the overriding status context label.
 	StatusContextLabelEnvVar = "LIGHTHOUSE_KEEPER_STATUS_CONTEXT_LABEL"
@@ -80,6 +96,18 @@ type statusController struct {
 	// the minimum status update period.
 	lastSyncStart time.Time
 
+	// throttle limits the status updates made in each sync, if set
+	throttle *StatusThrottle
+	// deferred are the PRs whose status update was deferred to the next sync by the throttle
+	deferred map[string]PullRequest
+
+	changedFiles *changedFilesAgent
+
+	// settings are the lighthouse settings of the keeper queries, such as their pool filters
+	settings settings.Getter
+	// watchdog reports the status syncs which are stuck, if enabled
+	watchdog *watchdog.Watchdog
+
 	sync.Mutex
 	poolPRs map[string]PullRequest
 	blocks  blockers.Blockers
@@ -108,24 +136,54 @@ func (sc *statusController) shutdown() {
 // the KeeperQuery is unknown. This can happen if this function's logic
 // does not match GitHub's and does not indicate that the PR matches the query.
 func requirementDiff(pr *PullRequest, q *config.KeeperQuery, cc contextChecker) (string, int) {
-	const maxLabelChars = 50
 	var desc string
-	var diff int
-	// Drops labels if needed to fit the description text area, but keep at least 1.
-	truncate := func(labels []string) []string {
-		i := 1
-		chars := len(labels[0])
-		for ; i < len(labels); i++ {
-			if chars+len(labels[i]) > maxLabelChars {
-				break
-			}
-			chars += len(labels[i]) + 2 // ", "
-		}
-		return labels[:i]
+	unmet := unmetRequirements(pr, q, cc)
+
+	if unmet.forbiddenBranch {
+		desc = messages.Render(forbiddenBranchMessage, " Merging to branch {{ .Branch }} is forbidden.", map[string]interface{}{"Branch": pr.BaseRef.Name})
+	}
+	if desc == "" && unmet.milestone != "" {
+		desc = messages.Render(milestoneMessage, " Must be in milestone {{ .Milestone }}.", map[string]interface{}{"Milestone": unmet.milestone})
+	}
+	if desc == "" && len(unmet.missingLabels) > 0 {
+		trunced := truncate(unmet.missingLabels)
+		desc = messages.Render(missingLabelsMessage, " Needs {{ .Labels }} label{{ if gt .Count 1 }}s{{ end }}.", listData("Labels", trunced))
+	}
+	if desc == "" && len(unmet.presentLabels) > 0 {
+		trunced := truncate(unmet.presentLabels)
+		desc = messages.Render(presentLabelsMessage, " Should not have {{ .Labels }} label{{ if gt .Count 1 }}s{{ end }}.", listData("Labels", trunced))
+	}
+	// fixing label issues takes precedence over status contexts
+	if desc == "" && len(unmet.contexts) > 0 {
+		trunced := truncate(unmet.contexts)
+		desc = messages.Render(failedJobsMessage, "{{ if gt .Count 1 }} Jobs {{ .Jobs }} have{{ else }} Job {{ .Jobs }} has{{ end }} not succeeded.", listData("Jobs", trunced))
 	}
 
-	// Weight incorrect branches with very high diff so that we select the query
-	// for the correct branch.
+	// TODO(cjwagner): List reviews (states:[APPROVED], first: 1) as part of open
+	// PR query.
+
+	return desc, unmet.diff()
+}
+
+// queryRequirements are the requirements of a KeeperQuery a PR does not satisfy
+type queryRequirements struct {
+	// forbiddenBranch is true if the base branch of the PR is not included in the query
+	forbiddenBranch bool
+	// milestone is the milestone of the query if the PR is not in it
+	milestone string
+	// missingLabels are the sorted labels required by the query the PR does not have
+	missingLabels []string
+	// presentLabels are the sorted labels forbidden by the query the PR has
+	presentLabels []string
+	// contexts are the sorted required contexts which did not succeed
+	contexts []string
+}
+
+// unmetRequirements returns the requirements of the query the PR does not satisfy. The status
+// contexts are not evaluated if cc is nil.
+func unmetRequirements(pr *PullRequest, q *config.KeeperQuery, cc contextChecker) *queryRequirements {
+	answer := &queryRequirements{}
+
 	targetBranchBlacklisted := false
 	for _, excludedBranch := range q.ExcludedBranches {
 		if string(pr.BaseRef.Name) == excludedBranch {
@@ -141,24 +199,12 @@ func requirementDiff(pr *PullRequest, q *config.KeeperQuery, cc contextChecker)
 			break
 		}
 	}
-	if targetBranchBlacklisted || !targetBranchWhitelisted {
-		diff += 1000
-		if desc == "" {
-			desc = fmt.Sprintf(" Merging to branch %s is forbidden.", pr.BaseRef.Name)
-		}
-	}
+	answer.forbiddenBranch = targetBranchBlacklisted || !targetBranchWhitelisted
 
-	// Weight incorrect milestone with relatively high diff so that we select the
-	// query for the correct milestone (but choose favor query for correct branch).
 	if q.Milestone != "" && (pr.Milestone == nil || string(pr.Milestone.Title) != q.Milestone) {
-		diff += 100
-		if desc == "" {
-			desc = fmt.Sprintf(" Must be in milestone %s.", q.Milestone)
-		}
+		answer.milestone = q.Milestone
 	}
 
-	// Weight incorrect labels and statues with low (normal) diff values.
-	var missingLabels []string
 	for _, l1 := range q.Labels {
 		var found bool
 		for _, l2 := range pr.Labels.Nodes {
@@ -168,72 +214,70 @@ func requirementDiff(pr *PullRequest, q *config.KeeperQuery, cc contextChecker)
 			}
 		}
 		if !found {
-			missingLabels = append(missingLabels, l1)
-		}
-	}
-	diff += len(missingLabels)
-	if desc == "" && len(missingLabels) > 0 {
-		sort.Strings(missingLabels)
-		trunced := truncate(missingLabels)
-		if len(trunced) == 1 {
-			desc = fmt.Sprintf(" Needs %s label.", trunced[0])
-		} else {
-			desc = fmt.Sprintf(" Needs %s labels.", strings.Join(trunced, ", "))
+			answer.missingLabels = append(answer.missingLabels, l1)
 		}
 	}
+	sort.Strings(answer.missingLabels)
 
-	var presentLabels []string
 	for _, l1 := range q.MissingLabels {
 		for _, l2 := range pr.Labels.Nodes {
 			if string(l2.Name) == l1 {
-				presentLabels = append(presentLabels, l1)
+				answer.presentLabels = append(answer.presentLabels, l1)
 				break
 			}
 		}
 	}
-	diff += len(presentLabels)
-	if desc == "" && len(presentLabels) > 0 {
-		sort.Strings(presentLabels)
-		trunced := truncate(presentLabels)
-		if len(trunced) == 1 {
-			desc = fmt.Sprintf(" Should not have %s label.", trunced[0])
-		} else {
-			desc = fmt.Sprintf(" Should not have %s labels.", strings.Join(trunced, ", "))
+	sort.Strings(answer.presentLabels)
+
+	if cc != nil {
+		for _, commit := range pr.Commits.Nodes {
+			if commit.Commit.OID == pr.HeadRefOID {
+				for _, ctx := range unsuccessfulContexts(commit.Commit.Status.Contexts, contextCheckerForPR(cc, pr), logrus.New().WithFields(pr.logFields())) {
+					answer.contexts = append(answer.contexts, string(ctx.Context))
+				}
+			}
 		}
+		sort.Strings(answer.contexts)
 	}
+	return answer
+}
 
-	// fixing label issues takes precedence over status contexts
-	var contexts []string
-	for _, commit := range pr.Commits.Nodes {
-		if commit.Commit.OID == pr.HeadRefOID {
-			for _, ctx := range unsuccessfulContexts(commit.Commit.Status.Contexts, cc, logrus.New().WithFields(pr.logFields())) {
-				contexts = append(contexts, string(ctx.Context))
-			}
-		}
+// diff weights the unmet requirements. Incorrect branches have a very high diff so that the query
+// for the correct branch is selected, incorrect milestones a relatively high diff so that the query
+// for the correct milestone is selected (but the query for the correct branch is favored), and
+// incorrect labels and statuses have a low (normal) diff.
+func (r *queryRequirements) diff() int {
+	diff := len(r.missingLabels) + len(r.presentLabels) + len(r.contexts)
+	if r.forbiddenBranch {
+		diff += 1000
 	}
-	diff += len(contexts)
-	if desc == "" && len(contexts) > 0 {
-		sort.Strings(contexts)
-		trunced := truncate(contexts)
-		if len(trunced) == 1 {
-			desc = fmt.Sprintf(" Job %s has not succeeded.", trunced[0])
-		} else {
-			desc = fmt.Sprintf(" Jobs %s have not succeeded.", strings.Join(trunced, ", "))
-		}
+	if r.milestone != "" {
+		diff += 100
 	}
+	return diff
+}
 
-	// TODO(cjwagner): List reviews (states:[APPROVED], first: 1) as part of open
-	// PR query.
-
-	return desc, diff
+// truncate drops labels if needed to fit the description text area, but keeps at least 1.
+func truncate(labels []string) []string {
+	const maxLabelChars = 50
+	i := 1
+	chars := len(labels[0])
+	for ; i < len(labels); i++ {
+		if chars+len(labels[i]) > maxLabelChars {
+			break
+		}
+		chars += len(labels[i]) + 2 // ", "
+	}
+	return labels[:i]
 }
 
 // Returns expected status state and description.
 // If a PR is not mergeable, we have to select a KeeperQuery to compare it against
 // in order to generate a diff for the status description. We choose the query
 // for the repo that the PR is closest to meeting (as determined by the number
-// of unmet/violated requirements).
-func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]PullRequest, cc contextChecker, blocks blockers.Blockers, providerType string) (string, string) {
+// of unmet/violated requirements). PRs excluded from the pool by the pool filters
+// of the queries are described by the reason of their exclusion instead.
+func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]PullRequest, cc contextChecker, blocks blockers.Blockers, exclusion, providerType string) (string, string) {
 	if _, ok := pool[prKey(pr)]; !ok {
 		// if the branch is blocked forget checking for a diff
 		blockingIssues := blocks.GetApplicable(string(pr.Repository.Owner.Login), string(pr.Repository.Name), string(pr.BaseRef.Name))
@@ -242,11 +286,13 @@ func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]
 			numbers = append(numbers, strconv.Itoa(issue.Number))
 		}
 		if len(numbers) > 0 {
-			var s string
-			if len(numbers) > 1 {
-				s = "s"
-			}
-			return scmprovider.StatusError, fmt.Sprintf(statusNotInPool, fmt.Sprintf(" Merging is blocked by issue%s %s.", s, strings.Join(numbers, ", ")))
+			return scmprovider.StatusError, notInPool(messages.Render(blockedMessage, " Merging is blocked by issue{{ if gt .Count 1 }}s{{ end }} {{ .Issues }}.", listData("Issues", numbers)))
+		}
+		switch exclusion {
+		case ExcludedByTitle:
+			return scmprovider.StatusPending, notInPool(messages.Render(excludedTitleMessage, " The title is excluded from the merge pool.", nil))
+		case ExcludedByPaths:
+			return scmprovider.StatusPending, notInPool(messages.Render(excludedPathsMessage, " The changed files are excluded from the merge pool.", nil))
 		}
 		minDiffCount := -1
 		var minDiff string
@@ -262,9 +308,24 @@ func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]
 		if providerType == "gitlab" {
 			minDiff = ""
 		}
-		return scmprovider.StatusPending, fmt.Sprintf(statusNotInPool, minDiff)
+		return scmprovider.StatusPending, notInPool(minDiff)
 	}
-	return scmprovider.StatusSuccess, statusInPool
+	return scmprovider.StatusSuccess, inPool()
+}
+
+// inPool returns the status description of PRs in a keeper pool
+func inPool() string {
+	return messages.Render(inPoolMessage, statusInPool, nil)
+}
+
+// notInPool returns the status description of PRs which are not in a keeper pool for the given reason
+func notInPool(reason string) string {
+	return messages.Render(notInPoolMessage, statusNotInPool, map[string]interface{}{"Reason": reason})
+}
+
+// listData returns the template data of a status description listing the given items
+func listData(name string, items []string) map[string]interface{} {
+	return map[string]interface{}{name: strings.Join(items, ", "), "Count": len(items)}
 }
 
 // targetURL determines the URL used for more details in the status
@@ -293,7 +354,11 @@ func (sc *statusController) setStatuses(all []PullRequest, pool map[string]PullR
 	// queryMap caches which queries match a repo.
 	// Make a new one each sync loop as queries will change.
 	queryMap := sc.config().Keeper.Queries.QueryMap()
+	if sc.changedFiles != nil {
+		defer sc.changedFiles.prune()
+	}
 	processed := sets.NewString()
+	var updates []statusUpdate
 
 	process := func(pr *PullRequest) {
 		processed.Insert(prKey(pr))
@@ -312,37 +377,46 @@ func (sc *statusController) setStatuses(all []PullRequest, pool map[string]PullR
 			return
 		}
 
-		wantState, wantDesc := expectedStatus(queryMap, pr, pool, cr, blocks, sc.spc.ProviderType())
-		var actualState githubql.StatusState
-		var actualDesc string
-		for _, ctx := range contexts {
-			if string(ctx.Context) == GetStatusContextLabel() {
-				actualState = ctx.State
-				actualDesc = string(ctx.Description)
+		cc := scopedContextChecker(currentSettings(sc.settings).Keeper.ContextScopes, string(pr.Repository.Owner.Login), string(pr.Repository.Name), cr, sc.changedFiles.prChanges)
+		exclusion := ""
+		if _, ok := pool[prKey(pr)]; !ok {
+			filters := NewPoolFilters(sc.config(), currentSettings(sc.settings), string(pr.Repository.Owner.Login), string(pr.Repository.Name), string(pr.BaseRef.Name), log)
+			exclusion, err = filters.Exclusion(string(pr.Title), sc.changedFiles.prChanges(pr))
+			if err != nil {
+				log.WithError(err).Error("Getting changed files to describe the pool exclusion.")
 			}
 		}
-		if wantState != strings.ToLower(string(actualState)) || wantDesc != actualDesc {
-			reportURL := ""
-			// BitBucket Server requires a valid URL in all status reports
-			if sc.spc.ProviderType() == "stash" {
-				reportURL = "https://github.com/jenkins-x/lighthouse"
+		wantState, wantDesc := expectedStatus(queryMap, pr, pool, cc, blocks, exclusion, sc.spc.ProviderType())
+		reportURL := ""
+		// BitBucket Server requires a valid URL in all status reports
+		if sc.spc.ProviderType() == "stash" {
+			reportURL = "https://github.com/jenkins-x/lighthouse"
+		}
+		_, inPool := pool[prKey(pr)]
+		wanted := append([]*scmprovider.Status{{
+			Context:     GetStatusContextLabel(),
+			State:       wantState,
+			Description: wantDesc,
+		}}, scopeStatuses(cc, pr, contexts, log)...)
+		for _, want := range wanted {
+			var actualState githubql.StatusState
+			var actualDesc string
+			for _, ctx := range contexts {
+				if string(ctx.Context) == want.Context {
+					actualState = ctx.State
+					actualDesc = string(ctx.Description)
+				}
 			}
-			if _, err := sc.spc.CreateGraphQLStatus(
-				string(pr.Repository.Owner.Login),
-				string(pr.Repository.Name),
-				string(pr.HeadRefOID),
-				&scmprovider.Status{
-					Context:     GetStatusContextLabel(),
-					State:       wantState,
-					Description: wantDesc,
-					TargetURL:   reportURL,
-				}); err != nil {
-				log.WithError(err).Errorf(
-					"Failed to set status context from %q to %q.",
-					strings.ToLower(string(actualState)),
-					wantState,
-				)
+			if want.State == strings.ToLower(string(actualState)) && want.Description == actualDesc {
+				continue
 			}
+			want.TargetURL = reportURL
+			updates = append(updates, statusUpdate{
+				pr:          *pr,
+				inPool:      inPool,
+				status:      want,
+				actualState: strings.ToLower(string(actualState)),
+			})
 		}
 	}
 
@@ -366,6 +440,68 @@ func (sc *statusController) setStatuses(all []PullRequest, pool map[string]PullR
 			process(&p)
 		}
 	}
+	// The search only returns the PRs updated since the previous sync so the PRs
+	// whose update was deferred by the throttle are processed again explicitly.
+	// They are re-fetched first as they may have been closed or pushed to since.
+	var unrefreshed []PullRequest
+	for key, deferredPR := range sc.deferred {
+		if processed.Has(key) {
+			continue
+		}
+		p, err := sc.refreshDeferred(deferredPR)
+		if err != nil {
+			sc.logger.WithFields(deferredPR.logFields()).WithError(err).Warn("Failed to re-fetch the PR whose keeper status update was deferred, retrying in the next sync.")
+			unrefreshed = append(unrefreshed, deferredPR)
+			continue
+		}
+		if p != nil {
+			process(p)
+		}
+	}
+
+	now, deferred := sc.throttle.plan(updates)
+	sc.deferred = map[string]PullRequest{}
+	for _, u := range deferred {
+		sc.deferred[prKey(&u.pr)] = u.pr
+	}
+	for i := range unrefreshed {
+		sc.deferred[prKey(&unrefreshed[i])] = unrefreshed[i]
+	}
+	if len(deferred) > 0 {
+		sc.logger.WithField("deferred", len(deferred)).Info("Deferring keeper status updates to the next sync to limit the updates per repository.")
+	}
+	for i, u := range now {
+		if i > 0 {
+			sc.throttle.wait()
+		}
+		if _, err := sc.spc.CreateGraphQLStatus(
+			string(u.pr.Repository.Owner.Login),
+			string(u.pr.Repository.Name),
+			string(u.pr.HeadRefOID),
+			u.status); err != nil {
+			sc.logger.WithFields(u.pr.logFields()).WithError(err).Errorf(
+				"Failed to set status context from %q to %q.",
+				u.actualState,
+				u.status.State,
+			)
+		}
+	}
+}
+
+// refreshDeferred re-fetches a PR whose status update was deferred by the throttle so that its status is
+// computed from its current head. It returns nil if the PR has been closed or merged since.
+func (sc *statusController) refreshDeferred(pr PullRequest) (*PullRequest, error) {
+	scmPR, err := sc.spc.GetPullRequest(string(pr.Repository.Owner.Login), string(pr.Repository.Name), int(pr.Number))
+	if err != nil {
+		return nil, err
+	}
+	if scmPR.Closed || scmPR.Merged {
+		return nil, nil
+	}
+	refreshed := scmPRToGraphQLPR(scmPR, &scm.Repository{})
+	refreshed.Repository = pr.Repository
+	refreshed.Milestone = pr.Milestone
+	return refreshed, nil
 }
 
 func (sc *statusController) load() {
@@ -421,6 +557,7 @@ func (sc *statusController) waitSync() {
 }
 
 func (sc *statusController) sync(pool map[string]PullRequest, blocks blockers.Blockers) {
+	defer sc.watchdog.Track()()
 	sc.lastSyncStart = time.Now()
 	defer func() {
 		duration := time.Since(sc.lastSyncStart)
@@ -17,7 +17,6 @@ limitations under the License.
 package keeper
 
 import (
-	"fmt"
 	"strings"
 	"testing"
 
@@ -54,7 +53,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool: true,
 
 			state: scmprovider.StatusSuccess,
-			desc:  statusInPool,
+			desc:  "In merge pool.",
 		},
 		{
 			name:      "check truncation of label list",
@@ -62,7 +61,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Needs need-1, need-2 labels."),
+			desc:  "Not mergeable. Needs need-1, need-2 labels.",
 		},
 		{
 			name:      "check truncation of label list is not excessive",
@@ -71,7 +70,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Needs need-a-very-super-duper-extra-not-short-at-all-label-name label."),
+			desc:  "Not mergeable. Needs need-a-very-super-duper-extra-not-short-at-all-label-name label.",
 		},
 		{
 			name:      "has forbidden labels",
@@ -80,7 +79,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Should not have forbidden-1, forbidden-2 labels."),
+			desc:  "Not mergeable. Should not have forbidden-1, forbidden-2 labels.",
 		},
 		{
 			name:      "has one forbidden label",
@@ -89,7 +88,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Should not have forbidden-1 label."),
+			desc:  "Not mergeable. Should not have forbidden-1 label.",
 		},
 		{
 			name:      "only mention one requirement class",
@@ -98,7 +97,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Needs need-1 label."),
+			desc:  "Not mergeable. Needs need-1 label.",
 		},
 		{
 			name:            "against excluded branch",
@@ -109,7 +108,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:          false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Merging to branch bad is forbidden."),
+			desc:  "Not mergeable. Merging to branch bad is forbidden.",
 		},
 		{
 			name:            "not against included branch",
@@ -120,7 +119,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:          false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Merging to branch bad is forbidden."),
+			desc:  "Not mergeable. Merging to branch bad is forbidden.",
 		},
 		{
 			name:            "choose query for correct branch",
@@ -131,7 +130,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:          false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Needs 1, 2, 3, 4, 5, 6, 7 labels."),
+			desc:  "Not mergeable. Needs 1, 2, 3, 4, 5, 6, 7 labels.",
 		},
 		{
 			name:      "only failed keeper context",
@@ -141,7 +140,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, ""),
+			desc:  "Not mergeable.",
 		},
 		{
 			name:      "single bad context",
@@ -151,7 +150,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Job job-name has not succeeded."),
+			desc:  "Not mergeable. Job job-name has not succeeded.",
 		},
 		{
 			name:      "multiple bad contexts",
@@ -164,7 +163,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool: false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Jobs job-name, other-job-name have not succeeded."),
+			desc:  "Not mergeable. Jobs job-name, other-job-name have not succeeded.",
 		},
 		{
 			name:      "wrong milestone",
@@ -174,7 +173,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Must be in milestone v1.0."),
+			desc:  "Not mergeable. Must be in milestone v1.0.",
 		},
 		{
 			name:      "unknown requirement",
@@ -184,7 +183,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, ""),
+			desc:  "Not mergeable.",
 		},
 		{
 			name:      "check that min diff query is used",
@@ -193,7 +192,7 @@ func TestExpectedStatus(t *testing.T) {
 			inPool:    false,
 
 			state: scmprovider.StatusPending,
-			desc:  fmt.Sprintf(statusNotInPool, " Needs 1, 2 labels."),
+			desc:  "Not mergeable. Needs 1, 2 labels.",
 		},
 		{
 			name:      "check that blockers take precedence over other queries",
@@ -203,7 +202,7 @@ func TestExpectedStatus(t *testing.T) {
 			blocks:    []int{1, 2},
 
 			state: scmprovider.StatusError,
-			desc:  fmt.Sprintf(statusNotInPool, " Merging is blocked by issues 1, 2."),
+			desc:  "Not mergeable. Merging is blocked by issues 1, 2.",
 		},
 	}
 
@@ -274,7 +273,7 @@ func TestExpectedStatus(t *testing.T) {
 		}
 		blocks.Repo[blockers.OrgRepo{Org: "", Repo: ""}] = items
 
-		state, desc := expectedStatus(queriesByRepo, &pr, pool, &config.KeeperContextPolicy{}, blocks, "fake")
+		state, desc := expectedStatus(queriesByRepo, &pr, pool, &config.KeeperContextPolicy{}, blocks, "", "fake")
 		if state != tc.state {
 			t.Errorf("Expected status state %q, but got %q.", string(tc.state), string(state))
 		}
@@ -285,7 +284,7 @@ func TestExpectedStatus(t *testing.T) {
 }
 
 func TestSetStatuses(t *testing.T) {
-	statusNotInPoolEmpty := fmt.Sprintf(statusNotInPool, "")
+	statusNotInPoolEmpty := "Not mergeable."
 	testcases := []struct {
 		name string
 
@@ -302,7 +301,7 @@ func TestSetStatuses(t *testing.T) {
 			inPool:     true,
 			hasContext: true,
 			state:      githubql.StatusStateSuccess,
-			desc:       statusInPool,
+			desc:       "In merge pool.",
 
 			shouldSet: false,
 		},
@@ -330,7 +329,7 @@ func TestSetStatuses(t *testing.T) {
 			inPool:     true,
 			hasContext: true,
 			state:      githubql.StatusStatePending,
-			desc:       statusInPool,
+			desc:       "In merge pool.",
 
 			shouldSet: true,
 		},
@@ -350,7 +349,7 @@ func TestSetStatuses(t *testing.T) {
 			inPool:     false,
 			hasContext: true,
 			state:      githubql.StatusStatePending,
-			desc:       statusInPool,
+			desc:       "In merge pool.",
 
 			shouldSet: true,
 		},
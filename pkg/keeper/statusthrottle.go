@@ -0,0 +1,82 @@
+package keeper
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+)
+
+// StatusThrottle spreads the updates of the keeper status context over several status
+// syncs when many PRs need updating at once, e.g. after a required job is renamed, so
+// that the writes stay under the abuse detection thresholds of the git provider.
+type StatusThrottle struct {
+	// MaxUpdatesPerRepo is the maximum number of status contexts updated per repository
+	// in a status sync, the other updates are deferred to the next syncs. Zero means no limit.
+	MaxUpdatesPerRepo int
+	// Jitter is the maximum random delay between two status updates. Zero means no delay.
+	Jitter time.Duration
+
+	sleep func(time.Duration)
+}
+
+// NewStatusThrottle creates a StatusThrottle. It returns nil if neither the limit nor the
+// jitter is specified, which disables throttling.
+func NewStatusThrottle(maxUpdatesPerRepo int, jitter time.Duration) *StatusThrottle {
+	if maxUpdatesPerRepo <= 0 && jitter <= 0 {
+		return nil
+	}
+	return &StatusThrottle{
+		MaxUpdatesPerRepo: maxUpdatesPerRepo,
+		Jitter:            jitter,
+		sleep:             time.Sleep,
+	}
+}
+
+// statusUpdate is a pending update of the keeper status context of a PR
+type statusUpdate struct {
+	pr          PullRequest
+	inPool      bool
+	status      *scmprovider.Status
+	actualState string
+}
+
+// plan orders the updates by repository, with the PRs in the pool first, and splits them
+// into the updates to make in this sync and the updates deferred to the next sync
+func (t *StatusThrottle) plan(updates []statusUpdate) ([]statusUpdate, []statusUpdate) {
+	sort.SliceStable(updates, func(i, j int) bool {
+		a, b := updates[i], updates[j]
+		if a.pr.Repository.NameWithOwner != b.pr.Repository.NameWithOwner {
+			return a.pr.Repository.NameWithOwner < b.pr.Repository.NameWithOwner
+		}
+		if a.inPool != b.inPool {
+			return a.inPool
+		}
+		return a.pr.Number < b.pr.Number
+	})
+	if t == nil || t.MaxUpdatesPerRepo <= 0 {
+		return updates, nil
+	}
+	var now, deferred []statusUpdate
+	perRepo := map[string]int{}
+	for _, u := range updates {
+		repo := string(u.pr.Repository.NameWithOwner)
+		if perRepo[repo] >= t.MaxUpdatesPerRepo {
+			deferred = append(deferred, u)
+			continue
+		}
+		perRepo[repo]++
+		now = append(now, u)
+	}
+	return now, deferred
+}
+
+// wait waits for a random delay up to the jitter before the next status update
+func (t *StatusThrottle) wait() {
+	if t == nil || t.Jitter <= 0 {
+		return
+	}
+	/* #nosec */
+	t.sleep(time.Duration(rand.Int63n(int64(t.Jitter))))
+}
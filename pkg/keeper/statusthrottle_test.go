@@ -0,0 +1,94 @@
+package keeper
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/keeper/blockers"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func throttledPR(repo string, number int) PullRequest {
+	pr := PullRequest{Number: githubql.Int(number), HeadRefOID: githubql.String(fmt.Sprintf("sha%d", number))}
+	pr.Repository.Owner.Login = "org"
+	pr.Repository.Name = githubql.String(repo)
+	pr.Repository.NameWithOwner = githubql.String("org/" + repo)
+	pr.Commits.Nodes = []struct{ Commit Commit }{{Commit: Commit{OID: pr.HeadRefOID}}}
+	return pr
+}
+
+func TestNewStatusThrottle(t *testing.T) {
+	assert.Nil(t, NewStatusThrottle(0, 0))
+	assert.NotNil(t, NewStatusThrottle(10, 0))
+	assert.NotNil(t, NewStatusThrottle(0, time.Second))
+}
+
+func TestSetStatusesThrottled(t *testing.T) {
+	fc := &fgc{}
+	ca := &config.Agent{}
+	ca.Set(&config.Config{})
+	var slept []time.Duration
+	throttle := NewStatusThrottle(2, time.Second)
+	throttle.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+	}
+	sc := &statusController{spc: fc, config: ca.Config, logger: logrus.WithField("component", "keeper"), throttle: throttle}
+
+	all := []PullRequest{throttledPR("b", 3), throttledPR("a", 2), throttledPR("a", 1), throttledPR("a", 4)}
+	pool := map[string]PullRequest{}
+	inPool := throttledPR("a", 4)
+	pool[prKey(&inPool)] = inPool
+
+	sc.setStatuses(all, pool, blockers.Blockers{})
+	assert.Equal(t, []string{"org/a@sha4", "org/a@sha1", "org/b@sha3"}, fc.statusesSet, "the PRs in the pool are updated first")
+	assert.Len(t, slept, 2, "the updates are spread with jitter")
+	for _, d := range slept {
+		assert.True(t, d >= 0 && d < time.Second)
+	}
+	assert.Len(t, sc.deferred, 1)
+
+	// the deferred PR is re-fetched and updated in the next sync even though the search does not
+	// return it again, but it is kept deferred while it cannot be fetched
+	fc.statusesSet = nil
+	sc.setStatuses(nil, nil, blockers.Blockers{})
+	assert.Empty(t, fc.statusesSet)
+	assert.Len(t, sc.deferred, 1)
+
+	fc.ignoreExpected = true
+	fc.pullRequests = map[int]*scm.PullRequest{2: {Number: 2, Head: scm.PullRequestBranch{Sha: "sha5"}}}
+	sc.setStatuses(nil, nil, blockers.Blockers{})
+	assert.Equal(t, []string{"org/a@sha5"}, fc.statusesSet, "the status is set on the current head")
+	assert.Empty(t, sc.deferred)
+}
+
+func TestSetStatusesDropsClosedDeferredPRs(t *testing.T) {
+	fc := &fgc{ignoreExpected: true}
+	ca := &config.Agent{}
+	ca.Set(&config.Config{})
+	sc := &statusController{spc: fc, config: ca.Config, logger: logrus.WithField("component", "keeper"), throttle: NewStatusThrottle(1, 0)}
+
+	sc.setStatuses([]PullRequest{throttledPR("a", 1), throttledPR("a", 2)}, nil, blockers.Blockers{})
+	assert.Len(t, sc.deferred, 1)
+
+	fc.statusesSet = nil
+	fc.pullRequests = map[int]*scm.PullRequest{2: {Number: 2, Closed: true, Head: scm.PullRequestBranch{Sha: "sha2"}}}
+	sc.setStatuses(nil, nil, blockers.Blockers{})
+	assert.Empty(t, fc.statusesSet)
+	assert.Empty(t, sc.deferred)
+}
+
+func TestSetStatusesUnthrottled(t *testing.T) {
+	fc := &fgc{}
+	ca := &config.Agent{}
+	ca.Set(&config.Config{})
+	sc := &statusController{spc: fc, config: ca.Config, logger: logrus.WithField("component", "keeper")}
+
+	sc.setStatuses([]PullRequest{throttledPR("a", 2), throttledPR("a", 1), throttledPR("a", 3)}, nil, blockers.Blockers{})
+	assert.Equal(t, []string{"org/a@sha1", "org/a@sha2", "org/a@sha3"}, fc.statusesSet)
+	assert.Empty(t, sc.deferred)
+}
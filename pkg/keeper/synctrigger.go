@@ -0,0 +1,162 @@
+package keeper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SyncPath is the path of the keeper endpoint which accepts SyncRequests
+const SyncPath = "/sync"
+
+// SyncRequest identifies the subpools which should be re-synced straight away,
+// typically because the webhook component received an event which may change
+// what keeper would do with them. An empty branch means all the branches of the repository.
+type SyncRequest struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// String returns a readable description of the request
+func (r SyncRequest) String() string {
+	if r.Branch == "" {
+		return r.Org + "/" + r.Repo
+	}
+	return fmt.Sprintf("%s/%s:%s", r.Org, r.Repo, r.Branch)
+}
+
+// matches returns true if the subpool of the given org, repo and branch is covered by the request
+func (r SyncRequest) matches(org, repo, branch string) bool {
+	return org == r.Org && repo == r.Repo && (r.Branch == "" || branch == r.Branch)
+}
+
+// queries narrows the keeper queries which apply to the repository of the request down to that repository
+func (r SyncRequest) queries(queries config.KeeperQueries) config.KeeperQueries {
+	var answer config.KeeperQueries
+	for _, q := range queries {
+		if !q.ForRepo(r.Org, r.Repo) {
+			continue
+		}
+		q.Orgs = nil
+		q.ExcludedRepos = nil
+		q.Repos = []string{r.Org + "/" + r.Repo}
+		answer = append(answer, q)
+	}
+	return answer
+}
+
+// SyncTrigger receives SyncRequests over HTTP and re-syncs the affected
+// subpools as soon as possible. Requests received while a sync is running are
+// de-duplicated so that a burst of events results in a single sync per repository.
+type SyncTrigger struct {
+	controller Controller
+	logger     *logrus.Entry
+
+	lock    sync.Mutex
+	pending map[SyncRequest]bool
+	wakeup  chan bool
+}
+
+// NewSyncTrigger creates a SyncTrigger for the given controller
+func NewSyncTrigger(controller Controller) *SyncTrigger {
+	return &SyncTrigger{
+		controller: controller,
+		logger:     logrus.WithField("controller", "sync-trigger"),
+		pending:    make(map[SyncRequest]bool),
+		wakeup:     make(chan bool, 1),
+	}
+}
+
+// ServeHTTP accepts a JSON encoded SyncRequest
+func (t *SyncTrigger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	request := SyncRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid sync request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if request.Org == "" || request.Repo == "" {
+		http.Error(w, "sync request must specify an org and a repo", http.StatusBadRequest)
+		return
+	}
+	t.Add(request)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Add queues a sync request
+func (t *SyncTrigger) Add(request SyncRequest) {
+	t.lock.Lock()
+	t.pending[request] = true
+	t.lock.Unlock()
+	select {
+	case t.wakeup <- true:
+	default:
+	}
+}
+
+// Run processes the queued sync requests until the stop channel is closed
+func (t *SyncTrigger) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.wakeup:
+			t.process()
+		}
+	}
+}
+
+func (t *SyncTrigger) process() {
+	t.lock.Lock()
+	requests := t.pending
+	t.pending = make(map[SyncRequest]bool)
+	t.lock.Unlock()
+
+	for request := range requests {
+		// a request for the whole repository covers the requests for its branches
+		if request.Branch != "" && requests[SyncRequest{Org: request.Org, Repo: request.Repo}] {
+			continue
+		}
+		if err := t.controller.SyncRepo(request.Org, request.Repo, request.Branch); err != nil {
+			t.logger.WithError(err).Errorf("Error syncing %s.", request.String())
+		}
+	}
+}
+
+// NotifySync asks the keeper at the given URL to re-sync the subpools matching the request,
+// authenticated with the given admin token
+func NotifySync(client *http.Client, keeperURL, token string, request SyncRequest) error {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal sync request")
+	}
+	req, err := http.NewRequest(http.MethodPost, keeperURL, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the sync request for %s", keeperURL)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to notify keeper at %s", keeperURL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("keeper at %s returned status %d for sync request %s", keeperURL, resp.StatusCode, request.String())
+	}
+	return nil
+}
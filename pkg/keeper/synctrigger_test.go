@@ -0,0 +1,87 @@
+package keeper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/keeper/history"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSyncController struct {
+	sync.Mutex
+	synced []SyncRequest
+}
+
+func (f *fakeSyncController) Sync() error {
+	return nil
+}
+
+func (f *fakeSyncController) SyncRepo(org, repo, branch string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.synced = append(f.synced, SyncRequest{Org: org, Repo: repo, Branch: branch})
+	return nil
+}
+
+func (f *fakeSyncController) Shutdown() {}
+
+func (f *fakeSyncController) GetPools() []Pool {
+	return nil
+}
+
+func (f *fakeSyncController) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func (f *fakeSyncController) GetHistory() *history.History {
+	return nil
+}
+
+func TestSyncRequestQueries(t *testing.T) {
+	queries := config.KeeperQueries{
+		{Orgs: []string{"org"}, ExcludedRepos: []string{"org/excluded"}, Labels: []string{"approved"}},
+		{Repos: []string{"other/repo"}},
+	}
+	request := SyncRequest{Org: "org", Repo: "repo"}
+	actual := request.queries(queries)
+	if assert.Len(t, actual, 1) {
+		assert.Equal(t, []string{"org/repo"}, actual[0].Repos)
+		assert.Empty(t, actual[0].Orgs)
+		assert.Equal(t, []string{"approved"}, actual[0].Labels)
+	}
+	// the config queries must not be modified
+	assert.Equal(t, []string{"org"}, queries[0].Orgs)
+
+	assert.Empty(t, SyncRequest{Org: "org", Repo: "excluded"}.queries(queries))
+	assert.True(t, request.matches("org", "repo", "master"))
+	assert.False(t, SyncRequest{Org: "org", Repo: "repo", Branch: "release"}.matches("org", "repo", "master"))
+}
+
+func TestSyncTrigger(t *testing.T) {
+	controller := &fakeSyncController{}
+	trigger := NewSyncTrigger(controller)
+	server := httptest.NewServer(util.AdminHandler("secret", trigger))
+	defer server.Close()
+
+	assert.Error(t, NotifySync(nil, server.URL, "invalid", SyncRequest{Org: "org", Repo: "unauthenticated"}))
+
+	require.NoError(t, NotifySync(nil, server.URL, "secret", SyncRequest{Org: "org", Repo: "repo", Branch: "master"}))
+	require.NoError(t, NotifySync(nil, server.URL, "secret", SyncRequest{Org: "org", Repo: "repo"}))
+	require.NoError(t, NotifySync(nil, server.URL, "secret", SyncRequest{Org: "org", Repo: "repo"}))
+	require.NoError(t, NotifySync(nil, server.URL, "secret", SyncRequest{Org: "org", Repo: "other", Branch: "master"}))
+	assert.Error(t, NotifySync(nil, server.URL, "secret", SyncRequest{Org: "org"}))
+
+	trigger.process()
+	assert.ElementsMatch(t, []SyncRequest{
+		{Org: "org", Repo: "repo"},
+		{Org: "org", Repo: "other", Branch: "master"},
+	}, controller.synced)
+
+	controller.synced = nil
+	trigger.process()
+	assert.Empty(t, controller.synced)
+}
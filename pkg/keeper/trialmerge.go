@@ -0,0 +1,172 @@
+package keeper
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/jenkins-x/lighthouse/pkg/git"
+	githubql "github.com/shurcooL/githubv4"
+)
+
+// TrialMerger keeps the PRs which do not merge cleanly into the current base SHA of their pool out of
+// the pool, by merging them in a clone of the repository. Git providers recompute the mergeability of
+// the PRs asynchronously after the base branch moves, so that keeper would otherwise trigger or batch PRs
+// which have conflicts the provider does not report yet, wasting their test runs.
+//
+// The outcome of each trial merge is cached for the base and head SHAs, so PRs are only merged again
+// when either moves.
+type TrialMerger struct {
+	repos []string
+	// botName is the committer of the merge commits
+	botName string
+
+	lock sync.Mutex
+	// results are the outcomes of the trial merges used since the last prune, keyed by trialMergeKey
+	results     map[string]bool
+	nextResults map[string]bool
+}
+
+// NewTrialMerger creates a TrialMerger for the given orgs and org/repos, committing the merges as the
+// bot. It returns nil if there are none, which disables the trial merges.
+func NewTrialMerger(repos []string, botName string) *TrialMerger {
+	if len(repos) == 0 {
+		return nil
+	}
+	return &TrialMerger{
+		repos:       repos,
+		botName:     botName,
+		results:     map[string]bool{},
+		nextResults: map[string]bool{},
+	}
+}
+
+// appliesTo returns true if the PRs of the org/repo repository are merged before entering the pool
+func (t *TrialMerger) appliesTo(org, repo string) bool {
+	for _, r := range t.repos {
+		if strings.EqualFold(r, org) || strings.EqualFold(r, org+"/"+repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter removes the PRs which do not merge cleanly into the base SHA of the subpool from it. The PRs
+// the provider already reports as conflicting are left to filterPR. If the repository cannot be cloned
+// the PRs whose outcome is not cached are kept, leaving the conflicts to the provider, without cloning
+// it again for each of them.
+func (t *TrialMerger) Filter(gc git.Client, sp *subpool) {
+	if t == nil || !t.appliesTo(sp.org, sp.repo) {
+		return
+	}
+	var toKeep []PullRequest
+	var r *git.Repo
+	var cloneErr error
+	defer func() {
+		if r != nil {
+			if err := r.Clean(); err != nil {
+				sp.log.WithError(err).Warn("failed to clean the clone of the trial merges")
+			}
+		}
+	}()
+	for _, pr := range sp.prs {
+		log := sp.log.WithFields(pr.logFields())
+		if pr.Mergeable == githubql.MergeableStateConflicting {
+			toKeep = append(toKeep, pr)
+			continue
+		}
+		key := trialMergeKey(sp.sha, string(pr.HeadRefOID))
+		merged, ok := t.result(key)
+		if !ok {
+			if r == nil && cloneErr == nil {
+				r, cloneErr = cloneForMerges(gc, sp, t.botName)
+				if cloneErr != nil {
+					sp.log.WithError(cloneErr).Warn("failed to clone the repository for the trial merges, keeping the PRs")
+				}
+			}
+			if cloneErr != nil {
+				toKeep = append(toKeep, pr)
+				continue
+			}
+			var err error
+			merged, err = trialMerge(r, sp.sha, string(pr.HeadRefOID))
+			if err != nil {
+				log.WithError(err).Warn("failed to merge the PR into the base SHA, keeping it")
+				toKeep = append(toKeep, pr)
+				// the clone may be in a bad state
+				_ = r.Clean()
+				r = nil
+				continue
+			}
+			t.record(key, merged)
+		}
+		if !merged {
+			log.WithField("baseSHA", sp.sha).Debug("filtering out PR as it does not merge cleanly into the base SHA")
+			continue
+		}
+		toKeep = append(toKeep, pr)
+	}
+	sp.prs = toKeep
+}
+
+// result returns the cached outcome of the trial merge of the key, if any
+func (t *TrialMerger) result(key string) (bool, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	merged, ok := t.nextResults[key]
+	if !ok {
+		merged, ok = t.results[key]
+		if ok {
+			t.nextResults[key] = merged
+		}
+	}
+	return merged, ok
+}
+
+// record caches the outcome of the trial merge of the key
+func (t *TrialMerger) record(key string, merged bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.nextResults[key] = merged
+}
+
+// prune removes the outcomes which were not used since the last prune
+func (t *TrialMerger) prune() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.results = t.nextResults
+	t.nextResults = map[string]bool{}
+}
+
+func trialMergeKey(baseSHA, headSHA string) string {
+	return baseSHA + ".." + headSHA
+}
+
+// cloneForMerges clones the repository of the subpool, from the cache of the git client, with the bot
+// configured as the committer of the merge commits
+func cloneForMerges(gc git.Client, sp *subpool, botName string) (*git.Repo, error) {
+	r, err := gc.Clone(sp.org + "/" + sp.repo)
+	if err != nil {
+		return nil, err
+	}
+	if botName == "" {
+		botName = "lighthouse"
+	}
+	for _, kv := range [][2]string{{"user.name", botName}, {"user.email", botName + "@localhost"}, {"commit.gpgsign", "false"}} {
+		if err := r.Config(kv[0], kv[1]); err != nil {
+			_ = r.Clean()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// trialMerge returns true if the head SHA merges cleanly into the base SHA
+func trialMerge(r *git.Repo, baseSHA, headSHA string) (bool, error) {
+	if err := r.Checkout(baseSHA); err != nil {
+		return false, err
+	}
+	return r.Merge(headSHA)
+}
@@ -0,0 +1,83 @@
+package keeper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/git"
+	"github.com/jenkins-x/lighthouse/pkg/git/localgit"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrialMerger(t *testing.T) {
+	assert.Nil(t, NewTrialMerger(nil, "bot"))
+
+	lg, gc, err := localgit.New()
+	require.NoError(t, err)
+	defer gc.Clean()
+	defer lg.Clean()
+	require.NoError(t, lg.MakeFakeRepo("o", "r"))
+	require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{"foo": []byte("foo")}))
+
+	branches := map[string]map[string][]byte{
+		"clean":    {"bar": []byte("bar")},
+		"conflict": {"foo": []byte("conflicts with master")},
+	}
+	for branch, files := range branches {
+		require.NoError(t, lg.CheckoutNewBranch("o", "r", branch))
+		require.NoError(t, lg.AddCommit("o", "r", files))
+		require.NoError(t, lg.Checkout("o", "r", "master"))
+	}
+	require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{"foo": []byte("moved on")}))
+
+	newPR := func(number int, branch string) PullRequest {
+		var pr PullRequest
+		pr.Number = githubql.Int(number)
+		pr.HeadRefOID = githubql.String("origin/" + branch)
+		return pr
+	}
+	sp := &subpool{
+		log:    logrus.WithField("component", "keeper"),
+		org:    "o",
+		repo:   "r",
+		branch: "master",
+		sha:    "master",
+		prs:    []PullRequest{newPR(1, "clean"), newPR(2, "conflict")},
+	}
+
+	NewTrialMerger([]string{"other"}, "bot").Filter(gc, sp)
+	assert.Len(t, sp.prs, 2, "the PRs of other repositories are not merged")
+
+	tm := NewTrialMerger([]string{"o/r"}, "bot")
+	tm.Filter(gc, sp)
+	require.Len(t, sp.prs, 1)
+	assert.Equal(t, githubql.Int(1), sp.prs[0].Number)
+
+	merged, ok := tm.result(trialMergeKey("master", "origin/conflict"))
+	assert.True(t, ok, "the outcome of the trial merge is cached")
+	assert.False(t, merged)
+	tm.prune()
+	tm.prune()
+	_, ok = tm.result(trialMergeKey("master", "origin/conflict"))
+	assert.False(t, ok, "the outcomes which are not used are pruned")
+
+	failing := &failingCloneClient{Client: gc}
+	sp.prs = []PullRequest{newPR(1, "clean"), newPR(2, "conflict")}
+	tm.Filter(failing, sp)
+	assert.Len(t, sp.prs, 2, "the PRs are kept when the repository cannot be cloned")
+	assert.Equal(t, 1, failing.clones, "the repository is not cloned again for each PR")
+}
+
+// failingCloneClient is a git client which fails to clone the repositories
+type failingCloneClient struct {
+	git.Client
+	clones int
+}
+
+func (c *failingCloneClient) Clone(repo string) (*git.Repo, error) {
+	c.clones++
+	return nil, errors.New("failed to clone")
+}
@@ -0,0 +1,215 @@
+package launcher
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	clientset "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// QueuedRepositoryAnnotation is the annotation of the queued LighthouseJobs containing the repository
+	// their pipeline is launched for once they are released
+	QueuedRepositoryAnnotation = "lighthouse.jenkins-x.io/queuedRepository"
+
+	// queueSyncPeriod is how often the queued jobs are released if their job has completed instances
+	queueSyncPeriod = 10 * time.Second
+)
+
+// Scheduler is implemented by the launchers holding the jobs whose max_concurrency is reached
+type Scheduler interface {
+	// RunScheduler launches the queued jobs as the running instances of their job complete, until stop is closed
+	RunScheduler(stop <-chan struct{})
+}
+
+// concurrencyLimiter holds the jobs whose max_concurrency is reached as LighthouseJobs in the queued state,
+// and releases them, oldest first, once fewer instances of their job are running
+type concurrencyLimiter struct {
+	lhClient  clientset.Interface
+	namespace string
+	logger    *logrus.Entry
+
+	lock sync.Mutex
+}
+
+// newConcurrencyLimiter creates a limiter of the jobs of the namespace of the clients, or returns nil
+// if there is no Lighthouse client, in which case the max_concurrency of the jobs is not enforced
+func newConcurrencyLimiter(c *clients.Clients) *concurrencyLimiter {
+	if c == nil || c.Lighthouse == nil {
+		return nil
+	}
+	return &concurrencyLimiter{
+		lhClient:  c.Lighthouse,
+		namespace: c.Namespace,
+		logger:    logrus.WithField("component", "concurrency-limiter"),
+	}
+}
+
+// hold creates the LighthouseJob of the request in the queued state if its max_concurrency is reached, or
+// if older instances of its job are already queued. It returns nil if the job can be launched.
+func (q *concurrencyLimiter) hold(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	if q == nil || request.Spec.MaxConcurrency <= 0 {
+		return nil, nil
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	jobs, err := q.activeJobs()
+	if err != nil {
+		return nil, err
+	}
+	running := 0
+	queued := 0
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Name == request.Name {
+			// a redelivery of the event which created the job
+			return job, nil
+		}
+		if job.Spec.Job != request.Spec.Job {
+			continue
+		}
+		if job.Status.State == v1alpha1.QueuedState {
+			queued++
+		} else {
+			running++
+		}
+	}
+	if running < request.Spec.MaxConcurrency && queued == 0 {
+		return nil, nil
+	}
+	q.logger.WithFields(logrus.Fields{"job": request.Spec.Job, "running": running, "queued": queued}).Info("max concurrency reached, queuing the job")
+	return q.queue(request, repository)
+}
+
+// queue creates the LighthouseJob of the request in the queued state
+func (q *concurrencyLimiter) queue(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	data, err := json.Marshal(repository)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the repository of the job")
+	}
+	job := request.DeepCopy()
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[QueuedRepositoryAnnotation] = string(data)
+	created, err := q.lhClient.LighthouseV1alpha1().LighthouseJobs(q.namespace).Create(job)
+	if err != nil {
+		if kubeerrors.IsAlreadyExists(err) {
+			return q.lhClient.LighthouseV1alpha1().LighthouseJobs(q.namespace).Get(job.Name, metav1.GetOptions{})
+		}
+		return nil, errors.Wrapf(err, "unable to create the queued LighthouseJob %s", job.Name)
+	}
+	created.Status = v1alpha1.LighthouseJobStatus{
+		State:       v1alpha1.QueuedState,
+		Description: "Waiting for the running instances of the job to complete",
+		StartTime:   metav1.Now(),
+	}
+	queued, err := q.lhClient.LighthouseV1alpha1().LighthouseJobs(q.namespace).UpdateStatus(created)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to set the status of the queued LighthouseJob %s", job.Name)
+	}
+	return queued, nil
+}
+
+// release launches the queued jobs, oldest first, of the jobs which have fewer running instances than their
+// max_concurrency. A queued job is deleted before its pipeline is launched, so that it is launched once even
+// if several launchers release the queued jobs.
+func (q *concurrencyLimiter) release(launch func(*v1alpha1.LighthouseJob, scm.Repository) (*v1alpha1.LighthouseJob, error)) {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	jobs, err := q.activeJobs()
+	if err != nil {
+		q.logger.WithError(err).Warn("failed to list the active jobs")
+		return
+	}
+	running := map[string]int{}
+	var queued []*v1alpha1.LighthouseJob
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Status.State == v1alpha1.QueuedState {
+			queued = append(queued, job)
+		} else {
+			running[job.Spec.Job]++
+		}
+	}
+	sort.SliceStable(queued, func(i, j int) bool {
+		return queued[i].CreationTimestamp.Before(&queued[j].CreationTimestamp)
+	})
+	for _, job := range queued {
+		if running[job.Spec.Job] >= job.Spec.MaxConcurrency {
+			continue
+		}
+		log := q.logger.WithFields(logrus.Fields{"job": job.Spec.Job, "LighthouseJob": job.Name})
+		repository := scm.Repository{}
+		if err := json.Unmarshal([]byte(job.Annotations[QueuedRepositoryAnnotation]), &repository); err != nil {
+			log.WithError(err).Warn("invalid repository of the queued job")
+			continue
+		}
+		uid := job.UID
+		err := q.lhClient.LighthouseV1alpha1().LighthouseJobs(q.namespace).Delete(job.Name, &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}})
+		if err != nil {
+			// another launcher released the job
+			log.WithError(err).Debug("failed to delete the queued job")
+			continue
+		}
+		request := releasedJob(job)
+		if _, err := launch(request, repository); err != nil {
+			log.WithError(err).Error("failed to launch the queued job, queuing it again")
+			if _, err := q.queue(request, repository); err != nil {
+				log.WithError(err).Error("failed to queue the job again")
+			}
+			continue
+		}
+		log.Info("launched the queued job")
+		running[job.Spec.Job]++
+	}
+}
+
+// activeJobs returns the LighthouseJobs of the namespace which are not completed
+func (q *concurrencyLimiter) activeJobs() ([]v1alpha1.LighthouseJob, error) {
+	list, err := q.lhClient.LighthouseV1alpha1().LighthouseJobs(q.namespace).List(metav1.ListOptions{LabelSelector: util.ActiveJobsSelector})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the active LighthouseJobs")
+	}
+	var answer []v1alpha1.LighthouseJob
+	for _, job := range list.Items {
+		switch job.Status.State {
+		case v1alpha1.SuccessState, v1alpha1.FailureState, v1alpha1.AbortedState:
+			continue
+		}
+		answer = append(answer, job)
+	}
+	return answer, nil
+}
+
+// releasedJob returns the request launching the queued job, which keeps its name, labels and spec
+func releasedJob(job *v1alpha1.LighthouseJob) *v1alpha1.LighthouseJob {
+	annotations := map[string]string{}
+	for k, v := range job.Annotations {
+		if k != QueuedRepositoryAnnotation {
+			annotations[k] = v
+		}
+	}
+	return &v1alpha1.LighthouseJob{
+		TypeMeta: job.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        job.Name,
+			Labels:      job.Labels,
+			Annotations: annotations,
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+}
@@ -0,0 +1,96 @@
+package launcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// creatingLauncher creates the LighthouseJobs it launches in the pending state, like the launchers of the agents
+type creatingLauncher struct {
+	lhClient *fake.Clientset
+	launched []string
+}
+
+func (l *creatingLauncher) Launch(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	l.launched = append(l.launched, request.Name+"@"+repository.FullName)
+	request.Status.State = v1alpha1.PendingState
+	return l.lhClient.LighthouseV1alpha1().LighthouseJobs("jx").Create(request)
+}
+
+func limitedJob(name string, created time.Time) *v1alpha1.LighthouseJob {
+	return &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)},
+		Spec:       v1alpha1.LighthouseJobSpec{Job: "deploy", MaxConcurrency: 1},
+	}
+}
+
+func TestAgentLauncherMaxConcurrency(t *testing.T) {
+	defer func(saved map[string]Factory) {
+		factories = saved
+	}(factories)
+	lhClient := fake.NewSimpleClientset()
+	recorder := &creatingLauncher{lhClient: lhClient}
+	factories = map[string]Factory{}
+	Register(DefaultAgent, func(Options) (PipelineLauncher, error) {
+		return recorder, nil
+	})
+
+	pl, err := NewAgentLauncher(Options{Clients: &clients.Clients{Lighthouse: lhClient, Namespace: "jx"}})
+	require.NoError(t, err)
+	l := pl.(*agentLauncher)
+	repo := scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo"}
+	now := time.Now()
+
+	job, err := l.Launch(limitedJob("a", now), repo)
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.PendingState, job.Status.State)
+
+	job, err = l.Launch(limitedJob("b", now.Add(time.Second)), repo)
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.QueuedState, job.Status.State, "the max concurrency is reached")
+	job, err = l.Launch(limitedJob("c", now.Add(2*time.Second)), repo)
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.QueuedState, job.Status.State)
+	job, err = l.Launch(limitedJob("b", now.Add(3*time.Second)), repo)
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.QueuedState, job.Status.State, "the redelivered job is not queued twice")
+
+	other := limitedJob("d", now)
+	other.Spec.Job = "lint"
+	job, err = l.Launch(other, repo)
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.PendingState, job.Status.State, "the limit applies to each job")
+	unlimited := limitedJob("e", now)
+	unlimited.Spec.MaxConcurrency = 0
+	_, err = l.Launch(unlimited, repo)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a@org/repo", "d@org/repo", "e@org/repo"}, recorder.launched)
+
+	l.limiter.release(l.launch)
+	assert.Len(t, recorder.launched, 3, "the queued jobs are held while the job runs")
+
+	running, err := lhClient.LighthouseV1alpha1().LighthouseJobs("jx").Get("a", metav1.GetOptions{})
+	require.NoError(t, err)
+	running.Status.State = v1alpha1.SuccessState
+	_, err = lhClient.LighthouseV1alpha1().LighthouseJobs("jx").UpdateStatus(running)
+	require.NoError(t, err)
+
+	l.limiter.release(l.launch)
+	assert.Equal(t, "b@org/repo", recorder.launched[3], "the oldest queued job is launched for the repository it was queued for")
+	assert.Len(t, recorder.launched, 4, "a single job is released")
+	released, err := lhClient.LighthouseV1alpha1().LighthouseJobs("jx").Get("b", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.PendingState, released.Status.State)
+	assert.NotContains(t, released.Annotations, QueuedRepositoryAnnotation)
+	queued, err := lhClient.LighthouseV1alpha1().LighthouseJobs("jx").Get("c", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.QueuedState, queued.Status.State)
+}
@@ -5,7 +5,9 @@ import (
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/errorutil"
 	"github.com/jenkins-x/lighthouse/pkg/launcher"
+	"github.com/jenkins-x/lighthouse/pkg/util"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
@@ -29,7 +31,14 @@ func NewLauncher() *Launcher {
 // Launch creates a launcher job
 func (p *Launcher) Launch(po *v1alpha1.LighthouseJob, repo scm.Repository) (*v1alpha1.LighthouseJob, error) {
 	if p.FailJobs.Has(po.Spec.Job) {
-		return po, errors.New("failed to create job")
+		return po, errorutil.NewUserError("failed to create job", errors.New("the job is configured to fail"))
+	}
+	if key := po.Labels[util.IdempotencyKeyLabel]; key != "" {
+		for _, existing := range p.Pipelines {
+			if existing.Labels[util.IdempotencyKeyLabel] == key {
+				return existing, nil
+			}
+		}
 	}
 	p.Pipelines = append(p.Pipelines, po)
 	po.Status.State = v1alpha1.SuccessState
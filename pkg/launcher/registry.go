@@ -0,0 +1,160 @@
+package launcher
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/errorutil"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultAgent is the agent launching the pipelines with the jx meta pipeline, which is the default
+// agent unless another one is configured in the launcher section of config.yaml
+const DefaultAgent = "jx"
+
+// Options are the clients and configuration given to the factories of the launchers
+type Options struct {
+	Clients *clients.Clients
+	Config  config.Getter
+	// Settings provides the default agent and the default environment variables of the jobs, it may be nil
+	Settings settings.Getter
+}
+
+// Factory creates the launcher of an agent
+type Factory func(Options) (PipelineLauncher, error)
+
+var factories = map[string]Factory{}
+
+// Register registers the factory of the launcher of the agent. Launchers register themselves in their
+// init function, so they are available to the binaries importing them.
+func Register(agent string, factory Factory) {
+	factories[agent] = factory
+}
+
+// Agents returns the names of the registered agents
+func Agents() []string {
+	var answer []string
+	for agent := range factories {
+		answer = append(answer, agent)
+	}
+	sort.Strings(answer)
+	return answer
+}
+
+// agentLauncher launches each job with the launcher of its agent
+type agentLauncher struct {
+	options Options
+	limiter *concurrencyLimiter
+
+	lock      sync.Mutex
+	launchers map[string]PipelineLauncher
+}
+
+// NewAgentLauncher creates a launcher launching each job with the launcher of the agent of its
+// spec, which is the agent of its job configuration, or of the default agent of the launcher settings. The launcher of the default agent
+// is created immediately so that misconfigurations fail fast, the others when first used. The jobs whose
+// max_concurrency is reached are queued, and launched by the RunScheduler of a launcher.
+func NewAgentLauncher(options Options) (PipelineLauncher, error) {
+	l := &agentLauncher{
+		options:   options,
+		limiter:   newConcurrencyLimiter(options.Clients),
+		launchers: map[string]PipelineLauncher{},
+	}
+	if _, err := l.launcher(l.defaultAgent()); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// defaultAgent returns the agent of the jobs which do not name one in the current settings
+func (l *agentLauncher) defaultAgent() string {
+	if l.options.Settings != nil {
+		if agent := l.options.Settings().Launcher.DefaultAgent; agent != "" {
+			return agent
+		}
+	}
+	return DefaultAgent
+}
+
+// Launch launches the job with the launcher of its agent, adding the default environment variables, or
+// queues it if its max_concurrency is reached
+func (l *agentLauncher) Launch(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	if l.options.Settings != nil {
+		s := l.options.Settings()
+		if s.ReadOnly {
+			logrus.WithFields(logrus.Fields{"job": request.Spec.Job, "type": request.Spec.Type}).Info("read-only mode: not launching the job")
+			return request, nil
+		}
+		jobutil.ApplyDefaultEnv(&request.Spec, s.DefaultEnv)
+	}
+	queued, err := l.limiter.hold(request, repository)
+	if err != nil {
+		return nil, errorutil.FromKubernetesError("failed to queue the job", err)
+	}
+	if queued != nil {
+		return queued, nil
+	}
+	return l.launch(request, repository)
+}
+
+// RunScheduler launches the queued jobs every queueSyncPeriod, as the running instances of their job complete
+func (l *agentLauncher) RunScheduler(stop <-chan struct{}) {
+	if l.limiter == nil {
+		return
+	}
+	ticker := time.NewTicker(queueSyncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.limiter.release(l.launch)
+		}
+	}
+}
+
+// launch launches the job with the launcher of its agent
+func (l *agentLauncher) launch(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	agent := request.Spec.Agent
+	if agent == "" {
+		agent = l.defaultAgent()
+	}
+	launcher, err := l.launcher(agent)
+	if err != nil {
+		return nil, errorutil.NewUserError(fmt.Sprintf("the pipelines of the agent %s cannot be launched", agent), err)
+	}
+	answer, err := launcher.Launch(request, repository)
+	if err != nil {
+		return answer, errorutil.FromKubernetesError("failed to create the pipeline of the job", err)
+	}
+	return answer, nil
+}
+
+// launcher returns the launcher of the agent, creating it if needed
+func (l *agentLauncher) launcher(agent string) (PipelineLauncher, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if launcher := l.launchers[agent]; launcher != nil {
+		return launcher, nil
+	}
+	factory := factories[agent]
+	if factory == nil {
+		return nil, errors.Errorf("no launcher registered for agent %q, the registered agents are %v", agent, Agents())
+	}
+	launcher, err := factory(l.options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the launcher of agent %s", agent)
+	}
+	l.launchers[agent] = launcher
+	return launcher, nil
+}
@@ -0,0 +1,124 @@
+package launcher
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type recordingLauncher struct {
+	launched []string
+}
+
+func (l *recordingLauncher) Launch(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	l.launched = append(l.launched, request.Name)
+	return request, nil
+}
+
+func agentJob(name, agent string) *v1alpha1.LighthouseJob {
+	return &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1alpha1.LighthouseJobSpec{Agent: agent},
+	}
+}
+
+func TestAgentLauncher(t *testing.T) {
+	defer func(saved map[string]Factory) {
+		factories = saved
+	}(factories)
+
+	launchers := map[string]*recordingLauncher{}
+	created := map[string]int{}
+	factories = map[string]Factory{}
+	for _, agent := range []string{DefaultAgent, "tekton"} {
+		agent := agent
+		Register(agent, func(Options) (PipelineLauncher, error) {
+			created[agent]++
+			launchers[agent] = &recordingLauncher{}
+			return launchers[agent], nil
+		})
+	}
+	Register("broken", func(Options) (PipelineLauncher, error) {
+		return nil, errors.New("no clients")
+	})
+	assert.Equal(t, []string{"broken", DefaultAgent, "tekton"}, Agents())
+
+	l, err := NewAgentLauncher(Options{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{DefaultAgent: 1}, created, "only the launcher of the default agent is created up front")
+
+	for _, job := range []*v1alpha1.LighthouseJob{agentJob("a", ""), agentJob("b", "tekton"), agentJob("c", "tekton"), agentJob("d", DefaultAgent)} {
+		_, err := l.Launch(job, scm.Repository{})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"a", "d"}, launchers[DefaultAgent].launched)
+	assert.Equal(t, []string{"b", "c"}, launchers["tekton"].launched)
+	assert.Equal(t, map[string]int{DefaultAgent: 1, "tekton": 1}, created, "the launchers are reused")
+
+	_, err = l.Launch(agentJob("e", "jenkins"), scm.Repository{})
+	assert.Error(t, err, "the agent must be registered")
+	_, err = l.Launch(agentJob("f", "broken"), scm.Repository{})
+	assert.Error(t, err)
+
+	s := &settings.Config{Launcher: settings.Launcher{DefaultAgent: "tekton"}}
+	l, err = NewAgentLauncher(Options{Settings: func() *settings.Config { return s }})
+	require.NoError(t, err)
+	_, err = l.Launch(agentJob("g", ""), scm.Repository{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"g"}, launchers["tekton"].launched, "the default agent is configured by the settings")
+
+	s.Launcher.DefaultAgent = DefaultAgent
+	_, err = l.Launch(agentJob("h", ""), scm.Repository{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h"}, launchers[DefaultAgent].launched, "the default agent is reloaded with the settings")
+
+	s.Launcher.DefaultAgent = "jenkins"
+	_, err = NewAgentLauncher(Options{Settings: func() *settings.Config { return s }})
+	assert.Error(t, err, "the default agent must be registered")
+}
+
+func TestAgentLauncherAddsTheDefaultEnv(t *testing.T) {
+	defer func(saved map[string]Factory) {
+		factories = saved
+	}(factories)
+	factories = map[string]Factory{}
+	Register(DefaultAgent, func(Options) (PipelineLauncher, error) {
+		return &recordingLauncher{}, nil
+	})
+
+	s := &settings.Config{DefaultEnv: map[string]string{"ARTIFACT_BUCKET": "gs://artifacts"}}
+	l, err := NewAgentLauncher(Options{Settings: func() *settings.Config { return s }})
+	require.NoError(t, err)
+	job, err := l.Launch(agentJob("a", ""), scm.Repository{})
+	require.NoError(t, err)
+	assert.Equal(t, "gs://artifacts", job.Spec.GetEnvVars()["ARTIFACT_BUCKET"])
+}
+
+func TestAgentLauncherReadOnly(t *testing.T) {
+	defer func(saved map[string]Factory) {
+		factories = saved
+	}(factories)
+	factories = map[string]Factory{}
+	recorder := &recordingLauncher{}
+	Register(DefaultAgent, func(Options) (PipelineLauncher, error) {
+		return recorder, nil
+	})
+
+	s := &settings.Config{ReadOnly: true}
+	l, err := NewAgentLauncher(Options{Settings: func() *settings.Config { return s }})
+	require.NoError(t, err)
+	_, err = l.Launch(agentJob("a", ""), scm.Repository{})
+	require.NoError(t, err)
+	assert.Empty(t, recorder.launched, "no job is launched in read-only mode")
+
+	s.ReadOnly = false
+	_, err = l.Launch(agentJob("b", ""), scm.Repository{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, recorder.launched)
+}
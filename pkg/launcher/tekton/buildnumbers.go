@@ -0,0 +1,111 @@
+package tekton
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	clientset "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// BuildNumbersConfigMapName is the name of the ConfigMap holding the latest build number of the jobs
+// of each repository branch
+const BuildNumbersConfigMapName = "lighthouse-build-numbers"
+
+// buildNumbers allocates the build numbers of the jobs, which foghorn uses to match the PipelineRuns
+// with the jobs. The latest build number of each branch is incremented in a ConfigMap with optimistic
+// concurrency so that concurrent launches by the webhook and keeper replicas never share a number.
+type buildNumbers struct {
+	kubeClient kubernetes.Interface
+	lhClient   clientset.Interface
+	namespace  string
+}
+
+// next allocates the build number following the latest one of the repository branch of the job
+func (b *buildNumbers) next(job *v1alpha1.LighthouseJob) (string, error) {
+	key := buildNumberKey(job)
+	build := 0
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMaps := b.kubeClient.CoreV1().ConfigMaps(b.namespace)
+		cm, err := configMaps.Get(BuildNumbersConfigMapName, metav1.GetOptions{})
+		create := kubeerrors.IsNotFound(err)
+		if create {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: BuildNumbersConfigMapName, Namespace: b.namespace}}
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to get the ConfigMap %s", BuildNumbersConfigMapName)
+		}
+		latest, err := strconv.Atoi(cm.Data[key])
+		if err != nil {
+			// the branch has no counter yet, it continues from the jobs launched before it was created
+			latest, err = b.latestJobBuildNumber(job)
+			if err != nil {
+				return err
+			}
+		}
+		build = latest + 1
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = strconv.Itoa(build)
+		if create {
+			_, err = configMaps.Create(cm)
+			if kubeerrors.IsAlreadyExists(err) {
+				// another replica created the ConfigMap concurrently
+				return kubeerrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, BuildNumbersConfigMapName, err)
+			}
+			return err
+		}
+		// the conflict error is returned as is so that it is retried
+		_, err = configMaps.Update(cm)
+		return err
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to allocate a build number for %s", key)
+	}
+	return strconv.Itoa(build), nil
+}
+
+// latestJobBuildNumber returns the latest build number of the jobs of the same repository branch
+func (b *buildNumbers) latestJobBuildNumber(job *v1alpha1.LighthouseJob) (int, error) {
+	var selectors []string
+	for _, label := range []string{util.OrgLabel, util.RepoLabel, util.BranchLabel} {
+		if value := job.Labels[label]; value != "" {
+			selectors = append(selectors, fmt.Sprintf("%s=%s", label, value))
+		}
+	}
+	list, err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).List(metav1.ListOptions{
+		LabelSelector: strings.Join(selectors, ","),
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to list the LighthouseJobs of the branch")
+	}
+	latest := 0
+	for _, j := range list.Items {
+		if n, err := strconv.Atoi(j.Labels[util.BuildNumLabel]); err == nil && n > latest {
+			latest = n
+		}
+	}
+	return latest, nil
+}
+
+// buildNumberKey returns the key of the build number of the repository branch of the job in the
+// ConfigMap, whose keys may only contain alphanumeric characters, '-', '_' or '.'
+func buildNumberKey(job *v1alpha1.LighthouseJob) string {
+	key := strings.Join([]string{job.Labels[util.OrgLabel], job.Labels[util.RepoLabel], job.Labels[util.BranchLabel]}, ".")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, key)
+}
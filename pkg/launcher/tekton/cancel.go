@@ -0,0 +1,45 @@
+package tekton
+
+import (
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	tektonclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/apis"
+)
+
+// CancelPipelineRuns cancels the PipelineRuns of the job which are still running, whether they were created
+// by this launcher or by the jx meta pipeline, and returns the names of the cancelled PipelineRuns
+func CancelPipelineRuns(tektonClient tektonclient.Interface, job *v1alpha1.LighthouseJob) ([]string, error) {
+	build := job.Labels[util.BuildNumLabel]
+	if tektonClient == nil || build == "" || job.Spec.Refs == nil {
+		return nil, nil
+	}
+	selector := labels.SelectorFromSet(labels.Set{
+		util.ActivityOwnerLabel:      job.Spec.Refs.Org,
+		util.ActivityRepositoryLabel: job.Spec.Refs.Repo,
+		util.ActivityBuildLabel:      build,
+		util.ActivityContextLabel:    job.Spec.Context,
+	})
+	runs := tektonClient.TektonV1alpha1().PipelineRuns(job.Namespace)
+	list, err := runs.List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the PipelineRuns of %s", job.Name)
+	}
+	var cancelled []string
+	for i := range list.Items {
+		run := &list.Items[i]
+		if condition := run.Status.GetCondition(apis.ConditionSucceeded); (condition != nil && !condition.IsUnknown()) || run.Spec.Status == pipelinev1alpha1.PipelineRunSpecStatusCancelled {
+			continue
+		}
+		run.Spec.Status = pipelinev1alpha1.PipelineRunSpecStatusCancelled
+		if _, err := runs.Update(run); err != nil {
+			return cancelled, errors.Wrapf(err, "failed to cancel PipelineRun %s", run.Name)
+		}
+		cancelled = append(cancelled, run.Name)
+	}
+	return cancelled, nil
+}
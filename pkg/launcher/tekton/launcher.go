@@ -0,0 +1,263 @@
+// Package tekton launches the pipelines of the LighthouseJobs by creating Tekton PipelineRuns
+// referencing Pipelines, so that Lighthouse can run on clusters without the jx meta
+// pipeline. The status of the jobs is reported by foghorn when it watches the PipelineRuns.
+package tekton
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	clientset "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	launcher2 "github.com/jenkins-x/lighthouse/pkg/launcher"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Agent is the agent of the jobs launched by creating their PipelineRuns directly
+	Agent = "tekton"
+
+	// PipelineRefAnnotation is the annotation of the jobs naming the Tekton Pipeline they run.
+	// Defaults to the name of the job.
+	PipelineRefAnnotation = "lighthouse.jenkins-x.io/pipelineRef"
+	// ServiceAccountAnnotation is the annotation of the jobs naming the ServiceAccount of their
+	// PipelineRuns. Defaults to $JX_SERVICE_ACCOUNT or tekton-bot.
+	ServiceAccountAnnotation = "lighthouse.jenkins-x.io/serviceAccount"
+
+	// RepoURLParam is the PipelineRun parameter containing the clone URL of the repository
+	RepoURLParam = "REPO_URL"
+	// BuildIDParam is the PipelineRun parameter containing the build number of the job
+	BuildIDParam = "BUILD_ID"
+)
+
+func init() {
+	launcher2.Register(Agent, func(o launcher2.Options) (launcher2.PipelineLauncher, error) {
+		return NewLauncher(o.Clients.Tekton, o.Clients.Kube, o.Clients.Lighthouse, o.Clients.Namespace, o.Config), nil
+	})
+}
+
+// launcher creates the PipelineRuns of the jobs
+type launcher struct {
+	tektonClient tektonclient.Interface
+	lhClient     clientset.Interface
+	buildNumbers *buildNumbers
+	namespace    string
+	config       config.Getter
+}
+
+// NewLauncher creates a launcher creating the PipelineRuns of the jobs in the namespace, using the
+// annotations of the launched jobs, or of the jobs in the latest configuration for those they do not set
+func NewLauncher(tektonClient tektonclient.Interface, kubeClient kubernetes.Interface, lhClient clientset.Interface, namespace string, cfg config.Getter) launcher2.PipelineLauncher {
+	return &launcher{
+		tektonClient: tektonClient,
+		lhClient:     lhClient,
+		buildNumbers: &buildNumbers{kubeClient: kubeClient, lhClient: lhClient, namespace: namespace},
+		namespace:    namespace,
+		config:       cfg,
+	}
+}
+
+// Launch creates the LighthouseJob and the PipelineRun of the Pipeline of the job
+func (b *launcher) Launch(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	spec := &request.Spec
+	l := logrus.WithFields(logrus.Fields{
+		"Owner":         repository.Namespace,
+		"Name":          repository.Name,
+		"Job":           spec.Job,
+		"LighthouseJob": request.Name,
+	})
+	existing, err := b.findExistingJob(request)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		l.WithField("LighthouseJob", existing.Name).Info("an equivalent LighthouseJob already exists, not starting a duplicate pipeline")
+		return existing, nil
+	}
+
+	annotations := b.jobAnnotations(request, repository)
+	pipelineName := annotations[PipelineRefAnnotation]
+	if pipelineName == "" {
+		pipelineName = spec.Job
+	}
+	sa := annotations[ServiceAccountAnnotation]
+	if sa == "" {
+		sa = os.Getenv("JX_SERVICE_ACCOUNT")
+	}
+	if sa == "" {
+		sa = "tekton-bot"
+	}
+
+	// create the job first so that a concurrent delivery of the same event is detected before
+	// a build number is allocated
+	appliedJob, err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).Create(request)
+	if err != nil {
+		if kubeerrors.IsAlreadyExists(err) {
+			// a concurrent delivery of the same event won the race
+			l.Info("an equivalent LighthouseJob was created concurrently, not starting a duplicate pipeline")
+			return b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).Get(request.Name, metav1.GetOptions{})
+		}
+		return nil, errors.Wrap(err, "unable to apply LighthouseJob")
+	}
+
+	build, err := b.buildNumbers.next(appliedJob)
+	if err != nil {
+		b.deleteJob(appliedJob, l)
+		return nil, err
+	}
+	if appliedJob.Labels == nil {
+		appliedJob.Labels = map[string]string{}
+	}
+	appliedJob.Labels[util.BuildNumLabel] = build
+	updatedJob, err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).Update(appliedJob)
+	if err != nil {
+		b.deleteJob(appliedJob, l)
+		return nil, errors.Wrapf(err, "unable to set the build number of LighthouseJob %s", appliedJob.Name)
+	}
+	appliedJob = updatedJob
+
+	run := &pipelinev1alpha1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      appliedJob.Name,
+			Namespace: b.namespace,
+			Labels: map[string]string{
+				util.ActivityOwnerLabel:      repository.Namespace,
+				util.ActivityRepositoryLabel: repository.Name,
+				util.ActivityBranchLabel:     appliedJob.Labels[util.BranchLabel],
+				util.ActivityBuildLabel:      build,
+				util.ActivityContextLabel:    appliedJob.Labels[util.ContextLabel],
+			},
+		},
+		Spec: pipelinev1alpha1.PipelineRunSpec{
+			PipelineRef:        &pipelinev1alpha1.PipelineRef{Name: pipelineName},
+			Params:             pipelineParams(spec, repository, build),
+			ServiceAccountName: sa,
+		},
+	}
+	l.WithField("Pipeline", pipelineName).Info("about to create the Tekton PipelineRun")
+	if _, err = b.tektonClient.TektonV1alpha1().PipelineRuns(b.namespace).Create(run); err != nil {
+		// remove the job so that the event can be retried
+		b.deleteJob(appliedJob, l)
+		return nil, errors.Wrapf(err, "unable to create the PipelineRun %s", run.Name)
+	}
+
+	appliedJob.Status = v1alpha1.LighthouseJobStatus{
+		State:        v1alpha1.PendingState,
+		ActivityName: run.Name,
+		StartTime:    metav1.Now(),
+	}
+	fullyCreatedJob, err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).UpdateStatus(appliedJob)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to set status on LighthouseJob %s", appliedJob.Name)
+	}
+	return fullyCreatedJob, nil
+}
+
+// deleteJob deletes the job whose pipeline could not be started
+func (b *launcher) deleteJob(job *v1alpha1.LighthouseJob, l *logrus.Entry) {
+	if err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).Delete(job.Name, &metav1.DeleteOptions{}); err != nil {
+		l.WithError(err).Warnf("failed to delete LighthouseJob %s", job.Name)
+	}
+}
+
+// findExistingJob returns the LighthouseJob with the same idempotency key as the request, if any
+func (b *launcher) findExistingJob(request *v1alpha1.LighthouseJob) (*v1alpha1.LighthouseJob, error) {
+	key := request.Labels[util.IdempotencyKeyLabel]
+	if key == "" {
+		return nil, nil
+	}
+	list, err := b.lhClient.LighthouseV1alpha1().LighthouseJobs(b.namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", util.IdempotencyKeyLabel, key),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list LighthouseJobs with idempotency key %s", key)
+	}
+	if len(list.Items) == 0 {
+		return nil, nil
+	}
+	return &list.Items[0], nil
+}
+
+// jobAnnotations returns the annotations of the launched job, which are copied from the job it was created
+// for, whether it is configured centrally or in the .lighthouse directory of the repository. The annotations
+// of the job in the central configuration of the repository are used for those the launched job does not set.
+func (b *launcher) jobAnnotations(request *v1alpha1.LighthouseJob, repository scm.Repository) map[string]string {
+	annotations := map[string]string{}
+	for k, v := range b.configuredAnnotations(&request.Spec, repository) {
+		annotations[k] = v
+	}
+	for k, v := range request.Annotations {
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// configuredAnnotations returns the annotations of the job in the central configuration of the repository
+func (b *launcher) configuredAnnotations(spec *v1alpha1.LighthouseJobSpec, repository scm.Repository) map[string]string {
+	var cfg *config.Config
+	if b.config != nil {
+		cfg = b.config()
+	}
+	if cfg == nil {
+		return nil
+	}
+	if repository.FullName == "" {
+		repository.FullName = scm.Join(repository.Namespace, repository.Name)
+	}
+	switch spec.Type {
+	case config.PresubmitJob, config.BatchJob:
+		for _, job := range jobutil.Presubmits(cfg, repository) {
+			if job.Name == spec.Job {
+				return job.Annotations
+			}
+		}
+	case config.PostsubmitJob:
+		for _, job := range jobutil.Postsubmits(cfg, repository) {
+			if job.Name == spec.Job {
+				return job.Annotations
+			}
+		}
+	case config.PeriodicJob:
+		for _, job := range cfg.AllPeriodics() {
+			if job.Name == spec.Job {
+				return job.Annotations
+			}
+		}
+	}
+	return nil
+}
+
+// pipelineParams returns the parameters of the PipelineRun, which are the environment variables of the
+// job. Tekton ignores the parameters the Pipeline does not declare, so the Pipeline is resolved by
+// Tekton when it runs rather than when the job is launched.
+func pipelineParams(spec *v1alpha1.LighthouseJobSpec, repository scm.Repository, build string) []pipelinev1alpha1.Param {
+	env := spec.GetEnvVars()
+	env[RepoURLParam] = repository.Clone
+	env[BuildIDParam] = build
+
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var params []pipelinev1alpha1.Param
+	for _, name := range names {
+		params = append(params, pipelinev1alpha1.Param{
+			Name:  name,
+			Value: pipelinev1beta1.NewArrayOrString(env[name]),
+		})
+	}
+	return params
+}
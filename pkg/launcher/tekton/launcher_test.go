@@ -0,0 +1,152 @@
+package tekton
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	lhfake "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+const ns = "jx"
+
+func job(name, jobName, idempotencyKey string, build string) *v1alpha1.LighthouseJob {
+	j := &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels: map[string]string{
+				util.OrgLabel:     "org",
+				util.RepoLabel:    "repo",
+				util.BranchLabel:  "PR-1",
+				util.ContextLabel: "unit",
+			},
+		},
+		Spec: v1alpha1.LighthouseJobSpec{
+			Type:    config.PresubmitJob,
+			Job:     jobName,
+			Context: "unit",
+			Refs: &v1alpha1.Refs{
+				Org:     "org",
+				Repo:    "repo",
+				BaseRef: "master",
+				BaseSHA: "base",
+				Pulls:   []v1alpha1.Pull{{Number: 1, SHA: "head"}},
+			},
+		},
+	}
+	if idempotencyKey != "" {
+		j.Labels[util.IdempotencyKeyLabel] = idempotencyKey
+	}
+	if build != "" {
+		j.Labels[util.BuildNumLabel] = build
+	}
+	return j
+}
+
+func TestLaunch(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Presubmits = map[string][]config.Presubmit{
+		"org/repo": {{JobBase: config.JobBase{
+			Name: "unit",
+			Annotations: map[string]string{
+				PipelineRefAnnotation:    "shared-unit",
+				ServiceAccountAnnotation: "builder",
+			},
+		}}},
+	}
+	tektonClient := tektonfake.NewSimpleClientset()
+	kubeClient := kubefake.NewSimpleClientset()
+	lhClient := lhfake.NewSimpleClientset(job("previous", "unit", "", "3"))
+	repository := scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo", Clone: "https://github.com/org/repo.git"}
+	l := NewLauncher(tektonClient, kubeClient, lhClient, ns, func() *config.Config {
+		return cfg
+	})
+
+	launched, err := l.Launch(job("unit-job", "unit", "key", ""), repository)
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.PendingState, launched.Status.State)
+	assert.Equal(t, "unit-job", launched.Status.ActivityName)
+	assert.Equal(t, "4", launched.Labels[util.BuildNumLabel], "the build numbers continue from the existing jobs")
+
+	run, err := tektonClient.TektonV1alpha1().PipelineRuns(ns).Get("unit-job", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "shared-unit", run.Spec.PipelineRef.Name)
+	assert.Equal(t, "builder", run.Spec.ServiceAccountName)
+	assert.Equal(t, map[string]string{
+		util.ActivityOwnerLabel:      "org",
+		util.ActivityRepositoryLabel: "repo",
+		util.ActivityBranchLabel:     "PR-1",
+		util.ActivityBuildLabel:      "4",
+		util.ActivityContextLabel:    "unit",
+	}, run.Labels)
+	params := map[string]string{}
+	for _, p := range run.Spec.Params {
+		params[p.Name] = p.Value.StringVal
+	}
+	assert.Equal(t, "4", params[BuildIDParam])
+	assert.Equal(t, "1", params[v1alpha1.PullNumberEnv])
+	assert.Equal(t, "https://github.com/org/repo.git", params[RepoURLParam])
+
+	again, err := l.Launch(job("unit-job-again", "unit", "key", ""), repository)
+	require.NoError(t, err)
+	assert.Equal(t, "unit-job", again.Name, "the job with the same idempotency key is reused")
+
+	lint := job("lint-job", "lint", "", "")
+	lint.Spec.Type = config.PostsubmitJob
+	launched, err = l.Launch(lint, repository)
+	require.NoError(t, err)
+	assert.Equal(t, "5", launched.Labels[util.BuildNumLabel])
+	run, err = tektonClient.TektonV1alpha1().PipelineRuns(ns).Get("lint-job", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "lint", run.Spec.PipelineRef.Name, "the pipeline defaults to the job name and need not exist yet")
+	assert.Equal(t, "tekton-bot", run.Spec.ServiceAccountName)
+
+	// the jobs of the .lighthouse directory of the repository are not in the central configuration
+	inRepo := job("in-repo-job", "in-repo", "", "")
+	inRepo.Annotations = map[string]string{PipelineRefAnnotation: "in-repo-pipeline"}
+	_, err = l.Launch(inRepo, repository)
+	require.NoError(t, err)
+	run, err = tektonClient.TektonV1alpha1().PipelineRuns(ns).Get("in-repo-job", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "in-repo-pipeline", run.Spec.PipelineRef.Name, "the annotations of the launched job are used")
+
+	cm, err := kubeClient.CoreV1().ConfigMaps(ns).Get(BuildNumbersConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"org.repo.PR-1": "6"}, cm.Data)
+}
+
+func TestLaunchFailure(t *testing.T) {
+	tektonClient := tektonfake.NewSimpleClientset()
+	tektonClient.PrependReactor("create", "pipelineruns", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("admission denied")
+	})
+	lhClient := lhfake.NewSimpleClientset()
+	l := NewLauncher(tektonClient, kubefake.NewSimpleClientset(), lhClient, ns, nil)
+
+	_, err := l.Launch(job("unit-job", "unit", "key", ""), scm.Repository{Namespace: "org", Name: "repo"})
+	require.Error(t, err)
+	_, err = lhClient.LighthouseV1alpha1().LighthouseJobs(ns).Get("unit-job", metav1.GetOptions{})
+	assert.True(t, kubeerrors.IsNotFound(err), "the job is deleted so that the event can be retried")
+
+	lhClient = lhfake.NewSimpleClientset()
+	lhClient.PrependReactor("update", "lighthousejobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("conflict")
+	})
+	l = NewLauncher(tektonfake.NewSimpleClientset(), kubefake.NewSimpleClientset(), lhClient, ns, nil)
+	_, err = l.Launch(job("unit-job", "unit", "key", ""), scm.Repository{Namespace: "org", Name: "repo"})
+	require.Error(t, err)
+	_, err = lhClient.LighthouseV1alpha1().LighthouseJobs(ns).Get("unit-job", metav1.GetOptions{})
+	assert.True(t, kubeerrors.IsNotFound(err), "the job is deleted when its build number cannot be set")
+}
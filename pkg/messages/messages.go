@@ -0,0 +1,128 @@
+// Package messages contains the catalog of bot-facing messages which can be
+// overridden per installation, e.g. to translate or rebrand the comments and
+// statuses posted by lighthouse.
+package messages
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// PathEnvVar is the environment variable containing the path of the message
+// catalog file, typically mounted from the lighthouse-messages ConfigMap.
+const PathEnvVar = "LIGHTHOUSE_MESSAGES_PATH"
+
+// Catalog contains overrides of message templates keyed by message ID. The
+// catalog file is a YAML map of message ID to Go template and is reloaded when
+// it changes, so ConfigMap updates are picked up without a restart.
+type Catalog struct {
+	path     string
+	modTime  time.Time
+	messages map[string]string
+	sync.Mutex
+}
+
+// NewCatalog creates a Catalog reading overrides from the given file. An empty
+// path or a missing file means no message is overridden.
+func NewCatalog(path string) *Catalog {
+	return &Catalog{path: path}
+}
+
+var defaultCatalog = NewCatalog(os.Getenv(PathEnvVar))
+
+// Template returns the template of the given message from the default catalog,
+// or defaultText if the message is not overridden.
+func Template(id, defaultText string) string {
+	return defaultCatalog.Template(id, defaultText)
+}
+
+// Render renders the given message from the default catalog with the data.
+func Render(id, defaultText string, data interface{}) string {
+	return defaultCatalog.Render(id, defaultText, data)
+}
+
+// Template returns the template of the given message, or defaultText if the
+// message is not overridden.
+func (c *Catalog) Template(id, defaultText string) string {
+	if text, ok := c.load()[id]; ok {
+		return text
+	}
+	return defaultText
+}
+
+// Render renders the given message with the data. If an override fails to render
+// the default text is used instead, so a broken catalog never stops the bot.
+func (c *Catalog) Render(id, defaultText string, data interface{}) string {
+	text := c.Template(id, defaultText)
+	msg, err := render(id, text, data)
+	if err == nil {
+		return msg
+	}
+	if text != defaultText {
+		logrus.WithError(err).WithField("message", id).Warn("Failed to render overridden message, using the default.")
+		if msg, err = render(id, defaultText, data); err == nil {
+			return msg
+		}
+	}
+	logrus.WithError(err).WithField("message", id).Error("Failed to render message.")
+	return defaultText
+}
+
+// load returns the overrides, reloading the catalog file if it has changed
+func (c *Catalog) load() map[string]string {
+	if c.path == "" {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	info, err := os.Stat(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.WithError(err).Warnf("Failed to check message catalog %s.", c.path)
+		}
+		return c.messages
+	}
+	if c.messages != nil && info.ModTime().Equal(c.modTime) {
+		return c.messages
+	}
+	messages, err := readCatalog(c.path)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to load message catalog %s, keeping the previous messages.", c.path)
+		return c.messages
+	}
+	c.messages = messages
+	c.modTime = info.ModTime()
+	return c.messages
+}
+
+func readCatalog(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	messages := map[string]string{}
+	if err := yaml.Unmarshal(data, &messages); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	return messages, nil
+}
+
+func render(id, text string, data interface{}) (string, error) {
+	tmpl, err := template.New(id).Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse template of message %s", id)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "failed to render message %s", id)
+	}
+	return buf.String(), nil
+}
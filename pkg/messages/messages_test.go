@@ -0,0 +1,34 @@
+package messages
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "messages")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "messages.yaml")
+
+	catalog := NewCatalog(path)
+	data := map[string]string{"Name": "world"}
+	assert.Equal(t, "Hello world", catalog.Render("greeting", "Hello {{ .Name }}", data), "missing file uses defaults")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("greeting: 'Hallo {{ .Name }}'\nbroken: '{{ .Missing'\n"), 0600))
+	assert.Equal(t, "Hallo world", catalog.Render("greeting", "Hello {{ .Name }}", data))
+	assert.Equal(t, "Bye world", catalog.Render("farewell", "Bye {{ .Name }}", data))
+	assert.Equal(t, "Broken world", catalog.Render("broken", "Broken {{ .Name }}", data), "invalid overrides fall back to the default")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("greeting: 'Bonjour {{ .Name }}'\n"), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	assert.Equal(t, "Bonjour world", catalog.Render("greeting", "Hello {{ .Name }}", data), "changes are reloaded")
+	assert.Equal(t, "Hello {{ .Name }}", NewCatalog("").Template("greeting", "Hello {{ .Name }}"))
+}
@@ -0,0 +1,122 @@
+package notifier
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/messages"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SMTPUsernameEnvVar is the environment variable containing the username the emails are sent with, if
+	// the SMTP server requires authentication
+	SMTPUsernameEnvVar = "LIGHTHOUSE_SMTP_USERNAME"
+	// SMTPPasswordEnvVar is the environment variable containing the password of the SMTP username
+	SMTPPasswordEnvVar = "LIGHTHOUSE_SMTP_PASSWORD" // #nosec
+
+	// the message catalog ID of the email body
+	postsubmitFailuresMessage = "notifier.postsubmitFailures"
+
+	defaultPostsubmitFailuresText = `The postsubmit job {{ .Job }} of {{ .Org }}/{{ .Repo }} failed {{ .Count }} times in a row on the {{ .Branch }} branch.
+
+The last failure was on commit {{ .SHA }}{{ if .Description }}: {{ .Description }}{{ end }}
+{{- if .URL }}
+
+{{ .URL }}
+{{- end }}
+`
+)
+
+// PostsubmitFailures is the notification of the consecutive failures of a postsubmit job on a branch
+type PostsubmitFailures struct {
+	JobFailure
+	// Count is the number of consecutive failures
+	Count int
+}
+
+// Emailer emails the owners of the repositories configured in the email notifications of the settings when
+// their postsubmits keep failing. A nil Emailer is disabled and sends nothing.
+type Emailer struct {
+	settings settings.Getter
+	username string
+	password string
+	send     func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	logger   *logrus.Entry
+}
+
+// NewEmailerFromEnv creates an Emailer authenticating with the credentials of the SMTPUsernameEnvVar and
+// SMTPPasswordEnvVar environment variables, if any. It returns nil if the settings are missing.
+func NewEmailerFromEnv(settings settings.Getter) *Emailer {
+	if settings == nil {
+		return nil
+	}
+	return &Emailer{
+		settings: settings,
+		username: os.Getenv(SMTPUsernameEnvVar),
+		password: os.Getenv(SMTPPasswordEnvVar),
+		send:     smtp.SendMail,
+		logger:   logrus.WithField("component", "emailer"),
+	}
+}
+
+// Threshold returns the number of consecutive failures of the postsubmits of the org/repo repository after
+// which its owners are emailed, or 0 if no email is sent for the repository
+func (e *Emailer) Threshold(org, repo string) int {
+	if e == nil {
+		return 0
+	}
+	email := e.settings().Notifications.Email
+	if email.SMTPServer == "" || len(email.Recipients(org, repo)) == 0 {
+		return 0
+	}
+	return email.Threshold()
+}
+
+// PostsubmitFailed emails the owners of the repository about the consecutive failures of the postsubmit.
+// The failures are only logged so that they never stop the reports.
+func (e *Emailer) PostsubmitFailed(failures PostsubmitFailures) {
+	if e == nil {
+		return
+	}
+	email := e.settings().Notifications.Email
+	to := email.Recipients(failures.Org, failures.Repo)
+	if email.SMTPServer == "" || len(to) == 0 {
+		return
+	}
+	subject := fmt.Sprintf("%s failed %d times in a row on %s/%s %s", failures.Job, failures.Count, failures.Org, failures.Repo, failures.Branch)
+	body := messages.Render(postsubmitFailuresMessage, defaultPostsubmitFailuresText, failures)
+	log := e.logger.WithFields(logrus.Fields{"org": failures.Org, "repo": failures.Repo, "branch": failures.Branch, "job": failures.Job})
+	if err := e.sendMail(email.SMTPServer, email.From, to, subject, body); err != nil {
+		log.WithError(err).Warn("Failed to email the postsubmit failures.")
+		return
+	}
+	log.WithField("to", to).Info("Emailed the postsubmit failures.")
+}
+
+// sendMail sends the email through the SMTP server, authenticating if a username is set
+func (e *Emailer) sendMail(server, from string, to []string, subject, body string) error {
+	var auth smtp.Auth
+	if e.username != "" {
+		host, _, err := net.SplitHostPort(server)
+		if err != nil {
+			return errors.Wrapf(err, "invalid SMTP server %s", server)
+		}
+		auth = smtp.PlainAuth("", e.username, e.password, host)
+	}
+	msg := strings.Join([]string{
+		"From: " + from,
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		strings.ReplaceAll(body, "\n", "\r\n"),
+	}, "\r\n")
+	return errors.Wrap(e.send(server, auth, from, to, []byte(msg)), "failed to send the email")
+}
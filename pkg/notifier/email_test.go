@@ -0,0 +1,54 @@
+package notifier
+
+import (
+	"net/smtp"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailer(t *testing.T) {
+	var disabled *Emailer
+	assert.Nil(t, NewEmailerFromEnv(nil))
+	assert.Equal(t, 0, disabled.Threshold("org", "repo"))
+	disabled.PostsubmitFailed(PostsubmitFailures{})
+
+	s := &settings.Config{}
+	e := NewEmailerFromEnv(func() *settings.Config { return s })
+	assert.Equal(t, 0, e.Threshold("org", "repo"), "no email is sent without an SMTP server")
+
+	s.Notifications.Email = settings.EmailNotifications{
+		SMTPServer:          "smtp.example.com:587",
+		From:                "lighthouse@example.com",
+		ConsecutiveFailures: 2,
+		Owners:              map[string][]string{"org/repo": {"dev@example.com"}},
+	}
+	assert.Equal(t, 2, e.Threshold("org", "repo"))
+	assert.Equal(t, 0, e.Threshold("org", "other"), "no email is sent for the repositories without owners")
+
+	var sent []string
+	var auth smtp.Auth
+	e.username, e.password = "bot", "secret"
+	e.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.com:587", addr)
+		assert.Equal(t, "lighthouse@example.com", from)
+		assert.Equal(t, []string{"dev@example.com"}, to)
+		auth = a
+		sent = append(sent, string(msg))
+		return nil
+	}
+	e.PostsubmitFailed(PostsubmitFailures{
+		JobFailure: JobFailure{Org: "org", Repo: "repo", Branch: "master", Job: "release", SHA: "abc", Description: "Pipeline failed", URL: "https://dashboard/logs"},
+		Count:      2,
+	})
+	require.Len(t, sent, 1)
+	assert.NotNil(t, auth)
+	assert.Contains(t, sent[0], "Subject: release failed 2 times in a row on org/repo master\r\n")
+	assert.Contains(t, sent[0], "The postsubmit job release of org/repo failed 2 times in a row on the master branch.\r\n\r\nThe last failure was on commit abc: Pipeline failed\r\n\r\nhttps://dashboard/logs")
+
+	e.send = func(string, smtp.Auth, string, []string, []byte) error { return errors.New("connection refused") }
+	e.PostsubmitFailed(PostsubmitFailures{JobFailure: JobFailure{Org: "org", Repo: "repo"}, Count: 2})
+}
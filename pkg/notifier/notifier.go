@@ -0,0 +1,207 @@
+// Package notifier posts notifications of the job failures reported by foghorn, and of the merges and
+// stuck pools of keeper, to the Slack channels routed in the notifications section of the lighthouse
+// settings, and emails the owners of the repositories whose postsubmits keep failing, so that teams
+// learn about them without watching the PRs.
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/messages"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SlackTokenEnvVar is the environment variable containing the token of the Slack bot posting the
+	// notifications, which needs the chat:write scope
+	SlackTokenEnvVar = "LIGHTHOUSE_SLACK_TOKEN" // #nosec
+
+	// SlackAPIURL is the URL of the Slack Web API
+	SlackAPIURL = "https://slack.com/api/"
+
+	// the message catalog IDs of the notifications
+	jobFailedMessage = "notifier.jobFailed"
+	mergedMessage    = "notifier.merged"
+	stuckPoolMessage = "notifier.stuckPool"
+
+	defaultJobFailedText = `:x: {{ .Job }} failed on {{ .Org }}/{{ .Repo }}
+{{- if .Number }}#{{ .Number }}{{ if .Title }} ({{ .Title }}){{ end }}{{ else if .Branch }} {{ .Branch }}{{ end }}
+{{- if .Author }} by {{ .Author }}{{ end }}
+{{- if .Description }}: {{ .Description }}{{ end }}
+{{- if .URL }}
+{{ .URL }}
+{{- end }}`
+	defaultMergedText = `:white_check_mark: {{ .Org }}/{{ .Repo }}#{{ .Number }} ({{ .Title }}) by {{ .Author }} was merged into {{ .Branch }}
+{{- if .URL }}
+{{ .URL }}
+{{- end }}`
+	defaultStuckPoolText = `:warning: keeper has not merged the PRs of {{ .Org }}/{{ .Repo }} {{ .Branch }}:
+{{- range .PRs }}
+- #{{ .Number }} ({{ .Title }}) has been {{ .State }} for {{ .Duration }}{{ if .URL }} {{ .URL }}{{ end }}
+{{- range .Blocking }}
+  - {{ . }}
+{{- end }}
+{{- end }}`
+)
+
+// JobFailure is the notification of a failed job
+type JobFailure struct {
+	Org    string
+	Repo   string
+	Branch string
+	// Job is the name of the job, or its context
+	Job string
+	// Number is the number of the PR the job ran for, if any
+	Number int
+	Title  string
+	Author string
+	SHA    string
+	// Description is the description of the status reported for the job
+	Description string
+	// URL is the URL of the logs of the job, if any
+	URL string
+}
+
+// Merge is the notification of a PR merged by keeper
+type Merge struct {
+	Org    string
+	Repo   string
+	Branch string
+	Number int
+	Title  string
+	Author string
+	SHA    string
+	URL    string
+}
+
+// StuckPool is the notification of the PRs of a pool which keeper has not merged for too long
+type StuckPool struct {
+	Org    string
+	Repo   string
+	Branch string
+	PRs    []StuckPR
+}
+
+// StuckPR is a PR of a StuckPool
+type StuckPR struct {
+	Number   int
+	Title    string
+	URL      string
+	State    string
+	Duration string
+	// Blocking describes what keeper is waiting for before it merges the PR
+	Blocking []string
+}
+
+// Notifier posts the notifications to the Slack channels of the routes of the settings. A nil Notifier
+// is disabled and posts nothing.
+type Notifier struct {
+	token    string
+	apiURL   string
+	settings settings.Getter
+	client   *http.Client
+	logger   *logrus.Entry
+}
+
+// NewFromEnv creates a Notifier posting with the token of the SlackTokenEnvVar environment variable, or
+// returns nil if it is not set
+func NewFromEnv(settings settings.Getter) *Notifier {
+	return New(strings.TrimSpace(os.Getenv(SlackTokenEnvVar)), SlackAPIURL, settings)
+}
+
+// New creates a Notifier posting to the Slack API with the token. It returns nil if the token or the
+// settings are missing, which disables the notifications.
+func New(token, apiURL string, settings settings.Getter) *Notifier {
+	if token == "" || settings == nil {
+		return nil
+	}
+	return &Notifier{
+		token:    token,
+		apiURL:   strings.TrimSuffix(apiURL, "/") + "/",
+		settings: settings,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logrus.WithField("component", "notifier"),
+	}
+}
+
+// JobFailed notifies the failure of a job
+func (n *Notifier) JobFailed(failure JobFailure) {
+	if n == nil {
+		return
+	}
+	n.notify(settings.NotifyJobFailed, failure.Org, failure.Repo, messages.Render(jobFailedMessage, defaultJobFailedText, failure))
+}
+
+// Merged notifies the merge of a PR
+func (n *Notifier) Merged(merge Merge) {
+	if n == nil {
+		return
+	}
+	n.notify(settings.NotifyMerged, merge.Org, merge.Repo, messages.Render(mergedMessage, defaultMergedText, merge))
+}
+
+// Stuck notifies the PRs of a pool which keeper has not merged for too long
+func (n *Notifier) Stuck(pool StuckPool) {
+	if n == nil || len(pool.PRs) == 0 {
+		return
+	}
+	n.notify(settings.NotifyStuckPool, pool.Org, pool.Repo, messages.Render(stuckPoolMessage, defaultStuckPoolText, pool))
+}
+
+// notify posts the text to the channels the event of the repository is routed to. The failures are
+// only logged so that they never stop the reports and merges.
+func (n *Notifier) notify(event, org, repo, text string) {
+	notifications := n.settings().Notifications
+	for _, channel := range notifications.SlackChannels(org, repo, event) {
+		log := n.logger.WithFields(logrus.Fields{"event": event, "org": org, "repo": repo, "channel": channel})
+		if err := n.post(channel, text); err != nil {
+			log.WithError(err).Warn("Failed to post the notification to Slack.")
+			continue
+		}
+		log.Debug("Posted the notification to Slack.")
+	}
+}
+
+// slackResponse is the part of the responses of the Slack API telling whether the call succeeded
+type slackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// post posts the text to the channel with the chat.postMessage method of the Slack API
+func (n *Notifier) post(channel, text string) error {
+	data, err := json.Marshal(map[string]string{"channel": channel, "text": text})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the message")
+	}
+	req, err := http.NewRequest(http.MethodPost, n.apiURL+"chat.postMessage", bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to create the request")
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+n.token)
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to post the message")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("slack returned status %d", resp.StatusCode)
+	}
+	// slack returns errors such as an unknown channel with a 200 status
+	result := slackResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return errors.Wrap(err, "failed to parse the slack response")
+	}
+	if !result.OK {
+		return errors.Errorf("slack returned error %s", result.Error)
+	}
+	return nil
+}
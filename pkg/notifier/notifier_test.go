@@ -0,0 +1,54 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifier(t *testing.T) {
+	var disabled *Notifier
+	assert.Nil(t, New("", SlackAPIURL, func() *settings.Config { return &settings.Config{} }))
+	disabled.JobFailed(JobFailure{Org: "org", Repo: "repo"})
+
+	var posted []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat.postMessage", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		message := map[string]string{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		if message["channel"] == "#unknown" {
+			_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+			return
+		}
+		posted = append(posted, message)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	s := &settings.Config{Notifications: settings.Notifications{Slack: []settings.SlackRoute{
+		{Channel: "#unknown"},
+		{Channel: "#failures", Events: []string{settings.NotifyJobFailed}},
+		{Channel: "#repo", Repos: []string{"org/repo"}, Events: []string{settings.NotifyMerged, settings.NotifyStuckPool}},
+	}}}
+	n := New("token", server.URL, func() *settings.Config { return s })
+
+	n.JobFailed(JobFailure{Org: "org", Repo: "repo", Job: "unit-tests", Number: 12, Title: "Fix the build", Author: "dev", Description: "Pipeline failed", URL: "https://dashboard/logs"})
+	n.Merged(Merge{Org: "org", Repo: "repo", Branch: "master", Number: 12, Title: "Fix the build", Author: "dev"})
+	n.Merged(Merge{Org: "org", Repo: "other", Branch: "master", Number: 3, Title: "Other", Author: "dev"})
+	n.Stuck(StuckPool{Org: "org", Repo: "repo", Branch: "master"})
+	n.Stuck(StuckPool{Org: "org", Repo: "repo", Branch: "master", PRs: []StuckPR{
+		{Number: 12, Title: "Fix the build", State: "pending", Duration: "2h0m0s", Blocking: []string{"context ci/e2e is pending"}},
+	}})
+
+	assert.Equal(t, []map[string]string{
+		{"channel": "#failures", "text": ":x: unit-tests failed on org/repo#12 (Fix the build) by dev: Pipeline failed\nhttps://dashboard/logs"},
+		{"channel": "#repo", "text": ":white_check_mark: org/repo#12 (Fix the build) by dev was merged into master"},
+		{"channel": "#repo", "text": ":warning: keeper has not merged the PRs of org/repo master:\n- #12 (Fix the build) has been pending for 2h0m0s\n  - context ci/e2e is pending"},
+	}, posted)
+}
@@ -0,0 +1,66 @@
+// Package pathmatch matches the paths of the files changed by pull requests against the path patterns
+// of the configuration, such as the path labels and the context scopes.
+package pathmatch
+
+import (
+	"path"
+	"strings"
+)
+
+const zeroToManyDirs = "**"
+
+// Match returns true if the file matches the pattern. Patterns are patterns as supported by path.Match
+// in which a `**` segment matches any number of directories, e.g. `charts/**` or `pkg/**/types.go`.
+// Patterns without a slash, e.g. `*.md`, match the name of the files at any depth.
+func Match(pattern, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		pattern = zeroToManyDirs + "/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+// MatchAny returns true if the file matches one of the patterns
+func MatchAny(patterns []string, file string) bool {
+	for _, p := range patterns {
+		if Match(p, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error if the pattern is malformed
+func Validate(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchSegments matches the segments of a file against the segments of a pattern. A `**` segment
+// matches any number of directories, or at least one file or directory when it is the last one.
+func matchSegments(pattern, file []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == zeroToManyDirs {
+			if len(pattern) == 1 {
+				return len(file) > 0
+			}
+			for i := 0; i < len(file); i++ {
+				if matchSegments(pattern[1:], file[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(file) == 0 {
+			return false
+		}
+		if match, _ := path.Match(pattern[0], file[0]); !match {
+			return false
+		}
+		pattern, file = pattern[1:], file[1:]
+	}
+	return len(file) == 0
+}
@@ -0,0 +1,35 @@
+package pathmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		match   bool
+	}{
+		{pattern: "charts/**", file: "charts/lighthouse/values.yaml", match: true},
+		{pattern: "charts/**", file: "charts", match: false},
+		{pattern: "charts/**", file: "chartsfoo/values.yaml", match: false},
+		{pattern: "pkg/**/types.go", file: "pkg/apis/lighthouse/v1alpha1/types.go", match: true},
+		{pattern: "pkg/**/types.go", file: "pkg/types.go", match: true},
+		{pattern: "pkg/**/types.go", file: "pkg/types.go.orig", match: false},
+		{pattern: "*.md", file: "README.md", match: true},
+		{pattern: "*.md", file: "docs/guide/index.md", match: true},
+		{pattern: "docs/*.md", file: "docs/guide/index.md", match: false},
+		{pattern: "**", file: "main.go", match: true},
+		{pattern: "services/*/Dockerfile", file: "services/a/Dockerfile", match: true},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.match, Match(tc.pattern, tc.file), "%s should match %s: %t", tc.pattern, tc.file, tc.match)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("pkg/**/types.go"))
+	assert.Error(t, Validate("docs/[a/**"))
+}
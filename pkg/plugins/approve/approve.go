@@ -92,6 +92,8 @@ type state struct {
 	author    string
 	assignees []scm.User
 	htmlURL   string
+	// requirement is the approve requirement of the repository
+	requirement plugins.ApproveRequirement
 }
 
 func init() {
@@ -132,6 +134,7 @@ func helpProvider(config *plugins.Configuration, enabledRepos []string) (*plugin
 		Description: `The approve plugin implements a pull request approval process that manages the '` + labels.Approved + `' label and an approval notification comment. Approval is achieved when the set of users that have approved the PR is capable of approving every file changed by the PR. A user is able to approve a file if their username or an alias they belong to is listed in the 'approvers' section of an OWNERS file in the directory of the file or higher in the directory tree.
 <br>
 <br>Per-repo configuration may be used to require that PRs link to an associated issue before approval is granted. It may also be used to specify that the PR authors implicitly approve their own PRs.
+<br>The approve_requirements section of plugins.yaml may require several distinct approvers, optionally from different OWNERS files, before the PR is approved.
 <br>For more information see <a href="https://git.github.com/jenkins-x/lighthouse/pkg/prow/plugins/approve/approvers/README.md">here</a>.`,
 		Config: approveConfig,
 	}
@@ -158,12 +161,13 @@ func handleGenericCommentEvent(pc plugins.Agent, ce scmprovider.GenericCommentEv
 		pc.OwnersClient,
 		baseURL,
 		pc.PluginConfig,
+		pc.PluginSettings.ApproveRequirements,
 		&ce,
 	)
 }
 
-func handleGenericComment(log *logrus.Entry, spc scmProviderClient, oc ownersClient, serverURL *url.URL, config *plugins.Configuration, ce *scmprovider.GenericCommentEvent) error {
-	if ce.Action != scm.ActionCreate || !ce.IsPR || ce.IssueState == "closed" {
+func handleGenericComment(log *logrus.Entry, spc scmProviderClient, oc ownersClient, serverURL *url.URL, config *plugins.Configuration, requirements plugins.ApproveRequirements, ce *scmprovider.GenericCommentEvent) error {
+	if ce.Action != scm.ActionCreate || !ce.IsPR {
 		return nil
 	}
 
@@ -176,6 +180,9 @@ func handleGenericComment(log *logrus.Entry, spc scmProviderClient, oc ownersCli
 	if !isApprovalCommand(botName, opts.LgtmActsAsApprove, &comment{Body: ce.Body, Author: ce.Author.Login}) {
 		return nil
 	}
+	if ce.IssueState == "closed" {
+		return plugins.NewPreconditionError(plugins.SeverityWarning, "/approve", "the pull request must be open")
+	}
 
 	pr, err := spc.GetPullRequest(ce.Repo.Namespace, ce.Repo.Name, ce.Number)
 	if err != nil {
@@ -187,6 +194,16 @@ func handleGenericComment(log *logrus.Entry, spc scmProviderClient, oc ownersCli
 		return err
 	}
 
+	if isApproveCommand(ce.Body) {
+		changes, err := spc.GetPullRequestChanges(ce.Repo.Namespace, ce.Repo.Name, ce.Number)
+		if err != nil {
+			return err
+		}
+		if !canApproveAny(repo, changes, ce.Author.Login) {
+			return plugins.NewPreconditionError(plugins.SeverityError, "/approve", "only the approvers listed in the OWNERS files of the changed files can approve the pull request")
+		}
+	}
+
 	return handleFunc(
 		log,
 		spc,
@@ -202,6 +219,8 @@ func handleGenericComment(log *logrus.Entry, spc scmProviderClient, oc ownersCli
 			author:    ce.IssueAuthor.Login,
 			assignees: ce.Assignees,
 			htmlURL:   ce.IssueLink,
+
+			requirement: requirements.For(ce.Repo.Namespace, ce.Repo.Name),
 		},
 	)
 }
@@ -221,11 +240,12 @@ func handleReviewEvent(pc plugins.Agent, re scm.ReviewHook) error {
 		pc.OwnersClient,
 		baseURL,
 		pc.PluginConfig,
+		pc.PluginSettings.ApproveRequirements,
 		&re,
 	)
 }
 
-func handleReview(log *logrus.Entry, spc scmProviderClient, oc ownersClient, serverURL *url.URL, config *plugins.Configuration, re *scm.ReviewHook) error {
+func handleReview(log *logrus.Entry, spc scmProviderClient, oc ownersClient, serverURL *url.URL, config *plugins.Configuration, requirements plugins.ApproveRequirements, re *scm.ReviewHook) error {
 	if re.Action != scm.ActionSubmitted && re.Action != scm.ActionDismissed {
 		return nil
 	}
@@ -270,6 +290,8 @@ func handleReview(log *logrus.Entry, spc scmProviderClient, oc ownersClient, ser
 			author:    re.PullRequest.Author.Login,
 			assignees: re.PullRequest.Assignees,
 			htmlURL:   re.PullRequest.Link,
+
+			requirement: requirements.For(re.Repo.Namespace, re.Repo.Name),
 		},
 	)
 
@@ -288,11 +310,12 @@ func handlePullRequestEvent(pc plugins.Agent, pre scm.PullRequestHook) error {
 		pc.OwnersClient,
 		baseURL,
 		pc.PluginConfig,
+		pc.PluginSettings.ApproveRequirements,
 		&pre,
 	)
 }
 
-func handlePullRequest(log *logrus.Entry, spc scmProviderClient, oc ownersClient, serverURL *url.URL, config *plugins.Configuration, pre *scm.PullRequestHook) error {
+func handlePullRequest(log *logrus.Entry, spc scmProviderClient, oc ownersClient, serverURL *url.URL, config *plugins.Configuration, requirements plugins.ApproveRequirements, pre *scm.PullRequestHook) error {
 	if pre.Action != scm.ActionOpen &&
 		pre.Action != scm.ActionReopen &&
 		pre.Action != scm.ActionSync &&
@@ -329,6 +352,8 @@ func handlePullRequest(log *logrus.Entry, spc scmProviderClient, oc ownersClient
 			author:    pre.PullRequest.Author.Login,
 			assignees: pre.PullRequest.Assignees,
 			htmlURL:   pre.PullRequest.Link,
+
+			requirement: requirements.For(pre.Repo.Namespace, pre.Repo.Name),
 		},
 	)
 }
@@ -424,6 +449,8 @@ func handle(log *logrus.Entry, spc scmProviderClient, repo approvers.Repo, baseU
 		log.WithError(err).Errorf("Failed to find associated issue from PR body: %v", err)
 	}
 	approversHandler.RequireIssue = opts.IssueRequired
+	approversHandler.MinApprovers = pr.requirement.MinApprovers
+	approversHandler.DistinctOwners = pr.requirement.DistinctOwners
 	approversHandler.ManuallyApproved = humanAddedApproved(spc, log, pr.org, pr.repo, pr.number, botName, hasApprovedLabel)
 
 	// Author implicitly approves their own PR if config allows it
@@ -531,6 +558,32 @@ func isApprovalCommand(botName string, lgtmActsAsApprove bool, c *comment) bool
 	return false
 }
 
+// isApproveCommand returns true if the comment contains an /approve command which is not cancelled
+func isApproveCommand(body string) bool {
+	for _, match := range commandRegex.FindAllStringSubmatch(body, -1) {
+		args := strings.ToLower(strings.TrimSpace(match[2]))
+		if removeLighthouseCommandPrefix(match[1]) == approveCommand && !strings.Contains(args, cancelArgument) {
+			return true
+		}
+	}
+	return false
+}
+
+// canApproveAny returns true if the user is an approver of any of the changed files, or if there is no
+// changed file
+func canApproveAny(repo approvers.Repo, changes []*scm.Change, user string) bool {
+	if len(changes) == 0 {
+		return true
+	}
+	user = scmprovider.NormLogin(user)
+	for _, change := range changes {
+		if repo.Approvers(change.Path).Has(user) {
+			return true
+		}
+	}
+	return false
+}
+
 func removeLighthouseCommandPrefix(cmd string) string {
 	cmd = strings.ToUpper(cmd)
 	if strings.HasPrefix(cmd, strings.ToUpper(util.LighthouseCommandPrefix)) {
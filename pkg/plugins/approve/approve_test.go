@@ -1276,10 +1276,12 @@ Approvers can cancel approval by writing ` + "`/approve cancel`" + ` in a commen
 // TODO: cache approvers 'GetFilesApprovers' and 'GetCCs' since these are called repeatedly and are
 // expensive.
 
-type fakeOwnersClient struct{}
+type fakeOwnersClient struct {
+	approvers map[string]sets.String
+}
 
 func (foc fakeOwnersClient) LoadRepoOwners(org, repo, base string) (repoowners.RepoOwner, error) {
-	return fakeRepoOwners{}, nil
+	return fakeRepoOwners{fakeRepo{approvers: foc.approvers}}, nil
 }
 
 type fakeRepoOwners struct {
@@ -1314,6 +1316,8 @@ func TestHandleGenericComment(t *testing.T) {
 		expectHandle      bool
 		expectState       *state
 		labelComments     bool
+		changes           []string
+		expectFailure     plugins.Severity
 	}{
 		{
 			name: "valid approve command",
@@ -1380,7 +1384,51 @@ func TestHandleGenericComment(t *testing.T) {
 				},
 				IssueState: "closed",
 			},
-			expectHandle: false,
+			expectHandle:  false,
+			expectFailure: plugins.SeverityWarning,
+		},
+		{
+			name: "approve command of an approver",
+			commentEvent: scmprovider.GenericCommentEvent{
+				Action: scm.ActionCreate,
+				IsPR:   true,
+				Body:   "/approve",
+				Number: 1,
+				Author: scm.User{
+					Login: "Approver",
+				},
+			},
+			changes:      []string{"README.md", "pkg/file.go"},
+			expectHandle: true,
+		},
+		{
+			name: "approve command of a non approver",
+			commentEvent: scmprovider.GenericCommentEvent{
+				Action: scm.ActionCreate,
+				IsPR:   true,
+				Body:   "/approve",
+				Number: 1,
+				Author: scm.User{
+					Login: "author",
+				},
+			},
+			changes:       []string{"README.md", "pkg/file.go"},
+			expectHandle:  false,
+			expectFailure: plugins.SeverityError,
+		},
+		{
+			name: "approve cancel command of a non approver",
+			commentEvent: scmprovider.GenericCommentEvent{
+				Action: scm.ActionCreate,
+				IsPR:   true,
+				Body:   "/approve cancel",
+				Number: 1,
+				Author: scm.User{
+					Login: "author",
+				},
+			},
+			changes:      []string{"README.md"},
+			expectHandle: true,
 		},
 		{
 			name: "no approve command",
@@ -1486,6 +1534,9 @@ func TestHandleGenericComment(t *testing.T) {
 				fakeClient = scmprovider.ToTestClient(fakeScmClient)
 			}
 			fspc.PullRequests[1] = &pr
+			for _, path := range test.changes {
+				fspc.PullRequestChanges[1] = append(fspc.PullRequestChanges[1], &scm.Change{Path: path})
+			}
 
 			test.commentEvent.Repo = repo
 			config := &plugins.Configuration{}
@@ -1496,14 +1547,25 @@ func TestHandleGenericComment(t *testing.T) {
 			err := handleGenericComment(
 				logrus.WithField("plugin", "approve"),
 				fakeClient,
-				fakeOwnersClient{},
+				fakeOwnersClient{approvers: map[string]sets.String{"pkg/file.go": sets.NewString("approver")}},
 				&url.URL{
 					Scheme: "https",
 					Host:   "github.com",
 				},
 				config,
+				nil,
 				&test.commentEvent,
 			)
+			if test.expectFailure != "" {
+				failed, ok := plugins.AsPreconditionError(err)
+				if !ok {
+					t.Fatalf("%s: expected a failed precondition, got: %v", test.name, err)
+				}
+				if failed.Severity != test.expectFailure {
+					t.Errorf("%s: expected a failed precondition of severity %s, got %s", test.name, test.expectFailure, failed.Severity)
+				}
+				err = nil
+			}
 
 			if test.expectHandle && !handled {
 				t.Errorf("%s: expected call to handleFunc, but it wasn't called", test.name)
@@ -1720,6 +1782,7 @@ func TestHandleReview(t *testing.T) {
 				Host:   "github.com",
 			},
 			config,
+			nil,
 			&test.reviewEvent,
 		)
 
@@ -1861,6 +1924,7 @@ func TestHandlePullRequest(t *testing.T) {
 				Host:   "github.com",
 			},
 			&plugins.Configuration{},
+			nil,
 			&test.prEvent,
 		)
 
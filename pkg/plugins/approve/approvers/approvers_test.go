@@ -618,6 +618,80 @@ func TestIsApprovedWithIssue(t *testing.T) {
 	}
 }
 
+func TestIsApprovedWithMinApprovers(t *testing.T) {
+	FakeRepoMap := map[string]sets.String{
+		"":  sets.NewString("Alice"),
+		"a": sets.NewString("Art", "Anne"),
+		"b": sets.NewString("Bill"),
+	}
+	tests := []struct {
+		testName          string
+		filenames         []string
+		currentlyApproved sets.String
+		minApprovers      int
+		distinctOwners    bool
+		isApproved        bool
+	}{
+		{
+			testName:          "Enough Approvers",
+			filenames:         []string{"a/test.go", "b/test.go"},
+			currentlyApproved: sets.NewString("Anne", "Bill"),
+			minApprovers:      2,
+			isApproved:        true,
+		},
+		{
+			testName:          "Not Enough Approvers",
+			filenames:         []string{"a/test.go", "b/test.go"},
+			currentlyApproved: sets.NewString("Anne", "Bill"),
+			minApprovers:      3,
+			isApproved:        false,
+		},
+		{
+			testName:          "Approvers Of Other Files Are Not Counted",
+			filenames:         []string{"a/test.go"},
+			currentlyApproved: sets.NewString("Anne", "Bill"),
+			minApprovers:      2,
+			isApproved:        false,
+		},
+		{
+			testName:          "Same OWNERS File",
+			filenames:         []string{"a/test.go"},
+			currentlyApproved: sets.NewString("Anne", "Art"),
+			minApprovers:      2,
+			isApproved:        true,
+		},
+		{
+			testName:          "Same OWNERS File With Distinct Owners",
+			filenames:         []string{"a/test.go"},
+			currentlyApproved: sets.NewString("Anne", "Art"),
+			minApprovers:      2,
+			distinctOwners:    true,
+			isApproved:        false,
+		},
+		{
+			testName:          "Parent OWNERS File With Distinct Owners",
+			filenames:         []string{"a/test.go"},
+			currentlyApproved: sets.NewString("Anne", "Alice"),
+			minApprovers:      2,
+			distinctOwners:    true,
+			isApproved:        true,
+		},
+	}
+
+	for _, test := range tests {
+		testApprovers := NewApprovers(Owners{filenames: test.filenames, repo: createFakeRepo(FakeRepoMap), log: logrus.WithField("plugin", "some_plugin")})
+		testApprovers.MinApprovers = test.minApprovers
+		testApprovers.DistinctOwners = test.distinctOwners
+		for approver := range test.currentlyApproved {
+			testApprovers.AddApprover(approver, "REFERENCE", false)
+		}
+		calculated := testApprovers.IsApproved()
+		if test.isApproved != calculated {
+			t.Errorf("Failed for test %v.  Expected Approval Status: %v. Found %v", test.testName, test.isApproved, calculated)
+		}
+	}
+}
+
 func TestGetFilesApprovers(t *testing.T) {
 	tests := []struct {
 		testName       string
@@ -1108,3 +1182,42 @@ Approvers can cancel approval by writing ` + "`/approve cancel`" + ` in a commen
 		t.Errorf("GetMessage() = %+v, want = %+v", *got, want)
 	}
 }
+
+func TestGetMessageMinApprovers(t *testing.T) {
+	ap := NewApprovers(
+		Owners{
+			filenames: []string{"a/a.go"},
+			repo: createFakeRepo(map[string]sets.String{
+				"a": sets.NewString("Alice", "Anne"),
+			}),
+			log: logrus.WithField("plugin", "some_plugin"),
+		},
+	)
+	ap.MinApprovers = 2
+	ap.DistinctOwners = true
+	ap.AddApprover("Alice", "REFERENCE", false)
+
+	want := `[APPROVALNOTIFIER] This PR is **NOT APPROVED**
+
+This pull-request has been approved by: *[Alice](REFERENCE "Approved")*
+**2** approvers from different OWNERS files are required, **1** approved so far.
+
+The full list of commands accepted by this bot can be found [here](https://go.k8s.io/bot-commands?repo=org%2Frepo).
+
+The pull request process is described [here](https://git.k8s.io/community/contributors/guide/owners.md#the-code-review-process)
+
+<details >
+Needs approval from an approver in each of these files:
+
+- ~~[a/OWNERS](https://github.com/org/repo/blob/master/a/OWNERS)~~ [Alice]
+
+Approvers can indicate their approval by writing ` + "`/approve`" + ` in a comment
+Approvers can cancel approval by writing ` + "`/approve cancel`" + ` in a comment
+</details>
+<!-- META={"approvers":[]} -->`
+	if got := GetMessage(ap, &url.URL{Scheme: "https", Host: "github.com"}, "org", "repo", "master", false, "github"); got == nil {
+		t.Error("GetMessage() failed")
+	} else if *got != want {
+		t.Errorf("GetMessage() = %+v, want = %+v", *got, want)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/jenkins-x/lighthouse/pkg/messages"
 	"github.com/jenkins-x/lighthouse/pkg/util"
 	"github.com/sirupsen/logrus"
 
@@ -181,6 +182,29 @@ func (o Owners) GetOwnersSet() sets.String {
 	return owners
 }
 
+// closestOwnersFile returns the OWNERS file closest to the changes which lists the approver, or false
+// if none does
+func (o Owners) closestOwnersFile(login string) (string, bool) {
+	closest, found := "", false
+	for _, fn := range o.GetOwnersSet().List() {
+		for dir := fn; ; dir = filepath.Dir(dir) {
+			if dir == "." {
+				dir = ""
+			}
+			if IntersectSetsCase(sets.NewString(login), o.repo.LeafApprovers(dir)).Len() > 0 {
+				if !found || len(dir) > len(closest) {
+					closest, found = dir, true
+				}
+				break
+			}
+			if dir == "" {
+				break
+			}
+		}
+	}
+	return closest, found
+}
+
 // GetShuffledApprovers shuffles the potential approvers so that we don't
 // always suggest the same people.
 func (o Owners) GetShuffledApprovers() []string {
@@ -250,6 +274,11 @@ type Approvers struct {
 	assignees       sets.String
 	AssociatedIssue int
 	RequireIssue    bool
+	// MinApprovers is the number of distinct approvers of the changed files required, a single approver
+	// of each file suffices if it is below 2
+	MinApprovers int
+	// DistinctOwners only counts a single approver per OWNERS file towards MinApprovers
+	DistinctOwners bool
 
 	ManuallyApproved func() bool
 }
@@ -400,6 +429,33 @@ func (ap Approvers) GetFilesApprovers() map[string]sets.String {
 	return filesApprovers
 }
 
+// CountedApprovers returns the current approvers counted towards MinApprovers: the ones able to approve
+// some of the changed files, keeping a single one per OWNERS file if DistinctOwners is set
+func (ap Approvers) CountedApprovers() sets.String {
+	reverseMap := ap.owners.GetReverseMap(ap.owners.GetApprovers())
+	counted := sets.NewString()
+	ownersFiles := sets.NewString()
+	for _, login := range ap.GetCurrentApproversSet().List() {
+		if len(reverseMap[login]) == 0 {
+			continue
+		}
+		if ap.DistinctOwners {
+			ownersFile, ok := ap.owners.closestOwnersFile(login)
+			if !ok || ownersFiles.Has(ownersFile) {
+				continue
+			}
+			ownersFiles.Insert(ownersFile)
+		}
+		counted.Insert(login)
+	}
+	return counted
+}
+
+// HasMinApprovers returns true if enough distinct approvers approved the PR
+func (ap Approvers) HasMinApprovers() bool {
+	return ap.MinApprovers < 2 || ap.CountedApprovers().Len() >= ap.MinApprovers
+}
+
 // NoIssueApprovers returns the list of people who have "no-issue"
 // approved the pull-request. They are included in the list iff they can
 // approve one of the files.
@@ -503,12 +559,13 @@ func (ap Approvers) AreFilesApproved() bool {
 
 // RequirementsMet returns a bool indicating whether the PR has met all approval requirements:
 // - all OWNERS files associated with the PR have been approved AND
+// - at least MinApprovers distinct approvers approved it AND
 // EITHER
 // 	- the munger config is such that an issue is not required to be associated with the PR
 // 	- that there is an associated issue with the PR
 // 	- an OWNER has indicated that the PR is trivial enough that an issue need not be associated with the PR
 func (ap Approvers) RequirementsMet() bool {
-	return ap.AreFilesApproved() && (!ap.RequireIssue || ap.AssociatedIssue != 0 || len(ap.NoIssueApprovers()) != 0)
+	return ap.AreFilesApproved() && ap.HasMinApprovers() && (!ap.RequireIssue || ap.AssociatedIssue != 0 || len(ap.NoIssueApprovers()) != 0)
 }
 
 // IsApproved returns a bool indicating whether the PR is fully approved.
@@ -629,6 +686,13 @@ func (ap Approvers) GetQuotedCCs(providerType string) []string {
 	return users
 }
 
+const (
+	// ApprovalMessage is the ID of the approval notification body in the message catalog
+	ApprovalMessage = "approve.message"
+	// ApprovalTitleMessage is the ID of the approval notification title in the message catalog
+	ApprovalTitleMessage = "approve.title"
+)
+
 // GetMessage returns the comment body that we want the approve plugin to display on PRs
 // The comment shows:
 // 	- a list of approvers files (and links) needed to get the PR approved
@@ -644,12 +708,16 @@ func GetMessage(ap Approvers, linkURL *url.URL, org, repo, branch string, usePre
 	if usePrefix {
 		lhPrefix = util.LighthouseCommandPrefix
 	}
-	message, err := GenerateTemplate(`{{if (and (not .ap.RequirementsMet) (call .ap.ManuallyApproved )) }}
+	message, err := GenerateTemplate(messages.Template(ApprovalMessage, `{{if (and (not .ap.RequirementsMet) (call .ap.ManuallyApproved )) }}
 Approval requirements bypassed by manually added approval.
 
 {{end -}}
 This pull-request has been approved by:{{range $index, $approval := .ap.ListApprovals}}{{if $index}}, {{else}} {{end}}{{$approval}}{{end}}
 
+{{- if (and (not .ap.HasMinApprovers) (not (call .ap.ManuallyApproved))) }}
+**{{.ap.MinApprovers}}** approvers{{if .ap.DistinctOwners}} from different OWNERS files{{end}} are required, **{{.ap.CountedApprovers.Len}}** approved so far.
+{{- end}}
+
 {{- if (and (not .ap.AreFilesApproved) (not (call .ap.ManuallyApproved))) }}
 To complete the [pull request process](https://git.k8s.io/community/contributors/guide/owners.md#the-code-review-process), please assign {{range $index, $cc := .ap.GetCCs}}{{if $index}}, {{end}}**{{$cc}}**{{end}}
 You can assign the PR to them by writing `+"`/{{.lhPrefix}}assign {{range $index, $cc := .ap.GetQuotedCCs .providerType}}{{if $index}} {{end}}@{{$cc}}{{end}}`"+` in a comment when ready.
@@ -682,7 +750,7 @@ Needs approval from an approver in each of these files:
 {{range .ap.GetFiles .baseURL .org .repo .branch .providerType}}{{.}}{{end}}
 Approvers can indicate their approval by writing `+"`/{{.lhPrefix}}approve`"+` in a comment
 Approvers can cancel approval by writing `+"`/{{.lhPrefix}}approve cancel`"+` in a comment
-</details>`, "message", map[string]interface{}{"ap": ap, "baseURL": linkURL, "org": org, "repo": repo, "branch": branch, "lhPrefix": lhPrefix, "providerType": providerType})
+</details>`), "message", map[string]interface{}{"ap": ap, "baseURL": linkURL, "org": org, "repo": repo, "branch": branch, "lhPrefix": lhPrefix, "providerType": providerType})
 	if err != nil {
 		ap.owners.log.WithError(err).Errorf("Error generating message.")
 		return nil
@@ -690,7 +758,7 @@ Approvers can cancel approval by writing `+"`/{{.lhPrefix}}approve cancel`"+` in
 
 	message += getGubernatorMetadata(ap.GetCCs())
 
-	title, err := GenerateTemplate("This PR is **{{if not .IsApproved}}NOT {{end}}APPROVED**", "title", ap)
+	title, err := GenerateTemplate(messages.Template(ApprovalTitleMessage, "This PR is **{{if not .IsApproved}}NOT {{end}}APPROVED**"), "title", ap)
 	if err != nil {
 		ap.owners.log.WithError(err).Errorf("Error generating title.")
 		return nil
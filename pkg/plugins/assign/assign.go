@@ -19,11 +19,14 @@ package assign
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/identity"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/jenkins-x/lighthouse/pkg/pluginhelp"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
@@ -78,9 +81,13 @@ func handleGenericComment(pc plugins.Agent, e scmprovider.GenericCommentEvent) e
 	if e.Action != scm.ActionCreate {
 		return nil
 	}
-	err := handle(newAssignHandler(e, pc.SCMProviderClient, pc.Logger))
+	h := newAssignHandler(e, pc.SCMProviderClient, pc.Logger)
+	h.mapper = pc.IdentityMapper
+	err := handle(h)
 	if e.IsPR {
-		err = combineErrors(err, handle(newReviewHandler(e, pc.SCMProviderClient, pc.Logger)))
+		h = newReviewHandler(e, pc.SCMProviderClient, pc.Logger)
+		h.mapper = pc.IdentityMapper
+		err = combineErrors(err, handle(h))
 	}
 	return err
 }
@@ -139,6 +146,17 @@ func handle(h *handler) error {
 		}
 	}
 
+	if inactive := identity.Inactive(h.mapper, toAdd); len(inactive) > 0 {
+		sort.Strings(inactive)
+		toAdd = sets.NewString(toAdd...).Delete(inactive...).List()
+		h.log.Printf("Not adding the inactive identities as %s to %s/%s#%d: %v", h.userType, org, repo, e.Number, inactive)
+		msg := fmt.Sprintf("The following users are not active in the directory of the organization and cannot be added as %s: %s.", h.userType, strings.Join(inactive, ", "))
+		if err := h.spc.CreateComment(org, repo, e.Number, e.IsPR,
+			plugins.FormatResponseRaw(e.Body, e.Link, h.spc.QuoteAuthorForComment(e.Author.Login), msg)); err != nil {
+			return fmt.Errorf("comment err: %v", err)
+		}
+	}
+
 	if len(toRemove) > 0 {
 		h.log.Printf("Removing %s from %s/%s#%d: %v", h.userType, org, repo, e.Number, toRemove)
 		if err := h.remove(org, repo, e.Number, toRemove); err != nil {
@@ -182,6 +200,10 @@ type handler struct {
 	// spc is the scmProviderClient to use for creating response comments in the event of a failure.
 	spc scmProviderClient
 
+	// mapper maps the logins to the identities of the internal directory, only active identities are added.
+	// It is nil if no identity mapping is configured.
+	mapper identity.Mapper
+
 	// log is a logrus.Entry used to record actions the handler takes.
 	log *logrus.Entry
 	// userType is a string that represents the type of users affected by this handler. (e.g. 'assignees')
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/identity"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 	"github.com/sirupsen/logrus"
 )
@@ -473,3 +474,31 @@ func TestAssignAndReview(t *testing.T) {
 		})
 	}
 }
+
+func TestAssignInactiveIdentities(t *testing.T) {
+	mapper, err := identity.NewFileMapper(
+		identity.Identity{Login: "cjwagner", Active: true},
+		identity.Identity{Login: "merlin"},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating the identity mapper: %v", err)
+	}
+	fc := newFakeClient([]string{"cjwagner", "merlin"})
+	e := scmprovider.GenericCommentEvent{
+		Body:   "/assign @cjwagner @merlin",
+		Author: scm.User{Login: "rando"},
+		Repo:   scm.Repository{Name: "repo", Namespace: "org"},
+		Number: 5,
+	}
+	h := newAssignHandler(e, fc, logrus.WithField("plugin", pluginName))
+	h.mapper = mapper
+	if err := handle(h); err != nil {
+		t.Fatalf("Didn't expect error from handle: %v", err)
+	}
+	if len(fc.assigned) != 1 || fc.assigned["cjwagner"] != 1 {
+		t.Errorf("Expected only cjwagner to be assigned, got %v", fc.assigned)
+	}
+	if !fc.commented {
+		t.Error("Expected a comment about the inactive identities")
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/jenkins-x/lighthouse-config/pkg/labels"
+	"github.com/jenkins-x/lighthouse/pkg/messages"
 	"github.com/jenkins-x/lighthouse/pkg/pluginhelp"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
 	"github.com/jenkins-x/lighthouse/pkg/repoowners"
@@ -36,6 +37,13 @@ import (
 const (
 	// PluginName defines this plugin's registered name.
 	PluginName = labels.LGTM
+
+	// ownPRMessage is the ID of the reply to authors approving their own PR in the message catalog
+	ownPRMessage = "lgtm.own-pr"
+	// collaboratorsOnlyMessage is the ID of the reply to non collaborators in the message catalog
+	collaboratorsOnlyMessage = "lgtm.collaborators-only"
+	// reviewersOnlyMessage is the ID of the reply to users missing from the OWNERS files in the message catalog
+	reviewersOnlyMessage = "lgtm.reviewers-only"
 )
 
 var (
@@ -275,7 +283,7 @@ func handle(wantLGTM bool, config *plugins.Configuration, ownersClient repoowner
 	// Author cannot LGTM own PR, comment and abort
 	isAuthor := author == issueAuthor
 	if isAuthor && wantLGTM {
-		resp := "you cannot LGTM your own PR."
+		resp := messages.Render(ownPRMessage, "you cannot LGTM your own PR.", nil)
 		log.Infof("Commenting with \"%s\".", resp)
 		return spc.CreateComment(rc.repo.Namespace, rc.repo.Name, rc.number, true, plugins.FormatResponseRaw(rc.body, rc.htmlURL, spc.QuoteAuthorForComment(rc.author), resp))
 	}
@@ -301,7 +309,7 @@ func handle(wantLGTM bool, config *plugins.Configuration, ownersClient repoowner
 
 	// if commentor isn't a collaborator, and we care about collaborators, abort
 	if !isAuthor && !skipCollaborators && !isCollaborator {
-		resp := "changing LGTM is restricted to collaborators"
+		resp := messages.Render(collaboratorsOnlyMessage, "changing LGTM is restricted to collaborators", nil)
 		log.Infof("Reply to /lgtm request with comment: \"%s\"", resp)
 		return spc.CreateComment(org, repoName, number, true, plugins.FormatResponseRaw(body, htmlURL, spc.QuoteAuthorForComment(author), resp))
 	}
@@ -327,7 +335,7 @@ func handle(wantLGTM bool, config *plugins.Configuration, ownersClient repoowner
 			return err
 		}
 		if !loadReviewers(ro, filenames).Has(scmprovider.NormLogin(author)) {
-			resp := "adding LGTM is restricted to approvers and reviewers in OWNERS files."
+			resp := messages.Render(reviewersOnlyMessage, "adding LGTM is restricted to approvers and reviewers in OWNERS files.", nil)
 			log.Infof("Reply to /lgtm request with comment: \"%s\"", resp)
 			return spc.CreateComment(org, repoName, number, true, plugins.FormatResponseRaw(body, htmlURL, spc.QuoteAuthorForComment(author), resp))
 		}
@@ -0,0 +1,98 @@
+// Package mergewhenready contains a plugin which allows the authors of pull
+// requests to confirm they are ready to be merged. Keeper queries which list
+// the label in their required labels will only merge pull requests once the
+// author has confirmed them.
+package mergewhenready
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/pluginhelp"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// PluginName defines this plugin's registered name.
+	PluginName = "merge-when-ready"
+	// Label is the label added to pull requests confirmed as ready to merge by their author
+	Label = "merge-when-ready"
+)
+
+var (
+	mergeWhenReadyRe       = regexp.MustCompile(`(?mi)^/(?:lh-)?merge-when-ready\s*$`)
+	mergeWhenReadyCancelRe = regexp.MustCompile(`(?mi)^/(?:lh-)?merge-when-ready cancel\s*$`)
+)
+
+func init() {
+	plugins.RegisterGenericCommentHandler(PluginName, handleGenericComment, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+	// The Config field is omitted because this plugin is not configurable.
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: "The merge-when-ready plugin allows the author of a pull request to add or remove the '" + Label + "' Label. " +
+			"Add the Label to the required labels of the keeper queries of a repository to require a confirmation from the author before merging.",
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/merge-when-ready [cancel]",
+		Description: "Adds or removes the `" + Label + "` Label which confirms the PR can be merged once all other merge requirements are met.",
+		Featured:    false,
+		WhoCanUse:   "The author of the pull request.",
+		Examples:    []string{"/merge-when-ready", "/merge-when-ready cancel", "/lh-merge-when-ready"},
+	})
+	return pluginHelp, nil
+}
+
+type scmProviderClient interface {
+	AddLabel(owner, repo string, number int, label string, pr bool) error
+	RemoveLabel(owner, repo string, number int, label string, pr bool) error
+	GetIssueLabels(org, repo string, number int, pr bool) ([]*scm.Label, error)
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+	QuoteAuthorForComment(string) string
+}
+
+func handleGenericComment(pc plugins.Agent, e scmprovider.GenericCommentEvent) error {
+	return handle(pc.SCMProviderClient, pc.Logger, &e)
+}
+
+// handle adds the label when the author of a pull request comments /merge-when-ready
+// and removes it when they comment /merge-when-ready cancel
+func handle(spc scmProviderClient, log *logrus.Entry, e *scmprovider.GenericCommentEvent) error {
+	if !e.IsPR || e.Action != scm.ActionCreate {
+		return nil
+	}
+	needsLabel := false
+	if mergeWhenReadyRe.MatchString(e.Body) {
+		needsLabel = true
+	} else if !mergeWhenReadyCancelRe.MatchString(e.Body) {
+		return nil
+	}
+
+	org := e.Repo.Namespace
+	repo := e.Repo.Name
+	commentAuthor := e.Author.Login
+	if scmprovider.NormLogin(commentAuthor) != scmprovider.NormLogin(e.IssueAuthor.Login) {
+		response := "Only the author of the pull request can confirm it is ready to merge."
+		log.Infof("Commenting \"%s\".", response)
+		return spc.CreateComment(org, repo, e.Number, true, plugins.FormatResponseRaw(e.Body, e.Link, spc.QuoteAuthorForComment(commentAuthor), response))
+	}
+
+	issueLabels, err := spc.GetIssueLabels(org, repo, e.Number, true)
+	if err != nil {
+		return fmt.Errorf("failed to get the labels on %s/%s#%d: %v", org, repo, e.Number, err)
+	}
+
+	hasLabel := scmprovider.HasLabel(Label, issueLabels)
+	if hasLabel && !needsLabel {
+		log.Infof("Removing %q Label for %s/%s#%d", Label, org, repo, e.Number)
+		return spc.RemoveLabel(org, repo, e.Number, Label, true)
+	} else if !hasLabel && needsLabel {
+		log.Infof("Adding %q Label for %s/%s#%d", Label, org, repo, e.Number)
+		return spc.AddLabel(org, repo, e.Number, Label, true)
+	}
+	return nil
+}
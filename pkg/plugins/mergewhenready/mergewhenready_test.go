@@ -0,0 +1,112 @@
+package mergewhenready
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandle(t *testing.T) {
+	const fakeLabel = "org/repo#1:" + Label
+	tests := []struct {
+		name          string
+		body          string
+		author        string
+		isPR          bool
+		hasLabel      bool
+		shouldLabel   bool
+		shouldUnlabel bool
+		shouldComment bool
+	}{
+		{
+			name:   "nothing to do",
+			body:   "noise",
+			author: "author",
+			isPR:   true,
+		},
+		{
+			name:        "author confirms",
+			body:        "/merge-when-ready",
+			author:      "author",
+			isPR:        true,
+			shouldLabel: true,
+		},
+		{
+			name:        "author confirms with prefix",
+			body:        "/lh-merge-when-ready",
+			author:      "Author",
+			isPR:        true,
+			shouldLabel: true,
+		},
+		{
+			name:     "author confirms, Label already exists",
+			body:     "/merge-when-ready",
+			author:   "author",
+			isPR:     true,
+			hasLabel: true,
+		},
+		{
+			name:          "author cancels",
+			body:          "/merge-when-ready cancel",
+			author:        "author",
+			isPR:          true,
+			hasLabel:      true,
+			shouldUnlabel: true,
+		},
+		{
+			name:          "someone else confirms",
+			body:          "/merge-when-ready",
+			author:        "reviewer",
+			isPR:          true,
+			shouldComment: true,
+		},
+		{
+			name:   "issue",
+			body:   "/merge-when-ready",
+			author: "author",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, fc := fake.NewDefault()
+			if tc.hasLabel {
+				fc.PullRequestLabelsExisting = []string{fakeLabel}
+			}
+			e := &scmprovider.GenericCommentEvent{
+				IsPR:        tc.isPR,
+				Action:      scm.ActionCreate,
+				Body:        tc.body,
+				Number:      1,
+				Repo:        scm.Repository{Namespace: "org", Name: "repo"},
+				Author:      scm.User{Login: tc.author},
+				IssueAuthor: scm.User{Login: "author"},
+			}
+
+			err := handle(scmprovider.ToTestClient(client), logrus.WithField("plugin", PluginName), e)
+			require.NoError(t, err)
+
+			if tc.shouldLabel {
+				assert.Equal(t, []string{fakeLabel}, fc.PullRequestLabelsAdded)
+			} else {
+				assert.Empty(t, fc.PullRequestLabelsAdded)
+			}
+			if tc.shouldUnlabel {
+				assert.Equal(t, []string{fakeLabel}, fc.PullRequestLabelsRemoved)
+			} else {
+				assert.Empty(t, fc.PullRequestLabelsRemoved)
+			}
+			if tc.shouldComment {
+				require.Len(t, fc.PullRequestCommentsAdded, 1)
+				assert.Contains(t, fc.PullRequestCommentsAdded[0], "Only the author of the pull request")
+			} else {
+				assert.Empty(t, fc.PullRequestCommentsAdded)
+			}
+		})
+	}
+}
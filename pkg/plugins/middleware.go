@@ -0,0 +1,99 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Invocation describes a single call of a plugin handler for an event
+type Invocation struct {
+	// Plugin is the name of the plugin
+	Plugin string
+	// Event is the kind of event handled, e.g. "PushEvent"
+	Event string
+	// Org and Repo are the repository the event belongs to
+	Org  string
+	Repo string
+	// Agent is the agent passed to the handler
+	Agent *Agent
+	// Context is cancelled once the deadline of the event is exceeded, it is nil if events have no deadline
+	Context context.Context
+}
+
+// TimedOut returns true if the deadline of the event was exceeded
+func (inv Invocation) TimedOut() bool {
+	return inv.Context != nil && inv.Context.Err() == context.DeadlineExceeded
+}
+
+// InvocationHandler invokes a plugin handler
+type InvocationHandler func(inv Invocation) error
+
+// Middleware wraps the invocation of plugin handlers, allowing cross-cutting
+// policies such as auth checks, rate limiting, auditing or metrics to be
+// enforced once for all plugins. A middleware may skip the invocation by not
+// calling next.
+type Middleware func(next InvocationHandler) InvocationHandler
+
+var (
+	middlewares     []Middleware
+	middlewaresLock sync.RWMutex
+)
+
+// RegisterMiddleware registers a middleware applied to all plugin handler invocations.
+// Middlewares are applied in registration order, the first registered being the outermost.
+func RegisterMiddleware(m Middleware) {
+	middlewaresLock.Lock()
+	defer middlewaresLock.Unlock()
+	middlewares = append(middlewares, m)
+}
+
+// Invoke calls the handler for the invocation through the given middlewares
+// followed by the registered ones
+func Invoke(inv Invocation, handler InvocationHandler, extra ...Middleware) error {
+	middlewaresLock.RLock()
+	chain := append(append([]Middleware{}, extra...), middlewares...)
+	middlewaresLock.RUnlock()
+
+	h := handler
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = chain[i](h)
+	}
+	return h(inv)
+}
+
+// RecoverMiddleware turns panics of plugin handlers into errors so that a
+// misbehaving plugin cannot take down the process
+func RecoverMiddleware(next InvocationHandler) InvocationHandler {
+	return func(inv Invocation) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("plugin %s panicked handling %s: %v\n%s", inv.Plugin, inv.Event, r, debug.Stack())
+			}
+		}()
+		return next(inv)
+	}
+}
+
+// AuditMiddleware logs every invocation of a plugin handler along with its duration and outcome,
+// including whether it timed out
+func AuditMiddleware(next InvocationHandler) InvocationHandler {
+	return func(inv Invocation) error {
+		start := time.Now()
+		err := next(inv)
+		if inv.Agent != nil && inv.Agent.Logger != nil {
+			l := inv.Agent.Logger.WithField("event", inv.Event).WithField("duration", time.Since(start).String())
+			if err != nil {
+				l = l.WithError(err)
+			}
+			if inv.TimedOut() {
+				l.WithField("timedOut", true).Warn("Plugin handler exceeded the deadline of the event, its SCM calls were cancelled.")
+				return err
+			}
+			l.Debug("Plugin handler invoked.")
+		}
+		return err
+	}
+}
@@ -0,0 +1,76 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoke(t *testing.T) {
+	defer func(old []Middleware) { middlewares = old }(middlewares)
+	middlewares = nil
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next InvocationHandler) InvocationHandler {
+			return func(inv Invocation) error {
+				calls = append(calls, name+":before")
+				err := next(inv)
+				calls = append(calls, name+":after")
+				return err
+			}
+		}
+	}
+	RegisterMiddleware(record("registered"))
+
+	err := Invoke(Invocation{Plugin: "cat", Event: "PushEvent"}, func(inv Invocation) error {
+		calls = append(calls, "handler:"+inv.Plugin)
+		return nil
+	}, record("extra"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"extra:before", "registered:before", "handler:cat", "registered:after", "extra:after"}, calls)
+
+	denied := errors.New("denied")
+	RegisterMiddleware(func(next InvocationHandler) InvocationHandler {
+		return func(inv Invocation) error {
+			return denied
+		}
+	})
+	called := false
+	err = Invoke(Invocation{Plugin: "cat"}, func(Invocation) error {
+		called = true
+		return nil
+	})
+	assert.Equal(t, denied, err)
+	assert.False(t, called, "a middleware not calling next skips the handler")
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	err := Invoke(Invocation{Plugin: "cat", Event: "PushEvent"}, func(Invocation) error {
+		panic("boom")
+	}, RecoverMiddleware, AuditMiddleware)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "plugin cat panicked handling PushEvent: boom")
+}
+
+func TestInvocationTimedOut(t *testing.T) {
+	assert.False(t, Invocation{}.TimedOut())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, Invocation{Context: ctx}.TimedOut(), "a cancelled event did not time out")
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	inv := Invocation{Plugin: "cat", Event: "PushEvent", Context: ctx}
+	assert.True(t, inv.TimedOut())
+	err := Invoke(inv, func(Invocation) error {
+		return ctx.Err()
+	}, AuditMiddleware)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -64,7 +64,7 @@ type overrideClient interface {
 
 type client struct {
 	spc           scmProviderClient
-	jc            config.JobConfig
+	cfg           *config.Config
 	clientFactory jxfactory.Factory
 	lhClient      lighthouseclient.LighthouseJobInterface
 }
@@ -115,7 +115,10 @@ func (c client) QuoteAuthorForComment(author string) string {
 }
 
 func (c client) presubmitForContext(org, repo, context string) *config.Presubmit {
-	for _, p := range c.jc.AllPresubmits([]string{org + "/" + repo}) {
+	if c.cfg == nil {
+		return nil
+	}
+	for _, p := range jobutil.Presubmits(c.cfg, scm.Repository{Namespace: org, Name: repo, FullName: scm.Join(org, repo)}) {
 		if p.Context == context {
 			return &p
 		}
@@ -146,9 +149,7 @@ func handleGenericComment(pc plugins.Agent, e scmprovider.GenericCommentEvent) e
 		spc:           pc.SCMProviderClient,
 		clientFactory: pc.ClientFactory,
 		lhClient:      pc.LighthouseClient,
-	}
-	if pc.Config != nil {
-		c.jc = pc.Config.JobConfig
+		cfg:           pc.Config,
 	}
 	return handle(pc.ClientFactory, c, pc.Logger, &e)
 }
@@ -0,0 +1,100 @@
+// Package pathlabel contains a plugin which labels pull requests according to
+// the files they change, using the mapping of path patterns to labels of the
+// path_labels section of plugins.yaml, for repositories which do not maintain
+// OWNERS files.
+package pathlabel
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/pluginhelp"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// PluginName defines this plugin's registered name.
+const PluginName = "path-label"
+
+func init() {
+	plugins.RegisterPullRequestHandler(PluginName, handlePullRequest, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+	return &pluginhelp.PluginHelp{
+			Description: "The path-label plugin automatically adds labels to PRs based on the files they touch, using the mapping of paths to labels of the path_labels section of plugins.yaml rather than OWNERS files.",
+		},
+		nil
+}
+
+type scmProviderClient interface {
+	AddLabel(org, repo string, number int, label string, pr bool) error
+	GetIssueLabels(org, repo string, number int, pr bool) ([]*scm.Label, error)
+	GetRepoLabels(owner, repo string) ([]*scm.Label, error)
+	GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error)
+}
+
+func handlePullRequest(pc plugins.Agent, pre scm.PullRequestHook) error {
+	if pre.Action != scm.ActionOpen && pre.Action != scm.ActionReopen && pre.Action != scm.ActionSync {
+		return nil
+	}
+	if pc.PluginSettings == nil {
+		return nil
+	}
+	return handle(pc.SCMProviderClient, pc.PluginSettings.PathLabels, pc.Logger, &pre)
+}
+
+func handle(spc scmProviderClient, pathLabels plugins.PathLabels, log *logrus.Entry, pre *scm.PullRequestHook) error {
+	org := pre.Repo.Namespace
+	repo := pre.Repo.Name
+	number := pre.PullRequest.Number
+	if len(pathLabels.For(org, repo)) == 0 {
+		return nil
+	}
+
+	changes, err := spc.GetPullRequestChanges(org, repo, number)
+	if err != nil {
+		return fmt.Errorf("error getting PR changes: %v", err)
+	}
+	var files []string
+	for _, change := range changes {
+		files = append(files, change.Path)
+	}
+	neededLabels := pathLabels.LabelsFor(org, repo, files)
+	if neededLabels.Len() == 0 {
+		return nil
+	}
+
+	repoLabels, err := spc.GetRepoLabels(org, repo)
+	if err != nil {
+		return err
+	}
+	issueLabels, err := spc.GetIssueLabels(org, repo, number, true)
+	if err != nil {
+		return err
+	}
+	existing := sets.NewString()
+	for _, label := range repoLabels {
+		existing.Insert(label.Name)
+	}
+	current := sets.NewString()
+	for _, label := range issueLabels {
+		current.Insert(label.Name)
+	}
+
+	nonexistent := sets.NewString()
+	for _, labelToAdd := range neededLabels.Difference(current).List() {
+		if !existing.Has(labelToAdd) {
+			nonexistent.Insert(labelToAdd)
+			continue
+		}
+		if err := spc.AddLabel(org, repo, number, labelToAdd, true); err != nil {
+			log.WithError(err).Errorf("Failed to add the label %s", labelToAdd)
+		}
+	}
+	if nonexistent.Len() > 0 {
+		log.Warnf("Unable to add nonexistent labels: %q", nonexistent.List())
+	}
+	return nil
+}
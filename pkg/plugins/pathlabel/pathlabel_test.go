@@ -0,0 +1,110 @@
+package pathlabel
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/sirupsen/logrus"
+)
+
+var testPathLabels = plugins.PathLabels{
+	"org": {
+		{Label: "area/docs", Paths: []string{"docs/**", "*.md"}},
+	},
+	"org/repo": {
+		{Label: "area/helm", Paths: []string{"charts/**"}},
+		{Label: "area/api", Paths: []string{"pkg/apis/*/types.go"}},
+	},
+	"other/repo": {
+		{Label: "area/other", Paths: []string{"**"}},
+	},
+}
+
+func TestHandle(t *testing.T) {
+	testcases := []struct {
+		name              string
+		action            scm.Action
+		repo              string
+		filesChanged      []string
+		repoLabels        []string
+		prLabels          []string
+		expectedNewLabels []string
+	}{
+		{
+			name:              "matching files",
+			action:            scm.ActionOpen,
+			repo:              "repo",
+			filesChanged:      []string{"charts/lighthouse/values.yaml", "docs/index.md", "main.go"},
+			repoLabels:        []string{"area/helm", "area/docs"},
+			expectedNewLabels: []string{"org/repo#1:area/docs", "org/repo#1:area/helm"},
+		},
+		{
+			name:              "label already present",
+			action:            scm.ActionSync,
+			repo:              "repo",
+			filesChanged:      []string{"charts/lighthouse/values.yaml"},
+			repoLabels:        []string{"area/helm"},
+			prLabels:          []string{"area/helm"},
+			expectedNewLabels: []string{"org/repo#1:area/helm"},
+		},
+		{
+			name:              "label does not exist on the repo",
+			action:            scm.ActionOpen,
+			repo:              "repo",
+			filesChanged:      []string{"charts/lighthouse/values.yaml"},
+			repoLabels:        []string{},
+			expectedNewLabels: []string{},
+		},
+		{
+			name:              "repo without path labels",
+			action:            scm.ActionOpen,
+			repo:              "unconfigured",
+			filesChanged:      []string{"charts/lighthouse/values.yaml"},
+			repoLabels:        []string{"area/helm"},
+			expectedNewLabels: []string{},
+		},
+	}
+	for _, tc := range testcases {
+		basicPR := scm.PullRequest{
+			Number: 1,
+			Base: scm.PullRequestBranch{
+				Repo: scm.Repository{
+					Namespace: "org",
+					Name:      tc.repo,
+				},
+			},
+		}
+		changes := make([]*scm.Change, 0, len(tc.filesChanged))
+		for _, name := range tc.filesChanged {
+			changes = append(changes, &scm.Change{Path: name})
+		}
+		fakeScmClient, fspc := fake.NewDefault()
+		fakeClient := scmprovider.ToTestClient(fakeScmClient)
+		fspc.PullRequests[basicPR.Number] = &basicPR
+		fspc.PullRequestChanges[basicPR.Number] = changes
+		fspc.RepoLabelsExisting = tc.repoLabels
+		for _, label := range tc.prLabels {
+			fakeClient.AddLabel(basicPR.Base.Repo.Namespace, basicPR.Base.Repo.Name, basicPR.Number, label, true)
+		}
+		pre := &scm.PullRequestHook{
+			Action:      tc.action,
+			PullRequest: basicPR,
+			Repo:        basicPR.Base.Repo,
+		}
+
+		if err := handle(fakeClient, testPathLabels, logrus.WithField("plugin", PluginName), pre); err != nil {
+			t.Errorf("[%s] unexpected error from handle: %v", tc.name, err)
+			continue
+		}
+		added := append([]string{}, fspc.PullRequestLabelsAdded...)
+		sort.Strings(added)
+		if !reflect.DeepEqual(tc.expectedNewLabels, added) {
+			t.Errorf("[%s] expected the labels %q to be added, but %q were added.", tc.name, tc.expectedNewLabels, added)
+		}
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package plugins
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -32,15 +33,44 @@ import (
 	lighthouseclient "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/typed/lighthouse/v1alpha1"
 	"github.com/jenkins-x/lighthouse/pkg/commentpruner"
 	git2 "github.com/jenkins-x/lighthouse/pkg/git"
+	"github.com/jenkins-x/lighthouse/pkg/identity"
 	"github.com/jenkins-x/lighthouse/pkg/launcher"
 	"github.com/jenkins-x/lighthouse/pkg/pluginhelp"
 	"github.com/jenkins-x/lighthouse/pkg/repoowners"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/yaml"
 )
 
+// DisablePluginPrefix is used in a repository's plugin list to disable a plugin
+// which is enabled for the repository's org, e.g. "-lgtm".
+const DisablePluginPrefix = "-"
+
+// The plugin events, which are the kinds of handlers plugins register
+const (
+	// GenericCommentEvent is handled by GenericCommentHandlers
+	GenericCommentEvent = "GenericCommentEvent"
+	// IssueEvent is handled by IssueHandlers
+	IssueEvent = "IssueEvent"
+	// IssueCommentEvent is handled by IssueCommentHandlers
+	IssueCommentEvent = "IssueCommentEvent"
+	// PullRequestEvent is handled by PullRequestHandlers
+	PullRequestEvent = "PullRequestEvent"
+	// PushEvent is handled by PushEventHandlers
+	PushEvent = "PushEvent"
+	// ReleaseEvent is handled by ReleaseEventHandlers
+	ReleaseEvent = "ReleaseEvent"
+	// ReviewEvent is handled by ReviewEventHandlers
+	ReviewEvent = "ReviewEvent"
+	// ReviewCommentEvent is handled by ReviewCommentEventHandlers
+	ReviewCommentEvent = "ReviewCommentEvent"
+	// StatusEvent is handled by StatusEventHandlers
+	StatusEvent = "StatusEvent"
+)
+
 var (
 	pluginHelp                 = map[string]HelpProvider{}
 	genericCommentHandlers     = map[string]GenericCommentHandler{}
@@ -48,6 +78,7 @@ var (
 	issueCommentHandlers       = map[string]IssueCommentHandler{}
 	pullRequestHandlers        = map[string]PullRequestHandler{}
 	pushEventHandlers          = map[string]PushEventHandler{}
+	releaseEventHandlers       = map[string]ReleaseEventHandler{}
 	reviewEventHandlers        = map[string]ReviewEventHandler{}
 	reviewCommentEventHandlers = map[string]ReviewCommentEventHandler{}
 	statusEventHandlers        = map[string]StatusEventHandler{}
@@ -107,6 +138,15 @@ func RegisterPushEventHandler(name string, fn PushEventHandler, help HelpProvide
 	pushEventHandlers[name] = fn
 }
 
+// ReleaseEventHandler defines the function contract for a scmprovider.ReleaseHook handler.
+type ReleaseEventHandler func(Agent, scmprovider.ReleaseHook) error
+
+// RegisterReleaseEventHandler registers a plugin's scmprovider.ReleaseHook handler.
+func RegisterReleaseEventHandler(name string, fn ReleaseEventHandler, help HelpProvider) {
+	pluginHelp[name] = help
+	releaseEventHandlers[name] = fn
+}
+
 // ReviewEventHandler defines the function contract for a ReviewHook handler.
 type ReviewEventHandler func(Agent, scm.ReviewHook) error
 
@@ -148,12 +188,18 @@ type Agent struct {
 	*/
 
 	OwnersClient *repoowners.Client
+	// IdentityMapper maps the logins to the identities of the internal directory, it may be nil
+	IdentityMapper identity.Mapper
 
 	// Config provides information about the jobs
 	// that we know how to run for repos.
 	Config *config.Config
 	// PluginConfig provides plugin-specific options
 	PluginConfig *Configuration
+	// Settings are the lighthouse settings of config.yaml
+	Settings *settings.Config
+	// PluginSettings are the lighthouse specific plugin settings of plugins.yaml
+	PluginSettings *Settings
 
 	Logger *logrus.Entry
 
@@ -166,6 +212,18 @@ func NewAgent(clientFactory jxfactory.Factory, configAgent *config.Agent, plugin
 	prowConfig := configAgent.Config()
 	pluginConfig := pluginConfigAgent.Config()
 	scmClient := scmprovider.ToClient(clientAgent.SCMProviderClient, clientAgent.BotName)
+	scmClient.SetSettings(clientAgent.Settings)
+	ownersClient := repoowners.NewClient(
+		clientAgent.GitClient, scmClient,
+		prowConfig, pluginConfig.MDYAMLEnabled,
+		pluginConfig.SkipCollaborators,
+	)
+	ownersClient.SetIdentityMapper(clientAgent.IdentityMapper)
+	ownersClient.SetCache(clientAgent.OwnersCache)
+	lighthouseSettings := &settings.Config{}
+	if clientAgent.Settings != nil {
+		lighthouseSettings = clientAgent.Settings()
+	}
 	return Agent{
 		ClientFactory:     clientFactory,
 		SCMProviderClient: scmClient,
@@ -177,14 +235,21 @@ func NewAgent(clientFactory jxfactory.Factory, configAgent *config.Agent, plugin
 		/*
 			SlackClient:   clientAgent.SlackClient,
 		*/
-		OwnersClient: repoowners.NewClient(
-			clientAgent.GitClient, scmClient,
-			prowConfig, pluginConfig.MDYAMLEnabled,
-			pluginConfig.SkipCollaborators,
-		),
-		Config:       prowConfig,
-		PluginConfig: pluginConfig,
-		Logger:       logger,
+		OwnersClient:   ownersClient,
+		IdentityMapper: clientAgent.IdentityMapper,
+		Config:         prowConfig,
+		PluginConfig:   pluginConfig,
+		Settings:       lighthouseSettings,
+		PluginSettings: pluginConfigAgent.Settings(),
+		Logger:         logger,
+	}
+}
+
+// SetContext sets the context of the SCM calls of the agent, including those of its
+// owners client and comment pruner, so that they are cancelled along with it
+func (a *Agent) SetContext(ctx context.Context) {
+	if a.SCMProviderClient != nil {
+		a.SCMProviderClient.SetContext(ctx)
 	}
 }
 
@@ -215,6 +280,13 @@ type ClientAgent struct {
 	GitClient        git2.Client
 	LauncherClient   launcher.PipelineLauncher
 	LighthouseClient lighthouseclient.LighthouseJobInterface
+	// IdentityMapper maps the logins to the identities of the internal directory, it is nil if
+	// no identity mapping is configured
+	IdentityMapper identity.Mapper
+	// OwnersCache caches the OWNERS files across events, a cache per agent is used if it is nil
+	OwnersCache *repoowners.Cache
+	// Settings are the lighthouse settings, such as the comment settings, none are used if it is nil
+	Settings settings.Getter
 
 	/*	SlackClient      *slack.Client
 	 */
@@ -224,6 +296,7 @@ type ClientAgent struct {
 type ConfigAgent struct {
 	mut           sync.Mutex
 	configuration *Configuration
+	settings      *Settings
 }
 
 // Load attempts to load config from the path. It returns an error if either
@@ -248,8 +321,13 @@ func (pa *ConfigAgent) Load(path string) error {
 	if err := np.ValidatePluginsArePresent(presentPlugins); err != nil {
 		return err
 	}
+	pluginSettings, err := LoadSettings(b)
+	if err != nil {
+		return err
+	}
 
 	pa.Set(np)
+	pa.SetSettings(pluginSettings)
 	return nil
 }
 
@@ -280,6 +358,26 @@ func (pa *ConfigAgent) Config() *Configuration {
 	return pa.configuration
 }
 
+// Settings returns the current lighthouse plugin settings, which are empty if none were loaded
+func (pa *ConfigAgent) Settings() *Settings {
+	if pa == nil {
+		return &Settings{}
+	}
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	if pa.settings == nil {
+		return &Settings{}
+	}
+	return pa.settings
+}
+
+// SetSettings sets the current lighthouse plugin settings
+func (pa *ConfigAgent) SetSettings(s *Settings) {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	pa.settings = s
+}
+
 // Set attempts to set the plugins that are enabled on repos. Plugins are listed
 // as a map from repositories to the list of plugins that are enabled on them.
 // Specifying simply an org name will also work, and will enable the plugin on
@@ -425,6 +523,69 @@ func (pa *ConfigAgent) PushEventHandlers(owner, repo string) map[string]PushEven
 	return hs
 }
 
+// ReleaseEventHandlers returns a map of plugin names to handlers for the repo.
+func (pa *ConfigAgent) ReleaseEventHandlers(owner, repo string) map[string]ReleaseEventHandler {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+
+	hs := map[string]ReleaseEventHandler{}
+	for _, p := range pa.getPlugins(owner, repo) {
+		if h, ok := releaseEventHandlers[p]; ok {
+			hs[p] = h
+		}
+	}
+
+	return hs
+}
+
+// PluginsForEvent returns the sorted names of the plugins enabled for the repo which handle the plugin event.
+func (pa *ConfigAgent) PluginsForEvent(event, owner, repo string) []string {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+
+	names := sets.NewString()
+	for _, p := range pa.getPlugins(owner, repo) {
+		if handlesEvent(p, event) {
+			names.Insert(p)
+		}
+	}
+	return names.List()
+}
+
+// EnabledPlugins returns the sorted names of the plugins enabled for the repo.
+func (pa *ConfigAgent) EnabledPlugins(owner, repo string) []string {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+
+	return sets.NewString(pa.getPlugins(owner, repo)...).List()
+}
+
+// handlesEvent returns true if the plugin registered a handler for the plugin event
+func handlesEvent(name, event string) bool {
+	var ok bool
+	switch event {
+	case GenericCommentEvent:
+		_, ok = genericCommentHandlers[name]
+	case IssueEvent:
+		_, ok = issueHandlers[name]
+	case IssueCommentEvent:
+		_, ok = issueCommentHandlers[name]
+	case PullRequestEvent:
+		_, ok = pullRequestHandlers[name]
+	case PushEvent:
+		_, ok = pushEventHandlers[name]
+	case ReleaseEvent:
+		_, ok = releaseEventHandlers[name]
+	case ReviewEvent:
+		_, ok = reviewEventHandlers[name]
+	case ReviewCommentEvent:
+		_, ok = reviewCommentEventHandlers[name]
+	case StatusEvent:
+		_, ok = statusEventHandlers[name]
+	}
+	return ok
+}
+
 // getPlugins returns a list of plugins that are enabled on a given (org, repository).
 func (pa *ConfigAgent) getPlugins(owner, repo string) []string {
 	var plugins []string
@@ -439,7 +600,14 @@ func (pa *ConfigAgent) getPlugins(owner, repo string) []string {
 	for _, o := range owners {
 		fullName := fmt.Sprintf("%s/%s", o, repo)
 		plugins = append(plugins, pa.configuration.Plugins[o]...)
-		plugins = append(plugins, pa.configuration.Plugins[fullName]...)
+		for _, p := range pa.configuration.Plugins[fullName] {
+			// a repository can opt out of a plugin enabled for the whole org with -name
+			if strings.HasPrefix(p, DisablePluginPrefix) {
+				plugins = removePlugin(plugins, strings.TrimPrefix(p, DisablePluginPrefix))
+				continue
+			}
+			plugins = append(plugins, p)
+		}
 	}
 
 	// until we have the configuration stuff setup nicely - lets add a simple way to enable plugins
@@ -466,6 +634,16 @@ func (pa *ConfigAgent) getPlugins(owner, repo string) []string {
 	return plugins
 }
 
+func removePlugin(plugins []string, name string) []string {
+	var answer []string
+	for _, p := range plugins {
+		if p != name {
+			answer = append(answer, p)
+		}
+	}
+	return answer
+}
+
 // EventsForPlugin returns the registered events for the passed plugin.
 func EventsForPlugin(name string) []string {
 	var events []string
@@ -481,6 +659,9 @@ func EventsForPlugin(name string) []string {
 	if _, ok := pushEventHandlers[name]; ok {
 		events = append(events, "push")
 	}
+	if _, ok := releaseEventHandlers[name]; ok {
+		events = append(events, "release")
+	}
 	if _, ok := reviewEventHandlers[name]; ok {
 		events = append(events, "pull_request_review")
 	}
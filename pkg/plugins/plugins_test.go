@@ -155,6 +155,16 @@ func TestGetPlugins(t *testing.T) {
 			repo:            "repo",
 			expectedPlugins: []string{"plugin3"},
 		},
+		{
+			name: "Plugins disabled for org1/repo should not be returned for org1/repo query",
+			pluginMap: map[string][]string{
+				"org1":      {"plugin1", "plugin2"},
+				"org1/repo": {"-plugin1", "plugin3"},
+			},
+			owner:           "org1",
+			repo:            "repo",
+			expectedPlugins: []string{"plugin2", "plugin3"},
+		},
 	}
 	for _, tc := range testcases {
 		pa := ConfigAgent{configuration: &Configuration{Plugins: tc.pluginMap}}
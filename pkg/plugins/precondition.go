@@ -0,0 +1,83 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/lighthouse/pkg/messages"
+	"github.com/pkg/errors"
+)
+
+// Severity tells the users how serious the failure of a precondition of a command is
+type Severity string
+
+const (
+	// SeverityInfo is for commands which had nothing to do, e.g. because it was already done
+	SeverityInfo Severity = "info"
+	// SeverityWarning is for commands which cannot run in the current state of the pull request or issue
+	SeverityWarning Severity = "warning"
+	// SeverityError is for commands the user is not allowed to run
+	SeverityError Severity = "error"
+)
+
+// CommandsDocsURL is the documentation of the commands linked by the failed preconditions without a more
+// specific documentation
+const CommandsDocsURL = "https://go.k8s.io/bot-commands"
+
+// preconditionMessage is the message catalog ID of the reply to the commands whose precondition failed
+const preconditionMessage = "plugins.precondition-failed"
+
+const defaultPreconditionText = "{{ .Icon }} **{{ .Title }}**: cannot run `{{ .Command }}`, {{ .Requirement }}.\n\nSee [the documentation]({{ .DocsURL }}) for the requirements of the command."
+
+// PreconditionError is returned by the plugin handlers when a command cannot run because of the
+// permissions of its author or the state of the pull request or issue. The webhook replies to the
+// command with a standard message telling the requirement which failed, rather than ignoring it.
+type PreconditionError struct {
+	// Severity is how serious the failure is
+	Severity Severity
+	// Command is the command which cannot run, e.g. /approve
+	Command string
+	// Requirement is the requirement which failed, e.g. the pull request must be open
+	Requirement string
+	// DocsURL is the documentation of the command, CommandsDocsURL if empty
+	DocsURL string
+}
+
+// NewPreconditionError returns the error of a command which cannot run as the requirement failed
+func NewPreconditionError(severity Severity, command, requirement string) *PreconditionError {
+	return &PreconditionError{Severity: severity, Command: command, Requirement: requirement}
+}
+
+// Error returns the failed requirement, for the logs
+func (e *PreconditionError) Error() string {
+	return fmt.Sprintf("cannot run %s: %s", e.Command, e.Requirement)
+}
+
+// Message returns the reply to the command telling the users why it cannot run. It is rendered from the
+// plugins.precondition-failed message of the message catalog.
+func (e *PreconditionError) Message() string {
+	icon, title := ":information_source:", "Info"
+	switch e.Severity {
+	case SeverityWarning:
+		icon, title = ":warning:", "Warning"
+	case SeverityError:
+		icon, title = ":no_entry:", "Error"
+	}
+	docsURL := e.DocsURL
+	if docsURL == "" {
+		docsURL = CommandsDocsURL
+	}
+	return messages.Render(preconditionMessage, defaultPreconditionText, map[string]interface{}{
+		"Icon":        icon,
+		"Title":       title,
+		"Severity":    string(e.Severity),
+		"Command":     e.Command,
+		"Requirement": e.Requirement,
+		"DocsURL":     docsURL,
+	})
+}
+
+// AsPreconditionError returns the PreconditionError the error was caused by, if any
+func AsPreconditionError(err error) (*PreconditionError, bool) {
+	e, ok := errors.Cause(err).(*PreconditionError)
+	return e, ok
+}
@@ -27,7 +27,7 @@ import (
 const AboutThisBotWithoutCommands = "Instructions for interacting with me using PR comments are available [here](https://git.k8s.io/community/contributors/guide/pull-requests.md).  If you have questions or suggestions related to my behavior, please file an issue against the [jenkins-x/lighthouse](https://github.com/jenkins-x/lighthouse/issues/new?title=Command%20issue:) repository."
 
 // AboutThisBotCommands contains the message that links to the commands the bot understand.
-const AboutThisBotCommands = "I understand the commands that are listed [here](https://go.k8s.io/bot-commands)."
+const AboutThisBotCommands = "I understand the commands that are listed [here](" + CommandsDocsURL + ")."
 
 // AboutThisBot contains the text of both AboutThisBotWithoutCommands and AboutThisBotCommands.
 const AboutThisBot = AboutThisBotWithoutCommands + " " + AboutThisBotCommands
@@ -0,0 +1,242 @@
+package plugins
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/pathmatch"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// Settings are the lighthouse specific plugin settings of plugins.yaml which are not part of the
+// lighthouse-config types. They are read from the same file as the rest of the plugin configuration,
+// so that they are reloaded with it.
+type Settings struct {
+	// PathLabels are the labels added by the path-label plugin, keyed by org or org/repo
+	PathLabels PathLabels `json:"path_labels,omitempty"`
+	// ApproveRequirements are the approvals required by the approve plugin, keyed by org or org/repo
+	ApproveRequirements ApproveRequirements `json:"approve_requirements,omitempty"`
+	// TrustPolicies are the users the trigger plugin trusts, keyed by org or org/repo
+	TrustPolicies TrustPolicies `json:"trust_policies,omitempty"`
+	// ShadowPlugins are the plugins which only log the changes they would make, keyed by org or org/repo
+	ShadowPlugins ShadowPlugins `json:"shadow_plugins,omitempty"`
+}
+
+// PathLabel adds a label to the pull requests changing files matching one of the paths. The paths
+// are patterns as supported by pathmatch.Match, e.g. `charts/**`, `pkg/**/types.go` or `*.md`.
+type PathLabel struct {
+	Label string   `json:"label"`
+	Paths []string `json:"paths"`
+}
+
+// PathLabels are the path labels keyed by org or org/repo. The path labels of a repository are the
+// ones of its org followed by its own.
+type PathLabels map[string][]PathLabel
+
+// For returns the path labels of the repository
+func (p PathLabels) For(org, repo string) []PathLabel {
+	return append(append([]PathLabel{}, p[org]...), p[org+"/"+repo]...)
+}
+
+// LabelsFor returns the labels of the repository for the changed files
+func (p PathLabels) LabelsFor(org, repo string, files []string) sets.String {
+	labels := sets.NewString()
+	for _, pl := range p.For(org, repo) {
+		for _, file := range files {
+			if pl.Matches(file) {
+				labels.Insert(pl.Label)
+				break
+			}
+		}
+	}
+	return labels
+}
+
+// Validate checks the labels and paths of the path labels
+func (p PathLabels) Validate() error {
+	for key, pls := range p {
+		for _, pl := range pls {
+			if pl.Label == "" {
+				return errors.Errorf("missing label for paths %v of %s", pl.Paths, key)
+			}
+			if len(pl.Paths) == 0 {
+				return errors.Errorf("missing paths for label %s of %s", pl.Label, key)
+			}
+			for _, p := range pl.Paths {
+				if err := pathmatch.Validate(p); err != nil {
+					return errors.Wrapf(err, "invalid path %s for label %s of %s", p, pl.Label, key)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the file matches one of the paths
+func (pl *PathLabel) Matches(file string) bool {
+	return pathmatch.MatchAny(pl.Paths, file)
+}
+
+// ApproveRequirement is the approvals the approve plugin requires, in addition to an approver of each
+// changed file, before applying the approved label
+type ApproveRequirement struct {
+	// MinApprovers is the number of distinct approvers of the changed files required, a single one if unset
+	MinApprovers int `json:"min_approvers,omitempty"`
+	// DistinctOwners only counts a single approver per OWNERS file, so that the approvals come from
+	// different subtrees of the repository
+	DistinctOwners bool `json:"distinct_owners,omitempty"`
+}
+
+// ApproveRequirements are the approve requirements keyed by org or org/repo
+type ApproveRequirements map[string]ApproveRequirement
+
+// For returns the approve requirement of the repository, which is the one of its org unless it has its own
+func (a ApproveRequirements) For(org, repo string) ApproveRequirement {
+	if r, ok := a[org+"/"+repo]; ok {
+		return r
+	}
+	return a[org]
+}
+
+// Validate checks the minimum numbers of approvers
+func (a ApproveRequirements) Validate() error {
+	for key, r := range a {
+		if r.MinApprovers < 0 {
+			return errors.Errorf("negative min_approvers %d for %s", r.MinApprovers, key)
+		}
+	}
+	return nil
+}
+
+// TrustPolicy is who the trigger plugin trusts to have the tests of their PRs run automatically and to
+// use /ok-to-test, instead of the collaborators and org members configured by the trigger plugin. A user
+// is trusted if any of the sources of the policy trusts them.
+type TrustPolicy struct {
+	// Collaborators trusts the collaborators of the repository
+	Collaborators bool `json:"collaborators,omitempty"`
+	// OrgMembers trusts the members of the org of the repository, and of the trusted_org of the trigger plugin
+	OrgMembers bool `json:"org_members,omitempty"`
+	// Teams trusts the members of the teams of the org of the repository, by name
+	Teams []string `json:"teams,omitempty"`
+	// AllowlistFile is the path of a file of the default branch of the repository listing the logins of the
+	// trusted users, one per line, e.g. .lighthouse/TRUSTED_USERS. The lines starting with # are ignored.
+	AllowlistFile string `json:"allowlist_file,omitempty"`
+	// Instructions are added to the comments telling untrusted users that their PR is not tested, e.g. to
+	// explain how to join the team of the contributors
+	Instructions string `json:"instructions,omitempty"`
+}
+
+// IsEmpty returns true if the policy has no source of trusted users, in which case the trigger plugin
+// trusts the collaborators and org members as configured by the trigger plugin
+func (p *TrustPolicy) IsEmpty() bool {
+	return !p.Collaborators && !p.OrgMembers && len(p.Teams) == 0 && p.AllowlistFile == ""
+}
+
+// Describe describes the users trusted by the policy for the comments of the trigger plugin
+func (p *TrustPolicy) Describe(org, trustedOrg string) string {
+	var trusted []string
+	if p.Collaborators {
+		trusted = append(trusted, "the collaborators of the repository")
+	}
+	if p.OrgMembers {
+		members := fmt.Sprintf("the members of the %s org", org)
+		if trustedOrg != "" && trustedOrg != org {
+			members = fmt.Sprintf("the members of the %s and %s orgs", org, trustedOrg)
+		}
+		trusted = append(trusted, members)
+	}
+	if len(p.Teams) > 0 {
+		trusted = append(trusted, fmt.Sprintf("the members of the %s teams of the %s org", strings.Join(p.Teams, ", "), org))
+	}
+	if p.AllowlistFile != "" {
+		trusted = append(trusted, fmt.Sprintf("the users listed in `%s`", p.AllowlistFile))
+	}
+	switch len(trusted) {
+	case 0:
+		return ""
+	case 1:
+		return trusted[0]
+	}
+	return strings.Join(trusted[:len(trusted)-1], ", ") + " or " + trusted[len(trusted)-1]
+}
+
+// TrustPolicies are the trust policies keyed by org or org/repo
+type TrustPolicies map[string]TrustPolicy
+
+// For returns the trust policy of the repository, which is the one of its org unless it has its own
+func (t TrustPolicies) For(org, repo string) TrustPolicy {
+	if p, ok := t[org+"/"+repo]; ok {
+		return p
+	}
+	return t[org]
+}
+
+// Validate checks the teams and allowlist files of the policies
+func (t TrustPolicies) Validate() error {
+	for key, p := range t {
+		for _, team := range p.Teams {
+			if strings.TrimSpace(team) == "" {
+				return errors.Errorf("empty team in the trust policy of %s", key)
+			}
+		}
+		if p.AllowlistFile != "" && (path.IsAbs(p.AllowlistFile) || strings.HasPrefix(path.Clean(p.AllowlistFile), "..")) {
+			return errors.Errorf("allowlist_file %s of %s is not a relative path in the repository", p.AllowlistFile, key)
+		}
+	}
+	return nil
+}
+
+// ShadowPlugins are the names of the plugins running in shadow mode keyed by org or org/repo. Shadow plugins
+// handle the events of the repositories they are enabled for, but only log the comments, labels, statuses
+// and jobs they would create rather than making them, so that new plugins can be validated against real
+// traffic before they are enabled.
+type ShadowPlugins map[string][]string
+
+// IsShadow returns true if the plugin runs in shadow mode for the repository, as it is a shadow plugin of the
+// repository or of its org
+func (s ShadowPlugins) IsShadow(org, repo, plugin string) bool {
+	for _, key := range []string{org + "/" + repo, org} {
+		for _, name := range s[key] {
+			if name == plugin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate checks the names of the shadow plugins
+func (s ShadowPlugins) Validate() error {
+	for key, names := range s {
+		for _, name := range names {
+			if strings.TrimSpace(name) == "" {
+				return errors.Errorf("empty shadow plugin name for %s", key)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadSettings parses the lighthouse specific plugin settings of the plugins.yaml data
+func LoadSettings(data []byte) (*Settings, error) {
+	s := &Settings{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the lighthouse plugin settings")
+	}
+	if err := s.PathLabels.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid path_labels")
+	}
+	if err := s.ApproveRequirements.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid approve_requirements")
+	}
+	if err := s.TrustPolicies.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid trust_policies")
+	}
+	if err := s.ShadowPlugins.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid shadow_plugins")
+	}
+	return s, nil
+}
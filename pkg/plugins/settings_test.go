@@ -0,0 +1,149 @@
+package plugins
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testPathLabels = PathLabels{
+	"org": {
+		{Label: "area/docs", Paths: []string{"docs/**", "*.md"}},
+	},
+	"org/repo": {
+		{Label: "area/helm", Paths: []string{"charts/**"}},
+		{Label: "area/api", Paths: []string{"pkg/**/types.go"}},
+	},
+}
+
+func TestLabelsFor(t *testing.T) {
+	testcases := []struct {
+		name     string
+		org      string
+		files    []string
+		expected []string
+	}{
+		{
+			name:     "no match",
+			org:      "org",
+			files:    []string{"main.go", "chartsfoo/values.yaml", "charts", "pkg/types.go.orig"},
+			expected: []string{},
+		},
+		{
+			name:     "dir and pattern matches",
+			org:      "org",
+			files:    []string{"charts/lighthouse/values.yaml", "pkg/apis/lighthouse/v1alpha1/types.go"},
+			expected: []string{"area/api", "area/helm"},
+		},
+		{
+			name:     "double star matches no directory",
+			org:      "org",
+			files:    []string{"pkg/types.go"},
+			expected: []string{"area/api"},
+		},
+		{
+			name:     "patterns without a directory match at any depth",
+			org:      "org",
+			files:    []string{"pkg/plugins/README.md"},
+			expected: []string{"area/docs"},
+		},
+		{
+			name:     "org labels only apply to their org",
+			org:      "another",
+			files:    []string{"docs/index.md"},
+			expected: []string{},
+		},
+	}
+	for _, tc := range testcases {
+		labels := testPathLabels.LabelsFor(tc.org, "repo", tc.files).List()
+		if !reflect.DeepEqual(tc.expected, labels) {
+			t.Errorf("[%s] expected labels %q, got %q", tc.name, tc.expected, labels)
+		}
+	}
+}
+
+func TestValidatePathLabels(t *testing.T) {
+	if err := testPathLabels.Validate(); err != nil {
+		t.Errorf("unexpected error validating the path labels: %v", err)
+	}
+	for name, pathLabels := range map[string]PathLabels{
+		"missing label": {"org": {{Paths: []string{"docs/**"}}}},
+		"missing paths": {"org": {{Label: "area/docs"}}},
+		"invalid path":  {"org": {{Label: "area/docs", Paths: []string{"docs/[a/**"}}}},
+	} {
+		if err := pathLabels.Validate(); err == nil {
+			t.Errorf("[%s] expected an error validating the path labels", name)
+		}
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	s, err := LoadSettings([]byte("plugins:\n  org/repo: [path-label]\npath_labels:\n  org/repo:\n  - label: area/helm\n    paths: [\"charts/**\"]\n"))
+	if err != nil {
+		t.Fatalf("unexpected error loading the settings: %v", err)
+	}
+	expected := PathLabels{"org/repo": {{Label: "area/helm", Paths: []string{"charts/**"}}}}
+	if !reflect.DeepEqual(expected, s.PathLabels) {
+		t.Errorf("expected path labels %v, got %v", expected, s.PathLabels)
+	}
+
+	if _, err := LoadSettings([]byte("path_labels:\n  org/repo:\n  - label: area/helm\n")); err == nil {
+		t.Error("expected an error loading path labels without paths")
+	}
+}
+
+func TestApproveRequirementsFor(t *testing.T) {
+	s, err := LoadSettings([]byte("approve_requirements:\n  org:\n    min_approvers: 2\n  org/repo:\n    min_approvers: 3\n    distinct_owners: true\n"))
+	if err != nil {
+		t.Fatalf("unexpected error loading the settings: %v", err)
+	}
+	for repo, expected := range map[string]ApproveRequirement{
+		"repo":    {MinApprovers: 3, DistinctOwners: true},
+		"another": {MinApprovers: 2},
+	} {
+		if actual := s.ApproveRequirements.For("org", repo); actual != expected {
+			t.Errorf("expected the approve requirement %+v for org/%s, got %+v", expected, repo, actual)
+		}
+	}
+	if actual := s.ApproveRequirements.For("other", "repo"); actual != (ApproveRequirement{}) {
+		t.Errorf("expected no approve requirement for other/repo, got %+v", actual)
+	}
+
+	if _, err := LoadSettings([]byte("approve_requirements:\n  org:\n    min_approvers: -1\n")); err == nil {
+		t.Error("expected an error loading a negative number of approvers")
+	}
+}
+
+func TestTrustPolicies(t *testing.T) {
+	s, err := LoadSettings([]byte(`
+trust_policies:
+  org:
+    org_members: true
+  org/repo:
+    collaborators: true
+    teams: [leads, admins]
+    allowlist_file: .lighthouse/TRUSTED_USERS
+    instructions: Ask in #contributors to join the contributors team.
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	policy := s.TrustPolicies.For("org", "repo")
+	if policy.Instructions != "Ask in #contributors to join the contributors team." {
+		t.Errorf("unexpected instructions %q", policy.Instructions)
+	}
+	expected := "the collaborators of the repository, the members of the leads, admins teams of the org org or the users listed in `.lighthouse/TRUSTED_USERS`"
+	if actual := policy.Describe("org", ""); actual != expected {
+		t.Errorf("expected %q but got %q", expected, actual)
+	}
+	policy = s.TrustPolicies.For("org", "other")
+	if expected := "the members of the org and partner orgs"; policy.Describe("org", "partner") != expected {
+		t.Errorf("expected %q but got %q", expected, policy.Describe("org", "partner"))
+	}
+	if policy = s.TrustPolicies.For("another", "repo"); !policy.IsEmpty() {
+		t.Errorf("expected no trust policy for another org but got %#v", policy)
+	}
+
+	if _, err := LoadSettings([]byte("trust_policies:\n  org:\n    allowlist_file: ../TRUSTED\n")); err == nil {
+		t.Error("expected an error for an allowlist outside the repository")
+	}
+}
@@ -0,0 +1,97 @@
+package plugins
+
+import (
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	lighthouseclient "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/typed/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/launcher"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ShadowMiddleware runs the shadow plugins of the repository of the invocation in shadow mode
+func ShadowMiddleware(next InvocationHandler) InvocationHandler {
+	return func(inv Invocation) error {
+		if inv.Agent != nil && inv.Agent.PluginSettings != nil && inv.Agent.PluginSettings.ShadowPlugins.IsShadow(inv.Org, inv.Repo, inv.Plugin) {
+			inv.Agent.EnableShadowMode(inv.Plugin)
+		}
+		return next(inv)
+	}
+}
+
+// EnableShadowMode makes the agent only log the changes the plugin would make to the git provider and the
+// jobs it would launch or modify, rather than making them. The changes made with the Kubernetes and git
+// clients, such as local clones, are still made.
+func (a *Agent) EnableShadowMode(plugin string) {
+	logger := a.Logger
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	logger = logger.WithField("shadow", true)
+	a.Logger = logger
+	if a.SCMProviderClient != nil {
+		a.SCMProviderClient.SetShadowPlugin(plugin)
+	}
+	if a.LauncherClient != nil {
+		a.LauncherClient = &shadowLauncher{logger: logger}
+	}
+	if a.LighthouseClient != nil {
+		a.LighthouseClient = &shadowLighthouseJobs{LighthouseJobInterface: a.LighthouseClient, logger: logger}
+	}
+}
+
+// shadowLauncher logs the jobs a shadow plugin would launch
+type shadowLauncher struct {
+	logger *logrus.Entry
+}
+
+// Launch logs the job and returns it as if it was launched
+func (l *shadowLauncher) Launch(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	l.logger.WithFields(logrus.Fields{"job": request.Spec.Job, "type": request.Spec.Type, "repo": repository.FullName}).Info("shadow plugin: not launching the job")
+	return request, nil
+}
+
+var _ launcher.PipelineLauncher = &shadowLauncher{}
+
+// shadowLighthouseJobs reads the LighthouseJobs and logs the changes a shadow plugin would make to them
+type shadowLighthouseJobs struct {
+	lighthouseclient.LighthouseJobInterface
+	logger *logrus.Entry
+}
+
+// Create logs the job and returns it as if it was created
+func (c *shadowLighthouseJobs) Create(job *v1alpha1.LighthouseJob) (*v1alpha1.LighthouseJob, error) {
+	c.logger.WithField("job", job.Spec.Job).Info("shadow plugin: not creating the LighthouseJob")
+	return job, nil
+}
+
+// Update logs the job and returns it as if it was updated
+func (c *shadowLighthouseJobs) Update(job *v1alpha1.LighthouseJob) (*v1alpha1.LighthouseJob, error) {
+	c.logger.WithField("name", job.Name).Info("shadow plugin: not updating the LighthouseJob")
+	return job, nil
+}
+
+// UpdateStatus logs the status of the job and returns it as if it was updated
+func (c *shadowLighthouseJobs) UpdateStatus(job *v1alpha1.LighthouseJob) (*v1alpha1.LighthouseJob, error) {
+	c.logger.WithFields(logrus.Fields{"name": job.Name, "state": job.Status.State}).Info("shadow plugin: not updating the status of the LighthouseJob")
+	return job, nil
+}
+
+// Delete logs the name of the job instead of deleting it
+func (c *shadowLighthouseJobs) Delete(name string, options *metav1.DeleteOptions) error {
+	c.logger.WithField("name", name).Info("shadow plugin: not deleting the LighthouseJob")
+	return nil
+}
+
+// DeleteCollection logs the selector of the jobs instead of deleting them
+func (c *shadowLighthouseJobs) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	c.logger.WithField("selector", listOptions.LabelSelector).Info("shadow plugin: not deleting the LighthouseJobs")
+	return nil
+}
+
+// Patch logs the patch and returns the current job as if it was patched
+func (c *shadowLighthouseJobs) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*v1alpha1.LighthouseJob, error) {
+	c.logger.WithFields(logrus.Fields{"name": name, "patch": string(data)}).Info("shadow plugin: not patching the LighthouseJob")
+	return c.Get(name, metav1.GetOptions{})
+}
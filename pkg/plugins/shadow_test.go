@@ -0,0 +1,70 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recordingLauncher records the jobs it launches
+type recordingLauncher struct {
+	launched []string
+}
+
+func (l *recordingLauncher) Launch(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	l.launched = append(l.launched, request.Spec.Job)
+	return request, nil
+}
+
+func TestShadowMiddleware(t *testing.T) {
+	existing := &v1alpha1.LighthouseJob{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "jx"}}
+	lhClient := fake.NewSimpleClientset(existing)
+	settings, err := LoadSettings([]byte(`
+shadow_plugins:
+  org:
+  - size
+  org/repo:
+  - trigger
+`))
+	require.NoError(t, err)
+
+	invoke := func(plugin, repo string) *recordingLauncher {
+		l := &recordingLauncher{}
+		agent := &Agent{
+			LauncherClient:   l,
+			LighthouseClient: lhClient.LighthouseV1alpha1().LighthouseJobs("jx"),
+			PluginSettings:   settings,
+		}
+		err := ShadowMiddleware(func(inv Invocation) error {
+			job := &v1alpha1.LighthouseJob{ObjectMeta: metav1.ObjectMeta{Name: plugin + "-" + repo}, Spec: v1alpha1.LighthouseJobSpec{Job: "unit"}}
+			if _, err := inv.Agent.LauncherClient.Launch(job, scm.Repository{FullName: "org/" + repo}); err != nil {
+				return err
+			}
+			_, err := inv.Agent.LighthouseClient.Create(job)
+			return err
+		})(Invocation{Plugin: plugin, Org: "org", Repo: repo, Agent: agent})
+		require.NoError(t, err)
+		return l
+	}
+
+	assert.Empty(t, invoke("trigger", "repo").launched, "shadow plugin of the repository")
+	assert.Empty(t, invoke("size", "other").launched, "shadow plugin of the org")
+	assert.Equal(t, []string{"unit"}, invoke("trigger", "other").launched)
+
+	jobs, err := lhClient.LighthouseV1alpha1().LighthouseJobs("jx").List(metav1.ListOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, job := range jobs.Items {
+		names = append(names, job.Name)
+	}
+	assert.ElementsMatch(t, []string{"existing", "trigger-other"}, names, "the shadow plugins do not create jobs")
+	assert.False(t, settings.ShadowPlugins.IsShadow("other", "repo", "size"))
+
+	_, err = LoadSettings([]byte("shadow_plugins:\n  org:\n  - \"\"\n"))
+	assert.Error(t, err)
+}
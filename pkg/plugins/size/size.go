@@ -51,7 +51,7 @@ func init() {
 func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
 	sizes := sizesOrDefault(config.Size)
 	return &pluginhelp.PluginHelp{
-			Description: "The size plugin manages the 'size/*' labels, maintaining the appropriate label on each pull request as it is updated. Generated files identified by the config file '.generated_files' at the repo root are ignored. Labels are applied based on the total number of lines of changes (additions and deletions).",
+			Description: "The size plugin manages the 'size/*' labels, maintaining the appropriate label on each pull request as it is updated. Generated files identified by the config file '.generated_files' at the repo root, or marked as 'linguist-generated' in its '.gitattributes', are ignored. Labels are applied based on the total number of lines of changes (additions and deletions).",
 			Config: map[string]string{
 				"": fmt.Sprintf(`The plugin has the following thresholds:<ul>
 <li>size/XS:  0-%d</li>
@@ -110,8 +110,12 @@ func handlePR(spc scmProviderClient, sizes plugins.Size, le *logrus.Entry, pe sc
 
 	var count int
 	for _, change := range changes {
-		// Skip generated and linguist-generated files.
-		if gf != nil && ga != nil && (gf.Match(change.Path) || ga.IsLinguistGenerated(change.Path)) {
+		// Skip generated and linguist-generated files, each config being honoured even if the other one
+		// could not be loaded.
+		if gf != nil && gf.Match(change.Path) {
+			continue
+		}
+		if ga != nil && ga.IsLinguistGenerated(change.Path) {
 			continue
 		}
 
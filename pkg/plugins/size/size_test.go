@@ -281,6 +281,53 @@ func TestHandlePR(t *testing.T) {
 			},
 			sizes: defaultSizes,
 		},
+		{
+			name: "simple size/M, with .gitattributes and an invalid .generated_files",
+			client: &spc{
+				labels: map[scm.Label]bool{},
+				files: map[string][]byte{
+					".generated_files": []byte(`
+						unknown-command foobar
+					`),
+					".gitattributes": []byte(`
+						generated/**/*.txt linguist-generated=true
+					`),
+				},
+				prChanges: []*scm.Change{
+					{
+						Sha:       "abcd",
+						Path:      "barfoo",
+						Additions: 50,
+						Deletions: 0,
+						Changes:   50,
+					},
+					{
+						Sha:       "abcd",
+						Path:      "generated/my/file.txt",
+						Additions: 300,
+						Deletions: 0,
+						Changes:   300,
+					},
+				},
+			},
+			event: scm.PullRequestHook{
+				Action: scm.ActionOpen,
+				PullRequest: scm.PullRequest{
+					Number: 101,
+					Base: scm.PullRequestBranch{
+						Sha: "abcd",
+						Repo: scm.Repository{
+							Namespace: "kubernetes",
+							Name:      "kubernetes",
+						},
+					},
+				},
+			},
+			finalLabels: []*scm.Label{
+				{Name: "size/M"},
+			},
+			sizes: defaultSizes,
+		},
 		{
 			name: "simple size/XS, with .generated_files and paths-from-repo",
 			client: &spc{
@@ -24,15 +24,21 @@ import (
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/errorutil"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
 	"github.com/jenkins-x/lighthouse/pkg/pluginhelp"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
 	"github.com/jenkins-x/lighthouse/pkg/plugins/trigger"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"github.com/sirupsen/logrus"
 )
 
 const pluginName = "skip"
 
+// scmErrorMessage is the message commented instead of the errors of the git provider, which are logged
+const scmErrorMessage = "the git provider request failed"
+
 var (
 	skipRe = regexp.MustCompile(`(?mi)^/(?:lh-)?skip\s*$`)
 )
@@ -66,10 +72,10 @@ func helpProvider(config *plugins.Configuration, enabledRepos []string) (*plugin
 
 func handleGenericComment(pc plugins.Agent, e scmprovider.GenericCommentEvent) error {
 	honorOkToTest := trigger.HonorOkToTest(pc.PluginConfig.TriggerFor(e.Repo.Namespace, e.Repo.Name))
-	return handle(pc.SCMProviderClient, pc.Logger, &e, pc.Config.GetPresubmits(e.Repo), honorOkToTest)
+	return handle(pc.SCMProviderClient, pc.Logger, &e, jobutil.Presubmits(pc.Config, e.Repo), pc.Settings, honorOkToTest)
 }
 
-func handle(spc scmProviderClient, log *logrus.Entry, e *scmprovider.GenericCommentEvent, presubmits []config.Presubmit, honorOkToTest bool) error {
+func handle(spc scmProviderClient, log *logrus.Entry, e *scmprovider.GenericCommentEvent, presubmits []config.Presubmit, s *settings.Config, honorOkToTest bool) error {
 	if !e.IsPR || e.IssueState != "open" || e.Action != scm.ActionCreate {
 		return nil
 	}
@@ -84,15 +90,15 @@ func handle(spc scmProviderClient, log *logrus.Entry, e *scmprovider.GenericComm
 
 	pr, err := spc.GetPullRequest(org, repo, number)
 	if err != nil {
-		resp := fmt.Sprintf("Cannot get PR #%d in %s/%s: %v", number, org, repo, err)
-		log.Warn(resp)
+		resp := fmt.Sprintf("Cannot get PR #%d in %s/%s: %s", number, org, repo, errorutil.UserMessage(err, scmErrorMessage))
+		log.WithError(err).Warn(resp)
 		return spc.CreateComment(org, repo, number, e.IsPR, plugins.FormatResponseRaw(e.Body, e.Link, spc.QuoteAuthorForComment(e.Author.Login), resp))
 	}
 
 	combinedStatus, err := spc.GetCombinedStatus(org, repo, pr.Head.Sha)
 	if err != nil {
-		resp := fmt.Sprintf("Cannot get combined commit statuses for PR #%d in %s/%s: %v", number, org, repo, err)
-		log.Warn(resp)
+		resp := fmt.Sprintf("Cannot get combined commit statuses for PR #%d in %s/%s: %s", number, org, repo, errorutil.UserMessage(err, scmErrorMessage))
+		log.WithError(err).Warn(resp)
 		return spc.CreateComment(org, repo, number, e.IsPR, plugins.FormatResponseRaw(e.Body, e.Link, spc.QuoteAuthorForComment(e.Author.Login), resp))
 	}
 	if combinedStatus.State == scm.StateSuccess {
@@ -100,10 +106,10 @@ func handle(spc scmProviderClient, log *logrus.Entry, e *scmprovider.GenericComm
 	}
 	statuses := combinedStatus.Statuses
 
-	filteredPresubmits, _, err := trigger.FilterPresubmits(honorOkToTest, spc, e.Body, pr, presubmits, log)
+	filteredPresubmits, _, err := trigger.FilterPresubmits(honorOkToTest, spc, e.Body, pr, presubmits, s, log)
 	if err != nil {
-		resp := fmt.Sprintf("Cannot get combined status for PR #%d in %s/%s: %v", number, org, repo, err)
-		log.Warn(resp)
+		resp := fmt.Sprintf("Cannot get combined status for PR #%d in %s/%s: %s", number, org, repo, errorutil.UserMessage(err, scmErrorMessage))
+		log.WithError(err).Warn(resp)
 		return spc.CreateComment(org, repo, number, e.IsPR, plugins.FormatResponseRaw(e.Body, e.Link, spc.QuoteAuthorForComment(e.Author.Login), resp))
 	}
 	triggerWillHandle := func(p config.Presubmit) bool {
@@ -140,8 +146,8 @@ func handle(spc scmProviderClient, log *logrus.Entry, e *scmprovider.GenericComm
 			Label: context,
 		}
 		if _, err := spc.CreateStatus(org, repo, pr.Head.Sha, status); err != nil {
-			resp := fmt.Sprintf("Cannot update PR status for context %s: %v", context, err)
-			log.Warn(resp)
+			resp := fmt.Sprintf("Cannot update PR status for context %s: %s", context, errorutil.UserMessage(err, scmErrorMessage))
+			log.WithError(err).Warn(resp)
 			return spc.CreateComment(org, repo, number, e.IsPR, plugins.FormatResponseRaw(e.Body, e.Link, spc.QuoteAuthorForComment(e.Author.Login), resp))
 		}
 	}
@@ -370,7 +370,7 @@ func TestSkipStatus(t *testing.T) {
 			}
 			l := logrus.WithField("plugin", pluginName)
 
-			if err := handle(fspc, l, test.event, test.presubmits, true); err != nil {
+			if err := handle(fspc, l, test.event, test.presubmits, nil, true); err != nil {
 				t.Fatalf("%s: unexpected error: %v", test.name, err)
 			}
 
@@ -18,6 +18,8 @@ package trigger
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
@@ -26,7 +28,9 @@ import (
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse-config/pkg/labels"
 	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/messages"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -36,15 +40,15 @@ func handleGenericComment(c Client, trigger *plugins.Trigger, gc scmprovider.Gen
 	number := gc.Number
 	commentAuthor := gc.Author.Login
 	// Only take action when a comment is first created,
-	// when it belongs to a PR,
-	// and the PR is open.
-	if gc.Action != scm.ActionCreate || !gc.IsPR || gc.IssueState != "open" {
+	// and when it belongs to a PR.
+	if gc.Action != scm.ActionCreate || !gc.IsPR {
 		return nil
 	}
-	// Skip comments not germane to this plugin
-	if !jobutil.RetestRe.MatchString(gc.Body) && !jobutil.OkToTestRe.MatchString(gc.Body) && !jobutil.TestAllRe.MatchString(gc.Body) {
+	// Skip comments not germane to this plugin. The `/test` commands naming unknown jobs are answered
+	// with the jobs which can be triggered.
+	if !jobutil.RetestRe.MatchString(gc.Body) && !jobutil.OkToTestRe.MatchString(gc.Body) && !jobutil.HasTestCommand(gc.Body) {
 		matched := false
-		for _, presubmit := range c.Config.GetPresubmits(gc.Repo) {
+		for _, presubmit := range jobutil.Presubmits(c.Config, gc.Repo) {
 			matched = matched || presubmit.TriggerMatches(gc.Body)
 			if matched {
 				break
@@ -65,6 +69,10 @@ func handleGenericComment(c Client, trigger *plugins.Trigger, gc scmprovider.Gen
 		c.Logger.Debug("Comment is made by the bot, skipping.")
 		return nil
 	}
+	// Only test open PRs.
+	if gc.IssueState != "open" {
+		return plugins.NewPreconditionError(plugins.SeverityWarning, triggerCommand(gc.Body), "the pull request must be open")
+	}
 
 	pr, err := c.SCMProviderClient.GetPullRequest(org, repo, number)
 	if err != nil {
@@ -72,19 +80,23 @@ func handleGenericComment(c Client, trigger *plugins.Trigger, gc scmprovider.Gen
 	}
 
 	// Skip untrusted users comments.
-	trusted, err := TrustedUser(c.SCMProviderClient, trigger, commentAuthor, org, repo)
+	policy := c.trustPolicy(org, repo)
+	trusted, err := TrustedUser(c.SCMProviderClient, trigger, policy, commentAuthor, org, repo)
 	if err != nil {
 		return fmt.Errorf("error checking trust of %s: %v", commentAuthor, err)
 	}
 	var l []*scm.Label
 	if !trusted {
 		// Skip untrusted PRs.
-		l, trusted, err = TrustedPullRequest(c.SCMProviderClient, trigger, gc.IssueAuthor.Login, org, repo, number, nil)
+		l, trusted, err = TrustedPullRequest(c.SCMProviderClient, trigger, policy, gc.IssueAuthor.Login, org, repo, number, nil)
 		if err != nil {
 			return err
 		}
 		if !trusted {
-			resp := fmt.Sprintf("Cannot trigger testing until a trusted user reviews the PR and leaves an `/ok-to-test` message.")
+			resp := messages.Render(untrustedMessage, untrustedResponse(trigger, policy, org), map[string]interface{}{
+				"TrustedUsers": policy.Describe(org, trigger.TrustedOrg),
+				"Instructions": policy.Instructions,
+			})
 			c.Logger.Infof("Commenting \"%s\".", resp)
 			return c.SCMProviderClient.CreateComment(org, repo, number, true, plugins.FormatResponseRaw(gc.Body, gc.Link, c.SCMProviderClient.QuoteAuthorForComment(gc.Author.Login), resp))
 		}
@@ -111,11 +123,79 @@ func handleGenericComment(c Client, trigger *plugins.Trigger, gc scmprovider.Gen
 		}
 	}
 
-	toTest, toSkip, err := FilterPresubmits(HonorOkToTest(trigger), c.SCMProviderClient, gc.Body, pr, c.Config.GetPresubmits(gc.Repo), c.Logger)
+	presubmits := jobutil.Presubmits(c.Config, gc.Repo)
+	body, unknown := jobutil.ResolveTestCommands(gc.Body, presubmits)
+	if len(unknown) > 0 {
+		resp := unknownJobsResponse(unknown, presubmits, pr.Base.Ref)
+		c.Logger.Infof("Commenting \"%s\".", resp)
+		if err := c.SCMProviderClient.CreateComment(org, repo, number, true, plugins.FormatResponseRaw(gc.Body, gc.Link, c.SCMProviderClient.QuoteAuthorForComment(gc.Author.Login), resp)); err != nil {
+			return err
+		}
+	}
+
+	toTest, toSkip, err := FilterPresubmits(HonorOkToTest(trigger), c.SCMProviderClient, body, pr, presubmits, c.Settings, c.Logger)
 	if err != nil {
 		return err
 	}
-	return RunAndSkipJobs(c, pr, toTest, toSkip, gc.GUID, trigger.ElideSkippedContexts)
+	if len(toTest) == 0 && len(unknown) > 0 {
+		return nil
+	}
+	parameters := map[string]map[string]string{}
+	for _, job := range toTest {
+		params, err := jobutil.ParsePresubmitParameters(job, body)
+		if err != nil {
+			resp := fmt.Sprintf("Cannot trigger %s: %v", job.Name, err)
+			c.Logger.Infof("Commenting \"%s\".", resp)
+			return c.SCMProviderClient.CreateComment(org, repo, number, true, plugins.FormatResponseRaw(gc.Body, gc.Link, c.SCMProviderClient.QuoteAuthorForComment(gc.Author.Login), resp))
+		}
+		if len(params) > 0 {
+			parameters[job.Name] = params
+		}
+	}
+	return RunAndSkipJobs(c, pr, toTest, toSkip, parameters, gc.GUID, trigger.ElideSkippedContexts)
+}
+
+// unknownJobsResponse returns the reply to the `/test` commands naming unknown jobs, listing the jobs which
+// can be triggered on the branch
+func unknownJobsResponse(unknown []jobutil.UnknownJob, presubmits []config.Presubmit, branch string) string {
+	var commands []string
+	for _, p := range presubmits {
+		if !p.Brancher.ShouldRun(branch) {
+			continue
+		}
+		command := p.RerunCommand
+		if command == "" {
+			command = "/test " + p.Name
+		}
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	return messages.Render(unknownJobsMessage, unknownJobsTemplate, map[string]interface{}{
+		"Unknown":  unknown,
+		"Commands": commands,
+	})
+}
+
+// triggerCommand returns the first command of the comment, for the replies to it
+func triggerCommand(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "/") {
+			return line
+		}
+	}
+	return "/test"
+}
+
+// untrustedResponse tells the untrusted users who can have their PR tested
+func untrustedResponse(trigger *plugins.Trigger, policy plugins.TrustPolicy, org string) string {
+	resp := "Cannot trigger testing until a trusted user reviews the PR and leaves an `/ok-to-test` message."
+	if trustedUsers := policy.Describe(org, trigger.TrustedOrg); trustedUsers != "" {
+		resp = fmt.Sprintf("Cannot trigger testing until one of %s reviews the PR and leaves an `/ok-to-test` message.", trustedUsers)
+	}
+	if policy.Instructions != "" {
+		resp += "\n\n" + policy.Instructions
+	}
+	return resp
 }
 
 // HonorOkToTest checks if shoudn't ignore the ok test
@@ -144,7 +224,7 @@ type SCMProviderClient interface {
 // If a comment that we get matches more than one of the above patterns, we
 // consider the set of matching presubmits the union of the results from the
 // matching cases.
-func FilterPresubmits(honorOkToTest bool, scmClient SCMProviderClient, body string, pr *scm.PullRequest, presubmits []config.Presubmit, logger *logrus.Entry) ([]config.Presubmit, []config.Presubmit, error) {
+func FilterPresubmits(honorOkToTest bool, scmClient SCMProviderClient, body string, pr *scm.PullRequest, presubmits []config.Presubmit, s *settings.Config, logger *logrus.Entry) ([]config.Presubmit, []config.Presubmit, error) {
 	org, repo, sha := pr.Base.Repo.Namespace, pr.Base.Repo.Name, pr.Head.Sha
 
 	contextGetter := func() (sets.String, sets.String, error) {
@@ -163,7 +243,7 @@ func FilterPresubmits(honorOkToTest bool, scmClient SCMProviderClient, body stri
 
 	number, branch := pr.Number, pr.Base.Ref
 	changes := config.NewGitHubDeferredChangedFilesProvider(scmClient, org, repo, number)
-	return jobutil.FilterPresubmits(filter, changes, branch, presubmits, logger)
+	return jobutil.FilterPresubmits(filter, changes, branch, presubmits, s, logger)
 }
 
 func getContexts(combinedStatus *scm.CombinedStatus) (sets.String, sets.String) {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/jenkins-x/go-scm/scm"
@@ -60,6 +61,7 @@ type testcase struct {
 	IssueLabels          []string
 	IgnoreOkToTest       bool
 	ElideSkippedContexts bool
+	FailedPrecondition   bool
 }
 
 func TestHandleGenericComment(t *testing.T) {
@@ -81,6 +83,8 @@ func TestHandleGenericComment(t *testing.T) {
 			State:       "closed",
 			IsPR:        true,
 			ShouldBuild: false,
+
+			FailedPrecondition: true,
 		},
 		{
 			name: "Comment by a bot.",
@@ -224,6 +228,35 @@ func TestHandleGenericComment(t *testing.T) {
 			IsPR:        true,
 			ShouldBuild: true,
 		},
+		{
+			name: "Trusted member's test of a job name in another case.",
+
+			Author:        "trusted-member",
+			Body:          "/test JIB",
+			State:         "open",
+			IsPR:          true,
+			ShouldBuild:   true,
+			StartsExactly: "pull-jib",
+		},
+		{
+			name: "Trusted member's test of a misspelled job name.",
+
+			Author:        "trusted-member",
+			Body:          "/lh-test jjib",
+			State:         "open",
+			IsPR:          true,
+			ShouldBuild:   true,
+			StartsExactly: "pull-jib",
+		},
+		{
+			name: "Trusted member's test of an ambiguous job name.",
+
+			Author:      "trusted-member",
+			Body:        "/test jeb",
+			State:       "open",
+			IsPR:        true,
+			ShouldBuild: false,
+		},
 		{
 			name: "Wrong branch.",
 
@@ -936,13 +969,19 @@ func TestHandleGenericComment(t *testing.T) {
 			// In some cases handleGenericComment can be called twice for the same event.
 			// For instance on Issue/PR creation and modification.
 			// Let's call it twice to ensure idempotency.
-			if err := handleGenericComment(c, trigger, event); err != nil {
-				t.Fatalf("%s: didn't expect error: %s", tc.name, err)
+			handle := func() {
+				err := handleGenericComment(c, trigger, event)
+				if tc.FailedPrecondition {
+					if _, ok := plugins.AsPreconditionError(err); !ok {
+						t.Fatalf("%s: expected a failed precondition, got: %v", tc.name, err)
+					}
+				} else if err != nil {
+					t.Fatalf("%s: didn't expect error: %s", tc.name, err)
+				}
 			}
+			handle()
 			validate(tc.name, fakeLauncher, g, tc, t)
-			if err := handleGenericComment(c, trigger, event); err != nil {
-				t.Fatalf("%s: didn't expect error: %s", tc.name, err)
-			}
+			handle()
 			validate(tc.name, fakeLauncher, g, tc, t)
 		})
 	}
@@ -1067,3 +1106,75 @@ func TestRetestFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleGenericCommentUnknownJob(t *testing.T) {
+	g := &fake2.SCMClient{
+		IssueComments:       map[int][]*scm.Comment{},
+		PullRequestComments: map[int][]*scm.Comment{},
+		OrgMembers:          map[string][]string{"org": {"trusted-member"}},
+		PullRequests: map[int]*scm.PullRequest{
+			0: {
+				Number: 0,
+				Head:   scm.PullRequestBranch{Sha: "cafe"},
+				Base: scm.PullRequestBranch{
+					Ref:  "master",
+					Repo: scm.Repository{Namespace: "org", Name: "repo"},
+				},
+			},
+		},
+		CombinedStatuses: map[string]*scm.CombinedStatus{"cafe": {}},
+	}
+	fakeLauncher := fake.NewLauncher()
+	c := Client{
+		SCMProviderClient: g,
+		LauncherClient:    fakeLauncher,
+		Config:            &config.Config{ProwConfig: config.ProwConfig{LighthouseJobNamespace: "lighthouseJobs"}},
+		Logger:            logrus.WithField("plugin", PluginName),
+	}
+	err := c.Config.SetPresubmits(map[string][]config.Presubmit{
+		"org/repo": {
+			{
+				JobBase:      config.JobBase{Name: "unit"},
+				Reporter:     config.Reporter{Context: "pull-unit"},
+				Trigger:      `(?m)^/test (?:.*? )?unit(?: .*?)?$`,
+				RerunCommand: "/test unit",
+			},
+			{
+				JobBase:      config.JobBase{Name: "lint"},
+				Reporter:     config.Reporter{Context: "pull-lint"},
+				Trigger:      `(?m)^/test (?:.*? )?lint(?: .*?)?$`,
+				RerunCommand: "/test lint",
+				Brancher:     config.Brancher{Branches: []string{"release"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to set presubmits: %v", err)
+	}
+	event := scmprovider.GenericCommentEvent{
+		Action:     scm.ActionCreate,
+		Repo:       scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo"},
+		Body:       "/test e2e",
+		Author:     scm.User{Login: "trusted-member"},
+		IssueState: "open",
+		IsPR:       true,
+	}
+	if err := handleGenericComment(c, &plugins.Trigger{}, event); err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+	if len(fakeLauncher.Pipelines) > 0 {
+		t.Errorf("expected no job to be triggered, got %d", len(fakeLauncher.Pipelines))
+	}
+	comments := g.PullRequestComments[0]
+	if len(comments) != 1 {
+		t.Fatalf("expected a reply listing the jobs, got %d comments", len(comments))
+	}
+	for _, expected := range []string{"There is no job named `e2e`.", "- `/test all`", "- `/test unit`"} {
+		if !strings.Contains(comments[0].Body, expected) {
+			t.Errorf("expected the reply to contain %q, got %q", expected, comments[0].Body)
+		}
+	}
+	if strings.Contains(comments[0].Body, "/test lint") {
+		t.Errorf("expected the reply not to list the jobs of other branches, got %q", comments[0].Body)
+	}
+}
@@ -19,30 +19,33 @@ package trigger
 import (
 	"fmt"
 	"net/url"
+	"strings"
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse-config/pkg/labels"
 	"github.com/jenkins-x/lighthouse/pkg/errorutil"
 	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/messages"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 )
 
 func handlePR(c Client, trigger *plugins.Trigger, pr scm.PullRequestHook) error {
-	if len(c.Config.GetPresubmits(pr.PullRequest.Base.Repo)) == 0 {
+	if len(jobutil.Presubmits(c.Config, pr.PullRequest.Base.Repo)) == 0 {
 		return nil
 	}
 
 	org, repo, a := orgRepoAuthor(pr.PullRequest)
 	author := string(a)
 	num := pr.PullRequest.Number
+	policy := c.trustPolicy(org, repo)
 	switch pr.Action {
 	case scm.ActionOpen:
 		// When a PR is opened, if the author is in the org then build it.
 		// Otherwise, ask for "/ok-to-test". There's no need to look for previous
 		// "/ok-to-test" comments since the PR was just opened!
-		member, err := TrustedUser(c.SCMProviderClient, trigger, author, org, repo)
+		member, err := TrustedUser(c.SCMProviderClient, trigger, policy, author, org, repo)
 		if err != nil {
 			return fmt.Errorf("could not check membership: %s", err)
 		}
@@ -51,13 +54,13 @@ func handlePR(c Client, trigger *plugins.Trigger, pr scm.PullRequestHook) error
 			return buildAll(c, &pr.PullRequest, pr.GUID, trigger.ElideSkippedContexts)
 		}
 		c.Logger.Infof("Author is not a member, Welcome message to PR author %q.", author)
-		if err := welcomeMsg(c.SCMProviderClient, trigger, pr.PullRequest); err != nil {
+		if err := welcomeMsg(c.SCMProviderClient, trigger, policy, pr.PullRequest); err != nil {
 			return fmt.Errorf("could not welcome non-org member %q: %v", author, err)
 		}
 	case scm.ActionReopen:
 		// When a PR is reopened, check that the user is in the org or that an org
 		// member had said "/ok-to-test" before building, resulting in label ok-to-test.
-		l, trusted, err := TrustedPullRequest(c.SCMProviderClient, trigger, author, org, repo, num, nil)
+		l, trusted, err := TrustedPullRequest(c.SCMProviderClient, trigger, policy, author, org, repo, num, nil)
 		if err != nil {
 			return fmt.Errorf("could not validate PR: %s", err)
 		} else if trusted {
@@ -85,7 +88,7 @@ func handlePR(c Client, trigger *plugins.Trigger, pr scm.PullRequestHook) error
 	case scm.ActionLabel:
 		// When a PR is LGTMd, if it is untrusted then build it once.
 		if pr.Label.Name == labels.LGTM {
-			_, trusted, err := TrustedPullRequest(c.SCMProviderClient, trigger, author, org, repo, num, nil)
+			_, trusted, err := TrustedPullRequest(c.SCMProviderClient, trigger, policy, author, org, repo, num, nil)
 			if err != nil {
 				return fmt.Errorf("could not validate PR: %s", err)
 			} else if !trusted {
@@ -115,7 +118,7 @@ func buildAllIfTrusted(c Client, trigger *plugins.Trigger, pr scm.PullRequestHoo
 	org, repo, a := orgRepoAuthor(pr.PullRequest)
 	author := string(a)
 	num := pr.PullRequest.Number
-	l, trusted, err := TrustedPullRequest(c.SCMProviderClient, trigger, author, org, repo, num, nil)
+	l, trusted, err := TrustedPullRequest(c.SCMProviderClient, trigger, c.trustPolicy(org, repo), author, org, repo, num, nil)
 	if err != nil {
 		return fmt.Errorf("could not validate PR: %s", err)
 	} else if trusted {
@@ -132,7 +135,7 @@ func buildAllIfTrusted(c Client, trigger *plugins.Trigger, pr scm.PullRequestHoo
 	return nil
 }
 
-func welcomeMsg(spc scmProviderClient, trigger *plugins.Trigger, pr scm.PullRequest) error {
+func welcomeMsg(spc scmProviderClient, trigger *plugins.Trigger, policy plugins.TrustPolicy, pr scm.PullRequest) error {
 	var errors []error
 	org, repo, a := orgRepoAuthor(pr)
 	author := string(a)
@@ -149,6 +152,7 @@ func welcomeMsg(spc scmProviderClient, trigger *plugins.Trigger, pr scm.PullRequ
 		joinOrgURL = fmt.Sprintf("https://github.com/orgs/%s/people", org)
 	}
 
+	trustedUsers := policy.Describe(org, trigger.TrustedOrg)
 	var comment string
 	if trigger.IgnoreOkToTest {
 		comment = fmt.Sprintf(`Hi @%s. Thanks for your PR.
@@ -176,11 +180,42 @@ I understand the commands that are listed [here](https://go.k8s.io/bot-commands?
 %s
 </details>
 `, author, org, org, more, joinOrgURL, labels.OkToTest, encodedRepoFullName, plugins.AboutThisBotWithoutCommands)
+		if trustedUsers != "" {
+			comment = fmt.Sprintf(`Hi @%s. Thanks for your PR.
+
+I'm waiting for one of %s to verify that this patch is reasonable to test. If it is, they should reply with `+"`/ok-to-test`"+` on its own line. Until that is done, I will not automatically test new commits in this PR, but the usual testing commands by trusted users will still work.
+
+Once the patch is verified, the new status will be reflected by the `+"`%s`"+` label.
+
+I understand the commands that are listed [here](https://go.k8s.io/bot-commands?repo=%s).
+
+<details>
+
+%s
+</details>
+`, author, trustedUsers, labels.OkToTest, encodedRepoFullName, plugins.AboutThisBotWithoutCommands)
+		}
 		if err := spc.AddLabel(org, repo, pr.Number, labels.NeedsOkToTest, true); err != nil {
 			errors = append(errors, err)
 		}
 	}
+	if policy.Instructions != "" {
+		comment = strings.TrimRight(comment, "\n") + "\n\n" + policy.Instructions + "\n"
+	}
 
+	// the catalog overrides the whole greeting, rendered with the same details
+	comment = messages.Render(welcomeUntrustedMessage, comment, map[string]interface{}{
+		"Author":         author,
+		"Org":            org,
+		"Repo":           repo,
+		"TrustedOrg":     trigger.TrustedOrg,
+		"JoinOrgURL":     joinOrgURL,
+		"IgnoreOkToTest": trigger.IgnoreOkToTest,
+		"OkToTestLabel":  labels.OkToTest,
+		"AboutThisBot":   plugins.AboutThisBotWithoutCommands,
+		"TrustedUsers":   trustedUsers,
+		"Instructions":   policy.Instructions,
+	})
 	if err := spc.CreateComment(org, repo, pr.Number, true, comment); err != nil {
 		errors = append(errors, err)
 	}
@@ -193,9 +228,9 @@ I understand the commands that are listed [here](https://go.k8s.io/bot-commands?
 
 // TrustedPullRequest returns whether or not the given PR should be tested.
 // It first checks if the author is in the org, then looks for "ok-to-test" label.
-func TrustedPullRequest(spc scmProviderClient, trigger *plugins.Trigger, author, org, repo string, num int, l []*scm.Label) ([]*scm.Label, bool, error) {
+func TrustedPullRequest(spc scmProviderClient, trigger *plugins.Trigger, policy plugins.TrustPolicy, author, org, repo string, num int, l []*scm.Label) ([]*scm.Label, bool, error) {
 	// First check if the author is a member of the org.
-	if orgMember, err := TrustedUser(spc, trigger, author, org, repo); err != nil {
+	if orgMember, err := TrustedUser(spc, trigger, policy, author, org, repo); err != nil {
 		return l, false, fmt.Errorf("error checking %s for trust: %v", author, err)
 	} else if orgMember {
 		return l, true, nil
@@ -215,9 +250,9 @@ func TrustedPullRequest(spc scmProviderClient, trigger *plugins.Trigger, author,
 func buildAll(c Client, pr *scm.PullRequest, eventGUID string, elideSkippedContexts bool) error {
 	org, repo, number, branch := pr.Base.Repo.Namespace, pr.Base.Repo.Name, pr.Number, pr.Base.Ref
 	changes := config.NewGitHubDeferredChangedFilesProvider(c.SCMProviderClient, org, repo, number)
-	toTest, toSkip, err := jobutil.FilterPresubmits(jobutil.TestAllFilter(), changes, branch, c.Config.GetPresubmits(pr.Base.Repo), c.Logger)
+	toTest, toSkip, err := jobutil.FilterPresubmits(jobutil.TestAllFilter(), changes, branch, jobutil.Presubmits(c.Config, pr.Base.Repo), c.Settings, c.Logger)
 	if err != nil {
 		return err
 	}
-	return RunAndSkipJobs(c, pr, toTest, toSkip, eventGUID, elideSkippedContexts)
+	return RunAndSkipJobs(c, pr, toTest, toSkip, nil, eventGUID, elideSkippedContexts)
 }
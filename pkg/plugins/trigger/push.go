@@ -62,8 +62,16 @@ func handlePE(c Client, pe scm.PushHook) error {
 		// we should not trigger jobs for a branch deletion
 		return nil
 	}
-	for _, j := range c.Config.GetPostsubmits(pe.Repo) {
-		branch := scmprovider.PushHookBranch(&pe)
+	branch := scmprovider.PushHookBranch(&pe)
+	if c.Settings != nil && !c.Settings.PostsubmitFilters.ShouldRun(pe.Repo.Namespace, pe.Repo.Name, branch) {
+		c.Logger.WithField("branch", branch).Debug("Skipping the postsubmits of the branch filtered out by the postsubmit filters.")
+		return nil
+	}
+	for _, j := range jobutil.Postsubmits(c.Config, pe.Repo) {
+		if jobutil.IsReleaseJob(j) {
+			// release jobs are triggered by release events
+			continue
+		}
 		if shouldRun, err := j.ShouldRun(branch, listPushEventChanges(pe)); err != nil {
 			return err
 		} else if !shouldRun {
@@ -76,6 +84,7 @@ func handlePE(c Client, pe scm.PushHook) error {
 		}
 		labels[scmprovider.EventGUID] = pe.GUID
 		pj := jobutil.NewLighthouseJob(jobutil.PostsubmitSpec(j, refs), labels, j.Annotations)
+		c.setChanges(&pj.Spec, pe.Repo.Namespace, pe.Repo.Name, listPushEventChanges(pe))
 		c.Logger.WithFields(jobutil.LighthouseJobFields(&pj)).Info("Creating a new LighthouseJob.")
 		if _, err := c.LauncherClient.Launch(&pj, pe.Repository()); err != nil {
 			return err
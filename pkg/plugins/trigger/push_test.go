@@ -17,13 +17,17 @@ limitations under the License.
 package trigger
 
 import (
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
 	"github.com/jenkins-x/lighthouse/pkg/launcher/fake"
 	fake2 "github.com/jenkins-x/lighthouse/pkg/scmprovider/fake"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/util/diff"
@@ -156,6 +160,12 @@ func TestHandlePE(t *testing.T) {
 						Name: "pass-salt",
 					},
 				},
+				{
+					JobBase: config.JobBase{
+						Name:        "publish-salt",
+						Annotations: map[string]string{jobutil.ReleaseJobAnnotation: "true"},
+					},
+				},
 			},
 			"org3/repo3": {
 				{
@@ -175,13 +185,105 @@ func TestHandlePE(t *testing.T) {
 		if err != nil {
 			t.Errorf("test %q: handlePE returned unexpected error %v", tc.name, err)
 		}
+		changes, _ := listPushEventChanges(*tc.pe)()
+		sort.Strings(changes)
 		var numStarted int
 		for _, job := range fakeLauncher.Pipelines {
 			t.Logf("created job with context %s", job.Spec.Context)
 			numStarted++
+			if strings.Join(job.Spec.ChangedPaths, ",") != strings.Join(changes, ",") {
+				t.Errorf("test %q: expected changed paths %v, got %v", tc.name, changes, job.Spec.ChangedPaths)
+			}
 		}
 		if numStarted != tc.jobsToRun {
 			t.Errorf("test %q: expected %d jobs to run, got %d", tc.name, tc.jobsToRun, numStarted)
 		}
 	}
 }
+
+func TestHandlePEPostsubmitFilters(t *testing.T) {
+	s, err := settings.Load([]byte(`
+postsubmitFilters:
+  skipBranches:
+  - ^feature/
+  repos:
+    org/repo:
+      branches:
+      - ^master$
+      - ^v\d+\.\d+\.\d+$
+`))
+	if err != nil {
+		t.Fatalf("failed to load the settings: %v", err)
+	}
+	testCases := []struct {
+		name      string
+		repo      string
+		ref       string
+		jobsToRun int
+	}{
+		{
+			name:      "matching branch",
+			repo:      "repo",
+			ref:       "refs/heads/master",
+			jobsToRun: 1,
+		},
+		{
+			name:      "matching tag",
+			repo:      "repo",
+			ref:       "refs/tags/v1.2.3",
+			jobsToRun: 1,
+		},
+		{
+			name: "branch not matching the filter of the repo",
+			repo: "repo",
+			ref:  "refs/heads/feature/storm",
+		},
+		{
+			name: "skipped branch of the default filter",
+			repo: "other",
+			ref:  "refs/heads/feature/storm",
+		},
+		{
+			name:      "branch of the default filter",
+			repo:      "other",
+			ref:       "refs/heads/develop",
+			jobsToRun: 1,
+		},
+	}
+	for _, tc := range testCases {
+		fakeLauncher := fake.NewLauncher()
+		c := Client{
+			SCMProviderClient: &fake2.SCMClient{},
+			LauncherClient:    fakeLauncher,
+			Config:            &config.Config{ProwConfig: config.ProwConfig{LighthouseJobNamespace: "lighthouseJobs"}},
+			Settings:          s,
+			Logger:            logrus.WithField("plugin", PluginName),
+		}
+		postsubmits := map[string][]config.Postsubmit{
+			"org/" + tc.repo: {
+				{
+					JobBase: config.JobBase{
+						Name: "release",
+					},
+				},
+			},
+		}
+		if err := c.Config.SetPostsubmits(postsubmits); err != nil {
+			t.Fatalf("failed to set postsubmits: %v", err)
+		}
+		pe := scm.PushHook{
+			Ref: tc.ref,
+			Repo: scm.Repository{
+				Namespace: "org",
+				Name:      tc.repo,
+				FullName:  "org/" + tc.repo,
+			},
+		}
+		if err := handlePE(c, pe); err != nil {
+			t.Errorf("test %q: handlePE returned unexpected error %v", tc.name, err)
+		}
+		if numStarted := len(fakeLauncher.Pipelines); numStarted != tc.jobsToRun {
+			t.Errorf("test %q: expected %d jobs to run, got %d", tc.name, tc.jobsToRun, numStarted)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package trigger
+
+import (
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/pkg/errors"
+)
+
+func handleRelease(c Client, re scmprovider.ReleaseHook) error {
+	if re.RawAction != scmprovider.ReleaseActionPublished || re.Release.Draft || re.Release.Tag == "" {
+		return nil
+	}
+	repo := re.Repository()
+	var sha string
+	for _, j := range jobutil.Postsubmits(c.Config, repo) {
+		if !jobutil.IsReleaseJob(j) || !j.Brancher.ShouldRun(re.Release.Tag) {
+			continue
+		}
+		if sha == "" {
+			var err error
+			sha, err = c.SCMProviderClient.GetRef(repo.Namespace, repo.Name, "tags/"+re.Release.Tag)
+			if err != nil {
+				return errors.Wrapf(err, "failed to find the commit of tag %s", re.Release.Tag)
+			}
+		}
+		refs := v1alpha1.Refs{
+			Org:      repo.Namespace,
+			Repo:     repo.Name,
+			BaseRef:  re.Release.Tag,
+			BaseSHA:  sha,
+			BaseLink: re.Release.Link,
+		}
+		release := v1alpha1.Release{
+			Tag:        re.Release.Tag,
+			Name:       re.Release.Name,
+			Link:       re.Release.Link,
+			Prerelease: re.Release.Prerelease,
+		}
+		labels := make(map[string]string)
+		for k, v := range j.Labels {
+			labels[k] = v
+		}
+		pj := jobutil.NewLighthouseJob(jobutil.ReleaseSpec(j, refs, release), labels, j.Annotations)
+		c.Logger.WithFields(jobutil.LighthouseJobFields(&pj)).Info("Creating a new LighthouseJob for release.")
+		if _, err := c.LauncherClient.Launch(&pj, repo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package trigger
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/launcher/fake"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	fake2 "github.com/jenkins-x/lighthouse/pkg/scmprovider/fake"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleRelease(t *testing.T) {
+	repo := scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo"}
+	releaseAnnotations := map[string]string{jobutil.ReleaseJobAnnotation: "true"}
+
+	testCases := []struct {
+		name     string
+		action   string
+		release  scmprovider.Release
+		expected []string
+	}{
+		{
+			name:     "published release",
+			action:   scmprovider.ReleaseActionPublished,
+			release:  scmprovider.Release{Tag: "v1.0.0", Name: "1.0.0", Link: "https://github.com/org/repo/releases/tag/v1.0.0"},
+			expected: []string{"publish", "publish-v1"},
+		},
+		{
+			name:     "published release not matching branches",
+			action:   scmprovider.ReleaseActionPublished,
+			release:  scmprovider.Release{Tag: "v2.0.0"},
+			expected: []string{"publish"},
+		},
+		{
+			name:    "draft release",
+			action:  scmprovider.ReleaseActionPublished,
+			release: scmprovider.Release{Tag: "v1.0.0", Draft: true},
+		},
+		{
+			name:    "deleted release",
+			action:  "deleted",
+			release: scmprovider.Release{Tag: "v1.0.0"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeLauncher := fake.NewLauncher()
+			c := Client{
+				SCMProviderClient: &fake2.SCMClient{},
+				LauncherClient:    fakeLauncher,
+				Config:            &config.Config{ProwConfig: config.ProwConfig{LighthouseJobNamespace: "lighthouseJobs"}},
+				Logger:            logrus.WithField("plugin", PluginName),
+			}
+			postsubmits := map[string][]config.Postsubmit{
+				"org/repo": {
+					{
+						JobBase: config.JobBase{Name: "build"},
+					},
+					{
+						JobBase: config.JobBase{Name: "publish", Annotations: releaseAnnotations},
+					},
+					{
+						JobBase:  config.JobBase{Name: "publish-v1", Annotations: releaseAnnotations},
+						Brancher: config.Brancher{Branches: []string{`^v1\.`}},
+					},
+				},
+			}
+			require.NoError(t, c.Config.SetPostsubmits(postsubmits))
+
+			re := scmprovider.ReleaseHook{
+				ReleaseHook: &scm.ReleaseHook{Repo: repo},
+				RawAction:   tc.action,
+				Release:     tc.release,
+			}
+			require.NoError(t, handleRelease(c, re))
+
+			var started []string
+			for _, job := range fakeLauncher.Pipelines {
+				started = append(started, job.Spec.Job)
+				if assert.NotNil(t, job.Spec.Release) {
+					assert.Equal(t, tc.release.Tag, job.Spec.Release.Tag)
+					assert.Equal(t, tc.release.Tag, job.Spec.Refs.BaseRef)
+					assert.Equal(t, fake2.TestRef, job.Spec.Refs.BaseSHA)
+				}
+			}
+			assert.ElementsMatch(t, tc.expected, started)
+		})
+	}
+}
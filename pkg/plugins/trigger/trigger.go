@@ -24,10 +24,12 @@ import (
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
 	"github.com/jenkins-x/lighthouse/pkg/errorutil"
+	"github.com/jenkins-x/lighthouse/pkg/identity"
 	"github.com/jenkins-x/lighthouse/pkg/jobutil"
 	"github.com/jenkins-x/lighthouse/pkg/pluginhelp"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
@@ -35,12 +37,30 @@ import (
 const (
 	// PluginName is the name of the trigger plugin
 	PluginName = "trigger"
+
+	// welcomeUntrustedMessage is the ID of the greeting of untrusted PR authors in the message catalog
+	welcomeUntrustedMessage = "trigger.welcome-untrusted"
+	// untrustedMessage is the ID of the reply to test commands on untrusted PRs in the message catalog
+	untrustedMessage = "trigger.untrusted"
+	// unknownJobsMessage is the ID of the reply to test commands naming unknown jobs in the message catalog
+	unknownJobsMessage = "trigger.unknown-jobs"
 )
 
+// unknownJobsTemplate is the default reply to test commands naming unknown jobs
+const unknownJobsTemplate = `{{ range .Unknown -}}
+There is no job named ` + "`{{ .Name }}`" + `{{ if .Suggestions }}, did you mean {{ range $i, $s := .Suggestions }}{{ if $i }} or {{ end }}` + "`{{ $s }}`" + `{{ end }}?{{ else }}.{{ end }}
+{{ end }}
+The jobs of this pull request can be triggered with:
+- ` + "`/test all`" + `
+{{- range .Commands }}
+- ` + "`{{ . }}`" + `
+{{- end }}`
+
 func init() {
 	plugins.RegisterGenericCommentHandler(PluginName, handleGenericCommentEvent, helpProvider)
 	plugins.RegisterPullRequestHandler(PluginName, handlePullRequest, helpProvider)
 	plugins.RegisterPushEventHandler(PluginName, handlePush, helpProvider)
+	plugins.RegisterReleaseEventHandler(PluginName, handleReleaseEvent, helpProvider)
 }
 
 func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
@@ -72,15 +92,15 @@ func helpProvider(config *plugins.Configuration, enabledRepos []string) (*plugin
 		Usage:       "/ok-to-test",
 		Description: "Marks a PR as 'trusted' and starts tests.",
 		Featured:    false,
-		WhoCanUse:   "Members of the trusted organization for the repo.",
+		WhoCanUse:   "Members of the trusted organization for the repo, or the users trusted by its trust policy.",
 		Examples:    []string{"/ok-to-test", "/lh-ok-to-test"},
 	})
 	pluginHelp.AddCommand(pluginhelp.Command{
-		Usage:       "/test (<job name>|all)",
-		Description: "Manually starts a/all test job(s).",
+		Usage:       "/test (<job name> [<parameter>=<value>...]|all)",
+		Description: "Manually starts a/all test job(s). Parameters declared by the job in its '" + jobutil.ParametersAnnotation + "' annotation are passed to the pipeline. A job name matching no job is matched to the single job it is close to, e.g. ignoring the case, otherwise the available jobs are listed in a reply.",
 		Featured:    true,
 		WhoCanUse:   "Anyone can trigger this command on a trusted PR.",
-		Examples:    []string{"/test all", "/test pull-bazel-test", "/lh-test all"},
+		Examples:    []string{"/test all", "/test pull-bazel-test", "/test pull-perf-test ITERATIONS=10", "/lh-test all"},
 	})
 	pluginHelp.AddCommand(pluginhelp.Command{
 		Usage:       "/retest",
@@ -108,6 +128,9 @@ type scmProviderClient interface {
 	DeleteStaleComments(org, repo string, number int, comments []*scm.Comment, pr bool, isStale func(*scm.Comment) bool) error
 	GetIssueLabels(org, repo string, number int, pr bool) ([]*scm.Label, error)
 	QuoteAuthorForComment(string) string
+	GetFile(org, repo, filepath, commit string) ([]byte, error)
+	ListTeams(org string) ([]*scm.Team, error)
+	ListTeamMembers(id int, role string) ([]*scm.TeamMember, error)
 }
 
 type launcher interface {
@@ -121,19 +144,78 @@ type Client struct {
 	SCMProviderClient scmProviderClient
 	LauncherClient    launcher
 	Config            *config.Config
+	Settings          *settings.Config
+	PluginSettings    *plugins.Settings
 	Logger            *logrus.Entry
 }
 
+// trustPolicy returns the trust policy of the repository, which is empty if none is configured
+func (c *Client) trustPolicy(org, repo string) plugins.TrustPolicy {
+	if c.PluginSettings == nil {
+		return plugins.TrustPolicy{}
+	}
+	return c.PluginSettings.TrustPolicies.For(org, repo)
+}
+
 type trustedUserClient interface {
 	IsCollaborator(org, repo, user string) (bool, error)
 	IsMember(org, user string) (bool, error)
 	BotName() (string, error)
+	GetFile(org, repo, filepath, commit string) ([]byte, error)
+	ListTeams(org string) ([]*scm.Team, error)
+	ListTeamMembers(id int, role string) ([]*scm.TeamMember, error)
+}
+
+// activeIdentityClient only trusts the collaborators and members whose logins map to active
+// identities of the internal directory
+type activeIdentityClient struct {
+	scmProviderClient
+	mapper identity.Mapper
+}
+
+// IsCollaborator returns false for the users who are not active identities
+func (c *activeIdentityClient) IsCollaborator(org, repo, user string) (bool, error) {
+	if !identity.IsActive(c.mapper, user) {
+		logrus.Infof("User %q is not an active identity", user)
+		return false, nil
+	}
+	return c.scmProviderClient.IsCollaborator(org, repo, user)
+}
+
+// IsMember returns false for the users who are not active identities
+func (c *activeIdentityClient) IsMember(org, user string) (bool, error) {
+	if !identity.IsActive(c.mapper, user) {
+		logrus.Infof("User %q is not an active identity", user)
+		return false, nil
+	}
+	return c.scmProviderClient.IsMember(org, user)
+}
+
+// ListTeamMembers only returns the members who are active identities
+func (c *activeIdentityClient) ListTeamMembers(id int, role string) ([]*scm.TeamMember, error) {
+	members, err := c.scmProviderClient.ListTeamMembers(id, role)
+	if err != nil {
+		return nil, err
+	}
+	var active []*scm.TeamMember
+	for _, member := range members {
+		if identity.IsActive(c.mapper, member.Login) {
+			active = append(active, member)
+		}
+	}
+	return active, nil
 }
 
 func getClient(pc plugins.Agent) Client {
+	var spc scmProviderClient = pc.SCMProviderClient
+	if pc.IdentityMapper != nil {
+		spc = &activeIdentityClient{scmProviderClient: spc, mapper: pc.IdentityMapper}
+	}
 	return Client{
-		SCMProviderClient: pc.SCMProviderClient,
+		SCMProviderClient: spc,
 		Config:            pc.Config,
+		Settings:          pc.Settings,
+		PluginSettings:    pc.PluginSettings,
 		LauncherClient:    pc.LauncherClient,
 		Logger:            pc.Logger,
 	}
@@ -152,16 +234,24 @@ func handlePush(pc plugins.Agent, pe scm.PushHook) error {
 	return handlePE(getClient(pc), pe)
 }
 
+func handleReleaseEvent(pc plugins.Agent, re scmprovider.ReleaseHook) error {
+	return handleRelease(getClient(pc), re)
+}
+
 // TrustedUser returns true if user is trusted in repo.
 //
 // Trusted users are either repo collaborators, org members or trusted org members.
-// Whether repo collaborators and/or a second org is trusted is configured by trigger.
-func TrustedUser(spc trustedUserClient, trigger *plugins.Trigger, user, org, repo string) (bool, error) {
+// Whether repo collaborators and/or a second org is trusted is configured by trigger,
+// unless the repo has a trust policy, which then decides who is trusted.
+func TrustedUser(spc trustedUserClient, trigger *plugins.Trigger, policy plugins.TrustPolicy, user, org, repo string) (bool, error) {
 	botUser, err := spc.BotName()
 	if err == nil && user == botUser {
 		logrus.Infof("User %q is the bot user", user)
 		return true, nil
 	}
+	if !policy.IsEmpty() {
+		return trustedByPolicy(spc, trigger, policy, user, org, repo)
+	}
 	// First check if user is a collaborator, assuming this is allowed
 	if !trigger.OnlyOrgMembers {
 		if ok, err := spc.IsCollaborator(org, repo, user); err != nil {
@@ -196,6 +286,99 @@ func TrustedUser(spc trustedUserClient, trigger *plugins.Trigger, user, org, rep
 	return member, nil
 }
 
+// trustedByPolicy returns true if one of the sources of the trust policy trusts the user
+func trustedByPolicy(spc trustedUserClient, trigger *plugins.Trigger, policy plugins.TrustPolicy, user, org, repo string) (bool, error) {
+	if policy.Collaborators {
+		if ok, err := spc.IsCollaborator(org, repo, user); err != nil {
+			return false, fmt.Errorf("error in IsCollaborator: %v", err)
+		} else if ok {
+			logrus.Infof("User %q is a collaborator of %s/%s", user, org, repo)
+			return true, nil
+		}
+	}
+	if policy.OrgMembers {
+		orgs := []string{org}
+		if trigger.TrustedOrg != "" && trigger.TrustedOrg != org {
+			orgs = append(orgs, trigger.TrustedOrg)
+		}
+		for _, o := range orgs {
+			if member, err := spc.IsMember(o, user); err != nil {
+				return false, fmt.Errorf("error in IsMember(%s): %v", o, err)
+			} else if member {
+				logrus.Infof("User %q is a member of org %q", user, o)
+				return true, nil
+			}
+		}
+	}
+	if len(policy.Teams) > 0 {
+		if member, err := teamMember(spc, policy.Teams, user, org); err != nil {
+			return false, err
+		} else if member {
+			return true, nil
+		}
+	}
+	if policy.AllowlistFile != "" {
+		// a missing or unreadable allowlist trusts nobody rather than blocking the other triggers
+		data, err := spc.GetFile(org, repo, policy.AllowlistFile, "")
+		if err != nil {
+			logrus.WithError(err).Warnf("Cannot read the allowlist %s of %s/%s", policy.AllowlistFile, org, repo)
+			return false, nil
+		}
+		if allowlisted(data, user) {
+			logrus.Infof("User %q is in the allowlist %s of %s/%s", user, policy.AllowlistFile, org, repo)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// teamMember returns true if the user is a member of one of the teams of the org
+func teamMember(spc trustedUserClient, teams []string, user, org string) (bool, error) {
+	orgTeams, err := spc.ListTeams(org)
+	if err != nil {
+		return false, fmt.Errorf("error in ListTeams(%s): %v", org, err)
+	}
+	for _, team := range orgTeams {
+		if !containsFold(teams, team.Name) {
+			continue
+		}
+		members, err := spc.ListTeamMembers(team.ID, scmprovider.RoleAll)
+		if err != nil {
+			return false, fmt.Errorf("error in ListTeamMembers(%s): %v", team.Name, err)
+		}
+		for _, member := range members {
+			if scmprovider.NormLogin(member.Login) == scmprovider.NormLogin(user) {
+				logrus.Infof("User %q is a member of team %q of org %q", user, team.Name, org)
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// allowlisted returns true if the allowlist lists the user, one login per line
+func allowlisted(data []byte, user string) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		login := strings.TrimPrefix(strings.TrimSpace(line), "@")
+		if login == "" || strings.HasPrefix(login, "#") {
+			continue
+		}
+		if scmprovider.NormLogin(login) == scmprovider.NormLogin(user) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
 func skippedStatusFor(context string) *scm.StatusInput {
 	return &scm.StatusInput{
 		State: scm.StateSuccess,
@@ -208,18 +391,18 @@ func failedStatusForMetapipelineCreation(context string, err error) *scm.StatusI
 	return &scm.StatusInput{
 		State: scm.StateError,
 		Label: context,
-		Desc:  fmt.Sprintf("Error creating metapipeline: %s", err),
+		Desc:  fmt.Sprintf("Error creating metapipeline: %s", errorutil.UserMessage(err, "internal error, see the logs of the webhook")),
 	}
 }
 
 // RunAndSkipJobs executes the config.Presubmits that are requested and posts skipped statuses
-// for the reporting jobs that are skipped
-func RunAndSkipJobs(c Client, pr *scm.PullRequest, requestedJobs []config.Presubmit, skippedJobs []config.Presubmit, eventGUID string, elideSkippedContexts bool) error {
+// for the reporting jobs that are skipped. The parameters of the requested jobs are keyed by job name.
+func RunAndSkipJobs(c Client, pr *scm.PullRequest, requestedJobs []config.Presubmit, skippedJobs []config.Presubmit, parameters map[string]map[string]string, eventGUID string, elideSkippedContexts bool) error {
 	if err := validateContextOverlap(requestedJobs, skippedJobs); err != nil {
 		c.Logger.WithError(err).Warn("Could not run or skip requested jobs, overlapping contexts.")
 		return err
 	}
-	runErr := runRequested(c, pr, requestedJobs, eventGUID)
+	runErr := runRequested(c, pr, requestedJobs, parameters, eventGUID)
 	var skipErr error
 	if !elideSkippedContexts {
 		skipErr = skipRequested(c, pr, skippedJobs)
@@ -246,20 +429,27 @@ func validateContextOverlap(toRun, toSkip []config.Presubmit) error {
 }
 
 // runRequested executes the config.Presubmits that are requested
-func runRequested(c Client, pr *scm.PullRequest, requestedJobs []config.Presubmit, eventGUID string) error {
+func runRequested(c Client, pr *scm.PullRequest, requestedJobs []config.Presubmit, parameters map[string]map[string]string, eventGUID string) error {
 	baseSHA, err := c.SCMProviderClient.GetRef(pr.Base.Repo.Namespace, pr.Base.Repo.Name, "heads/"+pr.Base.Ref)
 	if err != nil {
 		return err
 	}
 
+	changes := config.NewGitHubDeferredChangedFilesProvider(c.SCMProviderClient, pr.Base.Repo.Namespace, pr.Base.Repo.Name, pr.Number)
+
 	var errors []error
 	for _, job := range requestedJobs {
 		c.Logger.Infof("Starting %s build.", job.Name)
 		pj := jobutil.NewPresubmit(pr, baseSHA, job, eventGUID)
+		pj.Spec.Parameters = parameters[job.Name]
+		c.setChanges(&pj.Spec, pr.Base.Repo.Namespace, pr.Base.Repo.Name, changes)
 		c.Logger.WithFields(jobutil.LighthouseJobFields(&pj)).Info("Creating a new LighthouseJob.")
 		if _, err := c.LauncherClient.Launch(&pj, pr.Repository()); err != nil {
 			c.Logger.WithError(err).Error("Failed to create LighthouseJob.")
 			errors = append(errors, err)
+			if job.SkipReport {
+				continue
+			}
 			if _, statusErr := c.SCMProviderClient.CreateStatus(pr.Base.Repo.Namespace, pr.Base.Repo.Name, pr.Head.Ref, failedStatusForMetapipelineCreation(job.Context, err)); statusErr != nil {
 				errors = append(errors, statusErr)
 			}
@@ -268,6 +458,22 @@ func runRequested(c Client, pr *scm.PullRequest, requestedJobs []config.Presubmi
 	return errorutil.NewAggregate(errors...)
 }
 
+// setChanges passes the changed files to the job as test selection hints, the job is still run without them
+func (c *Client) setChanges(spec *v1alpha1.LighthouseJobSpec, org, repo string, changes config.ChangedFilesProvider) {
+	files, err := changes()
+	if err != nil {
+		c.Logger.WithError(err).Warn("Failed to list the changed files.")
+		return
+	}
+	var patterns []string
+	if c.Settings != nil {
+		patterns = c.Settings.ChangedModules.PatternsFor(org, repo)
+	}
+	if err := jobutil.SetChanges(spec, patterns, files); err != nil {
+		c.Logger.WithError(err).Warn("Failed to compute the changed modules.")
+	}
+}
+
 // skipRequested posts skipped statuses for the config.Presubmits that are requested
 func skipRequested(c Client, pr *scm.PullRequest, skippedJobs []config.Presubmit) error {
 	var errors []error
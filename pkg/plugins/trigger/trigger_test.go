@@ -22,6 +22,7 @@ import (
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/identity"
 	"github.com/jenkins-x/lighthouse/pkg/launcher/fake"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
 	fake2 "github.com/jenkins-x/lighthouse/pkg/scmprovider/fake"
@@ -328,7 +329,11 @@ func TestRunAndSkipJobs(t *testing.T) {
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			fakeSCMClient := fake2.SCMClient{}
+			fakeSCMClient := fake2.SCMClient{
+				PullRequestChanges: map[int][]*scm.Change{
+					pr.Number: {{Path: "pkg/util/util.go"}, {Path: "cmd/main.go"}, {Path: "README.md"}},
+				},
+			}
 			fakeLauncher := fake.NewLauncher()
 			fakeLauncher.FailJobs = testCase.jobCreationErrs
 
@@ -338,7 +343,7 @@ func TestRunAndSkipJobs(t *testing.T) {
 				Logger:            logrus.WithField("testcase", testCase.name),
 			}
 
-			err := RunAndSkipJobs(client, pr, testCase.requestedJobs, testCase.skippedJobs, "event-guid", testCase.elideSkippedContexts)
+			err := RunAndSkipJobs(client, pr, testCase.requestedJobs, testCase.skippedJobs, nil, "event-guid", testCase.elideSkippedContexts)
 			if err == nil && testCase.expectedErr {
 				t.Errorf("%s: expected an error but got none", testCase.name)
 			}
@@ -354,6 +359,12 @@ func TestRunAndSkipJobs(t *testing.T) {
 			existingLighthouseJobs := fakeLauncher.Pipelines
 			for _, job := range existingLighthouseJobs {
 				observedCreatedLighthouseJobs.Insert(job.Spec.Job)
+				if expected := []string{"README.md", "cmd/main.go", "pkg/util/util.go"}; !reflect.DeepEqual(job.Spec.ChangedPaths, expected) {
+					t.Errorf("%s: expected changed paths %v but got %v", testCase.name, expected, job.Spec.ChangedPaths)
+				}
+				if expected := []string{".", "cmd", "pkg"}; !reflect.DeepEqual(job.Spec.ChangedModules, expected) {
+					t.Errorf("%s: expected changed modules %v but got %v", testCase.name, expected, job.Spec.ChangedModules)
+				}
 			}
 
 			if missing := testCase.expectedJobs.Difference(observedCreatedLighthouseJobs); missing.Len() > 0 {
@@ -428,7 +439,11 @@ func TestRunRequested(t *testing.T) {
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			fakeSCMClient := fake2.SCMClient{}
+			fakeSCMClient := fake2.SCMClient{
+				PullRequestChanges: map[int][]*scm.Change{
+					pr.Number: {{Path: "pkg/util/util.go"}, {Path: "cmd/main.go"}, {Path: "README.md"}},
+				},
+			}
 			fakeLauncher := fake.NewLauncher()
 			fakeLauncher.FailJobs = testCase.jobCreationErrs
 
@@ -438,7 +453,7 @@ func TestRunRequested(t *testing.T) {
 				Logger:            logrus.WithField("testcase", testCase.name),
 			}
 
-			err := runRequested(client, pr, testCase.requestedJobs, "event-guid")
+			err := runRequested(client, pr, testCase.requestedJobs, nil, "event-guid")
 			if err == nil && testCase.expectedErr {
 				t.Errorf("%s: expected an error but got none", testCase.name)
 			}
@@ -450,6 +465,12 @@ func TestRunRequested(t *testing.T) {
 			existingLighthouseJobs := fakeLauncher.Pipelines
 			for _, job := range existingLighthouseJobs {
 				observedCreatedLighthouseJobs.Insert(job.Spec.Job)
+				if expected := []string{"README.md", "cmd/main.go", "pkg/util/util.go"}; !reflect.DeepEqual(job.Spec.ChangedPaths, expected) {
+					t.Errorf("%s: expected changed paths %v but got %v", testCase.name, expected, job.Spec.ChangedPaths)
+				}
+				if expected := []string{".", "cmd", "pkg"}; !reflect.DeepEqual(job.Spec.ChangedModules, expected) {
+					t.Errorf("%s: expected changed modules %v but got %v", testCase.name, expected, job.Spec.ChangedModules)
+				}
 			}
 
 			if missing := testCase.expectedJobs.Difference(observedCreatedLighthouseJobs); missing.Len() > 0 {
@@ -515,3 +536,74 @@ func TestValidateContextOverlap(t *testing.T) {
 		})
 	}
 }
+
+func TestTrustedUserIdentity(t *testing.T) {
+	mapper, err := identity.NewFileMapper(
+		identity.Identity{Login: "employee", Active: true},
+		identity.Identity{Login: "leaver"},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating the identity mapper: %v", err)
+	}
+	spc := &activeIdentityClient{
+		scmProviderClient: &fake2.SCMClient{
+			OrgMembers:    map[string][]string{"org": {"employee", "leaver"}},
+			Collaborators: []string{"contractor"},
+		},
+		mapper: mapper,
+	}
+	testcases := []struct {
+		user    string
+		trusted bool
+	}{
+		{user: "employee", trusted: true},
+		{user: "leaver", trusted: false},
+		{user: "contractor", trusted: false},
+		{user: fake2.Bot, trusted: true},
+	}
+	for _, tc := range testcases {
+		trusted, err := TrustedUser(spc, &plugins.Trigger{}, plugins.TrustPolicy{}, tc.user, "org", "repo")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.user, err)
+		} else if trusted != tc.trusted {
+			t.Errorf("%s: expected trusted %t but got %t", tc.user, tc.trusted, trusted)
+		}
+	}
+}
+
+func TestTrustedUserPolicy(t *testing.T) {
+	spc := &fake2.SCMClient{
+		OrgMembers:    map[string][]string{"org": {"employee"}, "partner": {"partner-dev"}},
+		Collaborators: []string{"contractor"},
+		RemoteFiles: map[string]map[string]string{
+			".lighthouse/TRUSTED_USERS": {"master": "# release managers\n@Releaser\n\nother\n"},
+		},
+	}
+	trigger := &plugins.Trigger{TrustedOrg: "partner"}
+	testcases := []struct {
+		name    string
+		policy  plugins.TrustPolicy
+		user    string
+		trusted bool
+	}{
+		{name: "org members only", policy: plugins.TrustPolicy{OrgMembers: true}, user: "employee", trusted: true},
+		{name: "trusted org members", policy: plugins.TrustPolicy{OrgMembers: true}, user: "partner-dev", trusted: true},
+		{name: "collaborators are not org members", policy: plugins.TrustPolicy{OrgMembers: true}, user: "contractor", trusted: false},
+		{name: "collaborators", policy: plugins.TrustPolicy{Collaborators: true}, user: "contractor", trusted: true},
+		{name: "org members are not collaborators", policy: plugins.TrustPolicy{Collaborators: true}, user: "employee", trusted: false},
+		{name: "team member", policy: plugins.TrustPolicy{Teams: []string{"leads"}}, user: "sig-lead", trusted: true},
+		{name: "member of another team", policy: plugins.TrustPolicy{Teams: []string{"leads"}}, user: "default-sig-lead", trusted: false},
+		{name: "allowlisted", policy: plugins.TrustPolicy{AllowlistFile: ".lighthouse/TRUSTED_USERS"}, user: "releaser", trusted: true},
+		{name: "commented out of the allowlist", policy: plugins.TrustPolicy{AllowlistFile: ".lighthouse/TRUSTED_USERS"}, user: "release", trusted: false},
+		{name: "missing allowlist", policy: plugins.TrustPolicy{AllowlistFile: "TRUSTED"}, user: "releaser", trusted: false},
+		{name: "bot", policy: plugins.TrustPolicy{Teams: []string{"leads"}}, user: fake2.Bot, trusted: true},
+	}
+	for _, tc := range testcases {
+		trusted, err := TrustedUser(spc, trigger, tc.policy, tc.user, "org", "repo")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if trusted != tc.trusted {
+			t.Errorf("%s: expected trusted %t but got %t", tc.name, tc.trusted, trusted)
+		}
+	}
+}
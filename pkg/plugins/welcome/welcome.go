@@ -26,14 +26,18 @@ import (
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/sirupsen/logrus"
 
+	"github.com/jenkins-x/lighthouse/pkg/messages"
 	"github.com/jenkins-x/lighthouse/pkg/pluginhelp"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 const (
 	pluginName            = "welcome"
 	defaultWelcomeMessage = "Welcome @{{.AuthorLogin}}! It looks like this is your first PR to {{.Org}}/{{.Repo}} 🎉"
+	// welcomeMessage is the ID of the default welcome message in the message catalog
+	welcomeMessage = "welcome.message"
 )
 
 // PRInfo contains info used provided to the welcome message template
@@ -74,7 +78,7 @@ func helpProvider(config *plugins.Configuration, enabledRepos []string) (*plugin
 
 type scmProviderClient interface {
 	CreateComment(owner, repo string, number int, pr bool, comment string) error
-	FindIssues(query, sort string, asc bool) ([]scm.Issue, error)
+	SearchAll(q scmprovider.SearchQuery) ([]*scm.SearchIssue, error)
 }
 
 type client struct {
@@ -103,8 +107,12 @@ func handlePR(c client, pre scm.PullRequestHook, welcomeTemplate string) error {
 	org := pre.PullRequest.Base.Repo.Namespace
 	repo := pre.PullRequest.Base.Repo.Name
 	user := pre.PullRequest.Author.Login
-	query := fmt.Sprintf("is:pr repo:%s/%s author:%s", org, repo, user)
-	issues, err := c.SCMProviderClient.FindIssues(query, "", false)
+	issues, err := c.SCMProviderClient.SearchAll(scmprovider.SearchQuery{
+		PullRequests: true,
+		Org:          org,
+		Repo:         repo,
+		Author:       user,
+	})
 	if err != nil {
 		return err
 	}
@@ -139,7 +147,7 @@ func welcomeMessageForRepo(config *plugins.Configuration, org, repo string) stri
 	if opts.MessageTemplate != "" {
 		return opts.MessageTemplate
 	}
-	return defaultWelcomeMessage
+	return messages.Template(welcomeMessage, defaultWelcomeMessage)
 }
 
 // optionsForRepo gets the plugins.Welcome struct that is applicable to the indicated repo.
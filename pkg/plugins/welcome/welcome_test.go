@@ -19,7 +19,6 @@ package welcome
 import (
 	"fmt"
 	"io/ioutil"
-	"regexp"
 	"strings"
 	"testing"
 
@@ -29,6 +28,7 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -68,10 +68,6 @@ func (fc *fakeClient) NumComments() int {
 	return n
 }
 
-var (
-	expectedQueryRegex = regexp.MustCompile(`is:pr repo:(.+)/(.+) author:(.+)`)
-)
-
 // AddPR records an PR in the client
 func (fc *fakeClient) AddPR(owner, repo, author string, number int) {
 	key := fmt.Sprintf("%s,%s,%s", owner, repo, author)
@@ -86,21 +82,21 @@ func (fc *fakeClient) ClearPRs() {
 	fc.prs = make(map[string]sets.Int)
 }
 
-// FindIssues fails if the query does not match the expected query regex and
-// looks up issues based on parsing the expected query format
-func (fc *fakeClient) FindIssues(query, sort string, asc bool) ([]scm.Issue, error) {
-	fields := expectedQueryRegex.FindStringSubmatch(query)
-	if fields == nil || len(fields) != 4 {
-		return nil, fmt.Errorf("invalid query: `%s` does not match expected regex `%s`", query, expectedQueryRegex.String())
+// SearchAll fails if the query is not a search for the pull requests of an author in a repository
+// and looks up the pull requests of the author
+func (fc *fakeClient) SearchAll(q scmprovider.SearchQuery) ([]*scm.SearchIssue, error) {
+	if !q.PullRequests || q.Org == "" || q.Repo == "" || q.Author == "" {
+		return nil, fmt.Errorf("invalid query: `%s` is not a search for the pull requests of an author in a repository", q.String())
 	}
 	// "find" results
-	owner, repo, author := fields[1], fields[2], fields[3]
-	key := fmt.Sprintf("%s,%s,%s", owner, repo, author)
+	key := fmt.Sprintf("%s,%s,%s", q.Org, q.Repo, q.Author)
 
-	issues := []scm.Issue{}
+	issues := []*scm.SearchIssue{}
 	for _, number := range fc.prs[key].List() {
-		issues = append(issues, scm.Issue{
-			Number: number,
+		issues = append(issues, &scm.SearchIssue{
+			Issue: scm.Issue{
+				Number: number,
+			},
 		})
 	}
 	return issues, nil
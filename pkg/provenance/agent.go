@@ -0,0 +1,60 @@
+package provenance
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/sirupsen/logrus"
+)
+
+// signerRetryPeriod is how long the agent waits before creating a signer again after it failed
+const signerRetryPeriod = time.Minute
+
+// Agent provides the Recorder of the provenance section of the lighthouse settings, creating it
+// again when the settings change so that the signing key is reloaded with the configuration
+type Agent struct {
+	settings  settings.Getter
+	newSigner func(string) (Signer, error)
+	now       func() time.Time
+
+	lock     sync.Mutex
+	loaded   bool
+	current  settings.Provenance
+	recorder *Recorder
+	failed   time.Time
+}
+
+// NewAgent creates an Agent of the given settings
+func NewAgent(s settings.Getter) *Agent {
+	return &Agent{settings: s, newSigner: NewSigner, now: time.Now}
+}
+
+// Recorder returns the recorder of the current settings, or nil if provenance is disabled or
+// its signer cannot be created
+func (a *Agent) Recorder() *Recorder {
+	if a == nil || a.settings == nil {
+		return nil
+	}
+	cfg := a.settings().Provenance
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	retry := a.recorder == nil && cfg.SigningKey != "" && a.now().Sub(a.failed) >= signerRetryPeriod
+	if a.loaded && cfg == a.current && !retry {
+		return a.recorder
+	}
+	a.loaded = true
+	a.current = cfg
+	a.recorder = nil
+	if cfg.SigningKey == "" {
+		return nil
+	}
+	signer, err := a.newSigner(cfg.SigningKey)
+	if err != nil {
+		a.failed = a.now()
+		logrus.WithError(err).Error("Failed to create the provenance signer, the provenance is not recorded.")
+		return nil
+	}
+	a.recorder = NewSignerRecorder(signer, cfg.Dir)
+	return a.recorder
+}
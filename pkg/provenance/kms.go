@@ -0,0 +1,146 @@
+package provenance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// GCPKMSPrefix prefixes the resource names of the Google Cloud KMS key versions signing provenance
+	GCPKMSPrefix = "gcpkms://"
+
+	gcpKMSEndpoint = "https://cloudkms.googleapis.com/v1/"
+	gcpKMSScope    = "https://www.googleapis.com/auth/cloudkms"
+	gcpKMSTimeout  = 30 * time.Second
+)
+
+// gcpKMSDigests are the digests of the Google Cloud KMS signing algorithms, by the suffix of the algorithm
+var gcpKMSDigests = map[string]struct {
+	name string
+	hash func() hash.Hash
+}{
+	"SHA256": {name: "sha256", hash: sha256.New},
+	"SHA384": {name: "sha384", hash: sha512.New384},
+	"SHA512": {name: "sha512", hash: sha512.New},
+}
+
+// gcpKMSSigner signs with an asymmetric signing key version of Google Cloud KMS, so that the
+// private key never has to be mounted in the components
+type gcpKMSSigner struct {
+	client     *http.Client
+	endpoint   string
+	name       string
+	keyID      string
+	digestName string
+	hash       func() hash.Hash
+}
+
+// newDefaultGCPKMSSigner creates a Signer using the Google Cloud KMS key version with the
+// application default credentials, such as the Workload Identity of the pod
+func newDefaultGCPKMSSigner(name string) (Signer, error) {
+	client, err := google.DefaultClient(context.Background(), gcpKMSScope)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the Google Cloud KMS client")
+	}
+	client.Timeout = gcpKMSTimeout
+	return NewGCPKMSSigner(client, gcpKMSEndpoint, name)
+}
+
+// NewGCPKMSSigner creates a Signer using the Google Cloud KMS key version of the given resource
+// name, e.g. projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1, through the
+// KMS REST API at the endpoint. The key ID is the one of the same key loaded from a PEM file.
+func NewGCPKMSSigner(client *http.Client, endpoint, name string) (Signer, error) {
+	s := &gcpKMSSigner{
+		client:   client,
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/",
+		name:     strings.Trim(name, "/"),
+	}
+	publicKey := struct {
+		PEM       string `json:"pem"`
+		Algorithm string `json:"algorithm"`
+	}{}
+	if err := s.call(http.MethodGet, s.name+"/publicKey", nil, &publicKey); err != nil {
+		return nil, errors.Wrapf(err, "failed to get the public key of the provenance signing key %s", s.name)
+	}
+	block, _ := pem.Decode([]byte(publicKey.PEM))
+	if block == nil {
+		return nil, errors.Errorf("no PEM block in the public key of the provenance signing key %s", s.name)
+	}
+	for suffix, digest := range gcpKMSDigests {
+		if strings.HasSuffix(publicKey.Algorithm, "_"+suffix) {
+			s.digestName = digest.name
+			s.hash = digest.hash
+		}
+	}
+	if s.hash == nil {
+		return nil, errors.Errorf("unsupported algorithm %s of the provenance signing key %s", publicKey.Algorithm, s.name)
+	}
+	keyDigest := sha256.Sum256(block.Bytes)
+	s.keyID = hex.EncodeToString(keyDigest[:])
+	return s, nil
+}
+
+func (s *gcpKMSSigner) KeyID() string {
+	return s.keyID
+}
+
+func (s *gcpKMSSigner) Sign(message []byte) ([]byte, error) {
+	h := s.hash()
+	h.Write(message) // #nosec
+	request := map[string]interface{}{
+		"digest": map[string]string{
+			s.digestName: base64.StdEncoding.EncodeToString(h.Sum(nil)),
+		},
+	}
+	response := struct {
+		Signature string `json:"signature"`
+	}{}
+	if err := s.call(http.MethodPost, s.name+":asymmetricSign", request, &response); err != nil {
+		return nil, errors.Wrapf(err, "failed to sign with %s", s.name)
+	}
+	return base64.StdEncoding.DecodeString(response.Signature)
+}
+
+// call calls the KMS API, decoding the JSON response into out
+func (s *gcpKMSSigner) call(method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the request")
+		}
+	}
+	req, err := http.NewRequest(method, s.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("the KMS returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return errors.Wrap(json.Unmarshal(data, out), "failed to parse the response")
+}
@@ -0,0 +1,47 @@
+package provenance
+
+import (
+	"encoding/json"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/launcher"
+	"github.com/sirupsen/logrus"
+)
+
+// Launcher annotates the LighthouseJobs it launches with the signed provenance of the launch
+type Launcher struct {
+	launcher.PipelineLauncher
+	recorder *Recorder
+	trigger  Trigger
+}
+
+// NewLauncher wraps the launcher so that the jobs it launches for the trigger are
+// annotated with their provenance. It returns the launcher unchanged if the recorder is nil.
+func NewLauncher(l launcher.PipelineLauncher, recorder *Recorder, trigger Trigger) launcher.PipelineLauncher {
+	if recorder == nil || l == nil {
+		return l
+	}
+	return &Launcher{PipelineLauncher: l, recorder: recorder, trigger: trigger}
+}
+
+// Launch signs the provenance of the job before launching it. Failing to sign is logged
+// rather than preventing the job from running.
+func (l *Launcher) Launch(request *v1alpha1.LighthouseJob, repository scm.Repository) (*v1alpha1.LighthouseJob, error) {
+	statement := l.recorder.Statement(ActionLaunch, l.trigger, request.Spec.Refs)
+	statement.Predicate.Job = request.Spec.Job
+	statement.Predicate.Type = string(request.Spec.Type)
+	envelope, err := l.recorder.Record(request.Name+"."+ActionLaunch, statement)
+	if err != nil {
+		logrus.WithError(err).WithField("LighthouseJob", request.Name).Error("Failed to record the provenance of the job.")
+	}
+	if envelope != nil {
+		if data, err := json.Marshal(envelope); err == nil {
+			if request.Annotations == nil {
+				request.Annotations = map[string]string{}
+			}
+			request.Annotations[Annotation] = string(data)
+		}
+	}
+	return l.PipelineLauncher.Launch(request, repository)
+}
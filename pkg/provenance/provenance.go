@@ -0,0 +1,281 @@
+// Package provenance generates signed provenance attestations of the LighthouseJobs
+// launched and the pull requests merged by Lighthouse, so that supply chain audits
+// can verify who triggered them, from which event and with which configuration.
+//
+// Attestations are in-toto statements wrapped in DSSE envelopes, the format used by
+// `cosign attest`, so they can be verified with `cosign verify-blob-attestation`
+// using the public key matching the signing key.
+package provenance
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Annotation is the annotation of LighthouseJobs containing the DSSE envelope of their launch
+	Annotation = "lighthouse.jenkins-x.io/provenance"
+
+	// PayloadType is the DSSE payload type of in-toto statements
+	PayloadType = "application/vnd.in-toto+json"
+	// StatementType is the type of the in-toto statements
+	StatementType = "https://in-toto.io/Statement/v0.1"
+	// PredicateType is the type of the Lighthouse provenance predicate
+	PredicateType = "https://lighthouse.jenkins.io/provenance/v1"
+
+	// ActionLaunch is the action of the provenance of a LighthouseJob launch
+	ActionLaunch = "launch"
+	// ActionMerge is the action of the provenance of a pull request merge
+	ActionMerge = "merge"
+)
+
+// Statement is an in-toto statement about the commits of a repository
+type Statement struct {
+	Type          string    `json:"_type"`
+	Subject       []Subject `json:"subject"`
+	PredicateType string    `json:"predicateType"`
+	Predicate     Predicate `json:"predicate"`
+}
+
+// Subject is a commit of a repository identified by its SHA
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Trigger describes what caused Lighthouse to act
+type Trigger struct {
+	// TriggeredBy is the login of the user who sent the event, or the component acting on its own
+	TriggeredBy string `json:"triggeredBy"`
+	// EventGUID is the identifier of the webhook event, if any
+	EventGUID string `json:"eventGUID,omitempty"`
+	// EventDigest is the sha256 digest of the webhook event payload, if any
+	EventDigest string `json:"eventDigest,omitempty"`
+	// ConfigVersion is the sha256 digest of the Lighthouse configuration used
+	ConfigVersion string `json:"configVersion,omitempty"`
+}
+
+// Predicate is the Lighthouse provenance of a launch or merge
+type Predicate struct {
+	Trigger
+	Action string         `json:"action"`
+	Time   time.Time      `json:"time"`
+	Job    string         `json:"job,omitempty"`
+	Type   string         `json:"type,omitempty"`
+	Refs   *v1alpha1.Refs `json:"refs,omitempty"`
+}
+
+// Envelope is a DSSE envelope of a signed statement
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a DSSE signature
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// Signer signs provenance statements. Signers backed by a KMS can implement it to
+// avoid mounting the private key in the components.
+type Signer interface {
+	// KeyID identifies the key of the signatures
+	KeyID() string
+	// Sign returns the signature of the message
+	Sign(message []byte) ([]byte, error)
+}
+
+// NewSigner creates the Signer of the key, which is either the resource name of a Google Cloud
+// KMS key version prefixed with GCPKMSPrefix or the path of a PEM private key
+func NewSigner(key string) (Signer, error) {
+	if strings.HasPrefix(key, GCPKMSPrefix) {
+		return newDefaultGCPKMSSigner(strings.TrimPrefix(key, GCPKMSPrefix))
+	}
+	return NewKeySigner(key)
+}
+
+// keySigner signs with a private key loaded from a PEM file
+type keySigner struct {
+	keyID string
+	key   crypto.Signer
+}
+
+// NewKeySigner creates a Signer using the unencrypted PKCS#8, EC or PKCS#1 PEM private key
+// in the given file. ECDSA, Ed25519 and RSA keys are supported.
+func NewKeySigner(path string) (Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the provenance signing key %s", path)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf("no PEM block in the provenance signing key %s", path)
+	}
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the provenance signing key %s", path)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("unsupported provenance signing key type %T", key)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the provenance public key")
+	}
+	digest := sha256.Sum256(publicKey)
+	return &keySigner{keyID: hex.EncodeToString(digest[:]), key: signer}, nil
+}
+
+func (s *keySigner) KeyID() string {
+	return s.keyID
+}
+
+func (s *keySigner) Sign(message []byte) ([]byte, error) {
+	switch s.key.(type) {
+	case ed25519.PrivateKey:
+		return s.key.Sign(rand.Reader, message, crypto.Hash(0))
+	case *ecdsa.PrivateKey, *rsa.PrivateKey:
+		digest := sha256.Sum256(message)
+		return s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	default:
+		return nil, errors.Errorf("unsupported provenance signing key type %T", s.key)
+	}
+}
+
+// PAE returns the DSSE pre-authentication encoding of the payload, which is what is signed
+func PAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// Digest returns the sha256 digest of the data in the `sha256:<hex>` form
+func Digest(data []byte) string {
+	digest := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(digest[:])
+}
+
+// Recorder signs provenance statements and stores the envelopes in a directory,
+// which can be a volume backed by object storage
+type Recorder struct {
+	signer Signer
+	dir    string
+	now    func() time.Time
+}
+
+// NewRecorder creates a Recorder signing with the given key, as supported by NewSigner, and
+// storing the envelopes in dir if it is not empty. It returns nil if no key is specified,
+// which disables provenance.
+func NewRecorder(key, dir string) (*Recorder, error) {
+	if key == "" {
+		return nil, nil
+	}
+	signer, err := NewSigner(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewSignerRecorder(signer, dir), nil
+}
+
+// NewSignerRecorder creates a Recorder using the given signer
+func NewSignerRecorder(signer Signer, dir string) *Recorder {
+	return &Recorder{signer: signer, dir: dir, now: time.Now}
+}
+
+// Statement returns the statement of the action on the refs, whose subjects are the
+// base commit and the head commits of the pull requests
+func (r *Recorder) Statement(action string, trigger Trigger, refs *v1alpha1.Refs) Statement {
+	statement := Statement{
+		Type:          StatementType,
+		PredicateType: PredicateType,
+		Predicate: Predicate{
+			Trigger: trigger,
+			Action:  action,
+			Time:    r.now().UTC(),
+			Refs:    refs,
+		},
+	}
+	if refs != nil {
+		name := refs.Org + "/" + refs.Repo
+		if refs.BaseSHA != "" {
+			statement.Subject = append(statement.Subject, Subject{Name: name + "@" + refs.BaseRef, Digest: map[string]string{"sha1": refs.BaseSHA}})
+		}
+		for _, pull := range refs.Pulls {
+			statement.Subject = append(statement.Subject, Subject{Name: fmt.Sprintf("%s#%d", name, pull.Number), Digest: map[string]string{"sha1": pull.SHA}})
+		}
+	}
+	return statement
+}
+
+// Sign signs the statement, returning its DSSE envelope
+func (r *Recorder) Sign(statement Statement) (*Envelope, error) {
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the provenance statement")
+	}
+	sig, err := r.signer.Sign(PAE(PayloadType, payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign the provenance statement")
+	}
+	return &Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []Signature{{KeyID: r.signer.KeyID(), Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}, nil
+}
+
+// Store writes the envelope to the directory of the recorder as <name>.intoto.json, doing
+// nothing if the recorder has no directory
+func (r *Recorder) Store(name string, envelope *Envelope) error {
+	if r.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the provenance envelope")
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the provenance directory %s", r.dir)
+	}
+	path := filepath.Join(r.dir, name+".intoto.json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write the provenance envelope %s", path)
+	}
+	return nil
+}
+
+// Record signs the statement and stores its envelope
+func (r *Recorder) Record(name string, statement Statement) (*Envelope, error) {
+	envelope, err := r.Sign(statement)
+	if err != nil {
+		return nil, err
+	}
+	return envelope, r.Store(name, envelope)
+}
@@ -0,0 +1,232 @@
+package provenance
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/launcher/fake"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, dir string, key interface{}) string {
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0600))
+	return path
+}
+
+func decodeEnvelope(t *testing.T, envelope *Envelope) ([]byte, []byte) {
+	require.Equal(t, PayloadType, envelope.PayloadType)
+	require.Len(t, envelope.Signatures, 1)
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	require.NoError(t, err)
+	sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	require.NoError(t, err)
+	return payload, sig
+}
+
+func TestNewRecorderDisabled(t *testing.T) {
+	r, err := NewRecorder("", "")
+	require.NoError(t, err)
+	assert.Nil(t, r)
+
+	l := fake.NewLauncher()
+	assert.Equal(t, l, NewLauncher(l, nil, Trigger{}))
+}
+
+func TestRecorderECDSA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provenance")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	storeDir := filepath.Join(dir, "store")
+	r, err := NewRecorder(writeKey(t, dir, key), storeDir)
+	require.NoError(t, err)
+	r.now = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	refs := &v1alpha1.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base", Pulls: []v1alpha1.Pull{{Number: 1, SHA: "head"}}}
+	trigger := Trigger{TriggeredBy: "user", EventGUID: "guid", EventDigest: Digest([]byte("{}")), ConfigVersion: "sha256:config"}
+	statement := r.Statement(ActionMerge, trigger, refs)
+	assert.Equal(t, []Subject{
+		{Name: "org/repo@master", Digest: map[string]string{"sha1": "base"}},
+		{Name: "org/repo#1", Digest: map[string]string{"sha1": "head"}},
+	}, statement.Subject)
+
+	envelope, err := r.Record("merge-1", statement)
+	require.NoError(t, err)
+	payload, sig := decodeEnvelope(t, envelope)
+	digest := sha256.Sum256(PAE(PayloadType, payload))
+	ecdsaSig := struct{ R, S *big.Int }{}
+	_, err = asn1.Unmarshal(sig, &ecdsaSig)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], ecdsaSig.R, ecdsaSig.S))
+
+	actual := Statement{}
+	require.NoError(t, json.Unmarshal(payload, &actual))
+	assert.Equal(t, StatementType, actual.Type)
+	assert.Equal(t, PredicateType, actual.PredicateType)
+	assert.Equal(t, ActionMerge, actual.Predicate.Action)
+	assert.Equal(t, "user", actual.Predicate.TriggeredBy)
+	assert.Equal(t, "guid", actual.Predicate.EventGUID)
+	assert.Equal(t, "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", actual.Predicate.EventDigest)
+
+	stored, err := ioutil.ReadFile(filepath.Join(storeDir, "merge-1.intoto.json"))
+	require.NoError(t, err)
+	storedEnvelope := &Envelope{}
+	require.NoError(t, json.Unmarshal(stored, storedEnvelope))
+	assert.Equal(t, envelope, storedEnvelope)
+}
+
+func TestLauncherEd25519(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provenance")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	r, err := NewRecorder(writeKey(t, dir, key), "")
+	require.NoError(t, err)
+
+	inner := fake.NewLauncher()
+	l := NewLauncher(inner, r, Trigger{TriggeredBy: "user"})
+	job := &v1alpha1.LighthouseJob{}
+	job.Name = "job"
+	job.Spec = v1alpha1.LighthouseJobSpec{
+		Type: "presubmit",
+		Job:  "build",
+		Refs: &v1alpha1.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base"},
+	}
+	_, err = l.Launch(job, scm.Repository{})
+	require.NoError(t, err)
+	require.Len(t, inner.Pipelines, 1)
+
+	envelope := &Envelope{}
+	require.NoError(t, json.Unmarshal([]byte(inner.Pipelines[0].Annotations[Annotation]), envelope))
+	payload, sig := decodeEnvelope(t, envelope)
+	assert.True(t, ed25519.Verify(publicKey, PAE(PayloadType, payload), sig))
+
+	actual := Statement{}
+	require.NoError(t, json.Unmarshal(payload, &actual))
+	assert.Equal(t, ActionLaunch, actual.Predicate.Action)
+	assert.Equal(t, "build", actual.Predicate.Job)
+	assert.Equal(t, "presubmit", actual.Predicate.Type)
+}
+
+func TestNewKeySignerInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provenance")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a key"), 0600))
+	_, err = NewKeySigner(path)
+	assert.Error(t, err)
+	_, err = NewKeySigner(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestGCPKMSSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	name := "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/" + name + "/publicKey":
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]string{
+				"pem":       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})),
+				"algorithm": "EC_SIGN_P256_SHA256",
+			}))
+		case "/v1/" + name + ":asymmetricSign":
+			request := struct {
+				Digest map[string]string `json:"digest"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			digest, err := base64.StdEncoding.DecodeString(request.Digest["sha256"])
+			require.NoError(t, err)
+			sig, err := key.Sign(rand.Reader, digest, crypto.SHA256)
+			require.NoError(t, err)
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]string{"signature": base64.StdEncoding.EncodeToString(sig)}))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	signer, err := NewGCPKMSSigner(server.Client(), server.URL+"/v1", name)
+	require.NoError(t, err)
+	keyDigest := sha256.Sum256(publicKey)
+	assert.Equal(t, hex.EncodeToString(keyDigest[:]), signer.KeyID(), "the key ID is the one of the PEM key")
+
+	r := NewSignerRecorder(signer, "")
+	envelope, err := r.Sign(r.Statement(ActionMerge, Trigger{TriggeredBy: "keeper"}, nil))
+	require.NoError(t, err)
+	payload, sig := decodeEnvelope(t, envelope)
+	digest := sha256.Sum256(PAE(PayloadType, payload))
+	ecdsaSig := struct{ R, S *big.Int }{}
+	_, err = asn1.Unmarshal(sig, &ecdsaSig)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], ecdsaSig.R, ecdsaSig.S))
+
+	_, err = NewGCPKMSSigner(server.Client(), server.URL+"/v1", "projects/p/missing")
+	assert.Error(t, err)
+}
+
+func TestAgent(t *testing.T) {
+	var agent *Agent
+	assert.Nil(t, agent.Recorder())
+
+	cfg := &settings.Config{}
+	agent = NewAgent(func() *settings.Config { return cfg })
+	var created []string
+	agent.newSigner = func(key string) (Signer, error) {
+		created = append(created, key)
+		if key == "broken" {
+			return nil, errors.New("broken key")
+		}
+		return &keySigner{keyID: key}, nil
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	agent.now = func() time.Time { return now }
+	assert.Nil(t, agent.Recorder(), "provenance is disabled without a signing key")
+
+	cfg = &settings.Config{Provenance: settings.Provenance{SigningKey: "key", Dir: "/provenance"}}
+	r := agent.Recorder()
+	require.NotNil(t, r)
+	assert.Equal(t, "/provenance", r.dir)
+	assert.Equal(t, r, agent.Recorder(), "the recorder is kept until the settings change")
+
+	cfg = &settings.Config{Provenance: settings.Provenance{SigningKey: "broken"}}
+	assert.Nil(t, agent.Recorder())
+	assert.Nil(t, agent.Recorder())
+	now = now.Add(signerRetryPeriod)
+	assert.Nil(t, agent.Recorder())
+	assert.Equal(t, []string{"key", "broken", "broken"}, created, "failed signers are retried after a while")
+}
@@ -0,0 +1,179 @@
+// Package providerstatus monitors the status page of the git provider, so that the controllers stop
+// merging pull requests and reporting commit statuses while the provider reports an incident on them,
+// rather than failing every call and reporting the statuses out of order once it recovers.
+package providerstatus
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// GitHubStatusURL is the API of the components of githubstatus.com
+	GitHubStatusURL = "https://www.githubstatus.com/api/v2/components.json"
+	// DefaultComponents are the components of the status page the controllers depend on
+	DefaultComponents = "Pull Requests,API Requests"
+
+	// operational is the status of the components of a status page which have no incident
+	operational = "operational"
+	// maxBodySize is the maximum size of the responses of the status pages which are read
+	maxBodySize = 1024 * 1024
+)
+
+var degradedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "lighthouse_provider_degraded",
+	Help: "Whether the status page of the git provider reports that the components Lighthouse depends on are degraded.",
+})
+
+func init() {
+	prometheus.MustRegister(degradedGauge)
+}
+
+// Options configure the monitoring of the status page of the git provider
+type Options struct {
+	// URL is either the components API of a Statuspage status page, such as GitHubStatusURL, or a health
+	// URL which returns a 2xx status while the provider is healthy. The monitoring is disabled if empty.
+	URL string
+	// Components are the comma separated names of the components of the status page which must be
+	// operational
+	Components string
+	// Interval is how often the status page is checked
+	Interval time.Duration
+}
+
+// AddFlags adds the command line flags of the monitoring of the status page
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.URL, "provider-status-url", "", fmt.Sprintf("If set, pause while the git provider is degraded according to this status page, either the components API of a Statuspage page such as %s, or a health URL returning a 2xx status while the provider is healthy.", GitHubStatusURL))
+	fs.StringVar(&o.Components, "provider-status-components", DefaultComponents, "The comma separated components of the status page which must be operational.")
+	fs.DurationVar(&o.Interval, "provider-status-interval", time.Minute, "How often the status page of the git provider is checked.")
+}
+
+// Monitor checks the status page of the git provider periodically. A nil Monitor is disabled and
+// always reports the provider as healthy.
+type Monitor struct {
+	url        string
+	components map[string]bool
+	interval   time.Duration
+	client     *http.Client
+
+	lock   sync.RWMutex
+	reason string
+}
+
+// New creates the monitor of the status page, or returns nil if it is disabled by the options
+func New(opts Options) *Monitor {
+	if opts.URL == "" {
+		return nil
+	}
+	components := map[string]bool{}
+	for _, c := range strings.Split(opts.Components, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			components[strings.ToLower(c)] = true
+		}
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Monitor{
+		url:        opts.URL,
+		components: components,
+		interval:   interval,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Degraded returns true if the provider is degraded, along with the reason
+func (m *Monitor) Degraded() (bool, string) {
+	if m == nil {
+		return false, ""
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.reason != "", m.reason
+}
+
+// Run checks the status page until stopCh is closed
+func (m *Monitor) Run(stopCh <-chan struct{}) {
+	if m == nil {
+		return
+	}
+	wait.Until(m.Check, m.interval, stopCh)
+}
+
+// Check checks the status page once. The previous state is kept if the status page cannot be reached,
+// as this does not tell whether the provider is degraded.
+func (m *Monitor) Check() {
+	if m == nil {
+		return
+	}
+	reason, err := m.fetch()
+	if err != nil {
+		logrus.WithError(err).WithField("url", m.url).Warn("failed to check the status of the git provider")
+		return
+	}
+	m.lock.Lock()
+	previous := m.reason
+	m.reason = reason
+	m.lock.Unlock()
+
+	switch {
+	case reason != "" && previous == "":
+		degradedGauge.Set(1)
+		logrus.WithField("url", m.url).Warnf("the git provider is degraded, pausing until it recovers: %s", reason)
+	case reason == "" && previous != "":
+		degradedGauge.Set(0)
+		logrus.WithField("url", m.url).Info("the git provider recovered")
+	}
+}
+
+// componentsResponse is the response of the components API of the Statuspage status pages
+type componentsResponse struct {
+	Components []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	} `json:"components"`
+}
+
+// fetch returns why the provider is degraded according to the status page, or an empty string if it
+// is healthy
+func (m *Monitor) fetch() (string, error) {
+	resp, err := m.client.Get(m.url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Sprintf("%s returned status %d", m.url, resp.StatusCode), nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the status page")
+	}
+	page := componentsResponse{}
+	if err := json.Unmarshal(body, &page); err != nil || len(page.Components) == 0 {
+		// a health URL which only tells the provider is healthy by its status
+		return "", nil
+	}
+	var degraded []string
+	for _, c := range page.Components {
+		if m.components[strings.ToLower(c.Name)] && c.Status != operational {
+			degraded = append(degraded, fmt.Sprintf("%s is %s", c.Name, strings.Replace(c.Status, "_", " ", -1)))
+		}
+	}
+	sort.Strings(degraded)
+	return strings.Join(degraded, ", "), nil
+}
@@ -0,0 +1,63 @@
+package providerstatus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor(t *testing.T) {
+	var m *Monitor
+	assert.Nil(t, New(Options{}))
+	degraded, _ := m.Degraded()
+	assert.False(t, degraded, "a disabled monitor reports the provider as healthy")
+	m.Run(nil)
+
+	status := http.StatusOK
+	body := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	m = New(Options{URL: server.URL, Components: DefaultComponents})
+	body = `{"components": [
+		{"name": "Git Operations", "status": "major_outage"},
+		{"name": "API Requests", "status": "operational"},
+		{"name": "Pull Requests", "status": "operational"}
+	]}`
+	m.Check()
+	degraded, _ = m.Degraded()
+	assert.False(t, degraded, "the other components are ignored")
+
+	body = `{"components": [
+		{"name": "API Requests", "status": "partial_outage"},
+		{"name": "Pull Requests", "status": "degraded_performance"}
+	]}`
+	m.Check()
+	degraded, reason := m.Degraded()
+	assert.True(t, degraded)
+	assert.Equal(t, "API Requests is partial outage, Pull Requests is degraded performance", reason)
+
+	server.Close()
+	m.Check()
+	degraded, _ = m.Degraded()
+	assert.True(t, degraded, "the state is kept while the status page is unreachable")
+
+	server = httptest.NewServer(server.Config.Handler)
+	defer server.Close()
+	m = New(Options{URL: server.URL})
+	status = http.StatusServiceUnavailable
+	m.Check()
+	degraded, reason = m.Degraded()
+	assert.True(t, degraded)
+	assert.Equal(t, server.URL+" returned status 503", reason)
+	status = http.StatusOK
+	body = "ok"
+	m.Check()
+	degraded, _ = m.Degraded()
+	assert.False(t, degraded, "a health URL is healthy when it returns a 2xx status")
+}
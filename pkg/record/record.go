@@ -35,14 +35,22 @@ type ActivityStageOrStep struct {
 	Steps          []*ActivityStageOrStep `json:"steps,omitempty"`
 }
 
-// RunningStages returns the list of stages currently running
-func (a *ActivityRecord) RunningStages() []string {
-	var running []string
-
+// StageRecords returns the state and timings of the stages of the activity
+func (a *ActivityRecord) StageRecords() []v1alpha1.Stage {
+	var stages []v1alpha1.Stage
 	for _, stage := range a.Stages {
-		if stage.Status == v1alpha1.RunningState {
-			running = append(running, stage.Name)
-		}
+		stages = append(stages, v1alpha1.Stage{
+			Name:           stage.Name,
+			State:          stage.Status,
+			StartTime:      stage.StartTime,
+			CompletionTime: stage.CompletionTime,
+		})
 	}
-	return running
+	return stages
+}
+
+// RunningStages returns the list of stages currently running
+func (a *ActivityRecord) RunningStages() []string {
+	status := v1alpha1.LighthouseJobStatus{Stages: a.StageRecords()}
+	return status.RunningStages()
 }
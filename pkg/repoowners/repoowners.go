@@ -27,6 +27,7 @@ import (
 
 	"github.com/jenkins-x/go-scm/scm"
 	git2 "github.com/jenkins-x/lighthouse/pkg/git"
+	"github.com/jenkins-x/lighthouse/pkg/identity"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
 	"github.com/sirupsen/logrus"
 	"sigs.k8s.io/yaml"
@@ -80,9 +81,47 @@ type scmProviderClient interface {
 }
 
 type cacheEntry struct {
-	sha     string
-	aliases RepoAliases
-	owners  *RepoOwners
+	sha          string
+	aliases      RepoAliases
+	owners       *RepoOwners
+	dirBlacklist sets.String
+}
+
+// Cache caches the OWNERS and OWNERS_ALIASES files of the repositories by repository and branch,
+// so that it can be shared by the clients of successive events
+type Cache struct {
+	lock    sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewCache returns an empty cache
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]cacheEntry)}
+}
+
+// Rename moves the entries of a repository which has been renamed or transferred to its new name,
+// dropping any stale entry of the new name
+func (c *Cache) Rename(from, to string) {
+	c.Invalidate(to)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, entry := range c.entries {
+		if strings.HasPrefix(key, from+":") {
+			delete(c.entries, key)
+			c.entries[to+strings.TrimPrefix(key, from)] = entry
+		}
+	}
+}
+
+// Invalidate removes the entries of the given repository
+func (c *Cache) Invalidate(repo string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, repo+":") {
+			delete(c.entries, key)
+		}
+	}
 }
 
 // Interface is an interface to work with OWNERS files.
@@ -104,9 +143,9 @@ type Client struct {
 
 	mdYAMLEnabled     func(org, repo string) bool
 	skipCollaborators func(org, repo string) bool
+	identityMapper    identity.Mapper
 
-	lock  sync.Mutex
-	cache map[string]cacheEntry
+	cache *Cache
 }
 
 // NewClient is the constructor for Client
@@ -121,7 +160,7 @@ func NewClient(
 		git:    gc,
 		spc:    spc,
 		logger: logrus.WithField("client", "repoowners"),
-		cache:  make(map[string]cacheEntry),
+		cache:  NewCache(),
 
 		mdYAMLEnabled:     mdYAMLEnabled,
 		skipCollaborators: skipCollaborators,
@@ -130,6 +169,19 @@ func NewClient(
 	}
 }
 
+// SetCache makes the client use the given cache, e.g. a cache shared with the clients of other events
+func (c *Client) SetCache(cache *Cache) {
+	if cache != nil {
+		c.cache = cache
+	}
+}
+
+// SetIdentityMapper makes the client remove the approvers and reviewers whose logins do not map to
+// active identities of the internal directory, so that only active employees can approve changes
+func (c *Client) SetIdentityMapper(m identity.Mapper) {
+	c.identityMapper = m
+}
+
 // RepoAliases defines groups of people to be used in OWNERS files
 type RepoAliases map[string]sets.String
 
@@ -178,9 +230,9 @@ func (c *Client) LoadRepoAliases(org, repo, base string) (RepoAliases, error) {
 		return nil, fmt.Errorf("failed to get current SHA for %s: %v", fullName, err)
 	}
 
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	entry, ok := c.cache[fullName]
+	c.cache.lock.Lock()
+	defer c.cache.lock.Unlock()
+	entry, ok := c.cache.entries[fullName]
 	if !ok || entry.sha != sha {
 		// entry is non-existent or stale.
 		gitRepo, err := c.git.Clone(cloneRef)
@@ -194,7 +246,7 @@ func (c *Client) LoadRepoAliases(org, repo, base string) (RepoAliases, error) {
 
 		entry.aliases = loadAliasesFrom(gitRepo.Dir, log)
 		entry.sha = sha
-		c.cache[fullName] = entry
+		c.cache.entries[fullName] = entry
 	}
 
 	return entry.aliases, nil
@@ -213,10 +265,20 @@ func (c *Client) LoadRepoOwners(org, repo, base string) (RepoOwner, error) {
 		return nil, fmt.Errorf("failed to get current SHA for %s: %v", fullName, err)
 	}
 
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	entry, ok := c.cache[fullName]
-	if !ok || entry.sha != sha || entry.owners == nil || entry.owners.enableMDYAML != mdYaml {
+	blacklistConfig := c.config.OwnersDirBlacklist
+
+	dirBlacklist := defaultDirBlacklist.Union(sets.NewString(blacklistConfig.Default...))
+	if bl, ok := blacklistConfig.Repos[org]; ok {
+		dirBlacklist.Insert(bl...)
+	}
+	if bl, ok := blacklistConfig.Repos[org+"/"+repo]; ok {
+		dirBlacklist.Insert(bl...)
+	}
+
+	c.cache.lock.Lock()
+	defer c.cache.lock.Unlock()
+	entry, ok := c.cache.entries[fullName]
+	if !ok || entry.sha != sha || entry.owners == nil || entry.owners.enableMDYAML != mdYaml || !entry.dirBlacklist.Equal(dirBlacklist) {
 		gitRepo, err := c.git.Clone(cloneRef)
 		if err != nil {
 			return nil, fmt.Errorf("failed to clone %s: %v", cloneRef, err)
@@ -231,37 +293,31 @@ func (c *Client) LoadRepoOwners(org, repo, base string) (RepoOwner, error) {
 			entry.aliases = loadAliasesFrom(gitRepo.Dir, log)
 		}
 
-		blacklistConfig := c.config.OwnersDirBlacklist
-
-		dirBlacklist := defaultDirBlacklist.Union(sets.NewString(blacklistConfig.Default...))
-		if bl, ok := blacklistConfig.Repos[org]; ok {
-			dirBlacklist.Insert(bl...)
-		}
-		if bl, ok := blacklistConfig.Repos[org+"/"+repo]; ok {
-			dirBlacklist.Insert(bl...)
-		}
 		entry.owners, err = loadOwnersFrom(gitRepo.Dir, mdYaml, entry.aliases, dirBlacklist, log)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load RepoOwners for %s: %v", fullName, err)
 		}
 		entry.sha = sha
-		c.cache[fullName] = entry
+		entry.dirBlacklist = dirBlacklist
+		c.cache.entries[fullName] = entry
 	}
 
+	owners := entry.owners
 	if c.skipCollaborators(org, repo) {
 		log.Debugf("Skipping collaborator checks for %s/%s", org, repo)
-		return entry.owners, nil
-	}
-
-	var owners *RepoOwners
-	// Filter collaborators. We must filter the RepoOwners struct even if it came from the cache
-	// because the list of collaborators could have changed without the git Sha changing.
-	collaborators, err := c.spc.ListCollaborators(org, repo)
-	if err != nil {
-		log.WithError(err).Errorf("Failed to list collaborators while loading RepoOwners. Skipping collaborator filtering.")
-		owners = entry.owners
 	} else {
-		owners = entry.owners.filterCollaborators(collaborators)
+		// Filter collaborators. We must filter the RepoOwners struct even if it came from the cache
+		// because the list of collaborators could have changed without the git Sha changing.
+		collaborators, err := c.spc.ListCollaborators(org, repo)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to list collaborators while loading RepoOwners. Skipping collaborator filtering.")
+		} else {
+			owners = owners.filterCollaborators(collaborators)
+		}
+	}
+	if c.identityMapper != nil {
+		// The identities are filtered on every load as users leave without the git Sha changing.
+		owners = owners.filterInactive(c.identityMapper, log)
 	}
 	return owners, nil
 }
@@ -525,6 +581,44 @@ func (o *RepoOwners) filterCollaborators(toKeep []scm.User) *RepoOwners {
 	return &result
 }
 
+// filterInactive removes the approvers and reviewers whose logins do not map to active identities
+func (o *RepoOwners) filterInactive(m identity.Mapper, log *logrus.Entry) *RepoOwners {
+	inactive := sets.NewString()
+	active := func(login string) bool {
+		if inactive.Has(login) {
+			return false
+		}
+		if identity.IsActive(m, login) {
+			return true
+		}
+		inactive.Insert(login)
+		return false
+	}
+	filter := func(ownerMap map[string]map[*regexp.Regexp]sets.String) map[string]map[*regexp.Regexp]sets.String {
+		filtered := make(map[string]map[*regexp.Regexp]sets.String)
+		for path, reMap := range ownerMap {
+			filtered[path] = make(map[*regexp.Regexp]sets.String)
+			for re, unfiltered := range reMap {
+				filtered[path][re] = sets.NewString()
+				for _, login := range unfiltered.List() {
+					if active(login) {
+						filtered[path][re].Insert(login)
+					}
+				}
+			}
+		}
+		return filtered
+	}
+
+	result := *o
+	result.approvers = filter(o.approvers)
+	result.reviewers = filter(o.reviewers)
+	if inactive.Len() > 0 {
+		log.Infof("Ignoring the OWNERS which are not active identities: %s", strings.Join(inactive.List(), ", "))
+	}
+	return &result
+}
+
 // findOwnersForFile returns the OWNERS file path furthest down the tree for a specified file
 // using ownerMap to check for entries
 func findOwnersForFile(log *logrus.Entry, path string, ownerMap map[string]map[*regexp.Regexp]sets.String) string {
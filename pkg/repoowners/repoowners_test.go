@@ -25,6 +25,7 @@ import (
 	"testing"
 
 	"github.com/jenkins-x/lighthouse/pkg/git/localgit"
+	"github.com/jenkins-x/lighthouse/pkg/identity"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider/fake"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -148,7 +149,7 @@ func getTestClient(
 			git:    git,
 			spc:    &fake.SCMClient{Collaborators: []string{"cjwagner", "k8s-ci-robot", "alice", "bob", "carl", "mml", "maggie"}},
 			logger: logrus.WithField("client", "repoowners"),
-			cache:  make(map[string]cacheEntry),
+			cache:  NewCache(),
 
 			mdYAMLEnabled: func(org, repo string) bool {
 				return enableMdYaml
@@ -691,6 +692,42 @@ func TestGetApprovers(t *testing.T) {
 	}
 }
 
+func TestFilterInactive(t *testing.T) {
+	mapper, err := identity.NewFileMapper(
+		identity.Identity{Login: "alice", Active: true},
+		identity.Identity{Login: "bob"},
+		identity.Identity{Login: "carl", Active: true},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating the identity mapper: %v", err)
+	}
+	ro := &RepoOwners{
+		approvers: map[string]map[*regexp.Regexp]sets.String{
+			baseDir: regexpAll("alice", "bob"),
+			leafDir: regexpAll("carl", "dave"),
+		},
+		reviewers: map[string]map[*regexp.Regexp]sets.String{
+			baseDir: regexpAll("bob", "carl"),
+		},
+	}
+	filtered := ro.filterInactive(mapper, logrus.WithField("test", t.Name()))
+	expectedApprovers := map[string]sets.String{
+		baseDir: sets.NewString("alice"),
+		leafDir: sets.NewString("carl"),
+	}
+	for path, expected := range expectedApprovers {
+		if found := filtered.approvers[path][nil]; !found.Equal(expected) {
+			t.Errorf("Expected the approvers of %s to be %v but found %v", path, expected.List(), found.List())
+		}
+	}
+	if found := filtered.reviewers[baseDir][nil]; !found.Equal(sets.NewString("carl")) {
+		t.Errorf("Expected the reviewers of %s to be [carl] but found %v", baseDir, found.List())
+	}
+	if found := ro.approvers[baseDir][nil]; !found.Equal(sets.NewString("alice", "bob")) {
+		t.Errorf("Expected the unfiltered approvers to be unchanged but found %v", found.List())
+	}
+}
+
 func TestFindLabelsForPath(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -854,3 +891,27 @@ func TestExpandAliases(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheRename(t *testing.T) {
+	cache := NewCache()
+	cache.entries["org/old:master"] = cacheEntry{sha: "1"}
+	cache.entries["org/old:release"] = cacheEntry{sha: "2"}
+	cache.entries["org/new:master"] = cacheEntry{sha: "stale"}
+	cache.entries["org/old-fork:master"] = cacheEntry{sha: "3"}
+
+	cache.Rename("org/old", "org/new")
+	if len(cache.entries) != 3 {
+		t.Fatalf("expected 3 entries, got %v", cache.entries)
+	}
+	if cache.entries["org/new:master"].sha != "1" || cache.entries["org/new:release"].sha != "2" {
+		t.Errorf("expected the entries to be moved to the new name, got %v", cache.entries)
+	}
+	if cache.entries["org/old-fork:master"].sha != "3" {
+		t.Errorf("expected the entries of other repositories to be kept, got %v", cache.entries)
+	}
+
+	cache.Invalidate("org/new")
+	if len(cache.entries) != 1 {
+		t.Errorf("expected the entries of the repository to be removed, got %v", cache.entries)
+	}
+}
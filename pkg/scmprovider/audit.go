@@ -0,0 +1,160 @@
+package scmprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditEntry is the record of a change made to the git provider
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Actor is the bot the change is made as
+	Actor string `json:"actor"`
+	// Action describes the change, e.g. adding the label lgtm
+	Action string `json:"action"`
+	Repo   string `json:"repo,omitempty"`
+	// Reason is why the change is made, e.g. the plugin and the event it handles
+	Reason string `json:"reason,omitempty"`
+	// DryRun is true if the change was only logged because of the readOnly switch of the settings
+	DryRun bool `json:"dryRun"`
+	// Details are the arguments of the change, such as the number of the pull request or the sha merged
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// AuditSink records the changes made to the git provider
+type AuditSink interface {
+	Record(entry *AuditEntry) error
+}
+
+type auditReasonKey struct{}
+
+// WithAuditReason returns a copy of the context carrying the reason of the changes made by the clients
+// using it, which is recorded in their audit entries
+func WithAuditReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, auditReasonKey{}, reason)
+}
+
+// AuditReason returns the reason of the changes carried by the context, if any
+func AuditReason(ctx context.Context) string {
+	reason, _ := ctx.Value(auditReasonKey{}).(string)
+	return reason
+}
+
+// SetAuditSink sets the sink the changes made by the client are recorded in, instead of the sink of the
+// lighthouse settings
+func (c *Client) SetAuditSink(sink AuditSink) {
+	c.auditSink = sink
+}
+
+// audit records the change in the audit sink, if any. Failing to record it does not prevent the change.
+func (c *Client) audit(action string, fields map[string]interface{}, dryRun bool) {
+	sink := c.auditSink
+	if sink == nil && c.settings != nil {
+		var err error
+		sink, err = NewAuditSink(c.settings().Audit.Sink)
+		if err != nil {
+			logrus.WithError(err).Warn("failed to open the audit sink")
+			return
+		}
+	}
+	if sink == nil {
+		return
+	}
+	entry := &AuditEntry{
+		Time:    time.Now().UTC(),
+		Actor:   c.botName,
+		Action:  action,
+		Reason:  AuditReason(c.Context()),
+		DryRun:  dryRun,
+		Details: map[string]interface{}{},
+	}
+	for k, v := range fields {
+		if k == "repo" {
+			entry.Repo, _ = v.(string)
+			continue
+		}
+		entry.Details[k] = v
+	}
+	if err := sink.Record(entry); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields(fields)).Warnf("failed to audit %s", action)
+	}
+}
+
+// auditSinks are the sinks opened so far keyed by their setting, so that files are only opened once
+// across reloads of the settings
+var auditSinks = struct {
+	sync.Mutex
+	sinks map[string]AuditSink
+}{sinks: map[string]AuditSink{}}
+
+// NewAuditSink returns the sink of the audit setting: stdout, the path of a file the entries are appended
+// to or an http(s) URL they are posted to. It returns nil if the setting is empty.
+func NewAuditSink(sink string) (AuditSink, error) {
+	if sink == "" {
+		return nil, nil
+	}
+	auditSinks.Lock()
+	defer auditSinks.Unlock()
+	if s, ok := auditSinks.sinks[sink]; ok {
+		return s, nil
+	}
+	var s AuditSink
+	switch {
+	case sink == "stdout":
+		s = &jsonAuditSink{w: os.Stdout}
+	case strings.HasPrefix(sink, "http://") || strings.HasPrefix(sink, "https://"):
+		s = &httpAuditSink{url: sink, client: &http.Client{Timeout: 10 * time.Second}}
+	default:
+		f, err := os.OpenFile(sink, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open the audit file %s", sink)
+		}
+		s = &jsonAuditSink{w: f}
+	}
+	auditSinks.sinks[sink] = s
+	return s, nil
+}
+
+// jsonAuditSink writes the entries as JSON lines
+type jsonAuditSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (s *jsonAuditSink) Record(entry *AuditEntry) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return json.NewEncoder(s.w).Encode(entry)
+}
+
+// httpAuditSink posts each entry as JSON
+type httpAuditSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpAuditSink) Record(entry *AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the audit entry")
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrapf(err, "failed to post the audit entry to %s", s.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.Errorf("failed to post the audit entry to %s: %s", s.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package scmprovider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAuditSink struct {
+	entries []*AuditEntry
+}
+
+func (s *fakeAuditSink) Record(entry *AuditEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestAuditClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+	s := &settings.Config{ReadOnly: true}
+	client.SetSettings(func() *settings.Config { return s })
+	sink := &fakeAuditSink{}
+	client.SetAuditSink(sink)
+	client.SetContext(WithAuditReason(context.Background(), "plugin lgtm handling the comment event"))
+
+	require.NoError(t, client.AddLabel("org", "repo", 1, "lgtm", true))
+	s.ReadOnly = false
+	assert.Error(t, client.Merge("org", "repo", 1, MergeDetails{SHA: "abc"}))
+	_, err = client.GetIssueLabels("org", "repo", 1, true)
+	assert.Error(t, err)
+
+	require.Len(t, sink.entries, 2, "only the changes are audited")
+	label := sink.entries[0]
+	assert.Equal(t, "bot", label.Actor)
+	assert.Equal(t, "adding the label lgtm", label.Action)
+	assert.Equal(t, "org/repo", label.Repo)
+	assert.Equal(t, "plugin lgtm handling the comment event", label.Reason)
+	assert.True(t, label.DryRun)
+	assert.Equal(t, 1, label.Details["number"])
+	merge := sink.entries[1]
+	assert.Equal(t, "merging the pull request", merge.Action)
+	assert.False(t, merge.DryRun)
+	assert.Equal(t, "abc", merge.Details["sha"])
+}
+
+func TestNewAuditSink(t *testing.T) {
+	sink, err := NewAuditSink("")
+	require.NoError(t, err)
+	assert.Nil(t, sink)
+
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	sink, err = NewAuditSink(path)
+	require.NoError(t, err)
+	again, err := NewAuditSink(path)
+	require.NoError(t, err)
+	assert.True(t, sink == again, "the file is only opened once")
+	require.NoError(t, sink.Record(&AuditEntry{Actor: "bot", Action: "commenting", Repo: "org/repo"}))
+	require.NoError(t, sink.Record(&AuditEntry{Actor: "bot", Action: "merging the pull request", Repo: "org/repo", DryRun: true}))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var actions []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := AuditEntry{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{"commenting", "merging the pull request"}, actions)
+
+	var posted AuditEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&posted))
+	}))
+	defer server.Close()
+	sink, err = NewAuditSink(server.URL)
+	require.NoError(t, err)
+	require.NoError(t, sink.Record(&AuditEntry{Actor: "bot", Action: "closing the issue"}))
+	assert.Equal(t, "closing the issue", posted.Action)
+}
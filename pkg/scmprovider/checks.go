@@ -0,0 +1,174 @@
+package scmprovider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// CheckRunStatusQueued is the status of a check run which has not started yet
+	CheckRunStatusQueued = "queued"
+	// CheckRunStatusInProgress is the status of a running check run
+	CheckRunStatusInProgress = "in_progress"
+	// CheckRunStatusCompleted is the status of a completed check run, which then has a conclusion
+	CheckRunStatusCompleted = "completed"
+
+	// CheckRunRerunAction is the identifier of the action re-running the job of a check run
+	CheckRunRerunAction = "rerun"
+)
+
+// CheckRunInput is the content of a GitHub check run, which go-scm does not support
+type CheckRunInput struct {
+	Name       string            `json:"name,omitempty"`
+	HeadSHA    string            `json:"head_sha,omitempty"`
+	Status     string            `json:"status,omitempty"`
+	Conclusion string            `json:"conclusion,omitempty"`
+	DetailsURL string            `json:"details_url,omitempty"`
+	ExternalID string            `json:"external_id,omitempty"`
+	Output     *CheckRunOutput   `json:"output,omitempty"`
+	Actions    []*CheckRunAction `json:"actions,omitempty"`
+}
+
+// CheckRunOutput is the output displayed for a check run
+type CheckRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Text    string `json:"text,omitempty"`
+}
+
+// CheckRunAction is a button displayed for a check run, which sends a check run webhook
+// with the requested_action action and the identifier of the button when clicked
+type CheckRunAction struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Identifier  string `json:"identifier"`
+}
+
+// SupportsCheckRuns returns true if the git provider supports check runs, which is only the case
+// for GitHub. GitHub only accepts check runs created with GitHub App credentials.
+func (c *Client) SupportsCheckRuns() bool {
+	return c.client.Driver == scm.DriverGithub
+}
+
+// CreateCheckRun creates a check run on a commit, returning its ID
+func (c *Client) CreateCheckRun(owner, repo string, input *CheckRunInput) (int64, error) {
+	if c.readOnly("creating a check run", logrus.Fields{"repo": c.repositoryName(owner, repo)}) {
+		return 0, nil
+	}
+	fullName := c.repositoryName(owner, repo)
+	created := struct {
+		ID int64 `json:"id"`
+	}{}
+	if err := c.checkRunRequest("POST", fmt.Sprintf("repos/%s/check-runs", fullName), input, &created); err != nil {
+		return 0, errors.Wrapf(err, "failed to create check run %s on %s", input.Name, fullName)
+	}
+	return created.ID, nil
+}
+
+// UpdateCheckRun updates a check run
+func (c *Client) UpdateCheckRun(owner, repo string, id int64, input *CheckRunInput) error {
+	if c.readOnly("updating the check run", logrus.Fields{"repo": c.repositoryName(owner, repo), "id": id}) {
+		return nil
+	}
+	fullName := c.repositoryName(owner, repo)
+	if err := c.checkRunRequest("PATCH", fmt.Sprintf("repos/%s/check-runs/%d", fullName, id), input, nil); err != nil {
+		return errors.Wrapf(err, "failed to update check run %d on %s", id, fullName)
+	}
+	return nil
+}
+
+func (c *Client) checkRunRequest(method, path string, input *CheckRunInput, output interface{}) error {
+	if !c.SupportsCheckRuns() {
+		return scm.ErrNotSupported
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(c.Context(), &scm.Request{
+		Method: method,
+		Path:   path,
+		Header: map[string][]string{
+			"Accept":       {"application/vnd.github.v3+json"},
+			"Content-Type": {"application/json"},
+		},
+		Body: bytes.NewReader(body),
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.Status > 299 {
+		return errors.Errorf("status %d", res.Status)
+	}
+	if output == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(output)
+}
+
+const (
+	// CheckRunActionRerequested is the action of the check run webhook sent when the re-run button of GitHub is clicked
+	CheckRunActionRerequested = "rerequested"
+	// CheckRunActionRequestedAction is the action of the check run webhook sent when an action button of the check run is clicked
+	CheckRunActionRequestedAction = "requested_action"
+)
+
+// CheckRun contains the details of a check run
+type CheckRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	HeadSHA    string `json:"head_sha"`
+	ExternalID string `json:"external_id"`
+}
+
+// CheckRunHook is a check run webhook including the check run details, the raw action and
+// the identifier of the requested action, which go-scm does not expose for check run events
+type CheckRunHook struct {
+	*scm.CheckRunHook
+
+	// RawAction is the action of the event as sent by the provider, e.g. "rerequested"
+	RawAction string
+	CheckRun  CheckRun
+	// RequestedAction is the identifier of the clicked action button, for the requested_action action
+	RequestedAction string
+}
+
+// Rerun returns true if the webhook asks for the job of the check run to be run again
+func (h *CheckRunHook) Rerun() bool {
+	switch h.RawAction {
+	case CheckRunActionRerequested:
+		return true
+	case CheckRunActionRequestedAction:
+		return h.RequestedAction == CheckRunRerunAction
+	}
+	return false
+}
+
+// checkRunPayload is the part of the GitHub check run webhook payload we need
+type checkRunPayload struct {
+	Action          string   `json:"action"`
+	CheckRun        CheckRun `json:"check_run"`
+	RequestedAction struct {
+		Identifier string `json:"identifier"`
+	} `json:"requested_action"`
+}
+
+// ParseCheckRunHook parses the check run details from the raw payload of a GitHub check run webhook
+func ParseCheckRunHook(hook *scm.CheckRunHook, payload []byte) (*CheckRunHook, error) {
+	data := checkRunPayload{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, errors.Wrap(err, "failed to parse check run webhook payload")
+	}
+	return &CheckRunHook{
+		CheckRunHook:    hook,
+		RawAction:       data.Action,
+		CheckRun:        data.CheckRun,
+		RequestedAction: data.RequestedAction.Identifier,
+	}, nil
+}
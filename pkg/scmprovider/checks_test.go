@@ -0,0 +1,76 @@
+package scmprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRuns(t *testing.T) {
+	var requests []string
+	var inputs []CheckRunInput
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		input := CheckRunInput{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&input))
+		inputs = append(inputs, input)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		fmt.Fprint(w, `{"id": 42}`)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+	assert.True(t, client.SupportsCheckRuns())
+
+	id, err := client.CreateCheckRun("org", "repo", &CheckRunInput{Name: "pr-build", HeadSHA: "abc123", Status: CheckRunStatusQueued})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), id)
+
+	err = client.UpdateCheckRun("org", "repo", id, &CheckRunInput{Status: CheckRunStatusCompleted, Conclusion: "success"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"POST /repos/org/repo/check-runs", "PATCH /repos/org/repo/check-runs/42"}, requests)
+	require.Len(t, inputs, 2)
+	assert.Equal(t, "abc123", inputs[0].HeadSHA)
+	assert.Equal(t, "success", inputs[1].Conclusion)
+
+	fakeClient, _ := fake.NewDefault()
+	unsupported := ToClient(fakeClient, "bot")
+	assert.False(t, unsupported.SupportsCheckRuns())
+	_, err = unsupported.CreateCheckRun("org", "repo", &CheckRunInput{Name: "pr-build"})
+	assert.Error(t, err)
+}
+
+func TestParseCheckRunHook(t *testing.T) {
+	hook := &scm.CheckRunHook{Repo: scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo"}}
+
+	rerequested, err := ParseCheckRunHook(hook, []byte(`{"action": "rerequested", "check_run": {"id": 7, "name": "pr-build", "head_sha": "abc123", "external_id": "job-1"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, CheckRun{ID: 7, Name: "pr-build", HeadSHA: "abc123", ExternalID: "job-1"}, rerequested.CheckRun)
+	assert.True(t, rerequested.Rerun())
+
+	clicked, err := ParseCheckRunHook(hook, []byte(`{"action": "requested_action", "check_run": {"id": 7}, "requested_action": {"identifier": "rerun"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, CheckRunRerunAction, clicked.RequestedAction)
+	assert.True(t, clicked.Rerun())
+
+	completed, err := ParseCheckRunHook(hook, []byte(`{"action": "completed", "check_run": {"id": 7}}`))
+	require.NoError(t, err)
+	assert.False(t, completed.Rerun())
+
+	_, err = ParseCheckRunHook(hook, []byte(`not json`))
+	assert.Error(t, err)
+}
@@ -7,16 +7,37 @@ import (
 	"os"
 
 	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 // ToClient converts the scm client to an API that the prow plugins expect
 func ToClient(client *scm.Client, botName string) *Client {
-	return &Client{client: client, botName: botName}
+	c := &Client{client: client, botName: botName}
+	if client != nil {
+		c.comments = NewCommentWriter(client.Driver.String(), nil)
+		installQueryParamsTransport(client)
+	}
+	return c
+}
+
+// SetSettings sets the lighthouse settings of the client: the settings the comments are truncated with,
+// such as their overflow bucket, and the readOnly switch which stops the client from modifying the git provider
+func (c *Client) SetSettings(settingsGetter settings.Getter) {
+	c.settings = settingsGetter
+	if c.client == nil {
+		return
+	}
+	c.comments = NewCommentWriter(c.client.Driver.String(), settingsGetter)
 }
 
 // SCMClient is an interface providing all functions on the Client struct.
 type SCMClient interface {
+	// Functions implemented in checks.go
+	SupportsCheckRuns() bool
+	CreateCheckRun(string, string, *CheckRunInput) (int64, error)
+	UpdateCheckRun(string, string, int64, *CheckRunInput) error
+
 	// Functions implemented in client.go
 	BotName() (string, error)
 	SetBotName(string)
@@ -28,6 +49,7 @@ type SCMClient interface {
 
 	// Functions implemented in content.go
 	GetFile(string, string, string, string) ([]byte, error)
+	ListFiles(string, string, string, string) ([]*scm.FileEntry, error)
 
 	// Functions implemented in git.go
 	GetRef(string, string, string) (string, error)
@@ -66,6 +88,7 @@ type SCMClient interface {
 	ReopenPR(string, string, int) error
 	ClosePR(string, string, int) error
 	ListAllPullRequestsForFullNameRepo(string, scm.PullRequestListOptions) ([]*scm.PullRequest, error)
+	ListPullRequests(string, PullRequestListOptions) ([]*scm.PullRequest, error)
 
 	// Functions implemented in repositories.go
 	GetRepoLabels(string, string) ([]*scm.Label, error)
@@ -79,12 +102,19 @@ type SCMClient interface {
 	GetUserPermission(string, string, string) (string, error)
 	IsMember(string, string) (bool, error)
 	GetRepositoryByFullName(string) (*scm.Repository, error)
+	ListProtectedBranches(string, string) ([]string, error)
 
 	// Functions implemented in reviews.go
 	ListReviews(string, string, int) ([]*scm.Review, error)
 	RequestReview(string, string, int, []string) error
 	UnrequestReview(string, string, int, []string) error
 
+	// Functions implemented in search.go
+	SearchAll(SearchQuery) ([]*scm.SearchIssue, error)
+
+	// Functions implemented in scopes.go
+	TokenScopes() ([]string, bool, error)
+
 	// Functions not yet implemented
 	ClearMilestone(string, string, int) error
 	SetMilestone(string, string, int, int) error
@@ -93,8 +123,28 @@ type SCMClient interface {
 
 // Client represents an interface that prow plugins expect on top of go-scm
 type Client struct {
-	client  *scm.Client
-	botName string
+	client    *scm.Client
+	botName   string
+	comments  *CommentWriter
+	settings  settings.Getter
+	ctx       context.Context
+	auditSink AuditSink
+	// shadowPlugin is the shadow plugin the client is used by, whose changes are only logged
+	shadowPlugin string
+}
+
+// Context returns the context of the requests made by the client, which defaults to the background context
+func (c *Client) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// SetContext sets the context of the requests made by the client, so that they are cancelled
+// along with it. It must not be called concurrently with requests.
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
 }
 
 // ClearMilestone clears milestone
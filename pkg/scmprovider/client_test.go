@@ -0,0 +1,40 @@
+package scmprovider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientContextCancelsRequests(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+	assert.Equal(t, context.Background(), client.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client.SetContext(ctx)
+
+	start := time.Now()
+	err = client.CreateComment("org", "repo", 1, true, "hello")
+	require.Error(t, err)
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+	assert.True(t, time.Since(start) < 10*time.Second, "the request was not cancelled")
+}
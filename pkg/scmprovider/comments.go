@@ -0,0 +1,128 @@
+package scmprovider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jenkins-x/lighthouse/pkg/jobexport"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultCommentMaxLength is the comment limit of GitHub, which is also used for the
+// providers whose limit is unknown
+const defaultCommentMaxLength = 65536
+
+// providerCommentMaxLengths are the comment limits of the git providers with a limit other than the default
+var providerCommentMaxLengths = map[string]int{
+	"gitlab":          1000000,
+	"bitbucketserver": 32768,
+	"stash":           32768,
+}
+
+// trailingTagsRegex matches the HTML comments at the end of a comment, which plugins use to find their comments
+var trailingTagsRegex = regexp.MustCompile(`(\s*<!--[^>]*-->)+\s*$`)
+
+// CommentWriter truncates comments to the limit of the git provider, writing their full content
+// to the overflow bucket of the comment settings and linking to it from the truncated comment
+type CommentWriter struct {
+	providerType string
+	settings     settings.Getter
+	newWriter    func(bucketURL string) (jobexport.Writer, error)
+}
+
+// NewCommentWriter creates a CommentWriter for the git provider using the comment settings of the getter,
+// or the limit of the provider and no overflow bucket if the getter is nil
+func NewCommentWriter(providerType string, settingsGetter settings.Getter) *CommentWriter {
+	return &CommentWriter{providerType: providerType, settings: settingsGetter, newWriter: jobexport.NewBucketWriter}
+}
+
+// config returns the current comment settings
+func (w *CommentWriter) config() settings.Comments {
+	if w.settings == nil {
+		return settings.Comments{}
+	}
+	return w.settings().Comments
+}
+
+// maxLength returns the maximum size in bytes of the comments
+func (w *CommentWriter) maxLength(cfg settings.Comments) int {
+	if cfg.MaxLength > 0 {
+		return cfg.MaxLength
+	}
+	if maxLength := providerCommentMaxLengths[w.providerType]; maxLength > 0 {
+		return maxLength
+	}
+	return defaultCommentMaxLength
+}
+
+// Write returns the comment to post on the issue or pull request, truncated if it is too long.
+// The HTML comments ending the comment are kept so that plugins can still find their comments.
+func (w *CommentWriter) Write(owner, repo string, number int, comment string) string {
+	if w == nil {
+		return comment
+	}
+	cfg := w.config()
+	maxLength := w.maxLength(cfg)
+	if len(comment) <= maxLength {
+		return comment
+	}
+	tags := trailingTagsRegex.FindString(comment)
+	body := strings.TrimSuffix(comment, tags)
+
+	notice := "\n\n---\n:scissors: This comment was truncated"
+	if link, err := w.storeOverflow(cfg, owner, repo, number, comment); err != nil {
+		logrus.WithError(err).WithField("repo", owner+"/"+repo).Warn("Failed to store the full content of a truncated comment.")
+	} else if link != "" {
+		notice += fmt.Sprintf(", see the [full comment](%s)", link)
+	}
+	notice += "."
+
+	size := maxLength - len(notice) - len(tags)
+	if size < 0 {
+		size = 0
+	}
+	return truncateUTF8(body, size) + notice + tags
+}
+
+// storeOverflow writes the full content of the comment to the overflow bucket, returning the
+// link to it or an empty string if there is no overflow bucket or URL
+func (w *CommentWriter) storeOverflow(cfg settings.Comments, owner, repo string, number int, comment string) (string, error) {
+	if cfg.OverflowBucketURL == "" {
+		return "", nil
+	}
+	write, err := w.newWriter(cfg.OverflowBucketURL)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid comment overflow bucket")
+	}
+	digest := sha256.Sum256([]byte(comment))
+	key := path.Join(owner, repo, strconv.Itoa(number), hex.EncodeToString(digest[:12])+".md")
+	if err := write(key, []byte(comment)); err != nil {
+		return "", errors.Wrapf(err, "failed to write the comment overflow %s", key)
+	}
+	if cfg.OverflowURL == "" {
+		return "", nil
+	}
+	return strings.TrimSuffix(cfg.OverflowURL, "/") + "/" + key, nil
+}
+
+// truncateUTF8 truncates s to at most size bytes without splitting a character, an invalid
+// byte counting as a character of its own
+func truncateUTF8(s string, size int) string {
+	end := 0
+	for end < len(s) {
+		_, width := utf8.DecodeRuneInString(s[end:])
+		if end+width > size {
+			break
+		}
+		end += width
+	}
+	return s[:end]
+}
@@ -0,0 +1,79 @@
+package scmprovider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/jobexport"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commentSettings(comments settings.Comments) settings.Getter {
+	return func() *settings.Config {
+		return &settings.Config{Comments: comments}
+	}
+}
+
+func TestCommentWriterLimits(t *testing.T) {
+	assert.Equal(t, defaultCommentMaxLength, NewCommentWriter("github", nil).maxLength(settings.Comments{}))
+	assert.Equal(t, 1000000, NewCommentWriter("gitlab", nil).maxLength(settings.Comments{}))
+	assert.Equal(t, defaultCommentMaxLength, NewCommentWriter("unknown", nil).maxLength(settings.Comments{}))
+	assert.Equal(t, 100, NewCommentWriter("gitlab", nil).maxLength(settings.Comments{MaxLength: 100}))
+
+	var w *CommentWriter
+	assert.Equal(t, "short", w.Write("org", "repo", 1, "short"))
+	assert.Equal(t, "short", NewCommentWriter("github", commentSettings(settings.Comments{MaxLength: 10})).Write("org", "repo", 1, "short"))
+}
+
+func TestCommentWriterTruncates(t *testing.T) {
+	written := map[string]string{}
+	var bucketURL string
+	w := NewCommentWriter("github", commentSettings(settings.Comments{
+		MaxLength:         200,
+		OverflowBucketURL: "gs://bucket/comments",
+		OverflowURL:       "https://logs.example.com/comments/",
+	}))
+	w.newWriter = func(u string) (jobexport.Writer, error) {
+		bucketURL = u
+		return func(key string, data []byte) error {
+			written[key] = string(data)
+			return nil
+		}, nil
+	}
+	comment := strings.Repeat("é", 200) + "\n<!-- lighthouse failure log -->"
+	actual := w.Write("org", "repo", 1, comment)
+
+	assert.True(t, len(actual) <= 200, "comment of %d bytes", len(actual))
+	assert.True(t, strings.HasPrefix(actual, "éé"))
+	assert.True(t, strings.HasSuffix(actual, "<!-- lighthouse failure log -->"))
+	assert.Contains(t, actual, "(https://logs.example.com/comments/org/repo/1/")
+	assert.NotContains(t, actual, "�")
+
+	assert.Equal(t, "gs://bucket/comments", bucketURL)
+	require.Len(t, written, 1)
+	for key, data := range written {
+		assert.True(t, strings.HasPrefix(key, "org/repo/1/"), key)
+		assert.Contains(t, actual, key)
+		assert.Equal(t, comment, data)
+	}
+
+	actual = NewCommentWriter("github", commentSettings(settings.Comments{MaxLength: 200})).Write("org", "repo", 1, comment)
+	assert.True(t, len(actual) <= 200)
+	assert.Contains(t, actual, "This comment was truncated.")
+
+	// an invalid bucket only loses the link
+	actual = NewCommentWriter("github", commentSettings(settings.Comments{MaxLength: 200, OverflowBucketURL: "bucket"})).Write("org", "repo", 1, comment)
+	assert.Contains(t, actual, "This comment was truncated.")
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "abc", truncateUTF8("abc", 10))
+	assert.Equal(t, "a", truncateUTF8("aé", 2))
+	assert.Equal(t, "aé", truncateUTF8("aé", 3))
+	assert.Equal(t, "", truncateUTF8("日本", 2))
+	assert.Equal(t, "日", truncateUTF8("日本", 5))
+	assert.Equal(t, "a\xff", truncateUTF8("a\xffé", 3), "invalid bytes are kept as single characters")
+	assert.Equal(t, "", truncateUTF8("abc", 0))
+}
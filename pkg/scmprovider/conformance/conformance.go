@@ -0,0 +1,338 @@
+// Package conformance exercises the scmprovider client against a git provider and
+// reports which capabilities the provider supports, so that new git kinds and
+// go-scm upgrades can be validated systematically.
+//
+// Destructive operations such as merging, closing or reopening pull requests and
+// deleting refs are never exercised. Checks which modify the target pull request,
+// e.g. adding labels or comments, only run if Target.AllowWrites is set.
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/pkg/errors"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// Supported means the check passed
+	Supported Status = "supported"
+	// Unsupported means the provider returned scm.ErrNotSupported
+	Unsupported Status = "unsupported"
+	// Failed means the check returned an error or panicked
+	Failed Status = "failed"
+	// Skipped means the check modifies the repository and writes are not allowed
+	Skipped Status = "skipped"
+)
+
+// conformanceLabel and conformanceComment are used by the checks modifying the target pull request
+const (
+	conformanceLabel   = "lighthouse-conformance"
+	conformanceComment = "Lighthouse SCM provider conformance check, this comment will be deleted."
+)
+
+// Target identifies the resources of the provider the checks are run against
+type Target struct {
+	Org  string
+	Repo string
+	// PullRequest is the number of an open pull request in the repository
+	PullRequest int
+	// Ref is a branch of the repository, defaults to the default branch
+	Ref string
+	// SHA is a commit of the repository, defaults to the head of the pull request
+	SHA string
+	// User is a collaborator of the repository
+	User string
+	// AllowWrites enables the checks which modify the pull request
+	AllowWrites bool
+}
+
+// Check exercises a capability of the provider
+type Check struct {
+	Capability string
+	// Write is true if the check modifies the target pull request
+	Write bool
+	Run   func(c *scmprovider.Client, t *Target) error
+}
+
+// Result is the result of a check
+type Result struct {
+	Capability string
+	Status     Status
+	Error      string
+	Duration   time.Duration
+}
+
+// Report is the result of running the checks against a provider
+type Report struct {
+	Provider string
+	Results  []Result
+}
+
+// Checks are the checks run against providers, in order. The first checks fill in
+// the defaults of the Target used by later checks.
+var Checks = []Check{
+	{Capability: "GetRepositoryByFullName", Run: func(c *scmprovider.Client, t *Target) error {
+		repo, err := c.GetRepositoryByFullName(scm.Join(t.Org, t.Repo))
+		if err != nil {
+			return err
+		}
+		if t.Ref == "" {
+			t.Ref = repo.Branch
+		}
+		return nil
+	}},
+	{Capability: "GetPullRequest", Run: func(c *scmprovider.Client, t *Target) error {
+		pr, err := c.GetPullRequest(t.Org, t.Repo, t.PullRequest)
+		if err != nil {
+			return err
+		}
+		if t.SHA == "" {
+			t.SHA = pr.Head.Sha
+		}
+		if t.User == "" {
+			t.User = pr.Author.Login
+		}
+		return nil
+	}},
+	{Capability: "BotName", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.BotName()
+		return err
+	}},
+	{Capability: "GetRepoLabels", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.GetRepoLabels(t.Org, t.Repo)
+		return err
+	}},
+	{Capability: "ListCollaborators", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListCollaborators(t.Org, t.Repo)
+		return err
+	}},
+	{Capability: "IsCollaborator", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.IsCollaborator(t.Org, t.Repo, t.User)
+		return err
+	}},
+	{Capability: "GetUserPermission", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.GetUserPermission(t.Org, t.Repo, t.User)
+		return err
+	}},
+	{Capability: "IsMember", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.IsMember(t.Org, t.User)
+		return err
+	}},
+	{Capability: "IsOrgAdmin", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.IsOrgAdmin(t.Org, t.User)
+		return err
+	}},
+	{Capability: "ListTeams", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListTeams(t.Org)
+		return err
+	}},
+	{Capability: "ListOrgMembers", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListOrgMembers(t.Org)
+		return err
+	}},
+	{Capability: "ListProtectedBranches", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListProtectedBranches(t.Org, t.Repo)
+		return err
+	}},
+	{Capability: "ListFiles", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListFiles(t.Org, t.Repo, "", t.Ref)
+		return err
+	}},
+	{Capability: "GetFile", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.GetFile(t.Org, t.Repo, "OWNERS", t.Ref)
+		if errors.Cause(err) == scm.ErrNotFound {
+			return nil
+		}
+		return err
+	}},
+	{Capability: "GetRef", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.GetRef(t.Org, t.Repo, "heads/"+t.Ref)
+		return err
+	}},
+	{Capability: "GetSingleCommit", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.GetSingleCommit(t.Org, t.Repo, t.SHA)
+		return err
+	}},
+	{Capability: "ListStatuses", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListStatuses(t.Org, t.Repo, t.SHA)
+		return err
+	}},
+	{Capability: "GetCombinedStatus", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.GetCombinedStatus(t.Org, t.Repo, t.SHA)
+		return err
+	}},
+	{Capability: "ListAllPullRequestsForFullNameRepo", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListAllPullRequestsForFullNameRepo(scm.Join(t.Org, t.Repo), scm.PullRequestListOptions{Open: true})
+		return err
+	}},
+	{Capability: "GetPullRequestChanges", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.GetPullRequestChanges(t.Org, t.Repo, t.PullRequest)
+		return err
+	}},
+	{Capability: "ListPullRequestComments", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListPullRequestComments(t.Org, t.Repo, t.PullRequest)
+		return err
+	}},
+	{Capability: "ListReviews", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListReviews(t.Org, t.Repo, t.PullRequest)
+		return err
+	}},
+	{Capability: "GetIssueLabels", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.GetIssueLabels(t.Org, t.Repo, t.PullRequest, true)
+		return err
+	}},
+	{Capability: "ListIssueEvents", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListIssueEvents(t.Org, t.Repo, t.PullRequest)
+		return err
+	}},
+	{Capability: "ListMilestones", Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.ListMilestones(t.Org, t.Repo)
+		return err
+	}},
+	{Capability: "CreateStatus", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		_, err := c.CreateStatus(t.Org, t.Repo, t.SHA, &scm.StatusInput{
+			State: scm.StateSuccess,
+			Label: "lighthouse-conformance",
+			Desc:  "Lighthouse SCM provider conformance check",
+		})
+		return err
+	}},
+	{Capability: "AddLabel", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		return c.AddLabel(t.Org, t.Repo, t.PullRequest, conformanceLabel, true)
+	}},
+	{Capability: "RemoveLabel", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		return c.RemoveLabel(t.Org, t.Repo, t.PullRequest, conformanceLabel, true)
+	}},
+	{Capability: "CreateComment", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		return c.CreateComment(t.Org, t.Repo, t.PullRequest, true, conformanceComment)
+	}},
+	{Capability: "EditComment", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		comment, err := findComment(c, t)
+		if err != nil {
+			return err
+		}
+		return c.EditComment(t.Org, t.Repo, t.PullRequest, comment.ID, conformanceComment, true)
+	}},
+	{Capability: "DeleteComment", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		comment, err := findComment(c, t)
+		if err != nil {
+			return err
+		}
+		return c.DeleteComment(t.Org, t.Repo, t.PullRequest, comment.ID, true)
+	}},
+	{Capability: "AssignIssue", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		return c.AssignIssue(t.Org, t.Repo, t.PullRequest, []string{t.User})
+	}},
+	{Capability: "UnassignIssue", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		return c.UnassignIssue(t.Org, t.Repo, t.PullRequest, []string{t.User})
+	}},
+	{Capability: "RequestReview", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		return c.RequestReview(t.Org, t.Repo, t.PullRequest, []string{t.User})
+	}},
+	{Capability: "UnrequestReview", Write: true, Run: func(c *scmprovider.Client, t *Target) error {
+		return c.UnrequestReview(t.Org, t.Repo, t.PullRequest, []string{t.User})
+	}},
+}
+
+// findComment finds the comment created by the CreateComment check
+func findComment(c *scmprovider.Client, t *Target) (*scm.Comment, error) {
+	comments, err := c.ListPullRequestComments(t.Org, t.Repo, t.PullRequest)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		if comment.Body == conformanceComment {
+			return comment, nil
+		}
+	}
+	return nil, errors.New("conformance comment not found")
+}
+
+// Run runs the checks against the provider of the client
+func Run(c *scmprovider.Client, target Target) *Report {
+	report := &Report{Provider: c.ProviderType()}
+	for _, check := range Checks {
+		if check.Write && !target.AllowWrites {
+			report.Results = append(report.Results, Result{Capability: check.Capability, Status: Skipped})
+			continue
+		}
+		report.Results = append(report.Results, runCheck(c, &target, check))
+	}
+	return report
+}
+
+func runCheck(c *scmprovider.Client, t *Target, check Check) (result Result) {
+	result.Capability = check.Capability
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		if r := recover(); r != nil {
+			result.Status = Failed
+			result.Error = fmt.Sprintf("panic: %v", r)
+		}
+	}()
+	err := check.Run(c, t)
+	switch {
+	case err == nil:
+		result.Status = Supported
+	case errors.Cause(err) == scm.ErrNotSupported:
+		result.Status = Unsupported
+	default:
+		result.Status = Failed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Failures returns the results of the failed checks
+func (r *Report) Failures() []Result {
+	var failures []Result
+	for _, result := range r.Results {
+		if result.Status == Failed {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// WriteMatrix writes a markdown capability matrix of the reports with a row per
+// capability and a column per provider
+func WriteMatrix(w io.Writer, reports ...*Report) error {
+	statuses := map[string]map[string]Status{}
+	var capabilities []string
+	for _, report := range reports {
+		for _, result := range report.Results {
+			if statuses[result.Capability] == nil {
+				statuses[result.Capability] = map[string]Status{}
+				capabilities = append(capabilities, result.Capability)
+			}
+			statuses[result.Capability][report.Provider] = result.Status
+		}
+	}
+	sort.Strings(capabilities)
+
+	var sb strings.Builder
+	sb.WriteString("| Capability |")
+	for _, report := range reports {
+		sb.WriteString(" " + report.Provider + " |")
+	}
+	sb.WriteString("\n|---|" + strings.Repeat("---|", len(reports)) + "\n")
+	for _, capability := range capabilities {
+		sb.WriteString("| " + capability + " |")
+		for _, report := range reports {
+			sb.WriteString(" " + string(statuses[capability][report.Provider]) + " |")
+		}
+		sb.WriteString("\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
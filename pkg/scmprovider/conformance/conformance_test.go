@@ -0,0 +1,171 @@
+package conformance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/go-scm/scm/factory"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resultsByCapability(report *Report) map[string]Result {
+	results := map[string]Result{}
+	for _, r := range report.Results {
+		results[r.Capability] = r
+	}
+	return results
+}
+
+func TestRunAgainstFakeProvider(t *testing.T) {
+	scmClient, data := fake.NewDefault()
+	data.Repositories = []*scm.Repository{{Namespace: "org", Name: "repo", FullName: "org/repo", Branch: "master"}}
+	data.PullRequests[1] = &scm.PullRequest{
+		Number: 1,
+		Author: scm.User{Login: "author"},
+		Head:   scm.PullRequestBranch{Sha: "abc"},
+	}
+	data.Commits["abc"] = &scm.Commit{Sha: "abc"}
+	client := scmprovider.ToClient(scmClient, "bot")
+
+	report := Run(client, Target{Org: "org", Repo: "repo", PullRequest: 1})
+	require.Len(t, report.Results, len(Checks))
+	results := resultsByCapability(report)
+	assert.Equal(t, Supported, results["GetRepositoryByFullName"].Status)
+	assert.Equal(t, Supported, results["GetPullRequest"].Status)
+	assert.Equal(t, Supported, results["ListStatuses"].Status)
+	assert.Equal(t, Unsupported, results["ListOrgMembers"].Status)
+	assert.Equal(t, Skipped, results["AddLabel"].Status)
+
+	report = Run(client, Target{Org: "org", Repo: "repo", PullRequest: 1, AllowWrites: true})
+	results = resultsByCapability(report)
+	assert.Equal(t, Supported, results["AddLabel"].Status)
+	assert.Equal(t, Unsupported, results["RequestReview"].Status)
+	assert.Equal(t, Failed, results["UnassignIssue"].Status)
+	assert.Contains(t, results["UnassignIssue"].Error, "panic")
+	assert.NotEmpty(t, report.Failures())
+
+	var sb strings.Builder
+	require.NoError(t, WriteMatrix(&sb, report))
+	assert.Contains(t, sb.String(), "| Capability | "+report.Provider+" |\n|---|---|\n")
+	assert.Contains(t, sb.String(), "| AddLabel | supported |\n")
+}
+
+// TestRunAgainstProvider runs the checks against a real provider configured with
+// the CONFORMANCE_GIT_KIND, CONFORMANCE_GIT_SERVER, CONFORMANCE_GIT_TOKEN, CONFORMANCE_REPO
+// and CONFORMANCE_PULL_REQUEST environment variables, writing the capability matrix
+// to the test log. Set CONFORMANCE_ALLOW_WRITES=true to also run the checks which
+// modify the pull request.
+//
+// Set CONFORMANCE_RECORD to the path of a cassette to record the run to, which can then be
+// replayed without access to the provider by setting CONFORMANCE_REPLAY to its path instead
+// of the other environment variables.
+func TestRunAgainstProvider(t *testing.T) {
+	var cassette *Cassette
+	var recorder *Recorder
+	if path := os.Getenv("CONFORMANCE_REPLAY"); path != "" {
+		var err error
+		cassette, err = LoadCassette(path)
+		require.NoError(t, err)
+		recorder = NewReplayer(cassette)
+	} else {
+		kind := os.Getenv("CONFORMANCE_GIT_KIND")
+		if kind == "" {
+			t.Skip("neither CONFORMANCE_GIT_KIND nor CONFORMANCE_REPLAY is set")
+		}
+		org, repo := scm.Split(os.Getenv("CONFORMANCE_REPO"))
+		number, err := strconv.Atoi(os.Getenv("CONFORMANCE_PULL_REQUEST"))
+		require.NoError(t, err, "invalid CONFORMANCE_PULL_REQUEST")
+		cassette = &Cassette{
+			Kind:   kind,
+			Server: os.Getenv("CONFORMANCE_GIT_SERVER"),
+			Target: Target{
+				Org:         org,
+				Repo:        repo,
+				PullRequest: number,
+				AllowWrites: os.Getenv("CONFORMANCE_ALLOW_WRITES") == "true",
+			},
+		}
+	}
+
+	scmClient, err := factory.NewClient(cassette.Kind, cassette.Server, "")
+	require.NoError(t, err)
+	if recorder == nil {
+		util.AddAuthToSCMClient(scmClient, os.Getenv("CONFORMANCE_GIT_TOKEN"), false)
+		if path := os.Getenv("CONFORMANCE_RECORD"); path != "" {
+			recorder = NewRecorder(cassette, scmClient.Client.Transport)
+			defer func() {
+				require.NoError(t, cassette.Save(path))
+			}()
+		}
+	}
+	if recorder != nil {
+		scmClient.Client = &http.Client{Transport: recorder}
+	}
+	client := scmprovider.ToClient(scmClient, "")
+
+	report := Run(client, cassette.Target)
+	var sb strings.Builder
+	require.NoError(t, WriteMatrix(&sb, report))
+	t.Log("\n" + sb.String())
+	for _, failure := range report.Failures() {
+		t.Errorf("%s failed: %s", failure.Capability, failure.Error)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		switch r.URL.Path {
+		case "/repos/org/repo":
+			fmt.Fprint(w, `{"name": "repo", "full_name": "org/repo", "owner": {"login": "org"}, "default_branch": "main"}`)
+		case "/repos/org/repo/branches":
+			fmt.Fprint(w, `[{"name": "main"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	target := Target{Org: "org", Repo: "repo", PullRequest: 1}
+	cassette := &Cassette{Kind: "github", Server: server.URL, Target: target}
+	scmClient, err := factory.NewClient(cassette.Kind, cassette.Server, "")
+	require.NoError(t, err)
+	scmClient.Client = &http.Client{Transport: NewRecorder(cassette, nil)}
+	recorded := Run(scmprovider.ToClient(scmClient, "bot"), target)
+	assert.Equal(t, Supported, resultsByCapability(recorded)["ListProtectedBranches"].Status)
+	require.Len(t, cassette.Interactions, requests)
+	assert.Empty(t, cassette.Interactions[0].Header.Get("Set-Cookie"), "cookies are not recorded")
+
+	dir, err := ioutil.TempDir("", "conformance")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+	require.NoError(t, cassette.Save(path))
+	server.Close()
+
+	loaded, err := LoadCassette(path)
+	require.NoError(t, err)
+	scmClient, err = factory.NewClient(loaded.Kind, loaded.Server, "")
+	require.NoError(t, err)
+	scmClient.Client = &http.Client{Transport: NewReplayer(loaded)}
+	replayed := Run(scmprovider.ToClient(scmClient, "bot"), loaded.Target)
+	for i, result := range replayed.Results {
+		assert.Equal(t, recorded.Results[i].Status, result.Status, result.Capability)
+	}
+}
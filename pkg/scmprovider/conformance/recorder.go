@@ -0,0 +1,144 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Interaction is an HTTP request made to a provider and its response
+type Interaction struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"requestBody,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body,omitempty"`
+}
+
+// Cassette is a conformance run recorded against a provider, so that it can be replayed
+// without access to the provider, e.g. to check a go-scm upgrade in CI
+type Cassette struct {
+	Kind         string        `json:"kind"`
+	Server       string        `json:"server,omitempty"`
+	Target       Target        `json:"target"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette loads the cassette saved at the given path
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read cassette %s", path)
+	}
+	cassette := &Cassette{}
+	if err := json.Unmarshal(data, cassette); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse cassette %s", path)
+	}
+	return cassette, nil
+}
+
+// Save saves the cassette at the given path
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal cassette")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, data, 0600), "failed to write cassette %s", path)
+}
+
+// unrecordedHeaders are the response headers which are not recorded as they are either
+// credentials or irrelevant to the checks
+var unrecordedHeaders = []string{"Set-Cookie", "Date", "X-Github-Request-Id", "X-Request-Id"}
+
+// Recorder is an http.RoundTripper recording the interactions with a provider in a cassette
+// when it has a transport, or replaying the interactions of the cassette when it has not.
+// Requests are replayed in the order they were recorded, so that the same request can get
+// different responses.
+type Recorder struct {
+	Cassette *Cassette
+
+	transport http.RoundTripper
+	lock      sync.Mutex
+	replayed  map[int]bool
+}
+
+// NewRecorder returns a recorder adding the interactions made with the transport to the cassette
+func NewRecorder(cassette *Cassette, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{Cassette: cassette, transport: transport}
+}
+
+// NewReplayer returns a recorder replaying the interactions of the cassette
+func NewReplayer(cassette *Cassette) *Recorder {
+	return &Recorder{Cassette: cassette, replayed: map[int]bool{}}
+}
+
+// RoundTrip records or replays the request
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		requestBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read request body")
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	}
+	if r.replayed != nil {
+		return r.replay(req, string(requestBody))
+	}
+
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response body")
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	header := res.Header.Clone()
+	for _, h := range unrecordedHeaders {
+		header.Del(h)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Cassette.Interactions = append(r.Cassette.Interactions, Interaction{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(requestBody),
+		Status:      res.StatusCode,
+		Header:      header,
+		Body:        string(body),
+	})
+	return res, nil
+}
+
+func (r *Recorder) replay(req *http.Request, requestBody string) (*http.Response, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, interaction := range r.Cassette.Interactions {
+		if r.replayed[i] || interaction.Method != req.Method || interaction.URL != req.URL.String() || interaction.RequestBody != requestBody {
+			continue
+		}
+		r.replayed[i] = true
+		return &http.Response{
+			Status:     http.StatusText(interaction.Status),
+			StatusCode: interaction.Status,
+			Header:     interaction.Header.Clone(),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(interaction.Body))),
+			Request:    req,
+		}, nil
+	}
+	return nil, errors.Errorf("no recorded interaction for %s %s", req.Method, req.URL.String())
+}
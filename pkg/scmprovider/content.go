@@ -1,12 +1,12 @@
 package scmprovider
 
 import (
-	"context"
+	"github.com/jenkins-x/go-scm/scm"
 )
 
 // GetFile retruns the file from GitHub
 func (c *Client) GetFile(owner, repo, filepath, commit string) ([]byte, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	answer, _, err := c.client.Contents.Find(ctx, fullName, filepath, commit)
 	var data []byte
@@ -15,3 +15,11 @@ func (c *Client) GetFile(owner, repo, filepath, commit string) ([]byte, error) {
 	}
 	return data, err
 }
+
+// ListFiles returns the files and directories in the given directory of the repository
+func (c *Client) ListFiles(owner, repo, filepath, commit string) ([]*scm.FileEntry, error) {
+	ctx := c.Context()
+	fullName := c.repositoryName(owner, repo)
+	answer, _, err := c.client.Contents.List(ctx, fullName, filepath, commit)
+	return answer, err
+}
@@ -91,6 +91,11 @@ type SCMClient struct {
 
 	// A list of refs that got deleted via DeleteRef
 	RefsDeleted []struct{ Org, Repo, Ref string }
+
+	// Whether check runs are supported, and the check runs created or updated,
+	// keyed by their ID
+	CheckRunsSupported bool
+	CheckRuns          map[int64]*scmprovider.CheckRunInput
 }
 
 // ProviderType returns the provider type
@@ -263,6 +268,33 @@ func (f *SCMClient) GetSingleCommit(org, repo, SHA string) (*scm.Commit, error)
 	return f.Commits[SHA], nil
 }
 
+// SupportsCheckRuns returns whether the provider supports check runs
+func (f *SCMClient) SupportsCheckRuns() bool {
+	return f.CheckRunsSupported
+}
+
+// CreateCheckRun creates a check run
+func (f *SCMClient) CreateCheckRun(owner, repo string, input *scmprovider.CheckRunInput) (int64, error) {
+	if !f.CheckRunsSupported {
+		return 0, scm.ErrNotSupported
+	}
+	if f.CheckRuns == nil {
+		f.CheckRuns = make(map[int64]*scmprovider.CheckRunInput)
+	}
+	id := int64(len(f.CheckRuns) + 1)
+	f.CheckRuns[id] = input
+	return id, nil
+}
+
+// UpdateCheckRun updates a check run
+func (f *SCMClient) UpdateCheckRun(owner, repo string, id int64, input *scmprovider.CheckRunInput) error {
+	if _, ok := f.CheckRuns[id]; !ok {
+		return fmt.Errorf("check run %d not found", id)
+	}
+	f.CheckRuns[id] = input
+	return nil
+}
+
 // CreateStatus adds a status context to a commit.
 func (f *SCMClient) CreateStatus(owner, repo, SHA string, s *scm.StatusInput) (*scm.Status, error) {
 	if f.CreatedStatuses == nil {
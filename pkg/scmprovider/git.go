@@ -1,14 +1,13 @@
 package scmprovider
 
 import (
-	"context"
-
 	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
 )
 
 // GetRef retruns the ref from repository
 func (c *Client) GetRef(owner, repo, ref string) (string, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	answer, _, err := c.client.Git.FindRef(ctx, fullName, ref)
 	return answer, err
@@ -16,7 +15,10 @@ func (c *Client) GetRef(owner, repo, ref string) (string, error) {
 
 // DeleteRef deletes the ref from repository
 func (c *Client) DeleteRef(owner, repo, ref string) error {
-	ctx := context.Background()
+	if c.readOnly("deleting the ref "+ref, logrus.Fields{"repo": c.repositoryName(owner, repo)}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	_, err := c.client.Git.DeleteRef(ctx, fullName, ref)
 	return err
@@ -24,7 +26,7 @@ func (c *Client) DeleteRef(owner, repo, ref string) error {
 
 // GetSingleCommit returns a single commit
 func (c *Client) GetSingleCommit(owner, repo, SHA string) (*scm.Commit, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	commit, _, err := c.client.Git.FindCommit(ctx, fullName, SHA)
 	return commit, err
@@ -0,0 +1,394 @@
+package scmprovider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+)
+
+// The go-scm driver of Gitea does not support the labels, reviews, combined statuses, membership checks or
+// comment edits of the Gitea API, so the client calls the Gitea API directly for them.
+
+const (
+	// giteaEventHeader is the request header containing the event of Gitea webhooks
+	giteaEventHeader = "X-Gitea-Event"
+	// giteaEventTypeHeader is the request header containing the detailed event type of Gitea webhooks,
+	// e.g. pull_request_review_approved for the pull_request_approved event
+	giteaEventTypeHeader = "X-Gitea-Event-Type"
+
+	// giteaReviewEventTypePrefix is the prefix of the event types of the review events of Gitea webhooks
+	giteaReviewEventTypePrefix = "pull_request_review_"
+
+	// giteaPageSize is the page size of the lists requested from Gitea
+	giteaPageSize = 50
+	// giteaLabelColor is the color of the labels created when they are added to an issue or pull request
+	giteaLabelColor = "#ededed"
+)
+
+// giteaReviewStates maps the states of Gitea reviews to the review states of go-scm
+var giteaReviewStates = map[string]string{
+	"APPROVED":        scm.ReviewStateApproved,
+	"REQUEST_CHANGES": scm.ReviewStateChangesRequested,
+	"COMMENT":         scm.ReviewStateCommented,
+	"PENDING":         scm.ReviewStatePending,
+}
+
+// giteaReviewEvents maps the review events of Gitea webhooks to the state of the submitted review
+var giteaReviewEvents = map[string]string{
+	"pull_request_approved":        scm.ReviewStateApproved,
+	"pull_request_rejected":        scm.ReviewStateChangesRequested,
+	"pull_request_comment":         scm.ReviewStateCommented,
+	"pull_request_review_approved": scm.ReviewStateApproved,
+	"pull_request_review_rejected": scm.ReviewStateChangesRequested,
+	"pull_request_review_comment":  scm.ReviewStateCommented,
+}
+
+type giteaLabel struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+}
+
+type giteaIssue struct {
+	Number int           `json:"number"`
+	Labels []*giteaLabel `json:"labels"`
+}
+
+type giteaUser struct {
+	Login     string `json:"login"`
+	FullName  string `json:"full_name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type giteaReview struct {
+	ID          int       `json:"id"`
+	User        giteaUser `json:"user"`
+	Body        string    `json:"body"`
+	CommitID    string    `json:"commit_id"`
+	State       string    `json:"state"`
+	HTMLURL     string    `json:"html_url"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+type giteaStatus struct {
+	State       string `json:"status"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url"`
+	URL         string `json:"url"`
+}
+
+type giteaCombinedStatus struct {
+	State    string         `json:"state"`
+	SHA      string         `json:"sha"`
+	Statuses []*giteaStatus `json:"statuses"`
+}
+
+// isGitea returns true if the git provider is Gitea
+func (c *Client) isGitea() bool {
+	return c.client != nil && c.client.Driver == scm.DriverGitea
+}
+
+// giteaRequest sends a request to the Gitea API, returning its status code. Statuses above 299 are errors
+// unless they are in allowedStatuses.
+func (c *Client) giteaRequest(method, path string, input, output interface{}, allowedStatuses ...int) (int, error) {
+	req := &scm.Request{
+		Method: method,
+		Path:   "api/v1/" + path,
+		Header: map[string][]string{
+			"Accept": {"application/json"},
+		},
+	}
+	if input != nil {
+		body, err := json.Marshal(input)
+		if err != nil {
+			return 0, err
+		}
+		req.Header["Content-Type"] = []string{"application/json"}
+		req.Body = bytes.NewReader(body)
+	}
+	res, err := c.client.Do(c.Context(), req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.Status > 299 {
+		for _, status := range allowedStatuses {
+			if res.Status == status {
+				return res.Status, nil
+			}
+		}
+		return res.Status, errors.Errorf("status %d", res.Status)
+	}
+	if output == nil || res.Status == http.StatusNoContent {
+		return res.Status, nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(output); err != nil && err != io.EOF {
+		return res.Status, err
+	}
+	return res.Status, nil
+}
+
+// giteaListLabels lists every label of a path, page by page
+func (c *Client) giteaListLabels(path string) ([]*scm.Label, error) {
+	var answer []*scm.Label
+	for page := 1; ; page++ {
+		var labels []*giteaLabel
+		if _, err := c.giteaRequest(http.MethodGet, fmt.Sprintf("%s?page=%d&limit=%d", path, page, giteaPageSize), nil, &labels); err != nil {
+			return nil, err
+		}
+		answer = append(answer, toSCMLabels(labels)...)
+		if len(labels) < giteaPageSize {
+			return answer, nil
+		}
+	}
+}
+
+func toSCMLabels(labels []*giteaLabel) []*scm.Label {
+	var answer []*scm.Label
+	for _, l := range labels {
+		answer = append(answer, &scm.Label{ID: l.ID, Name: l.Name, Color: l.Color, Description: l.Description, URL: l.URL})
+	}
+	return answer
+}
+
+// giteaPullRequestLabels returns the labels of the pull requests of the state, keyed by number. The pull
+// requests of Gitea are listed without their labels, unlike their issues, so they are listed as issues once
+// instead of listing the labels of each pull request.
+func (c *Client) giteaPullRequestLabels(fullName string, opts scm.PullRequestListOptions) (map[int][]*scm.Label, error) {
+	state := "open"
+	if opts.Closed {
+		state = "closed"
+		if opts.Open {
+			state = "all"
+		}
+	}
+	answer := map[int][]*scm.Label{}
+	for page := 1; ; page++ {
+		var issues []*giteaIssue
+		path := fmt.Sprintf("repos/%s/issues?type=pulls&state=%s&page=%d&limit=%d", fullName, state, page, giteaPageSize)
+		if _, err := c.giteaRequest(http.MethodGet, path, nil, &issues); err != nil {
+			return nil, errors.Wrapf(err, "failed to list the labels of the pull requests of %s", fullName)
+		}
+		for _, issue := range issues {
+			answer[issue.Number] = toSCMLabels(issue.Labels)
+		}
+		if len(issues) < giteaPageSize {
+			return answer, nil
+		}
+	}
+}
+
+// giteaIssueLabels returns the labels of an issue or pull request
+func (c *Client) giteaIssueLabels(fullName string, number int) ([]*scm.Label, error) {
+	labels, err := c.giteaListLabels(fmt.Sprintf("repos/%s/issues/%d/labels", fullName, number))
+	return labels, errors.Wrapf(err, "failed to list the labels of %s#%d", fullName, number)
+}
+
+// giteaRepoLabel returns the label of the repository with the name, as Gitea adds and removes labels by ID.
+// The label is created if the repository has none with the name, like GitHub does when adding labels.
+func (c *Client) giteaRepoLabel(fullName, name string) (*scm.Label, error) {
+	labels, err := c.giteaListLabels(fmt.Sprintf("repos/%s/labels", fullName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the labels of %s", fullName)
+	}
+	for _, l := range labels {
+		if l.Name == name {
+			return l, nil
+		}
+	}
+	created := giteaLabel{}
+	input := map[string]string{"name": name, "color": giteaLabelColor}
+	if _, err := c.giteaRequest(http.MethodPost, fmt.Sprintf("repos/%s/labels", fullName), input, &created); err != nil {
+		return nil, errors.Wrapf(err, "failed to create label %s in %s", name, fullName)
+	}
+	return &scm.Label{ID: created.ID, Name: created.Name, Color: created.Color}, nil
+}
+
+// giteaAddLabel adds a label of the repository to an issue or pull request
+func (c *Client) giteaAddLabel(fullName string, number int, name string) error {
+	label, err := c.giteaRepoLabel(fullName, name)
+	if err != nil {
+		return err
+	}
+	input := map[string][]int64{"labels": {label.ID}}
+	_, err = c.giteaRequest(http.MethodPost, fmt.Sprintf("repos/%s/issues/%d/labels", fullName, number), input, nil)
+	return errors.Wrapf(err, "failed to add label %s to %s#%d", name, fullName, number)
+}
+
+// giteaRemoveLabel removes a label from an issue or pull request
+func (c *Client) giteaRemoveLabel(fullName string, number int, name string) error {
+	labels, err := c.giteaIssueLabels(fullName, number)
+	if err != nil {
+		return err
+	}
+	for _, l := range labels {
+		if l.Name == name {
+			_, err = c.giteaRequest(http.MethodDelete, fmt.Sprintf("repos/%s/issues/%d/labels/%d", fullName, number, l.ID), nil, nil)
+			return errors.Wrapf(err, "failed to remove label %s from %s#%d", name, fullName, number)
+		}
+	}
+	return nil
+}
+
+// giteaEditComment edits a comment of an issue or pull request, which share their comments in Gitea
+func (c *Client) giteaEditComment(fullName string, id int, body string) error {
+	input := map[string]string{"body": body}
+	_, err := c.giteaRequest(http.MethodPatch, fmt.Sprintf("repos/%s/issues/comments/%d", fullName, id), input, nil)
+	return errors.Wrapf(err, "failed to edit comment %d of %s", id, fullName)
+}
+
+// giteaExists returns true if the path exists, which is how Gitea answers membership checks
+func (c *Client) giteaExists(path string) (bool, error) {
+	status, err := c.giteaRequest(http.MethodGet, path, nil, nil, http.StatusNotFound)
+	if err != nil {
+		return false, err
+	}
+	return status != http.StatusNotFound, nil
+}
+
+// giteaCombinedStatus returns the combined status of a ref
+func (c *Client) giteaCombinedStatus(fullName, ref string) (*scm.CombinedStatus, error) {
+	combined := giteaCombinedStatus{}
+	if _, err := c.giteaRequest(http.MethodGet, fmt.Sprintf("repos/%s/commits/%s/status", fullName, ref), nil, &combined); err != nil {
+		return nil, errors.Wrapf(err, "failed to get the combined status of %s in %s", ref, fullName)
+	}
+	answer := &scm.CombinedStatus{
+		State: scm.ToState(combined.State),
+		Sha:   combined.SHA,
+	}
+	for _, s := range combined.Statuses {
+		answer.Statuses = append(answer.Statuses, &scm.Status{
+			State:  scm.ToState(s.State),
+			Label:  s.Context,
+			Desc:   s.Description,
+			Target: s.TargetURL,
+			Link:   s.URL,
+		})
+	}
+	return answer, nil
+}
+
+// giteaListReviews lists the reviews of a pull request, with their states mapped to the states of go-scm
+func (c *Client) giteaListReviews(fullName string, number int) ([]*scm.Review, error) {
+	var answer []*scm.Review
+	for page := 1; ; page++ {
+		var reviews []*giteaReview
+		if _, err := c.giteaRequest(http.MethodGet, fmt.Sprintf("repos/%s/pulls/%d/reviews?page=%d&limit=%d", fullName, number, page, giteaPageSize), nil, &reviews); err != nil {
+			return nil, errors.Wrapf(err, "failed to list the reviews of %s#%d", fullName, number)
+		}
+		for _, r := range reviews {
+			state := giteaReviewStates[r.State]
+			if state == "" {
+				// e.g. the REQUEST_REVIEW placeholders of the requested reviewers
+				continue
+			}
+			answer = append(answer, &scm.Review{
+				ID:      r.ID,
+				Body:    r.Body,
+				Sha:     r.CommitID,
+				Link:    r.HTMLURL,
+				State:   state,
+				Author:  scm.User{Login: r.User.Login, Name: r.User.FullName, Email: r.User.Email, Avatar: r.User.AvatarURL},
+				Created: r.SubmittedAt,
+				Updated: r.SubmittedAt,
+			})
+		}
+		if len(reviews) < giteaPageSize {
+			return answer, nil
+		}
+	}
+}
+
+// giteaRequestReview requests or unrequests the review of a pull request from users
+func (c *Client) giteaRequestReview(method, fullName string, number int, logins []string) error {
+	input := map[string][]string{"reviewers": logins}
+	_, err := c.giteaRequest(method, fmt.Sprintf("repos/%s/pulls/%d/requested_reviewers", fullName, number), input, nil)
+	return err
+}
+
+type giteaReviewPayload struct {
+	Review struct {
+		Type    string `json:"type"`
+		Content string `json:"content"`
+	} `json:"review"`
+	CommitID string `json:"commit_id"`
+}
+
+// IsGiteaReviewWebhook returns true if the request is a Gitea webhook of a submitted review, which go-scm
+// does not parse
+func IsGiteaReviewWebhook(r *http.Request) bool {
+	_, ok := giteaReviewEvents[giteaReviewEvent(r)]
+	return ok
+}
+
+// giteaReviewEvent returns the review event of a Gitea webhook, which depending on the version of Gitea
+// is only in the event type header. The event type header is only consulted for review events, as it
+// also names other events, such as pull_request_comment for the comments of pull requests.
+func giteaReviewEvent(r *http.Request) string {
+	event := r.Header.Get(giteaEventHeader)
+	if _, ok := giteaReviewEvents[event]; ok {
+		return event
+	}
+	if eventType := r.Header.Get(giteaEventTypeHeader); strings.HasPrefix(eventType, giteaReviewEventTypePrefix) {
+		return eventType
+	}
+	return event
+}
+
+// ParseGiteaReviewWebhook parses a Gitea webhook of a submitted review into a review hook. The payload is
+// parsed and its signature verified as a pull request webhook by go-scm first, as Gitea sends the pull
+// request along with the review.
+func ParseGiteaReviewWebhook(client *scm.Client, r *http.Request, payload []byte, fn scm.SecretFunc) (*scm.ReviewHook, error) {
+	event := giteaReviewEvent(r)
+	state, ok := giteaReviewEvents[event]
+	if !ok {
+		return nil, scm.UnknownWebhook{Event: event}
+	}
+	req := r.Clone(r.Context())
+	req.Header.Set(giteaEventHeader, "pull_request")
+	req.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	hook, err := client.Webhooks.Parse(req, fn)
+	if err != nil {
+		return nil, err
+	}
+	prHook, ok := hook.(*scm.PullRequestHook)
+	if !ok {
+		return nil, errors.Errorf("unexpected webhook %T for Gitea event %s", hook, event)
+	}
+	data := giteaReviewPayload{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, errors.Wrap(err, "failed to parse review webhook payload")
+	}
+	repo := prHook.Repo
+	if repo.FullName == "" {
+		repo.FullName = scm.Join(repo.Namespace, repo.Name)
+	}
+	sha := data.CommitID
+	if sha == "" {
+		sha = prHook.PullRequest.Sha
+	}
+	return &scm.ReviewHook{
+		Action:      scm.ActionSubmitted,
+		PullRequest: prHook.PullRequest,
+		Repo:        repo,
+		Review: scm.Review{
+			Body:   data.Review.Content,
+			Sha:    sha,
+			Link:   prHook.PullRequest.Link,
+			State:  state,
+			Author: prHook.Sender,
+		},
+	}, nil
+}
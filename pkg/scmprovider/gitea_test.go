@@ -0,0 +1,186 @@
+package scmprovider
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/gitea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGiteaAPI(t *testing.T) {
+	var requests []string
+	var bodies []string
+	responses := map[string]string{
+		"GET /api/v1/repos/org/repo/labels":                `[{"id": 1, "name": "lgtm"}, {"id": 2, "name": "approved"}]`,
+		"GET /api/v1/repos/org/repo/issues/3/labels":       `[{"id": 2, "name": "approved"}]`,
+		"GET /api/v1/repos/org/repo/commits/abc123/status": `{"state": "failure", "sha": "abc123", "statuses": [{"status": "failure", "context": "pr-build", "target_url": "https://dashboard/1"}]}`,
+		"GET /api/v1/repos/org/repo/pulls/3/reviews":       `[{"id": 5, "user": {"login": "alice"}, "state": "APPROVED", "commit_id": "abc123"}, {"id": 6, "user": {"login": "bob"}, "state": "REQUEST_CHANGES"}, {"id": 7, "user": {"login": "carol"}, "state": "REQUEST_REVIEW"}]`,
+		"POST /api/v1/repos/org/repo/labels":               `{"id": 3, "name": "hold"}`,
+		"GET /api/v1/repos/org/repo/pulls":                 `[{"number": 3, "state": "open"}, {"number": 4, "state": "open"}, {"number": 5, "state": "open"}]`,
+		"GET /api/v1/repos/org/repo/issues":                `[{"number": 3, "labels": [{"id": 1, "name": "lgtm"}]}, {"number": 5, "labels": [{"id": 1, "name": "lgtm"}]}]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+		requests = append(requests, request)
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) > 0 {
+			bodies = append(bodies, string(bytes.TrimSpace(body)))
+		}
+		switch r.URL.Path {
+		case "/api/v1/repos/org/repo/collaborators/alice", "/api/v1/orgs/org/members/alice":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/api/v1/repos/org/repo/collaborators/mallory", "/api/v1/orgs/org/members/mallory":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if response, ok := responses[request]; ok {
+			fmt.Fprint(w, response)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	scmClient, err := gitea.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+
+	labels, err := client.GetIssueLabels("org", "repo", 3, true)
+	require.NoError(t, err)
+	require.Len(t, labels, 1)
+	assert.Equal(t, "approved", labels[0].Name)
+
+	require.NoError(t, client.AddLabel("org", "repo", 3, "lgtm", true))
+	require.NoError(t, client.RemoveLabel("org", "repo", 3, "approved", true))
+	require.NoError(t, client.RemoveLabel("org", "repo", 3, "hold", true), "removing a missing label is a no-op")
+	require.NoError(t, client.AddLabel("org", "repo", 3, "hold", true), "the missing labels are created")
+
+	require.NoError(t, client.EditComment("org", "repo", 3, 9, "edited", true))
+
+	for _, login := range []string{"alice", "mallory"} {
+		collaborator, err := client.IsCollaborator("org", "repo", login)
+		require.NoError(t, err)
+		assert.Equal(t, login == "alice", collaborator, login)
+		member, err := client.IsMember("org", login)
+		require.NoError(t, err)
+		assert.Equal(t, login == "alice", member, login)
+	}
+
+	combined, err := client.GetCombinedStatus("org", "repo", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, scm.StateFailure, combined.State)
+	require.Len(t, combined.Statuses, 1)
+	assert.Equal(t, scm.Status{State: scm.StateFailure, Label: "pr-build", Target: "https://dashboard/1"}, *combined.Statuses[0])
+
+	reviews, err := client.ListReviews("org", "repo", 3)
+	require.NoError(t, err)
+	require.Len(t, reviews, 2, "the placeholders of the requested reviews are not reviews")
+	assert.Equal(t, scm.ReviewStateApproved, reviews[0].State)
+	assert.Equal(t, "alice", reviews[0].Author.Login)
+	assert.Equal(t, "abc123", reviews[0].Sha)
+	assert.Equal(t, scm.ReviewStateChangesRequested, reviews[1].State)
+
+	require.NoError(t, client.RequestReview("org", "repo", 3, []string{"carol"}))
+
+	prs, err := client.ListPullRequests("org/repo", PullRequestListOptions{PullRequestListOptions: scm.PullRequestListOptions{Labels: []string{"lgtm"}}})
+	require.NoError(t, err)
+	require.Len(t, prs, 2)
+	assert.Equal(t, 3, prs[0].Number)
+	assert.Equal(t, 5, prs[1].Number)
+
+	assert.Equal(t, []string{
+		"GET /api/v1/repos/org/repo/issues/3/labels",
+		"GET /api/v1/repos/org/repo/labels",
+		"POST /api/v1/repos/org/repo/issues/3/labels",
+		"GET /api/v1/repos/org/repo/issues/3/labels",
+		"DELETE /api/v1/repos/org/repo/issues/3/labels/2",
+		"GET /api/v1/repos/org/repo/issues/3/labels",
+		"GET /api/v1/repos/org/repo/labels",
+		"POST /api/v1/repos/org/repo/labels",
+		"POST /api/v1/repos/org/repo/issues/3/labels",
+		"PATCH /api/v1/repos/org/repo/issues/comments/9",
+		"GET /api/v1/repos/org/repo/collaborators/alice",
+		"GET /api/v1/orgs/org/members/alice",
+		"GET /api/v1/repos/org/repo/collaborators/mallory",
+		"GET /api/v1/orgs/org/members/mallory",
+		"GET /api/v1/repos/org/repo/commits/abc123/status",
+		"GET /api/v1/repos/org/repo/pulls/3/reviews",
+		"POST /api/v1/repos/org/repo/pulls/3/requested_reviewers",
+		"GET /api/v1/repos/org/repo/pulls",
+		"GET /api/v1/repos/org/repo/issues",
+	}, requests, "the labels of the pull requests are listed at once")
+	assert.Equal(t, []string{`{"labels":[1]}`, `{"color":"#ededed","name":"hold"}`, `{"labels":[3]}`, `{"body":"edited"}`, `{"reviewers":["carol"]}`}, bodies)
+}
+
+func TestParseGiteaReviewWebhook(t *testing.T) {
+	scmClient, err := gitea.New("https://gitea.example.com")
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"action":       "reviewed",
+		"number":       3,
+		"pull_request": map[string]interface{}{"number": 3, "title": "Fix", "head": map[string]interface{}{"sha": "abc123"}, "html_url": "https://gitea.example.com/org/repo/pulls/3"},
+		"repository":   map[string]interface{}{"name": "repo", "full_name": "org/repo", "owner": map[string]interface{}{"login": "org"}},
+		"sender":       map[string]interface{}{"login": "alice"},
+		"review":       map[string]interface{}{"type": "pull_request_review_approved", "content": "looks good"},
+	})
+	require.NoError(t, err)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+	signature := hex.EncodeToString(mac.Sum(nil))
+	secret := func(scm.Webhook) (string, error) {
+		return "secret", nil
+	}
+	request := func(event, signature string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(payload))
+		r.Header.Set("X-Gitea-Event", event)
+		r.Header.Set("X-Gitea-Signature", signature)
+		return r
+	}
+
+	r := request("pull_request_approved", signature)
+	require.True(t, IsGiteaReviewWebhook(r))
+	hook, err := ParseGiteaReviewWebhook(scmClient, r, payload, secret)
+	require.NoError(t, err)
+	assert.Equal(t, scm.ActionSubmitted, hook.Action)
+	assert.Equal(t, 3, hook.PullRequest.Number)
+	assert.Equal(t, "org/repo", hook.Repo.FullName)
+	assert.Equal(t, scm.Review{
+		Body:   "looks good",
+		Sha:    "abc123",
+		Link:   "https://gitea.example.com/org/repo/pulls/3",
+		State:  scm.ReviewStateApproved,
+		Author: hook.Review.Author,
+	}, hook.Review)
+	assert.Equal(t, "alice", hook.Review.Author.Login)
+
+	r = request("pull_request_rejected", signature)
+	hook, err = ParseGiteaReviewWebhook(scmClient, r, payload, secret)
+	require.NoError(t, err)
+	assert.Equal(t, scm.ReviewStateChangesRequested, hook.Review.State)
+
+	r = request("pull_request_approved", "invalid")
+	_, err = ParseGiteaReviewWebhook(scmClient, r, payload, secret)
+	assert.Equal(t, scm.ErrSignatureInvalid, err)
+
+	r = request("push", signature)
+	assert.False(t, IsGiteaReviewWebhook(r))
+	r.Header.Set("X-Gitea-Event-Type", "pull_request_review_comment")
+	assert.True(t, IsGiteaReviewWebhook(r), "the event type header names the review events of newer versions of Gitea")
+	r = request("issue_comment", signature)
+	r.Header.Set("X-Gitea-Event-Type", "pull_request_comment")
+	assert.False(t, IsGiteaReviewWebhook(r), "the comments of pull requests are not reviews")
+}
@@ -53,7 +53,7 @@ func (c *Client) Query(ctx context.Context, q interface{}, vars map[string]inter
 
 // Search query issues/PRs using a query string
 func (c *Client) Search(opts scm.SearchOptions) ([]*scm.SearchIssue, *RateLimits, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	results, res, err := c.client.Issues.Search(ctx, opts)
 
 	rates := &RateLimits{}
@@ -63,7 +63,7 @@ func (c *Client) Search(opts scm.SearchOptions) ([]*scm.SearchIssue, *RateLimits
 
 // ListIssueEvents list issue events
 func (c *Client) ListIssueEvents(org, repo string, number int) ([]*scm.ListedIssueEvent, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(org, repo)
 	var allEvents []*scm.ListedIssueEvent
 	var resp *scm.Response
@@ -87,7 +87,10 @@ func (c *Client) ListIssueEvents(org, repo string, number int) ([]*scm.ListedIss
 
 // AssignIssue assigns issue
 func (c *Client) AssignIssue(owner, repo string, number int, logins []string) error {
-	ctx := context.Background()
+	if c.readOnly("assigning the issue", logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number, "logins": logins}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	_, err := c.client.Issues.AssignIssue(ctx, fullName, number, logins)
 	return err
@@ -95,7 +98,10 @@ func (c *Client) AssignIssue(owner, repo string, number int, logins []string) er
 
 // UnassignIssue unassigns issue
 func (c *Client) UnassignIssue(owner, repo string, number int, logins []string) error {
-	ctx := context.Background()
+	if c.readOnly("unassigning the issue", logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number, "logins": logins}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	_, err := c.client.Issues.UnassignIssue(ctx, fullName, number, logins)
 	return err
@@ -103,8 +109,14 @@ func (c *Client) UnassignIssue(owner, repo string, number int, logins []string)
 
 // AddLabel adds a label
 func (c *Client) AddLabel(owner, repo string, number int, label string, pr bool) error {
-	ctx := context.Background()
+	if c.readOnly("adding the label "+label, logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
+	if c.isGitea() {
+		return c.giteaAddLabel(fullName, number, label)
+	}
 	if pr {
 		if !c.SupportsPRLabels() {
 			return AddLabelToComment(c, owner, repo, number, label)
@@ -118,8 +130,14 @@ func (c *Client) AddLabel(owner, repo string, number int, label string, pr bool)
 
 // RemoveLabel removes labesl
 func (c *Client) RemoveLabel(owner, repo string, number int, label string, pr bool) error {
-	ctx := context.Background()
+	if c.readOnly("removing the label "+label, logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
+	if c.isGitea() {
+		return c.giteaRemoveLabel(fullName, number, label)
+	}
 	if pr {
 		if !c.SupportsPRLabels() {
 			return DeleteLabelFromComment(c, owner, repo, number, label)
@@ -133,9 +151,12 @@ func (c *Client) RemoveLabel(owner, repo string, number int, label string, pr bo
 
 // DeleteComment delete comments
 func (c *Client) DeleteComment(org, repo string, number, ID int, pr bool) error {
-	ctx := context.Background()
+	if c.readOnly("deleting the comment", logrus.Fields{"repo": c.repositoryName(org, repo), "number": number, "id": ID}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(org, repo)
-	if pr {
+	if pr && !c.isGitea() {
 		_, err := c.client.PullRequests.DeleteComment(ctx, fullName, number, ID)
 		return err
 	}
@@ -169,7 +190,7 @@ func (c *Client) DeleteStaleComments(org, repo string, number int, comments []*s
 
 // ListIssueComments list comments associated with an issue
 func (c *Client) ListIssueComments(org, repo string, number int) ([]*scm.Comment, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(org, repo)
 	var allComments []*scm.Comment
 	var resp *scm.Response
@@ -193,7 +214,7 @@ func (c *Client) ListIssueComments(org, repo string, number int) ([]*scm.Comment
 
 // GetIssueLabels returns the issue labels
 func (c *Client) GetIssueLabels(org, repo string, number int, pr bool) ([]*scm.Label, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(org, repo)
 	var allLabels []*scm.Label
 	var resp *scm.Response
@@ -203,6 +224,9 @@ func (c *Client) GetIssueLabels(org, repo string, number int, pr bool) ([]*scm.L
 	opts := scm.ListOptions{
 		Page: 1,
 	}
+	if c.isGitea() {
+		return c.giteaIssueLabels(fullName, number)
+	}
 	if pr {
 		if !c.SupportsPRLabels() {
 			return GetLabelsFromComment(c, org, repo, number)
@@ -229,33 +253,26 @@ func (c *Client) GetIssueLabels(org, repo string, number int, pr bool) ([]*scm.L
 	return labels, err
 }
 
-// CreateComment create a comment
+// CreateComment create a comment, truncating it to the comment limit of the git provider
 func (c *Client) CreateComment(owner, repo string, number int, pr bool, comment string) error {
+	if c.readOnly("commenting", logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number, "comment": comment}) {
+		return nil
+	}
 	fullName := c.repositoryName(owner, repo)
 	commentInput := scm.CommentInput{
-		Body: comment,
+		Body: c.comments.Write(owner, repo, number, comment),
 	}
-	ctx := context.Background()
-	if pr {
+	ctx := c.Context()
+	if pr && !c.isGitea() {
 		_, response, err := c.client.PullRequests.CreateComment(ctx, fullName, number, &commentInput)
 		if err != nil {
-			var b bytes.Buffer
-			_, cperr := io.Copy(&b, response.Body)
-			if cperr != nil {
-				return errors.Wrapf(cperr, "response: %s", b.String())
-			}
-			return errors.Wrapf(err, "response: %s", b.String())
+			return responseError(response, err)
 		}
 
 	} else {
 		_, response, err := c.client.Issues.CreateComment(ctx, fullName, number, &commentInput)
 		if err != nil {
-			var b bytes.Buffer
-			_, cperr := io.Copy(&b, response.Body)
-			if cperr != nil {
-				return errors.Wrapf(cperr, "reponse: %s", b.String())
-			}
-			return errors.Wrapf(err, "response: %s", b.String())
+			return responseError(response, err)
 		}
 	}
 	return nil
@@ -263,31 +280,27 @@ func (c *Client) CreateComment(owner, repo string, number int, pr bool, comment
 
 // EditComment edit a comment
 func (c *Client) EditComment(owner, repo string, number int, id int, comment string, pr bool) error {
+	if c.readOnly("editing the comment", logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number, "id": id, "comment": comment}) {
+		return nil
+	}
 	fullName := c.repositoryName(owner, repo)
 	commentInput := scm.CommentInput{
-		Body: comment,
+		Body: c.comments.Write(owner, repo, number, comment),
+	}
+	ctx := c.Context()
+	if c.isGitea() {
+		return c.giteaEditComment(fullName, id, commentInput.Body)
 	}
-	ctx := context.Background()
 	if pr {
 		_, response, err := c.client.PullRequests.EditComment(ctx, fullName, number, id, &commentInput)
 		if err != nil {
-			var b bytes.Buffer
-			_, cperr := io.Copy(&b, response.Body)
-			if cperr != nil {
-				return errors.Wrapf(cperr, "response: %s", b.String())
-			}
-			return errors.Wrapf(err, "response: %s", b.String())
+			return responseError(response, err)
 		}
 
 	} else {
 		_, response, err := c.client.Issues.EditComment(ctx, fullName, number, id, &commentInput)
 		if err != nil {
-			var b bytes.Buffer
-			_, cperr := io.Copy(&b, response.Body)
-			if cperr != nil {
-				return errors.Wrapf(cperr, "reponse: %s", b.String())
-			}
-			return errors.Wrapf(err, "response: %s", b.String())
+			return responseError(response, err)
 		}
 	}
 	return nil
@@ -295,7 +308,10 @@ func (c *Client) EditComment(owner, repo string, number int, id int, comment str
 
 // ReopenIssue reopen an issue
 func (c *Client) ReopenIssue(owner, repo string, number int) error {
-	ctx := context.Background()
+	if c.readOnly("reopening the issue", logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	_, err := c.client.Issues.Reopen(ctx, fullName, number)
 	return err
@@ -308,8 +324,24 @@ func (c *Client) FindIssues(query, sort string, asc bool) ([]scm.Issue, error) {
 
 // CloseIssue close issue
 func (c *Client) CloseIssue(owner, repo string, number int) error {
-	ctx := context.Background()
+	if c.readOnly("closing the issue", logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	_, err := c.client.Issues.Close(ctx, fullName, number)
 	return err
 }
+
+// responseError wraps the error of a request with the body of its response, if any
+func responseError(response *scm.Response, err error) error {
+	if response == nil || response.Body == nil {
+		return err
+	}
+	var b bytes.Buffer
+	_, cperr := io.Copy(&b, response.Body)
+	if cperr != nil {
+		return errors.Wrapf(cperr, "response: %s", b.String())
+	}
+	return errors.Wrapf(err, "response: %s", b.String())
+}
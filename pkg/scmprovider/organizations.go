@@ -1,14 +1,12 @@
 package scmprovider
 
 import (
-	"context"
-
 	"github.com/jenkins-x/go-scm/scm"
 )
 
 // ListTeams list teams in the organisation
 func (c *Client) ListTeams(org string) ([]*scm.Team, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	var allTeams []*scm.Team
 	var resp *scm.Response
 	var teams []*scm.Team
@@ -31,7 +29,7 @@ func (c *Client) ListTeams(org string) ([]*scm.Team, error) {
 
 // ListTeamMembers list the team members
 func (c *Client) ListTeamMembers(id int, role string) ([]*scm.TeamMember, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	var allMembers []*scm.TeamMember
 	var resp *scm.Response
 	var members []*scm.TeamMember
@@ -54,7 +52,7 @@ func (c *Client) ListTeamMembers(id int, role string) ([]*scm.TeamMember, error)
 
 // ListOrgMembers list the org members
 func (c *Client) ListOrgMembers(org string) ([]*scm.TeamMember, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	var allMembers []*scm.TeamMember
 	var resp *scm.Response
 	var members []*scm.TeamMember
@@ -77,7 +75,7 @@ func (c *Client) ListOrgMembers(org string) ([]*scm.TeamMember, error) {
 
 // IsOrgAdmin returns whether this user is an admin of the org
 func (c *Client) IsOrgAdmin(org, user string) (bool, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	ok, _, err := c.client.Organizations.IsAdmin(ctx, org, user)
 	return ok, err
 }
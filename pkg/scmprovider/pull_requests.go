@@ -1,11 +1,19 @@
 package scmprovider
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 // MergeDetails optional extra parameters
@@ -18,7 +26,7 @@ type MergeDetails struct {
 
 // GetPullRequest returns the pull request
 func (c *Client) GetPullRequest(owner, repo string, number int) (*scm.PullRequest, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	pr, _, err := c.client.PullRequests.Find(ctx, fullName, number)
 	if err != nil {
@@ -28,7 +36,8 @@ func (c *Client) GetPullRequest(owner, repo string, number int) (*scm.PullReques
 }
 
 func (c *Client) populateFields(ctx context.Context, pr *scm.PullRequest, owner, repo string) (*scm.PullRequest, error) {
-	if pr != nil && !c.SupportsPRLabels() {
+	// The pull requests of Gitea are listed without their labels
+	if pr != nil && (!c.SupportsPRLabels() || c.isGitea()) {
 		labels, err := c.GetIssueLabels(owner, repo, pr.Number, true)
 		if err != nil {
 			return nil, errors.Wrapf(err, "getting labels from comment for PR")
@@ -57,41 +66,225 @@ func (c *Client) populateFields(ctx context.Context, pr *scm.PullRequest, owner,
 	return pr, nil
 }
 
+// PullRequestListOptions are the options of ListPullRequests. The state, labels and update and creation times of
+// the go-scm options are filtered by the git providers whose driver supports them, and the state, labels, base
+// branch and UpdatedAfter are also filtered by the client.
+type PullRequestListOptions struct {
+	scm.PullRequestListOptions
+	// BaseBranch restricts the pull requests to the ones targeting the branch. It is sent to GitHub and GitLab.
+	BaseBranch string
+	// SortByUpdated lists the most recently updated pull requests first on GitHub and GitLab, which then
+	// stop the listing at the first pull request updated before UpdatedAfter
+	SortByUpdated bool
+	// Stop stops the listing at the first pull request it returns true for, which is not listed. The pull
+	// requests are listed in the order of the git provider, the most recently created first for most providers,
+	// so e.g. stopping at the first pull request created before a date avoids paging through the older ones.
+	Stop func(*scm.PullRequest) bool
+}
+
+// matches returns true if the pull request matches the filters of the options, except its labels
+func (o *PullRequestListOptions) matches(pr *scm.PullRequest) bool {
+	if o.Open != o.Closed && pr.Closed == o.Open {
+		return false
+	}
+	if o.BaseBranch != "" && pr.Target != o.BaseBranch && pr.Base.Ref != o.BaseBranch {
+		return false
+	}
+	if o.UpdatedAfter != nil && !pr.Updated.IsZero() && pr.Updated.Before(*o.UpdatedAfter) {
+		return false
+	}
+	return true
+}
+
+// hasLabels returns true if the pull request has all the labels of the options
+func (o *PullRequestListOptions) hasLabels(pr *scm.PullRequest) bool {
+	for _, required := range o.Labels {
+		found := false
+		for _, l := range pr.Labels {
+			if strings.EqualFold(l.Name, required) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // ListAllPullRequestsForFullNameRepo lists all pull requests in a full-name repository
 func (c *Client) ListAllPullRequestsForFullNameRepo(fullName string, opts scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
-	ctx := context.Background()
+	return c.ListPullRequests(fullName, PullRequestListOptions{PullRequestListOptions: opts})
+}
+
+// ListPullRequests lists the pull requests of a full-name repository matching the options, page by page until
+// the last page or the Stop predicate, waiting for the rate limit to reset between the pages if needed
+func (c *Client) ListPullRequests(fullName string, opts PullRequestListOptions) ([]*scm.PullRequest, error) {
+	ctx := c.Context()
+	if opts.Page == 0 {
+		opts.Page = 1
+	}
+	params := c.pullRequestListParams(opts)
+	listCtx := ctx
+	if len(params) > 0 {
+		// the transport is usually installed by ToClient, unless the HTTP client was replaced since
+		installQueryParamsTransport(c.client)
+		listCtx = context.WithValue(ctx, queryParamsKey{}, params)
+	}
+	sorted := opts.SortByUpdated && len(params) > 0
+	nameParts := strings.Split(fullName, "/")
 	var allPRs []*scm.PullRequest
-	var resp *scm.Response
-	var pagePRs []*scm.PullRequest
-	var err error
-	for resp == nil || opts.Page <= resp.Page.Last {
-		pagePRs, resp, err = c.client.PullRequests.List(ctx, fullName, opts)
+	var giteaLabels map[int][]*scm.Label
+	for {
+		pagePRs, resp, err := c.client.PullRequests.List(listCtx, fullName, opts.PullRequestListOptions)
 		if err != nil {
 			return nil, err
 		}
-		// TODO: Switch to getting repo info here - right now that's done in keeper
-		allPRs = append(allPRs, pagePRs...)
+		stopped := false
+		for _, pr := range pagePRs {
+			if opts.Stop != nil && opts.Stop(pr) {
+				stopped = true
+				break
+			}
+			if sorted && opts.UpdatedAfter != nil && !pr.Updated.IsZero() && pr.Updated.Before(*opts.UpdatedAfter) {
+				// the next pull requests were updated even earlier
+				stopped = true
+				break
+			}
+			if !opts.matches(pr) {
+				continue
+			}
+			// TODO: Switch to getting repo info here - right now that's done in keeper
+			if c.isGitea() {
+				// the labels of the pull requests are listed at once rather than for each pull request
+				if giteaLabels == nil {
+					giteaLabels, err = c.giteaPullRequestLabels(fullName, opts.PullRequestListOptions)
+					if err != nil {
+						return nil, err
+					}
+				}
+				pr.Labels = giteaLabels[pr.Number]
+			} else if !c.SupportsPRLabels() {
+				pr, err = c.populateFields(ctx, pr, nameParts[0], nameParts[1])
+				if err != nil {
+					return nil, err
+				}
+			}
+			if opts.hasLabels(pr) {
+				allPRs = append(allPRs, pr)
+			}
+		}
+		if stopped || len(pagePRs) == 0 || resp == nil || opts.Page >= resp.Page.Last {
+			return allPRs, nil
+		}
 		opts.Page++
+		if err := waitForRateLimit(resp, "pull request listing"); err != nil {
+			return nil, errors.Wrapf(err, "failed to list the pull requests of %s", fullName)
+		}
 	}
-	if c.SupportsPRLabels() {
-		return allPRs, nil
+}
+
+// pullRequestListParams returns the query parameters of the pull request listings of the options which
+// go-scm does not send to the git provider
+func (c *Client) pullRequestListParams(opts PullRequestListOptions) url.Values {
+	params := url.Values{}
+	switch c.client.Driver {
+	case scm.DriverGithub:
+		if opts.BaseBranch != "" {
+			params.Set("base", opts.BaseBranch)
+		}
+		if opts.SortByUpdated {
+			params.Set("sort", "updated")
+			params.Set("direction", "desc")
+		}
+	case scm.DriverGitlab:
+		if opts.BaseBranch != "" {
+			params.Set("target_branch", opts.BaseBranch)
+		}
+		if opts.SortByUpdated {
+			params.Set("order_by", "updated_at")
+			params.Set("sort", "desc")
+		}
 	}
-	nameParts := strings.Split(fullName, "/")
-	var withLabels []*scm.PullRequest
-	for _, pr := range allPRs {
-		updatedPR, err := c.populateFields(ctx, pr, nameParts[0], nameParts[1])
-		if err != nil {
-			return nil, err
+	return params
+}
+
+// queryParamsKey is the key of the context value holding the query parameters added to the requests
+type queryParamsKey struct{}
+
+// queryParamsTransport adds the query parameters of the context of the requests to them, so that
+// the parameters go-scm does not support are sent to the git provider. It also records the rate limit
+// budget of the git provider returned with the responses.
+type queryParamsTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip adds the query parameters of the context to the request
+func (t *queryParamsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if params, ok := req.Context().Value(queryParamsKey{}).(url.Values); ok {
+		req = req.Clone(req.Context())
+		query := req.URL.Query()
+		for key, values := range params {
+			query[key] = values
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err == nil {
+		observeRateLimit(resp)
+	}
+	return resp, err
+}
+
+// queryParamsLock serialises the installation of the query parameters transport of the scm clients
+var queryParamsLock sync.Mutex
+
+// installQueryParamsTransport wraps the transport of the scm client with a queryParamsTransport. The
+// HTTP client is copied, as it may be shared, e.g. http.DefaultClient.
+func installQueryParamsTransport(client *scm.Client) {
+	queryParamsLock.Lock()
+	defer queryParamsLock.Unlock()
+	httpClient := http.Client{}
+	if client.Client != nil {
+		if _, ok := client.Client.Transport.(*queryParamsTransport); ok {
+			return
+		}
+		httpClient = *client.Client
+	}
+	httpClient.Transport = &queryParamsTransport{base: httpClient.Transport}
+	client.Client = &httpClient
+}
+
+// waitForRateLimit waits for the rate limit of the git provider to reset if the response exhausted it, or fails
+// if the reset is more than maxSearchRateLimitWait away
+func waitForRateLimit(res *scm.Response, what string) error {
+	rates := &RateLimits{}
+	rates.populate(res)
+	if rates.Remaining == 0 && rates.Reset > 0 {
+		wait := time.Until(time.Unix(int64(rates.Reset), 0))
+		if wait > maxSearchRateLimitWait {
+			return errors.Errorf("%s rate limit exceeded until %s", what, time.Unix(int64(rates.Reset), 0).UTC().Format(time.RFC3339))
+		}
+		if wait > 0 {
+			searchSleep(wait)
 		}
-		withLabels = append(withLabels, updatedPR)
 	}
-	return withLabels, nil
+	return nil
 }
 
 // ListPullRequestComments list pull request comments
 func (c *Client) ListPullRequestComments(owner, repo string, number int) ([]*scm.Comment, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
+	if c.isGitea() {
+		// the comments of Gitea pull requests are issue comments
+		return c.ListIssueComments(owner, repo, number)
+	}
 	var allComments []*scm.Comment
 	var resp *scm.Response
 	var comments []*scm.Comment
@@ -114,7 +307,7 @@ func (c *Client) ListPullRequestComments(owner, repo string, number int) ([]*scm
 
 // GetPullRequestChanges returns the changes in a pull request
 func (c *Client) GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(org, repo)
 	var allChanges []*scm.Change
 	var resp *scm.Response
@@ -138,8 +331,14 @@ func (c *Client) GetPullRequestChanges(org, repo string, number int) ([]*scm.Cha
 
 // Merge reopens a pull request
 func (c *Client) Merge(owner, repo string, number int, details MergeDetails) error {
-	ctx := context.Background()
+	if c.readOnly("merging the pull request", logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number, "sha": details.SHA, "method": details.MergeMethod, "title": details.CommitTitle}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
+	if details.CommitMessage != "" && c.client.Driver == scm.DriverGithub {
+		return c.githubMerge(fullName, number, details)
+	}
 	mergeOptions := &scm.PullRequestMergeOptions{
 		CommitTitle: details.CommitTitle,
 		SHA:         details.SHA,
@@ -149,6 +348,47 @@ func (c *Client) Merge(owner, repo string, number int, details MergeDetails) err
 	return err
 }
 
+// githubMerge merges the pull request with the GitHub API directly, as the merge options of go-scm cannot
+// carry the body of the commit message
+func (c *Client) githubMerge(fullName string, number int, details MergeDetails) error {
+	body, err := json.Marshal(map[string]string{
+		"commit_title":   details.CommitTitle,
+		"commit_message": details.CommitMessage,
+		"sha":            details.SHA,
+		"merge_method":   details.MergeMethod,
+	})
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(c.Context(), &scm.Request{
+		Method: http.MethodPut,
+		Path:   fmt.Sprintf("repos/%s/pulls/%d/merge", fullName, number),
+		Header: map[string][]string{
+			"Accept":       {"application/vnd.github.v3+json"},
+			"Content-Type": {"application/json"},
+		},
+		Body: bytes.NewReader(body),
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.Status < 300 {
+		return nil
+	}
+	failure := struct {
+		Message string `json:"message"`
+	}{}
+	_ = json.NewDecoder(res.Body).Decode(&failure)
+	switch res.Status {
+	case http.StatusConflict:
+		return ModifiedHeadError(failure.Message)
+	case http.StatusMethodNotAllowed:
+		return UnmergablePRError(failure.Message)
+	}
+	return errors.Errorf("failed to merge pull request %d of %s: status %d %s", number, fullName, res.Status, failure.Message)
+}
+
 // ModifiedHeadError happens when github refuses to merge a PR because the PR changed.
 type ModifiedHeadError string
 
@@ -176,7 +416,10 @@ func (e MergeCommitsForbiddenError) Error() string { return string(e) }
 
 // ReopenPR reopens a pull request
 func (c *Client) ReopenPR(owner, repo string, number int) error {
-	ctx := context.Background()
+	if c.readOnly("reopening the pull request", logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	_, err := c.client.PullRequests.Reopen(ctx, fullName, number)
 	return err
@@ -184,7 +427,10 @@ func (c *Client) ReopenPR(owner, repo string, number int) error {
 
 // ClosePR closes a pull request
 func (c *Client) ClosePR(owner, repo string, number int) error {
-	ctx := context.Background()
+	if c.readOnly("closing the pull request", logrus.Fields{"repo": c.repositoryName(owner, repo), "number": number}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	_, err := c.client.PullRequests.Close(ctx, fullName, number)
 	return err
@@ -0,0 +1,150 @@
+package scmprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPullRequests(t *testing.T) {
+	created := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	var pages []int
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pages = append(pages, page)
+		if page < 3 {
+			base := "http://" + r.Host + r.URL.Path
+			w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d>; rel="next", <%s?page=3>; rel="last"`, base, page+1, base))
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(10*time.Second).Unix(), 10))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		// two pull requests per page, created a day apart from the most recent
+		first := (page - 1) * 2
+		fmt.Fprint(w, "[")
+		for i := first; i < first+2; i++ {
+			if i > first {
+				fmt.Fprint(w, ",")
+			}
+			state, branch := "open", "master"
+			if i%2 == 1 {
+				branch = "release"
+			}
+			if i == 2 {
+				state = "closed"
+			}
+			date := created.AddDate(0, 0, -i).Format(time.RFC3339)
+			fmt.Fprintf(w, `{"number": %d, "state": %q, "base": {"ref": %q}, "labels": [{"name": "lgtm"}], "created_at": %q, "updated_at": %q}`, i+1, state, branch, date, date)
+		}
+		fmt.Fprint(w, "]")
+	}))
+	defer server.Close()
+
+	var waits int
+	searchSleep = func(time.Duration) { waits++ }
+	defer func() { searchSleep = time.Sleep }()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+
+	numbers := func(prs []*scm.PullRequest) []int {
+		var answer []int
+		for _, pr := range prs {
+			answer = append(answer, pr.Number)
+		}
+		return answer
+	}
+
+	prs, err := client.ListAllPullRequestsForFullNameRepo("org/repo", scm.PullRequestListOptions{Open: true})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 4, 5, 6}, numbers(prs), "the closed pull requests are filtered")
+	assert.Equal(t, []int{1, 2, 3}, pages)
+	assert.Equal(t, 2, waits, "the listing waits for the rate limit to reset between pages")
+
+	pages = nil
+	since := created.AddDate(0, 0, -3)
+	prs, err = client.ListPullRequests("org/repo", PullRequestListOptions{
+		PullRequestListOptions: scm.PullRequestListOptions{Page: 1, Open: true, UpdatedAfter: &since, Labels: []string{"LGTM"}},
+		BaseBranch:             "master",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, numbers(prs), "the base branch and update time are filtered")
+	assert.Equal(t, "master", query.Get("base"), "the base branch is sent to the git provider")
+
+	pages = nil
+	since = created.Add(-36 * time.Hour)
+	prs, err = client.ListPullRequests("org/repo", PullRequestListOptions{
+		PullRequestListOptions: scm.PullRequestListOptions{Page: 1, Open: true, UpdatedAfter: &since},
+		BaseBranch:             "master",
+		SortByUpdated:          true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, numbers(prs))
+	assert.Equal(t, []int{1, 2}, pages, "the listing sorted by update time stops at the first pull request updated before UpdatedAfter")
+	assert.Equal(t, "updated", query.Get("sort"))
+	assert.Equal(t, "desc", query.Get("direction"))
+
+	pages = nil
+	cutoff := created.AddDate(0, 0, -2)
+	prs, err = client.ListPullRequests("org/repo", PullRequestListOptions{
+		PullRequestListOptions: scm.PullRequestListOptions{Open: true, Closed: true},
+		Stop: func(pr *scm.PullRequest) bool {
+			return pr.Created.Before(cutoff)
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, numbers(prs))
+	assert.Equal(t, []int{1, 2}, pages, "the listing stops at the first pull request matching the predicate")
+
+	prs, err = client.ListPullRequests("org/repo", PullRequestListOptions{
+		PullRequestListOptions: scm.PullRequestListOptions{Open: true, Labels: []string{"hold"}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, prs)
+}
+
+func TestMergeWithCommitMessage(t *testing.T) {
+	var method, path string
+	var input map[string]string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&input))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"message": "Head branch was modified. Review and try the merge again."}`)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+
+	details := MergeDetails{SHA: "abc", MergeMethod: "squash", CommitTitle: "feat: add things (#1)", CommitMessage: "Adds things"}
+	require.NoError(t, client.Merge("org", "repo", 1, details))
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/repos/org/repo/pulls/1/merge", path)
+	assert.Equal(t, map[string]string{
+		"commit_title":   "feat: add things (#1)",
+		"commit_message": "Adds things",
+		"sha":            "abc",
+		"merge_method":   "squash",
+	}, input)
+
+	status = http.StatusConflict
+	err = client.Merge("org", "repo", 1, details)
+	assert.Equal(t, ModifiedHeadError("Head branch was modified. Review and try the merge again."), err)
+}
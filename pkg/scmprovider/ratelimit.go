@@ -0,0 +1,145 @@
+package scmprovider
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultRateLimitResource is the resource of the rate limits of the providers which do not tell it, and of
+// the core API of GitHub
+const defaultRateLimitResource = "core"
+
+var (
+	rateLimitRemainingGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lighthouse_scm_rate_limit_remaining",
+		Help: "The number of requests remaining in the rate limit of the git provider, as of its last response.",
+	}, []string{"host", "resource"})
+	rateLimitLimitGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lighthouse_scm_rate_limit_limit",
+		Help: "The number of requests allowed by the rate limit of the git provider per period.",
+	}, []string{"host", "resource"})
+	throttledCallsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_scm_throttled_calls_total",
+		Help: "A counter of the non-critical calls which were skipped because the rate limit of the git provider was low.",
+	}, []string{"call"})
+)
+
+func init() {
+	prometheus.MustRegister(rateLimitRemainingGauge)
+	prometheus.MustRegister(rateLimitLimitGauge)
+	prometheus.MustRegister(throttledCallsCounter)
+}
+
+// RateLimitBudget is the rate limit of a resource of a git provider as of its last response
+type RateLimitBudget struct {
+	// Limit is the number of requests allowed per period
+	Limit int
+	// Remaining is the number of requests remaining in the current period
+	Remaining int
+	// Reset is when the current period ends
+	Reset time.Time
+}
+
+// Low returns true if less than the fraction of the limit remains in the current period
+func (b RateLimitBudget) Low(fraction float64, now time.Time) bool {
+	return b.Limit > 0 && now.Before(b.Reset) && float64(b.Remaining) < fraction*float64(b.Limit)
+}
+
+// rateLimitBudgets are the budgets of the git providers, keyed by host and resource. The budgets of the
+// different tokens of a host, e.g. the installation tokens of a GitHub App, are not told apart.
+var rateLimitBudgets = struct {
+	sync.RWMutex
+	budgets map[string]RateLimitBudget
+}{budgets: map[string]RateLimitBudget{}}
+
+// observeRateLimit records the rate limit the git provider returned in the headers of the response, either
+// the X-RateLimit headers of GitHub and Gitea or the RateLimit headers of GitLab
+func observeRateLimit(resp *http.Response) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	h := resp.Header
+	prefix := "X-Ratelimit-"
+	if h.Get(prefix+"Limit") == "" {
+		prefix = "Ratelimit-"
+	}
+	limit, err := strconv.Atoi(h.Get(prefix + "Limit"))
+	if err != nil || limit <= 0 {
+		return
+	}
+	remaining, err := strconv.Atoi(h.Get(prefix + "Remaining"))
+	if err != nil {
+		return
+	}
+	reset, _ := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64)
+	resource := strings.ToLower(h.Get(prefix + "Resource"))
+	if resource == "" {
+		resource = defaultRateLimitResource
+	}
+	host := resp.Request.URL.Host
+
+	rateLimitBudgets.Lock()
+	rateLimitBudgets.budgets[host+"/"+resource] = RateLimitBudget{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)}
+	rateLimitBudgets.Unlock()
+	rateLimitRemainingGauge.WithLabelValues(host, resource).Set(float64(remaining))
+	rateLimitLimitGauge.WithLabelValues(host, resource).Set(float64(limit))
+}
+
+// GetRateLimitBudget returns the rate limit budget of the resource of the host as of the last response, and
+// false if no response of the host told it yet
+func GetRateLimitBudget(host, resource string) (RateLimitBudget, bool) {
+	rateLimitBudgets.RLock()
+	defer rateLimitBudgets.RUnlock()
+	b, ok := rateLimitBudgets.budgets[host+"/"+resource]
+	return b, ok
+}
+
+// RateLimitThrottle skips the non-critical calls, such as the re-queries of keeper, while the rate limit
+// budget of a git provider is low, so that the remaining requests are kept for the critical calls such as
+// merges and commit statuses. A nil RateLimitThrottle is disabled.
+type RateLimitThrottle struct {
+	// MinFraction is the fraction of the rate limit below which the non-critical calls are skipped
+	MinFraction float64
+
+	now func() time.Time
+}
+
+// NewRateLimitThrottle creates a RateLimitThrottle. It returns nil if the fraction is not positive, which
+// disables throttling.
+func NewRateLimitThrottle(minFraction float64) *RateLimitThrottle {
+	if minFraction <= 0 {
+		return nil
+	}
+	return &RateLimitThrottle{MinFraction: minFraction, now: time.Now}
+}
+
+// Allow returns nil if the non-critical call can be made, otherwise an error telling which rate limit
+// budget is low and when it resets. The skipped calls are counted in the metrics.
+func (t *RateLimitThrottle) Allow(call string) error {
+	if t == nil {
+		return nil
+	}
+	now := t.now()
+	rateLimitBudgets.RLock()
+	var low []string
+	for key, b := range rateLimitBudgets.budgets {
+		if b.Low(t.MinFraction, now) {
+			low = append(low, fmt.Sprintf("%s has %d of %d requests left until %s", key, b.Remaining, b.Limit, b.Reset.UTC().Format(time.RFC3339)))
+		}
+	}
+	rateLimitBudgets.RUnlock()
+	if len(low) == 0 {
+		return nil
+	}
+	sort.Strings(low)
+	throttledCallsCounter.WithLabelValues(call).Inc()
+	return errors.Errorf("the rate limit budget is low: %s", strings.Join(low, ", "))
+}
@@ -0,0 +1,70 @@
+package scmprovider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitThrottle(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(30 * time.Minute)
+	remaining := 4000
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.Header().Set("X-RateLimit-Resource", "graphql")
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	call := func() {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := (&queryParamsTransport{}).RoundTrip(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	call()
+	budget, ok := GetRateLimitBudget(serverURL.Host, "graphql")
+	require.True(t, ok, "the budget is recorded from the response headers")
+	assert.Equal(t, RateLimitBudget{Limit: 5000, Remaining: 4000, Reset: time.Unix(reset.Unix(), 0)}, budget)
+
+	var disabled *RateLimitThrottle
+	throttle := NewRateLimitThrottle(0.1)
+	throttle.now = func() time.Time { return now }
+	assert.Nil(t, NewRateLimitThrottle(0))
+	assert.NoError(t, throttle.Allow("test"))
+
+	remaining = 400
+	call()
+	assert.Error(t, throttle.Allow("test"), "less than 10% of the rate limit remains")
+	assert.NoError(t, disabled.Allow("test"))
+
+	now = reset.Add(time.Second)
+	assert.NoError(t, throttle.Allow("test"), "the budget is reset")
+}
+
+func TestObserveRateLimitGitLab(t *testing.T) {
+	resp := &http.Response{
+		Header:  http.Header{},
+		Request: &http.Request{URL: &url.URL{Host: "gitlab.example.com"}},
+	}
+	resp.Header.Set("RateLimit-Limit", "600")
+	resp.Header.Set("RateLimit-Remaining", "599")
+	resp.Header.Set("RateLimit-Reset", "1591012800")
+	observeRateLimit(resp)
+
+	budget, ok := GetRateLimitBudget("gitlab.example.com", defaultRateLimitResource)
+	require.True(t, ok)
+	assert.Equal(t, 599, budget.Remaining)
+	assert.Equal(t, 600, budget.Limit)
+}
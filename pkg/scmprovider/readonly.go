@@ -0,0 +1,29 @@
+package scmprovider
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// readOnly returns true if the git provider must not be modified because the readOnly switch of the
+// lighthouse settings is on or the client is used by a shadow plugin, in which case the intended action
+// is logged instead. The action is audited either way.
+func (c *Client) readOnly(action string, fields logrus.Fields) bool {
+	readOnly := c.settings != nil && c.settings().ReadOnly
+	shadow := c.shadowPlugin != ""
+	c.audit(action, fields, readOnly || shadow)
+	switch {
+	case readOnly:
+		logrus.WithFields(fields).Infof("read-only mode: not %s", action)
+	case shadow:
+		logrus.WithFields(fields).WithField("plugin", c.shadowPlugin).Infof("shadow plugin: not %s", action)
+	default:
+		return false
+	}
+	return true
+}
+
+// SetShadowPlugin makes the client only log the changes of the shadow plugin it is used by, rather than
+// making them. The changes are audited as dry runs.
+func (c *Client) SetShadowPlugin(plugin string) {
+	c.shadowPlugin = plugin
+}
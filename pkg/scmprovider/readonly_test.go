@@ -0,0 +1,66 @@
+package scmprovider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyClient(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+	s := &settings.Config{ReadOnly: true}
+	client.SetSettings(func() *settings.Config { return s })
+
+	require.NoError(t, client.CreateComment("org", "repo", 1, true, "hello"))
+	require.NoError(t, client.AddLabel("org", "repo", 1, "lgtm", true))
+	require.NoError(t, client.Merge("org", "repo", 1, MergeDetails{}))
+	status, err := client.CreateStatus("org", "repo", "sha", &scm.StatusInput{State: scm.StateSuccess, Label: "ci"})
+	require.NoError(t, err)
+	assert.Equal(t, "ci", status.Label)
+	assert.Empty(t, requests, "the git provider is not modified in read-only mode")
+
+	_, err = client.GetIssueLabels("org", "repo", 1, true)
+	assert.Error(t, err)
+	assert.Len(t, requests, 1, "the git provider is still read")
+
+	s.ReadOnly = false
+	assert.Error(t, client.CreateComment("org", "repo", 1, true, "hello"))
+	assert.Len(t, requests, 2)
+}
+
+func TestShadowPluginClient(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+	client.SetShadowPlugin("size")
+
+	require.NoError(t, client.CreateComment("org", "repo", 1, true, "hello"))
+	require.NoError(t, client.AddLabel("org", "repo", 1, "size/XS", true))
+	assert.Empty(t, requests, "the changes of shadow plugins are only logged")
+
+	_, err = client.GetIssueLabels("org", "repo", 1, true)
+	assert.Error(t, err)
+	assert.Len(t, requests, 1, "shadow plugins still read the git provider")
+}
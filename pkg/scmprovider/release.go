@@ -0,0 +1,96 @@
+package scmprovider
+
+import (
+	"encoding/json"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ReleaseActionPublished is the action of the release webhook sent when a release is published
+	ReleaseActionPublished = "published"
+
+	// gitlabReleaseActionCreate is the action of the GitLab release webhook sent when a release is created
+	gitlabReleaseActionCreate = "create"
+)
+
+// Release contains the details of a release
+type Release struct {
+	// Tag is the name of the tag of the release
+	Tag string `json:"tag_name"`
+	// Name is the title of the release
+	Name string `json:"name"`
+	// Commitish is the branch or commit the tag was created from
+	Commitish string `json:"target_commitish"`
+	// Link is the URL of the release page
+	Link string `json:"html_url"`
+	// Draft is true for unpublished releases
+	Draft bool `json:"draft"`
+	// Prerelease is true for releases marked as not ready for production
+	Prerelease bool `json:"prerelease"`
+}
+
+// ReleaseHook is a release webhook including the release details and the raw
+// action, which go-scm does not expose for release events
+type ReleaseHook struct {
+	*scm.ReleaseHook
+
+	// RawAction is the action of the event as sent by the provider, e.g. "published"
+	RawAction string
+	Release   Release
+}
+
+// releasePayload is the part of the GitHub and Gitea release webhook payload we need
+type releasePayload struct {
+	Action  string  `json:"action"`
+	Release Release `json:"release"`
+}
+
+// gitlabReleasePayload is the part of the GitLab release webhook payload we need
+type gitlabReleasePayload struct {
+	Action   string `json:"action"`
+	Tag      string `json:"tag"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Upcoming bool   `json:"upcoming_release"`
+	Commit   struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+// ParseReleaseHook parses the release details from the raw payload of a release webhook of the
+// given driver. GitLab releases are reported as published when they are created as GitLab has
+// no draft releases.
+func ParseReleaseHook(driver scm.Driver, hook *scm.ReleaseHook, payload []byte) (*ReleaseHook, error) {
+	if driver == scm.DriverGitlab {
+		data := gitlabReleasePayload{}
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return nil, errors.Wrap(err, "failed to parse GitLab release webhook payload")
+		}
+		action := data.Action
+		if action == gitlabReleaseActionCreate {
+			action = ReleaseActionPublished
+		}
+		return &ReleaseHook{
+			ReleaseHook: hook,
+			RawAction:   action,
+			Release: Release{
+				Tag:        data.Tag,
+				Name:       data.Name,
+				Commitish:  data.Commit.ID,
+				Link:       data.URL,
+				Prerelease: data.Upcoming,
+			},
+		}, nil
+	}
+	data := releasePayload{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, errors.Wrap(err, "failed to parse release webhook payload")
+	}
+	return &ReleaseHook{
+		ReleaseHook: hook,
+		RawAction:   data.Action,
+		Release:     data.Release,
+	}, nil
+}
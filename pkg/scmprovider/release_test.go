@@ -0,0 +1,32 @@
+package scmprovider
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReleaseHook(t *testing.T) {
+	hook := &scm.ReleaseHook{}
+
+	github := `{"action": "published", "release": {"tag_name": "v1.2.0", "name": "1.2.0", "target_commitish": "master", "html_url": "https://github.com/org/repo/releases/v1.2.0", "prerelease": true}}`
+	release, err := ParseReleaseHook(scm.DriverGithub, hook, []byte(github))
+	require.NoError(t, err)
+	assert.Equal(t, ReleaseActionPublished, release.RawAction)
+	assert.Equal(t, Release{Tag: "v1.2.0", Name: "1.2.0", Commitish: "master", Link: "https://github.com/org/repo/releases/v1.2.0", Prerelease: true}, release.Release)
+
+	release, err = ParseReleaseHook(scm.DriverGitea, hook, []byte(github))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.0", release.Release.Tag)
+
+	gitlab := `{"object_kind": "release", "action": "create", "tag": "v1.2.0", "name": "1.2.0", "url": "https://gitlab.com/org/repo/-/releases/v1.2.0", "commit": {"id": "abc123"}}`
+	release, err = ParseReleaseHook(scm.DriverGitlab, hook, []byte(gitlab))
+	require.NoError(t, err)
+	assert.Equal(t, ReleaseActionPublished, release.RawAction)
+	assert.Equal(t, Release{Tag: "v1.2.0", Name: "1.2.0", Commitish: "abc123", Link: "https://gitlab.com/org/repo/-/releases/v1.2.0"}, release.Release)
+
+	_, err = ParseReleaseHook(scm.DriverGitlab, hook, []byte("not json"))
+	assert.Error(t, err)
+}
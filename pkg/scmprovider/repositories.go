@@ -1,22 +1,74 @@
 package scmprovider
 
 import (
-	"context"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
+// protectedBranchesPageSize is the number of protected branches listed per request, the maximum of GitHub
+const protectedBranchesPageSize = 100
+
 // GetRepositoryByFullName returns the repository details
 func (c *Client) GetRepositoryByFullName(fullName string) (*scm.Repository, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	r, _, err := c.client.Repositories.Find(ctx, fullName)
 	return r, err
 }
 
+// ListProtectedBranches returns the names of the protected branches of the repository.
+// Branch protection is not exposed by go-scm so this is only supported for GitHub,
+// other providers return scm.ErrNotSupported.
+func (c *Client) ListProtectedBranches(owner, repo string) ([]string, error) {
+	if c.client.Driver != scm.DriverGithub {
+		return nil, scm.ErrNotSupported
+	}
+	ctx := c.Context()
+	fullName := c.repositoryName(owner, repo)
+	var names []string
+	for page := 1; ; page++ {
+		res, err := c.client.Do(ctx, &scm.Request{
+			Method: "GET",
+			Path:   fmt.Sprintf("repos/%s/branches?protected=true&per_page=%d&page=%d", fullName, protectedBranchesPageSize, page),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list protected branches of %s", fullName)
+		}
+		if res.Status > 299 {
+			res.Body.Close()
+			return nil, errors.Errorf("failed to list protected branches of %s: status %d", fullName, res.Status)
+		}
+		var branches []struct {
+			Name string `json:"name"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&branches)
+		res.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode protected branches of %s", fullName)
+		}
+		for _, b := range branches {
+			names = append(names, b.Name)
+		}
+		if len(branches) < protectedBranchesPageSize {
+			return names, nil
+		}
+	}
+}
+
 // GetRepoLabels returns the repository labels
 func (c *Client) GetRepoLabels(owner, repo string) ([]*scm.Label, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
+	if c.isGitea() {
+		return c.giteaListLabels(fmt.Sprintf("repos/%s/labels", fullName))
+	}
 	var allLabels []*scm.Label
 	var resp *scm.Response
 	var labels []*scm.Label
@@ -37,17 +89,98 @@ func (c *Client) GetRepoLabels(owner, repo string) ([]*scm.Label, error) {
 	return allLabels, nil
 }
 
+// CreateRepoLabel creates the label, with its color and description, in the repository
+func (c *Client) CreateRepoLabel(owner, repo string, label *scm.Label) error {
+	fullName := c.repositoryName(owner, repo)
+	if c.readOnly("creating the label "+label.Name, logrus.Fields{"repo": fullName, "color": label.Color}) {
+		return nil
+	}
+	input := map[string]string{"name": label.Name, "color": labelColor(c.client.Driver, label.Color), "description": label.Description}
+	var err error
+	switch c.client.Driver {
+	case scm.DriverGithub:
+		err = c.jsonRequest(http.MethodPost, fmt.Sprintf("repos/%s/labels", fullName), input)
+	case scm.DriverGitlab:
+		err = c.jsonRequest(http.MethodPost, fmt.Sprintf("api/v4/projects/%s/labels", url.PathEscape(fullName)), input)
+	case scm.DriverGitea:
+		_, err = c.giteaRequest(http.MethodPost, fmt.Sprintf("repos/%s/labels", fullName), input, nil)
+	default:
+		return scm.ErrNotSupported
+	}
+	return errors.Wrapf(err, "failed to create the label %s in %s", label.Name, fullName)
+}
+
+// UpdateRepoLabel updates the color and description of the label of the repository with the same name.
+// The ID of the label is required by Gitea, as returned by GetRepoLabels.
+func (c *Client) UpdateRepoLabel(owner, repo string, label *scm.Label) error {
+	fullName := c.repositoryName(owner, repo)
+	if c.readOnly("updating the label "+label.Name, logrus.Fields{"repo": fullName, "color": label.Color}) {
+		return nil
+	}
+	input := map[string]string{"color": labelColor(c.client.Driver, label.Color), "description": label.Description}
+	var err error
+	switch c.client.Driver {
+	case scm.DriverGithub:
+		err = c.jsonRequest(http.MethodPatch, fmt.Sprintf("repos/%s/labels/%s", fullName, url.PathEscape(label.Name)), input)
+	case scm.DriverGitlab:
+		err = c.jsonRequest(http.MethodPut, fmt.Sprintf("api/v4/projects/%s/labels/%s", url.PathEscape(fullName), url.PathEscape(label.Name)), input)
+	case scm.DriverGitea:
+		_, err = c.giteaRequest(http.MethodPatch, fmt.Sprintf("repos/%s/labels/%d", fullName, label.ID), input, nil)
+	default:
+		return scm.ErrNotSupported
+	}
+	return errors.Wrapf(err, "failed to update the label %s in %s", label.Name, fullName)
+}
+
+// labelColor returns the hex color of a label as expected by the git provider: GitHub does not accept the
+// leading # which the others require
+func labelColor(driver scm.Driver, color string) string {
+	color = strings.TrimPrefix(color, "#")
+	if driver == scm.DriverGithub {
+		return color
+	}
+	return "#" + color
+}
+
+// jsonRequest sends a request with the JSON input to the API of the git provider, statuses above 299 are errors
+func (c *Client) jsonRequest(method, path string, input interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(c.Context(), &scm.Request{
+		Method: method,
+		Path:   path,
+		Header: map[string][]string{
+			"Accept":       {"application/json"},
+			"Content-Type": {"application/json"},
+		},
+		Body: bytes.NewReader(body),
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.Status > 299 {
+		return errors.Errorf("status %d", res.Status)
+	}
+	return nil
+}
+
 // IsCollaborator check if a user is collaborator to a repository
 func (c *Client) IsCollaborator(owner, repo, login string) (bool, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
+	if c.isGitea() {
+		return c.giteaExists(fmt.Sprintf("repos/%s/collaborators/%s", fullName, login))
+	}
 	flag, _, err := c.client.Repositories.IsCollaborator(ctx, fullName, login)
 	return flag, err
 }
 
 // ListCollaborators list the collaborators to a repository
 func (c *Client) ListCollaborators(owner, repo string) ([]scm.User, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	var allCollabs []scm.User
 	var resp *scm.Response
@@ -71,9 +204,14 @@ func (c *Client) ListCollaborators(owner, repo string) ([]scm.User, error) {
 
 // CreateStatus create a status into a repository
 func (c *Client) CreateStatus(owner, repo, ref string, s *scm.StatusInput) (*scm.Status, error) {
-	ctx := context.Background()
+	if c.readOnly("setting the status "+s.Label, logrus.Fields{"repo": c.repositoryName(owner, repo), "ref": ref, "state": s.State, "description": s.Desc}) {
+		return &scm.Status{State: s.State, Label: s.Label, Desc: s.Desc, Target: s.Target}, nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
-	status, _, err := c.client.Repositories.CreateStatus(ctx, fullName, ref, s)
+	input := *s
+	input.Label = AddStatusContextPrefix(s.Label)
+	status, _, err := c.client.Repositories.CreateStatus(ctx, fullName, ref, &input)
 	return status, err
 }
 
@@ -98,7 +236,7 @@ type Status struct {
 
 // ListStatuses list the statuses
 func (c *Client) ListStatuses(owner, repo, ref string) ([]*scm.Status, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
 	var allStatuses []*scm.Status
 	var resp *scm.Response
@@ -117,14 +255,23 @@ func (c *Client) ListStatuses(owner, repo, ref string) ([]*scm.Status, error) {
 		allStatuses = append(allStatuses, statuses...)
 		opts.Page++
 	}
-	return allStatuses, nil
+	return trimStatusesContextPrefix(allStatuses), nil
 }
 
 // GetCombinedStatus returns the combined status
 func (c *Client) GetCombinedStatus(owner, repo, ref string) (*scm.CombinedStatus, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
-	resources, _, err := c.client.Repositories.FindCombinedStatus(ctx, fullName, ref)
+	var resources *scm.CombinedStatus
+	var err error
+	if c.isGitea() {
+		resources, err = c.giteaCombinedStatus(fullName, ref)
+	} else {
+		resources, _, err = c.client.Repositories.FindCombinedStatus(ctx, fullName, ref)
+	}
+	if resources != nil {
+		resources.Statuses = trimStatusesContextPrefix(resources.Statuses)
+	}
 	return resources, err
 }
 
@@ -144,7 +291,7 @@ func (c *Client) HasPermission(org, repo, user string, roles ...string) (bool, e
 
 // GetUserPermission returns the user's permission level for a repo
 func (c *Client) GetUserPermission(org, repo, user string) (string, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(org, repo)
 	perm, _, err := c.client.Repositories.FindUserPermission(ctx, fullName, user)
 	return perm, err
@@ -152,7 +299,10 @@ func (c *Client) GetUserPermission(org, repo, user string) (string, error) {
 
 // IsMember checks if a user is a member of the organisation
 func (c *Client) IsMember(org, user string) (bool, error) {
-	ctx := context.Background()
+	ctx := c.Context()
+	if c.isGitea() {
+		return c.giteaExists(fmt.Sprintf("orgs/%s/members/%s", org, user))
+	}
 	member, _, err := c.client.Organizations.IsMember(ctx, org, user)
 	return member, err
 }
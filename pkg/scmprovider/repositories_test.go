@@ -0,0 +1,79 @@
+package scmprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListProtectedBranches(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/org/repo/branches", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("protected"))
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		count := protectedBranchesPageSize
+		if page == "2" {
+			count = 1
+		}
+		var branches []string
+		for i := 0; i < count; i++ {
+			branches = append(branches, fmt.Sprintf(`{"name": "release-%s.%s"}`, page, strconv.Itoa(i)))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "[%s]", strings.Join(branches, ","))
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	branches, err := ToClient(scmClient, "bot").ListProtectedBranches("org", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, pages)
+	assert.Len(t, branches, protectedBranchesPageSize+1)
+	assert.Equal(t, "release-2.0", branches[protectedBranchesPageSize])
+
+	fakeClient, _ := fake.NewDefault()
+	_, err = ToClient(fakeClient, "bot").ListProtectedBranches("org", "repo")
+	assert.Equal(t, scm.ErrNotSupported, err)
+}
+
+func TestCreateAndUpdateRepoLabel(t *testing.T) {
+	var requests []string
+	var inputs []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		input := map[string]string{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&input))
+		inputs = append(inputs, input)
+		if r.Method == http.MethodPost && input["name"] == "existing" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+
+	require.NoError(t, client.CreateRepoLabel("org", "repo", &scm.Label{Name: "kind/bug", Color: "#D73A4A", Description: "Something is broken"}))
+	require.NoError(t, client.UpdateRepoLabel("org", "repo", &scm.Label{Name: "kind/bug", Color: "e11d21"}))
+	assert.Error(t, client.CreateRepoLabel("org", "repo", &scm.Label{Name: "existing", Color: "ededed"}))
+
+	assert.Equal(t, []string{"POST /repos/org/repo/labels", "PATCH /repos/org/repo/labels/kind%2Fbug", "POST /repos/org/repo/labels"}, requests)
+	assert.Equal(t, map[string]string{"name": "kind/bug", "color": "D73A4A", "description": "Something is broken"}, inputs[0])
+	assert.Equal(t, map[string]string{"color": "e11d21", "description": ""}, inputs[1])
+}
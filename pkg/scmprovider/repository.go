@@ -0,0 +1,84 @@
+package scmprovider
+
+import (
+	"encoding/json"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RepositoryActionRenamed is the action of the repository webhook sent when a repository is renamed
+	RepositoryActionRenamed = "renamed"
+	// RepositoryActionTransferred is the action of the repository webhook sent when a repository is transferred to another owner
+	RepositoryActionTransferred = "transferred"
+)
+
+// RepositoryHook is a repository webhook including the raw action and the previous
+// owner and name of the repository, which go-scm does not expose for repository events
+type RepositoryHook struct {
+	*scm.RepositoryHook
+
+	// RawAction is the action of the event as sent by the provider, e.g. "renamed"
+	RawAction string
+	// PreviousOwner is the owner of the repository before it was transferred
+	PreviousOwner string
+	// PreviousName is the name of the repository before it was renamed
+	PreviousName string
+}
+
+// repositoryPayload is the part of the GitHub repository webhook payload we need
+type repositoryPayload struct {
+	Action  string `json:"action"`
+	Changes struct {
+		Repository struct {
+			Name struct {
+				From string `json:"from"`
+			} `json:"name"`
+		} `json:"repository"`
+		Owner struct {
+			From struct {
+				User *struct {
+					Login string `json:"login"`
+				} `json:"user"`
+				Organization *struct {
+					Login string `json:"login"`
+				} `json:"organization"`
+			} `json:"from"`
+		} `json:"owner"`
+	} `json:"changes"`
+}
+
+// ParseRepositoryHook parses the previous owner and name from the raw payload of a GitHub repository webhook
+func ParseRepositoryHook(hook *scm.RepositoryHook, payload []byte) (*RepositoryHook, error) {
+	data := repositoryPayload{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, errors.Wrap(err, "failed to parse repository webhook payload")
+	}
+	h := &RepositoryHook{
+		RepositoryHook: hook,
+		RawAction:      data.Action,
+		PreviousOwner:  hook.Repo.Namespace,
+		PreviousName:   hook.Repo.Name,
+	}
+	if name := data.Changes.Repository.Name.From; name != "" {
+		h.PreviousName = name
+	}
+	if from := data.Changes.Owner.From; from.Organization != nil && from.Organization.Login != "" {
+		h.PreviousOwner = from.Organization.Login
+	} else if from.User != nil && from.User.Login != "" {
+		h.PreviousOwner = from.User.Login
+	}
+	return h, nil
+}
+
+// Moved returns true if the repository was renamed or transferred to another owner
+func (h *RepositoryHook) Moved() bool {
+	return (h.RawAction == RepositoryActionRenamed || h.RawAction == RepositoryActionTransferred) &&
+		h.PreviousFullName() != h.Repo.FullName
+}
+
+// PreviousFullName returns the full name of the repository before it was renamed or transferred
+func (h *RepositoryHook) PreviousFullName() string {
+	return scm.Join(h.PreviousOwner, h.PreviousName)
+}
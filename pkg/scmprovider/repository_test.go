@@ -0,0 +1,36 @@
+package scmprovider
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRepositoryHook(t *testing.T) {
+	hook := &scm.RepositoryHook{Repo: scm.Repository{Namespace: "org", Name: "new-name", FullName: "org/new-name"}}
+
+	renamed, err := ParseRepositoryHook(hook, []byte(`{"action": "renamed", "changes": {"repository": {"name": {"from": "old-name"}}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, RepositoryActionRenamed, renamed.RawAction)
+	assert.Equal(t, "org/old-name", renamed.PreviousFullName())
+	assert.True(t, renamed.Moved())
+
+	transferred, err := ParseRepositoryHook(hook, []byte(`{"action": "transferred", "changes": {"owner": {"from": {"organization": {"login": "old-org"}}}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "old-org/new-name", transferred.PreviousFullName())
+	assert.True(t, transferred.Moved())
+
+	fromUser, err := ParseRepositoryHook(hook, []byte(`{"action": "transferred", "changes": {"owner": {"from": {"user": {"login": "someone"}}}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "someone/new-name", fromUser.PreviousFullName())
+
+	archived, err := ParseRepositoryHook(hook, []byte(`{"action": "archived"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "org/new-name", archived.PreviousFullName())
+	assert.False(t, archived.Moved())
+
+	_, err = ParseRepositoryHook(hook, []byte(`not json`))
+	assert.Error(t, err)
+}
@@ -1,16 +1,20 @@
 package scmprovider
 
 import (
-	"context"
+	"net/http"
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 // ListReviews list the reviews
 func (c *Client) ListReviews(owner, repo string, number int) ([]*scm.Review, error) {
-	ctx := context.Background()
+	ctx := c.Context()
 	fullName := c.repositoryName(owner, repo)
+	if c.isGitea() {
+		return c.giteaListReviews(fullName, number)
+	}
 	var allReviews []*scm.Review
 	var resp *scm.Response
 	var reviews []*scm.Review
@@ -33,16 +37,28 @@ func (c *Client) ListReviews(owner, repo string, number int) ([]*scm.Review, err
 
 // RequestReview requests a review
 func (c *Client) RequestReview(org, repo string, number int, logins []string) error {
-	ctx := context.Background()
+	if c.readOnly("requesting reviews", logrus.Fields{"repo": c.repositoryName(org, repo), "number": number, "logins": logins}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(org, repo)
+	if c.isGitea() {
+		return errors.Wrapf(c.giteaRequestReview(http.MethodPost, fullName, number, logins), "requesting review from %s", logins)
+	}
 	_, err := c.client.PullRequests.RequestReview(ctx, fullName, number, logins)
 	return errors.Wrapf(err, "requesting review from %s", logins)
 }
 
 // UnrequestReview unrequest a review
 func (c *Client) UnrequestReview(org, repo string, number int, logins []string) error {
-	ctx := context.Background()
+	if c.readOnly("unrequesting reviews", logrus.Fields{"repo": c.repositoryName(org, repo), "number": number, "logins": logins}) {
+		return nil
+	}
+	ctx := c.Context()
 	fullName := c.repositoryName(org, repo)
+	if c.isGitea() {
+		return errors.Wrapf(c.giteaRequestReview(http.MethodDelete, fullName, number, logins), "unrequesting review from %s", logins)
+	}
 	_, err := c.client.PullRequests.UnrequestReview(ctx, fullName, number, logins)
 	return errors.Wrapf(err, "unrequesting review from %s", logins)
 }
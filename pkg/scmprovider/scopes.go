@@ -0,0 +1,81 @@
+package scmprovider
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+)
+
+// FineGrainedTokenPrefix is the prefix of GitHub fine-grained personal access tokens
+const FineGrainedTokenPrefix = "github_pat_"
+
+// impliedScopes are the OAuth scopes granted by other scopes
+var impliedScopes = map[string][]string{
+	"repo":            {"repo:status", "repo_deployment", "public_repo", "repo:invite", "security_events"},
+	"admin:org":       {"write:org", "read:org"},
+	"write:org":       {"read:org"},
+	"admin:repo_hook": {"write:repo_hook", "read:repo_hook"},
+	"write:repo_hook": {"read:repo_hook"},
+	"user":            {"read:user", "user:email", "user:follow"},
+}
+
+// TokenScopes returns the OAuth scopes of the token used by the client. The scopes
+// are only reported by GitHub for classic personal access tokens and OAuth tokens,
+// so reported is false for fine-grained tokens, GitHub App installation tokens and other providers.
+func (c *Client) TokenScopes() (scopes []string, reported bool, err error) {
+	if c.client.Driver != scm.DriverGithub {
+		return nil, false, nil
+	}
+	res, err := c.client.Do(c.Context(), &scm.Request{Method: "GET", Path: "user"})
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get the user of the token")
+	}
+	defer res.Body.Close()
+	if res.Status == http.StatusForbidden {
+		// GitHub App installation tokens can't get a user
+		return nil, false, nil
+	}
+	if res.Status > 299 {
+		return nil, false, errors.Errorf("failed to get the user of the token: status %d", res.Status)
+	}
+	values, ok := res.Header["X-Oauth-Scopes"]
+	if !ok {
+		return nil, false, nil
+	}
+	for _, value := range values {
+		for _, scope := range strings.Split(value, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes, true, nil
+}
+
+// HasScope returns true if the scopes grant the required scope, directly or implied by another scope
+func HasScope(scopes []string, required string) bool {
+	for _, scope := range scopes {
+		if scope == required {
+			return true
+		}
+		for _, implied := range impliedScopes[scope] {
+			if implied == required {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MissingScopes returns the required scopes which are not granted by the scopes
+func MissingScopes(scopes []string, required []string) []string {
+	var missing []string
+	for _, r := range required {
+		if !HasScope(scopes, r) {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,18 @@
+package scmprovider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingScopes(t *testing.T) {
+	assert.True(t, HasScope([]string{"repo"}, "repo"))
+	assert.True(t, HasScope([]string{"repo"}, "repo:status"))
+	assert.True(t, HasScope([]string{"admin:org"}, "read:org"))
+	assert.False(t, HasScope([]string{"public_repo"}, "repo"))
+	assert.False(t, HasScope(nil, "repo"))
+
+	assert.Empty(t, MissingScopes([]string{"repo", "write:org"}, []string{"repo", "read:org"}))
+	assert.Equal(t, []string{"read:org"}, MissingScopes([]string{"repo"}, []string{"repo", "read:org"}))
+}
@@ -0,0 +1,245 @@
+package scmprovider
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// searchPageSize is the number of search results requested per page
+	searchPageSize = 100
+	// maxSearchResults is the maximum number of results GitHub returns for a search
+	maxSearchResults = 1000
+	// maxSearchRateLimitWait is the longest time a search waits for the rate limit to reset
+	maxSearchRateLimitWait = time.Minute
+)
+
+// searchSleep is used to wait for the search rate limit to reset
+var searchSleep = time.Sleep
+
+// SearchQuery is a search for issues and pull requests which is translated to
+// the search syntax of the provider, or to listing and filtering pull requests
+// for providers without a search API
+type SearchQuery struct {
+	// PullRequests restricts the results to pull requests
+	PullRequests bool
+	// Open restricts the results to open issues and pull requests
+	Open bool
+	// Org restricts the results to an organisation or user
+	Org string
+	// Repo restricts the results to a repository of the Org
+	Repo string
+	// Labels restricts the results to issues and pull requests with all the labels
+	Labels []string
+	// Author restricts the results to issues and pull requests created by the user
+	Author string
+	// Commit restricts the results to issues and pull requests referencing the commit SHA
+	Commit string
+	// Sort is the field used to sort the results, e.g. "created" or "updated"
+	Sort string
+	// Ascending sorts the results in ascending order
+	Ascending bool
+}
+
+// String returns the query in the GitHub search syntax
+func (q SearchQuery) String() string {
+	var terms []string
+	if q.PullRequests {
+		terms = append(terms, "is:pr")
+	}
+	if q.Open {
+		terms = append(terms, "is:open")
+	}
+	if q.Org != "" && q.Repo != "" {
+		terms = append(terms, "repo:"+scm.Join(q.Org, q.Repo))
+	} else if q.Org != "" {
+		terms = append(terms, "org:"+q.Org)
+	}
+	for _, label := range q.Labels {
+		terms = append(terms, fmt.Sprintf("label:%q", label))
+	}
+	if q.Author != "" {
+		terms = append(terms, "author:"+q.Author)
+	}
+	if q.Commit != "" {
+		terms = append(terms, q.Commit)
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchAll returns all the issues and pull requests matching the query, following the
+// pagination of the results and waiting for the search rate limit to reset if needed.
+// Providers without a search API only support searching the issues and pull requests of a repository.
+func (c *Client) SearchAll(q SearchQuery) ([]*scm.SearchIssue, error) {
+	if c.client.Driver != scm.DriverGithub {
+		return c.searchRepository(q)
+	}
+	ctx := c.Context()
+	var results []*scm.SearchIssue
+	for page := 1; ; page++ {
+		opts := scm.SearchOptions{
+			Query:     q.String(),
+			Sort:      q.Sort,
+			Ascending: q.Ascending,
+			ListOptions: scm.ListOptions{
+				Page: page,
+				Size: searchPageSize,
+			},
+		}
+		pageResults, res, err := c.client.Issues.Search(ctx, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to search for %q", q.String())
+		}
+		results = append(results, pageResults...)
+		if res == nil || res.Page.Next == 0 || len(pageResults) == 0 || len(results) >= maxSearchResults {
+			return results, nil
+		}
+		if err := waitForRateLimit(res, "search"); err != nil {
+			return results, err
+		}
+	}
+}
+
+// searchRepository searches the issues and pull requests of a repository by listing and filtering them
+func (c *Client) searchRepository(q SearchQuery) ([]*scm.SearchIssue, error) {
+	if q.Org == "" || q.Repo == "" || q.Commit != "" {
+		return nil, errors.Wrapf(scm.ErrNotSupported, "search for %q on %s", q.String(), c.client.Driver.String())
+	}
+	fullName := scm.Join(q.Org, q.Repo)
+	opts := PullRequestListOptions{
+		PullRequestListOptions: scm.PullRequestListOptions{
+			Page:   1,
+			Size:   searchPageSize,
+			Open:   true,
+			Closed: !q.Open,
+			Labels: q.Labels,
+		},
+	}
+	prs, err := c.ListPullRequests(fullName, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the pull requests of %s", fullName)
+	}
+	var results []*scm.SearchIssue
+	for _, pr := range prs {
+		if matchesSearch(pr, q) {
+			results = append(results, pullRequestSearchIssue(pr))
+		}
+	}
+	if q.PullRequests {
+		return results, nil
+	}
+	issues, err := c.listIssues(fullName, q)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, issues...), nil
+}
+
+// listIssues lists the issues of a repository matching the state, labels and author of the query,
+// page by page, waiting for the rate limit to reset between the pages if needed
+func (c *Client) listIssues(fullName string, q SearchQuery) ([]*scm.SearchIssue, error) {
+	ctx := c.Context()
+	org, name := scm.Split(fullName)
+	repository := scm.Repository{Namespace: org, Name: name, FullName: fullName}
+	opts := scm.IssueListOptions{
+		Page:   1,
+		Size:   searchPageSize,
+		Open:   true,
+		Closed: !q.Open,
+	}
+	var results []*scm.SearchIssue
+	for {
+		issues, res, err := c.client.Issues.List(ctx, fullName, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the issues of %s", fullName)
+		}
+		for _, issue := range issues {
+			// some providers list the pull requests as issues too
+			if issue.PullRequest || !matchesIssueSearch(issue, q) {
+				continue
+			}
+			results = append(results, &scm.SearchIssue{Issue: *issue, Repository: repository})
+		}
+		if len(issues) == 0 || res == nil || opts.Page >= res.Page.Last {
+			return results, nil
+		}
+		opts.Page++
+		if err := waitForRateLimit(res, "issue listing"); err != nil {
+			return nil, errors.Wrapf(err, "failed to list the issues of %s", fullName)
+		}
+	}
+}
+
+// matchesIssueSearch returns true if the issue matches the state, labels and author of the query
+func matchesIssueSearch(issue *scm.Issue, q SearchQuery) bool {
+	if q.Open && issue.Closed {
+		return false
+	}
+	if q.Author != "" && !strings.EqualFold(issue.Author.Login, q.Author) {
+		return false
+	}
+	for _, required := range q.Labels {
+		found := false
+		for _, l := range issue.Labels {
+			if strings.EqualFold(l, required) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesSearch returns true if the pull request matches the state, labels and author of the query
+func matchesSearch(pr *scm.PullRequest, q SearchQuery) bool {
+	if q.Open && pr.Closed {
+		return false
+	}
+	if q.Author != "" && !strings.EqualFold(pr.Author.Login, q.Author) {
+		return false
+	}
+	for _, required := range q.Labels {
+		found := false
+		for _, l := range pr.Labels {
+			if strings.EqualFold(l.Name, required) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func pullRequestSearchIssue(pr *scm.PullRequest) *scm.SearchIssue {
+	var labels []string
+	for _, l := range pr.Labels {
+		labels = append(labels, l.Name)
+	}
+	return &scm.SearchIssue{
+		Issue: scm.Issue{
+			Number:      pr.Number,
+			Title:       pr.Title,
+			Body:        pr.Body,
+			Link:        pr.Link,
+			State:       pr.State,
+			Labels:      labels,
+			Closed:      pr.Closed,
+			Author:      pr.Author,
+			Assignees:   pr.Assignees,
+			PullRequest: true,
+			Created:     pr.Created,
+			Updated:     pr.Updated,
+		},
+		Repository: pr.Base.Repo,
+	}
+}
@@ -0,0 +1,94 @@
+package scmprovider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchQueryString(t *testing.T) {
+	q := SearchQuery{PullRequests: true, Open: true, Org: "org", Labels: []string{"approved", "needs review"}, Author: "someone"}
+	assert.Equal(t, `is:pr is:open org:org label:"approved" label:"needs review" author:someone`, q.String())
+
+	q = SearchQuery{Org: "org", Repo: "repo", Commit: "abc123"}
+	assert.Equal(t, "repo:org/repo abc123", q.String())
+}
+
+func TestSearchAllPaginates(t *testing.T) {
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/search/issues?page=2>; rel="next", <%s/search/issues?page=2>; rel="last"`, "http://"+r.Host, "http://"+r.Host))
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(10*time.Second).Unix(), 10))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"total_count": 2, "items": [{"number": %d, "title": "PR %d", "repository_url": "https://api.github.com/repos/org/repo"}]}`, page, page)
+	}))
+	defer server.Close()
+
+	var waited time.Duration
+	searchSleep = func(d time.Duration) { waited = d }
+	defer func() { searchSleep = time.Sleep }()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	client := ToClient(scmClient, "bot")
+
+	results, err := client.SearchAll(SearchQuery{PullRequests: true, Open: true, Org: "org", Labels: []string{"approved"}})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, results[0].Number)
+	assert.Equal(t, 2, results[1].Number)
+	assert.True(t, waited > 0 && waited <= 10*time.Second, "should wait for the rate limit to reset")
+
+	require.Len(t, queries, 2)
+	assert.Equal(t, `is:pr is:open org:org label:"approved"`, queries[0].Get("q"))
+	assert.Equal(t, "1", queries[0].Get("page"))
+	assert.Equal(t, "100", queries[0].Get("per_page"))
+	assert.Equal(t, "2", queries[1].Get("page"))
+}
+
+func TestMatchesSearch(t *testing.T) {
+	pr := &scm.PullRequest{
+		Number: 1,
+		Author: scm.User{Login: "Someone"},
+		Labels: []*scm.Label{{Name: "approved"}, {Name: "lgtm"}},
+	}
+	assert.True(t, matchesSearch(pr, SearchQuery{Open: true, Author: "someone", Labels: []string{"approved"}}))
+	assert.False(t, matchesSearch(pr, SearchQuery{Labels: []string{"approved", "hold"}}))
+	assert.False(t, matchesSearch(pr, SearchQuery{Author: "other"}))
+
+	pr.Closed = true
+	assert.False(t, matchesSearch(pr, SearchQuery{Open: true}))
+	assert.True(t, matchesSearch(pr, SearchQuery{}))
+
+	issue := pullRequestSearchIssue(pr)
+	assert.True(t, issue.PullRequest)
+	assert.Equal(t, []string{"approved", "lgtm"}, issue.Labels)
+}
+
+func TestMatchesIssueSearch(t *testing.T) {
+	issue := &scm.Issue{
+		Number: 1,
+		Author: scm.User{Login: "Someone"},
+		Labels: []string{"blocker", "bug"},
+	}
+	assert.True(t, matchesIssueSearch(issue, SearchQuery{Open: true, Author: "someone", Labels: []string{"Blocker"}}))
+	assert.False(t, matchesIssueSearch(issue, SearchQuery{Labels: []string{"blocker", "hold"}}))
+	assert.False(t, matchesIssueSearch(issue, SearchQuery{Author: "other"}))
+
+	issue.Closed = true
+	assert.False(t, matchesIssueSearch(issue, SearchQuery{Open: true}))
+}
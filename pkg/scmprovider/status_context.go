@@ -0,0 +1,62 @@
+package scmprovider
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+)
+
+// StatusContextPrefixEnvVar is the environment variable containing an optional prefix, such as "lighthouse/", which
+// is added to the context of all the commit statuses reported by Lighthouse.
+const StatusContextPrefixEnvVar = "LIGHTHOUSE_STATUS_CONTEXT_PREFIX"
+
+// GetStatusContextPrefix returns the prefix added to the context of the commit statuses reported by Lighthouse
+func GetStatusContextPrefix() string {
+	return os.Getenv(StatusContextPrefixEnvVar)
+}
+
+// AddStatusContextPrefix adds the status context prefix, if any, to the given context
+func AddStatusContextPrefix(context string) string {
+	prefix := GetStatusContextPrefix()
+	if prefix == "" || strings.HasPrefix(context, prefix) {
+		return context
+	}
+	return prefix + context
+}
+
+// TrimStatusContextPrefix removes the status context prefix, if any, from the given context. It returns true if the
+// context had the prefix.
+func TrimStatusContextPrefix(context string) (string, bool) {
+	prefix := GetStatusContextPrefix()
+	if prefix == "" || !strings.HasPrefix(context, prefix) {
+		return context, false
+	}
+	return strings.TrimPrefix(context, prefix), true
+}
+
+// trimStatusesContextPrefix removes the status context prefix from the statuses reported by Lighthouse so that they
+// match the contexts in the job configuration. Statuses reported by other systems with a context which collides
+// with one of the Lighthouse contexts are dropped.
+func trimStatusesContextPrefix(statuses []*scm.Status) []*scm.Status {
+	if GetStatusContextPrefix() == "" {
+		return statuses
+	}
+	ours := map[string]bool{}
+	for _, s := range statuses {
+		if context, ok := TrimStatusContextPrefix(s.Label); ok {
+			ours[context] = true
+		}
+	}
+	answer := make([]*scm.Status, 0, len(statuses))
+	for _, s := range statuses {
+		context, ok := TrimStatusContextPrefix(s.Label)
+		if !ok && ours[context] {
+			continue
+		}
+		status := *s
+		status.Label = context
+		answer = append(answer, &status)
+	}
+	return answer
+}
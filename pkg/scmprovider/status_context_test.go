@@ -0,0 +1,35 @@
+package scmprovider
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusContextPrefix(t *testing.T) {
+	os.Setenv(StatusContextPrefixEnvVar, "lighthouse/")
+	defer os.Unsetenv(StatusContextPrefixEnvVar)
+
+	assert.Equal(t, "lighthouse/unit", AddStatusContextPrefix("unit"))
+	assert.Equal(t, "lighthouse/unit", AddStatusContextPrefix("lighthouse/unit"))
+
+	statuses := trimStatusesContextPrefix([]*scm.Status{
+		{Label: "lighthouse/unit", State: scm.StateSuccess},
+		{Label: "unit", State: scm.StateFailure},
+		{Label: "other-ci", State: scm.StatePending},
+	})
+	assert.Equal(t, []*scm.Status{
+		{Label: "unit", State: scm.StateSuccess},
+		{Label: "other-ci", State: scm.StatePending},
+	}, statuses)
+}
+
+func TestStatusContextNoPrefix(t *testing.T) {
+	os.Unsetenv(StatusContextPrefixEnvVar)
+
+	assert.Equal(t, "unit", AddStatusContextPrefix("unit"))
+	statuses := []*scm.Status{{Label: "unit"}, {Label: "other-ci"}}
+	assert.Equal(t, statuses, trimStatusesContextPrefix(statuses))
+}
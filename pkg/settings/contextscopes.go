@@ -0,0 +1,85 @@
+package settings
+
+import (
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/pathmatch"
+	"github.com/pkg/errors"
+)
+
+// ContextScope scopes status contexts to the paths of a monorepo, such as the directory of a service.
+//
+// The contexts of a scope are only required for the PRs changing files matching its paths, so that
+// PRs only touching service A can merge while the jobs of service B are failing. The contexts of a
+// scope are the ones listed, or if none are listed the ones named after the scope, e.g. `service-a/unit`
+// for the `service-a` scope. Keeper also reports the status of each scope as the `<keeper>/<scope>`
+// context, e.g. `keeper/service-a`.
+type ContextScope struct {
+	Name string `json:"name"`
+	// Paths are patterns as supported by pathmatch.Match, e.g. `services/a/**` or `go.mod`
+	Paths    []string `json:"paths"`
+	Contexts []string `json:"contexts,omitempty"`
+}
+
+// HasContext returns true if the context belongs to the scope
+func (s *ContextScope) HasContext(context string) bool {
+	if len(s.Contexts) == 0 {
+		return strings.HasPrefix(context, s.Name+"/")
+	}
+	for _, c := range s.Contexts {
+		if c == context {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesFiles returns true if one of the files matches the paths of the scope
+func (s *ContextScope) MatchesFiles(files []string) bool {
+	for _, file := range files {
+		if pathmatch.MatchAny(s.Paths, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusContext returns the name of the status context reporting the scope, prefixed with the
+// status context of keeper
+func (s *ContextScope) StatusContext(keeperContext string) string {
+	return keeperContext + "/" + s.Name
+}
+
+// ContextScopes are the context scopes of the repositories keyed by org or org/repo.
+// The scopes of a repository are the ones of its org followed by its own.
+type ContextScopes map[string][]ContextScope
+
+// Validate checks the names and paths of the scopes
+func (s ContextScopes) Validate() error {
+	for key, scopes := range s {
+		names := map[string]bool{}
+		for _, scope := range scopes {
+			if scope.Name == "" {
+				return errors.Errorf("missing name for the scope of paths %v of %s", scope.Paths, key)
+			}
+			if names[scope.Name] {
+				return errors.Errorf("duplicate scope %s of %s", scope.Name, key)
+			}
+			names[scope.Name] = true
+			if len(scope.Paths) == 0 {
+				return errors.Errorf("missing paths for the scope %s of %s", scope.Name, key)
+			}
+			for _, p := range scope.Paths {
+				if err := pathmatch.Validate(p); err != nil {
+					return errors.Wrapf(err, "invalid path %s for the scope %s of %s", p, scope.Name, key)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ForRepo returns the scopes of the repository
+func (s ContextScopes) ForRepo(org, repo string) []ContextScope {
+	return append(append([]ContextScope{}, s[org]...), s[org+"/"+repo]...)
+}
@@ -0,0 +1,65 @@
+package settings
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadContextScopes(t *testing.T) {
+	cfg, err := Load([]byte(`
+tide:
+  contextScopes:
+    org:
+    - name: docs
+      paths: ["docs/**"]
+    org/monorepo:
+    - name: service-a
+      paths: ["services/a/**"]
+    - name: service-b
+      paths: ["services/b/**", "go.mod"]
+      contexts: [unit-b, e2e-b]
+`))
+	require.NoError(t, err)
+
+	var names []string
+	for _, s := range cfg.Keeper.ContextScopes.ForRepo("org", "monorepo") {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"docs", "service-a", "service-b"}, names)
+	assert.Len(t, cfg.Keeper.ContextScopes.ForRepo("org", "other"), 1)
+	assert.Empty(t, (&Config{}).Keeper.ContextScopes.ForRepo("org", "repo"))
+
+	_, err = Load([]byte(`
+tide:
+  contextScopes:
+    org/monorepo:
+    - name: service-a
+      paths: ["services/a/**"]
+    - name: service-a
+      paths: ["services/b/**"]
+`))
+	assert.Error(t, err, "the scope names of a repository must be unique")
+}
+
+func TestContextScopesValidate(t *testing.T) {
+	assert.NoError(t, ContextScopes{"org": {{Name: "a", Paths: []string{"a/**", "*.md"}}}}.Validate())
+	assert.Error(t, ContextScopes{"org": {{Paths: []string{"a/**"}}}}.Validate())
+	assert.Error(t, ContextScopes{"org": {{Name: "a"}}}.Validate())
+	assert.Error(t, ContextScopes{"org": {{Name: "a", Paths: []string{"[a/**"}}}}.Validate())
+}
+
+func TestContextScope(t *testing.T) {
+	named := &ContextScope{Name: "service-a", Paths: []string{"services/a/**"}}
+	assert.True(t, named.HasContext("service-a/unit"))
+	assert.False(t, named.HasContext("service-b/unit"))
+	assert.True(t, named.MatchesFiles([]string{"README.md", "services/a/main.go"}))
+	assert.False(t, named.MatchesFiles([]string{"services/b/main.go"}))
+	assert.Equal(t, "keeper/service-a", named.StatusContext("keeper"))
+
+	listed := &ContextScope{Name: "service-b", Paths: []string{"go.mod"}, Contexts: []string{"unit-b"}}
+	assert.True(t, listed.HasContext("unit-b"))
+	assert.False(t, listed.HasContext("service-b/unit"))
+	assert.True(t, listed.MatchesFiles([]string{"go.mod"}))
+}
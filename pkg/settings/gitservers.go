@@ -0,0 +1,127 @@
+package settings
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/gittoken"
+	"github.com/pkg/errors"
+)
+
+// GitServer is a git server the webhooks and foghorn talk to in addition to the one of $GIT_SERVER, such as
+// a GitHub Enterprise instance next to github.com
+type GitServer struct {
+	// Name identifies the server in the X-Lighthouse-Git-Server header or the server query parameter of
+	// the webhooks it delivers. As anyone may name the server of a webhook, its signature is checked with
+	// the HMAC token of the named server.
+	Name string `json:"name"`
+	// URL is the URL of the server, e.g. https://github.example.com
+	URL string `json:"url"`
+	// Kind is the kind of git provider, e.g. github, gitlab or bitbucketserver. Defaults to github.
+	Kind string `json:"kind,omitempty"`
+	// TokenEnv is the environment variable of the git token of the server
+	TokenEnv string `json:"tokenEnv"`
+	// User is the login of the bot on the server. Defaults to $GIT_USER.
+	User string `json:"user,omitempty"`
+	// HMACTokenEnv is the environment variable of the HMAC token signing the webhooks of the server. Defaults
+	// to $HMAC_TOKEN, which lets any sender with the HMAC token of the default server have its webhooks
+	// handled with the git token of the server it names.
+	HMACTokenEnv string `json:"hmacTokenEnv,omitempty"`
+}
+
+// GetKind returns the kind of git provider of the server
+func (s *GitServer) GetKind() string {
+	if s.Kind == "" {
+		return "github"
+	}
+	return s.Kind
+}
+
+// Token returns the git token of the server
+func (s *GitServer) Token() (string, error) {
+	value, err := (&gittoken.EnvProvider{EnvVar: s.TokenEnv}).Token()
+	if err != nil {
+		return value, errors.Wrapf(err, "no token available for git server %s", s.Name)
+	}
+	return value, nil
+}
+
+// HMACToken returns the HMAC token signing the webhooks of the server
+func (s *GitServer) HMACToken() string {
+	if s.HMACTokenEnv == "" {
+		return os.Getenv("HMAC_TOKEN")
+	}
+	return os.Getenv(s.HMACTokenEnv)
+}
+
+// Host returns the host of the URL of the server
+func (s *GitServer) Host() string {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// GitServers are the git servers besides the one of $GIT_SERVER, which remains the default server
+type GitServers []GitServer
+
+// Validate checks that the servers are named uniquely and have a URL and a token
+func (s GitServers) Validate() error {
+	names := map[string]bool{}
+	hosts := map[string]bool{}
+	for _, server := range s {
+		if server.Name == "" {
+			return errors.Errorf("missing name for git server %s", server.URL)
+		}
+		if names[server.Name] {
+			return errors.Errorf("duplicate git server %s", server.Name)
+		}
+		names[server.Name] = true
+		u, err := url.Parse(server.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.Errorf("invalid url %q for git server %s", server.URL, server.Name)
+		}
+		host := strings.ToLower(u.Host)
+		if hosts[host] {
+			return errors.Errorf("duplicate host %s for git server %s", host, server.Name)
+		}
+		hosts[host] = true
+		if server.TokenEnv == "" {
+			return errors.Errorf("missing tokenEnv for git server %s", server.Name)
+		}
+	}
+	return nil
+}
+
+// Named returns the server with the given name, or nil if there is none
+func (s GitServers) Named(name string) *GitServer {
+	for i := range s {
+		if s[i].Name == name {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+// ForHost returns the server of the host, e.g. github.example.com, or nil if the host is not one of the servers
+func (s GitServers) ForHost(host string) *GitServer {
+	host = strings.ToLower(host)
+	for i := range s {
+		if host != "" && s[i].Host() == host {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+// ForURL returns the server of the URL, such as the link or the clone URL of a repository, or nil if the URL
+// is not on one of the servers
+func (s GitServers) ForURL(link string) *GitServer {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil
+	}
+	return s.ForHost(u.Host)
+}
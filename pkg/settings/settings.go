@@ -0,0 +1,853 @@
+// Package settings contains the lighthouse settings of the config.yaml file which are not part of
+// the lighthouse-config types. They are read from the same file as the rest of the configuration,
+// so that they are reloaded with it.
+package settings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the lighthouse specific part of config.yaml
+type Config struct {
+	// Keeper extends the tide section of the configuration
+	Keeper Keeper `json:"tide,omitempty"`
+	// Foghorn are the settings of foghorn
+	Foghorn Foghorn `json:"foghorn,omitempty"`
+	// Webhooks are the settings of the webhooks
+	Webhooks Webhooks `json:"webhooks,omitempty"`
+	// DefaultEnv are the environment variables added to every job which does not set them itself,
+	// e.g. ARTIFACT_BUCKET: gs://my-artifacts
+	DefaultEnv map[string]string `json:"default_env,omitempty"`
+	// GitCredentials configure the git credentials served to the pipelines by the webhooks
+	GitCredentials GitCredentials `json:"gitCredentials,omitempty"`
+	// Provenance configures the signed provenance of the jobs launched and the PRs merged
+	Provenance Provenance `json:"provenance,omitempty"`
+	// Comments configure the comments posted on the issues and pull requests
+	Comments Comments `json:"comments,omitempty"`
+	// ChangedModules configure the grouping of the changed files into the modules passed to the jobs
+	ChangedModules ChangedModules `json:"changedModules,omitempty"`
+	// Launcher configures the launching of the pipelines of the jobs
+	Launcher Launcher `json:"launcher,omitempty"`
+	// InRepoConfig configures the repositories whose jobs are also read from their .lighthouse directory
+	InRepoConfig InRepoConfig `json:"inRepoConfig,omitempty"`
+	// ReadOnly disables the changes to the git providers, such as the comments, labels, statuses and
+	// merges, and the launching of jobs: the components only log the actions they would have taken
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// Audit configures the audit log of the changes made to the git providers
+	Audit Audit `json:"audit,omitempty"`
+	// Labels declare the labels keeper creates and updates on the repositories
+	Labels LabelSync `json:"labels,omitempty"`
+	// Notifications route the notifications of the job failures, merges and stuck pools to Slack channels
+	Notifications Notifications `json:"notifications,omitempty"`
+	// StatusContexts name the status contexts of the jobs after the naming conventions of the orgs
+	StatusContexts StatusContexts `json:"statusContexts,omitempty"`
+	// PresubmitFilters configure the filtering of the presubmits which is not part of their job definitions
+	PresubmitFilters PresubmitFilters `json:"presubmitFilters,omitempty"`
+	// PostsubmitFilters configure the branches and tags the postsubmits of the repositories are launched for
+	PostsubmitFilters PostsubmitFilters `json:"postsubmitFilters,omitempty"`
+	// GitServers are the git servers the webhooks and foghorn talk to besides the one of $GIT_SERVER
+	GitServers GitServers `json:"gitServers,omitempty"`
+
+	// Version is the sha256 digest of the config.yaml file the settings were loaded from, which
+	// identifies the configuration in the provenance of the jobs and merges
+	Version string `json:"-"`
+}
+
+// InRepoConfig configures the repositories which configure presubmits and postsubmits themselves in the
+// .lighthouse directory of their branches, in addition to the jobs of the central configuration
+type InRepoConfig struct {
+	// Repos are the repositories as org/repo, or org for all the repositories of an org
+	Repos []string `json:"repos,omitempty"`
+}
+
+// Enabled returns true if the org/repo repository configures jobs in its .lighthouse directory
+func (c *InRepoConfig) Enabled(org, repo string) bool {
+	for _, r := range c.Repos {
+		if strings.EqualFold(r, org+"/"+repo) || strings.EqualFold(r, org) {
+			return true
+		}
+	}
+	return false
+}
+
+// Audit configures the audit log recording every change made to the git providers, such as the statuses,
+// comments, labels and merges, including the ones skipped in read-only mode
+type Audit struct {
+	// Sink is where the audit entries are written as JSON lines: either stdout, the path of a file they are
+	// appended to, or an http(s) URL they are posted to. Nothing is audited if it is not set.
+	Sink string `json:"sink,omitempty"`
+}
+
+// LabelSync declares the labels keeper reconciles onto the repositories of its queries: the missing labels are
+// created and the color and description of the existing ones updated, the other labels are left alone
+type LabelSync struct {
+	// Default are the labels of every repository, such as the labels required by the plugins
+	Default []Label `json:"default,omitempty"`
+	// Repos are the additional labels of repositories keyed by org/repo, or by org for all the repositories
+	// of an org
+	Repos map[string][]Label `json:"repos,omitempty"`
+}
+
+// Label is a label of the repositories
+type Label struct {
+	Name string `json:"name"`
+	// Color is the hex color of the label, e.g. 0ffa16
+	Color       string `json:"color"`
+	Description string `json:"description,omitempty"`
+}
+
+// labelColorRegex matches the hex colors of the labels
+var labelColorRegex = regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`)
+
+// For returns the labels of the org/repo repository: the default labels, those of its org and its own,
+// the labels of a repository overriding the ones of its org, which override the default labels
+func (l *LabelSync) For(org, repo string) []Label {
+	var labels []Label
+	index := map[string]int{}
+	for _, ls := range [][]Label{l.Default, l.Repos[org], l.Repos[org+"/"+repo]} {
+		for _, label := range ls {
+			key := strings.ToLower(label.Name)
+			if i, ok := index[key]; ok {
+				labels[i] = label
+				continue
+			}
+			index[key] = len(labels)
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// Validate checks the names and colors of the labels
+func (l *LabelSync) Validate() error {
+	all := map[string][]Label{"default": l.Default}
+	for key, labels := range l.Repos {
+		all[key] = labels
+	}
+	for key, labels := range all {
+		for _, label := range labels {
+			if label.Name == "" {
+				return errors.Errorf("missing name for a label of %s", key)
+			}
+			if !labelColorRegex.MatchString(label.Color) {
+				return errors.Errorf("invalid color %q for label %s of %s", label.Color, label.Name, key)
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	// NotifyJobFailed notifies the failures of the jobs
+	NotifyJobFailed = "jobFailed"
+	// NotifyMerged notifies the PRs merged by keeper
+	NotifyMerged = "merged"
+	// NotifyStuckPool notifies the pools whose PRs keeper has not merged for longer than the stuck PR threshold
+	NotifyStuckPool = "stuckPool"
+)
+
+// Notifications route the notifications of foghorn and keeper to Slack channels and email addresses
+type Notifications struct {
+	// Slack are the routes of the notifications to Slack channels. A notification is posted to the channel
+	// of every route matching its repository and event.
+	Slack []SlackRoute `json:"slack,omitempty"`
+	// Email configures the emails sent to the owners of the repositories whose postsubmits keep failing
+	Email EmailNotifications `json:"email,omitempty"`
+}
+
+// DefaultConsecutiveFailures is the default number of consecutive failures of a postsubmit after which its
+// owners are emailed
+const DefaultConsecutiveFailures = 3
+
+// EmailNotifications configures the emails sent when a postsubmit job fails several times in a row on a branch
+type EmailNotifications struct {
+	// SMTPServer is the host:port of the SMTP server the emails are sent with. No email is sent if it is empty.
+	SMTPServer string `json:"smtpServer,omitempty"`
+	// From is the sender address of the emails
+	From string `json:"from,omitempty"`
+	// ConsecutiveFailures is the number of consecutive failures of a postsubmit job on a branch after which
+	// the owners are emailed, once per series of failures. Defaults to DefaultConsecutiveFailures.
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// Owners are the email addresses of the owners of the repositories keyed by org/repo, or by org for all
+	// the repositories of an org
+	Owners map[string][]string `json:"owners,omitempty"`
+}
+
+// Threshold returns the number of consecutive failures after which the owners are emailed
+func (e *EmailNotifications) Threshold() int {
+	if e.ConsecutiveFailures <= 0 {
+		return DefaultConsecutiveFailures
+	}
+	return e.ConsecutiveFailures
+}
+
+// Recipients returns the email addresses of the owners of the org/repo repository and of its org
+func (e *EmailNotifications) Recipients(org, repo string) []string {
+	var answer []string
+	seen := map[string]bool{}
+	for _, key := range []string{org + "/" + repo, org} {
+		for _, address := range e.Owners[key] {
+			if !seen[address] {
+				seen[address] = true
+				answer = append(answer, address)
+			}
+		}
+	}
+	return answer
+}
+
+// SlackRoute routes the notifications of some repositories to a Slack channel
+type SlackRoute struct {
+	// Channel is the name or ID of the channel, e.g. #ci-failures
+	Channel string `json:"channel"`
+	// Repos are the repositories as org/repo, or org for all the repositories of an org. The route matches
+	// all the repositories if it is empty.
+	Repos []string `json:"repos,omitempty"`
+	// Events are the notified events among jobFailed, merged and stuckPool. The route matches all the
+	// events if it is empty.
+	Events []string `json:"events,omitempty"`
+}
+
+// SlackChannels returns the channels the event of the org/repo repository is posted to
+func (n *Notifications) SlackChannels(org, repo, event string) []string {
+	var channels []string
+	seen := map[string]bool{}
+	for _, route := range n.Slack {
+		if seen[route.Channel] || !route.matches(org, repo, event) {
+			continue
+		}
+		seen[route.Channel] = true
+		channels = append(channels, route.Channel)
+	}
+	return channels
+}
+
+func (r *SlackRoute) matches(org, repo, event string) bool {
+	if len(r.Events) > 0 && !contains(r.Events, event) {
+		return false
+	}
+	if len(r.Repos) == 0 {
+		return true
+	}
+	for _, name := range r.Repos {
+		if strings.EqualFold(name, org+"/"+repo) || strings.EqualFold(name, org) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the channels and events of the routes and the email settings
+func (n *Notifications) Validate() error {
+	if n.Email.ConsecutiveFailures < 0 {
+		return errors.Errorf("email.consecutiveFailures must not be negative")
+	}
+	if n.Email.SMTPServer != "" && n.Email.From == "" {
+		return errors.Errorf("missing email.from for the SMTP server %s", n.Email.SMTPServer)
+	}
+	for i, route := range n.Slack {
+		if route.Channel == "" {
+			return errors.Errorf("missing channel for slack route %d", i)
+		}
+		for _, event := range route.Events {
+			switch event {
+			case NotifyJobFailed, NotifyMerged, NotifyStuckPool:
+			default:
+				return errors.Errorf("unknown event %q for slack route %d, must be %s, %s or %s", event, i, NotifyJobFailed, NotifyMerged, NotifyStuckPool)
+			}
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusContextParams are the parameters of the status context templates
+var StatusContextParams = []string{"Org", "Repo", "Type", "Job", "Context"}
+
+// StatusContexts name the status contexts the jobs report, e.g. with the org-wide prefix of
+// "ci/{{ .Type }}/{{ .Context }}". The templates are Go templates of the StatusContextParams, where Type
+// is either presubmit or postsubmit, Context is the context configured for the job, which defaults to its
+// name, and Repo is empty for the jobs configured for a whole org. The contexts of the jobs are renamed
+// when the configuration is loaded, so that the statuses the jobs report are the ones keeper requires.
+type StatusContexts struct {
+	// Template is the template of the contexts of all the jobs
+	Template string `json:"template,omitempty"`
+	// Presubmit is the template of the contexts of the presubmits, which overrides Template
+	Presubmit string `json:"presubmit,omitempty"`
+	// Postsubmit is the template of the contexts of the postsubmits, which overrides Template
+	Postsubmit string `json:"postsubmit,omitempty"`
+	// Repos are the templates of the jobs configured for a repository, keyed by org/repo, or by org for
+	// all the jobs of an org, which override the templates of the job types
+	Repos map[string]string `json:"repos,omitempty"`
+	// Jobs are the templates of the jobs keyed by job name, which override the templates of the repositories
+	Jobs map[string]string `json:"jobs,omitempty"`
+}
+
+// IsEmpty returns true if the contexts of the jobs are not renamed
+func (s *StatusContexts) IsEmpty() bool {
+	return s.Template == "" && s.Presubmit == "" && s.Postsubmit == "" && len(s.Repos) == 0 && len(s.Jobs) == 0
+}
+
+// Context returns the context of the job of the given type configured for the org/repo repository, or
+// for the org if repo is empty
+func (s *StatusContexts) Context(org, repo, jobType, job, context string) (string, error) {
+	text := s.templateFor(org, repo, jobType, job)
+	if text == "" {
+		return context, nil
+	}
+	tmpl, err := template.New(job).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid status context template of job %s", job)
+	}
+	buf := strings.Builder{}
+	data := map[string]string{"Org": org, "Repo": repo, "Type": jobType, "Job": job, "Context": context}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "failed to evaluate the status context template of job %s", job)
+	}
+	answer := strings.TrimSpace(buf.String())
+	if answer == "" {
+		return "", errors.Errorf("the status context template of job %s is empty", job)
+	}
+	return answer, nil
+}
+
+func (s *StatusContexts) templateFor(org, repo, jobType, job string) string {
+	candidates := []string{s.Jobs[job]}
+	if repo != "" {
+		candidates = append(candidates, s.Repos[org+"/"+repo])
+	}
+	candidates = append(candidates, s.Repos[org])
+	switch jobType {
+	case "presubmit":
+		candidates = append(candidates, s.Presubmit)
+	case "postsubmit":
+		candidates = append(candidates, s.Postsubmit)
+	}
+	for _, t := range append(candidates, s.Template) {
+		if t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// Validate checks that the templates parse and only use the StatusContextParams
+func (s *StatusContexts) Validate() error {
+	all := map[string]string{"template": s.Template, "presubmit": s.Presubmit, "postsubmit": s.Postsubmit}
+	for key, t := range s.Repos {
+		all["repos."+key] = t
+	}
+	for key, t := range s.Jobs {
+		all["jobs."+key] = t
+	}
+	return validateTemplates(all, StatusContextParams)
+}
+
+// PresubmitFilters configure the filtering of the presubmits
+type PresubmitFilters struct {
+	// SkipIfOnlyChanged are regexes of the files keyed by presubmit name. A presubmit is skipped, and
+	// reported as skipped, when all the files changed by a PR match its regex, e.g. `^docs/|\.md$`. The
+	// presubmits still run when they are requested with /test.
+	SkipIfOnlyChanged map[string]string `json:"skipIfOnlyChanged,omitempty"`
+
+	skipIfOnlyChanged map[string]*regexp.Regexp
+}
+
+// Validate checks that the regexes compile
+func (p *PresubmitFilters) Validate() error {
+	p.skipIfOnlyChanged = map[string]*regexp.Regexp{}
+	for job, text := range p.SkipIfOnlyChanged {
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return errors.Wrapf(err, "invalid skipIfOnlyChanged regex of %s", job)
+		}
+		p.skipIfOnlyChanged[job] = re
+	}
+	return nil
+}
+
+// SkipsChanges returns true if the presubmit is skipped for the changed files as they all match its
+// skipIfOnlyChanged regex, which is the case when no file changed
+func (p *PresubmitFilters) SkipsChanges(job string, changes []string) bool {
+	re := p.skipIfOnlyChanged[job]
+	if re == nil {
+		text, ok := p.SkipIfOnlyChanged[job]
+		if !ok {
+			return false
+		}
+		var err error
+		if re, err = regexp.Compile(text); err != nil {
+			return false
+		}
+	}
+	for _, change := range changes {
+		if !re.MatchString(change) {
+			return false
+		}
+	}
+	return true
+}
+
+// PostsubmitFilters configure the branches and tags the postsubmits are launched for when they are pushed,
+// on top of the branches and skip_branches of the jobs, e.g. to not launch the postsubmits of a repository
+// for its feature branches
+type PostsubmitFilters struct {
+	BranchFilter `json:",inline"`
+	// Repos are the filters of repositories by org/repo, or by org for all the repositories of an org,
+	// which replace the default filter
+	Repos map[string]BranchFilter `json:"repos,omitempty"`
+}
+
+// BranchFilter filters the branches and tags with regexes, which are not anchored: use ^ and $ to match
+// the whole name, e.g. ^release-.*$
+type BranchFilter struct {
+	// Branches are the regexes of the branches and tags which are pushed, all of them if there are none
+	Branches []string `json:"branches,omitempty"`
+	// SkipBranches are the regexes of the branches and tags which are not, taking precedence over Branches
+	SkipBranches []string `json:"skipBranches,omitempty"`
+
+	branches     *regexp.Regexp
+	skipBranches *regexp.Regexp
+}
+
+// Validate checks that the regexes compile
+func (p *PostsubmitFilters) Validate() error {
+	if err := p.BranchFilter.compile(); err != nil {
+		return err
+	}
+	for key, filter := range p.Repos {
+		if err := filter.compile(); err != nil {
+			return errors.Wrapf(err, "invalid filter of %s", key)
+		}
+		p.Repos[key] = filter
+	}
+	return nil
+}
+
+// ShouldRun returns true if the postsubmits of the org/repo repository are launched for the branch or tag
+func (p *PostsubmitFilters) ShouldRun(org, repo, branch string) bool {
+	if filter, ok := p.Repos[org+"/"+repo]; ok {
+		return filter.ShouldRun(branch)
+	}
+	if filter, ok := p.Repos[org]; ok {
+		return filter.ShouldRun(branch)
+	}
+	return p.BranchFilter.ShouldRun(branch)
+}
+
+// ShouldRun returns true if the branch or tag matches the filter
+func (f *BranchFilter) ShouldRun(branch string) bool {
+	if f.branches == nil && f.skipBranches == nil && (len(f.Branches) > 0 || len(f.SkipBranches) > 0) {
+		// the filter was not validated, such as in tests
+		if err := f.compile(); err != nil {
+			return true
+		}
+	}
+	if f.skipBranches != nil && f.skipBranches.MatchString(branch) {
+		return false
+	}
+	return f.branches == nil || f.branches.MatchString(branch)
+}
+
+func (f *BranchFilter) compile() error {
+	var err error
+	if f.branches, err = compileAlternatives(f.Branches); err != nil {
+		return errors.Wrap(err, "invalid branches")
+	}
+	if f.skipBranches, err = compileAlternatives(f.SkipBranches); err != nil {
+		return errors.Wrap(err, "invalid skipBranches")
+	}
+	return nil
+}
+
+// compileAlternatives compiles a regex matching any of the regexes, or returns nil if there are none
+func compileAlternatives(all []string) (*regexp.Regexp, error) {
+	if len(all) == 0 {
+		return nil, nil
+	}
+	for _, text := range all {
+		if _, err := regexp.Compile(text); err != nil {
+			return nil, err
+		}
+	}
+	return regexp.Compile("(?:" + strings.Join(all, ")|(?:") + ")")
+}
+
+// Launcher configures the launching of the pipelines of the jobs
+type Launcher struct {
+	// DefaultAgent is the agent launching the pipelines of the jobs which do not name one, e.g. tekton.
+	// Defaults to jx, which launches the jx meta pipeline.
+	DefaultAgent string `json:"defaultAgent,omitempty"`
+}
+
+// ChangedModules configure how the files changed by pull requests and pushes are grouped into the
+// modules passed to the jobs in $CHANGED_MODULES. The patterns are directory patterns as supported by
+// path.Match, e.g. `services/*`: the files under a directory matching a pattern belong to the module
+// of this directory, the first matching pattern wins. The files matching no pattern belong to the `.`
+// module. The top level directories are the modules by default.
+type ChangedModules struct {
+	// Patterns are the module patterns of the repositories which are not listed in Repos
+	Patterns []string `json:"patterns,omitempty"`
+	// Repos are the module patterns of repositories by org/repo, or by org for all the repositories of an org
+	Repos map[string][]string `json:"repos,omitempty"`
+}
+
+// PatternsFor returns the module patterns of the org/repo repository
+func (c *ChangedModules) PatternsFor(org, repo string) []string {
+	if patterns, ok := c.Repos[org+"/"+repo]; ok {
+		return patterns
+	}
+	if patterns, ok := c.Repos[org]; ok {
+		return patterns
+	}
+	return c.Patterns
+}
+
+// Comments configure the comments posted on the issues and pull requests
+type Comments struct {
+	// MaxLength overrides the maximum size in bytes of the comments, which defaults to the limit of the git provider
+	MaxLength int `json:"maxLength,omitempty"`
+	// OverflowBucketURL is the bucket URL the full content of truncated comments is written below,
+	// e.g. gs://my-bucket/comments. The full content is dropped if it is not set.
+	OverflowBucketURL string `json:"overflowBucketURL,omitempty"`
+	// OverflowURL is the URL the overflow bucket is served from, which truncated comments link to
+	OverflowURL string `json:"overflowURL,omitempty"`
+}
+
+// Provenance configures the signing of the provenance of the LighthouseJobs launched and the PRs merged
+type Provenance struct {
+	// SigningKey is either the path of an unencrypted PEM private key or the resource name of a
+	// Google Cloud KMS key version prefixed with gcpkms://, e.g.
+	// gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1.
+	// Provenance is disabled when it is not set.
+	SigningKey string `json:"signingKey,omitempty"`
+	// Dir is the directory the signed envelopes are also stored in, such as a volume backed by object storage
+	Dir string `json:"dir,omitempty"`
+}
+
+// DefaultGitCredentialsAudience is the default audience of the projected ServiceAccount tokens of the
+// pipelines fetching git credentials
+const DefaultGitCredentialsAudience = "lighthouse-git-credentials"
+
+// GitCredentials configure the GitHub App installation tokens served to the git credential helper of the
+// pipelines. Each token is created for the repository requested by the pipeline, which its ServiceAccount
+// must be bound to, and only grants the permissions on that repository.
+type GitCredentials struct {
+	// Audience is the audience the projected ServiceAccount tokens of the pipelines must be issued for,
+	// so that tokens issued for other services are refused. Defaults to DefaultGitCredentialsAudience
+	Audience string `json:"audience,omitempty"`
+	// ServiceAccounts are the ServiceAccounts allowed to fetch git credentials, no git credentials are
+	// served if there are none
+	ServiceAccounts []GitCredentialsServiceAccount `json:"serviceAccounts,omitempty"`
+	// Permissions are the GitHub App permissions of the tokens on the repository, e.g. {contents: read}.
+	// Defaults to DefaultGitCredentialsPermissions
+	Permissions map[string]string `json:"permissions,omitempty"`
+}
+
+// DefaultGitCredentialsPermissions are the permissions of the git credentials by default, which allow the
+// pipelines to clone the repository and to push to it, e.g. their release tags
+var DefaultGitCredentialsPermissions = map[string]string{"contents": "write"}
+
+// GitCredentialsServiceAccount binds ServiceAccounts to the repositories they can fetch git credentials for
+type GitCredentialsServiceAccount struct {
+	// Namespace is the namespace of the ServiceAccount
+	Namespace string `json:"namespace"`
+	// Name is the name of the ServiceAccount, all the ServiceAccounts of the namespace are bound if it is empty
+	Name string `json:"name,omitempty"`
+	// Repos are the repositories as org/repo, or org for all the repositories of an org
+	Repos []string `json:"repos"`
+}
+
+// GetAudience returns the audience of the tokens fetching git credentials
+func (g *GitCredentials) GetAudience() string {
+	if g.Audience == "" {
+		return DefaultGitCredentialsAudience
+	}
+	return g.Audience
+}
+
+// GetPermissions returns the permissions of the git credentials on the repository
+func (g *GitCredentials) GetPermissions() map[string]string {
+	if len(g.Permissions) == 0 {
+		return DefaultGitCredentialsPermissions
+	}
+	return g.Permissions
+}
+
+// Allows returns true if the ServiceAccount can fetch the git credentials of the org/repo repository
+func (g *GitCredentials) Allows(namespace, name, repo string) bool {
+	org := strings.SplitN(repo, "/", 2)[0]
+	for _, sa := range g.ServiceAccounts {
+		if sa.Namespace != namespace || (sa.Name != "" && sa.Name != name) {
+			continue
+		}
+		for _, r := range sa.Repos {
+			if strings.EqualFold(r, repo) || strings.EqualFold(r, org) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Foghorn are the settings of foghorn
+type Foghorn struct {
+	// PendingTimeout is how long a presubmit may stay triggered or pending before it is marked as
+	// errored and its pipeline is cancelled, e.g. 30m. Pending jobs never time out if it is not set.
+	PendingTimeout *metav1.Duration `json:"pendingTimeout,omitempty"`
+	// CheckRuns are the kinds of the git providers the pipelines are also reported to as check runs, with
+	// a summary of their stages and a re-run action, e.g. [github]. Check runs require GitHub App credentials.
+	CheckRuns []string `json:"checkRuns,omitempty"`
+	// ReportURL configures the target URLs of the statuses reported for the pipelines
+	ReportURL ReportURL `json:"reportURL,omitempty"`
+}
+
+// ReportURLParams are the parameters of the target URL templates
+var ReportURLParams = []string{"BaseURL", "Owner", "Repository", "Branch", "Build", "Context", "Team", "Job"}
+
+// ReportURL configures the target URLs of the statuses foghorn reports for the pipelines, which link to their
+// logs. The templates are Go templates of the ReportURLParams, e.g.
+// {{ .BaseURL }}/teams/{{ .Team }}/projects/{{ .Owner }}/{{ .Repository }}/{{ .Branch }}/{{ .Build }}
+type ReportURL struct {
+	// BaseURL is the BaseURL parameter of the templates. It defaults to $LIGHTHOUSE_REPORT_URL_BASE.
+	BaseURL string `json:"baseURL,omitempty"`
+	// Team is the Team parameter of the templates. It defaults to $LIGHTHOUSE_REPORT_URL_TEAM, or to the
+	// namespace of the jobs.
+	Team string `json:"team,omitempty"`
+	// Template is the template of the target URLs of all the jobs
+	Template string `json:"template,omitempty"`
+	// Repos are the templates of the repositories keyed by org/repo, or by org for all the repositories of
+	// an org, which override Template
+	Repos map[string]string `json:"repos,omitempty"`
+	// Jobs are the templates of the jobs keyed by job name, which override the templates of the repositories
+	Jobs map[string]string `json:"jobs,omitempty"`
+}
+
+// TemplateFor returns the template of the target URLs of the job of the org/repo repository, or an empty
+// string if none is configured
+func (r *ReportURL) TemplateFor(org, repo, job string) string {
+	for _, t := range []string{r.Jobs[job], r.Repos[org+"/"+repo], r.Repos[org], r.Template} {
+		if t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// Validate checks that the templates parse and only use the ReportURLParams
+func (r *ReportURL) Validate() error {
+	all := map[string]string{"template": r.Template}
+	for key, t := range r.Repos {
+		all["repos."+key] = t
+	}
+	for key, t := range r.Jobs {
+		all["jobs."+key] = t
+	}
+	return validateTemplates(all, ReportURLParams)
+}
+
+// validateTemplates checks that the templates keyed by their setting parse and only use the params
+func validateTemplates(all map[string]string, params []string) error {
+	data := map[string]string{}
+	for _, name := range params {
+		data[name] = name
+	}
+	for key, text := range all {
+		if text == "" {
+			continue
+		}
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s", key)
+		}
+		if err := tmpl.Execute(ioutil.Discard, data); err != nil {
+			return errors.Wrapf(err, "invalid %s, the parameters are %s", key, strings.Join(params, ", "))
+		}
+	}
+	return nil
+}
+
+// ReportsCheckRuns returns true if the pipelines are reported as check runs to the git provider of the given kind
+func (f *Foghorn) ReportsCheckRuns(gitKind string) bool {
+	for _, kind := range f.CheckRuns {
+		if strings.EqualFold(kind, gitKind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhooks are the settings of the webhooks
+type Webhooks struct {
+	// EventDeadline is the maximum duration of the handling of an event by all its plugins, e.g. 5m,
+	// after which their SCM calls are cancelled. Events have no deadline if it is not set.
+	EventDeadline *metav1.Duration `json:"eventDeadline,omitempty"`
+	// MaxPayloadSize is the maximum size of the webhook payloads, e.g. 10Mi, both as delivered and once
+	// decompressed. Larger payloads are rejected. It defaults to DefaultMaxPayloadSize.
+	MaxPayloadSize *resource.Quantity `json:"maxPayloadSize,omitempty"`
+}
+
+// DefaultMaxPayloadSize is the default maximum size of the webhook payloads, which is the size GitHub
+// caps its payloads to
+const DefaultMaxPayloadSize = 25 * 1024 * 1024
+
+// GetEventDeadline returns the deadline of the handling of an event, which is zero if there is none
+func (w *Webhooks) GetEventDeadline() time.Duration {
+	if w.EventDeadline == nil || w.EventDeadline.Duration < 0 {
+		return 0
+	}
+	return w.EventDeadline.Duration
+}
+
+// GetMaxPayloadSize returns the maximum size of the webhook payloads in bytes
+func (w *Webhooks) GetMaxPayloadSize() int64 {
+	if w.MaxPayloadSize == nil || w.MaxPayloadSize.Value() <= 0 {
+		return DefaultMaxPayloadSize
+	}
+	return w.MaxPayloadSize.Value()
+}
+
+// Keeper are the lighthouse specific settings of keeper
+type Keeper struct {
+	// Queries extend the keeper queries of the same index
+	Queries []KeeperQuery `json:"queries,omitempty"`
+	// ContextScopes scope the required contexts of the PRs of monorepos to the paths they change,
+	// keyed by org or org/repo
+	ContextScopes ContextScopes `json:"contextScopes,omitempty"`
+}
+
+// KeeperQuery are the lighthouse specific settings of a keeper query
+type KeeperQuery struct {
+	// ExcludedTitles are regexes of the titles of the PRs kept out of the merge pool, e.g. `^\[WIP\]`
+	ExcludedTitles []string `json:"excludedTitles,omitempty"`
+	// ExcludedPaths keep the PRs which only change matching files out of the merge pool. They are
+	// either `dir/**`, matching all the files below dir, or patterns as supported by path.Match
+	ExcludedPaths []string `json:"excludedPaths,omitempty"`
+	// OrgDefault makes the query the default of the repositories of its orgs: the repositories named by
+	// the queries which are not org defaults are excluded from it, so that they override it
+	OrgDefault bool `json:"orgDefault,omitempty"`
+}
+
+// Query returns the settings of the keeper query of the given index
+func (k *Keeper) Query(i int) KeeperQuery {
+	if i < 0 || i >= len(k.Queries) {
+		return KeeperQuery{}
+	}
+	return k.Queries[i]
+}
+
+// Load parses the lighthouse settings of the config.yaml data
+func Load(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the lighthouse settings")
+	}
+	if err := cfg.Keeper.ContextScopes.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid tide.contextScopes")
+	}
+	if err := cfg.Labels.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid labels")
+	}
+	if err := cfg.Notifications.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid notifications")
+	}
+	if err := cfg.Foghorn.ReportURL.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid foghorn.reportURL")
+	}
+	if err := cfg.StatusContexts.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid statusContexts")
+	}
+	if err := cfg.PresubmitFilters.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid presubmitFilters")
+	}
+	if err := cfg.PostsubmitFilters.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid postsubmitFilters")
+	}
+	if err := cfg.GitServers.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid gitServers")
+	}
+	digest := sha256.Sum256(data)
+	cfg.Version = "sha256:" + hex.EncodeToString(digest[:])
+	return cfg, nil
+}
+
+// Getter returns the current settings
+type Getter func() *Config
+
+// Agent holds the current settings
+type Agent struct {
+	lock   sync.Mutex
+	config *Config
+}
+
+// Config returns the current settings, which are empty if none were loaded
+func (a *Agent) Config() *Config {
+	if a == nil {
+		return &Config{}
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.config == nil {
+		return &Config{}
+	}
+	return a.config
+}
+
+// Set sets the current settings
+func (a *Agent) Set(cfg *Config) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.config = cfg
+}
+
+// Load loads the settings of the config.yaml file at the given path
+func (a *Agent) Load(path string) error {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", path)
+	}
+	cfg, err := Load(data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load %s", path)
+	}
+	a.Set(cfg)
+	return nil
+}
+
+// Start loads the settings of the config.yaml file at the given path and then reloads them every
+// minute. If the first load fails, then start returns the error.
+func (a *Agent) Start(path string) error {
+	if err := a.Load(path); err != nil {
+		return err
+	}
+	ticker := time.Tick(1 * time.Minute)
+	go func() {
+		for range ticker {
+			if err := a.Load(path); err != nil {
+				logrus.WithField("path", path).WithError(err).Error("Error loading the lighthouse settings.")
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,338 @@
+package settings
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+tide:
+  queries:
+  - repos:
+    - org/repo
+    labels:
+    - approved
+    excludedTitles:
+    - ^\[WIP\]
+    excludedPaths:
+    - docs/**
+  - repos:
+    - org/other
+foghorn:
+  pendingTimeout: 30m
+  checkRuns:
+  - github
+webhooks:
+  eventDeadline: 5m
+  maxPayloadSize: 10Mi
+default_env:
+  ARTIFACT_BUCKET: gs://artifacts
+changedModules:
+  patterns:
+  - services/*
+  repos:
+    org/repo:
+    - libs/*
+    other: []
+launcher:
+  defaultAgent: tekton
+`
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load([]byte(testConfig))
+	require.NoError(t, err)
+	require.Len(t, cfg.Keeper.Queries, 2)
+	assert.Equal(t, KeeperQuery{ExcludedTitles: []string{`^\[WIP\]`}, ExcludedPaths: []string{"docs/**"}}, cfg.Keeper.Query(0))
+	assert.Equal(t, KeeperQuery{}, cfg.Keeper.Query(1))
+	assert.Equal(t, KeeperQuery{}, cfg.Keeper.Query(2))
+	require.NotNil(t, cfg.Foghorn.PendingTimeout)
+	assert.Equal(t, 30*time.Minute, cfg.Foghorn.PendingTimeout.Duration)
+	assert.True(t, cfg.Foghorn.ReportsCheckRuns("github"))
+	assert.False(t, cfg.Foghorn.ReportsCheckRuns("gitlab"))
+	assert.Equal(t, 5*time.Minute, cfg.Webhooks.GetEventDeadline())
+	assert.Equal(t, time.Duration(0), (&Webhooks{}).GetEventDeadline())
+	assert.Equal(t, int64(10*1024*1024), cfg.Webhooks.GetMaxPayloadSize())
+	assert.Equal(t, int64(DefaultMaxPayloadSize), (&Webhooks{}).GetMaxPayloadSize())
+	assert.Equal(t, map[string]string{"ARTIFACT_BUCKET": "gs://artifacts"}, cfg.DefaultEnv)
+	assert.Equal(t, []string{"libs/*"}, cfg.ChangedModules.PatternsFor("org", "repo"))
+	assert.Equal(t, []string{"services/*"}, cfg.ChangedModules.PatternsFor("org", "other"))
+	assert.Empty(t, cfg.ChangedModules.PatternsFor("other", "repo"))
+	assert.Equal(t, "tekton", cfg.Launcher.DefaultAgent)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", cfg.Version)
+
+	other, err := Load([]byte(testConfig + "\n"))
+	require.NoError(t, err)
+	assert.NotEqual(t, cfg.Version, other.Version, "the version identifies the configuration file")
+
+	_, err = Load([]byte("tide: ["))
+	assert.Error(t, err)
+}
+
+func TestAgent(t *testing.T) {
+	var agent *Agent
+	assert.Equal(t, &Config{}, agent.Config())
+
+	agent = &Agent{}
+	assert.Equal(t, &Config{}, agent.Config())
+
+	dir, err := ioutil.TempDir("", "settings")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testConfig), 0600))
+	require.NoError(t, agent.Load(path))
+	assert.Len(t, agent.Config().Keeper.Queries, 2)
+
+	assert.Error(t, agent.Load(filepath.Join(dir, "missing.yaml")))
+	assert.Len(t, agent.Config().Keeper.Queries, 2, "the settings are kept when they can't be loaded")
+}
+
+func TestLabelSync(t *testing.T) {
+	cfg, err := Load([]byte(`
+labels:
+  default:
+  - name: lgtm
+    color: 15dd18
+    description: Looks good to me
+  - name: kind/bug
+    color: "#e11d21"
+  repos:
+    org:
+    - name: kind/bug
+      color: d73a4a
+      description: Something is broken
+    org/repo:
+    - name: area/docs
+      color: 0052cc
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []Label{
+		{Name: "lgtm", Color: "15dd18", Description: "Looks good to me"},
+		{Name: "kind/bug", Color: "d73a4a", Description: "Something is broken"},
+		{Name: "area/docs", Color: "0052cc"},
+	}, cfg.Labels.For("org", "repo"))
+	assert.Equal(t, []Label{
+		{Name: "lgtm", Color: "15dd18", Description: "Looks good to me"},
+		{Name: "kind/bug", Color: "#e11d21"},
+	}, cfg.Labels.For("other", "repo"))
+
+	_, err = Load([]byte("labels:\n  default:\n  - name: lgtm\n    color: green\n"))
+	assert.Error(t, err)
+	_, err = Load([]byte("labels:\n  repos:\n    org:\n    - color: 15dd18\n"))
+	assert.Error(t, err)
+}
+
+func TestNotifications(t *testing.T) {
+	cfg, err := Load([]byte(`
+notifications:
+  slack:
+  - channel: "#ci"
+  - channel: "#myrepo"
+    repos: [org/repo]
+    events: [jobFailed, merged]
+  - channel: "#myorg"
+    repos: [org]
+    events: [stuckPool]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"#ci", "#myrepo"}, cfg.Notifications.SlackChannels("org", "repo", NotifyMerged))
+	assert.Equal(t, []string{"#ci", "#myorg"}, cfg.Notifications.SlackChannels("org", "other", NotifyStuckPool))
+	assert.Equal(t, []string{"#ci"}, cfg.Notifications.SlackChannels("other", "repo", NotifyJobFailed))
+
+	_, err = Load([]byte("notifications:\n  slack:\n  - repos: [org]\n"))
+	assert.Error(t, err)
+	_, err = Load([]byte("notifications:\n  slack:\n  - channel: \"#ci\"\n    events: [deployed]\n"))
+	assert.Error(t, err)
+}
+
+func TestEmailNotifications(t *testing.T) {
+	cfg, err := Load([]byte(`
+notifications:
+  email:
+    smtpServer: smtp.example.com:587
+    from: lighthouse@example.com
+    owners:
+      org: [team@example.com]
+      org/repo: [dev@example.com, team@example.com]
+`))
+	require.NoError(t, err)
+	email := cfg.Notifications.Email
+	assert.Equal(t, DefaultConsecutiveFailures, email.Threshold())
+	assert.Equal(t, []string{"dev@example.com", "team@example.com"}, email.Recipients("org", "repo"))
+	assert.Equal(t, []string{"team@example.com"}, email.Recipients("org", "other"))
+	assert.Empty(t, email.Recipients("other", "repo"))
+
+	_, err = Load([]byte("notifications:\n  email:\n    smtpServer: smtp.example.com:587\n"))
+	assert.Error(t, err)
+	_, err = Load([]byte("notifications:\n  email:\n    consecutiveFailures: -1\n"))
+	assert.Error(t, err)
+}
+
+func TestReportURL(t *testing.T) {
+	cfg, err := Load([]byte(`
+foghorn:
+  reportURL:
+    baseURL: https://dashboard.example.com
+    template: "{{ .BaseURL }}/{{ .Owner }}/{{ .Repository }}/{{ .Build }}"
+    repos:
+      org: "{{ .BaseURL }}/org/{{ .Repository }}/{{ .Build }}"
+      org/repo: "https://ci.example.com/{{ .Repository }}/{{ .Branch }}/{{ .Build }}"
+    jobs:
+      release: "https://release.example.com/{{ .Job }}/{{ .Build }}"
+`))
+	require.NoError(t, err)
+	reportURL := cfg.Foghorn.ReportURL
+	assert.Equal(t, "https://release.example.com/{{ .Job }}/{{ .Build }}", reportURL.TemplateFor("org", "repo", "release"))
+	assert.Equal(t, "https://ci.example.com/{{ .Repository }}/{{ .Branch }}/{{ .Build }}", reportURL.TemplateFor("org", "repo", "unit"))
+	assert.Equal(t, "{{ .BaseURL }}/org/{{ .Repository }}/{{ .Build }}", reportURL.TemplateFor("org", "other", "unit"))
+	assert.Equal(t, "{{ .BaseURL }}/{{ .Owner }}/{{ .Repository }}/{{ .Build }}", reportURL.TemplateFor("other", "repo", "unit"))
+	assert.Empty(t, (&ReportURL{}).TemplateFor("org", "repo", "unit"))
+
+	_, err = Load([]byte("foghorn:\n  reportURL:\n    template: \"{{ .BaseURL }\"\n"))
+	assert.Error(t, err)
+	_, err = Load([]byte("foghorn:\n  reportURL:\n    jobs:\n      unit: \"{{ .BaseURL }}/{{ .Repo }}\"\n"))
+	assert.Error(t, err, "Repo is not a parameter")
+}
+
+func TestStatusContexts(t *testing.T) {
+	cfg, err := Load([]byte(`
+statusContexts:
+  presubmit: "ci/pr/{{ .Context }}"
+  postsubmit: "ci/release/{{ .Context }}"
+  repos:
+    legacy: "{{ .Context }}"
+    org/repo: "{{ .Org }}/{{ .Repo }}/{{ .Context }}"
+  jobs:
+    lint: "lint"
+`))
+	require.NoError(t, err)
+	contexts := cfg.StatusContexts
+	assert.False(t, contexts.IsEmpty())
+
+	for _, tc := range []struct {
+		org, repo, jobType, job, context string
+		expected                         string
+	}{
+		{"org", "other", "presubmit", "unit", "pr-unit", "ci/pr/pr-unit"},
+		{"org", "other", "postsubmit", "release", "release", "ci/release/release"},
+		{"org", "repo", "presubmit", "unit", "pr-unit", "org/repo/pr-unit"},
+		{"org", "", "presubmit", "unit", "pr-unit", "ci/pr/pr-unit"},
+		{"legacy", "repo", "presubmit", "unit", "pr-unit", "pr-unit"},
+		{"org", "repo", "presubmit", "lint", "pr-lint", "lint"},
+		{"org", "repo", "periodic", "nightly", "nightly", "org/repo/nightly"},
+	} {
+		actual, err := contexts.Context(tc.org, tc.repo, tc.jobType, tc.job, tc.context)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, actual, "%s of %s/%s", tc.job, tc.org, tc.repo)
+	}
+
+	actual, err := (&StatusContexts{}).Context("org", "repo", "presubmit", "unit", "pr-unit")
+	require.NoError(t, err)
+	assert.Equal(t, "pr-unit", actual)
+	_, err = (&StatusContexts{Template: "{{ if false }}x{{ end }}"}).Context("org", "repo", "presubmit", "unit", "pr-unit")
+	assert.Error(t, err, "empty context")
+
+	_, err = Load([]byte("statusContexts:\n  presubmit: \"ci/{{ .Name }}\"\n"))
+	assert.Error(t, err, "Name is not a parameter")
+}
+
+func TestPresubmitFilters(t *testing.T) {
+	cfg, err := Load([]byte(`
+presubmitFilters:
+  skipIfOnlyChanged:
+    unit: "^docs/|\\.md$"
+`))
+	require.NoError(t, err)
+	filters := cfg.PresubmitFilters
+	assert.True(t, filters.SkipsChanges("unit", []string{"docs/index.html", "README.md"}))
+	assert.False(t, filters.SkipsChanges("unit", []string{"docs/index.html", "main.go"}))
+	assert.True(t, filters.SkipsChanges("unit", nil), "nothing changed")
+	assert.False(t, filters.SkipsChanges("lint", []string{"README.md"}), "no regex for the presubmit")
+
+	_, err = Load([]byte("presubmitFilters:\n  skipIfOnlyChanged:\n    unit: \"(\"\n"))
+	assert.Error(t, err)
+}
+
+func TestPostsubmitFilters(t *testing.T) {
+	cfg, err := Load([]byte(`
+postsubmitFilters:
+  skipBranches:
+  - ^feature/
+  repos:
+    org:
+      branches:
+      - ^main$
+    org/repo:
+      branches:
+      - ^main$
+      - ^release-
+      skipBranches:
+      - -rc$
+`))
+	require.NoError(t, err)
+	filters := cfg.PostsubmitFilters
+	assert.True(t, filters.ShouldRun("other", "repo", "main"))
+	assert.False(t, filters.ShouldRun("other", "repo", "feature/x"), "skipped by default")
+	assert.True(t, filters.ShouldRun("org", "app", "main"))
+	assert.False(t, filters.ShouldRun("org", "app", "release-1.0"), "not a branch of the org")
+	assert.True(t, filters.ShouldRun("org", "repo", "release-1.0"))
+	assert.False(t, filters.ShouldRun("org", "repo", "release-1.0-rc"), "skipBranches take precedence")
+	assert.True(t, (&PostsubmitFilters{}).ShouldRun("org", "repo", "anything"), "no filter")
+
+	_, err = Load([]byte("postsubmitFilters:\n  repos:\n    org/repo:\n      branches:\n      - \"(\"\n"))
+	assert.Error(t, err)
+}
+
+func TestGitServers(t *testing.T) {
+	cfg, err := Load([]byte(`
+gitServers:
+- name: ghe
+  url: https://GitHub.example.com
+  tokenEnv: GHE_TOKEN
+- name: gitlab
+  url: https://gitlab.example.com/
+  kind: gitlab
+  tokenEnv: GITLAB_TOKEN
+  user: gitlab-bot
+  hmacTokenEnv: GITLAB_HMAC_TOKEN
+`))
+	require.NoError(t, err)
+	servers := cfg.GitServers
+	require.NotNil(t, servers.Named("ghe"))
+	assert.Equal(t, "github", servers.Named("ghe").GetKind())
+	assert.Equal(t, "gitlab", servers.Named("gitlab").GetKind())
+	assert.Nil(t, servers.Named("github"))
+	assert.Equal(t, "ghe", servers.ForHost("github.example.com").Name)
+	assert.Equal(t, "gitlab", servers.ForURL("https://gitlab.example.com/org/repo.git").Name)
+	assert.Nil(t, servers.ForURL("https://github.com/org/repo"), "the default server")
+	assert.Nil(t, servers.ForHost(""))
+
+	for name, value := range map[string]string{"GHE_TOKEN": "ghe-token", "HMAC_TOKEN": "secret", "GITLAB_HMAC_TOKEN": "gitlab-secret"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, value)
+	}
+	os.Unsetenv("GITLAB_TOKEN")
+	token, err := servers.Named("ghe").Token()
+	require.NoError(t, err)
+	assert.Equal(t, "ghe-token", token)
+	_, err = servers.Named("gitlab").Token()
+	assert.Error(t, err)
+	assert.Equal(t, "secret", servers.Named("ghe").HMACToken(), "the HMAC token defaults to the one of the default server")
+	assert.Equal(t, "gitlab-secret", servers.Named("gitlab").HMACToken())
+
+	for _, invalid := range []string{
+		"gitServers:\n- url: https://github.example.com\n  tokenEnv: TOKEN\n",
+		"gitServers:\n- name: ghe\n  url: github.example.com\n  tokenEnv: TOKEN\n",
+		"gitServers:\n- name: ghe\n  url: https://github.example.com\n",
+		"gitServers:\n- name: ghe\n  url: https://github.example.com\n  tokenEnv: TOKEN\n- name: ghe\n  url: https://other.example.com\n  tokenEnv: TOKEN\n",
+		"gitServers:\n- name: ghe\n  url: https://github.example.com\n  tokenEnv: TOKEN\n- name: other\n  url: https://github.example.com/api\n  tokenEnv: TOKEN\n",
+	} {
+		_, err = Load([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
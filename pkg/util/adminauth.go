@@ -0,0 +1,34 @@
+package util
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// AdminTokenEnvVar is the environment variable containing the bearer token of the admin endpoints.
+// The admin endpoints are disabled when it is not set.
+const AdminTokenEnvVar = "LIGHTHOUSE_ADMIN_TOKEN" // #nosec
+
+// GetAdminToken returns the bearer token of the admin endpoints, or an empty string if they are disabled
+func GetAdminToken() string {
+	return strings.TrimSpace(os.Getenv(AdminTokenEnvVar))
+}
+
+// AdminHandler only serves the handler to requests authenticated with the admin token as a bearer
+// token. Requests are refused when no admin token is configured.
+func AdminHandler(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "the admin endpoints are disabled as no admin token is configured", http.StatusForbidden)
+			return
+		}
+		bearer := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if bearer == "" || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			http.Error(w, "the admin token is required as a bearer token", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,38 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		token  string
+		bearer string
+		status int
+	}{
+		{name: "authenticated", token: "admin", bearer: "admin", status: http.StatusNoContent},
+		{name: "no bearer", token: "admin", status: http.StatusUnauthorized},
+		{name: "invalid bearer", token: "admin", bearer: "other", status: http.StatusUnauthorized},
+		{name: "disabled", bearer: "admin", status: http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.bearer)
+			}
+			w := httptest.NewRecorder()
+			AdminHandler(tc.token, ok).ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+}
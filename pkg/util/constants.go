@@ -19,6 +19,13 @@ const (
 	// use for GitHub API calls when present.
 	GitHubAppAPIUserFilename = "username"
 
+	// GitHubAppIDFilename is the filename inside the GitHub App secrets dir containing the App ID. The installation
+	// tokens are created with the App private key when it is present along with the private key.
+	GitHubAppIDFilename = "app-id"
+
+	// GitHubAppPrivateKeyFilename is the filename inside the GitHub App secrets dir containing the App private key
+	GitHubAppPrivateKeyFilename = "private-key.pem"
+
 	// LighthousePipelineActivityNameLabel is added to the LighthouseJob with
 	// the name of the PipelineActivity corresponding to it.
 	LighthousePipelineActivityNameLabel = "lighthouse.jenkins-x.io/activityName"
@@ -47,6 +54,17 @@ const (
 	// BranchLabel is added in resources created by Lighthouse and contains the branch name for the job.
 	BranchLabel = "lighthouse.jenkins-x.io/branch"
 
+	// IdempotencyKeyLabel is added to LighthouseJobs created for webhook events and contains a key
+	// derived from the repository, SHA, job and event GUID, used to detect duplicate jobs.
+	IdempotencyKeyLabel = "lighthouse.jenkins-x.io/idempotencyKey"
+
+	// CompletedLabel is added to LighthouseJobs by foghorn once they reached a final state, so that
+	// controllers can watch only the jobs which are not completed yet with the ActiveJobsSelector.
+	CompletedLabel = "lighthouse.jenkins-x.io/completed"
+
+	// ActiveJobsSelector is the label selector of the LighthouseJobs which are not completed yet.
+	ActiveJobsSelector = "!" + CompletedLabel
+
 	// BuildNumLabel is added in resources created by Lighthouse and contains the build number for the job.
 	BuildNumLabel = "lighthouse.jenkins-x.io/buildNum"
 
@@ -93,4 +111,10 @@ const (
 
 	// LighthousePayloadTypeActivity is the activity type
 	LighthousePayloadTypeActivity = "activity"
+
+	// LighthousePayloadTypeHandshake is the type of the handshake negotiating the API version with external plugins
+	LighthousePayloadTypeHandshake = "handshake"
+
+	// LighthousePayloadVersionHeader is the header key containing the API version of the payload relayed to external plugins
+	LighthousePayloadVersionHeader = "X-Lighthouse-Payload-Version"
 )
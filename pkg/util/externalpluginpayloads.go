@@ -0,0 +1,243 @@
+package util
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The payloads of each API version are types of their own, converted from and to the internal types of
+// Lighthouse, so that the payloads relayed to the external plugins only change with the API version.
+
+// ExternalPluginEnvelope is the payload relayed to the external plugins supporting the v2 API. Either
+// Webhook or Activity is set, depending on the PayloadType.
+type ExternalPluginEnvelope struct {
+	APIVersion  string      `json:"apiVersion"`
+	PayloadType string      `json:"payloadType"`
+	WebhookKind string      `json:"webhookKind,omitempty"`
+	Webhook     *WebhookV2  `json:"webhook,omitempty"`
+	Activity    *ActivityV2 `json:"activity,omitempty"`
+}
+
+// WebhookV2 is a webhook in the v2 API, with the repository and sender of every kind of webhook. Hook is
+// the go-scm webhook of the Kind.
+type WebhookV2 struct {
+	Kind       string          `json:"kind"`
+	Repository RepositoryV2    `json:"repository"`
+	Sender     string          `json:"sender,omitempty"`
+	Hook       json.RawMessage `json:"hook"`
+}
+
+// RepositoryV2 is the repository of a webhook in the v2 API
+type RepositoryV2 struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	FullName  string `json:"fullName"`
+	Branch    string `json:"branch,omitempty"`
+	CloneURL  string `json:"cloneURL,omitempty"`
+	Link      string `json:"link,omitempty"`
+}
+
+// ActivityV2 is the activity record of a pipeline in the v2 API
+type ActivityV2 struct {
+	Name           string            `json:"name"`
+	Owner          string            `json:"owner,omitempty"`
+	Repo           string            `json:"repo,omitempty"`
+	Branch         string            `json:"branch,omitempty"`
+	BuildID        string            `json:"buildId,omitempty"`
+	Context        string            `json:"context,omitempty"`
+	GitURL         string            `json:"gitURL,omitempty"`
+	LogURL         string            `json:"logURL,omitempty"`
+	LinkURL        string            `json:"linkURL,omitempty"`
+	Status         string            `json:"status,omitempty"`
+	BaseSHA        string            `json:"baseSHA,omitempty"`
+	LastCommitSHA  string            `json:"lastCommitSHA,omitempty"`
+	StartTime      *time.Time        `json:"startTime,omitempty"`
+	CompletionTime *time.Time        `json:"completionTime,omitempty"`
+	Stages         []ActivityStageV2 `json:"stages,omitempty"`
+	Steps          []ActivityStageV2 `json:"steps,omitempty"`
+}
+
+// ActivityStageV2 is a stage or step of an activity in the v2 API
+type ActivityStageV2 struct {
+	Name           string            `json:"name"`
+	Status         string            `json:"status"`
+	StartTime      *time.Time        `json:"startTime,omitempty"`
+	CompletionTime *time.Time        `json:"completionTime,omitempty"`
+	Stages         []ActivityStageV2 `json:"stages,omitempty"`
+	Steps          []ActivityStageV2 `json:"steps,omitempty"`
+}
+
+// webhookToV2 converts a go-scm webhook to the v2 API
+func webhookToV2(hook scm.Webhook) (*WebhookV2, error) {
+	data, err := json.Marshal(hook)
+	if err != nil {
+		return nil, err
+	}
+	repo := hook.Repository()
+	answer := &WebhookV2{
+		Kind: string(hook.Kind()),
+		Repository: RepositoryV2{
+			Namespace: repo.Namespace,
+			Name:      repo.Name,
+			FullName:  repo.FullName,
+			Branch:    repo.Branch,
+			CloneURL:  repo.Clone,
+			Link:      repo.Link,
+		},
+		Hook: data,
+	}
+	if answer.Repository.FullName == "" && repo.Name != "" {
+		answer.Repository.FullName = scm.Join(repo.Namespace, repo.Name)
+	}
+	if sender := webhookSender(hook); sender != nil {
+		answer.Sender = sender.Login
+	}
+	return answer, nil
+}
+
+// webhookSender returns the user who triggered the webhook, if its kind has one
+func webhookSender(hook scm.Webhook) *scm.User {
+	switch h := hook.(type) {
+	case *scm.PullRequestHook:
+		return &h.Sender
+	case *scm.PullRequestCommentHook:
+		return &h.Sender
+	case *scm.IssueHook:
+		return &h.Sender
+	case *scm.IssueCommentHook:
+		return &h.Sender
+	case *scm.PushHook:
+		return &h.Sender
+	case *scm.BranchHook:
+		return &h.Sender
+	case *scm.TagHook:
+		return &h.Sender
+	case *scm.ReviewHook:
+		return &h.Review.Author
+	}
+	return nil
+}
+
+// toWebhook converts a webhook of the v2 API to the go-scm webhook of its kind
+func (w *WebhookV2) toWebhook() (scm.Webhook, error) {
+	return parseWebhook(logrus.WithField("Kind", w.Kind), w.Kind, w.Hook)
+}
+
+// activityToV2 converts an activity record to the v2 API
+func activityToV2(a *record.ActivityRecord) *ActivityV2 {
+	return &ActivityV2{
+		Name:           a.Name,
+		Owner:          a.Owner,
+		Repo:           a.Repo,
+		Branch:         a.Branch,
+		BuildID:        a.BuildIdentifier,
+		Context:        a.Context,
+		GitURL:         a.GitURL,
+		LogURL:         a.LogURL,
+		LinkURL:        a.LinkURL,
+		Status:         string(a.Status),
+		BaseSHA:        a.BaseSHA,
+		LastCommitSHA:  a.LastCommitSHA,
+		StartTime:      fromMetaTime(a.StartTime),
+		CompletionTime: fromMetaTime(a.CompletionTime),
+		Stages:         stagesToV2(a.Stages),
+		Steps:          stagesToV2(a.Steps),
+	}
+}
+
+func stagesToV2(stages []*record.ActivityStageOrStep) []ActivityStageV2 {
+	var answer []ActivityStageV2
+	for _, s := range stages {
+		answer = append(answer, ActivityStageV2{
+			Name:           s.Name,
+			Status:         string(s.Status),
+			StartTime:      fromMetaTime(s.StartTime),
+			CompletionTime: fromMetaTime(s.CompletionTime),
+			Stages:         stagesToV2(s.Stages),
+			Steps:          stagesToV2(s.Steps),
+		})
+	}
+	return answer
+}
+
+// toActivityRecord converts an activity of the v2 API to an activity record
+func (a *ActivityV2) toActivityRecord() *record.ActivityRecord {
+	return &record.ActivityRecord{
+		Name:            a.Name,
+		Owner:           a.Owner,
+		Repo:            a.Repo,
+		Branch:          a.Branch,
+		BuildIdentifier: a.BuildID,
+		Context:         a.Context,
+		GitURL:          a.GitURL,
+		LogURL:          a.LogURL,
+		LinkURL:         a.LinkURL,
+		Status:          v1alpha1.PipelineState(a.Status),
+		BaseSHA:         a.BaseSHA,
+		LastCommitSHA:   a.LastCommitSHA,
+		StartTime:       toMetaTime(a.StartTime),
+		CompletionTime:  toMetaTime(a.CompletionTime),
+		Stages:          stagesFromV2(a.Stages),
+		Steps:           stagesFromV2(a.Steps),
+	}
+}
+
+func stagesFromV2(stages []ActivityStageV2) []*record.ActivityStageOrStep {
+	var answer []*record.ActivityStageOrStep
+	for _, s := range stages {
+		answer = append(answer, &record.ActivityStageOrStep{
+			Name:           s.Name,
+			Status:         v1alpha1.PipelineState(s.Status),
+			StartTime:      toMetaTime(s.StartTime),
+			CompletionTime: toMetaTime(s.CompletionTime),
+			Stages:         stagesFromV2(s.Stages),
+			Steps:          stagesFromV2(s.Steps),
+		})
+	}
+	return answer
+}
+
+func fromMetaTime(t *metav1.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	answer := t.Time
+	return &answer
+}
+
+func toMetaTime(t *time.Time) *metav1.Time {
+	if t == nil {
+		return nil
+	}
+	answer := metav1.NewTime(*t)
+	return &answer
+}
+
+// envelopeToEvent converts the envelope of the v2 API to the webhook or activity record it contains
+func envelopeToEvent(envelope *ExternalPluginEnvelope) (scm.Webhook, *record.ActivityRecord, error) {
+	switch envelope.PayloadType {
+	case LighthousePayloadTypeWebhook:
+		if envelope.Webhook == nil {
+			return nil, nil, errors.New("no webhook in the webhook envelope")
+		}
+		hook, err := envelope.Webhook.toWebhook()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "parsing webhook")
+		}
+		return hook, nil, nil
+	case LighthousePayloadTypeActivity:
+		if envelope.Activity == nil {
+			return nil, nil, errors.New("no activity in the activity envelope")
+		}
+		return nil, envelope.Activity.toActivityRecord(), nil
+	default:
+		return nil, nil, errors.Errorf("unknown Lighthouse payload type %s", envelope.PayloadType)
+	}
+}
@@ -2,19 +2,14 @@ package util
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
-	goscmhmac "github.com/jenkins-x/go-scm/pkg/hmac"
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
 	"github.com/jenkins-x/lighthouse/pkg/record"
@@ -22,11 +17,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// ParseExternalPluginEvent parses a webhook relayed to an external plugin
+// ParseExternalPluginEvent parses a webhook relayed to an external plugin, in any API version
 func ParseExternalPluginEvent(req *http.Request, secretToken string) (scm.Webhook, *record.ActivityRecord, error) {
-	data, err := ioutil.ReadAll(
-		io.LimitReader(req.Body, 10000000),
-	)
+	data, err := readExternalPluginPayload(req, secretToken)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -37,23 +30,22 @@ func ParseExternalPluginEvent(req *http.Request, secretToken string) (scm.Webhoo
 		"Body":    string(data),
 	})
 
-	ua := req.Header.Get("User-Agent")
-	if ua != LighthouseUserAgent {
-		return nil, nil, fmt.Errorf("unknown User-Agent %s, expected %s", ua, LighthouseUserAgent)
-	}
-
-	sig := req.Header.Get(LighthouseSignatureHeader)
-	if sig == "" {
-		return nil, nil, scm.ErrSignatureInvalid
-	}
-	if !goscmhmac.ValidatePrefix(data, []byte(secretToken), sig) {
-		return nil, nil, scm.ErrSignatureInvalid
+	switch version := req.Header.Get(LighthousePayloadVersionHeader); version {
+	case "", ExternalPluginAPIVersionV1:
+	case ExternalPluginAPIVersionV2:
+		envelope := ExternalPluginEnvelope{}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return nil, nil, errors.Wrap(err, "parsing envelope")
+		}
+		return envelopeToEvent(&envelope)
+	default:
+		return nil, nil, fmt.Errorf("unsupported Lighthouse payload version %s, the supported versions are %v", version, ExternalPluginAPIVersions)
 	}
 
-	payloadType := req.Header.Get(LighthousePayloadTypeHeader)
-	switch payloadType {
+	kind := req.Header.Get(LighthouseWebhookKindHeader)
+	switch payloadType := req.Header.Get(LighthousePayloadTypeHeader); payloadType {
 	case LighthousePayloadTypeWebhook:
-		hook, err := parseWebhook(log, req, data)
+		hook, err := parseWebhook(log, kind, data)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "parsing webhook")
 		}
@@ -76,8 +68,7 @@ func parseActivity(l *logrus.Entry, req *http.Request, data []byte) (*record.Act
 	return ar, err
 }
 
-func parseWebhook(l *logrus.Entry, req *http.Request, data []byte) (scm.Webhook, error) {
-	kind := req.Header.Get(LighthouseWebhookKindHeader)
+func parseWebhook(l *logrus.Entry, kind string, data []byte) (scm.Webhook, error) {
 	if kind == "" {
 		return nil, scm.MissingHeader{Header: LighthouseWebhookKindHeader}
 	}
@@ -164,54 +155,77 @@ func parseWebhook(l *logrus.Entry, req *http.Request, data []byte) (scm.Webhook,
 	return hook, nil
 }
 
-// callExternalPlugins dispatches the provided payload to the external plugins.
-func callExternalPlugins(l *logrus.Entry, externalPlugins []plugins.ExternalPlugin, payload []byte, headers http.Header, hmacToken string, wg *sync.WaitGroup) {
-	headers.Set("User-Agent", LighthouseUserAgent)
-	mac := hmac.New(sha256.New, []byte(hmacToken))
-	_, err := mac.Write(payload)
-	if err != nil {
-		l.WithError(err).Error("Unable to generate signature for relayed payload")
-		return
-	}
-	sum := mac.Sum(nil)
-	signature := "sha256=" + hex.EncodeToString(sum)
-	headers.Set(LighthouseSignatureHeader, signature)
+// callExternalPlugins dispatches the provided payload to the external plugins, in the API version negotiated
+// with each of them. The events the plugins did not declare in their handshake are not dispatched to them.
+// The plugins using the gRPC protocol get the v2 API and their responses are applied with the responder.
+func callExternalPlugins(l *logrus.Entry, externalPlugins []plugins.ExternalPlugin, payload *externalPluginPayload, hmacToken string, responder ExternalPluginResponder, wg *sync.WaitGroup) {
 	for _, p := range externalPlugins {
 		wg.Add(1)
 		go func(p plugins.ExternalPlugin) {
 			defer wg.Done()
-			if err := dispatch(p.Endpoint, payload, headers); err != nil {
-				l.WithError(err).WithField("external-plugin", p.Name).Error("Error dispatching event to external plugin.")
+			l := l.WithField("external-plugin", p.Name)
+			if isGRPCEndpoint(p.Endpoint) {
+				callGRPCExternalPlugin(l, p.Endpoint, payload, hmacToken, responder)
+				return
+			}
+			negotiated := defaultExternalPluginNegotiator.negotiate(p.Endpoint, hmacToken)
+			if negotiated.err != nil {
+				l.WithError(negotiated.err).Error("Not dispatching event to external plugin.")
+				return
+			}
+			if !negotiated.wants(payload.eventKind()) {
+				l.Debugf("External plugin does not want %s events", payload.eventKind())
+				return
+			}
+			body, headers, err := payload.encode(negotiated.version)
+			if err != nil {
+				l.WithError(err).Errorf("Unable to encode %s payload for external plugin.", payload.eventKind())
+				return
+			}
+			if err := signPayload(body, headers, hmacToken); err != nil {
+				l.WithError(err).Error("Unable to generate signature for relayed payload")
+				return
+			}
+			if err := dispatch(p.Endpoint, body, headers); err != nil {
+				l.WithError(err).Error("Error dispatching event to external plugin.")
 			} else {
-				l.WithField("external-plugin", p.Name).Info("Dispatched event to external plugin")
+				l.WithField("api-version", negotiated.version).Info("Dispatched event to external plugin")
 			}
 		}(p)
 	}
 }
 
-// CallExternalPluginsWithActivityRecord dispatches the provided activity record to the external plugins.
-func CallExternalPluginsWithActivityRecord(l *logrus.Entry, externalPlugins []plugins.ExternalPlugin, activity *record.ActivityRecord, hmacToken string, wg *sync.WaitGroup) {
-	headers := http.Header{}
-	headers.Set(LighthousePayloadTypeHeader, LighthousePayloadTypeActivity)
-	payload, err := json.Marshal(activity)
+// callGRPCExternalPlugin dispatches the payload in the v2 API to the external plugin using the gRPC protocol
+// and applies its response with the responder
+func callGRPCExternalPlugin(l *logrus.Entry, endpoint string, payload *externalPluginPayload, hmacToken string, responder ExternalPluginResponder) {
+	l = l.WithField("api-version", ExternalPluginAPIVersionV2)
+	body, headers, err := payload.encode(ExternalPluginAPIVersionV2)
 	if err != nil {
-		l.WithError(err).Errorf("Unable to marshal activity for relaying to external plugins. Activity is: %v", activity)
+		l.WithError(err).Errorf("Unable to encode %s payload for external plugin.", payload.eventKind())
 		return
 	}
-	callExternalPlugins(l, externalPlugins, payload, headers, hmacToken, wg)
-}
-
-// CallExternalPluginsWithWebhook dispatches the provided webhook to the external plugins.
-func CallExternalPluginsWithWebhook(l *logrus.Entry, externalPlugins []plugins.ExternalPlugin, webhook scm.Webhook, hmacToken string, wg *sync.WaitGroup) {
-	headers := http.Header{}
-	headers.Set(LighthouseWebhookKindHeader, string(webhook.Kind()))
-	headers.Set(LighthousePayloadTypeHeader, LighthousePayloadTypeWebhook)
-	payload, err := json.Marshal(webhook)
+	if err := signPayload(body, headers, hmacToken); err != nil {
+		l.WithError(err).Error("Unable to generate signature for relayed payload")
+		return
+	}
+	resp, err := dispatchGRPC(endpoint, body, headers.Get(LighthouseSignatureHeader))
 	if err != nil {
-		l.WithError(err).Errorf("Unable to marshal webhook for relaying to external plugins. Webhook is: %v", webhook)
+		l.WithError(err).Error("Error dispatching event to external plugin.")
 		return
 	}
-	callExternalPlugins(l, externalPlugins, payload, headers, hmacToken, wg)
+	l.Info("Dispatched event to external plugin")
+	applyExternalPluginResponse(l, responder, payload, resp)
+}
+
+// CallExternalPluginsWithActivityRecord dispatches the provided activity record to the external plugins.
+func CallExternalPluginsWithActivityRecord(l *logrus.Entry, externalPlugins []plugins.ExternalPlugin, activity *record.ActivityRecord, hmacToken string, wg *sync.WaitGroup) {
+	callExternalPlugins(l, externalPlugins, &externalPluginPayload{activity: activity}, hmacToken, nil, wg)
+}
+
+// CallExternalPluginsWithWebhook dispatches the provided webhook to the external plugins. The statuses and
+// actions returned by the plugins using the gRPC protocol are applied with the responder, if any.
+func CallExternalPluginsWithWebhook(l *logrus.Entry, externalPlugins []plugins.ExternalPlugin, webhook scm.Webhook, hmacToken string, responder ExternalPluginResponder, wg *sync.WaitGroup) {
+	callExternalPlugins(l, externalPlugins, &externalPluginPayload{webhook: webhook}, hmacToken, responder, wg)
 }
 
 // dispatch creates a new request using the provided payload and headers
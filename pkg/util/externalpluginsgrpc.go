@@ -0,0 +1,268 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	goscmhmac "github.com/jenkins-x/go-scm/pkg/hmac"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// ExternalPluginGRPCScheme is the scheme of the endpoints of the external plugins using the gRPC protocol,
+	// e.g. grpc://my-plugin:9090. The events are relayed to them in the v2 API and they answer with an
+	// ExternalPluginResponse.
+	ExternalPluginGRPCScheme = "grpc"
+	// ExternalPluginGRPCTLSScheme is the scheme of the endpoints of the external plugins using the gRPC
+	// protocol over TLS, e.g. grpcs://my-plugin.example.com:443
+	ExternalPluginGRPCTLSScheme = "grpcs"
+
+	// ExternalPluginActionComment comments on the pull request or issue
+	ExternalPluginActionComment = "comment"
+	// ExternalPluginActionAddLabel adds a label to the pull request or issue
+	ExternalPluginActionAddLabel = "add-label"
+	// ExternalPluginActionRemoveLabel removes a label from the pull request or issue
+	ExternalPluginActionRemoveLabel = "remove-label"
+
+	// externalPluginGRPCMethod is the method of the gRPC service of the external plugins handling the events
+	externalPluginGRPCMethod = "/lighthouse.ExternalPlugin/Handle"
+	// externalPluginGRPCCodec is the content subtype of the gRPC messages, which are encoded in JSON
+	externalPluginGRPCCodec = "json"
+	// externalPluginGRPCTimeout is the timeout of the gRPC calls to the external plugins
+	externalPluginGRPCTimeout = 30 * time.Second
+)
+
+// ExternalPluginRequest is the request of the gRPC protocol of the external plugins: an ExternalPluginEnvelope
+// of the v2 API, signed like the payloads of the HTTP protocol
+type ExternalPluginRequest struct {
+	Signature string          `json:"signature"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// ExternalPluginResponse is returned by the external plugins using the gRPC protocol. The statuses and
+// actions apply to the repository of the webhook the plugin handled.
+type ExternalPluginResponse struct {
+	// Error is the error the plugin failed to handle the event with, which is logged
+	Error string `json:"error,omitempty"`
+	// Statuses are the commit statuses to report
+	Statuses []ExternalPluginStatus `json:"statuses,omitempty"`
+	// Actions are the actions requested on the pull requests and issues
+	Actions []ExternalPluginAction `json:"actions,omitempty"`
+}
+
+// ExternalPluginStatus is a commit status requested by an external plugin
+type ExternalPluginStatus struct {
+	SHA         string `json:"sha"`
+	Context     string `json:"context"`
+	State       string `json:"state"`
+	Description string `json:"description,omitempty"`
+	TargetURL   string `json:"targetURL,omitempty"`
+}
+
+// ExternalPluginAction is an action requested by an external plugin on a pull request or issue: a comment
+// with the Body, or the addition or removal of the Label
+type ExternalPluginAction struct {
+	Type        string `json:"type"`
+	Number      int    `json:"number"`
+	PullRequest bool   `json:"pullRequest,omitempty"`
+	Body        string `json:"body,omitempty"`
+	Label       string `json:"label,omitempty"`
+}
+
+// ExternalPluginResponder applies the statuses and actions returned by the external plugins, it is
+// implemented by the scmprovider client
+type ExternalPluginResponder interface {
+	CreateStatus(owner, repo, ref string, s *scm.StatusInput) (*scm.Status, error)
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+	AddLabel(owner, repo string, number int, label string, pr bool) error
+	RemoveLabel(owner, repo string, number int, label string, pr bool) error
+}
+
+// ExternalPluginGRPCServer is implemented by the external plugins using the gRPC protocol, which parse the
+// requests with ParseExternalPluginRequest
+type ExternalPluginGRPCServer interface {
+	Handle(ctx context.Context, req *ExternalPluginRequest) (*ExternalPluginResponse, error)
+}
+
+// RegisterExternalPluginGRPCServer registers the handler of an external plugin on the gRPC server
+func RegisterExternalPluginGRPCServer(s *grpc.Server, srv ExternalPluginGRPCServer) {
+	s.RegisterService(&externalPluginServiceDesc, srv)
+}
+
+// ParseExternalPluginRequest parses the event of a gRPC request relayed by Lighthouse, checking its signature
+func ParseExternalPluginRequest(req *ExternalPluginRequest, secretToken string) (scm.Webhook, *record.ActivityRecord, error) {
+	if req.Signature == "" || !goscmhmac.ValidatePrefix(req.Payload, []byte(secretToken), req.Signature) {
+		return nil, nil, scm.ErrSignatureInvalid
+	}
+	envelope := ExternalPluginEnvelope{}
+	if err := json.Unmarshal(req.Payload, &envelope); err != nil {
+		return nil, nil, errors.Wrap(err, "parsing envelope")
+	}
+	return envelopeToEvent(&envelope)
+}
+
+var externalPluginServiceDesc = grpc.ServiceDesc{
+	ServiceName: "lighthouse.ExternalPlugin",
+	HandlerType: (*ExternalPluginGRPCServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Handle",
+			Handler:    handleExternalPluginGRPC,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "lighthouse/externalplugin",
+}
+
+func handleExternalPluginGRPC(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(ExternalPluginRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExternalPluginGRPCServer).Handle(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: externalPluginGRPCMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExternalPluginGRPCServer).Handle(ctx, req.(*ExternalPluginRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+// jsonCodec encodes the gRPC messages of the external plugins in JSON, so that they share the payload types
+// of the HTTP protocol rather than needing generated protobuf types
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return externalPluginGRPCCodec
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// isGRPCEndpoint returns true if the endpoint of the external plugin uses the gRPC protocol
+func isGRPCEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, ExternalPluginGRPCScheme+"://") || strings.HasPrefix(endpoint, ExternalPluginGRPCTLSScheme+"://")
+}
+
+// grpcConnections are the connections to the external plugins using the gRPC protocol, keyed by endpoint
+var grpcConnections = struct {
+	sync.Mutex
+	conns map[string]*grpc.ClientConn
+}{conns: map[string]*grpc.ClientConn{}}
+
+// grpcConnection returns the connection to the endpoint of an external plugin, which is reused by the calls
+func grpcConnection(endpoint string) (*grpc.ClientConn, error) {
+	grpcConnections.Lock()
+	defer grpcConnections.Unlock()
+	if conn, ok := grpcConnections.conns[endpoint]; ok {
+		return conn, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid endpoint %s", endpoint)
+	}
+	creds := grpc.WithInsecure()
+	if u.Scheme == ExternalPluginGRPCTLSScheme {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}))
+	}
+	conn, err := grpc.Dial(u.Host, creds, grpc.WithDefaultCallOptions(grpc.CallContentSubtype(externalPluginGRPCCodec)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to %s", endpoint)
+	}
+	grpcConnections.conns[endpoint] = conn
+	return conn, nil
+}
+
+// dispatchGRPC relays the signed payload of the v2 API to the external plugin at the gRPC endpoint and
+// returns its response
+func dispatchGRPC(endpoint string, payload []byte, signature string) (*ExternalPluginResponse, error) {
+	conn, err := grpcConnection(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), externalPluginGRPCTimeout)
+	defer cancel()
+	req := &ExternalPluginRequest{Signature: signature, Payload: payload}
+	resp := &ExternalPluginResponse{}
+	if err := conn.Invoke(ctx, externalPluginGRPCMethod, req, resp, grpc.WaitForReady(true)); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// applyExternalPluginResponse logs the error of the response of an external plugin and applies its statuses
+// and actions to the repository of the webhook with the responder. They are ignored for the other events.
+func applyExternalPluginResponse(l *logrus.Entry, responder ExternalPluginResponder, payload *externalPluginPayload, resp *ExternalPluginResponse) {
+	if resp.Error != "" {
+		l.WithField("plugin-error", resp.Error).Error("External plugin failed to handle the event.")
+	}
+	if len(resp.Statuses) == 0 && len(resp.Actions) == 0 {
+		return
+	}
+	if responder == nil || payload.webhook == nil {
+		l.Warnf("Ignoring the %d statuses and %d actions of the external plugin for a %s event.", len(resp.Statuses), len(resp.Actions), payload.eventKind())
+		return
+	}
+	repo := payload.webhook.Repository()
+	for _, s := range resp.Statuses {
+		state, ok := externalPluginStates[strings.ToLower(s.State)]
+		if !ok || s.SHA == "" || s.Context == "" {
+			l.Errorf("Ignoring invalid status %q of state %q for %q of the external plugin.", s.Context, s.State, s.SHA)
+			continue
+		}
+		_, err := responder.CreateStatus(repo.Namespace, repo.Name, s.SHA, &scm.StatusInput{
+			State:  state,
+			Label:  s.Context,
+			Desc:   s.Description,
+			Target: s.TargetURL,
+		})
+		if err != nil {
+			l.WithError(err).Errorf("Failed to report status %s of the external plugin.", s.Context)
+		}
+	}
+	for _, a := range resp.Actions {
+		var err error
+		switch a.Type {
+		case ExternalPluginActionComment:
+			err = responder.CreateComment(repo.Namespace, repo.Name, a.Number, a.PullRequest, a.Body)
+		case ExternalPluginActionAddLabel:
+			err = responder.AddLabel(repo.Namespace, repo.Name, a.Number, a.Label, a.PullRequest)
+		case ExternalPluginActionRemoveLabel:
+			err = responder.RemoveLabel(repo.Namespace, repo.Name, a.Number, a.Label, a.PullRequest)
+		default:
+			err = errors.Errorf("unknown action %q", a.Type)
+		}
+		if err != nil {
+			l.WithError(err).WithField("number", a.Number).Errorf("Failed to apply the %s action of the external plugin.", a.Type)
+		}
+	}
+}
+
+// externalPluginStates are the states of the statuses the external plugins can report
+var externalPluginStates = map[string]scm.State{
+	"pending": scm.StatePending,
+	"success": scm.StateSuccess,
+	"failure": scm.StateFailure,
+	"error":   scm.StateError,
+}
@@ -0,0 +1,132 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcExternalPlugin records the events relayed to it with the gRPC protocol and answers with the response
+type grpcExternalPlugin struct {
+	lock       sync.Mutex
+	hooks      []scm.Webhook
+	activities []*record.ActivityRecord
+	response   *ExternalPluginResponse
+}
+
+func (p *grpcExternalPlugin) Handle(ctx context.Context, req *ExternalPluginRequest) (*ExternalPluginResponse, error) {
+	hook, activity, err := ParseExternalPluginRequest(req, "secret")
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if hook != nil {
+		p.hooks = append(p.hooks, hook)
+	}
+	if activity != nil {
+		p.activities = append(p.activities, activity)
+	}
+	return p.response, nil
+}
+
+// recordingResponder records the statuses and actions of the external plugins
+type recordingResponder struct {
+	lock     sync.Mutex
+	statuses []string
+	actions  []string
+}
+
+func (r *recordingResponder) record(list *[]string, format string, args ...interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	*list = append(*list, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingResponder) CreateStatus(owner, repo, ref string, s *scm.StatusInput) (*scm.Status, error) {
+	r.record(&r.statuses, "%s/%s@%s %s=%s %s %s", owner, repo, ref, s.Label, s.State.String(), s.Desc, s.Target)
+	return &scm.Status{}, nil
+}
+
+func (r *recordingResponder) CreateComment(owner, repo string, number int, pr bool, comment string) error {
+	r.record(&r.actions, "%s/%s#%d pr=%t comment %s", owner, repo, number, pr, comment)
+	return nil
+}
+
+func (r *recordingResponder) AddLabel(owner, repo string, number int, label string, pr bool) error {
+	r.record(&r.actions, "%s/%s#%d pr=%t add %s", owner, repo, number, pr, label)
+	return nil
+}
+
+func (r *recordingResponder) RemoveLabel(owner, repo string, number int, label string, pr bool) error {
+	r.record(&r.actions, "%s/%s#%d pr=%t remove %s", owner, repo, number, pr, label)
+	return nil
+}
+
+func TestGRPCExternalPlugin(t *testing.T) {
+	plugin := &grpcExternalPlugin{
+		response: &ExternalPluginResponse{
+			Error: "the linter crashed on one file",
+			Statuses: []ExternalPluginStatus{
+				{SHA: "abc123", Context: "lint", State: "failure", Description: "3 issues", TargetURL: "https://lint/3"},
+				{SHA: "abc123", Context: "invalid", State: "unknown"},
+			},
+			Actions: []ExternalPluginAction{
+				{Type: ExternalPluginActionComment, Number: 3, PullRequest: true, Body: "please fix the lint issues"},
+				{Type: ExternalPluginActionAddLabel, Number: 3, PullRequest: true, Label: "lint-failed"},
+				{Type: ExternalPluginActionRemoveLabel, Number: 3, PullRequest: true, Label: "lint-passed"},
+				{Type: "merge", Number: 3},
+			},
+		},
+	}
+	server := grpc.NewServer()
+	RegisterExternalPluginGRPCServer(server, plugin)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener) // #nosec
+	defer server.Stop()
+	externalPlugins := []plugins.ExternalPlugin{{Name: "lint", Endpoint: "grpc://" + listener.Addr().String()}}
+
+	l := logrus.WithField("test", t.Name())
+	wg := &sync.WaitGroup{}
+	responder := &recordingResponder{}
+	pr := &scm.PullRequestHook{
+		Action:      scm.ActionOpen,
+		Repo:        scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo"},
+		PullRequest: scm.PullRequest{Number: 3, Sha: "abc123"},
+	}
+	CallExternalPluginsWithWebhook(l, externalPlugins, pr, "secret", responder, wg)
+	wg.Wait()
+
+	require.Len(t, plugin.hooks, 1)
+	assert.Equal(t, pr, plugin.hooks[0], "the webhooks are relayed in the v2 API")
+	assert.Equal(t, []string{"org/repo@abc123 lint=failure 3 issues https://lint/3"}, responder.statuses, "the invalid statuses are ignored")
+	assert.Equal(t, []string{
+		"org/repo#3 pr=true comment please fix the lint issues",
+		"org/repo#3 pr=true add lint-failed",
+		"org/repo#3 pr=true remove lint-passed",
+	}, responder.actions, "the unknown actions are ignored")
+
+	CallExternalPluginsWithActivityRecord(l, externalPlugins, &record.ActivityRecord{Name: "job-1"}, "secret", wg)
+	wg.Wait()
+	require.Len(t, plugin.activities, 1)
+	assert.Equal(t, "job-1", plugin.activities[0].Name)
+	assert.Len(t, responder.statuses, 1, "the statuses of the responses to activities are ignored")
+
+	CallExternalPluginsWithWebhook(l, externalPlugins, pr, "wrong", responder, wg)
+	wg.Wait()
+	assert.Len(t, plugin.hooks, 1, "the plugin checks the signature")
+	assert.Len(t, responder.actions, 3)
+}
@@ -0,0 +1,305 @@
+package util
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	goscmhmac "github.com/jenkins-x/go-scm/pkg/hmac"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// ExternalPluginAPIVersionV1 is the original API of external plugins: the payload is the JSON of the
+	// go-scm webhook or of the activity record, described by the headers
+	ExternalPluginAPIVersionV1 = "v1"
+	// ExternalPluginAPIVersionV2 is the API of external plugins where the payload is a self-describing
+	// ExternalPluginEnvelope of the v2 payload types
+	ExternalPluginAPIVersionV2 = "v2"
+
+	// externalPluginHandshakeTTL is how long the outcome of a handshake with an external plugin is cached
+	externalPluginHandshakeTTL = 10 * time.Minute
+	// externalPluginHandshakeRetry is how long a failed handshake is cached before it is retried, doubled
+	// after each consecutive failure up to externalPluginHandshakeTTL
+	externalPluginHandshakeRetry = 10 * time.Second
+	// externalPluginHandshakeTimeout is the timeout of the handshake requests
+	externalPluginHandshakeTimeout = 10 * time.Second
+)
+
+// ExternalPluginAPIVersions are the API versions of external plugins supported by Lighthouse, from the preferred one
+var ExternalPluginAPIVersions = []string{ExternalPluginAPIVersionV2, ExternalPluginAPIVersionV1}
+
+// ExternalPluginHandshake is sent by Lighthouse to external plugins, with the API versions it supports, before
+// relaying events to them
+type ExternalPluginHandshake struct {
+	Versions []string `json:"versions"`
+}
+
+// ExternalPluginCapabilities are returned by external plugins in response to the handshake, with the API
+// versions they support and the kinds of events they want to receive, e.g. pull_request or activity.
+// No events means every event enabled for the plugin in the plugins configuration.
+type ExternalPluginCapabilities struct {
+	Versions []string `json:"versions"`
+	Events   []string `json:"events,omitempty"`
+}
+
+// externalPluginPayload is an event to relay to external plugins, either a webhook or an activity record,
+// converted to the payload types of the negotiated API version of each
+type externalPluginPayload struct {
+	webhook  scm.Webhook
+	activity *record.ActivityRecord
+}
+
+// payloadType returns the type of the payload, either webhook or activity
+func (p *externalPluginPayload) payloadType() string {
+	if p.webhook != nil {
+		return LighthousePayloadTypeWebhook
+	}
+	return LighthousePayloadTypeActivity
+}
+
+// eventKind returns the kind of the event, as given in the events of the external plugins
+func (p *externalPluginPayload) eventKind() string {
+	if p.webhook != nil {
+		return string(p.webhook.Kind())
+	}
+	return LighthousePayloadTypeActivity
+}
+
+// encode returns the body and headers of the payload in the API version
+func (p *externalPluginPayload) encode(version string) ([]byte, http.Header, error) {
+	headers := http.Header{}
+	headers.Set(LighthousePayloadVersionHeader, version)
+	headers.Set(LighthousePayloadTypeHeader, p.payloadType())
+	if p.webhook != nil {
+		headers.Set(LighthouseWebhookKindHeader, string(p.webhook.Kind()))
+	}
+	switch version {
+	case ExternalPluginAPIVersionV1:
+		// the v1 payloads are the internal types
+		var body []byte
+		var err error
+		if p.webhook != nil {
+			body, err = json.Marshal(p.webhook)
+		} else {
+			body, err = json.Marshal(p.activity)
+		}
+		return body, headers, err
+	case ExternalPluginAPIVersionV2:
+		envelope := &ExternalPluginEnvelope{APIVersion: version, PayloadType: p.payloadType()}
+		if p.webhook != nil {
+			webhook, err := webhookToV2(p.webhook)
+			if err != nil {
+				return nil, nil, err
+			}
+			envelope.WebhookKind = webhook.Kind
+			envelope.Webhook = webhook
+		} else {
+			envelope.Activity = activityToV2(p.activity)
+		}
+		body, err := json.Marshal(envelope)
+		return body, headers, err
+	default:
+		return nil, nil, errors.Errorf("unsupported external plugin API version %s", version)
+	}
+}
+
+// signPayload sets the User-Agent and signature headers of a payload relayed to external plugins
+func signPayload(payload []byte, headers http.Header, hmacToken string) error {
+	headers.Set("User-Agent", LighthouseUserAgent)
+	mac := hmac.New(sha256.New, []byte(hmacToken))
+	if _, err := mac.Write(payload); err != nil {
+		return err
+	}
+	headers.Set(LighthouseSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// negotiatedPlugin is the outcome of the handshake with an external plugin
+type negotiatedPlugin struct {
+	version string
+	events  sets.String
+	err     error
+	expires time.Time
+	// failures is the number of consecutive handshakes which failed transiently
+	failures int
+}
+
+// wants returns true if the plugin declared the kind of event among the events it wants to receive
+func (n *negotiatedPlugin) wants(eventKind string) bool {
+	return n.events.Len() == 0 || n.events.Has(eventKind)
+}
+
+// externalPluginNegotiator negotiates the API version and events of the external plugins, caching the outcome
+// of the handshake with each endpoint
+type externalPluginNegotiator struct {
+	lock       sync.Mutex
+	now        func() time.Time
+	handshake  func(endpoint, hmacToken string) (*ExternalPluginCapabilities, error)
+	negotiated map[string]*negotiatedPlugin
+}
+
+var defaultExternalPluginNegotiator = &externalPluginNegotiator{
+	now:        time.Now,
+	handshake:  handshakeExternalPlugin,
+	negotiated: map[string]*negotiatedPlugin{},
+}
+
+// negotiate returns the API version and events of the external plugin. The external plugins which do not
+// support the handshake, e.g. because they predate it, use the v1 API and receive every event. The
+// handshakes failing transiently, e.g. because the plugin is unreachable, are retried with a backoff,
+// keeping the outcome of the previous handshake meanwhile.
+func (n *externalPluginNegotiator) negotiate(endpoint, hmacToken string) *negotiatedPlugin {
+	n.lock.Lock()
+	cached := n.negotiated[endpoint]
+	n.lock.Unlock()
+	now := n.now()
+	if cached != nil && now.Before(cached.expires) {
+		return cached
+	}
+
+	answer := &negotiatedPlugin{version: ExternalPluginAPIVersionV1, expires: now.Add(externalPluginHandshakeTTL)}
+	capabilities, err := n.handshake(endpoint, hmacToken)
+	if err != nil {
+		if _, ok := err.(*handshakeUnsupportedError); !ok {
+			answer = transientHandshakeFailure(cached, err, now)
+		}
+	} else if len(capabilities.Versions) > 0 {
+		answer.version = ""
+		answer.events = sets.NewString(capabilities.Events...)
+		supported := sets.NewString(capabilities.Versions...)
+		for _, version := range ExternalPluginAPIVersions {
+			if supported.Has(version) {
+				answer.version = version
+				break
+			}
+		}
+		if answer.version == "" {
+			answer.err = errors.Errorf("the external plugin supports the API versions %v but Lighthouse only supports %v", capabilities.Versions, ExternalPluginAPIVersions)
+		}
+	}
+	n.lock.Lock()
+	n.negotiated[endpoint] = answer
+	n.lock.Unlock()
+	return answer
+}
+
+// transientHandshakeFailure returns the outcome of a handshake which failed transiently, retried after a
+// backoff. The version and events of the previous handshake are kept, if any, so that the plugin still
+// receives the events it wants, otherwise no event is relayed to it until a handshake succeeds.
+func transientHandshakeFailure(previous *negotiatedPlugin, err error, now time.Time) *negotiatedPlugin {
+	answer := &negotiatedPlugin{err: errors.Wrap(err, "the handshake failed")}
+	if previous != nil {
+		answer.version, answer.events, answer.err, answer.failures = previous.version, previous.events, previous.err, previous.failures
+	}
+	backoff := externalPluginHandshakeRetry << uint(answer.failures)
+	if backoff <= 0 || backoff > externalPluginHandshakeTTL {
+		backoff = externalPluginHandshakeTTL
+	}
+	answer.failures++
+	answer.expires = now.Add(backoff)
+	return answer
+}
+
+// handshakeUnsupportedError is returned when an external plugin answers the handshake with 404 Not Found or
+// 400 Bad Request, or a body which is not its capabilities, which is the case of the plugins not supporting it
+type handshakeUnsupportedError struct {
+	status int
+}
+
+func (e *handshakeUnsupportedError) Error() string {
+	return fmt.Sprintf("the handshake was answered with status %d", e.status)
+}
+
+// handshakeExternalPlugin sends the handshake to the endpoint of an external plugin, returning its capabilities
+func handshakeExternalPlugin(endpoint, hmacToken string) (*ExternalPluginCapabilities, error) {
+	payload, err := json.Marshal(&ExternalPluginHandshake{Versions: ExternalPluginAPIVersions})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(LighthousePayloadTypeHeader, LighthousePayloadTypeHandshake)
+	req.Header.Set("Content-Type", "application/json")
+	if err := signPayload(payload, req.Header, hmacToken); err != nil {
+		return nil, err
+	}
+	c := &http.Client{Timeout: externalPluginHandshakeTimeout}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusBadRequest:
+		return nil, &handshakeUnsupportedError{status: resp.StatusCode}
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, errors.Errorf("the handshake was answered with status %d", resp.StatusCode)
+	}
+	capabilities := &ExternalPluginCapabilities{}
+	if err := json.NewDecoder(resp.Body).Decode(capabilities); err != nil {
+		return nil, &handshakeUnsupportedError{status: resp.StatusCode}
+	}
+	return capabilities, nil
+}
+
+// IsExternalPluginHandshake returns true if the request received by an external plugin is the handshake of Lighthouse
+func IsExternalPluginHandshake(req *http.Request) bool {
+	return req.Header.Get(LighthousePayloadTypeHeader) == LighthousePayloadTypeHandshake
+}
+
+// RespondToExternalPluginHandshake answers the handshake of Lighthouse with the capabilities of an external
+// plugin, after checking its signature. External plugins call it for the requests IsExternalPluginHandshake
+// returns true for, before ParseExternalPluginEvent.
+func RespondToExternalPluginHandshake(w http.ResponseWriter, req *http.Request, secretToken string, capabilities ExternalPluginCapabilities) error {
+	data, err := readExternalPluginPayload(req, secretToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return err
+	}
+	handshake := ExternalPluginHandshake{}
+	if err := json.Unmarshal(data, &handshake); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return errors.Wrap(err, "parsing handshake")
+	}
+	if !sets.NewString(handshake.Versions...).HasAny(capabilities.Versions...) {
+		// answered anyway so that Lighthouse logs the mismatch
+		logrus.WithFields(map[string]interface{}{
+			"lighthouse": handshake.Versions,
+			"plugin":     capabilities.Versions,
+		}).Warn("no API version supported by both Lighthouse and the external plugin")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(&capabilities)
+}
+
+// readExternalPluginPayload reads the payload of a request relayed by Lighthouse, checking its user agent and signature
+func readExternalPluginPayload(req *http.Request, secretToken string) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(req.Body, 10000000))
+	if err != nil {
+		return nil, err
+	}
+	ua := req.Header.Get("User-Agent")
+	if ua != LighthouseUserAgent {
+		return nil, errors.Errorf("unknown User-Agent %s, expected %s", ua, LighthouseUserAgent)
+	}
+	sig := req.Header.Get(LighthouseSignatureHeader)
+	if sig == "" || !goscmhmac.ValidatePrefix(data, []byte(secretToken), sig) {
+		return nil, scm.ErrSignatureInvalid
+	}
+	return data, nil
+}
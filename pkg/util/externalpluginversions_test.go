@@ -0,0 +1,210 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/record"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// externalPlugin records the events relayed to it by Lighthouse
+type externalPlugin struct {
+	lock       sync.Mutex
+	handshakes int
+	versions   []string
+	hooks      []scm.Webhook
+	activities []*record.ActivityRecord
+}
+
+func (p *externalPlugin) serve(t *testing.T, capabilities *ExternalPluginCapabilities) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if IsExternalPluginHandshake(r) {
+			p.handshakes++
+			if capabilities != nil {
+				assert.NoError(t, RespondToExternalPluginHandshake(w, r, "secret", *capabilities))
+				return
+			}
+		}
+		hook, activity, err := ParseExternalPluginEvent(r, "secret")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.versions = append(p.versions, r.Header.Get(LighthousePayloadVersionHeader))
+		if hook != nil {
+			p.hooks = append(p.hooks, hook)
+		}
+		if activity != nil {
+			p.activities = append(p.activities, activity)
+		}
+	}))
+}
+
+func TestExternalPluginVersions(t *testing.T) {
+	defer func(saved map[string]*negotiatedPlugin) {
+		defaultExternalPluginNegotiator.negotiated = saved
+	}(defaultExternalPluginNegotiator.negotiated)
+	defaultExternalPluginNegotiator.negotiated = map[string]*negotiatedPlugin{}
+
+	current, legacy, future := &externalPlugin{}, &externalPlugin{}, &externalPlugin{}
+	currentServer := current.serve(t, &ExternalPluginCapabilities{Versions: []string{"v1", "v2"}, Events: []string{"pull_request", "activity"}})
+	defer currentServer.Close()
+	legacyServer := legacy.serve(t, nil)
+	defer legacyServer.Close()
+	futureServer := future.serve(t, &ExternalPluginCapabilities{Versions: []string{"v3"}})
+	defer futureServer.Close()
+	externalPlugins := []plugins.ExternalPlugin{
+		{Name: "current", Endpoint: currentServer.URL},
+		{Name: "legacy", Endpoint: legacyServer.URL},
+		{Name: "future", Endpoint: futureServer.URL},
+	}
+
+	l := logrus.WithField("test", t.Name())
+	wg := &sync.WaitGroup{}
+	pr := &scm.PullRequestHook{Action: scm.ActionOpen, PullRequest: scm.PullRequest{Number: 3}}
+	CallExternalPluginsWithWebhook(l, externalPlugins, pr, "secret", nil, wg)
+	wg.Wait()
+	CallExternalPluginsWithWebhook(l, externalPlugins, &scm.IssueCommentHook{Comment: scm.Comment{Body: "/meow"}}, "secret", nil, wg)
+	CallExternalPluginsWithActivityRecord(l, externalPlugins, &record.ActivityRecord{Name: "job-1"}, "secret", wg)
+	wg.Wait()
+
+	assert.Equal(t, 1, current.handshakes, "the handshake is cached")
+	assert.Equal(t, []string{"v2", "v2"}, current.versions, "the preferred version supported by the plugin is used")
+	require.Len(t, current.hooks, 1, "the events the plugin did not declare are not relayed")
+	assert.Equal(t, pr, current.hooks[0])
+	require.Len(t, current.activities, 1)
+	assert.Equal(t, "job-1", current.activities[0].Name)
+
+	assert.Equal(t, 1, legacy.handshakes)
+	assert.Equal(t, []string{"v1", "v1", "v1"}, legacy.versions, "the plugins without handshake use v1 and receive every event")
+	assert.Len(t, legacy.hooks, 2)
+	assert.Len(t, legacy.activities, 1)
+
+	assert.Equal(t, 1, future.handshakes)
+	assert.Empty(t, future.versions, "no event is relayed without a common version")
+
+	defaultExternalPluginNegotiator.now = func() time.Time {
+		return time.Now().Add(externalPluginHandshakeTTL)
+	}
+	defer func() {
+		defaultExternalPluginNegotiator.now = time.Now
+	}()
+	CallExternalPluginsWithWebhook(l, externalPlugins[:1], pr, "secret", nil, wg)
+	wg.Wait()
+	assert.Equal(t, 2, current.handshakes, "the handshake is renewed when it expires")
+}
+
+func TestParseExternalPluginEventVersions(t *testing.T) {
+	started := metav1.NewTime(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	activity := &record.ActivityRecord{
+		Name:      "job-1",
+		Owner:     "org",
+		Status:    v1alpha1.RunningState,
+		StartTime: &started,
+		Stages:    []*record.ActivityStageOrStep{{Name: "build", Status: v1alpha1.SuccessState, StartTime: &started}},
+	}
+	ping := &scm.PingHook{GUID: "42", Repo: scm.Repository{Namespace: "org", Name: "repo"}}
+	for _, version := range []string{"v1", "v2"} {
+		for _, payload := range []*externalPluginPayload{{webhook: ping}, {activity: activity}} {
+			body, headers, err := payload.encode(version)
+			require.NoError(t, err)
+			require.NoError(t, signPayload(body, headers, "secret"))
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header = headers
+			hook, parsedActivity, err := ParseExternalPluginEvent(req, "secret")
+			require.NoError(t, err, version)
+			if payload.webhook != nil {
+				assert.Equal(t, ping, hook, version)
+			} else {
+				require.NotNil(t, parsedActivity, version)
+				assert.Equal(t, activity.Name, parsedActivity.Name, version)
+				assert.True(t, started.Equal(parsedActivity.StartTime), version)
+				require.Len(t, parsedActivity.Stages, 1, version)
+				assert.Equal(t, v1alpha1.SuccessState, parsedActivity.Stages[0].Status, version)
+			}
+		}
+	}
+
+	body, _, err := (&externalPluginPayload{webhook: ping}).encode("v2")
+	require.NoError(t, err)
+	envelope := ExternalPluginEnvelope{}
+	require.NoError(t, json.Unmarshal(body, &envelope))
+	require.NotNil(t, envelope.Webhook)
+	assert.Equal(t, "org/repo", envelope.Webhook.Repository.FullName, "the v2 webhooks describe their repository")
+
+	body = []byte(`{}`)
+	headers := http.Header{}
+	headers.Set(LighthousePayloadVersionHeader, "v3")
+	require.NoError(t, signPayload(body, headers, "secret"))
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header = headers
+	_, _, err = ParseExternalPluginEvent(req, "secret")
+	assert.Error(t, err, "unknown versions are rejected rather than misparsed")
+}
+
+func TestNegotiateHandshakeFailures(t *testing.T) {
+	now := time.Now()
+	var err error
+	handshakes := 0
+	n := &externalPluginNegotiator{
+		now: func() time.Time {
+			return now
+		},
+		handshake: func(endpoint, hmacToken string) (*ExternalPluginCapabilities, error) {
+			handshakes++
+			if err != nil {
+				return nil, err
+			}
+			return &ExternalPluginCapabilities{Versions: []string{"v2"}, Events: []string{"pull_request"}}, nil
+		},
+		negotiated: map[string]*negotiatedPlugin{},
+	}
+
+	err = errors.New("connection refused")
+	negotiated := n.negotiate("http://plugin", "secret")
+	assert.Error(t, negotiated.err, "no event is relayed until a handshake succeeds")
+	n.negotiate("http://plugin", "secret")
+	assert.Equal(t, 1, handshakes, "the failure is cached")
+	now = now.Add(externalPluginHandshakeRetry)
+	n.negotiate("http://plugin", "secret")
+	assert.Equal(t, 2, handshakes, "the handshake is retried after the backoff")
+	now = now.Add(externalPluginHandshakeRetry)
+	n.negotiate("http://plugin", "secret")
+	assert.Equal(t, 2, handshakes, "the backoff is doubled")
+
+	err = nil
+	now = now.Add(externalPluginHandshakeRetry)
+	negotiated = n.negotiate("http://plugin", "secret")
+	require.NoError(t, negotiated.err)
+	assert.Equal(t, "v2", negotiated.version)
+	assert.True(t, negotiated.wants("pull_request"))
+	assert.False(t, negotiated.wants("pull_requests"), "the event kinds are matched exactly")
+
+	err = errors.New("status 503")
+	now = now.Add(externalPluginHandshakeTTL)
+	negotiated = n.negotiate("http://plugin", "secret")
+	require.NoError(t, negotiated.err, "the previous handshake is kept on transient failures")
+	assert.Equal(t, "v2", negotiated.version)
+
+	err = &handshakeUnsupportedError{status: http.StatusNotFound}
+	now = now.Add(externalPluginHandshakeTTL)
+	negotiated = n.negotiate("http://plugin", "secret")
+	require.NoError(t, negotiated.err)
+	assert.Equal(t, "v1", negotiated.version, "the plugins not supporting the handshake use v1")
+	assert.True(t, negotiated.wants("issue_comment"))
+}
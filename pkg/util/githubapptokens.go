@@ -0,0 +1,269 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// gitHubAppTokenRefreshMargin is how long before they expire the cached installation tokens are refreshed
+const gitHubAppTokenRefreshMargin = 5 * time.Minute
+
+// GitHubAppTokenManager finds the GitHub App installation tokens of the owners. When the GitHub App secrets
+// dir contains the App ID and private key, the manager creates the installation tokens itself, caches them
+// per owner and refreshes them before they expire. Otherwise the tokens are read from the files of the dir,
+// like OwnerTokensDir does.
+type GitHubAppTokenManager struct {
+	apiURL     string
+	appID      int64
+	privateKey []byte
+	tokensDir  *OwnerTokensDir
+	client     *http.Client
+	now        func() time.Time
+
+	lock   sync.Mutex
+	owners map[string]*gitHubAppOwnerToken
+}
+
+// gitHubAppOwnerToken is the cached installation token of an owner, or of a repository of the owner
+type gitHubAppOwnerToken struct {
+	lock           sync.Mutex
+	installationID int64
+	token          string
+	expiresAt      time.Time
+}
+
+var gitHubAppTokenManagers = struct {
+	sync.Mutex
+	managers map[string]*GitHubAppTokenManager
+}{managers: map[string]*GitHubAppTokenManager{}}
+
+// GetGitHubAppTokenManager returns the token manager of the git server and GitHub App secrets dir, which is
+// shared by the process so that the installation tokens are cached across the requests
+func GetGitHubAppTokenManager(gitServer, dir string) *GitHubAppTokenManager {
+	key := gitServer + "|" + dir
+	gitHubAppTokenManagers.Lock()
+	defer gitHubAppTokenManagers.Unlock()
+	m, ok := gitHubAppTokenManagers.managers[key]
+	if !ok {
+		m = NewGitHubAppTokenManager(gitServer, dir)
+		gitHubAppTokenManagers.managers[key] = m
+	}
+	return m
+}
+
+// NewGitHubAppTokenManager creates a token manager for the git server and the GitHub App secrets dir
+func NewGitHubAppTokenManager(gitServer, dir string) *GitHubAppTokenManager {
+	m := &GitHubAppTokenManager{
+		apiURL:    gitHubAPIURL(gitServer),
+		tokensDir: NewOwnerTokensDir(gitServer, dir),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		now:       time.Now,
+		owners:    map[string]*gitHubAppOwnerToken{},
+	}
+	/* #nosec */
+	appID, idErr := ioutil.ReadFile(filepath.Join(dir, GitHubAppIDFilename))
+	/* #nosec */
+	privateKey, keyErr := ioutil.ReadFile(filepath.Join(dir, GitHubAppPrivateKeyFilename))
+	if idErr != nil || keyErr != nil {
+		// the tokens are created outside of Lighthouse
+		return m
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(string(appID)), 10, 64)
+	if err != nil {
+		logrus.WithError(err).Warnf("invalid GitHub App ID in %s, reading the installation tokens from the files of the dir", dir)
+		return m
+	}
+	m.appID = id
+	m.privateKey = privateKey
+	return m
+}
+
+// FindToken returns the installation token of the owner
+func (m *GitHubAppTokenManager) FindToken(owner string) (string, error) {
+	if m.appID == 0 {
+		return m.tokensDir.FindToken(owner)
+	}
+	return m.findToken(strings.ToLower(owner), owner, nil)
+}
+
+// FindRepositoryToken returns an installation token of the installation of the owner which only grants the
+// permissions on the repository of the owner, e.g. {"contents": "read"}. It fails if the App private key is
+// not available, as the tokens of the files of the dir grant access to all the repositories of the owner.
+func (m *GitHubAppTokenManager) FindRepositoryToken(owner, repo string, permissions map[string]string) (string, error) {
+	if m.appID == 0 {
+		return "", errors.Errorf("cannot create a token scoped to repository %s/%s without the GitHub App private key", owner, repo)
+	}
+	request := struct {
+		Repositories []string          `json:"repositories"`
+		Permissions  map[string]string `json:"permissions,omitempty"`
+	}{Repositories: []string{repo}, Permissions: permissions}
+	body, err := json.Marshal(&request)
+	if err != nil {
+		return "", err
+	}
+	// the permissions are marshalled with sorted keys, so the key of the cache only depends on their values
+	key := strings.ToLower(owner + "/" + repo + " " + string(body))
+	return m.findToken(key, owner, body)
+}
+
+// findToken returns the cached installation token of the key, creating the token with the body of the
+// access_tokens request if it is missing or about to expire
+func (m *GitHubAppTokenManager) findToken(key, owner string, body []byte) (string, error) {
+	m.lock.Lock()
+	cached, ok := m.owners[key]
+	if !ok {
+		cached = &gitHubAppOwnerToken{}
+		m.owners[key] = cached
+	}
+	m.lock.Unlock()
+
+	cached.lock.Lock()
+	defer cached.lock.Unlock()
+	now := m.now()
+	if cached.token != "" && now.Add(gitHubAppTokenRefreshMargin).Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+	token, expiresAt, err := m.createToken(owner, body, cached)
+	if err != nil {
+		if cached.token != "" && now.Before(cached.expiresAt) {
+			logrus.WithError(err).WithField("owner", owner).Warn("failed to refresh the GitHub App installation token, using the previous token until it expires")
+			return cached.token, nil
+		}
+		return "", errors.Wrapf(err, "failed to create the GitHub App installation token of %s", owner)
+	}
+	cached.token = token
+	cached.expiresAt = expiresAt
+	return token, nil
+}
+
+// createToken creates an installation token for the installation of the App of the owner, which is looked
+// up the first time. The body restricts the repositories and permissions of the token, if any.
+func (m *GitHubAppTokenManager) createToken(owner string, body []byte, cached *gitHubAppOwnerToken) (string, time.Time, error) {
+	jwt, err := GitHubAppJWT(m.appID, m.privateKey, m.now())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if cached.installationID == 0 {
+		installation := struct {
+			ID int64 `json:"id"`
+		}{}
+		err := m.do(http.MethodGet, fmt.Sprintf("/orgs/%s/installation", url.PathEscape(owner)), jwt, nil, &installation)
+		if errors.Cause(err) == errGitHubNotFound {
+			err = m.do(http.MethodGet, fmt.Sprintf("/users/%s/installation", url.PathEscape(owner)), jwt, nil, &installation)
+		}
+		if err != nil {
+			return "", time.Time{}, errors.Wrapf(err, "failed to find the GitHub App installation of %s", owner)
+		}
+		cached.installationID = installation.ID
+	}
+	accessToken := struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{}
+	err = m.do(http.MethodPost, fmt.Sprintf("/app/installations/%d/access_tokens", cached.installationID), jwt, body, &accessToken)
+	if errors.Cause(err) == errGitHubNotFound {
+		// the App was reinstalled, look the installation up again next time
+		cached.installationID = 0
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return accessToken.Token, accessToken.ExpiresAt, nil
+}
+
+var errGitHubNotFound = errors.New("not found")
+
+// do calls the GitHub API as the App, with the JSON body if it is not nil
+func (m *GitHubAppTokenManager) do(method, path, jwt string, body []byte, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, m.apiURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errors.Wrapf(errGitHubNotFound, "%s %s", method, path)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return errors.Errorf("%s %s returned status %d: %s", method, path, resp.StatusCode, string(data))
+	}
+	return json.Unmarshal(data, result)
+}
+
+// gitHubAPIURL returns the URL of the API of the GitHub server, which is the /api/v3 path of GitHub Enterprise
+// servers
+func gitHubAPIURL(gitServer string) string {
+	server := strings.TrimSuffix(gitServer, "/")
+	if server == "" || server == GithubServer {
+		return "https://api.github.com"
+	}
+	return server + "/api/v3"
+}
+
+// GitHubAppJWT creates the JSON Web Token used to authenticate as the GitHub App
+func GitHubAppJWT(appID int64, privateKey []byte, now time.Time) (string, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return "", errors.New("failed to decode GitHub App private key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse GitHub App private key")
+	}
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign GitHub App JWT")
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
@@ -0,0 +1,151 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubAppTokenManager(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "github-app")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, GitHubAppIDFilename), []byte("42\n"), 0600))
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, GitHubAppPrivateKeyFilename), privateKey, 0600))
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	created := 0
+	available := true
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "), "the App authenticates with a JWT")
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case !available:
+			w.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/users/jstrachan/installation":
+			_, _ = w.Write([]byte(`{"id": 7}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/7/access_tokens":
+			created++
+			_, _ = fmt.Fprintf(w, `{"token": "token-%d", "expires_at": %q}`, created, now.Add(time.Hour).Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	m := NewGitHubAppTokenManager(GithubServer, dir)
+	assert.Equal(t, "https://api.github.com", m.apiURL)
+	m.apiURL = server.URL
+	m.now = func() time.Time { return now }
+
+	token, err := m.FindToken("jstrachan")
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, []string{
+		"GET /orgs/jstrachan/installation",
+		"GET /users/jstrachan/installation",
+		"POST /app/installations/7/access_tokens",
+	}, requests, "the installation of a user is looked up once the one of an org is not found")
+
+	now = now.Add(50 * time.Minute)
+	token, err = m.FindToken("JStrachan")
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token, "the token is cached per owner")
+	assert.Len(t, requests, 3)
+
+	now = now.Add(6 * time.Minute)
+	token, err = m.FindToken("jstrachan")
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token, "the token is refreshed before it expires")
+	assert.Len(t, requests, 4, "the installation is cached")
+
+	available = false
+	now = now.Add(56 * time.Minute)
+	token, err = m.FindToken("jstrachan")
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token, "the previous token is used until it expires")
+	now = now.Add(5 * time.Minute)
+	_, err = m.FindToken("jstrachan")
+	assert.Error(t, err)
+
+	_, err = m.FindToken("unknown")
+	assert.Error(t, err)
+}
+
+func TestGitHubAppTokenManagerTokensDir(t *testing.T) {
+	m := GetGitHubAppTokenManager(GithubServer, filepath.Join("test_data", "secret_dir"))
+	assert.Equal(t, m, GetGitHubAppTokenManager(GithubServer, filepath.Join("test_data", "secret_dir")), "the manager is shared")
+	token, err := m.FindToken("arcalos-environments")
+	require.NoError(t, err)
+	assert.Equal(t, "mytoken", token, "the tokens are read from the dir without the App private key")
+
+	assert.Equal(t, "https://github.example.com/api/v3", gitHubAPIURL("https://github.example.com/"))
+}
+
+func TestGitHubAppTokenManagerRepositoryToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "github-app")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, GitHubAppIDFilename), []byte("42\n"), 0600))
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, GitHubAppPrivateKeyFilename), privateKey, 0600))
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/orgs/my-org/installation":
+			_, _ = w.Write([]byte(`{"id": 7}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/7/access_tokens":
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			bodies = append(bodies, string(body))
+			_, _ = fmt.Fprintf(w, `{"token": "token-%d", "expires_at": %q}`, len(bodies), now.Add(time.Hour).Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	m := NewGitHubAppTokenManager(GithubServer, dir)
+	m.apiURL = server.URL
+	m.now = func() time.Time { return now }
+
+	token, err := m.FindRepositoryToken("my-org", "my-repo", map[string]string{"contents": "read"})
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, []string{`{"repositories":["my-repo"],"permissions":{"contents":"read"}}`}, bodies, "the token is scoped to the repository")
+
+	token, err = m.FindRepositoryToken("my-org", "my-repo", map[string]string{"contents": "read"})
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token, "the token of the repository is cached")
+	token, err = m.FindRepositoryToken("my-org", "other", map[string]string{"contents": "read"})
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token, "the tokens of the other repositories are not shared")
+	token, err = m.FindToken("my-org")
+	require.NoError(t, err)
+	assert.Equal(t, "token-3", token, "the token of the owner is not shared with the repositories")
+	assert.Equal(t, "", bodies[2])
+
+	_, err = GetGitHubAppTokenManager(GithubServer, filepath.Join("test_data", "secret_dir")).FindRepositoryToken("arcalos-environments", "repo", nil)
+	assert.Error(t, err, "the tokens of the owners are not served as repository tokens")
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TweakListOptions returns a function tweaking the list and watch options of informers so that they
+// only watch the objects matching the label selector, if any, and list them in pages of pageSize
+// objects, if not zero. The initial lists of informers are otherwise served in one response from the
+// watch cache of the API server, which can exhaust the memory of controllers when there are many objects.
+func TweakListOptions(labelSelector string, pageSize int64) func(*metav1.ListOptions) {
+	return func(options *metav1.ListOptions) {
+		if labelSelector != "" {
+			options.LabelSelector = labelSelector
+		}
+		if pageSize > 0 && !options.Watch && options.ResourceVersion == "0" {
+			// the watch cache ignores the limit so the list has to be served by etcd
+			options.ResourceVersion = ""
+			options.Limit = pageSize
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTweakListOptions(t *testing.T) {
+	initialList := metav1.ListOptions{ResourceVersion: "0"}
+	TweakListOptions(ActiveJobsSelector, 100)(&initialList)
+	assert.Equal(t, metav1.ListOptions{LabelSelector: "!lighthouse.jenkins-x.io/completed", Limit: 100}, initialList)
+
+	watch := metav1.ListOptions{ResourceVersion: "123"}
+	TweakListOptions(ActiveJobsSelector, 100)(&watch)
+	assert.Equal(t, metav1.ListOptions{LabelSelector: "!lighthouse.jenkins-x.io/completed", ResourceVersion: "123"}, watch)
+
+	unchanged := metav1.ListOptions{ResourceVersion: "0"}
+	TweakListOptions("", 0)(&unchanged)
+	assert.Equal(t, metav1.ListOptions{ResourceVersion: "0"}, unchanged)
+}
@@ -0,0 +1,191 @@
+// Package watchdog detects the workers of the controllers which are wedged, either processing an item
+// for too long or not processing their queue at all, so that the process can be restarted cleanly
+// rather than silently stop reconciling.
+package watchdog
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// growthChecks is the number of checks in a row the queue must grow for its growth to be reported
+const growthChecks = 5
+
+var stuckCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "lighthouse_watchdog_stuck_total",
+	Help: "A counter of the times the workers of a component were detected as stuck by the watchdog.",
+}, []string{"component"})
+
+func init() {
+	prometheus.MustRegister(stuckCounter)
+}
+
+// Options configure the watchdog of a component
+type Options struct {
+	// Threshold is how long a worker may process an item, or a non empty queue may wait without any
+	// item being processed, before the workers are considered stuck. The watchdog is disabled if zero.
+	Threshold time.Duration
+	// Exit exits the process when the workers are stuck, so that it is restarted
+	Exit bool
+}
+
+// AddFlags adds the command line flags of the watchdog
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&o.Threshold, "watchdog-threshold", 0, "If set, the workers which process an item, or leave a non empty queue unprocessed, for longer than this are reported as stuck with a dump of the goroutines.")
+	fs.BoolVar(&o.Exit, "watchdog-exit", false, "Exit the process when the watchdog detects stuck workers, so that it is restarted.")
+}
+
+// Watchdog monitors the items the workers of a component are processing and the length of their
+// queue. A nil Watchdog is disabled.
+type Watchdog struct {
+	component string
+	threshold time.Duration
+	exit      bool
+	queueLen  func() int
+
+	now      func() time.Time
+	exitFunc func(int)
+
+	lock         sync.Mutex
+	nextID       int
+	working      map[int]time.Time
+	lastProgress time.Time
+	lastLen      int
+	growing      int
+	stuck        bool
+}
+
+// New creates the watchdog of the workers of a component, whose queue length is returned by queueLen
+// if it has a queue, or returns nil if it is disabled by the options
+func New(component string, opts Options, queueLen func() int) *Watchdog {
+	if opts.Threshold <= 0 {
+		return nil
+	}
+	return &Watchdog{
+		component:    component,
+		threshold:    opts.Threshold,
+		exit:         opts.Exit,
+		queueLen:     queueLen,
+		now:          time.Now,
+		exitFunc:     os.Exit,
+		working:      map[int]time.Time{},
+		lastProgress: time.Now(),
+	}
+}
+
+// Track records that a worker started processing an item. The returned function records that the
+// worker is done with it.
+func (w *Watchdog) Track() func() {
+	if w == nil {
+		return func() {}
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	id := w.nextID
+	w.nextID++
+	w.working[id] = w.now()
+	return func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		delete(w.working, id)
+		w.lastProgress = w.now()
+	}
+}
+
+// Run checks the workers until stopCh is closed
+func (w *Watchdog) Run(stopCh <-chan struct{}) {
+	if w == nil {
+		return
+	}
+	interval := w.threshold / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	wait.Until(func() { w.check() }, interval, stopCh)
+}
+
+// check returns true if the workers are stuck. They are reported when they get stuck, and the process
+// exits if the watchdog is configured to.
+func (w *Watchdog) check() bool {
+	reason := w.stuckReason()
+	if reason == "" {
+		return false
+	}
+	log := logrus.WithField("component", w.component)
+	if !w.markStuck() {
+		stuckCounter.WithLabelValues(w.component).Inc()
+		log.Errorf("watchdog: %s, the goroutines are:\n%s", reason, goroutines())
+	}
+	if w.exit {
+		log.Errorf("watchdog: %s, exiting so that the process is restarted", reason)
+		w.exitFunc(1)
+	}
+	return true
+}
+
+// stuckReason returns why the workers are stuck, or an empty string if they are not
+func (w *Watchdog) stuckReason() string {
+	length := 0
+	if w.queueLen != nil {
+		length = w.queueLen()
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	now := w.now()
+
+	if length > w.lastLen {
+		w.growing++
+		if w.growing%growthChecks == 0 {
+			logrus.WithFields(logrus.Fields{"component": w.component, "length": length}).Warnf("watchdog: the queue has grown for %d checks in a row", w.growing)
+		}
+	} else {
+		w.growing = 0
+	}
+	w.lastLen = length
+
+	var oldest time.Time
+	for _, start := range w.working {
+		if oldest.IsZero() || start.Before(oldest) {
+			oldest = start
+		}
+	}
+	if length == 0 && len(w.working) == 0 {
+		// an idle component is making progress
+		w.lastProgress = now
+	}
+	switch {
+	case !oldest.IsZero() && now.Sub(oldest) > w.threshold:
+		return fmt.Sprintf("a worker has been processing an item for %s", now.Sub(oldest).Round(time.Second))
+	case length > 0 && now.Sub(w.lastProgress) > w.threshold:
+		return fmt.Sprintf("%d items are queued but none was processed for %s", length, now.Sub(w.lastProgress).Round(time.Second))
+	}
+	w.stuck = false
+	return ""
+}
+
+// markStuck marks the workers as stuck, returning whether they already were
+func (w *Watchdog) markStuck() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	stuck := w.stuck
+	w.stuck = true
+	return stuck
+}
+
+// goroutines returns the stacks of all the goroutines
+func goroutines() string {
+	buf := &bytes.Buffer{}
+	if err := pprof.Lookup("goroutine").WriteTo(buf, 2); err != nil {
+		return fmt.Sprintf("failed to dump the goroutines: %v", err)
+	}
+	return buf.String()
+}
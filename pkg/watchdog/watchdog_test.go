@@ -0,0 +1,55 @@
+package watchdog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdog(t *testing.T) {
+	var w *Watchdog
+	assert.Nil(t, New("test", Options{}, nil))
+	w.Track()()
+	w.Run(nil)
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	queued := 0
+	exits := 0
+	w = New("test", Options{Threshold: time.Minute, Exit: true}, func() int { return queued })
+	w.now = func() time.Time { return now }
+	w.lastProgress = now
+	w.exitFunc = func(int) { exits++ }
+	stuck := func() float64 {
+		return testutil.ToFloat64(stuckCounter.WithLabelValues("test"))
+	}
+
+	done := w.Track()
+	now = now.Add(30 * time.Second)
+	assert.False(t, w.check())
+	done()
+	now = now.Add(time.Hour)
+	assert.False(t, w.check(), "an idle component is not stuck")
+
+	queued = 3
+	now = now.Add(30 * time.Second)
+	assert.False(t, w.check(), "the queued items are not late yet")
+	now = now.Add(time.Minute)
+	assert.True(t, w.check(), "the queued items are not processed")
+	assert.Equal(t, 1, exits)
+	assert.Equal(t, float64(1), stuck())
+	assert.True(t, w.check())
+	assert.Equal(t, float64(1), stuck(), "the stuck workers are reported once")
+
+	w.exit = false
+	done = w.Track()
+	done()
+	assert.False(t, w.check(), "the workers are processing the queue again")
+
+	w.Track()
+	now = now.Add(2 * time.Minute)
+	assert.True(t, w.check(), "a worker is stuck processing an item")
+	assert.Equal(t, float64(2), stuck())
+	assert.Equal(t, 2, exits, "the process only exits if configured to")
+}
@@ -0,0 +1,146 @@
+package watcher
+
+import (
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewConfigAgentWatcher creates a watcher keeping the config, settings and plugins agents up to date
+// with the Lighthouse ConfigMaps of the namespace, so that the agents can be shared by the controllers
+// of a process. The settings agent is optional.
+func NewConfigAgentWatcher(kubeClient kubernetes.Interface, ns string, configAgent *config.Agent, settingsAgent *settings.Agent, pluginAgent *plugins.ConfigAgent, stopCh <-chan struct{}) (*ConfigMapWatcher, error) {
+	callbacks := []ConfigMapCallback{
+		&ConfigMapEntryCallback{
+			Name:     util.ProwConfigMapName,
+			Key:      util.ProwConfigFilename,
+			Callback: configYamlCallback(configAgent, settingsAgent),
+		},
+		&ConfigMapEntryCallback{
+			Name:     util.ProwPluginsConfigMapName,
+			Key:      util.ProwPluginsFilename,
+			Callback: pluginsYamlCallback(pluginAgent),
+		},
+	}
+	return newConfigMapWatcher(kubeClient, ns, callbacks, stopCh)
+}
+
+// NewConfigAgentFileWatchers creates the watchers keeping the config, settings and plugins agents up to date
+// with the config.yaml and plugins.yaml files at the given paths, which are reloaded when they change, e.g.
+// when they are mounted from a Secret or synchronized by git-sync. The file whose path is empty is watched in
+// its Lighthouse ConfigMap of the namespace instead. The watchers which watch nothing are nil.
+func NewConfigAgentFileWatchers(kubeClient kubernetes.Interface, ns, configFile, pluginFile string, configAgent *config.Agent, settingsAgent *settings.Agent, pluginAgent *plugins.ConfigAgent, stopCh <-chan struct{}) (*FileWatcher, *ConfigMapWatcher, error) {
+	var fileCallbacks []*FileCallback
+	var configMapCallbacks []ConfigMapCallback
+	if configFile != "" {
+		fileCallbacks = append(fileCallbacks, &FileCallback{
+			Path:     configFile,
+			Callback: configYamlCallback(configAgent, settingsAgent),
+		})
+	} else {
+		configMapCallbacks = append(configMapCallbacks, &ConfigMapEntryCallback{
+			Name:     util.ProwConfigMapName,
+			Key:      util.ProwConfigFilename,
+			Callback: configYamlCallback(configAgent, settingsAgent),
+		})
+	}
+	if pluginFile != "" {
+		fileCallbacks = append(fileCallbacks, &FileCallback{
+			Path:     pluginFile,
+			Callback: pluginsYamlCallback(pluginAgent),
+		})
+	} else {
+		configMapCallbacks = append(configMapCallbacks, &ConfigMapEntryCallback{
+			Name:     util.ProwPluginsConfigMapName,
+			Key:      util.ProwPluginsFilename,
+			Callback: pluginsYamlCallback(pluginAgent),
+		})
+	}
+
+	var fileWatcher *FileWatcher
+	var configMapWatcher *ConfigMapWatcher
+	var err error
+	if len(fileCallbacks) > 0 {
+		fileWatcher, err = NewFileWatcher(fileCallbacks, stopCh)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to create file watcher")
+		}
+	}
+	if len(configMapCallbacks) > 0 {
+		configMapWatcher, err = newConfigMapWatcher(kubeClient, ns, configMapCallbacks, stopCh)
+		if err != nil {
+			fileWatcher.Stop()
+			return nil, nil, err
+		}
+	}
+	return fileWatcher, configMapWatcher, nil
+}
+
+func newConfigMapWatcher(kubeClient kubernetes.Interface, ns string, callbacks []ConfigMapCallback, stopCh <-chan struct{}) (*ConfigMapWatcher, error) {
+	w, err := NewConfigMapWatcher(kubeClient, ns, callbacks, stopCh)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create ConfigMap watcher")
+	}
+	return w, nil
+}
+
+// configYamlCallback returns the callback updating the config and settings agents with the config.yaml text
+func configYamlCallback(configAgent *config.Agent, settingsAgent *settings.Agent) func(string) {
+	return func(text string) {
+		if text == "" {
+			return
+		}
+		cfg, err := config.LoadYAMLConfig([]byte(text))
+		if err != nil {
+			logrus.WithError(err).Error("Error processing the prow Config YAML")
+			return
+		}
+		s, settingsErr := settings.Load([]byte(text))
+		if settingsErr == nil {
+			renamed, err := jobutil.ApplyStatusContexts(cfg, s.StatusContexts)
+			if err != nil {
+				logrus.WithError(err).Error("Error renaming the status contexts of the jobs, keeping the configured contexts")
+			} else {
+				cfg = renamed
+			}
+		}
+		logrus.Info("updating the prow core configuration")
+		configAgent.Set(cfg)
+
+		if settingsAgent != nil {
+			if settingsErr != nil {
+				logrus.WithError(settingsErr).Error("Error processing the lighthouse settings of the Config YAML")
+				return
+			}
+			settingsAgent.Set(s)
+		}
+	}
+}
+
+// pluginsYamlCallback returns the callback updating the plugins agent with the plugins.yaml text
+func pluginsYamlCallback(pluginAgent *plugins.ConfigAgent) func(string) {
+	return func(text string) {
+		if text == "" {
+			return
+		}
+		cfg, err := pluginAgent.LoadYAMLConfig([]byte(text))
+		if err != nil {
+			logrus.WithError(err).Error("Error processing the prow Plugins YAML")
+			return
+		}
+		logrus.Info("updating the prow plugins configuration")
+		pluginAgent.Set(cfg)
+
+		s, err := plugins.LoadSettings([]byte(text))
+		if err != nil {
+			logrus.WithError(err).Error("Error processing the lighthouse settings of the Plugins YAML")
+			return
+		}
+		pluginAgent.SetSettings(s)
+	}
+}
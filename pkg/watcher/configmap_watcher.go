@@ -91,8 +91,11 @@ func (w *ConfigMapWatcher) IsStopped() bool {
 	return w.stopped
 }
 
-// Stop stops the configmap watcher
+// Stop stops the configmap watcher, if any
 func (w *ConfigMapWatcher) Stop() {
+	if w == nil {
+		return
+	}
 	w.stopped = true
 	w.watch.Stop()
 }
@@ -0,0 +1,166 @@
+package watcher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// fileEventsDelay coalesces the bursts of events of a file update, e.g. the symlink swaps of the atomic
+// writer of the mounted Secrets or of git-sync, so that the files are read once they are written
+const fileEventsDelay = 100 * time.Millisecond
+
+// FileWatcher invokes callbacks with the content of files when they change. The directories of the files
+// are watched with fsnotify, along with the directories of the symlinks of their paths, so that the files
+// replaced by swapping a symlink, as the mounted Secrets and ConfigMaps and git-sync do, are reloaded.
+type FileWatcher struct {
+	watcher   *fsnotify.Watcher
+	callbacks []*FileCallback
+	stopCh    <-chan struct{}
+	watched   map[string]string
+	stopOnce  sync.Once
+}
+
+// FileCallback invokes a callback if the content of a file changes
+type FileCallback struct {
+	Path     string
+	Callback func(string)
+	oldValue string
+}
+
+// OnChange reads the file and invokes the callback function if its content is not empty and changes
+func (cb *FileCallback) OnChange() error {
+	data, err := ioutil.ReadFile(cb.Path) // #nosec
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", cb.Path)
+	}
+	value := string(data)
+	if value != "" && value != cb.oldValue {
+		cb.oldValue = value
+		cb.Callback(value)
+	}
+	return nil
+}
+
+// NewFileWatcher creates a watcher of the files of the callbacks which reads them synchronously then
+// asynchronously processes the file system events. It returns an error if a file cannot be read.
+func NewFileWatcher(callbacks []*FileCallback, stopCh <-chan struct{}) (*FileWatcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the file system watcher")
+	}
+	w := &FileWatcher{
+		watcher: fsWatcher,
+		// lets take a copy of the slice
+		callbacks: append([]*FileCallback{}, callbacks...),
+		stopCh:    stopCh,
+		watched:   map[string]string{},
+	}
+	if err := w.watchDirs(); err != nil {
+		w.Stop()
+		return nil, err
+	}
+	for _, cb := range w.callbacks {
+		if err := cb.OnChange(); err != nil {
+			w.Stop()
+			return nil, err
+		}
+	}
+
+	go w.watchEvents()
+	return w, nil
+}
+
+// Stop stops the file watcher, if any
+func (w *FileWatcher) Stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() {
+		w.watcher.Close()
+	})
+}
+
+func (w *FileWatcher) watchEvents() {
+	l := logrus.WithField("component", "FileWatcher")
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-w.stopCh:
+			w.Stop()
+			return
+		case _, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if timer == nil {
+				timer = time.After(fileEventsDelay)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			l.WithError(err).Error("failed to watch the files")
+		case <-timer:
+			timer = nil
+			// the symlinks may now point to other directories
+			if err := w.watchDirs(); err != nil {
+				l.WithError(err).Error("failed to watch the directories of the files")
+			}
+			for _, cb := range w.callbacks {
+				if err := cb.OnChange(); err != nil {
+					l.WithError(err).Error("failed to reload the file")
+				}
+			}
+		}
+	}
+}
+
+// watchDirs watches the directories of the files and of the symlinks of their paths, watching again the
+// directories which now resolve to other directories
+func (w *FileWatcher) watchDirs() error {
+	for _, cb := range w.callbacks {
+		for _, dir := range watchedDirs(cb.Path) {
+			resolved, err := filepath.EvalSymlinks(dir)
+			if err != nil {
+				return errors.Wrapf(err, "failed to resolve directory %s", dir)
+			}
+			previous, ok := w.watched[dir]
+			if ok && previous == resolved {
+				continue
+			}
+			if ok {
+				// the watch of the previous directory may already be gone with it
+				_ = w.watcher.Remove(dir)
+			}
+			if err := w.watcher.Add(dir); err != nil {
+				return errors.Wrapf(err, "failed to watch directory %s", dir)
+			}
+			w.watched[dir] = resolved
+		}
+	}
+	return nil
+}
+
+// watchedDirs returns the directory of the file along with the directories containing the symlinks of
+// its path, e.g. the directory of the link git-sync swaps to a new worktree
+func watchedDirs(path string) []string {
+	path = filepath.Clean(path)
+	dirs := []string{filepath.Dir(path)}
+	for p := path; ; p = filepath.Dir(p) {
+		parent := filepath.Dir(p)
+		if parent == p {
+			break
+		}
+		if info, err := os.Lstat(p); err == nil && info.Mode()&os.ModeSymlink != 0 && parent != dirs[0] {
+			dirs = append(dirs, parent)
+		}
+	}
+	return dirs
+}
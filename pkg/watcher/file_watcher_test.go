@@ -0,0 +1,107 @@
+package watcher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the values of a file callback
+type recorder struct {
+	lock   sync.Mutex
+	values []string
+}
+
+func (r *recorder) record(value string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.values = append(r.values, value)
+}
+
+func (r *recorder) get() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string{}, r.values...)
+}
+
+// tempDir creates a temporary directory whose path has no symlinks
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "file-watcher")
+	require.NoError(t, err)
+	dir, err = filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	return dir
+}
+
+func TestFileWatcher(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("a"), 0600))
+	r := &recorder{}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	w, err := NewFileWatcher([]*FileCallback{{Path: file, Callback: r.record}}, stopCh)
+	require.NoError(t, err)
+	defer w.Stop()
+	assert.Equal(t, []string{"a"}, r.get(), "the file is read synchronously")
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("b"), 0600))
+	assert.Eventually(t, func() bool { return len(r.get()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, ioutil.WriteFile(file, []byte(""), 0600))
+	require.NoError(t, ioutil.WriteFile(file, []byte("b"), 0600))
+	time.Sleep(3 * fileEventsDelay)
+	assert.Equal(t, []string{"a", "b"}, r.get(), "the empty and unchanged contents are ignored")
+
+	_, err = NewFileWatcher([]*FileCallback{{Path: filepath.Join(dir, "missing.yaml"), Callback: r.record}}, stopCh)
+	assert.Error(t, err)
+}
+
+func TestFileWatcherSymlinkSwap(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// git-sync checks out each revision in a new worktree then swaps the link to the worktrees
+	worktree := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.Mkdir(path, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(path, "plugins.yaml"), []byte(content), 0600))
+		return path
+	}
+	link := filepath.Join(dir, "repo")
+	require.NoError(t, os.Symlink(worktree("rev1", "a"), link))
+
+	r := &recorder{}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	w, err := NewFileWatcher([]*FileCallback{{Path: filepath.Join(link, "plugins.yaml"), Callback: r.record}}, stopCh)
+	require.NoError(t, err)
+	defer w.Stop()
+
+	for i, rev := range []string{"rev2", "rev3"} {
+		tmpLink := filepath.Join(dir, "tmp-link")
+		require.NoError(t, os.Symlink(worktree(rev, rev), tmpLink))
+		require.NoError(t, os.Rename(tmpLink, link))
+		expected := i + 2
+		assert.Eventually(t, func() bool { return len(r.get()) == expected }, 5*time.Second, 10*time.Millisecond)
+	}
+	assert.Equal(t, []string{"a", "rev2", "rev3"}, r.get())
+}
+
+func TestWatchedDirs(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "rev1", "config"), 0700))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "rev1"), filepath.Join(dir, "repo")))
+
+	assert.Equal(t, []string{filepath.Join(dir, "rev1", "config")}, watchedDirs(filepath.Join(dir, "rev1", "config", "config.yaml")))
+	assert.Equal(t, []string{filepath.Join(dir, "repo", "config"), dir}, watchedDirs(filepath.Join(dir, "repo", "config", "config.yaml")))
+}
@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/sirupsen/logrus"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AdmissionPath is the URL path of the validating admission webhook of the LighthouseJobs
+	AdmissionPath = "/validate/lighthousejobs"
+
+	// AdmissionCertDirEnvVar is the environment variable of the directory containing the tls.crt and tls.key
+	// files the admission webhook is served with. The admission webhook is disabled when it is not set.
+	AdmissionCertDirEnvVar = "LIGHTHOUSE_ADMISSION_CERT_DIR"
+	// AdmissionPortEnvVar is the environment variable of the TLS port of the admission webhook, 8443 by default
+	AdmissionPortEnvVar = "LIGHTHOUSE_ADMISSION_PORT"
+
+	// defaultAdmissionPort is the TLS port of the admission webhook by default
+	defaultAdmissionPort = 8443
+	// maxAdmissionReviewSize is the maximum size of the admission reviews, which contain a single job
+	maxAdmissionReviewSize = 3 * 1024 * 1024
+)
+
+// serveAdmissionFromEnv serves the admission webhook of the LighthouseJobs over TLS if $LIGHTHOUSE_ADMISSION_CERT_DIR
+// is set. The certificate is read on each handshake so that it is renewed without restarting the webhooks.
+func serveAdmissionFromEnv() error {
+	dir := os.Getenv(AdmissionCertDirEnvVar)
+	if dir == "" {
+		return nil
+	}
+	port := defaultAdmissionPort
+	if value := os.Getenv(AdmissionPortEnvVar); value != "" {
+		p, err := strconv.Atoi(value)
+		if err != nil || p <= 0 {
+			return fmt.Errorf("invalid $%s value %q, it must be a port number", AdmissionPortEnvVar, value)
+		}
+		port = p
+	}
+	mux := http.NewServeMux()
+	mux.Handle(AdmissionPath, http.HandlerFunc(validateLighthouseJobs))
+	server := &http.Server{
+		Addr:    ":" + strconv.Itoa(port),
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+				if err != nil {
+					return nil, err
+				}
+				return &cert, nil
+			},
+		},
+	}
+	go func() {
+		logrus.Infof("Lighthouse is now validating the LighthouseJobs on path %s and port %d", AdmissionPath, port)
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			logrus.WithError(err).Fatal("failed to serve the admission webhook")
+		}
+	}()
+	return nil
+}
+
+// validateLighthouseJobs answers the admission reviews of the LighthouseJobs, rejecting the jobs whose spec is
+// invalid
+func validateLighthouseJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxAdmissionReviewSize))
+	if err != nil {
+		http.Error(w, "failed to read the body", http.StatusBadRequest)
+		return
+	}
+	review := admissionv1beta1.AdmissionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+	review.Response = admitLighthouseJob(review.Request)
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&review); err != nil {
+		logrus.WithError(err).Error("failed to write the admission response")
+	}
+}
+
+// admitLighthouseJob allows the LighthouseJob of the request if its spec is valid
+func admitLighthouseJob(request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	response := &admissionv1beta1.AdmissionResponse{UID: request.UID, Allowed: true}
+	job := v1alpha1.LighthouseJob{}
+	err := json.Unmarshal(request.Object.Raw, &job)
+	if err == nil {
+		err = job.Spec.Validate()
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"name": request.Name, "namespace": request.Namespace}).WithError(err).Info("rejected invalid LighthouseJob")
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+			Message: fmt.Sprintf("invalid LighthouseJob: %v", err),
+		}
+	}
+	return response
+}
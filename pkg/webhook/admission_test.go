@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateLighthouseJobs(t *testing.T) {
+	review := func(spec v1alpha1.LighthouseJobSpec) *admissionv1beta1.AdmissionResponse {
+		raw, err := json.Marshal(&v1alpha1.LighthouseJob{Spec: spec})
+		require.NoError(t, err)
+		body, err := json.Marshal(&admissionv1beta1.AdmissionReview{Request: &admissionv1beta1.AdmissionRequest{
+			UID:    "uid",
+			Object: runtime.RawExtension{Raw: raw},
+		}})
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		validateLighthouseJobs(w, httptest.NewRequest(http.MethodPost, AdmissionPath, bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		answer := admissionv1beta1.AdmissionReview{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &answer))
+		require.NotNil(t, answer.Response)
+		assert.Equal(t, "uid", string(answer.Response.UID))
+		return answer.Response
+	}
+
+	valid := v1alpha1.LighthouseJobSpec{
+		Type: config.PresubmitJob,
+		Job:  "unit",
+		Refs: &v1alpha1.Refs{Org: "org", Repo: "repo", BaseRef: "master", Pulls: []v1alpha1.Pull{{Number: 1, SHA: "abcdef1"}}},
+	}
+	assert.True(t, review(valid).Allowed)
+
+	response := review(v1alpha1.LighthouseJobSpec{Type: config.PresubmitJob, Job: "unit"})
+	assert.False(t, response.Allowed)
+	require.NotNil(t, response.Result)
+	assert.Equal(t, "invalid LighthouseJob: missing refs for presubmit job unit", response.Result.Message)
+	assert.Equal(t, int32(http.StatusUnprocessableEntity), response.Result.Code)
+
+	w := httptest.NewRecorder()
+	validateLighthouseJobs(w, httptest.NewRequest(http.MethodPost, AdmissionPath, bytes.NewReader([]byte("{}"))))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "no request to review")
+}
@@ -0,0 +1,438 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// AdoptionPath is the URL path for the HTTP endpoint that returns the adoption report of the repositories.
+	AdoptionPath = "/admin/adoption"
+
+	// AdoptionConfigMapName is the name of the ConfigMap the webhook activity of the repositories is saved in
+	AdoptionConfigMapName = "lighthouse-webhooks-adoption"
+	adoptionConfigMapKey  = "activity.json"
+
+	// activitySavePeriod is how often the activity recorded by a replica is saved
+	activitySavePeriod = time.Minute
+
+	// defaultStaleDays is the number of days without events after which a repository is reported as stale
+	defaultStaleDays = 30
+)
+
+// commandRe matches the name of the slash commands of a comment
+var commandRe = regexp.MustCompile(`(?m)^/(?:lh-)?([a-z][a-z0-9-]*)`)
+
+// repoActivity is the webhook activity of the repositories
+type repoActivity struct {
+	// Since is when the activity started to be recorded
+	Since     time.Time                 `json:"since"`
+	LastEvent map[string]time.Time      `json:"lastEvent,omitempty"`
+	Commands  map[string]map[string]int `json:"commands,omitempty"`
+}
+
+func newRepoActivity(since time.Time) *repoActivity {
+	return &repoActivity{
+		Since:     since,
+		LastEvent: map[string]time.Time{},
+		Commands:  map[string]map[string]int{},
+	}
+}
+
+// add merges the other activity into the activity, keeping the latest events and summing the commands
+func (a *repoActivity) add(other *repoActivity) {
+	if other == nil {
+		return
+	}
+	if a.Since.IsZero() || (!other.Since.IsZero() && other.Since.Before(a.Since)) {
+		a.Since = other.Since
+	}
+	for r, t := range other.LastEvent {
+		if t.After(a.LastEvent[r]) {
+			a.LastEvent[r] = t
+		}
+	}
+	for r, counts := range other.Commands {
+		total := a.Commands[r]
+		if total == nil {
+			total = map[string]int{}
+			a.Commands[r] = total
+		}
+		for c, n := range counts {
+			total[c] += n
+		}
+	}
+}
+
+func (a *repoActivity) empty() bool {
+	return len(a.LastEvent) == 0 && len(a.Commands) == 0
+}
+
+// activityStore persists the activity recorded by the webhook replicas
+type activityStore interface {
+	// Load returns the persisted activity
+	Load() (*repoActivity, error)
+	// Add merges the activity into the persisted activity
+	Add(activity *repoActivity) error
+}
+
+// configMapActivityStore persists the activity as JSON in the AdoptionConfigMapName ConfigMap
+type configMapActivityStore struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+}
+
+func (s *configMapActivityStore) Load() (*repoActivity, error) {
+	cm, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(AdoptionConfigMapName, metav1.GetOptions{})
+	if kubeerrors.IsNotFound(err) {
+		return newRepoActivity(time.Time{}), nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get ConfigMap %s", AdoptionConfigMapName)
+	}
+	return parseActivity(cm)
+}
+
+func (s *configMapActivityStore) Add(activity *repoActivity) error {
+	configMaps := s.kubeClient.CoreV1().ConfigMaps(s.namespace)
+	// the replicas add their activity concurrently, so the merge is retried on conflicts
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(AdoptionConfigMapName, metav1.GetOptions{})
+		if kubeerrors.IsNotFound(err) {
+			data, err := json.Marshal(activity)
+			if err != nil {
+				return errors.Wrap(err, "failed to marshal the activity")
+			}
+			_, err = configMaps.Create(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: AdoptionConfigMapName},
+				Data:       map[string]string{adoptionConfigMapKey: string(data)},
+			})
+			return errors.Wrapf(err, "failed to create ConfigMap %s", AdoptionConfigMapName)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get ConfigMap %s", AdoptionConfigMapName)
+		}
+		saved, err := parseActivity(cm)
+		if err != nil {
+			return err
+		}
+		saved.add(activity)
+		data, err := json.Marshal(saved)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the activity")
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[adoptionConfigMapKey] = string(data)
+		_, err = configMaps.Update(cm)
+		if kubeerrors.IsConflict(err) {
+			return err
+		}
+		return errors.Wrapf(err, "failed to update ConfigMap %s", AdoptionConfigMapName)
+	})
+}
+
+func parseActivity(cm *corev1.ConfigMap) (*repoActivity, error) {
+	activity := newRepoActivity(time.Time{})
+	if data := cm.Data[adoptionConfigMapKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), activity); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse ConfigMap %s", AdoptionConfigMapName)
+		}
+	}
+	// maps missing from the JSON are nil after unmarshalling
+	if activity.LastEvent == nil {
+		activity.LastEvent = map[string]time.Time{}
+	}
+	if activity.Commands == nil {
+		activity.Commands = map[string]map[string]int{}
+	}
+	return activity, nil
+}
+
+// activityTracker records the webhook activity of the repositories. The activity recorded by the
+// replica is periodically added to the store, if there is one, so that the adoption report covers
+// all the replicas and survives their restarts.
+type activityTracker struct {
+	lock    sync.Mutex
+	pending *repoActivity
+	store   activityStore
+}
+
+func newActivityTracker(now time.Time, store activityStore) *activityTracker {
+	return &activityTracker{
+		pending: newRepoActivity(now),
+		store:   store,
+	}
+}
+
+// recordEvent records that the repository received a webhook
+func (t *activityTracker) recordEvent(fullName string, at time.Time) {
+	if t == nil || fullName == "" {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if at.After(t.pending.LastEvent[fullName]) {
+		t.pending.LastEvent[fullName] = at
+	}
+}
+
+// recordCommands counts the slash commands of a comment on the repository
+func (t *activityTracker) recordCommands(fullName, body string) {
+	if t == nil || fullName == "" {
+		return
+	}
+	matches := commandRe.FindAllStringSubmatch(body, -1)
+	if len(matches) == 0 {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	counts := t.pending.Commands[fullName]
+	if counts == nil {
+		counts = map[string]int{}
+		t.pending.Commands[fullName] = counts
+	}
+	for _, m := range matches {
+		counts[m[1]]++
+	}
+}
+
+// save adds the pending activity to the store, the activity stays pending if it cannot be saved
+func (t *activityTracker) save() error {
+	if t == nil || t.store == nil {
+		return nil
+	}
+	t.lock.Lock()
+	pending := t.pending
+	t.pending = newRepoActivity(pending.Since)
+	t.lock.Unlock()
+	if pending.empty() {
+		return nil
+	}
+	if err := t.store.Add(pending); err != nil {
+		t.lock.Lock()
+		t.pending.add(pending)
+		t.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// run saves the pending activity every activitySavePeriod, and a last time once stop is closed
+func (t *activityTracker) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(activitySavePeriod)
+	defer ticker.Stop()
+	for stopped := false; !stopped; {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			stopped = true
+		}
+		if err := t.save(); err != nil {
+			logrus.WithError(err).Warn("failed to save the webhook activity")
+		}
+	}
+}
+
+// snapshot returns the persisted activity merged with the activity pending in the replica
+func (t *activityTracker) snapshot() (*repoActivity, error) {
+	answer := newRepoActivity(time.Time{})
+	if t == nil {
+		return answer, nil
+	}
+	if t.store != nil {
+		saved, err := t.store.Load()
+		if err != nil {
+			return nil, err
+		}
+		answer.add(saved)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	answer.add(t.pending)
+	return answer, nil
+}
+
+// AdoptionReport aggregates the plugins enabled, the commands used and the merge automation of the repositories
+type AdoptionReport struct {
+	// Since is when the webhook servers started recording events and commands
+	Since time.Time `json:"since"`
+	// StaleDays is the number of days without events after which a repository is stale
+	StaleDays int `json:"staleDays"`
+	// Repositories is the adoption of each repository
+	Repositories []RepositoryAdoption `json:"repositories"`
+	// Plugins is the number of repositories each plugin is enabled for
+	Plugins map[string]int `json:"plugins"`
+	// Commands is the number of uses of each command across the repositories
+	Commands map[string]int `json:"commands"`
+	// MergeAutomation is the number of repositories covered by a keeper query
+	MergeAutomation int `json:"mergeAutomation"`
+	// Stale is the number of stale repositories
+	Stale int `json:"stale"`
+}
+
+// RepositoryAdoption is the adoption of lighthouse by a repository
+type RepositoryAdoption struct {
+	Repo            string         `json:"repo"`
+	Plugins         []string       `json:"plugins,omitempty"`
+	ExternalPlugins []string       `json:"externalPlugins,omitempty"`
+	Commands        map[string]int `json:"commands,omitempty"`
+	// Jobs is the number of presubmits and postsubmits of the repository, including those inherited from its org
+	Jobs            int        `json:"jobs"`
+	MergeAutomation bool       `json:"mergeAutomation"`
+	LastEvent       *time.Time `json:"lastEvent,omitempty"`
+	// Stale is true if the repository received no events in the last StaleDays days
+	Stale bool `json:"stale"`
+}
+
+// AdoptionReport returns the adoption report of the repositories of the org, or of all the
+// repositories known to the config or which sent events if org is empty
+func (s *Server) AdoptionReport(org string, staleDays int, now time.Time) (*AdoptionReport, error) {
+	activity, err := s.activity.snapshot()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the webhook activity")
+	}
+	lastEvent, commands := activity.LastEvent, activity.Commands
+	report := &AdoptionReport{
+		Since:     activity.Since,
+		StaleDays: staleDays,
+		Plugins:   map[string]int{},
+		Commands:  map[string]int{},
+	}
+
+	repos := sets.NewString()
+	for r := range lastEvent {
+		repos.Insert(r)
+	}
+	var cfg *config.Config
+	if s.ConfigAgent != nil {
+		cfg = s.ConfigAgent.Config()
+	}
+	if cfg != nil {
+		for r := range cfg.Presubmits {
+			repos.Insert(r)
+		}
+		for r := range cfg.Postsubmits {
+			repos.Insert(r)
+		}
+		for _, q := range cfg.Keeper.Queries {
+			repos.Insert(q.Repos...)
+		}
+	}
+	var pluginCfg *plugins.Configuration
+	if s.Plugins != nil {
+		pluginCfg = s.Plugins.Config()
+	}
+	if pluginCfg != nil {
+		for r := range pluginCfg.Plugins {
+			if strings.Contains(r, "/") {
+				repos.Insert(r)
+			}
+		}
+		for r := range pluginCfg.ExternalPlugins {
+			if strings.Contains(r, "/") {
+				repos.Insert(r)
+			}
+		}
+	}
+
+	stale := now.Add(-time.Duration(staleDays) * 24 * time.Hour)
+	for _, r := range repos.List() {
+		o, n := scm.Split(r)
+		if o == "" || n == "" || (org != "" && !strings.EqualFold(o, org)) {
+			continue
+		}
+		adoption := RepositoryAdoption{
+			Repo:     r,
+			Commands: commands[r],
+			Stale:    true,
+		}
+		if cfg != nil {
+			repository := scm.Repository{Namespace: o, Name: n, FullName: r}
+			adoption.Jobs = len(jobutil.Presubmits(cfg, repository)) + len(jobutil.Postsubmits(cfg, repository))
+			for _, q := range cfg.Keeper.Queries {
+				if q.ForRepo(o, n) {
+					adoption.MergeAutomation = true
+					break
+				}
+			}
+		}
+		if pluginCfg != nil {
+			adoption.Plugins = s.Plugins.EnabledPlugins(o, n)
+			external := sets.NewString()
+			for _, p := range append(pluginCfg.ExternalPlugins[o], pluginCfg.ExternalPlugins[r]...) {
+				external.Insert(p.Name)
+			}
+			adoption.ExternalPlugins = external.List()
+		}
+		if t, ok := lastEvent[r]; ok {
+			adoption.LastEvent = &t
+			adoption.Stale = t.Before(stale)
+		}
+
+		for _, p := range adoption.Plugins {
+			report.Plugins[p]++
+		}
+		for c, count := range adoption.Commands {
+			report.Commands[c] += count
+		}
+		if adoption.MergeAutomation {
+			report.MergeAutomation++
+		}
+		if adoption.Stale {
+			report.Stale++
+		}
+		report.Repositories = append(report.Repositories, adoption)
+	}
+	return report, nil
+}
+
+// adoption returns the adoption report of the org given by the org query parameter, or of all
+// the repositories, as JSON. The stale-days query parameter overrides the number of days without
+// events after which repositories are reported as stale.
+func (o *Options) adoption(w http.ResponseWriter, r *http.Request) {
+	staleDays := defaultStaleDays
+	if value := r.URL.Query().Get("stale-days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			http.Error(w, "the stale-days query parameter must be a positive number of days", http.StatusBadRequest)
+			return
+		}
+		staleDays = days
+	}
+	report, err := o.server.AdoptionReport(r.URL.Query().Get("org"), staleDays, time.Now())
+	if err != nil {
+		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
+		return
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		logrus.WithError(err).Debug("failed to write the adoption report")
+	}
+}
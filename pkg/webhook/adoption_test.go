@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdoptionReport(t *testing.T) {
+	now := time.Now()
+	cfg := &config.Config{}
+	require.NoError(t, cfg.SetPresubmits(map[string][]config.Presubmit{
+		"org/jobs": {{JobBase: config.JobBase{Name: "lint"}, Reporter: config.Reporter{Context: "lint"}}},
+		"org":      {{JobBase: config.JobBase{Name: "license"}, Reporter: config.Reporter{Context: "license"}}},
+	}))
+	cfg.Keeper.Queries = config.KeeperQueries{{Repos: []string{"org/repo"}}}
+	configAgent := &config.Agent{}
+	configAgent.Set(cfg)
+
+	// no plugins are enabled while the comments are handled
+	noPlugins := &plugins.ConfigAgent{}
+	noPlugins.Set(&plugins.Configuration{})
+	s := &Server{
+		ConfigAgent: configAgent,
+		Plugins:     noPlugins,
+		activity:    newActivityTracker(now.Add(-60*24*time.Hour), nil),
+	}
+	s.activity.recordEvent("org/repo", now.Add(-time.Hour))
+	s.activity.recordEvent("org/jobs", now.Add(-40*24*time.Hour))
+	s.activity.recordEvent("other/repo", now)
+	s.handleGenericComment(&event{}, logrus.WithField("test", t.Name()), &scmprovider.GenericCommentEvent{
+		Action: scm.ActionCreate,
+		Body:   "/lgtm\nlooks good\n/lh-retest\n/lgtm cancel",
+		Repo:   scm.Repository{Namespace: "org", Name: "repo"},
+	})
+	s.handleGenericComment(&event{}, logrus.WithField("test", t.Name()), &scmprovider.GenericCommentEvent{
+		Action: scm.ActionEdited,
+		Body:   "/hold",
+		Repo:   scm.Repository{Namespace: "org", Name: "repo"},
+	})
+	s.Plugins = routingPluginAgent()
+
+	report, err := s.AdoptionReport("org", 30, now)
+	require.NoError(t, err)
+	require.Len(t, report.Repositories, 2)
+
+	jobs := report.Repositories[0]
+	assert.Equal(t, "org/jobs", jobs.Repo)
+	assert.Equal(t, []string{"hold"}, jobs.Plugins)
+	assert.Equal(t, []string{"needs-rebase"}, jobs.ExternalPlugins)
+	assert.Equal(t, 2, jobs.Jobs, "the org jobs should be inherited")
+	assert.False(t, jobs.MergeAutomation)
+	assert.True(t, jobs.Stale, "no events in the last 30 days")
+
+	repo := report.Repositories[1]
+	assert.Equal(t, "org/repo", repo.Repo)
+	assert.Equal(t, []string{"hold", "trigger"}, repo.Plugins)
+	assert.Equal(t, map[string]int{"lgtm": 2, "retest": 1}, repo.Commands)
+	assert.Equal(t, 1, repo.Jobs)
+	assert.True(t, repo.MergeAutomation)
+	assert.False(t, repo.Stale)
+
+	assert.Equal(t, map[string]int{"hold": 2, "trigger": 1}, report.Plugins)
+	assert.Equal(t, map[string]int{"lgtm": 2, "retest": 1}, report.Commands)
+	assert.Equal(t, 1, report.MergeAutomation)
+	assert.Equal(t, 1, report.Stale)
+
+	report, err = s.AdoptionReport("", 60, now)
+	require.NoError(t, err)
+	assert.Len(t, report.Repositories, 3)
+	report, err = s.AdoptionReport("org", 60, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Stale)
+}
+
+func TestActivitySavedAcrossReplicas(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	kubeClient := fake.NewSimpleClientset()
+	store := &configMapActivityStore{kubeClient: kubeClient, namespace: "jx"}
+
+	first := newActivityTracker(now.Add(-2*time.Hour), store)
+	first.recordEvent("org/repo", now.Add(-time.Hour))
+	first.recordCommands("org/repo", "/lgtm")
+	require.NoError(t, first.save())
+
+	second := newActivityTracker(now, store)
+	second.recordEvent("org/repo", now.Add(-2*time.Hour))
+	second.recordEvent("org/other", now)
+	second.recordCommands("org/repo", "/lgtm\n/hold")
+	require.NoError(t, second.save())
+	// the pending activity of a replica is reported before it is saved
+	second.recordCommands("org/other", "/retest")
+
+	_, err := kubeClient.CoreV1().ConfigMaps("jx").Get(AdoptionConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	activity, err := newActivityTracker(now, store).snapshot()
+	require.NoError(t, err)
+	assert.True(t, now.Add(-2*time.Hour).Equal(activity.Since))
+	assert.True(t, now.Add(-time.Hour).Equal(activity.LastEvent["org/repo"]), "the latest event should be kept")
+	assert.Equal(t, map[string]int{"lgtm": 2, "hold": 1}, activity.Commands["org/repo"])
+	assert.Nil(t, activity.Commands["org/other"])
+
+	activity, err = second.snapshot()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"retest": 1}, activity.Commands["org/other"])
+}
+
+func TestAdoptionEndpoint(t *testing.T) {
+	o := &Options{server: &Server{Plugins: routingPluginAgent(), activity: newActivityTracker(time.Now(), nil)}}
+
+	rec := httptest.NewRecorder()
+	o.adoption(rec, httptest.NewRequest(http.MethodGet, AdoptionPath+"?stale-days=nope", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	o.adoption(rec, httptest.NewRequest(http.MethodGet, AdoptionPath+"?org=org&stale-days=7", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	report := AdoptionReport{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, 7, report.StaleDays)
+	require.Len(t, report.Repositories, 1)
+	assert.Equal(t, "org/repo", report.Repositories[0].Repo)
+	assert.True(t, report.Repositories[0].Stale)
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HandleCheckRunEvent handles the re-run buttons of the check runs foghorn reports jobs as, by
+// launching a copy of the job the check run was created for
+func (s *Server) HandleCheckRunEvent(l *logrus.Entry, hook *scmprovider.CheckRunHook) {
+	if !hook.Rerun() {
+		return
+	}
+	repo := hook.Repository()
+	l = l.WithFields(logrus.Fields{
+		scmprovider.OrgLogField:  repo.Namespace,
+		scmprovider.RepoLogField: repo.Name,
+	})
+	name := hook.CheckRun.ExternalID
+	if name == "" {
+		l.Info("ignoring re-run of a check run which was not created by lighthouse")
+		return
+	}
+	if s.ClientAgent == nil || s.ClientAgent.LighthouseClient == nil || s.ClientAgent.LauncherClient == nil {
+		l.Warn("cannot re-run the check run as there is no lighthouse client")
+		return
+	}
+	job, err := s.ClientAgent.LighthouseClient.Get(name, metav1.GetOptions{})
+	if err != nil {
+		l.WithError(err).Warnf("failed to find the LighthouseJob %s of the check run", name)
+		return
+	}
+	if refs := job.Spec.Refs; refs == nil || refs.Org != repo.Namespace || refs.Repo != repo.Name {
+		l.Warnf("ignoring re-run of LighthouseJob %s which is not for repository %s", name, repo.FullName)
+		return
+	}
+
+	pj := rerunOf(job)
+	l.WithFields(jobutil.LighthouseJobFields(&pj)).Infof("Re-running LighthouseJob %s of check run %s.", name, hook.CheckRun.Name)
+	if _, err := s.ClientAgent.LauncherClient.Launch(&pj, repo); err != nil {
+		l.WithError(err).Warnf("failed to re-run LighthouseJob %s", name)
+	}
+}
+
+// rerunOf returns a copy of the job to launch again
+func rerunOf(job *v1alpha1.LighthouseJob) v1alpha1.LighthouseJob {
+	labels := make(map[string]string)
+	for k, v := range job.Labels {
+		labels[k] = v
+	}
+	// the copy must not be mistaken for a redelivery of the event which created the job
+	delete(labels, scmprovider.EventGUID)
+	delete(labels, util.IdempotencyKeyLabel)
+	delete(labels, util.CompletedLabel)
+	return jobutil.NewLighthouseJob(job.Spec, labels, job.Annotations)
+}
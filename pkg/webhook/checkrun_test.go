@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	fakelauncher "github.com/jenkins-x/lighthouse/pkg/launcher/fake"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHandleCheckRunEvent(t *testing.T) {
+	job := &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "job-1",
+			Namespace: "jx",
+			Labels: map[string]string{
+				"custom":                 "label",
+				scmprovider.EventGUID:    "guid",
+				util.IdempotencyKeyLabel: "job-1",
+				util.CompletedLabel:      "true",
+			},
+		},
+		Spec: v1alpha1.LighthouseJobSpec{
+			Type: config.PresubmitJob,
+			Job:  "pr-build",
+			Refs: &v1alpha1.Refs{Org: "org", Repo: "repo", BaseRef: "master", Pulls: []v1alpha1.Pull{{Number: 1, SHA: "abc123"}}},
+		},
+	}
+	launcher := fakelauncher.NewLauncher()
+	s := &Server{
+		ClientAgent: &plugins.ClientAgent{
+			LighthouseClient: fake.NewSimpleClientset(job).LighthouseV1alpha1().LighthouseJobs("jx"),
+			LauncherClient:   launcher,
+		},
+	}
+	hook := func(repo, action, externalID, requested string) *scmprovider.CheckRunHook {
+		return &scmprovider.CheckRunHook{
+			CheckRunHook:    &scm.CheckRunHook{Repo: scm.Repository{Namespace: "org", Name: repo, FullName: "org/" + repo}},
+			RawAction:       action,
+			CheckRun:        scmprovider.CheckRun{ID: 7, Name: "pr-build", ExternalID: externalID},
+			RequestedAction: requested,
+		}
+	}
+	l := logrus.WithField("test", t.Name())
+
+	s.HandleCheckRunEvent(l, hook("repo", "completed", "job-1", ""))
+	s.HandleCheckRunEvent(l, hook("repo", scmprovider.CheckRunActionRequestedAction, "job-1", "other"))
+	s.HandleCheckRunEvent(l, hook("repo", scmprovider.CheckRunActionRerequested, "", ""))
+	s.HandleCheckRunEvent(l, hook("repo", scmprovider.CheckRunActionRerequested, "missing", ""))
+	s.HandleCheckRunEvent(l, hook("other", scmprovider.CheckRunActionRerequested, "job-1", ""))
+	assert.Empty(t, launcher.Pipelines)
+
+	s.HandleCheckRunEvent(l, hook("repo", scmprovider.CheckRunActionRequestedAction, "job-1", scmprovider.CheckRunRerunAction))
+	s.HandleCheckRunEvent(l, hook("repo", scmprovider.CheckRunActionRerequested, "job-1", ""))
+	require.Len(t, launcher.Pipelines, 2)
+	rerun := launcher.Pipelines[0]
+	assert.NotEqual(t, "job-1", rerun.Name)
+	assert.Equal(t, job.Spec.Refs, rerun.Spec.Refs)
+	assert.Equal(t, "pr-build", rerun.Spec.Job)
+	assert.Equal(t, "label", rerun.Labels["custom"])
+	assert.Empty(t, rerun.Labels[scmprovider.EventGUID])
+	assert.Empty(t, rerun.Labels[util.IdempotencyKeyLabel])
+	assert.Empty(t, rerun.Labels[util.CompletedLabel])
+}
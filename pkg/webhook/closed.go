@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/launcher/tekton"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// abortJobsOfClosedPullRequest aborts the presubmits of the closed or merged pull request which have not
+// completed yet, including the queued ones, and cancels their PipelineRuns to reclaim the cluster capacity
+func (s *Server) abortJobsOfClosedPullRequest(l *logrus.Entry, repo scm.Repository, number int) {
+	if s.ClientAgent == nil || s.ClientAgent.LighthouseClient == nil {
+		return
+	}
+	selector := labels.SelectorFromSet(labels.Set{
+		config.LighthouseJobTypeLabel: string(config.PresubmitJob),
+		util.OrgLabel:                 strings.ToLower(repo.Namespace),
+		util.RepoLabel:                repo.Name,
+		util.PullLabel:                strconv.Itoa(number),
+	}).String() + "," + util.ActiveJobsSelector
+	list, err := s.ClientAgent.LighthouseClient.List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		l.WithError(err).Warn("failed to list the jobs of the closed pull request")
+		return
+	}
+	for i := range list.Items {
+		job := &list.Items[i]
+		switch job.Status.State {
+		case v1alpha1.SuccessState, v1alpha1.FailureState, v1alpha1.AbortedState:
+			continue
+		}
+		log := l.WithField("LighthouseJob", job.Name)
+		completed := metav1.NewTime(time.Now())
+		job.Status.State = v1alpha1.AbortedState
+		job.Status.CompletionTime = &completed
+		job.Status.Description = jobutil.PullRequestClosedDescription
+		updated, err := s.ClientAgent.LighthouseClient.UpdateStatus(job)
+		if err != nil {
+			log.WithError(err).Warn("failed to abort the job of the closed pull request")
+			continue
+		}
+		cancelled, err := tekton.CancelPipelineRuns(s.TektonClient, updated)
+		for _, name := range cancelled {
+			log.Infof("cancelled PipelineRun %s", name)
+		}
+		if err != nil {
+			log.WithError(err).Warn("failed to cancel the pipeline of the job")
+		}
+		log.Info("aborted the job of the closed pull request")
+	}
+}
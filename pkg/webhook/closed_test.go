@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func pullRequestJob(name string, jobType config.PipelineKind, pull string, state v1alpha1.PipelineState) *v1alpha1.LighthouseJob {
+	return &v1alpha1.LighthouseJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "jx",
+			Labels: map[string]string{
+				config.LighthouseJobTypeLabel: string(jobType),
+				util.OrgLabel:                 "org",
+				util.RepoLabel:                "repo",
+				util.PullLabel:                pull,
+				util.BuildNumLabel:            "2",
+			},
+		},
+		Spec: v1alpha1.LighthouseJobSpec{
+			Type:    jobType,
+			Job:     name,
+			Context: name,
+			Refs:    &v1alpha1.Refs{Org: "org", Repo: "repo", BaseRef: "master", Pulls: []v1alpha1.Pull{{Number: 1, SHA: "abc123"}}},
+		},
+		Status: v1alpha1.LighthouseJobStatus{State: state},
+	}
+}
+
+func TestAbortJobsOfClosedPullRequest(t *testing.T) {
+	jobs := []*v1alpha1.LighthouseJob{
+		pullRequestJob("running", config.PresubmitJob, "1", v1alpha1.RunningState),
+		pullRequestJob("queued", config.PresubmitJob, "1", v1alpha1.QueuedState),
+		pullRequestJob("succeeded", config.PresubmitJob, "1", v1alpha1.SuccessState),
+		pullRequestJob("other-pr", config.PresubmitJob, "2", v1alpha1.RunningState),
+		pullRequestJob("batch", config.BatchJob, "1", v1alpha1.RunningState),
+	}
+	lhClient := fake.NewSimpleClientset()
+	for _, job := range jobs {
+		_, err := lhClient.LighthouseV1alpha1().LighthouseJobs("jx").Create(job)
+		require.NoError(t, err)
+	}
+	run := &pipelinev1alpha1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "org-repo-pr-1-running-2", Namespace: "jx", Labels: map[string]string{
+			util.ActivityOwnerLabel:      "org",
+			util.ActivityRepositoryLabel: "repo",
+			util.ActivityBuildLabel:      "2",
+			util.ActivityContextLabel:    "running",
+		}},
+	}
+	tektonClient := tektonfake.NewSimpleClientset(run)
+	s := &Server{
+		ClientAgent:  &plugins.ClientAgent{LighthouseClient: lhClient.LighthouseV1alpha1().LighthouseJobs("jx")},
+		TektonClient: tektonClient,
+	}
+
+	s.abortJobsOfClosedPullRequest(logrus.WithField("test", t.Name()), scm.Repository{Namespace: "Org", Name: "repo"}, 1)
+
+	expected := map[string]v1alpha1.PipelineState{
+		"running":   v1alpha1.AbortedState,
+		"queued":    v1alpha1.AbortedState,
+		"succeeded": v1alpha1.SuccessState,
+		"other-pr":  v1alpha1.RunningState,
+		"batch":     v1alpha1.RunningState,
+	}
+	for name, state := range expected {
+		job, err := lhClient.LighthouseV1alpha1().LighthouseJobs("jx").Get(name, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, state, job.Status.State, name)
+	}
+	aborted, err := lhClient.LighthouseV1alpha1().LighthouseJobs("jx").Get("running", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotNil(t, aborted.Status.CompletionTime)
+	assert.Equal(t, "Aborted as the pull request was closed", aborted.Status.Description)
+
+	cancelled, err := tektonClient.TektonV1alpha1().PipelineRuns("jx").Get(run.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, pipelinev1alpha1.PipelineRunSpecStatusCancelled, cancelled.Spec.Status)
+}
@@ -0,0 +1,222 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DedupSizeEnvVar is the environment variable containing the number of the most recent webhook delivery
+	// IDs remembered so that the deliveries processed already are skipped. Deduplication is disabled when
+	// it is not set or is 0.
+	DedupSizeEnvVar = "LIGHTHOUSE_DEDUP_SIZE"
+
+	// DedupConfigMapEnvVar is the environment variable containing the name of the ConfigMap the remembered
+	// delivery IDs are saved in, so that they are kept across restarts. They are only kept in memory when
+	// it is not set.
+	DedupConfigMapEnvVar = "LIGHTHOUSE_DEDUP_CONFIGMAP"
+
+	dedupConfigMapKey = "deliveries.json"
+
+	// dedupSaveInterval is how often the remembered delivery IDs are saved when they changed
+	dedupSaveInterval = 10 * time.Second
+)
+
+// dedupStore remembers the IDs of the most recent webhook deliveries, evicting the oldest ones, so that the
+// deliveries the git provider sends again are not processed twice
+type dedupStore struct {
+	lock sync.Mutex
+	size int
+	// ids are the remembered delivery IDs, the oldest first
+	ids []string
+	// processed tells the deliveries processed from the ones being processed
+	processed map[string]bool
+	dirty     bool
+
+	kubeClient kubernetes.Interface
+	namespace  string
+	configMap  string
+}
+
+// newDedupStoreFromEnv returns the store of the number of delivery IDs given by $LIGHTHOUSE_DEDUP_SIZE,
+// loaded from the ConfigMap given by $LIGHTHOUSE_DEDUP_CONFIGMAP if any, or nil if deduplication is disabled
+func newDedupStoreFromEnv(kubeClient kubernetes.Interface, namespace string) (*dedupStore, error) {
+	value := os.Getenv(DedupSizeEnvVar)
+	if value == "" {
+		return nil, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid $%s value %q, it must be a positive number of deliveries", DedupSizeEnvVar, value)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	s := newDedupStore(size)
+	if name := os.Getenv(DedupConfigMapEnvVar); name != "" {
+		s.kubeClient = kubeClient
+		s.namespace = namespace
+		s.configMap = name
+		if err := s.load(); err != nil {
+			logrus.WithError(err).Warnf("failed to load the webhook delivery IDs from ConfigMap %s, starting without them", name)
+		}
+	}
+	return s, nil
+}
+
+func newDedupStore(size int) *dedupStore {
+	return &dedupStore{size: size, processed: map[string]bool{}}
+}
+
+// claim returns true if the delivery has to be processed, remembering it, or false if it was processed
+// already or is being processed. Deliveries without an ID are always processed.
+func (s *dedupStore) claim(id string) bool {
+	if s == nil || id == "" {
+		return true
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.processed[id]; ok {
+		return false
+	}
+	if len(s.ids) >= s.size {
+		evicted := len(s.ids) - s.size + 1
+		for _, old := range s.ids[:evicted] {
+			delete(s.processed, old)
+		}
+		s.ids = append([]string{}, s.ids[evicted:]...)
+	}
+	s.ids = append(s.ids, id)
+	s.processed[id] = false
+	return true
+}
+
+// done marks the claimed delivery as processed if it succeeded, otherwise it is forgotten so that the git
+// provider can deliver it again
+func (s *dedupStore) done(id string, succeeded bool) {
+	if s == nil || id == "" {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.processed[id]; !ok {
+		return
+	}
+	if succeeded {
+		s.processed[id] = true
+		s.dirty = true
+		return
+	}
+	delete(s.processed, id)
+	for i, existing := range s.ids {
+		if existing == id {
+			s.ids = append(s.ids[:i], s.ids[i+1:]...)
+			break
+		}
+	}
+}
+
+// run saves the processed delivery IDs in the ConfigMap periodically until the stop channel is closed
+func (s *dedupStore) run(stop <-chan struct{}) {
+	if s == nil || s.configMap == "" {
+		return
+	}
+	ticker := time.NewTicker(dedupSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			s.save()
+			return
+		case <-ticker.C:
+			s.save()
+		}
+	}
+}
+
+// load reads the processed delivery IDs from the ConfigMap, which does not exist until they are first saved
+func (s *dedupStore) load() error {
+	cm, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(s.configMap, metav1.GetOptions{})
+	if kubeerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get ConfigMap %s", s.configMap)
+	}
+	var ids []string
+	if data := cm.Data[dedupConfigMapKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &ids); err != nil {
+			return errors.Wrapf(err, "failed to parse ConfigMap %s", s.configMap)
+		}
+	}
+	if len(ids) > s.size {
+		ids = ids[len(ids)-s.size:]
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, id := range ids {
+		s.ids = append(s.ids, id)
+		s.processed[id] = true
+	}
+	return nil
+}
+
+// save writes the processed delivery IDs to the ConfigMap if they changed since they were last saved
+func (s *dedupStore) save() {
+	s.lock.Lock()
+	if !s.dirty {
+		s.lock.Unlock()
+		return
+	}
+	ids := make([]string, 0, len(s.ids))
+	for _, id := range s.ids {
+		if s.processed[id] {
+			ids = append(ids, id)
+		}
+	}
+	s.dirty = false
+	s.lock.Unlock()
+
+	if err := s.saveIDs(ids); err != nil {
+		logrus.WithError(err).Warn("failed to save the webhook delivery IDs")
+		s.lock.Lock()
+		s.dirty = true
+		s.lock.Unlock()
+	}
+}
+
+func (s *dedupStore) saveIDs(ids []string) error {
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the delivery IDs")
+	}
+	configMaps := s.kubeClient.CoreV1().ConfigMaps(s.namespace)
+	cm, err := configMaps.Get(s.configMap, metav1.GetOptions{})
+	if kubeerrors.IsNotFound(err) {
+		_, err = configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: s.configMap},
+			Data:       map[string]string{dedupConfigMapKey: string(data)},
+		})
+		return errors.Wrapf(err, "failed to create ConfigMap %s", s.configMap)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get ConfigMap %s", s.configMap)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[dedupConfigMapKey] = string(data)
+	_, err = configMaps.Update(cm)
+	return errors.Wrapf(err, "failed to update ConfigMap %s", s.configMap)
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDedupStore(t *testing.T) {
+	s := newDedupStore(2)
+	assert.True(t, s.claim("a"))
+	assert.False(t, s.claim("a"), "the delivery is being processed")
+	s.done("a", true)
+	assert.False(t, s.claim("a"), "the delivery was processed")
+
+	assert.True(t, s.claim("b"))
+	s.done("b", false)
+	assert.True(t, s.claim("b"), "the failed delivery can be delivered again")
+	s.done("b", true)
+
+	assert.True(t, s.claim("c"))
+	s.done("c", true)
+	assert.True(t, s.claim("a"), "the oldest delivery is evicted")
+	assert.False(t, s.claim("c"))
+
+	assert.True(t, s.claim(""), "deliveries without an ID are always processed")
+	assert.True(t, s.claim(""))
+
+	var disabled *dedupStore
+	assert.True(t, disabled.claim("a"))
+	assert.True(t, disabled.claim("a"))
+	disabled.done("a", true)
+}
+
+func TestDedupStoreConfigMap(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	s := newDedupStore(10)
+	s.kubeClient = kubeClient
+	s.namespace = "jx"
+	s.configMap = "lighthouse-webhook-deliveries"
+	for _, id := range []string{"a", "b", "c"} {
+		require.True(t, s.claim(id))
+	}
+	s.done("a", true)
+	s.done("c", true)
+	s.save()
+
+	cm, err := kubeClient.CoreV1().ConfigMaps("jx").Get("lighthouse-webhook-deliveries", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `["a", "c"]`, cm.Data[dedupConfigMapKey], "only the processed deliveries are saved")
+
+	restarted := newDedupStore(1)
+	restarted.kubeClient = kubeClient
+	restarted.namespace = "jx"
+	restarted.configMap = "lighthouse-webhook-deliveries"
+	require.NoError(t, restarted.load())
+	assert.False(t, restarted.claim("c"), "the deliveries processed before the restart are skipped")
+	assert.True(t, restarted.claim("a"), "the most recent deliveries which fit are loaded")
+}
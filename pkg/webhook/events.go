@@ -17,6 +17,8 @@ limitations under the License.
 package webhook
 
 import (
+	"context"
+	"fmt"
 	"net/url"
 	"strconv"
 	"sync"
@@ -26,7 +28,9 @@ import (
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
 	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"github.com/sirupsen/logrus"
+	tektonclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
 )
 
 // Server keeps the information required to start a server
@@ -36,15 +40,91 @@ type Server struct {
 	Plugins        *plugins.ConfigAgent
 	ConfigAgent    *config.Agent
 	ServerURL      *url.URL
+	Namespace      string
 	TokenGenerator func() []byte
 	Metrics        *Metrics
+	// Settings returns the lighthouse settings, which configure the deadline of the events
+	Settings settings.Getter
+	// TektonClient cancels the PipelineRuns of the jobs of the closed pull requests, if set
+	TektonClient tektonclient.Interface
+
+	// activity records the events and commands of the repositories for the adoption report
+	activity *activityTracker
 
 	// Tracks running handlers for graceful shutdown
 	wg sync.WaitGroup
 }
 
+// pluginMiddlewares are applied to every plugin handler invocation before any
+// middleware registered with plugins.RegisterMiddleware
+var pluginMiddlewares = []plugins.Middleware{
+	plugins.RecoverMiddleware,
+	instrumentPlugin,
+	plugins.AuditMiddleware,
+	plugins.ShadowMiddleware,
+}
+
+// event is the handling of a webhook event by all its plugins, which share the deadline of the event
+// and its configuration
+type event struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	handlers sync.WaitGroup
+
+	// config is the configuration merged with the jobs of the repository, nil for the central configuration
+	config       *config.Config
+	configLoaded bool
+}
+
+// startEvent starts the handling of an event, whose deadline is taken from the lighthouse settings
+func (s *Server) startEvent() *event {
+	e := &event{}
+	if s.Settings == nil {
+		return e
+	}
+	if deadline := s.Settings().Webhooks.GetEventDeadline(); deadline > 0 {
+		e.ctx, e.cancel = context.WithTimeout(context.Background(), deadline)
+	}
+	return e
+}
+
+// end releases the deadline of the event once all its plugin handlers have returned
+func (e *event) end() {
+	if e.cancel == nil {
+		return
+	}
+	go func() {
+		e.handlers.Wait()
+		e.cancel()
+	}()
+}
+
 const failedCommentCoerceFmt = "Could not coerce %s event to a GenericCommentEvent. Unknown 'action': %q."
 
+// invokePlugin invokes a plugin handler of the event through the plugin middlewares, logging any error
+func (s *Server) invokePlugin(e *event, agent *plugins.Agent, plugin, event, org, repo string, handle func() error) {
+	inv := plugins.Invocation{
+		Plugin: plugin,
+		Event:  event,
+		Org:    org,
+		Repo:   repo,
+		Agent:  agent,
+	}
+	if e.ctx != nil {
+		agent.SetContext(scmprovider.WithAuditReason(e.ctx, fmt.Sprintf("plugin %s handling the %s event", plugin, event)))
+		inv.Context = e.ctx
+	}
+	if e.config != nil {
+		agent.Config = e.config
+	}
+	err := plugins.Invoke(inv, func(plugins.Invocation) error {
+		return handle()
+	}, pluginMiddlewares...)
+	if err != nil {
+		agent.Logger.WithError(err).Errorf("Error handling %s.", event)
+	}
+}
+
 // HandleIssueCommentEvent handle comment events
 func (s *Server) HandleIssueCommentEvent(l *logrus.Entry, ic scm.IssueCommentHook) {
 	l = l.WithFields(logrus.Fields{
@@ -55,23 +135,28 @@ func (s *Server) HandleIssueCommentEvent(l *logrus.Entry, ic scm.IssueCommentHoo
 		"url":                    ic.Comment.Link,
 	})
 	l.Infof("Issue comment %s.", ic.Action)
+	e := s.startEvent()
+	defer e.end()
 	for p, h := range s.Plugins.IssueCommentHandlers(ic.Repo.Namespace, ic.Repo.Name) {
 		s.wg.Add(1)
+		e.handlers.Add(1)
 		go func(p string, h plugins.IssueCommentHandler) {
 			defer s.wg.Done()
+			defer e.handlers.Done()
 			agent := plugins.NewAgent(s.ClientFactory, s.ConfigAgent, s.Plugins, s.ClientAgent, s.ServerURL, l.WithField("plugin", p))
 			agent.InitializeCommentPruner(
 				ic.Repo.Namespace,
 				ic.Repo.Name,
 				ic.Issue.Number,
 			)
-			if err := h(agent, ic); err != nil {
-				agent.Logger.WithError(err).Error("Error handling IssueCommentEvent.")
-			}
+			s.invokePlugin(e, &agent, p, plugins.IssueCommentEvent, ic.Repo.Namespace, ic.Repo.Name, func() error {
+				return h(agent, ic)
+			})
 		}(p, h)
 	}
 
 	s.handleGenericComment(
+		e,
 		l,
 		&scmprovider.GenericCommentEvent{
 			GUID:        strconv.Itoa(ic.Comment.ID),
@@ -101,8 +186,11 @@ func (s *Server) HandlePullRequestCommentEvent(l *logrus.Entry, pc scm.PullReque
 		"url":                    pc.Comment.Link,
 	})
 	l.Infof("PR comment %s.", pc.Action)
+	e := s.startEvent()
+	defer e.end()
 
 	s.handleGenericComment(
+		e,
 		l,
 		&scmprovider.GenericCommentEvent{
 			GUID:        strconv.Itoa(pc.Comment.ID),
@@ -122,24 +210,53 @@ func (s *Server) HandlePullRequestCommentEvent(l *logrus.Entry, pc scm.PullReque
 	)
 }
 
-func (s *Server) handleGenericComment(l *logrus.Entry, ce *scmprovider.GenericCommentEvent) {
+func (s *Server) handleGenericComment(e *event, l *logrus.Entry, ce *scmprovider.GenericCommentEvent) {
+	if ce.Action == scm.ActionCreate {
+		s.activity.recordCommands(scm.Join(ce.Repo.Namespace, ce.Repo.Name), ce.Body)
+	}
+	if ce.IsPR && ce.Action == scm.ActionCreate {
+		s.loadInRepoConfigOfPR(e, l, ce.Repo.Namespace, ce.Repo.Name, ce.Number)
+	}
 	for p, h := range s.Plugins.GenericCommentHandlers(ce.Repo.Namespace, ce.Repo.Name) {
 		s.wg.Add(1)
+		e.handlers.Add(1)
 		go func(p string, h plugins.GenericCommentHandler) {
 			defer s.wg.Done()
+			defer e.handlers.Done()
 			agent := plugins.NewAgent(s.ClientFactory, s.ConfigAgent, s.Plugins, s.ClientAgent, s.ServerURL, l.WithField("plugin", p))
 			agent.InitializeCommentPruner(
 				ce.Repo.Namespace,
 				ce.Repo.Name,
 				ce.Number,
 			)
-			if err := h(agent, *ce); err != nil {
-				agent.Logger.WithError(err).Error("Error handling GenericCommentEvent.")
-			}
+			s.invokePlugin(e, &agent, p, plugins.GenericCommentEvent, ce.Repo.Namespace, ce.Repo.Name, func() error {
+				return replyToFailedPrecondition(&agent, ce, h(agent, *ce))
+			})
 		}(p, h)
 	}
 }
 
+// replyToFailedPrecondition replies to the commands of the comment which cannot run because a precondition
+// failed with a message telling the requirement, so that they are not silently ignored
+func replyToFailedPrecondition(agent *plugins.Agent, ce *scmprovider.GenericCommentEvent, err error) error {
+	failed, ok := plugins.AsPreconditionError(err)
+	if !ok {
+		return err
+	}
+	l := agent.Logger.WithFields(logrus.Fields{"command": failed.Command, "severity": failed.Severity})
+	if failed.Severity == plugins.SeverityInfo {
+		l.Info(failed.Error())
+	} else {
+		l.Warn(failed.Error())
+	}
+	spc := agent.SCMProviderClient
+	if spc == nil {
+		return nil
+	}
+	resp := plugins.FormatResponseRaw(ce.Body, ce.Link, spc.QuoteAuthorForComment(ce.Author.Login), failed.Message())
+	return spc.CreateComment(ce.Repo.Namespace, ce.Repo.Name, ce.Number, ce.IsPR, resp)
+}
+
 // HandlePushEvent handles a push event
 func (s *Server) HandlePushEvent(l *logrus.Entry, pe *scm.PushHook) {
 	repo := pe.Repository()
@@ -150,21 +267,56 @@ func (s *Server) HandlePushEvent(l *logrus.Entry, pe *scm.PushHook) {
 		"head":                   pe.After,
 	})
 	l.Info("Push event.")
+	e := s.startEvent()
+	defer e.end()
+	if !pe.Deleted {
+		s.loadInRepoConfig(e, l, repo.Namespace, repo.Name, pe.After)
+	}
 	c := 0
 	for p, h := range s.Plugins.PushEventHandlers(repo.Namespace, repo.Name) {
 		s.wg.Add(1)
+		e.handlers.Add(1)
 		c++
 		go func(p string, h plugins.PushEventHandler) {
 			defer s.wg.Done()
+			defer e.handlers.Done()
 			agent := plugins.NewAgent(s.ClientFactory, s.ConfigAgent, s.Plugins, s.ClientAgent, s.ServerURL, l.WithField("plugin", p))
-			if err := h(agent, *pe); err != nil {
-				agent.Logger.WithError(err).Error("Error handling PushEvent.")
-			}
+			s.invokePlugin(e, &agent, p, plugins.PushEvent, repo.Namespace, repo.Name, func() error {
+				return h(agent, *pe)
+			})
 		}(p, h)
 	}
 	l.WithField("count", strconv.Itoa(c)).Info("number of push handlers")
 }
 
+// HandleReleaseEvent handles a release event
+func (s *Server) HandleReleaseEvent(l *logrus.Entry, re *scmprovider.ReleaseHook) {
+	repo := re.Repository()
+	l = l.WithFields(logrus.Fields{
+		scmprovider.OrgLogField:  repo.Namespace,
+		scmprovider.RepoLogField: repo.Name,
+		"tag":                    re.Release.Tag,
+	})
+	l.Infof("Release %s.", re.RawAction)
+	e := s.startEvent()
+	defer e.end()
+	c := 0
+	for p, h := range s.Plugins.ReleaseEventHandlers(repo.Namespace, repo.Name) {
+		s.wg.Add(1)
+		e.handlers.Add(1)
+		c++
+		go func(p string, h plugins.ReleaseEventHandler) {
+			defer s.wg.Done()
+			defer e.handlers.Done()
+			agent := plugins.NewAgent(s.ClientFactory, s.ConfigAgent, s.Plugins, s.ClientAgent, s.ServerURL, l.WithField("plugin", p))
+			s.invokePlugin(e, &agent, p, plugins.ReleaseEvent, repo.Namespace, repo.Name, func() error {
+				return h(agent, *re)
+			})
+		}(p, h)
+	}
+	l.WithField("count", strconv.Itoa(c)).Info("number of release handlers")
+}
+
 // HandlePullRequestEvent handles a pull request event
 func (s *Server) HandlePullRequestEvent(l *logrus.Entry, pr *scm.PullRequestHook) {
 	l = l.WithFields(logrus.Fields{
@@ -176,25 +328,33 @@ func (s *Server) HandlePullRequestEvent(l *logrus.Entry, pr *scm.PullRequestHook
 	})
 	action := pr.Action
 	l.Infof("Pull request %s.", action)
+	e := s.startEvent()
+	defer e.end()
 	c := 0
 	repo := pr.PullRequest.Base.Repo
 	if repo.Name == "" {
 		repo = pr.Repo
 	}
+	if action == scm.ActionClose {
+		s.abortJobsOfClosedPullRequest(l, repo, pr.PullRequest.Number)
+	}
+	s.loadInRepoConfig(e, l, repo.Namespace, repo.Name, pr.PullRequest.Sha)
 	for p, h := range s.Plugins.PullRequestHandlers(repo.Namespace, repo.Name) {
 		s.wg.Add(1)
+		e.handlers.Add(1)
 		c++
 		go func(p string, h plugins.PullRequestHandler) {
 			defer s.wg.Done()
+			defer e.handlers.Done()
 			agent := plugins.NewAgent(s.ClientFactory, s.ConfigAgent, s.Plugins, s.ClientAgent, s.ServerURL, l.WithField("plugin", p))
 			agent.InitializeCommentPruner(
 				pr.Repo.Namespace,
 				pr.Repo.Name,
 				pr.PullRequest.Number,
 			)
-			if err := h(agent, *pr); err != nil {
-				agent.Logger.WithError(err).Error("Error handling PullRequestEvent.")
-			}
+			s.invokePlugin(e, &agent, p, plugins.PullRequestEvent, repo.Namespace, repo.Name, func() error {
+				return h(agent, *pr)
+			})
 		}(p, h)
 	}
 	l.WithField("count", strconv.Itoa(c)).Info("number of PR handlers")
@@ -203,6 +363,7 @@ func (s *Server) HandlePullRequestEvent(l *logrus.Entry, pr *scm.PullRequestHook
 		return
 	}
 	s.handleGenericComment(
+		e,
 		l,
 		&scmprovider.GenericCommentEvent{
 			GUID:        pr.GUID,
@@ -238,19 +399,23 @@ func (s *Server) HandleReviewEvent(l *logrus.Entry, re scm.ReviewHook) {
 		"url":                    re.Review.Link,
 	})
 	l.Infof("Review %s.", re.Action)
+	e := s.startEvent()
+	defer e.end()
 	for p, h := range s.Plugins.ReviewEventHandlers(re.PullRequest.Base.Repo.Namespace, re.PullRequest.Base.Repo.Name) {
 		s.wg.Add(1)
+		e.handlers.Add(1)
 		go func(p string, h plugins.ReviewEventHandler) {
 			defer s.wg.Done()
+			defer e.handlers.Done()
 			agent := plugins.NewAgent(s.ClientFactory, s.ConfigAgent, s.Plugins, s.ClientAgent, s.ServerURL, l.WithField("plugin", p))
 			agent.InitializeCommentPruner(
 				re.Repo.Namespace,
 				re.Repo.Name,
 				re.PullRequest.Number,
 			)
-			if err := h(agent, re); err != nil {
-				agent.Logger.WithError(err).Error("Error handling ReviewEvent.")
-			}
+			s.invokePlugin(e, &agent, p, plugins.ReviewEvent, re.PullRequest.Base.Repo.Namespace, re.PullRequest.Base.Repo.Name, func() error {
+				return h(agent, re)
+			})
 		}(p, h)
 	}
 	action := re.Action
@@ -258,6 +423,7 @@ func (s *Server) HandleReviewEvent(l *logrus.Entry, re scm.ReviewHook) {
 		return
 	}
 	s.handleGenericComment(
+		e,
 		l,
 		&scmprovider.GenericCommentEvent{
 			GUID:        re.GUID,
@@ -278,11 +444,13 @@ func (s *Server) HandleReviewEvent(l *logrus.Entry, re scm.ReviewHook) {
 }
 
 func actionRelatesToPullRequestComment(action scm.Action, l *logrus.Entry) bool {
+	for _, a := range pullRequestCommentActions {
+		if action == a {
+			return true
+		}
+	}
 	switch action {
 
-	case scm.ActionCreate, scm.ActionOpen, scm.ActionSubmitted, scm.ActionEdited, scm.ActionDelete, scm.ActionDismissed, scm.ActionUpdate:
-		return true
-
 	case scm.ActionAssigned,
 		scm.ActionUnassigned,
 		scm.ActionReviewRequested,
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEventDeadline(t *testing.T) {
+	s := &Server{}
+	e := s.startEvent()
+	assert.Nil(t, e.ctx, "events have no deadline without settings")
+	e.end()
+
+	cfg := &settings.Config{Webhooks: settings.Webhooks{EventDeadline: &metav1.Duration{Duration: time.Hour}}}
+	s.Settings = func() *settings.Config { return cfg }
+	e = s.startEvent()
+	require.NotNil(t, e.ctx)
+	deadline, ok := e.ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+
+	// the deadline is shared by the handlers of the event until the last one returns
+	e.handlers.Add(2)
+	e.end()
+	e.handlers.Done()
+	assert.NoError(t, e.ctx.Err())
+	e.handlers.Done()
+	select {
+	case <-e.ctx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("the event context was not released once its handlers returned")
+	}
+}
+
+func TestReplyToFailedPrecondition(t *testing.T) {
+	fakeScmClient, data := fake.NewDefault()
+	agent := &plugins.Agent{
+		Logger:            logrus.WithField("test", t.Name()),
+		SCMProviderClient: &scmprovider.ToTestClient(fakeScmClient).Client,
+	}
+	ce := &scmprovider.GenericCommentEvent{
+		Repo:   scm.Repository{Namespace: "org", Name: "repo"},
+		Number: 1,
+		IsPR:   true,
+		Body:   "/approve",
+		Link:   "https://github.com/org/repo/pull/1#comment",
+		Author: scm.User{Login: "user"},
+	}
+
+	assert.NoError(t, replyToFailedPrecondition(agent, ce, nil))
+	err := errors.New("failed to get the pull request")
+	assert.Equal(t, err, replyToFailedPrecondition(agent, ce, err))
+	assert.Empty(t, data.PullRequestComments[1])
+
+	failed := plugins.NewPreconditionError(plugins.SeverityError, "/approve", "only the approvers can approve the pull request")
+	require.NoError(t, replyToFailedPrecondition(agent, ce, errors.Wrap(failed, "failed to handle the comment")))
+	require.Len(t, data.PullRequestComments[1], 1)
+	body := data.PullRequestComments[1][0].Body
+	assert.Contains(t, body, ":no_entry: **Error**: cannot run `/approve`, only the approvers can approve the pull request.")
+	assert.Contains(t, body, plugins.CommandsDocsURL)
+	assert.Contains(t, body, ">/approve", "the command is quoted")
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/cmd/gitcredentials"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// GitCredentialsPath is the URL path for the HTTP endpoint that returns GitHub App tokens to the
+	// git credential helper of pipeline pods. The ServiceAccounts allowed to fetch them are configured
+	// in the gitCredentials section of config.yaml.
+	GitCredentialsPath = "/git-credentials"
+
+	serviceAccountUserPrefix = "system:serviceaccount:"
+)
+
+// gitCredentialsHandler returns a GitHub App installation token scoped to a repository of the git server to
+// pods authenticated with the projected token of a ServiceAccount bound to the repository
+type gitCredentialsHandler struct {
+	kubeClient kubernetes.Interface
+	gitHost    string
+	settings   settings.Getter
+	findToken  func(owner, repo string, permissions map[string]string) (string, error)
+}
+
+// newGitCredentialsHandler returns the git credentials handler, or nil if the endpoint is disabled
+func (o *Options) newGitCredentialsHandler() (*gitCredentialsHandler, error) {
+	ghaSecretDir := util.GetGitHubAppSecretDir()
+	if ghaSecretDir == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(o.gitServerURL)
+	if err != nil {
+		return nil, err
+	}
+	kubeClient, _, err := o.GetFactory().CreateKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	return &gitCredentialsHandler{
+		kubeClient: kubeClient,
+		gitHost:    u.Host,
+		settings:   o.settingsAgent.Config,
+		findToken:  util.GetGitHubAppTokenManager(o.gitServerURL, ghaSecretDir).FindRepositoryToken,
+	}, nil
+}
+
+// serviceAccount returns the namespace and name of the ServiceAccount of the user, if it is one
+func serviceAccount(username string) (string, string, bool) {
+	if !strings.HasPrefix(username, serviceAccountUserPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(username, serviceAccountUserPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func (h *gitCredentialsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if token == "" {
+		http.Error(w, "a ServiceAccount bearer token is required", http.StatusUnauthorized)
+		return
+	}
+	cfg := h.settings().GitCredentials
+	audience := cfg.GetAudience()
+	review, err := h.kubeClient.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: []string{audience},
+		},
+	})
+	if err != nil {
+		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: failed to review token: %s", err.Error()))
+		return
+	}
+	if !review.Status.Authenticated || !hasAudience(review.Status.Audiences, audience) {
+		http.Error(w, fmt.Sprintf("invalid token, a projected ServiceAccount token with the audience %s is required", audience), http.StatusUnauthorized)
+		return
+	}
+	username := review.Status.User.Username
+	l := logrus.WithField("user", username)
+	host := r.URL.Query().Get("host")
+	if !strings.EqualFold(host, h.gitHost) {
+		l.WithField("host", host).Warn("refusing git credentials for a host other than the git server")
+		http.Error(w, fmt.Sprintf("git credentials are only served for %s", h.gitHost), http.StatusForbidden)
+		return
+	}
+	repo := strings.Trim(r.URL.Query().Get("repository"), "/")
+	if len(strings.Split(repo, "/")) != 2 {
+		http.Error(w, "the repository query parameter is required as org/repo", http.StatusBadRequest)
+		return
+	}
+	namespace, name, ok := serviceAccount(username)
+	if !ok || !cfg.Allows(namespace, name, repo) {
+		l.WithField("repository", repo).Warn("refusing git credentials to a user which is not a ServiceAccount bound to the repository")
+		http.Error(w, fmt.Sprintf("%s is not allowed to fetch the git credentials of %s", username, repo), http.StatusForbidden)
+		return
+	}
+	parts := strings.Split(repo, "/")
+	// the token only grants access to the bound repository, not to the other repositories of the installation
+	password, err := h.findToken(parts[0], parts[1], cfg.GetPermissions())
+	if err != nil {
+		l.WithError(err).Warnf("no git credentials for repository %s", repo)
+		http.Error(w, fmt.Sprintf("no git credentials for repository %s", repo), http.StatusNotFound)
+		return
+	}
+	l.WithField("repository", repo).Info("serving git credentials")
+	credential := &gitcredentials.Credential{
+		Username: util.GitHubAppGitRemoteUsername,
+		Password: password,
+	}
+	if _, err := w.Write([]byte(credential.String())); err != nil {
+		l.WithError(err).Debug("failed to write the git credentials")
+	}
+}
+
+func hasAudience(audiences []string, audience string) bool {
+	for _, a := range audiences {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
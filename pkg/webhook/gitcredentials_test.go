@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestGitCredentialsHandler(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "tokenreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		users := map[string]string{
+			"pipeline-token": "system:serviceaccount:jx:tekton-bot",
+			"build-token":    "system:serviceaccount:build:anything",
+			"other-token":    "system:serviceaccount:other:default",
+			"user-token":     "someone",
+		}
+		if user, ok := users[review.Spec.Token]; ok {
+			review.Status.Authenticated = true
+			review.Status.User.Username = user
+			review.Status.Audiences = review.Spec.Audiences
+		}
+		if review.Spec.Token == "default-audience-token" {
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:jx:tekton-bot"
+		}
+		return true, review, nil
+	})
+	cfg := &settings.Config{
+		GitCredentials: settings.GitCredentials{
+			ServiceAccounts: []settings.GitCredentialsServiceAccount{
+				{Namespace: "jx", Name: "tekton-bot", Repos: []string{"my-org/my-repo", "other-org"}},
+				{Namespace: "build", Repos: []string{"my-org/build"}},
+			},
+		},
+	}
+	h := &gitCredentialsHandler{
+		kubeClient: kubeClient,
+		gitHost:    "github.com",
+		settings:   func() *settings.Config { return cfg },
+		findToken: func(owner, repo string, permissions map[string]string) (string, error) {
+			assert.Equal(t, map[string]string{"contents": "write"}, permissions)
+			if owner == "my-org" {
+				return "token-of-" + owner + "/" + repo, nil
+			}
+			return "", errors.Errorf("no token for %s", owner)
+		},
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		host   string
+		repo   string
+		status int
+		body   string
+	}{
+		{name: "allowed", token: "pipeline-token", repo: "my-org/my-repo", status: http.StatusOK, body: "username=x-access-token\npassword=token-of-my-org/my-repo\n"},
+		{name: "namespace", token: "build-token", repo: "my-org/build", status: http.StatusOK, body: "username=x-access-token\npassword=token-of-my-org/build\n"},
+		{name: "no token", repo: "my-org/my-repo", status: http.StatusUnauthorized},
+		{name: "invalid token", token: "invalid", repo: "my-org/my-repo", status: http.StatusUnauthorized},
+		{name: "other audience", token: "default-audience-token", repo: "my-org/my-repo", status: http.StatusUnauthorized},
+		{name: "other host", token: "pipeline-token", host: "evil.example.com", repo: "my-org/my-repo", status: http.StatusForbidden},
+		{name: "other repository", token: "pipeline-token", repo: "my-org/other", status: http.StatusForbidden},
+		{name: "repository of another ServiceAccount", token: "build-token", repo: "my-org/my-repo", status: http.StatusForbidden},
+		{name: "other ServiceAccount", token: "other-token", repo: "my-org/my-repo", status: http.StatusForbidden},
+		{name: "not a ServiceAccount", token: "user-token", repo: "my-org/my-repo", status: http.StatusForbidden},
+		{name: "no repository", token: "pipeline-token", status: http.StatusBadRequest},
+		{name: "unknown owner", token: "pipeline-token", repo: "other-org/repo", status: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			host := tc.host
+			if host == "" {
+				host = "github.com"
+			}
+			r := httptest.NewRequest(http.MethodGet, GitCredentialsPath+"?host="+host+"&repository="+tc.repo, nil)
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+			if tc.body != "" {
+				assert.Equal(t, tc.body, w.Body.String())
+			}
+		})
+	}
+}
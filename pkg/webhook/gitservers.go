@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/factory"
+	"github.com/jenkins-x/lighthouse/pkg/git"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GitServerHeader is the request header naming the git server of the gitServers settings which delivered
+	// a webhook. The webhooks without it are delivered by the server of $GIT_SERVER, unless they name their
+	// server with the server query parameter or are delivered by GitHub Enterprise or GitLab with the host
+	// of one of the servers.
+	GitServerHeader = "X-Lighthouse-Git-Server"
+
+	// gitServerParam is the query parameter of the webhook URLs naming their git server
+	gitServerParam = "server"
+)
+
+// unknownGitServerError is returned for the webhooks naming a git server which is not configured
+type unknownGitServerError struct {
+	name string
+}
+
+func (e *unknownGitServerError) Error() string {
+	return "unknown git server " + e.name
+}
+
+// gitServerOf returns the configured git server which delivered the webhook, or nil if it is the default
+// server. The name of the server is recorded in the GitServerHeader so that the server of the webhook is
+// known when it is queued or replayed. As the sender chooses the server, the webhook must be signed with
+// the HMAC token of the server, see hmacTokenFor.
+func (o *Options) gitServerOf(r *http.Request) (*settings.GitServer, error) {
+	servers := o.settingsAgent.Config().GitServers
+	name := r.Header.Get(GitServerHeader)
+	if name == "" {
+		name = r.URL.Query().Get(gitServerParam)
+	}
+	if name != "" {
+		server := servers.Named(name)
+		if server == nil {
+			return nil, &unknownGitServerError{name: name}
+		}
+		r.Header.Set(GitServerHeader, name)
+		return server, nil
+	}
+	var server *settings.GitServer
+	if host := r.Header.Get("X-GitHub-Enterprise-Host"); host != "" {
+		server = servers.ForHost(host)
+	} else if instance := r.Header.Get("X-Gitlab-Instance"); instance != "" {
+		server = servers.ForURL(instance)
+	}
+	if server != nil {
+		r.Header.Set(GitServerHeader, server.Name)
+	}
+	return server, nil
+}
+
+// createSCMClientFor creates the client of the git server, or of the default server if it is nil
+func (o *Options) createSCMClientFor(server *settings.GitServer) (*scm.Client, string, error) {
+	if server == nil {
+		return o.createSCMClient()
+	}
+	client, err := factory.NewClient(server.GetKind(), server.URL, "")
+	return client, server.URL, err
+}
+
+// hmacTokenFor returns the HMAC token of the webhooks of the git server, or of the default server if it is nil
+func (o *Options) hmacTokenFor(server *settings.GitServer) string {
+	if server == nil {
+		return o.hmacToken()
+	}
+	return server.HMACToken()
+}
+
+// gitServerBotName returns the login of the bot on the git server, or on the default server if it is nil
+func (o *Options) gitServerBotName(server *settings.GitServer) string {
+	if server == nil || server.User == "" {
+		return o.GetBotName()
+	}
+	return server.User
+}
+
+// gitClients are the git clients of the configured git servers, created when the first webhook of
+// each server is delivered
+type gitClients struct {
+	lock    sync.Mutex
+	clients map[string]git.Client
+}
+
+// gitClientFor returns the git client of the git server, or the client of the default server if it is nil
+func (o *Options) gitClientFor(server *settings.GitServer) (git.Client, error) {
+	if server == nil {
+		return o.gitClient, nil
+	}
+	o.gitClients.lock.Lock()
+	defer o.gitClients.lock.Unlock()
+	key := server.Name + "\n" + server.URL
+	if client := o.gitClients.clients[key]; client != nil {
+		return client, nil
+	}
+	client, err := git.NewClient(server.URL, server.GetKind())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the git client of git server %s", server.Name)
+	}
+	if o.gitClients.clients == nil {
+		o.gitClients.clients = map[string]git.Client{}
+	}
+	o.gitClients.clients[key] = client
+	return client, nil
+}
+
+// cleanGitClients removes the clones of the git clients of the configured git servers
+func (o *Options) cleanGitClients() error {
+	o.gitClients.lock.Lock()
+	defer o.gitClients.lock.Unlock()
+	for key, client := range o.gitClients.clients {
+		if err := client.Clean(); err != nil {
+			return err
+		}
+		delete(o.gitClients.clients, key)
+	}
+	return nil
+}
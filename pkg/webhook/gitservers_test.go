@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitServerOf(t *testing.T) {
+	agent := &settings.Agent{}
+	agent.Set(&settings.Config{GitServers: settings.GitServers{
+		{Name: "ghe", URL: "https://github.example.com", TokenEnv: "GHE_TOKEN", HMACTokenEnv: "GHE_HMAC_TOKEN"},
+		{Name: "gitlab", URL: "https://gitlab.example.com", Kind: "gitlab", TokenEnv: "GITLAB_TOKEN"},
+	}})
+	o := &Options{settingsAgent: agent}
+
+	r := httptest.NewRequest(http.MethodPost, "/hook", nil)
+	server, err := o.gitServerOf(r)
+	require.NoError(t, err)
+	assert.Nil(t, server, "the default server")
+	assert.Empty(t, r.Header.Get(GitServerHeader))
+
+	r = httptest.NewRequest(http.MethodPost, "/hook?server=gitlab", nil)
+	server, err = o.gitServerOf(r)
+	require.NoError(t, err)
+	require.NotNil(t, server)
+	assert.Equal(t, "gitlab", server.Name)
+	assert.Equal(t, "gitlab", r.Header.Get(GitServerHeader), "the server is recorded for the queued webhooks")
+
+	r = httptest.NewRequest(http.MethodPost, "/hook", nil)
+	r.Header.Set(GitServerHeader, "ghe")
+	server, err = o.gitServerOf(r)
+	require.NoError(t, err)
+	assert.Equal(t, "ghe", server.Name)
+
+	r = httptest.NewRequest(http.MethodPost, "/hook", nil)
+	r.Header.Set("X-GitHub-Enterprise-Host", "GitHub.example.com")
+	server, err = o.gitServerOf(r)
+	require.NoError(t, err)
+	assert.Equal(t, "ghe", server.Name)
+	assert.Equal(t, "ghe", r.Header.Get(GitServerHeader))
+
+	r = httptest.NewRequest(http.MethodPost, "/hook", nil)
+	r.Header.Set("X-Gitlab-Instance", "https://gitlab.example.com")
+	server, err = o.gitServerOf(r)
+	require.NoError(t, err)
+	assert.Equal(t, "gitlab", server.Name)
+
+	r = httptest.NewRequest(http.MethodPost, "/hook", nil)
+	r.Header.Set("X-GitHub-Enterprise-Host", "other.example.com")
+	server, err = o.gitServerOf(r)
+	require.NoError(t, err)
+	assert.Nil(t, server, "unknown enterprise hosts are delivered by the default server")
+
+	r = httptest.NewRequest(http.MethodPost, "/hook?server=bitbucket", nil)
+	_, err = o.gitServerOf(r)
+	assert.EqualError(t, err, "unknown git server bitbucket")
+
+	for name, value := range map[string]string{"HMAC_TOKEN": "secret", "GHE_HMAC_TOKEN": "ghe-secret"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, value)
+	}
+	assert.Equal(t, "secret", o.hmacTokenFor(nil))
+	assert.Equal(t, "ghe-secret", o.hmacTokenFor(agent.Config().GitServers.Named("ghe")), "the webhooks naming a server are signed with its token")
+	assert.Equal(t, "secret", o.hmacTokenFor(agent.Config().GitServers.Named("gitlab")))
+}
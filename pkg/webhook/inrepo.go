@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"github.com/jenkins-x/lighthouse/pkg/inrepo"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/sirupsen/logrus"
+)
+
+// loadInRepoConfig sets the configuration of the event to the central configuration merged with the jobs
+// of the .lighthouse directory of the repository at the given ref, if the repository is enabled in the
+// inRepoConfig settings. The plugins keep the central configuration if the jobs cannot be loaded.
+func (s *Server) loadInRepoConfig(e *event, l *logrus.Entry, owner, repo, ref string) {
+	if e.configLoaded {
+		return
+	}
+	e.configLoaded = true
+	if s.Settings == nil || s.ConfigAgent == nil || s.ClientAgent == nil || s.ClientAgent.SCMProviderClient == nil || ref == "" {
+		return
+	}
+	if !s.Settings().InRepoConfig.Enabled(owner, repo) {
+		return
+	}
+	l = l.WithField("ref", ref)
+	scmClient := scmprovider.ToClient(s.ClientAgent.SCMProviderClient, s.ClientAgent.BotName)
+	if e.ctx != nil {
+		scmClient.SetContext(e.ctx)
+	}
+	jobs, err := inrepo.Load(scmClient, owner, repo, ref)
+	if err != nil {
+		l.WithError(err).Warnf("failed to load the jobs of the %s directory, using the central configuration", inrepo.Dir)
+		return
+	}
+	cfg, err := inrepo.Merge(s.ConfigAgent.Config(), owner, repo, jobs)
+	if err != nil {
+		l.WithError(err).Warnf("invalid jobs in the %s directory, using the central configuration", inrepo.Dir)
+		return
+	}
+	l.WithFields(logrus.Fields{
+		"presubmits":  len(jobs.Presubmits),
+		"postsubmits": len(jobs.Postsubmits),
+	}).Debugf("loaded the jobs of the %s directory", inrepo.Dir)
+	e.config = cfg
+}
+
+// loadInRepoConfigOfPR loads the configuration of the event at the head of the pull request
+func (s *Server) loadInRepoConfigOfPR(e *event, l *logrus.Entry, owner, repo string, number int) {
+	if e.configLoaded || s.Settings == nil || !s.Settings().InRepoConfig.Enabled(owner, repo) {
+		return
+	}
+	if s.ClientAgent == nil || s.ClientAgent.SCMProviderClient == nil {
+		return
+	}
+	scmClient := scmprovider.ToClient(s.ClientAgent.SCMProviderClient, s.ClientAgent.BotName)
+	if e.ctx != nil {
+		scmClient.SetContext(e.ctx)
+	}
+	pr, err := scmClient.GetPullRequest(owner, repo, number)
+	if err != nil {
+		e.configLoaded = true
+		l.WithError(err).Warnf("failed to get the pull request to load the jobs of its %s directory, using the central configuration", inrepo.Dir)
+		return
+	}
+	s.loadInRepoConfig(e, l, owner, repo, pr.Sha)
+}
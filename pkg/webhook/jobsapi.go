@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	lighthouseclient "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/typed/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/errorutil"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/launcher"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// JobsAPIPath is the URL path of the JSON API listing the LighthouseJobs with GET, returning a job
+	// with GET on JobsAPIPath/<name> and re-running a job with POST on JobsAPIPath/<name>/rerun
+	JobsAPIPath = "/api/v1/jobs"
+
+	// defaultJobsLimit is the number of most recent jobs listed when the limit query parameter is not set
+	defaultJobsLimit = 100
+	// maxJobsLimit is the maximum number of jobs listed
+	maxJobsLimit = 1000
+	// listJobsPageSize is the number of jobs fetched at once from the API server when listing the jobs
+	listJobsPageSize = 500
+)
+
+// jobsAPI serves the JSON API of the LighthouseJobs
+type jobsAPI struct {
+	jobs     lighthouseclient.LighthouseJobInterface
+	launcher launcher.PipelineLauncher
+}
+
+// newJobsAPIHandler returns the jobs API handler authenticated with the admin token
+func (o *Options) newJobsAPIHandler() http.Handler {
+	return util.AdminHandler(util.GetAdminToken(), &jobsAPI{
+		jobs:     o.kubeClients.Lighthouse.LighthouseV1alpha1().LighthouseJobs(o.namespace),
+		launcher: o.launcher,
+	})
+}
+
+func (a *jobsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, JobsAPIPath), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		a.list(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		a.get(w, parts[0])
+	case len(parts) == 2 && parts[1] == "rerun" && r.Method == http.MethodPost:
+		a.rerun(w, parts[0])
+	case path == "" || len(parts) == 1 || (len(parts) == 2 && parts[1] == "rerun"):
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// list writes the most recent jobs matching the repo (org/repo or org), type, branch and state query
+// parameters, up to the number given by the limit query parameter. The jobs are fetched in pages, of
+// which only the most recent jobs are kept, so that the jobs of busy clusters are not all loaded at once.
+func (a *jobsAPI) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	selector := labels.Set{}
+	if repo := q.Get("repo"); repo != "" {
+		org, name := scm.Split(repo)
+		if org == "" {
+			org = name
+			name = ""
+		}
+		selector[util.OrgLabel] = strings.ToLower(org)
+		if name != "" {
+			selector[util.RepoLabel] = name
+		}
+	}
+	if jobType := q.Get("type"); jobType != "" {
+		selector[config.LighthouseJobTypeLabel] = jobType
+	}
+	if branch := q.Get("branch"); branch != "" {
+		selector[util.BranchLabel] = branch
+	}
+	for key, value := range selector {
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			http.Error(w, fmt.Sprintf("invalid %s %q: %s", key, value, strings.Join(errs, ", ")), http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultJobsLimit
+	if value := q.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxJobsLimit {
+			http.Error(w, fmt.Sprintf("the limit query parameter must be a positive number of jobs up to %d", maxJobsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	state := v1alpha1.PipelineState(q.Get("state"))
+	jobs := []v1alpha1.LighthouseJob{}
+	opts := metav1.ListOptions{LabelSelector: selector.String(), Limit: listJobsPageSize}
+	for {
+		list, err := a.jobs.List(opts)
+		if err != nil {
+			responseHTTPError(w, http.StatusInternalServerError, "500 Internal Server Error: failed to list the jobs")
+			logrus.WithError(err).Error("failed to list the LighthouseJobs of the jobs API")
+			return
+		}
+		for _, job := range list.Items {
+			if state == "" || job.Status.State == state {
+				jobs = append(jobs, job)
+			}
+		}
+		jobs = mostRecentJobs(jobs, limit)
+		if list.Continue == "" {
+			break
+		}
+		opts.Continue = list.Continue
+	}
+	writeJSON(w, http.StatusOK, &v1alpha1.LighthouseJobList{Items: jobs})
+}
+
+// mostRecentJobs returns up to limit of the jobs, the most recent first
+func mostRecentJobs(jobs []v1alpha1.LighthouseJob, limit int) []v1alpha1.LighthouseJob {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Status.StartTime.After(jobs[j].Status.StartTime.Time)
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
+
+// get writes the job of the given name
+func (a *jobsAPI) get(w http.ResponseWriter, name string) {
+	job, err := a.jobs.Get(name, metav1.GetOptions{})
+	if err != nil {
+		a.writeGetError(w, name, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// rerun launches a copy of the job of the given name and writes the new job
+func (a *jobsAPI) rerun(w http.ResponseWriter, name string) {
+	job, err := a.jobs.Get(name, metav1.GetOptions{})
+	if err != nil {
+		a.writeGetError(w, name, err)
+		return
+	}
+	refs := job.Spec.Refs
+	if refs == nil {
+		http.Error(w, fmt.Sprintf("the job %s has no repository to re-run it for", name), http.StatusUnprocessableEntity)
+		return
+	}
+	pj := rerunOf(job)
+	l := logrus.WithFields(jobutil.LighthouseJobFields(&pj))
+	l.Infof("Re-running LighthouseJob %s from the jobs API.", name)
+	launched, err := a.launcher.Launch(&pj, repositoryOf(refs))
+	if err != nil {
+		l.WithError(err).Warnf("failed to re-run LighthouseJob %s", name)
+		responseHTTPError(w, http.StatusInternalServerError, "500 Internal Server Error: "+errorutil.UserMessage(err, "failed to re-run the job"))
+		return
+	}
+	writeJSON(w, http.StatusCreated, launched)
+}
+
+func (a *jobsAPI) writeGetError(w http.ResponseWriter, name string, err error) {
+	if kubeerrors.IsNotFound(err) {
+		http.Error(w, fmt.Sprintf("no job %s", name), http.StatusNotFound)
+		return
+	}
+	responseHTTPError(w, http.StatusInternalServerError, "500 Internal Server Error: failed to get the job")
+	logrus.WithError(err).Errorf("failed to get the LighthouseJob %s of the jobs API", name)
+}
+
+// repositoryOf returns the repository the pipelines of the jobs with the refs are launched for
+func repositoryOf(refs *v1alpha1.Refs) scm.Repository {
+	clone := refs.CloneURI
+	if clone == "" && refs.RepoLink != "" {
+		clone = refs.RepoLink + ".git"
+	}
+	return scm.Repository{
+		Namespace: refs.Org,
+		Name:      refs.Repo,
+		FullName:  scm.Join(refs.Org, refs.Repo),
+		Branch:    refs.BaseRef,
+		Clone:     clone,
+		Link:      refs.RepoLink,
+	}
+}
+
+// writeJSON writes the value as the indented JSON body of the response
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(data); err != nil {
+		logrus.WithError(err).Debug("failed to write the response of the jobs API")
+	}
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/apis/lighthouse/v1alpha1"
+	"github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/fake"
+	lighthouseclient "github.com/jenkins-x/lighthouse/pkg/client/clientset/versioned/typed/lighthouse/v1alpha1"
+	fakelauncher "github.com/jenkins-x/lighthouse/pkg/launcher/fake"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestJobsAPI(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	newJob := func(name, repo string, jobType config.PipelineKind, state v1alpha1.PipelineState, started time.Duration) runtime.Object {
+		return &v1alpha1.LighthouseJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "jx",
+				Labels: map[string]string{
+					util.OrgLabel:                 "org",
+					util.RepoLabel:                repo,
+					util.BranchLabel:              "master",
+					config.LighthouseJobTypeLabel: string(jobType),
+					util.IdempotencyKeyLabel:      name,
+				},
+			},
+			Spec: v1alpha1.LighthouseJobSpec{
+				Type: jobType,
+				Job:  name,
+				Refs: &v1alpha1.Refs{Org: "Org", Repo: repo, BaseRef: "master", RepoLink: "https://github.com/Org/" + repo},
+			},
+			Status: v1alpha1.LighthouseJobStatus{State: state, StartTime: metav1.NewTime(now.Add(-started))},
+		}
+	}
+	launcher := fakelauncher.NewLauncher()
+	launcher.FailJobs = sets.NewString("broken")
+	api := &jobsAPI{
+		jobs: fake.NewSimpleClientset(
+			newJob("unit", "app", config.PresubmitJob, v1alpha1.FailureState, time.Hour),
+			newJob("lint", "app", config.PresubmitJob, v1alpha1.SuccessState, time.Minute),
+			newJob("release", "app", config.PostsubmitJob, v1alpha1.SuccessState, 2*time.Hour),
+			newJob("broken", "lib", config.PostsubmitJob, v1alpha1.FailureState, 3*time.Hour),
+		).LighthouseV1alpha1().LighthouseJobs("jx"),
+		launcher: launcher,
+	}
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	list := func(query string) []string {
+		w := serve(http.MethodGet, JobsAPIPath+"?"+query)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var jobs v1alpha1.LighthouseJobList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jobs))
+		var names []string
+		for _, job := range jobs.Items {
+			names = append(names, job.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"lint", "unit", "release", "broken"}, list(""), "the most recent jobs come first")
+	assert.Equal(t, []string{"lint", "unit"}, list("repo=Org/app&type=presubmit"))
+	assert.Equal(t, []string{"broken"}, list("repo=org/lib"))
+	assert.Len(t, list("repo=org&branch=master"), 4)
+	assert.Equal(t, []string{"unit", "broken"}, list("state=failure"))
+	assert.Equal(t, []string{"lint"}, list("limit=1"))
+	assert.Empty(t, list("type=periodic"))
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, JobsAPIPath+"?limit=none").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, JobsAPIPath+"?limit=1001").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, JobsAPIPath+"?branch=not%20a%20label").Code)
+
+	w := serve(http.MethodGet, JobsAPIPath+"/unit")
+	require.Equal(t, http.StatusOK, w.Code)
+	var job v1alpha1.LighthouseJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, "unit", job.Spec.Job)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, JobsAPIPath+"/missing").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, JobsAPIPath+"/unit/logs").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodDelete, JobsAPIPath+"/unit").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, JobsAPIPath+"/unit/rerun").Code)
+
+	w = serve(http.MethodPost, JobsAPIPath+"/unit/rerun")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Len(t, launcher.Pipelines, 1)
+	rerun := launcher.Pipelines[0]
+	assert.Equal(t, "unit", rerun.Spec.Job)
+	assert.Empty(t, rerun.Labels[util.IdempotencyKeyLabel], "the copy is not mistaken for a redelivery")
+	assert.Equal(t, "https://github.com/Org/app.git", repositoryOf(rerun.Spec.Refs).Clone)
+	assert.Equal(t, "Org/app", repositoryOf(rerun.Spec.Refs).FullName)
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, JobsAPIPath+"/missing/rerun").Code)
+	w = serve(http.MethodPost, JobsAPIPath+"/broken/rerun")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "failed to create job")
+	assert.Len(t, launcher.Pipelines, 1)
+}
+
+// pagedJobs lists the jobs in pages of the given size, as the API server does for the list options with
+// a limit, which the fake clientset ignores
+type pagedJobs struct {
+	lighthouseclient.LighthouseJobInterface
+	pageSize int
+	pages    int
+}
+
+func (p *pagedJobs) List(opts metav1.ListOptions) (*v1alpha1.LighthouseJobList, error) {
+	list, err := p.LighthouseJobInterface.List(metav1.ListOptions{LabelSelector: opts.LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Name < list.Items[j].Name
+	})
+	start, _ := strconv.Atoi(opts.Continue)
+	end := start + p.pageSize
+	if end < len(list.Items) {
+		list.Continue = strconv.Itoa(end)
+	} else {
+		end = len(list.Items)
+	}
+	list.Items = list.Items[start:end]
+	p.pages++
+	return list, nil
+}
+
+func TestJobsAPIPages(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	var objects []runtime.Object
+	for i := 0; i < 7; i++ {
+		objects = append(objects, &v1alpha1.LighthouseJob{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("job-%d", i), Namespace: "jx"},
+			Status:     v1alpha1.LighthouseJobStatus{StartTime: metav1.NewTime(now.Add(time.Duration(i%4) * time.Minute))},
+		})
+	}
+	jobs := &pagedJobs{LighthouseJobInterface: fake.NewSimpleClientset(objects...).LighthouseV1alpha1().LighthouseJobs("jx"), pageSize: 2}
+	api := &jobsAPI{jobs: jobs}
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, JobsAPIPath+"?limit=3", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list v1alpha1.LighthouseJobList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	var names []string
+	for _, job := range list.Items {
+		names = append(names, job.Name)
+	}
+	assert.Equal(t, []string{"job-3", "job-2", "job-6"}, names, "the most recent jobs of all the pages are listed")
+	assert.Equal(t, 4, jobs.pages)
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/keeper"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+// KeeperSyncURLEnvVar is the environment variable containing the URL of the keeper sync endpoint.
+// When set, webhook events which may change what keeper would do with a pull request are
+// forwarded to keeper so that it re-syncs the affected subpool straight away. The requests are
+// authenticated with the admin token.
+const KeeperSyncURLEnvVar = "LIGHTHOUSE_KEEPER_SYNC_URL"
+
+// keeperSyncRequest returns the keeper sync request for the webhook or nil if the
+// webhook cannot affect a keeper subpool
+func keeperSyncRequest(webhook scm.Webhook) *keeper.SyncRequest {
+	repo := webhook.Repository()
+	request := &keeper.SyncRequest{
+		Org:  repo.Namespace,
+		Repo: repo.Name,
+	}
+	switch hook := webhook.(type) {
+	case *scm.PullRequestHook:
+		switch hook.Action {
+		case scm.ActionOpen, scm.ActionReopen, scm.ActionSync, scm.ActionLabel, scm.ActionUnlabel:
+			request.Branch = hook.PullRequest.Base.Ref
+		case scm.ActionEdited:
+			// the base branch may have changed so sync the whole repository
+		default:
+			return nil
+		}
+	case *scm.ReviewHook:
+		request.Branch = hook.PullRequest.Base.Ref
+	case *scm.PushHook:
+		if !strings.HasPrefix(hook.Ref, "refs/heads/") {
+			return nil
+		}
+		// the base branch of the subpool has moved on
+		request.Branch = strings.TrimPrefix(hook.Ref, "refs/heads/")
+	case *scm.StatusHook, *scm.CheckRunHook, *scmprovider.CheckRunHook, *scm.CheckSuiteHook:
+	default:
+		return nil
+	}
+	if request.Org == "" || request.Repo == "" {
+		return nil
+	}
+	return request
+}
+
+// notifyKeeper asks keeper to re-sync the subpool affected by the webhook, if any
+func (o *Options) notifyKeeper(l *logrus.Entry, webhook scm.Webhook) {
+	keeperURL := os.Getenv(KeeperSyncURLEnvVar)
+	token := util.GetAdminToken()
+	if keeperURL == "" || token == "" {
+		return
+	}
+	request := keeperSyncRequest(webhook)
+	if request == nil {
+		return
+	}
+	o.server.wg.Add(1)
+	go func() {
+		defer o.server.wg.Done()
+		if err := keeper.NotifySync(nil, keeperURL, token, *request); err != nil {
+			l.WithError(err).Warnf("failed to notify keeper to sync %s", request.String())
+		}
+	}()
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/keeper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeeperSyncRequest(t *testing.T) {
+	repo := scm.Repository{Namespace: "org", Name: "repo"}
+	pr := scm.PullRequest{Base: scm.PullRequestBranch{Ref: "master"}}
+
+	testCases := []struct {
+		name     string
+		webhook  scm.Webhook
+		expected *keeper.SyncRequest
+	}{
+		{
+			name:     "PR synchronized",
+			webhook:  &scm.PullRequestHook{Action: scm.ActionSync, Repo: repo, PullRequest: pr},
+			expected: &keeper.SyncRequest{Org: "org", Repo: "repo", Branch: "master"},
+		},
+		{
+			name:     "PR labeled",
+			webhook:  &scm.PullRequestHook{Action: scm.ActionLabel, Repo: repo, PullRequest: pr},
+			expected: &keeper.SyncRequest{Org: "org", Repo: "repo", Branch: "master"},
+		},
+		{
+			name:     "PR edited",
+			webhook:  &scm.PullRequestHook{Action: scm.ActionEdited, Repo: repo, PullRequest: pr},
+			expected: &keeper.SyncRequest{Org: "org", Repo: "repo"},
+		},
+		{
+			name:    "PR assigned",
+			webhook: &scm.PullRequestHook{Action: scm.ActionAssigned, Repo: repo, PullRequest: pr},
+		},
+		{
+			name:     "status",
+			webhook:  &scm.StatusHook{Repo: repo},
+			expected: &keeper.SyncRequest{Org: "org", Repo: "repo"},
+		},
+		{
+			name:     "push to branch",
+			webhook:  &scm.PushHook{Ref: "refs/heads/release", Repo: repo},
+			expected: &keeper.SyncRequest{Org: "org", Repo: "repo", Branch: "release"},
+		},
+		{
+			name:    "push tag",
+			webhook: &scm.PushHook{Ref: "refs/tags/v1.0.0", Repo: repo},
+		},
+		{
+			name:    "issue comment",
+			webhook: &scm.IssueCommentHook{Repo: repo},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, keeperSyncRequest(tc.webhook))
+		})
+	}
+}
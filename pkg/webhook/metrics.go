@@ -17,6 +17,9 @@ limitations under the License.
 package webhook
 
 import (
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -30,11 +33,37 @@ var (
 		Name: "prow_webhook_response_codes",
 		Help: "A counter of the different responses hook has responded to webhooks with.",
 	}, []string{"response_code"})
+	pluginHandlerHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "lighthouse_plugin_handler_duration_seconds",
+		Help: "Duration of plugin handler invocations by plugin, event and result.",
+	}, []string{"plugin", "event_type", "result"})
+	pluginTimeoutCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_plugin_handler_timeouts_total",
+		Help: "A counter of the plugin handler invocations which exceeded the deadline of their event.",
+	}, []string{"plugin", "event_type"})
+	payloadSizeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lighthouse_webhook_payload_bytes",
+		Help:    "The size of the webhook payloads as delivered, by content encoding.",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+	}, []string{"encoding"})
+	payloadRejectedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_webhook_payloads_rejected_total",
+		Help: "A counter of the webhook payloads rejected before being parsed, by reason.",
+	}, []string{"reason"})
+	duplicateDeliveryCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_webhook_duplicate_deliveries_total",
+		Help: "A counter of the webhook deliveries skipped because they were processed already, by event type.",
+	}, []string{"event_type"})
 )
 
 func init() {
 	prometheus.MustRegister(webhookCounter)
 	prometheus.MustRegister(responseCounter)
+	prometheus.MustRegister(pluginHandlerHistogram)
+	prometheus.MustRegister(pluginTimeoutCounter)
+	prometheus.MustRegister(payloadSizeHistogram)
+	prometheus.MustRegister(payloadRejectedCounter)
+	prometheus.MustRegister(duplicateDeliveryCounter)
 }
 
 // Metrics is a set of metrics gathered by hook.
@@ -50,3 +79,21 @@ func NewMetrics() *Metrics {
 		ResponseCounter: responseCounter,
 	}
 }
+
+// instrumentPlugin is a plugin middleware recording the duration and result of handler invocations
+func instrumentPlugin(next plugins.InvocationHandler) plugins.InvocationHandler {
+	return func(inv plugins.Invocation) error {
+		start := time.Now()
+		err := next(inv)
+		result := "success"
+		switch {
+		case inv.TimedOut():
+			result = "timeout"
+			pluginTimeoutCounter.WithLabelValues(inv.Plugin, inv.Event).Inc()
+		case err != nil:
+			result = "error"
+		}
+		pluginHandlerHistogram.WithLabelValues(inv.Plugin, inv.Event, result).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha1" // #nosec
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+)
+
+var (
+	errPayloadTooLarge     = errors.New("the payload exceeds the maximum size")
+	errUnsupportedEncoding = errors.New("the content encoding of the payload is not supported")
+	errInvalidEncoding     = errors.New("the payload is not valid for its content encoding")
+)
+
+// payload is the body of a webhook delivery
+type payload struct {
+	// raw is the body as delivered, which is compressed if it has a content encoding
+	raw []byte
+	// body is the decompressed body
+	body []byte
+	// encoding is the content encoding of the delivery, identity if it is not compressed
+	encoding string
+	// signatureVerified is true if the HMAC signature of the body was verified while it was read
+	signatureVerified bool
+}
+
+// readPayload reads the body of the delivery, rejecting it if it is larger than maxSize bytes either as
+// delivered or once decompressed. The HMAC signature of the body is verified while it is read when the
+// provider signs the bodies and the secret is set, and gzip encoded bodies are decompressed.
+func readPayload(r *http.Request, secret string, maxSize int64) (*payload, error) {
+	p := &payload{encoding: strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))}
+	if p.encoding == "" {
+		p.encoding = "identity"
+	}
+	if p.encoding != "identity" && p.encoding != "gzip" {
+		return p, errors.Wrapf(errUnsupportedEncoding, "unsupported content encoding %q", p.encoding)
+	}
+	if r.ContentLength > maxSize {
+		return p, errors.Wrapf(errPayloadTooLarge, "the payload of %d bytes exceeds the maximum of %d bytes", r.ContentLength, maxSize)
+	}
+
+	var reader io.Reader = io.LimitReader(r.Body, maxSize+1)
+	mac, signature := signatureOf(r.Header, secret)
+	if mac != nil {
+		reader = io.TeeReader(reader, mac)
+	}
+	var err error
+	p.raw, err = ioutil.ReadAll(reader)
+	if err != nil {
+		return p, errors.Wrap(err, "failed to read the body")
+	}
+	if int64(len(p.raw)) > maxSize {
+		return p, errors.Wrapf(errPayloadTooLarge, "the payload exceeds the maximum of %d bytes", maxSize)
+	}
+	if mac != nil {
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return p, scm.ErrSignatureInvalid
+		}
+		p.signatureVerified = true
+	}
+
+	p.body = p.raw
+	if p.encoding == "gzip" {
+		p.body, err = gunzip(p.raw, maxSize)
+		if err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// gunzip decompresses the gzip encoded data, failing if it is larger than maxSize bytes once decompressed
+func gunzip(data []byte, maxSize int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(errInvalidEncoding, err.Error())
+	}
+	defer zr.Close() // #nosec
+	body, err := ioutil.ReadAll(io.LimitReader(zr, maxSize+1))
+	if err != nil {
+		return nil, errors.Wrap(errInvalidEncoding, err.Error())
+	}
+	if int64(len(body)) > maxSize {
+		return nil, errors.Wrapf(errPayloadTooLarge, "the decompressed payload exceeds the maximum of %d bytes", maxSize)
+	}
+	return body, nil
+}
+
+// signatureOf returns the HMAC of the body of the deliveries signed with the secret and the signature
+// the provider sent for it, or a nil HMAC if the secret is empty or the provider does not sign bodies
+// with it. The signature of the providers which only send a token is checked when the payload is parsed.
+func signatureOf(header http.Header, secret string) (hash.Hash, []byte) {
+	if secret == "" {
+		return nil, nil
+	}
+	key := []byte(secret)
+	for _, name := range []string{"X-Hub-Signature-256", "X-Hub-Signature"} {
+		value := header.Get(name)
+		switch {
+		case strings.HasPrefix(value, "sha256="):
+			return hmacOf(sha256.New, key, strings.TrimPrefix(value, "sha256="))
+		case strings.HasPrefix(value, "sha1="):
+			return hmacOf(sha1.New, key, strings.TrimPrefix(value, "sha1="))
+		}
+	}
+	for _, name := range []string{"X-Gitea-Signature", "X-Gogs-Signature"} {
+		if value := header.Get(name); value != "" {
+			return hmacOf(sha256.New, key, value)
+		}
+	}
+	return nil, nil
+}
+
+// hmacOf returns the HMAC of the hash function with the key and the decoded hexadecimal signature. An
+// invalid signature is returned as an empty one, so that the verification fails.
+func hmacOf(h func() hash.Hash, key []byte, signature string) (hash.Hash, []byte) {
+	decoded, err := hex.DecodeString(signature)
+	if err != nil {
+		decoded = []byte{}
+	}
+	return hmac.New(h, key), decoded
+}
+
+// payloadErrorStatus returns the HTTP status of a delivery whose payload cannot be read and the reason
+// it is rejected for, for the metrics
+func payloadErrorStatus(err error) (int, string) {
+	switch errors.Cause(err) {
+	case errPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge, "too_large"
+	case errUnsupportedEncoding:
+		return http.StatusUnsupportedMediaType, "unsupported_encoding"
+	case errInvalidEncoding:
+		return http.StatusBadRequest, "invalid_encoding"
+	case scm.ErrSignatureInvalid:
+		return http.StatusForbidden, "invalid_signature"
+	}
+	return http.StatusInternalServerError, "read_error"
+}
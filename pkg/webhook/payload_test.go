@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPayload(t *testing.T) {
+	body := []byte(`{"action":"opened","number":1}`)
+	sign := func(data []byte) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		_, _ = mac.Write(data)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	gzipped := func(data []byte) []byte {
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		_, err := zw.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+	request := func(data []byte, header map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(data))
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+	status := func(err error) int {
+		s, _ := payloadErrorStatus(err)
+		return s
+	}
+
+	p, err := readPayload(request(body, nil), "secret", 1024)
+	require.NoError(t, err)
+	assert.Equal(t, body, p.body)
+	assert.Equal(t, "identity", p.encoding)
+	assert.False(t, p.signatureVerified, "the token of the providers which do not sign the bodies is checked by go-scm")
+
+	p, err = readPayload(request(body, map[string]string{"X-Hub-Signature-256": "sha256=" + sign(body)}), "secret", 1024)
+	require.NoError(t, err)
+	assert.True(t, p.signatureVerified)
+	p, err = readPayload(request(body, map[string]string{"X-Gitea-Signature": sign(body)}), "secret", 1024)
+	require.NoError(t, err)
+	assert.True(t, p.signatureVerified)
+	p, err = readPayload(request(body, map[string]string{"X-Hub-Signature-256": "sha256=" + sign(body)}), "", 1024)
+	require.NoError(t, err)
+	assert.False(t, p.signatureVerified, "the signatures are not verified without a secret")
+
+	_, err = readPayload(request(body, map[string]string{"X-Hub-Signature-256": "sha256=" + sign([]byte("other"))}), "secret", 1024)
+	assert.Equal(t, scm.ErrSignatureInvalid, errors.Cause(err))
+	assert.Equal(t, http.StatusForbidden, status(err))
+	_, err = readPayload(request(body, map[string]string{"X-Hub-Signature": "sha1=not-hex"}), "secret", 1024)
+	assert.Equal(t, http.StatusForbidden, status(err))
+
+	compressed := gzipped(body)
+	p, err = readPayload(request(compressed, map[string]string{"Content-Encoding": "gzip", "X-Hub-Signature-256": "sha256=" + sign(compressed)}), "secret", 1024)
+	require.NoError(t, err)
+	assert.Equal(t, body, p.body)
+	assert.Equal(t, compressed, p.raw, "the deliveries are replayed as delivered")
+	assert.True(t, p.signatureVerified, "the signature is of the compressed body")
+
+	_, err = readPayload(request(body, nil), "", 10)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status(err))
+	r := request(body, nil)
+	r.ContentLength = -1
+	_, err = readPayload(r, "", 10)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status(err), "the payloads without a content length are limited while read")
+	bomb := gzipped([]byte(strings.Repeat("a", 10000)))
+	require.True(t, len(bomb) < 1024)
+	_, err = readPayload(request(bomb, map[string]string{"Content-Encoding": "gzip"}), "", 1024)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status(err), "the decompressed payloads are limited too")
+
+	_, err = readPayload(request(body, map[string]string{"Content-Encoding": "gzip"}), "", 1024)
+	assert.Equal(t, http.StatusBadRequest, status(err))
+	_, err = readPayload(request(body, map[string]string{"Content-Encoding": "br"}), "", 1024)
+	assert.Equal(t, http.StatusUnsupportedMediaType, status(err))
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"reflect"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/launcher"
+	"github.com/jenkins-x/lighthouse/pkg/provenance"
+)
+
+// provenanceLauncher returns the launcher recording the provenance of the jobs launched for the webhook
+func (o *Options) provenanceLauncher(webhook scm.Webhook, payload []byte) launcher.PipelineLauncher {
+	recorder := o.provenance.Recorder()
+	if recorder == nil {
+		return o.launcher
+	}
+	trigger := provenance.Trigger{
+		TriggeredBy:   webhookSender(webhook).Login,
+		EventGUID:     webhookStringField(webhook, "GUID"),
+		EventDigest:   provenance.Digest(payload),
+		ConfigVersion: o.settingsAgent.Config().Version,
+	}
+	return provenance.NewLauncher(o.launcher, recorder, trigger)
+}
+
+// webhookSender returns the sender of the webhook. All the go-scm hooks have a Sender
+// field but the scm.Webhook interface does not expose it.
+func webhookSender(webhook scm.Webhook) scm.User {
+	field := webhookField(webhook, "Sender")
+	if field.IsValid() {
+		if sender, ok := field.Interface().(scm.User); ok {
+			return sender
+		}
+	}
+	return scm.User{}
+}
+
+func webhookStringField(webhook scm.Webhook, name string) string {
+	field := webhookField(webhook, name)
+	if field.IsValid() && field.Kind() == reflect.String {
+		return field.String()
+	}
+	return ""
+}
+
+func webhookField(webhook scm.Webhook, name string) reflect.Value {
+	v := reflect.Indirect(reflect.ValueOf(webhook))
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return v.FieldByName(name)
+}
@@ -0,0 +1,25 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/launcher/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSender(t *testing.T) {
+	hook := &scm.PushHook{GUID: "guid", Sender: scm.User{Login: "user"}}
+	assert.Equal(t, "user", webhookSender(hook).Login)
+	assert.Equal(t, "guid", webhookStringField(hook, "GUID"))
+	assert.Equal(t, "", webhookStringField(hook, "Missing"))
+
+	comment := &scm.IssueCommentHook{Sender: scm.User{Login: "commenter"}}
+	assert.Equal(t, "commenter", webhookSender(comment).Login)
+}
+
+func TestProvenanceLauncherDisabled(t *testing.T) {
+	l := fake.NewLauncher()
+	o := &Options{launcher: l}
+	assert.Equal(t, l, o.provenanceLauncher(&scm.PushHook{}, []byte("{}")))
+}
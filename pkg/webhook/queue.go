@@ -0,0 +1,290 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// QueueWorkersEnvVar is the environment variable containing the number of workers processing the queued
+	// webhooks. The webhooks are processed when they are delivered, without being queued, when it is not set
+	// or is 0.
+	QueueWorkersEnvVar = "LIGHTHOUSE_QUEUE_WORKERS"
+
+	// QueuedHeader is the request header which marked the queued webhooks being processed. It is stripped
+	// from the webhooks as the queued webhooks are marked with the request context, so that senders cannot
+	// skip the queue and its limit of concurrent webhooks.
+	QueuedHeader = "X-Lighthouse-Queued"
+
+	// queuedEventLabel is the label of the ConfigMaps of the queued webhooks
+	queuedEventLabel = "lighthouse.jenkins-x.io/webhook-event"
+	// queuedClaimedAnnotation is the annotation containing when a queued webhook was last claimed by a worker
+	queuedClaimedAnnotation = "lighthouse.jenkins-x.io/claimed-at"
+	// queuedAttemptsAnnotation is the annotation containing the number of attempts to process a queued webhook
+	queuedAttemptsAnnotation = "lighthouse.jenkins-x.io/attempts"
+	// queuedReplayAnnotation is the annotation containing the ID of the delivery a queued replay re-submits
+	queuedReplayAnnotation = "lighthouse.jenkins-x.io/replay-of"
+
+	queuedHeaderKey = "header.json"
+	queuedBodyKey   = "body"
+
+	// maxQueuedPayloadSize is the size of the largest payload queued, as ConfigMaps are limited to 1MiB.
+	// Larger payloads are processed when they are delivered.
+	maxQueuedPayloadSize = 900 * 1024
+	// queueClaimTimeout is how long a claimed webhook is left to its worker before the workers of any
+	// webhook replica claim it again, which is also the delay before the failed webhooks are retried
+	queueClaimTimeout = 5 * time.Minute
+	// queueResyncInterval is how often the queued webhooks are listed to recover the ones left by the
+	// replicas which stopped and to retry the failed ones
+	queueResyncInterval = time.Minute
+	// maxQueuedAttempts is the number of attempts to process a queued webhook before it is dropped
+	maxQueuedAttempts = 5
+)
+
+var errQueuedPayloadTooLarge = errors.New("the payload is too large to be queued")
+
+// eventQueue keeps the accepted webhooks in ConfigMaps until they are processed by its workers, so that the
+// webhooks of a replica which stops before processing them are processed by the other replicas or once it
+// restarts
+type eventQueue struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	workers    int
+	handler    http.HandlerFunc
+	names      chan string
+	now        func() time.Time
+}
+
+// newEventQueueFromEnv returns the queue with the number of workers given by $LIGHTHOUSE_QUEUE_WORKERS,
+// processing the webhooks with the handler, or nil if the webhooks are not queued
+func newEventQueueFromEnv(kubeClient kubernetes.Interface, namespace string, handler http.HandlerFunc) (*eventQueue, error) {
+	value := os.Getenv(QueueWorkersEnvVar)
+	if value == "" {
+		return nil, nil
+	}
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 0 {
+		return nil, fmt.Errorf("invalid $%s value %q, it must be a positive number of workers", QueueWorkersEnvVar, value)
+	}
+	if workers == 0 {
+		return nil, nil
+	}
+	return newEventQueue(kubeClient, namespace, workers, handler), nil
+}
+
+func newEventQueue(kubeClient kubernetes.Interface, namespace string, workers int, handler http.HandlerFunc) *eventQueue {
+	return &eventQueue{
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		workers:    workers,
+		handler:    handler,
+		names:      make(chan string, 1000),
+		now:        time.Now,
+	}
+}
+
+// withQueued returns the request marked as the queued webhook of the ConfigMap of the given name
+func withQueued(r *http.Request, name string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), queuedContextKey, name))
+}
+
+// queuedOf returns the name of the ConfigMap of the queued webhook of the request, or an empty string if
+// the webhook is not queued yet
+func queuedOf(r *http.Request) string {
+	name, _ := r.Context().Value(queuedContextKey).(string)
+	return name
+}
+
+// enqueue saves the webhook as delivered in a ConfigMap claimed by this replica and hands it to the workers.
+// The replay is the ID of the delivery the webhook replays, if any.
+func (q *eventQueue) enqueue(header http.Header, body []byte, replay string) (string, error) {
+	if len(body) > maxQueuedPayloadSize {
+		return "", errQueuedPayloadTooLarge
+	}
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal the headers")
+	}
+	annotations := map[string]string{queuedClaimedAnnotation: q.now().UTC().Format(time.RFC3339)}
+	if replay != "" {
+		annotations[queuedReplayAnnotation] = replay
+	}
+	cm, err := q.kubeClient.CoreV1().ConfigMaps(q.namespace).Create(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "lighthouse-webhook-event-" + newID(),
+			Labels:      map[string]string{queuedEventLabel: "true"},
+			Annotations: annotations,
+		},
+		Data:       map[string]string{queuedHeaderKey: string(headerData)},
+		BinaryData: map[string][]byte{queuedBodyKey: body},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create the ConfigMap of the webhook")
+	}
+	q.hand(cm.Name)
+	return cm.Name, nil
+}
+
+// hand gives the queued webhook to the workers, unless they are busy in which case it is claimed again
+// once the claim times out
+func (q *eventQueue) hand(name string) {
+	select {
+	case q.names <- name:
+	default:
+		logrus.WithField("queued", name).Warn("the webhook workers are busy, the webhook will be processed later")
+	}
+}
+
+// run starts the workers and recovers the queued webhooks periodically until the stop channel is closed
+func (q *eventQueue) run(stop <-chan struct{}) {
+	if q == nil {
+		return
+	}
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				case name := <-q.names:
+					q.process(name)
+				}
+			}
+		}()
+	}
+	q.resync()
+	ticker := time.NewTicker(queueResyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			q.resync()
+		}
+	}
+}
+
+// resync claims the queued webhooks whose claim timed out, either because their replica stopped or
+// because they failed to process, and hands them to the workers
+func (q *eventQueue) resync() {
+	configMaps := q.kubeClient.CoreV1().ConfigMaps(q.namespace)
+	list, err := configMaps.List(metav1.ListOptions{LabelSelector: queuedEventLabel + "=true"})
+	if err != nil {
+		logrus.WithError(err).Warn("failed to list the queued webhooks")
+		return
+	}
+	now := q.now()
+	for i := range list.Items {
+		cm := &list.Items[i]
+		claimed, _ := time.Parse(time.RFC3339, cm.Annotations[queuedClaimedAnnotation])
+		if now.Sub(claimed) < queueClaimTimeout {
+			continue
+		}
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Annotations[queuedClaimedAnnotation] = now.UTC().Format(time.RFC3339)
+		// the update fails with a conflict if another replica claimed the webhook first
+		if _, err := configMaps.Update(cm); err != nil {
+			if !kubeerrors.IsConflict(err) {
+				logrus.WithError(err).WithField("queued", cm.Name).Warn("failed to claim the queued webhook")
+			}
+			continue
+		}
+		q.hand(cm.Name)
+	}
+}
+
+// process runs the handler on the queued webhook, deleting it unless it failed with an internal error, in
+// which case it is retried once its claim times out until it ran out of attempts
+func (q *eventQueue) process(name string) {
+	l := logrus.WithField("queued", name)
+	configMaps := q.kubeClient.CoreV1().ConfigMaps(q.namespace)
+	cm, err := configMaps.Get(name, metav1.GetOptions{})
+	if kubeerrors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		l.WithError(err).Warn("failed to get the queued webhook, it will be processed later")
+		return
+	}
+	header := http.Header{}
+	if err := json.Unmarshal([]byte(cm.Data[queuedHeaderKey]), &header); err != nil {
+		l.WithError(err).Error("dropping the queued webhook with invalid headers")
+		q.delete(name)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(cm.BinaryData[queuedBodyKey]))
+	if err != nil {
+		l.WithError(err).Error("dropping the queued webhook")
+		q.delete(name)
+		return
+	}
+	if replay := cm.Annotations[queuedReplayAnnotation]; replay != "" {
+		req = withReplay(req, replay)
+	}
+	req = withQueued(req, name)
+	req.Header = header
+	w := &queuedResponseWriter{header: http.Header{}, status: http.StatusOK}
+	q.handler(w, req)
+	if w.status < http.StatusInternalServerError {
+		q.delete(name)
+		return
+	}
+	attempts, _ := strconv.Atoi(cm.Annotations[queuedAttemptsAnnotation])
+	attempts++
+	if attempts >= maxQueuedAttempts {
+		l.WithField("status-code", w.status).Errorf("dropping the queued webhook which failed to process %d times", attempts)
+		q.delete(name)
+		return
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[queuedAttemptsAnnotation] = strconv.Itoa(attempts)
+	cm.Annotations[queuedClaimedAnnotation] = q.now().UTC().Format(time.RFC3339)
+	if _, err := configMaps.Update(cm); err != nil {
+		l.WithError(err).Warn("failed to record the failed attempt of the queued webhook")
+		return
+	}
+	l.WithField("status-code", w.status).Warnf("the queued webhook failed to process, retrying in %s", queueClaimTimeout)
+}
+
+func (q *eventQueue) delete(name string) {
+	err := q.kubeClient.CoreV1().ConfigMaps(q.namespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !kubeerrors.IsNotFound(err) {
+		logrus.WithError(err).WithField("queued", name).Warn("failed to delete the queued webhook")
+	}
+}
+
+// queuedResponseWriter keeps the status of the response of the handler to a queued webhook, discarding its body
+type queuedResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *queuedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *queuedResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *queuedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEventQueue(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	status := http.StatusInternalServerError
+	var bodies []string
+	replay := ""
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, queuedOf(r), "the queued webhooks are not queued again")
+		assert.Equal(t, "push", r.Header.Get("X-GitHub-Event"))
+		assert.Equal(t, replay, replayOf(r), "the queued replays are still replays")
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+	}
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	q := newEventQueue(kubeClient, "jx", 1, handler)
+	q.now = func() time.Time { return now }
+
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	name, err := q.enqueue(header, []byte(`{"ref": "refs/heads/master"}`), "")
+	require.NoError(t, err)
+	require.Equal(t, name, <-q.names)
+
+	q.process(name)
+	assert.Equal(t, []string{`{"ref": "refs/heads/master"}`}, bodies)
+	cm, err := kubeClient.CoreV1().ConfigMaps("jx").Get(name, metav1.GetOptions{})
+	require.NoError(t, err, "the failed webhook is kept")
+	assert.Equal(t, "1", cm.Annotations[queuedAttemptsAnnotation])
+
+	q.resync()
+	assert.Len(t, q.names, 0, "the failed webhook is retried once its claim times out")
+	now = now.Add(queueClaimTimeout)
+	q.resync()
+	require.Len(t, q.names, 1)
+
+	status = http.StatusAccepted
+	q.process(<-q.names)
+	assert.Len(t, bodies, 2)
+	_, err = kubeClient.CoreV1().ConfigMaps("jx").Get(name, metav1.GetOptions{})
+	assert.Error(t, err, "the processed webhook is deleted")
+
+	replay = "delivery-1"
+	_, err = q.enqueue(header, []byte(`{"ref": "refs/heads/master"}`), replay)
+	require.NoError(t, err)
+	q.process(<-q.names)
+	assert.Len(t, bodies, 3)
+
+	_, err = q.enqueue(header, make([]byte, maxQueuedPayloadSize+1), "")
+	assert.Equal(t, errQueuedPayloadTooLarge, err)
+}
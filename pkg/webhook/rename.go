@@ -0,0 +1,319 @@
+package webhook
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// HandleRepositoryEvent handles repositories being renamed or transferred by moving
+// the git and OWNERS caches, renaming the repository in the config and plugins ConfigMaps,
+// adding the jobs and plugins of the old name to the in-memory config until the ConfigMaps
+// are reloaded and warning about the config entries which need to be updated manually
+func (s *Server) HandleRepositoryEvent(l *logrus.Entry, hook *scmprovider.RepositoryHook) {
+	if !hook.Moved() {
+		return
+	}
+	from := hook.PreviousFullName()
+	to := hook.Repo.FullName
+	l = l.WithFields(logrus.Fields{
+		"From": from,
+		"To":   to,
+	})
+	l.Infof("repository %s was %s to %s", from, hook.RawAction, to)
+
+	if s.ClientAgent != nil && s.ClientAgent.GitClient != nil {
+		if err := s.ClientAgent.GitClient.Rename(from, to); err != nil {
+			l.WithError(err).Warn("failed to move the git cache of the repository")
+		}
+	}
+	if s.ClientAgent != nil && s.ClientAgent.OwnersCache != nil {
+		s.ClientAgent.OwnersCache.Rename(from, to)
+	}
+
+	var cfg *config.Config
+	if s.ConfigAgent != nil {
+		cfg = s.ConfigAgent.Config()
+	}
+	var pluginCfg *plugins.Configuration
+	if s.Plugins != nil {
+		pluginCfg = s.Plugins.Config()
+	}
+
+	report := renameReport(cfg, pluginCfg, from, to)
+	if cfg != nil {
+		renamed, err := renameJobConfig(cfg, from, to)
+		if err != nil {
+			l.WithError(err).Warn("failed to add the jobs of the repository under its new name")
+		} else {
+			s.ConfigAgent.Set(renamed)
+		}
+	}
+	if pluginCfg != nil {
+		s.Plugins.Set(renamePluginConfig(pluginCfg, from, to))
+	}
+	if s.ClientAgent != nil && s.ClientAgent.KubernetesClient != nil && s.Namespace != "" {
+		persisted, err := persistRename(s.ClientAgent.KubernetesClient, s.Namespace, from, to)
+		if err != nil {
+			l.WithError(err).Warn("failed to rename the repository in the ConfigMaps")
+		}
+		if len(persisted) > 0 {
+			l.WithField("Entries", persisted).Infof("renamed %s to %s in the ConfigMaps, the source of the configuration needs to be updated too: %v", from, to, persisted)
+		}
+		report = sets.NewString(report...).Difference(sets.NewString(persisted...)).List()
+	}
+	if len(report) > 0 {
+		l.WithField("Entries", report).Warnf("repository %s was %s to %s, the following config entries need to be updated manually: %v", from, hook.RawAction, to, report)
+	}
+}
+
+// persistRename renames the repository in the config and plugins ConfigMaps, so the rename survives
+// restarts and reloads. It returns the renamed entries in the format of the rename report. The entries
+// inherited from the previous org of a transferred repository are left unchanged as they may apply to
+// other repositories.
+func persistRename(kubeClient kubernetes.Interface, ns, from, to string) ([]string, error) {
+	var persisted []string
+	renamed, err := renameConfigMap(kubeClient, ns, util.ProwConfigMapName, util.ProwConfigFilename, func(doc map[string]interface{}) []string {
+		entries := renameKey(doc, "presubmits", from, to)
+		entries = append(entries, renameKey(doc, "postsubmits", from, to)...)
+		if tide, ok := doc["tide"].(map[string]interface{}); ok {
+			for _, i := range renameInRepos(tide["queries"], from, to) {
+				entries = append(entries, fmt.Sprintf("tide.queries[%d]", i))
+			}
+		}
+		return entries
+	})
+	persisted = append(persisted, renamed...)
+	if err != nil {
+		return persisted, err
+	}
+	renamed, err = renameConfigMap(kubeClient, ns, util.ProwPluginsConfigMapName, util.ProwPluginsFilename, func(doc map[string]interface{}) []string {
+		entries := renameKey(doc, "plugins", from, to)
+		entries = append(entries, renameKey(doc, "external_plugins", from, to)...)
+		for _, key := range []string{"approve", "lgtm", "triggers"} {
+			for _, i := range renameInRepos(doc[key], from, to) {
+				entries = append(entries, fmt.Sprintf("%s[%d]", key, i))
+			}
+		}
+		return entries
+	})
+	persisted = append(persisted, renamed...)
+	sort.Strings(persisted)
+	return persisted, err
+}
+
+// renameConfigMap applies the rename function to the YAML document of the ConfigMap key and updates
+// the ConfigMap if any entry was renamed
+func renameConfigMap(kubeClient kubernetes.Interface, ns, name, key string, rename func(map[string]interface{}) []string) ([]string, error) {
+	configMaps := kubeClient.CoreV1().ConfigMaps(ns)
+	cm, err := configMaps.Get(name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get ConfigMap %s", name)
+	}
+	text := cm.Data[key]
+	if text == "" {
+		return nil, nil
+	}
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s of ConfigMap %s", key, name)
+	}
+	entries := rename(doc)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal %s of ConfigMap %s", key, name)
+	}
+	cm.Data[key] = string(data)
+	if _, err := configMaps.Update(cm); err != nil {
+		return nil, errors.Wrapf(err, "failed to update ConfigMap %s", name)
+	}
+	return entries, nil
+}
+
+// renameKey moves the entry of the previous name of the repository in the map of the given key to
+// the new name, unless the new name already has an entry
+func renameKey(doc map[string]interface{}, key, from, to string) []string {
+	m, ok := doc[key].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	value, ok := m[from]
+	if !ok {
+		return nil
+	}
+	if _, exists := m[to]; !exists {
+		m[to] = value
+	}
+	delete(m, from)
+	return []string{fmt.Sprintf("%s: %s", key, from)}
+}
+
+// renameInRepos replaces the previous name of the repository in the repos of the list of entries,
+// returning the indexes of the renamed entries
+func renameInRepos(list interface{}, from, to string) []int {
+	items, ok := list.([]interface{})
+	if !ok {
+		return nil
+	}
+	var renamed []int
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		repos, ok := m["repos"].([]interface{})
+		if !ok {
+			continue
+		}
+		for j, r := range repos {
+			if r == from {
+				repos[j] = to
+				renamed = append(renamed, i)
+			}
+		}
+	}
+	return renamed
+}
+
+// renameReport returns the config entries which refer to the previous name of the repository
+func renameReport(cfg *config.Config, pluginCfg *plugins.Configuration, from, to string) []string {
+	fromOrg, _ := scm.Split(from)
+	toOrg, _ := scm.Split(to)
+	transferred := fromOrg != toOrg
+	var report []string
+	refersTo := func(repos []string) bool {
+		for _, r := range repos {
+			if r == from || (transferred && r == fromOrg) {
+				return true
+			}
+		}
+		return false
+	}
+	if cfg != nil {
+		if len(cfg.Presubmits[from]) > 0 {
+			report = append(report, fmt.Sprintf("presubmits: %s", from))
+		}
+		if len(cfg.Postsubmits[from]) > 0 {
+			report = append(report, fmt.Sprintf("postsubmits: %s", from))
+		}
+		// the jobs inherited from the previous org are no longer inherited after a transfer
+		if transferred && len(cfg.Presubmits[fromOrg]) > 0 {
+			report = append(report, fmt.Sprintf("presubmits: %s", fromOrg))
+		}
+		if transferred && len(cfg.Postsubmits[fromOrg]) > 0 {
+			report = append(report, fmt.Sprintf("postsubmits: %s", fromOrg))
+		}
+		for i, q := range cfg.Keeper.Queries {
+			if refersTo(q.Repos) || (transferred && sets.NewString(q.Orgs...).Has(fromOrg)) {
+				report = append(report, fmt.Sprintf("tide.queries[%d]", i))
+			}
+		}
+	}
+	if pluginCfg != nil {
+		if _, ok := pluginCfg.Plugins[from]; ok {
+			report = append(report, fmt.Sprintf("plugins: %s", from))
+		}
+		if _, ok := pluginCfg.ExternalPlugins[from]; ok {
+			report = append(report, fmt.Sprintf("external_plugins: %s", from))
+		}
+		for i, a := range pluginCfg.Approve {
+			if refersTo(a.Repos) {
+				report = append(report, fmt.Sprintf("approve[%d]", i))
+			}
+		}
+		for i, l := range pluginCfg.Lgtm {
+			if refersTo(l.Repos) {
+				report = append(report, fmt.Sprintf("lgtm[%d]", i))
+			}
+		}
+		for i, t := range pluginCfg.Triggers {
+			if refersTo(t.Repos) {
+				report = append(report, fmt.Sprintf("triggers[%d]", i))
+			}
+		}
+	}
+	sort.Strings(report)
+	return report
+}
+
+// renameJobConfig returns a copy of the config with the jobs of the previous name of
+// the repository also configured for its new name, so triggering keeps working until
+// the config is updated. Jobs already configured for the new name are left unchanged.
+// On transfers the jobs inherited from the previous org are copied too.
+func renameJobConfig(cfg *config.Config, from, to string) (*config.Config, error) {
+	fromOrg, fromName := scm.Split(from)
+	toOrg, _ := scm.Split(to)
+	fromRepo := scm.Repository{Namespace: fromOrg, Name: fromName, FullName: from}
+	transferred := fromOrg != toOrg
+
+	renamed := *cfg
+	presubmits := map[string][]config.Presubmit{}
+	for k, v := range cfg.Presubmits {
+		presubmits[k] = v
+	}
+	fromPresubmits := cfg.Presubmits[from]
+	if transferred {
+		fromPresubmits = jobutil.Presubmits(cfg, fromRepo)
+	}
+	if _, ok := presubmits[to]; !ok && len(fromPresubmits) > 0 {
+		presubmits[to] = fromPresubmits
+	}
+	if err := renamed.SetPresubmits(presubmits); err != nil {
+		return nil, err
+	}
+	postsubmits := map[string][]config.Postsubmit{}
+	for k, v := range cfg.Postsubmits {
+		postsubmits[k] = v
+	}
+	fromPostsubmits := cfg.Postsubmits[from]
+	if transferred {
+		fromPostsubmits = jobutil.Postsubmits(cfg, fromRepo)
+	}
+	if _, ok := postsubmits[to]; !ok && len(fromPostsubmits) > 0 {
+		postsubmits[to] = fromPostsubmits
+	}
+	if err := renamed.SetPostsubmits(postsubmits); err != nil {
+		return nil, err
+	}
+	return &renamed, nil
+}
+
+// renamePluginConfig returns a copy of the plugin config with the plugins of the previous
+// name of the repository also enabled for its new name
+func renamePluginConfig(pluginCfg *plugins.Configuration, from, to string) *plugins.Configuration {
+	renamed := *pluginCfg
+	renamed.Plugins = map[string][]string{}
+	for k, v := range pluginCfg.Plugins {
+		renamed.Plugins[k] = v
+	}
+	if _, ok := renamed.Plugins[to]; !ok && len(pluginCfg.Plugins[from]) > 0 {
+		renamed.Plugins[to] = pluginCfg.Plugins[from]
+	}
+	renamed.ExternalPlugins = map[string][]plugins.ExternalPlugin{}
+	for k, v := range pluginCfg.ExternalPlugins {
+		renamed.ExternalPlugins[k] = v
+	}
+	if _, ok := renamed.ExternalPlugins[to]; !ok && len(pluginCfg.ExternalPlugins[from]) > 0 {
+		renamed.ExternalPlugins[to] = pluginCfg.ExternalPlugins[from]
+	}
+	return &renamed
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse-config/pkg/config"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func renameTestConfigs(t *testing.T) (*config.Config, *plugins.Configuration) {
+	cfg := &config.Config{}
+	require.NoError(t, cfg.SetPresubmits(map[string][]config.Presubmit{
+		"org/old": {{JobBase: config.JobBase{Name: "lint"}, Reporter: config.Reporter{Context: "lint"}}},
+		"org":     {{JobBase: config.JobBase{Name: "license"}, Reporter: config.Reporter{Context: "license"}}},
+	}))
+	require.NoError(t, cfg.SetPostsubmits(map[string][]config.Postsubmit{
+		"org/old": {{JobBase: config.JobBase{Name: "release"}}},
+	}))
+	cfg.Keeper.Queries = config.KeeperQueries{
+		{Repos: []string{"org/old"}},
+		{Orgs: []string{"org"}},
+		{Repos: []string{"org/other"}},
+	}
+	pluginCfg := &plugins.Configuration{
+		Plugins:         map[string][]string{"org/old": {"trigger"}, "org": {"lgtm"}},
+		ExternalPlugins: map[string][]plugins.ExternalPlugin{"org/old": {{Name: "ext"}}},
+		Approve:         []plugins.Approve{{Repos: []string{"org/old"}}},
+		Lgtm:            []plugins.Lgtm{{Repos: []string{"org"}}},
+		Triggers:        []plugins.Trigger{{Repos: []string{"org/old"}}},
+	}
+	return cfg, pluginCfg
+}
+
+func TestRenameReport(t *testing.T) {
+	cfg, pluginCfg := renameTestConfigs(t)
+
+	assert.Equal(t, []string{
+		"approve[0]",
+		"external_plugins: org/old",
+		"plugins: org/old",
+		"postsubmits: org/old",
+		"presubmits: org/old",
+		"tide.queries[0]",
+		"triggers[0]",
+	}, renameReport(cfg, pluginCfg, "org/old", "org/new"))
+
+	assert.Equal(t, []string{
+		"approve[0]",
+		"external_plugins: org/old",
+		"lgtm[0]",
+		"plugins: org/old",
+		"postsubmits: org/old",
+		"presubmits: org",
+		"presubmits: org/old",
+		"tide.queries[0]",
+		"tide.queries[1]",
+		"triggers[0]",
+	}, renameReport(cfg, pluginCfg, "org/old", "other/old"), "transfers also report entries for the previous org")
+
+	assert.Empty(t, renameReport(nil, nil, "org/old", "org/new"))
+}
+
+func TestHandleRepositoryEvent(t *testing.T) {
+	cfg, pluginCfg := renameTestConfigs(t)
+	configAgent := &config.Agent{}
+	configAgent.Set(cfg)
+	pluginAgent := &plugins.ConfigAgent{}
+	pluginAgent.Set(pluginCfg)
+	s := &Server{ConfigAgent: configAgent, Plugins: pluginAgent}
+
+	hook := &scmprovider.RepositoryHook{
+		RepositoryHook: &scm.RepositoryHook{Repo: scm.Repository{Namespace: "org", Name: "new", FullName: "org/new"}},
+		RawAction:      scmprovider.RepositoryActionRenamed,
+		PreviousOwner:  "org",
+		PreviousName:   "old",
+	}
+	s.HandleRepositoryEvent(logrus.WithField("test", t.Name()), hook)
+
+	renamed := configAgent.Config()
+	require.Len(t, renamed.Presubmits["org/new"], 1)
+	assert.Equal(t, "lint", renamed.Presubmits["org/new"][0].Name)
+	assert.Len(t, renamed.Presubmits["org/old"], 1)
+	require.Len(t, renamed.Postsubmits["org/new"], 1)
+	assert.Equal(t, "release", renamed.Postsubmits["org/new"][0].Name)
+	assert.Empty(t, cfg.Presubmits["org/new"], "the previous config should not be modified")
+
+	renamedPlugins := pluginAgent.Config()
+	assert.Equal(t, []string{"trigger"}, renamedPlugins.Plugins["org/new"])
+	assert.Len(t, renamedPlugins.ExternalPlugins["org/new"], 1)
+	assert.Empty(t, pluginCfg.Plugins["org/new"])
+
+	configAgent.Set(cfg)
+	hook.Repo = scm.Repository{Namespace: "other", Name: "old", FullName: "other/old"}
+	s.HandleRepositoryEvent(logrus.WithField("test", t.Name()), hook)
+	transferred := configAgent.Config()
+	require.Len(t, transferred.Presubmits["other/old"], 2, "transfers should keep the jobs inherited from the previous org")
+	assert.Equal(t, "lint", transferred.Presubmits["other/old"][0].Name)
+	assert.Equal(t, "license", transferred.Presubmits["other/old"][1].Name)
+
+	hook.RawAction = "archived"
+	configAgent.Set(cfg)
+	s.HandleRepositoryEvent(logrus.WithField("test", t.Name()), hook)
+	assert.Empty(t, configAgent.Config().Presubmits["org/new"], "other actions should be ignored")
+}
+
+func TestPersistRename(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: util.ProwConfigMapName, Namespace: "jx"},
+			Data: map[string]string{util.ProwConfigFilename: `presubmits:
+  org/old:
+  - name: lint
+  org:
+  - name: license
+tide:
+  queries:
+  - repos:
+    - org/old
+  - orgs:
+    - org
+`},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: util.ProwPluginsConfigMapName, Namespace: "jx"},
+			Data: map[string]string{util.ProwPluginsFilename: `plugins:
+  org/old:
+  - trigger
+approve:
+- repos:
+  - org/old
+`},
+		},
+	)
+
+	persisted, err := persistRename(kubeClient, "jx", "org/old", "org/new")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"approve[0]", "plugins: org/old", "presubmits: org/old", "tide.queries[0]"}, persisted)
+
+	cm, err := kubeClient.CoreV1().ConfigMaps("jx").Get(util.ProwConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	cfg, err := config.LoadYAMLConfig([]byte(cm.Data[util.ProwConfigFilename]))
+	require.NoError(t, err)
+	assert.Len(t, cfg.Presubmits["org/new"], 1)
+	assert.Empty(t, cfg.Presubmits["org/old"])
+	assert.Len(t, cfg.Presubmits["org"], 1, "the jobs of the org should be left unchanged")
+	assert.Equal(t, []string{"org/new"}, cfg.Keeper.Queries[0].Repos)
+
+	cm, err = kubeClient.CoreV1().ConfigMaps("jx").Get(util.ProwPluginsConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, cm.Data[util.ProwPluginsFilename], "org/new")
+	assert.NotContains(t, cm.Data[util.ProwPluginsFilename], "org/old")
+
+	persisted, err = persistRename(kubeClient, "jx", "org/old", "org/new")
+	require.NoError(t, err)
+	assert.Empty(t, persisted, "the ConfigMaps should only be updated once")
+}
@@ -0,0 +1,254 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ReplayPath is the URL path for the HTTP endpoint that lists the recent webhook deliveries and
+	// re-submits one of them by its ID.
+	ReplayPath = "/replay"
+
+	// ReplaySizeEnvVar is the environment variable containing the number of recent webhook deliveries
+	// kept for replay. The replay endpoint is disabled when it is not set or is 0.
+	ReplaySizeEnvVar = "LIGHTHOUSE_REPLAY_SIZE"
+
+	// ReplayHeader is the request header which marked the replayed webhooks. It is stripped from the
+	// webhooks as the replays are marked with the request context, so that senders cannot pass webhooks
+	// off as replays to skip the deduplication and the recording of the deliveries.
+	ReplayHeader = "X-Lighthouse-Replay"
+)
+
+// webhookContextKey is the type of the keys of the values the webhook handler reads from the request context,
+// which only the handlers of Lighthouse can set
+type webhookContextKey int
+
+const (
+	// replayContextKey is the key of the ID of the delivery a replayed webhook re-submits
+	replayContextKey webhookContextKey = iota
+	// queuedContextKey is the key of the name of the ConfigMap of a queued webhook being processed
+	queuedContextKey
+)
+
+// withReplay returns the request marked as a replay of the delivery of the given ID
+func withReplay(r *http.Request, id string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), replayContextKey, id))
+}
+
+// replayOf returns the ID of the delivery the request replays, or an empty string if it is not a replay
+func replayOf(r *http.Request) string {
+	id, _ := r.Context().Value(replayContextKey).(string)
+	return id
+}
+
+// eventKindHeaders are the request headers of the providers containing the kind of the event
+var eventKindHeaders = []string{"X-GitHub-Event", "X-Gitlab-Event", "X-Gitea-Event", "X-Event-Key"}
+
+// delivery is the raw webhook received from the git provider
+type delivery struct {
+	id       string
+	received time.Time
+	header   http.Header
+	body     []byte
+}
+
+// DeliverySummary describes a webhook delivery which can be replayed
+type DeliverySummary struct {
+	ID       string    `json:"id"`
+	Event    string    `json:"event,omitempty"`
+	Received time.Time `json:"received"`
+	Size     int       `json:"size"`
+}
+
+// deliveryStore keeps the most recent webhook deliveries in memory
+type deliveryStore struct {
+	lock       sync.Mutex
+	size       int
+	deliveries []*delivery
+}
+
+// newDeliveryStoreFromEnv returns the store of the number of deliveries given by $LIGHTHOUSE_REPLAY_SIZE,
+// or nil if replays are disabled
+func newDeliveryStoreFromEnv() (*deliveryStore, error) {
+	value := os.Getenv(ReplaySizeEnvVar)
+	if value == "" {
+		return nil, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid $%s value %q, it must be a positive number of deliveries", ReplaySizeEnvVar, value)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	return newDeliveryStore(size), nil
+}
+
+func newDeliveryStore(size int) *deliveryStore {
+	return &deliveryStore{size: size}
+}
+
+// record keeps the delivery, evicting the oldest one if the store is full
+func (s *deliveryStore) record(id string, header http.Header, body []byte, at time.Time) {
+	if s == nil || id == "" {
+		return
+	}
+	d := &delivery{
+		id:       id,
+		received: at,
+		header:   header.Clone(),
+		body:     body,
+	}
+	// the credentials of the caller are never replayed
+	d.header.Del("Authorization")
+	d.header.Del("Cookie")
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, existing := range s.deliveries {
+		if existing.id == id {
+			s.deliveries = append(s.deliveries[:i], s.deliveries[i+1:]...)
+			break
+		}
+	}
+	if len(s.deliveries) >= s.size {
+		s.deliveries = s.deliveries[len(s.deliveries)-s.size+1:]
+	}
+	s.deliveries = append(s.deliveries, d)
+}
+
+// get returns the delivery of the given ID or nil if it is not kept
+func (s *deliveryStore) get(id string) *delivery {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, d := range s.deliveries {
+		if d.id == id {
+			return d
+		}
+	}
+	return nil
+}
+
+// list returns the summaries of the deliveries, the most recent first
+func (s *deliveryStore) list() []DeliverySummary {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	summaries := make([]DeliverySummary, 0, len(s.deliveries))
+	for i := len(s.deliveries) - 1; i >= 0; i-- {
+		d := s.deliveries[i]
+		summary := DeliverySummary{
+			ID:       d.id,
+			Received: d.received,
+			Size:     len(d.body),
+		}
+		for _, header := range eventKindHeaders {
+			if kind := d.header.Get(header); kind != "" {
+				summary.Event = kind
+				break
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// replayHandler lists the kept deliveries on GET and re-submits the delivery given by the id query
+// parameter to the webhook handler on POST
+type replayHandler struct {
+	store   *deliveryStore
+	path    string
+	handler http.HandlerFunc
+}
+
+func (h *replayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		data, err := json.MarshalIndent(h.store.list(), "", "  ")
+		if err != nil {
+			responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(data); err != nil {
+			logrus.WithError(err).Debug("failed to write the webhook deliveries")
+		}
+	case http.MethodPost:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "the id query parameter is required", http.StatusBadRequest)
+			return
+		}
+		d := h.store.get(id)
+		if d == nil {
+			http.Error(w, fmt.Sprintf("no webhook delivery %s is kept for replay", id), http.StatusNotFound)
+			return
+		}
+		replay, err := http.NewRequest(http.MethodPost, h.path, bytes.NewReader(d.body))
+		if err != nil {
+			responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
+			return
+		}
+		replay = withReplay(replay.WithContext(r.Context()), id)
+		replay.Header = d.header.Clone()
+		// the jobs are named after the delivery ID so a replay is given a new one to create its own jobs
+		// rather than to find those of the delivery it replays
+		guid := newID()
+		for _, header := range eventIDHeaders {
+			if replay.Header.Get(header) != "" {
+				replay.Header.Set(header, guid)
+			}
+		}
+		logrus.WithFields(logrus.Fields{"event-id": id, "replay-id": guid}).Info("replaying webhook delivery")
+		h.handler(w, replay)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// recordDelivery keeps the raw webhook for replay unless it is itself a replay
+func (o *Options) recordDelivery(r *http.Request, body []byte) {
+	if o.deliveries == nil || replayOf(r) != "" {
+		return
+	}
+	o.deliveries.record(deliveryID(r), r.Header, body, time.Now())
+}
+
+// deliveryID returns the ID of the delivery from the request headers, or an empty string if the
+// provider does not identify its deliveries
+func deliveryID(r *http.Request) string {
+	for _, header := range eventIDHeaders {
+		if id := r.Header.Get(header); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// newReplayHandler returns the replay handler authenticated with the admin token, or nil if replays
+// are disabled
+func (o *Options) newReplayHandler() http.Handler {
+	if o.deliveries == nil {
+		return nil
+	}
+	token := util.GetAdminToken()
+	if token == "" {
+		logrus.Warnf("ignoring $%s as webhook deliveries can only be replayed when $%s is set", ReplaySizeEnvVar, util.AdminTokenEnvVar)
+		return nil
+	}
+	return util.AdminHandler(token, &replayHandler{
+		store:   o.deliveries,
+		path:    o.Path,
+		handler: o.handleWebHookRequests,
+	})
+}
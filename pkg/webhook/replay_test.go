@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryStore(t *testing.T) {
+	store := newDeliveryStore(2)
+	now := time.Now()
+	for i, id := range []string{"a", "b", "c"} {
+		header := http.Header{}
+		header.Set("X-GitHub-Event", "push")
+		header.Set("Authorization", "token secret")
+		store.record(id, header, []byte(id+"-body"), now.Add(time.Duration(i)*time.Second))
+	}
+
+	assert.Nil(t, store.get("a"), "the oldest delivery should be evicted")
+	require.NotNil(t, store.get("c"))
+	assert.Equal(t, "c-body", string(store.get("c").body))
+	assert.Empty(t, store.get("c").header.Get("Authorization"))
+
+	summaries := store.list()
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "c", summaries[0].ID)
+	assert.Equal(t, "push", summaries[0].Event)
+	assert.Equal(t, len("c-body"), summaries[0].Size)
+	assert.Equal(t, "b", summaries[1].ID)
+}
+
+func TestReplayHandler(t *testing.T) {
+	store := newDeliveryStore(10)
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "pull_request")
+	header.Set("X-Hub-Signature", "sha1=abc")
+	header.Set("X-GitHub-Delivery", "delivery-1")
+	store.record("delivery-1", header, []byte(`{"action":"opened"}`), time.Now())
+
+	var replayed *http.Request
+	var replayedBody string
+	h := &replayHandler{
+		store: store,
+		path:  "/hook",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			replayed = r
+			data, _ := ioutil.ReadAll(r.Body)
+			replayedBody = string(data)
+			w.WriteHeader(http.StatusAccepted)
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		id     string
+		status int
+	}{
+		{name: "list", method: http.MethodGet, status: http.StatusOK},
+		{name: "no id", method: http.MethodPost, status: http.StatusBadRequest},
+		{name: "unknown id", method: http.MethodPost, id: "other", status: http.StatusNotFound},
+		{name: "replay", method: http.MethodPost, id: "delivery-1", status: http.StatusAccepted},
+		{name: "invalid method", method: http.MethodDelete, status: http.StatusMethodNotAllowed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, ReplayPath+"?id="+tc.id, nil)
+			r.Header.Set("Authorization", "Bearer admin-token")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, ReplayPath, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var summaries []DeliverySummary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summaries))
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "delivery-1", summaries[0].ID)
+	assert.Equal(t, "pull_request", summaries[0].Event)
+
+	require.NotNil(t, replayed)
+	assert.Equal(t, "/hook", replayed.URL.Path)
+	assert.Equal(t, `{"action":"opened"}`, replayedBody)
+	assert.Equal(t, "sha1=abc", replayed.Header.Get("X-Hub-Signature"))
+	assert.Equal(t, "delivery-1", replayOf(replayed))
+	assert.NotEmpty(t, deliveryID(replayed))
+	assert.NotEqual(t, "delivery-1", deliveryID(replayed), "the replay has a new delivery ID so its jobs are not those of the delivery")
+	assert.Equal(t, "delivery-1", store.get("delivery-1").header.Get("X-GitHub-Delivery"), "the kept delivery is unchanged")
+	assert.Empty(t, replayed.Header.Get("Authorization"))
+}
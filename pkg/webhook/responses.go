@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// UnconfiguredRepoStatusEnvVar is the environment variable containing the HTTP status, either 404
+// or 202, returned for webhooks from repositories without jobs in GitHub App mode. Defaults to 404.
+const UnconfiguredRepoStatusEnvVar = "LIGHTHOUSE_UNCONFIGURED_REPO_STATUS"
+
+const (
+	// EventIDHeader is the response header containing the ID of the event accepted for processing
+	EventIDHeader = "X-Lighthouse-Event-ID"
+	// CorrelationIDHeader is the response header containing the ID logged with an internal error
+	CorrelationIDHeader = "X-Lighthouse-Correlation-ID"
+)
+
+// eventIDHeaders are the request headers of the providers containing the ID of the delivery
+var eventIDHeaders = []string{"X-GitHub-Delivery", "X-Gitlab-Event-UUID", "X-Request-UUID", "X-Request-Id", "X-Gitea-Delivery"}
+
+// webhookResponse is the body of the responses to webhooks, so that the delivery logs of the git
+// providers tell what happened to each event
+type webhookResponse struct {
+	Message       string `json:"message"`
+	EventID       string `json:"eventID,omitempty"`
+	CorrelationID string `json:"correlationID,omitempty"`
+}
+
+// unconfiguredRepoError is returned when processing webhooks from repositories without jobs in GitHub App mode
+type unconfiguredRepoError struct {
+	link string
+}
+
+func (e *unconfiguredRepoError) Error() string {
+	return fmt.Sprintf("repository not configured: %s", e.link)
+}
+
+// unconfiguredRepoStatus returns the HTTP status returned for webhooks from unconfigured repositories
+func unconfiguredRepoStatus() int {
+	switch value := os.Getenv(UnconfiguredRepoStatusEnvVar); value {
+	case "":
+		return http.StatusNotFound
+	case "202":
+		return http.StatusAccepted
+	case "404":
+		return http.StatusNotFound
+	default:
+		logrus.Warnf("invalid $%s value %q, returning 404 for unconfigured repositories", UnconfiguredRepoStatusEnvVar, value)
+		return http.StatusNotFound
+	}
+}
+
+// parseErrorStatus returns the HTTP status of a webhook which failed to parse: 403 if its signature is
+// invalid and 400 otherwise
+func parseErrorStatus(err error) int {
+	if errors.Cause(err) == scm.ErrSignatureInvalid {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// processErrorStatus returns the HTTP status of a webhook which failed to process
+func processErrorStatus(err error) int {
+	if _, ok := errors.Cause(err).(*unconfiguredRepoError); ok {
+		return unconfiguredRepoStatus()
+	}
+	return http.StatusInternalServerError
+}
+
+// eventID returns the ID of the delivery of the webhook, from the hook or the request headers, or
+// a new ID if the provider does not identify its deliveries
+func eventID(webhook scm.Webhook, r *http.Request) string {
+	if webhook != nil {
+		if id := webhookStringField(webhook, "GUID"); id != "" {
+			return id
+		}
+	}
+	for _, header := range eventIDHeaders {
+		if id := r.Header.Get(header); id != "" {
+			return id
+		}
+	}
+	return newID()
+}
+
+func newID() string {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+// responseWebhook writes the JSON response to a webhook
+func responseWebhook(w http.ResponseWriter, statusCode int, response webhookResponse) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if response.EventID != "" {
+		w.Header().Set(EventIDHeader, response.EventID)
+	}
+	if response.CorrelationID != "" {
+		w.Header().Set(CorrelationIDHeader, response.CorrelationID)
+	}
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(data); err != nil {
+		logrus.WithError(err).Debug("failed to write the webhook response")
+	}
+}
+
+// responseWebhookError logs the error with a correlation ID and writes it in the response to the webhook,
+// so that the failed deliveries listed by the git provider can be matched with the logs
+func responseWebhookError(w http.ResponseWriter, l *logrus.Entry, statusCode int, eventID, message string, err error) {
+	response := webhookResponse{
+		Message: fmt.Sprintf("%d %s: %s", statusCode, http.StatusText(statusCode), message),
+		EventID: eventID,
+	}
+	if statusCode >= http.StatusInternalServerError {
+		response.CorrelationID = newID()
+	}
+	l.WithError(err).WithFields(logrus.Fields{
+		"status-code":    statusCode,
+		"event-id":       eventID,
+		"correlation-id": response.CorrelationID,
+	}).Warn(response.Message)
+	responseWebhook(w, statusCode, response)
+}
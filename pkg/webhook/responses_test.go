@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusForbidden, parseErrorStatus(scm.ErrSignatureInvalid))
+	assert.Equal(t, http.StatusForbidden, parseErrorStatus(errors.WithStack(scm.ErrSignatureInvalid)))
+	assert.Equal(t, http.StatusBadRequest, parseErrorStatus(fmt.Errorf("unexpected end of JSON input")))
+}
+
+func TestProcessErrorStatus(t *testing.T) {
+	origEnvVar := os.Getenv(UnconfiguredRepoStatusEnvVar)
+	defer os.Setenv(UnconfiguredRepoStatusEnvVar, origEnvVar)
+
+	unconfigured := &unconfiguredRepoError{link: "https://github.com/org/repo"}
+	assert.Equal(t, "repository not configured: https://github.com/org/repo", unconfigured.Error())
+
+	os.Unsetenv(UnconfiguredRepoStatusEnvVar)
+	assert.Equal(t, http.StatusNotFound, processErrorStatus(unconfigured))
+	os.Setenv(UnconfiguredRepoStatusEnvVar, "202")
+	assert.Equal(t, http.StatusAccepted, processErrorStatus(unconfigured))
+	os.Setenv(UnconfiguredRepoStatusEnvVar, "200")
+	assert.Equal(t, http.StatusNotFound, processErrorStatus(unconfigured))
+
+	assert.Equal(t, http.StatusInternalServerError, processErrorStatus(fmt.Errorf("boom")))
+}
+
+func TestEventID(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/hook", nil)
+	assert.Equal(t, "guid", eventID(&scm.PushHook{GUID: "guid"}, r))
+
+	r.Header.Set("X-GitHub-Delivery", "delivery")
+	assert.Equal(t, "delivery", eventID(&scm.PushHook{}, r))
+	assert.Equal(t, "delivery", eventID(nil, r))
+
+	r = httptest.NewRequest(http.MethodPost, "/hook", nil)
+	id := eventID(nil, r)
+	assert.NotEmpty(t, id)
+	assert.NotEqual(t, id, eventID(nil, r))
+}
+
+func TestResponseWebhook(t *testing.T) {
+	w := httptest.NewRecorder()
+	responseWebhook(w, http.StatusAccepted, webhookResponse{Message: "processed PR hook", EventID: "guid"})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "guid", w.Header().Get(EventIDHeader))
+	var response webhookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, webhookResponse{Message: "processed PR hook", EventID: "guid"}, response)
+
+	l := logrus.WithField("test", t.Name())
+	w = httptest.NewRecorder()
+	responseWebhookError(w, l, http.StatusForbidden, "guid", "failed to parse webhook: Invalid webhook signature", scm.ErrSignatureInvalid)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "403 Forbidden: failed to parse webhook: Invalid webhook signature", response.Message)
+	assert.Empty(t, response.CorrelationID, "client errors have no correlation ID")
+
+	w = httptest.NewRecorder()
+	responseWebhookError(w, l, http.StatusInternalServerError, "guid", "failed to process the webhook", fmt.Errorf("boom"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	response = webhookResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "500 Internal Server Error: failed to process the webhook", response.Message)
+	assert.NotEmpty(t, response.CorrelationID)
+	assert.Equal(t, response.CorrelationID, w.Header().Get(CorrelationIDHeader))
+}
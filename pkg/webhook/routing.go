@@ -0,0 +1,363 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/util"
+	"github.com/jenkins-x/lighthouse/pkg/version"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RoutesPath is the URL path for the HTTP endpoint that returns the routing table of a repository.
+	// It requires the admin token as it reveals the configuration of the repositories.
+	RoutesPath = "/routes"
+
+	// externalPluginsEvent is the event of the routes to external plugins
+	externalPluginsEvent = "external"
+)
+
+// eventRoute routes a kind of webhook to its handler and lists the plugin events it is delivered as
+type eventRoute struct {
+	// events are the plugin events the webhook is delivered as
+	events []routedEvent
+	// unconfiguredRepos processes the webhook even for repositories without jobs in GitHub App mode
+	unconfiguredRepos bool
+	// process adds the fields of the webhook to the log and invokes its handler, returning the
+	// response. It returns false if the webhook is not of the type expected for its kind.
+	process func(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool)
+}
+
+// routedEvent is a plugin event a webhook is delivered as, only for the given actions if any
+type routedEvent struct {
+	event   string
+	actions []scm.Action
+}
+
+// pullRequestCommentActions are the actions of pull request and review webhooks which are also
+// delivered to the plugins as generic comments, as the body of the pull request or review is user text
+var pullRequestCommentActions = []scm.Action{
+	scm.ActionCreate,
+	scm.ActionOpen,
+	scm.ActionSubmitted,
+	scm.ActionEdited,
+	scm.ActionDelete,
+	scm.ActionDismissed,
+	scm.ActionUpdate,
+}
+
+// eventRoutes is the routing table of the webhooks handled by lighthouse
+var eventRoutes = map[scm.WebhookKind]eventRoute{
+	scm.WebhookKindPing: {
+		unconfiguredRepos: true,
+		process:           processPing,
+	},
+	// renamed repositories are only known to the config by their previous name
+	scm.WebhookKindRepository: {
+		unconfiguredRepos: true,
+		process:           processRepository,
+	},
+	scm.WebhookKindPush: {
+		events:  []routedEvent{{event: plugins.PushEvent}},
+		process: processPush,
+	},
+	scm.WebhookKindRelease: {
+		events:  []routedEvent{{event: plugins.ReleaseEvent}},
+		process: processRelease,
+	},
+	scm.WebhookKindCheckRun: {
+		process: processCheckRun,
+	},
+	scm.WebhookKindPullRequest: {
+		events: []routedEvent{
+			{event: plugins.PullRequestEvent},
+			{event: plugins.GenericCommentEvent, actions: pullRequestCommentActions},
+		},
+		process: processPullRequest,
+	},
+	scm.WebhookKindBranch: {
+		process: processBranch,
+	},
+	scm.WebhookKindIssueComment: {
+		events: []routedEvent{
+			{event: plugins.IssueCommentEvent},
+			{event: plugins.GenericCommentEvent},
+		},
+		process: processIssueComment,
+	},
+	scm.WebhookKindPullRequestComment: {
+		events:  []routedEvent{{event: plugins.GenericCommentEvent}},
+		process: processPullRequestComment,
+	},
+	scm.WebhookKindReview: {
+		events: []routedEvent{
+			{event: plugins.ReviewEvent},
+			{event: plugins.GenericCommentEvent, actions: pullRequestCommentActions},
+		},
+		process: processReview,
+	},
+}
+
+// Route is an entry of the routing table of a repository, listing the plugins which react to a kind of webhook
+type Route struct {
+	// Kind is the kind of the webhook, e.g. "pull_request"
+	Kind string `json:"kind"`
+	// Actions restricts the route to webhooks with one of the actions, if any
+	Actions []string `json:"actions,omitempty"`
+	// Event is the plugin event the webhook is delivered as, or "external" for external plugins
+	Event string `json:"event"`
+	// Plugins are the plugins which react to the webhook
+	Plugins []string `json:"plugins"`
+}
+
+// RoutingTable returns the routes of the webhooks of the repository to the plugins enabled for it
+func RoutingTable(pluginAgent *plugins.ConfigAgent, org, repo string) []Route {
+	var kinds []string
+	for kind := range eventRoutes {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+
+	var routes []Route
+	for _, kind := range kinds {
+		for _, e := range eventRoutes[scm.WebhookKind(kind)].events {
+			names := pluginAgent.PluginsForEvent(e.event, org, repo)
+			if len(names) == 0 {
+				continue
+			}
+			var actions []string
+			for _, a := range e.actions {
+				actions = append(actions, a.String())
+			}
+			routes = append(routes, Route{Kind: kind, Actions: actions, Event: e.event, Plugins: names})
+		}
+		if kind == string(scm.WebhookKindPing) || pluginAgent.Config() == nil {
+			continue
+		}
+		var external []string
+		for _, p := range util.ExternalPluginsForEvent(pluginAgent, kind, scm.Join(org, repo)) {
+			external = append(external, p.Name)
+		}
+		if len(external) > 0 {
+			sort.Strings(external)
+			routes = append(routes, Route{Kind: kind, Event: externalPluginsEvent, Plugins: external})
+		}
+	}
+	return routes
+}
+
+// routes returns the routing table of the repository given by the repo query parameter as JSON
+func (o *Options) routes(w http.ResponseWriter, r *http.Request) {
+	org, repo := scm.Split(r.URL.Query().Get("repo"))
+	if org == "" || repo == "" {
+		http.Error(w, "the repo query parameter must be the full name of a repository, e.g. ?repo=org/repo", http.StatusBadRequest)
+		return
+	}
+	data, err := json.MarshalIndent(RoutingTable(o.server.Plugins, org, repo), "", "  ")
+	if err != nil {
+		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		logrus.WithError(err).Debug("failed to write the routing table")
+	}
+}
+
+func processPing(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	l.Info("received ping")
+	return l, fmt.Sprintf("pong from lighthouse %s", version.Version), true
+}
+
+func processRepository(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	repositoryHook, ok := webhook.(*scmprovider.RepositoryHook)
+	if !ok {
+		return l, "", false
+	}
+	l = l.WithFields(logrus.Fields{
+		"Action":           repositoryHook.RawAction,
+		"PreviousFullName": repositoryHook.PreviousFullName(),
+	})
+
+	l.Info("invoking Repository handler")
+
+	s.HandleRepositoryEvent(l, repositoryHook)
+	return l, "processed repository hook", true
+}
+
+func processPush(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	pushHook, ok := webhook.(*scm.PushHook)
+	if !ok {
+		return l, "", false
+	}
+	l = l.WithFields(logrus.Fields{
+		"Ref":                   pushHook.Ref,
+		"BaseRef":               pushHook.BaseRef,
+		"Commit.Sha":            pushHook.Commit.Sha,
+		"Commit.Link":           pushHook.Commit.Link,
+		"Commit.Author":         pushHook.Commit.Author,
+		"Commit.Message":        pushHook.Commit.Message,
+		"Commit.Committer.Name": pushHook.Commit.Committer.Name,
+	})
+
+	l.Info("invoking Push handler")
+
+	s.HandlePushEvent(l, pushHook)
+	return l, "processed push hook", true
+}
+
+func processRelease(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	releaseHook, ok := webhook.(*scmprovider.ReleaseHook)
+	if !ok {
+		return l, "", false
+	}
+	l = l.WithFields(logrus.Fields{
+		"Action":       releaseHook.RawAction,
+		"Release.Tag":  releaseHook.Release.Tag,
+		"Release.Name": releaseHook.Release.Name,
+		"Release.Link": releaseHook.Release.Link,
+	})
+
+	l.Info("invoking Release handler")
+
+	s.HandleReleaseEvent(l, releaseHook)
+	return l, "processed release hook", true
+}
+
+func processCheckRun(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	checkRunHook, ok := webhook.(*scmprovider.CheckRunHook)
+	if !ok {
+		return l, "", false
+	}
+	l = l.WithFields(logrus.Fields{
+		"Action":              checkRunHook.RawAction,
+		"CheckRun.Name":       checkRunHook.CheckRun.Name,
+		"CheckRun.ExternalID": checkRunHook.CheckRun.ExternalID,
+		"CheckRun.HeadSHA":    checkRunHook.CheckRun.HeadSHA,
+	})
+
+	l.Info("invoking CheckRun handler")
+
+	s.HandleCheckRunEvent(l, checkRunHook)
+	return l, "processed check run hook", true
+}
+
+func processPullRequest(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	prHook, ok := webhook.(*scm.PullRequestHook)
+	if !ok {
+		return l, "", false
+	}
+	pr := prHook.PullRequest
+	l = l.WithFields(logrus.Fields{
+		"Action":    prHook.Action.String(),
+		"PR.Number": pr.Number,
+		"PR.Ref":    pr.Ref,
+		"PR.Sha":    pr.Sha,
+		"PR.Title":  pr.Title,
+		"PR.Body":   pr.Body,
+	})
+
+	l.Info("invoking PR handler")
+
+	s.HandlePullRequestEvent(l, prHook)
+	return l, "processed PR hook", true
+}
+
+func processBranch(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	branchHook, ok := webhook.(*scm.BranchHook)
+	if !ok {
+		return l, "", false
+	}
+	l = l.WithFields(logrus.Fields{
+		"Action":      branchHook.Action.String(),
+		"Ref.Sha":     branchHook.Ref.Sha,
+		"Sender.Name": branchHook.Sender.Name,
+	})
+
+	l.Info("invoking branch handler")
+
+	s.HandleBranchEvent(l, branchHook)
+	return l, "processed branch hook", true
+}
+
+func processIssueComment(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	issueCommentHook, ok := webhook.(*scm.IssueCommentHook)
+	if !ok {
+		return l, "", false
+	}
+	issue := issueCommentHook.Issue
+	sender := issueCommentHook.Sender
+	l = l.WithFields(logrus.Fields{
+		"Action":       issueCommentHook.Action.String(),
+		"Issue.Number": issue.Number,
+		"Issue.Title":  issue.Title,
+		"Issue.Body":   issue.Body,
+		"Comment.Body": issueCommentHook.Comment.Body,
+		"Sender.Body":  sender.Name,
+		"Sender.Login": sender.Login,
+		"Kind":         "IssueCommentHook",
+	})
+
+	l.Info("invoking Issue Comment handler")
+
+	s.HandleIssueCommentEvent(l, *issueCommentHook)
+	return l, "processed issue comment hook", true
+}
+
+func processPullRequestComment(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	prCommentHook, ok := webhook.(*scm.PullRequestCommentHook)
+	if !ok {
+		return l, "", false
+	}
+	pr := prCommentHook.PullRequest
+	author := prCommentHook.Comment.Author
+	l = l.WithFields(logrus.Fields{
+		"Action":        prCommentHook.Action.String(),
+		"PR.Number":     pr.Number,
+		"PR.Ref":        pr.Ref,
+		"PR.Sha":        pr.Sha,
+		"PR.Title":      pr.Title,
+		"PR.Body":       pr.Body,
+		"Comment.Body":  prCommentHook.Comment.Body,
+		"Author.Name":   author.Name,
+		"Author.Login":  author.Login,
+		"Author.Avatar": author.Avatar,
+	})
+
+	l.Info("invoking PR Comment handler")
+
+	s.HandlePullRequestCommentEvent(l, *prCommentHook)
+	return l, "processed PR comment hook", true
+}
+
+func processReview(s *Server, l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, bool) {
+	prReviewHook, ok := webhook.(*scm.ReviewHook)
+	if !ok {
+		return l, "", false
+	}
+	pr := prReviewHook.PullRequest
+	reviewer := prReviewHook.Review.Author
+	l = l.WithFields(logrus.Fields{
+		"Action":          prReviewHook.Action.String(),
+		"PR.Number":       pr.Number,
+		"PR.Ref":          pr.Ref,
+		"PR.Sha":          pr.Sha,
+		"PR.Title":        pr.Title,
+		"PR.Body":         pr.Body,
+		"Review.State":    prReviewHook.Review.State,
+		"Reviewer.Name":   reviewer.Name,
+		"Reviewer.Login":  reviewer.Login,
+		"Reviewer.Avatar": reviewer.Avatar,
+	})
+
+	l.Info("invoking PR Review handler")
+
+	s.HandleReviewEvent(l, *prReviewHook)
+	return l, "processed PR review hook", true
+}
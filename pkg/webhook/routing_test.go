@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func routingPluginAgent() *plugins.ConfigAgent {
+	pluginAgent := &plugins.ConfigAgent{}
+	pluginAgent.Set(&plugins.Configuration{
+		Plugins: map[string][]string{
+			"org":      {"hold"},
+			"org/repo": {"trigger"},
+		},
+		ExternalPlugins: map[string][]plugins.ExternalPlugin{
+			"org": {{Name: "needs-rebase", Events: []string{"pull_request"}}},
+		},
+	})
+	return pluginAgent
+}
+
+func TestRoutingTable(t *testing.T) {
+	routes := RoutingTable(routingPluginAgent(), "org", "repo")
+
+	var commentActions []string
+	for _, a := range pullRequestCommentActions {
+		commentActions = append(commentActions, a.String())
+	}
+	expected := []Route{
+		{Kind: "issue_comment", Event: plugins.GenericCommentEvent, Plugins: []string{"hold", "trigger"}},
+		{Kind: "pull_request", Event: plugins.PullRequestEvent, Plugins: []string{"trigger"}},
+		{Kind: "pull_request", Actions: commentActions, Event: plugins.GenericCommentEvent, Plugins: []string{"hold", "trigger"}},
+		{Kind: "pull_request", Event: externalPluginsEvent, Plugins: []string{"needs-rebase"}},
+		{Kind: "pull_request_comment", Event: plugins.GenericCommentEvent, Plugins: []string{"hold", "trigger"}},
+		{Kind: "push", Event: plugins.PushEvent, Plugins: []string{"trigger"}},
+		{Kind: "release", Event: plugins.ReleaseEvent, Plugins: []string{"trigger"}},
+		{Kind: "review", Actions: commentActions, Event: plugins.GenericCommentEvent, Plugins: []string{"hold", "trigger"}},
+	}
+	assert.Equal(t, expected, routes)
+
+	assert.Empty(t, RoutingTable(routingPluginAgent(), "other", "repo"))
+}
+
+func TestRoutesEndpoint(t *testing.T) {
+	o := &Options{server: &Server{Plugins: routingPluginAgent()}}
+
+	w := httptest.NewRecorder()
+	o.routes(w, httptest.NewRequest(http.MethodGet, RoutesPath+"?repo=org/repo", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var routes []Route
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &routes))
+	assert.Equal(t, RoutingTable(o.server.Plugins, "org", "repo"), routes)
+
+	w = httptest.NewRecorder()
+	o.routes(w, httptest.NewRequest(http.MethodGet, RoutesPath+"?repo=org", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestProcessWebHookUnknownKind(t *testing.T) {
+	o := &Options{server: &Server{Plugins: routingPluginAgent()}}
+
+	_, output, err := o.ProcessWebHook(logrus.WithField("test", t.Name()), &scm.TagHook{Repo: scm.Repository{Namespace: "org", Name: "repo"}})
+	require.NoError(t, err)
+	assert.Equal(t, "unknown hook tag", output)
+
+	// a repository webhook go-scm parsed but whose previous name we could not parse is not routed
+	_, output, err = o.ProcessWebHook(logrus.WithField("test", t.Name()), &scm.RepositoryHook{Repo: scm.Repository{Namespace: "org", Name: "repo"}})
+	require.NoError(t, err)
+	assert.Equal(t, "unknown hook repository", output)
+}
@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// baseScopes are the OAuth scopes needed by all plugins to comment, label and report statuses
+var baseScopes = []string{"repo"}
+
+// pluginScopes are the OAuth scopes needed by plugins in addition to the base scopes
+var pluginScopes = map[string][]string{
+	"lgtm":       {"read:org"},
+	"override":   {"read:org"},
+	"sigmention": {"read:org"},
+	"trigger":    {"read:org"},
+}
+
+// tokenClient is the subset of the SCM provider client used to validate tokens
+type tokenClient interface {
+	TokenScopes() ([]string, bool, error)
+	GetRepositoryByFullName(fullName string) (*scm.Repository, error)
+	IsMember(org, user string) (bool, error)
+}
+
+// maxValidatedTokens is the number of validated tokens remembered, the oldest ones are forgotten
+// first so that the rotated GitHub App installation tokens do not accumulate
+const maxValidatedTokens = 100
+
+// tokenValidator validates that tokens grant the permissions needed by the enabled plugins.
+// Each token is only validated once, so rotated tokens are validated on first use.
+type tokenValidator struct {
+	validated sets.String
+	order     []string
+	lock      sync.Mutex
+}
+
+// validatedTokens remembers the tokens which have been validated by the webhook handler
+var validatedTokens = newTokenValidator()
+
+func newTokenValidator() *tokenValidator {
+	return &tokenValidator{validated: sets.NewString()}
+}
+
+// remember returns false if the token was already validated, otherwise it remembers it
+func (v *tokenValidator) remember(token string) bool {
+	sum := sha256.Sum256([]byte(token))
+	hash := string(sum[:])
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.validated.Has(hash) {
+		return false
+	}
+	if len(v.order) >= maxValidatedTokens {
+		v.validated.Delete(v.order[0])
+		v.order = v.order[1:]
+	}
+	v.validated.Insert(hash)
+	v.order = append(v.order, hash)
+	return true
+}
+
+// validate logs the permissions missing from the token for each enabled plugin. The permissions of
+// GitHub App installation tokens and fine-grained tokens, which do not report their scopes, are
+// probed by calling the APIs needed by the plugins.
+func (v *tokenValidator) validate(client tokenClient, token, botName string, isGitHubApp bool, cfg *plugins.Configuration) {
+	if cfg == nil || token == "" || !v.remember(token) {
+		return
+	}
+	if isGitHubApp {
+		probeToken(client, botName, cfg)
+		return
+	}
+
+	scopes, reported, err := client.TokenScopes()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to validate the scopes of the git token.")
+		return
+	}
+	if reported {
+		missing := missingPluginScopes(scopes, cfg)
+		for _, plugin := range sortedKeys(missing) {
+			logrus.WithField("plugin", plugin).Errorf("The git token is missing the OAuth scopes %s needed by the %s plugin.", strings.Join(missing[plugin], ", "), plugin)
+		}
+		if len(missing) == 0 {
+			logrus.Info("The git token has the OAuth scopes needed by the enabled plugins.")
+		}
+		return
+	}
+	if strings.HasPrefix(token, scmprovider.FineGrainedTokenPrefix) {
+		probeToken(client, botName, cfg)
+	}
+}
+
+// missingPluginScopes returns the scopes missing for each plugin enabled in any repository
+func missingPluginScopes(scopes []string, cfg *plugins.Configuration) map[string][]string {
+	missing := map[string][]string{}
+	for _, plugin := range enabledPlugins(cfg).List() {
+		required := append(append([]string{}, baseScopes...), pluginScopes[plugin]...)
+		if m := scmprovider.MissingScopes(scopes, required); len(m) > 0 {
+			missing[plugin] = m
+		}
+	}
+	return missing
+}
+
+// probeToken checks the permissions of tokens which do not report their scopes by calling
+// the APIs needed by the plugins enabled in each repository
+func probeToken(client tokenClient, botName string, cfg *plugins.Configuration) {
+	checkedOrgs := sets.NewString()
+	for _, repo := range sortedKeys(cfg.Plugins) {
+		enabled := cfg.Plugins[repo]
+		org := repo
+		l := logrus.WithField("repo", repo)
+		if strings.Contains(repo, "/") {
+			org, _ = scm.Split(repo)
+			if _, err := client.GetRepositoryByFullName(repo); err != nil {
+				l.WithError(err).Errorf("The git token cannot access the repository needed by the plugins %s.", strings.Join(enabled, ", "))
+				continue
+			}
+		}
+		var needMembers []string
+		for _, plugin := range enabled {
+			if sets.NewString(pluginScopes[plugin]...).Has("read:org") {
+				needMembers = append(needMembers, plugin)
+			}
+		}
+		if len(needMembers) == 0 || checkedOrgs.Has(org) {
+			continue
+		}
+		checkedOrgs.Insert(org)
+		if _, err := client.IsMember(org, botName); err != nil {
+			l.WithError(err).Errorf("The git token needs the organization members read permission for %s needed by the plugins %s.", org, strings.Join(needMembers, ", "))
+		}
+	}
+}
+
+func enabledPlugins(cfg *plugins.Configuration) sets.String {
+	enabled := sets.NewString()
+	for _, names := range cfg.Plugins {
+		enabled.Insert(names...)
+	}
+	return enabled
+}
+
+func sortedKeys(m map[string][]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTokenClient struct {
+	scopes   []string
+	reported bool
+	repos    []string
+	members  []string
+}
+
+func (f *fakeTokenClient) TokenScopes() ([]string, bool, error) {
+	return f.scopes, f.reported, nil
+}
+
+func (f *fakeTokenClient) GetRepositoryByFullName(fullName string) (*scm.Repository, error) {
+	f.repos = append(f.repos, fullName)
+	return nil, errors.New("not found")
+}
+
+func (f *fakeTokenClient) IsMember(org, user string) (bool, error) {
+	f.members = append(f.members, org)
+	return false, nil
+}
+
+func TestMissingPluginScopes(t *testing.T) {
+	cfg := &plugins.Configuration{
+		Plugins: map[string][]string{
+			"org":       {"trigger", "size"},
+			"org/repo":  {"lgtm"},
+			"other/foo": {"cat"},
+		},
+	}
+	assert.Empty(t, missingPluginScopes([]string{"repo", "read:org"}, cfg))
+	assert.Equal(t, map[string][]string{
+		"lgtm":    {"read:org"},
+		"trigger": {"read:org"},
+	}, missingPluginScopes([]string{"repo"}, cfg))
+	assert.Equal(t, map[string][]string{
+		"cat":     {"repo"},
+		"lgtm":    {"repo", "read:org"},
+		"size":    {"repo"},
+		"trigger": {"repo", "read:org"},
+	}, missingPluginScopes(nil, cfg))
+}
+
+func TestTokenValidatorValidatesEachTokenOnce(t *testing.T) {
+	cfg := &plugins.Configuration{
+		Plugins: map[string][]string{
+			"org":      {"trigger"},
+			"org/repo": {"lgtm"},
+		},
+	}
+	v := newTokenValidator()
+	client := &fakeTokenClient{}
+
+	v.validate(client, "github_pat_abc", "bot", false, cfg)
+	assert.Equal(t, []string{"org/repo"}, client.repos)
+	assert.Equal(t, []string{"org"}, client.members)
+
+	v.validate(client, "github_pat_abc", "bot", false, cfg)
+	assert.Len(t, client.repos, 1, "the token should only be validated once")
+
+	v.validate(client, "ghp_classic", "bot", false, cfg)
+	assert.Len(t, client.repos, 1, "classic tokens without reported scopes are not probed")
+
+	v.validate(client, "ghs_installation", "bot", true, cfg)
+	assert.Len(t, client.repos, 2, "GitHub App installation tokens are probed")
+}
+
+func TestTokenValidatorForgetsTheOldestTokens(t *testing.T) {
+	v := newTokenValidator()
+	for i := 0; i < maxValidatedTokens+1; i++ {
+		assert.True(t, v.remember(fmt.Sprintf("token-%d", i)))
+	}
+	assert.Len(t, v.order, maxValidatedTokens)
+	assert.Equal(t, maxValidatedTokens, v.validated.Len())
+	assert.False(t, v.remember(fmt.Sprintf("token-%d", maxValidatedTokens)))
+	assert.True(t, v.remember("token-0"), "the oldest token is forgotten")
+}
@@ -11,26 +11,38 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/factory"
 	"github.com/jenkins-x/jx/v2/pkg/jxfactory"
 	"github.com/jenkins-x/lighthouse-config/pkg/config"
 	"github.com/jenkins-x/lighthouse/pkg/clients"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/configcmd"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/gha"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/gitcredentials"
 	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/initcmd"
+	"github.com/jenkins-x/lighthouse/pkg/errorutil"
 	"github.com/jenkins-x/lighthouse/pkg/git"
-	"github.com/jenkins-x/lighthouse/pkg/jx"
+	"github.com/jenkins-x/lighthouse/pkg/gittoken"
+	"github.com/jenkins-x/lighthouse/pkg/identity"
+	"github.com/jenkins-x/lighthouse/pkg/jobutil"
 	"github.com/jenkins-x/lighthouse/pkg/launcher"
 	"github.com/jenkins-x/lighthouse/pkg/logrusutil"
 	"github.com/jenkins-x/lighthouse/pkg/metrics"
 	"github.com/jenkins-x/lighthouse/pkg/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/provenance"
+	"github.com/jenkins-x/lighthouse/pkg/repoowners"
+	"github.com/jenkins-x/lighthouse/pkg/scmprovider"
+	"github.com/jenkins-x/lighthouse/pkg/settings"
 	"github.com/jenkins-x/lighthouse/pkg/util"
-	"github.com/jenkins-x/lighthouse/pkg/version"
 	"github.com/jenkins-x/lighthouse/pkg/watcher"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const (
@@ -55,8 +67,20 @@ type Options struct {
 	botName          string
 	gitServerURL     string
 	configMapWatcher *watcher.ConfigMapWatcher
+	fileWatcher      *watcher.FileWatcher
+	kubeClients      *clients.Clients
+	configAgent      *config.Agent
+	settingsAgent    *settings.Agent
+	pluginAgent      *plugins.ConfigAgent
 	gitClient        git.Client
+	gitClients       gitClients
 	launcher         launcher.PipelineLauncher
+	provenance       *provenance.Agent
+	identityMapper   identity.Mapper
+	ownersCache      *repoowners.Cache
+	deliveries       *deliveryStore
+	dedup            *dedupStore
+	queue            *eventQueue
 }
 
 // NewCmdWebhook creates the command
@@ -72,19 +96,29 @@ func NewCmdWebhook() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().BoolVarP(&options.JSONLog, "json", "", true, "Enable JSON logging")
-	cmd.Flags().IntVarP(&options.Port, "port", "", 8080, "The TCP port to listen on.")
-	cmd.Flags().StringVarP(&options.BindAddress, "bind", "", "",
-		"The interface address to bind to (by default, will listen on all interfaces/addresses).")
-	cmd.Flags().StringVarP(&options.Path, "path", "", "/hook",
-		"The path to listen on for requests to trigger a pipeline run.")
-	cmd.Flags().StringVar(&options.pluginFilename, "plugin-file", "", "Path to the plugins.yaml file. If not specified it is loaded from the 'plugins' ConfigMap")
-	cmd.Flags().StringVar(&options.configFilename, "config-file", "", "Path to the config.yaml file. If not specified it is loaded from the 'config' ConfigMap")
-	cmd.Flags().StringVar(&options.botName, "bot-name", "", "The name of the bot user to run as. Defaults to $GIT_USER if not specified.")
+	options.AddFlags(cmd.Flags())
+
+	cmd.AddCommand(configcmd.NewCmdConfig())
+	cmd.AddCommand(gha.NewCmdGHA())
+	cmd.AddCommand(gitcredentials.NewCmdGitCredentials())
+	cmd.AddCommand(initcmd.NewCmdInit())
 
 	return cmd
 }
 
+// AddFlags adds the command line flags of the webhook handler
+func (o *Options) AddFlags(flags *pflag.FlagSet) {
+	flags.BoolVarP(&o.JSONLog, "json", "", true, "Enable JSON logging")
+	flags.IntVarP(&o.Port, "port", "", 8080, "The TCP port to listen on.")
+	flags.StringVarP(&o.BindAddress, "bind", "", "",
+		"The interface address to bind to (by default, will listen on all interfaces/addresses).")
+	flags.StringVarP(&o.Path, "path", "", "/hook",
+		"The path to listen on for requests to trigger a pipeline run.")
+	flags.StringVar(&o.pluginFilename, "plugin-file", "", "Path to the plugins.yaml file, which is reloaded when it changes. If not specified it is loaded from the 'plugins' ConfigMap")
+	flags.StringVar(&o.configFilename, "config-file", "", "Path to the config.yaml file, which is reloaded when it changes. If not specified it is loaded from the 'config' ConfigMap")
+	flags.StringVar(&o.botName, "bot-name", "", "The name of the bot user to run as. Defaults to $GIT_USER if not specified.")
+}
+
 // NewWebhook creates a new webhook handler
 func NewWebhook(factory jxfactory.Factory, server *Server) *Options {
 	return &Options{
@@ -93,6 +127,26 @@ func NewWebhook(factory jxfactory.Factory, server *Server) *Options {
 	}
 }
 
+// SetConfigAgents makes the webhook handler use the given config agents, which are kept up to date
+// by the caller, rather than watching the configuration ConfigMaps itself
+func (o *Options) SetConfigAgents(configAgent *config.Agent, settingsAgent *settings.Agent, pluginAgent *plugins.ConfigAgent) {
+	o.configAgent = configAgent
+	o.settingsAgent = settingsAgent
+	o.pluginAgent = pluginAgent
+}
+
+// ConfigFiles returns the paths of the config.yaml and plugins.yaml files given with --config-file and
+// --plugin-file, which are empty if the files are loaded from the ConfigMaps
+func (o *Options) ConfigFiles() (string, string) {
+	return o.configFilename, o.pluginFilename
+}
+
+// SetClients makes the webhook handler use the given Kubernetes clients, which are shared with other
+// components, rather than creating the clients of the webhooks
+func (o *Options) SetClients(kubeClients *clients.Clients) {
+	o.kubeClients = kubeClients
+}
+
 // Run will implement this command
 func (o *Options) Run() error {
 	if o.JSONLog {
@@ -104,16 +158,27 @@ func (o *Options) Run() error {
 		return errors.Wrapf(err, "failed to create JX Client")
 	}
 	o.namespace = ns
+	if o.kubeClients == nil {
+		o.kubeClients, err = clients.GetClientsForComponent(o.GetFactory(), clients.Webhooks)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the Kubernetes clients")
+		}
+	}
+	clients.ReportMissingPermissions(o.kubeClients.Kube, clients.Webhooks, o.kubeClients.Namespace)
 	o.server, err = o.createHookServer()
 	if err != nil {
 		return errors.Wrapf(err, "failed to create Hook Server")
 	}
 	defer o.configMapWatcher.Stop()
+	defer o.fileWatcher.Stop()
+	go o.server.activity.run(stopper())
 
-	_, o.gitServerURL, err = o.createSCMClient()
+	scmClient, gitServerURL, err := o.createSCMClient()
 	if err != nil {
 		return errors.Wrapf(err, "failed to create ScmClient")
 	}
+	o.gitServerURL = gitServerURL
+	o.validateToken(scmClient)
 
 	gitClient, err := git.NewClient(o.gitServerURL, o.gitKind())
 	if err != nil {
@@ -124,19 +189,66 @@ func (o *Options) Run() error {
 		if err != nil {
 			logrus.WithError(err).Fatal("Error cleaning the git client.")
 		}
+		err = o.cleanGitClients()
+		if err != nil {
+			logrus.WithError(err).Fatal("Error cleaning the git clients of the git servers.")
+		}
 	}()
 
 	o.gitClient = gitClient
 
-	o.launcher, err = jx.NewLauncher()
+	o.launcher, err = launcher.NewAgentLauncher(launcher.Options{Clients: o.kubeClients, Config: o.configAgent.Config, Settings: o.settingsAgent.Config})
 	if err != nil {
 		err = errors.Wrapf(err, "failed to create PipelineLauncher client")
 		logrus.Errorf("%s", err.Error())
 		return err
 	}
+	if scheduler, ok := o.launcher.(launcher.Scheduler); ok {
+		// the jobs queued by keeper are also released by the webhooks
+		go scheduler.RunScheduler(stopper())
+	}
+	o.provenance = provenance.NewAgent(o.settingsAgent.Config)
+	o.ownersCache = repoowners.NewCache()
+	o.identityMapper, err = identity.NewMapperFromEnv()
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the identity mapper")
+	}
 	mux := http.NewServeMux()
 	mux.Handle(HealthPath, http.HandlerFunc(o.health))
 	mux.Handle(ReadyPath, http.HandlerFunc(o.ready))
+	mux.Handle(RoutesPath, util.AdminHandler(util.GetAdminToken(), http.HandlerFunc(o.routes)))
+	mux.Handle(AdoptionPath, util.AdminHandler(util.GetAdminToken(), http.HandlerFunc(o.adoption)))
+	jobs := o.newJobsAPIHandler()
+	mux.Handle(JobsAPIPath, jobs)
+	mux.Handle(JobsAPIPath+"/", jobs)
+	o.deliveries, err = newDeliveryStoreFromEnv()
+	if err != nil {
+		return err
+	}
+	o.dedup, err = newDedupStoreFromEnv(o.kubeClients.Kube, o.kubeClients.Namespace)
+	if err != nil {
+		return err
+	}
+	go o.dedup.run(stopper())
+	o.queue, err = newEventQueueFromEnv(o.kubeClients.Kube, o.kubeClients.Namespace, o.handleWebHookRequests)
+	if err != nil {
+		return err
+	}
+	go o.queue.run(stopper())
+	if replay := o.newReplayHandler(); replay != nil {
+		mux.Handle(ReplayPath, replay)
+	}
+	gitCredentials, err := o.newGitCredentialsHandler()
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the git credentials handler")
+	}
+	if gitCredentials != nil {
+		mux.Handle(GitCredentialsPath, gitCredentials)
+	}
+
+	if err := serveAdmissionFromEnv(); err != nil {
+		return errors.Wrapf(err, "failed to serve the admission webhook")
+	}
 
 	mux.Handle("/", http.HandlerFunc(o.defaultHandler))
 	mux.Handle(o.Path, http.HandlerFunc(o.handleWebHookRequests))
@@ -187,102 +299,207 @@ func (o *Options) handleWebHookRequests(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	logrus.Debug("about to parse webhook")
+	// the replays and the queued webhooks are marked with the request context rather than with headers
+	r.Header.Del(ReplayHeader)
+	r.Header.Del(QueuedHeader)
 
-	bodyBytes, err := ioutil.ReadAll(r.Body)
+	l := logrus.NewEntry(logrus.StandardLogger())
+	// the webhooks are verified with the HMAC token of the git server they name
+	gitServer, err := o.gitServerOf(r)
+	if err != nil {
+		responseWebhookError(w, l, http.StatusBadRequest, eventID(nil, r), err.Error(), err)
+		return
+	}
+	if gitServer != nil {
+		l = l.WithField("git-server", gitServer.Name)
+	}
+	hmacToken := o.hmacTokenFor(gitServer)
+	p, err := readPayload(r, hmacToken, o.settingsAgent.Config().Webhooks.GetMaxPayloadSize())
 	if err != nil {
-		logrus.Errorf("failed to Read Body: %s", err.Error())
-		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: Read Body: %s", err.Error()))
+		status, reason := payloadErrorStatus(err)
+		payloadRejectedCounter.WithLabelValues(reason).Inc()
+		message := err.Error()
+		if status == http.StatusInternalServerError {
+			message = "failed to read the body"
+		}
+		responseWebhookError(w, l, status, eventID(nil, r), message, err)
 		return
 	}
+	payloadSizeHistogram.WithLabelValues(p.encoding).Observe(float64(len(p.raw)))
 
 	err = r.Body.Close() // must close
 	if err != nil {
-		logrus.Errorf("failed to Close Body: %s", err.Error())
-		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: Read Close: %s", err.Error()))
+		responseWebhookError(w, l, http.StatusInternalServerError, eventID(nil, r), "failed to close the body", err)
 		return
 	}
 
+	bodyBytes := p.body
 	r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-	scmClient, serverURL, err := o.createSCMClient()
+	// the deliveries are replayed as delivered
+	o.recordDelivery(r, p.raw)
+	scmClient, serverURL, err := o.createSCMClientFor(gitServer)
 	if err != nil {
-		logrus.Errorf("failed to create SCM scmClient: %s", err.Error())
-		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: Failed to parse webhook: %s", err.Error()))
+		responseWebhookError(w, l, http.StatusInternalServerError, eventID(nil, r), "failed to create the SCM client", err)
 		return
 	}
 
-	webhook, err := scmClient.Webhooks.Parse(r, o.secretFn)
+	secretFn := func(scm.Webhook) (string, error) {
+		return hmacToken, nil
+	}
+	if p.signatureVerified {
+		// the signature is of the body as delivered, which may be compressed, and was verified already
+		secretFn = noSecret
+	}
+	var webhook scm.Webhook
+	if scmClient.Driver == scm.DriverGitea && scmprovider.IsGiteaReviewWebhook(r) {
+		// go-scm does not parse the review webhooks of Gitea
+		webhook, err = scmprovider.ParseGiteaReviewWebhook(scmClient, r, bodyBytes, secretFn)
+	} else {
+		webhook, err = scmClient.Webhooks.Parse(r, secretFn)
+	}
 	if err != nil {
-		logrus.Warnf("failed to parse webhook: %s", err.Error())
-
-		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: Failed to parse webhook: %s", err.Error()))
+		responseWebhookError(w, l, parseErrorStatus(err), eventID(nil, r), fmt.Sprintf("failed to parse webhook: %s", err.Error()), err)
 		return
 	}
 	if webhook == nil {
-		logrus.Error("no webhook was parsed")
+		responseWebhookError(w, l, http.StatusBadRequest, eventID(nil, r), "no webhook could be parsed", nil)
+		return
+	}
 
-		responseHTTPError(w, http.StatusInternalServerError, "500 Internal Server Error: No webhook could be parsed")
+	// go-scm does not expose the release details so parse them from the payload
+	if releaseHook, ok := webhook.(*scm.ReleaseHook); ok {
+		webhook, err = scmprovider.ParseReleaseHook(scmClient.Driver, releaseHook, bodyBytes)
+		if err != nil {
+			responseWebhookError(w, l, http.StatusBadRequest, eventID(releaseHook, r), fmt.Sprintf("failed to parse release webhook: %s", err.Error()), err)
+			return
+		}
+	}
+	// go-scm does not expose the check run details so parse them from the payload
+	if checkRunHook, ok := webhook.(*scm.CheckRunHook); ok {
+		webhook, err = scmprovider.ParseCheckRunHook(checkRunHook, bodyBytes)
+		if err != nil {
+			responseWebhookError(w, l, http.StatusBadRequest, eventID(checkRunHook, r), fmt.Sprintf("failed to parse check run webhook: %s", err.Error()), err)
+			return
+		}
+	}
+	// go-scm does not expose the previous name of renamed repositories so parse it from the payload
+	if repositoryHook, ok := webhook.(*scm.RepositoryHook); ok {
+		webhook, err = scmprovider.ParseRepositoryHook(repositoryHook, bodyBytes)
+		if err != nil {
+			responseWebhookError(w, l, http.StatusBadRequest, eventID(repositoryHook, r), fmt.Sprintf("failed to parse repository webhook: %s", err.Error()), err)
+			return
+		}
+	}
+	id := eventID(webhook, r)
+	l = l.WithField("event-id", id)
+
+	// the webhooks are acknowledged once queued and processed by the workers of the queue
+	if o.queue != nil && queuedOf(r) == "" {
+		queued, err := o.queue.enqueue(r.Header, p.raw, replayOf(r))
+		if err == nil {
+			l.WithField("queued", queued).Debug("queued webhook")
+			responseWebhook(w, http.StatusAccepted, webhookResponse{Message: "webhook queued", EventID: id})
+			return
+		}
+		l.WithError(err).Warn("failed to queue the webhook, processing it now")
+	}
+
+	// the git providers may deliver the same webhook again, while replays are meant to process it again
+	deliveryGUID := deliveryID(r)
+	if replayOf(r) != "" {
+		deliveryGUID = ""
+	}
+	if !o.dedup.claim(deliveryGUID) {
+		duplicateDeliveryCounter.WithLabelValues(string(webhook.Kind())).Inc()
+		l.Info("skipping webhook delivery which was processed already")
+		responseWebhook(w, http.StatusOK, webhookResponse{Message: "duplicate delivery skipped", EventID: id})
 		return
 	}
+	processed := false
+	defer func() {
+		o.dedup.done(deliveryGUID, processed)
+	}()
 
 	ghaSecretDir := util.GetGitHubAppSecretDir()
 
+	botName := o.gitServerBotName(gitServer)
 	var gitCloneUser string
 	var token string
-	if ghaSecretDir != "" {
+	if gitServer != nil {
+		// the GitHub App of the GitHub App mode is installed on the default server
+		ghaSecretDir = ""
+		gitCloneUser = botName
+		token, err = gitServer.Token()
+		if err != nil {
+			responseWebhookError(w, l, http.StatusInternalServerError, id, "no scm token specified", err)
+			return
+		}
+	} else if ghaSecretDir != "" {
 		gitCloneUser = util.GitHubAppGitRemoteUsername
-		tokenFinder := util.NewOwnerTokensDir(serverURL, ghaSecretDir)
+		tokenFinder := util.GetGitHubAppTokenManager(serverURL, ghaSecretDir)
 		token, err = tokenFinder.FindToken(webhook.Repository().Namespace)
 		if err != nil {
-			logrus.Errorf("failed to read owner token: %s", err.Error())
-			responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: failed to read owner token: %s", err.Error()))
+			responseWebhookError(w, l, http.StatusInternalServerError, id, "failed to read owner token", err)
 			return
 		}
 	} else {
 		gitCloneUser = o.GetBotName()
 		token, err = o.createSCMToken(o.gitKind())
 		if err != nil {
-			logrus.Errorf("no scm token specified: %s", err.Error())
-			responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: no scm token specified: %s", err.Error()))
+			responseWebhookError(w, l, http.StatusInternalServerError, id, "no scm token specified", err)
 			return
 		}
 	}
-	_, _, kubeClient, lhClient, _, err := clients.GetClientsAndNamespace(nil)
+
+	gitClient, err := o.gitClientFor(gitServer)
 	if err != nil {
-		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
+		responseWebhookError(w, l, http.StatusInternalServerError, id, "failed to create the git client", err)
+		return
 	}
-
-	o.gitClient.SetCredentials(gitCloneUser, func() []byte {
+	gitClient.SetCredentials(gitCloneUser, func() []byte {
 		return []byte(token)
 	})
 	util.AddAuthToSCMClient(scmClient, token, ghaSecretDir != "")
+	go validatedTokens.validate(scmprovider.ToClient(scmClient, botName), token, botName, ghaSecretDir != "", o.server.Plugins.Config())
 
 	o.server.ClientAgent = &plugins.ClientAgent{
-		BotName:           o.GetBotName(),
+		BotName:           botName,
 		SCMProviderClient: scmClient,
-		KubernetesClient:  kubeClient,
-		GitClient:         o.gitClient,
-		LighthouseClient:  lhClient.LighthouseV1alpha1().LighthouseJobs(o.namespace),
-		LauncherClient:    o.launcher,
-	}
-	l, output, err := o.ProcessWebHook(logrus.WithField("Webhook", webhook.Kind()), webhook)
+		KubernetesClient:  o.kubeClients.Kube,
+		GitClient:         gitClient,
+		LighthouseClient:  o.kubeClients.Lighthouse.LighthouseV1alpha1().LighthouseJobs(o.namespace),
+		LauncherClient:    o.provenanceLauncher(webhook, bodyBytes),
+		IdentityMapper:    o.identityMapper,
+		OwnersCache:       o.ownersCache,
+		Settings:          o.settingsAgent.Config,
+	}
+	l, output, err := o.ProcessWebHook(l.WithField("Webhook", webhook.Kind()), webhook)
 	if err != nil {
-		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
+		status := processErrorStatus(err)
+		message := err.Error()
+		if status == http.StatusInternalServerError {
+			message = errorutil.UserMessage(err, "failed to process the webhook")
+		}
+		responseWebhookError(w, l, status, id, message, err)
+		return
 	}
+	processed = true
+	o.notifyKeeper(l, webhook)
+
 	// Demux events only to external plugins that require this event.
 	if external := util.ExternalPluginsForEvent(o.server.Plugins, string(webhook.Kind()), webhook.Repository().FullName); len(external) > 0 {
-		go util.CallExternalPluginsWithWebhook(l, external, webhook, o.hmacToken(), &o.server.wg)
+		responder := scmprovider.ToClient(scmClient, botName)
+		go util.CallExternalPluginsWithWebhook(l, external, webhook, o.hmacToken(), responder, &o.server.wg)
 	}
 
-	_, err = w.Write([]byte(output))
-	if err != nil {
-		l.Debugf("failed to process the webhook: %v", err)
-	}
+	// the plugins handle the event asynchronously
+	responseWebhook(w, http.StatusAccepted, webhookResponse{Message: output, EventID: id})
 }
 
 // ProcessWebHook process a webhook
 func (o *Options) ProcessWebHook(l *logrus.Entry, webhook scm.Webhook) (*logrus.Entry, string, error) {
 	repository := webhook.Repository()
-	fields := map[string]interface{}{
+	l = l.WithFields(logrus.Fields{
 		"Namespace": repository.Namespace,
 		"Name":      repository.Name,
 		"Branch":    repository.Branch,
@@ -290,132 +507,24 @@ func (o *Options) ProcessWebHook(l *logrus.Entry, webhook scm.Webhook) (*logrus.
 		"ID":        repository.ID,
 		"Clone":     repository.Clone,
 		"Webhook":   webhook.Kind(),
-	}
-	l = l.WithFields(logrus.Fields(fields))
-	_, ok := webhook.(*scm.PingHook)
-	if ok {
-		l.Info("received ping")
-		return l, fmt.Sprintf("pong from lighthouse %s", version.Version), nil
-	}
+	})
+	route, ok := eventRoutes[webhook.Kind()]
 	// If we are in GitHub App mode and have a populated config, check if the repository for this webhook is one we actually
 	// know about and error out if not.
-	if util.GetGitHubAppSecretDir() != "" && o.server.ConfigAgent != nil {
+	if !route.unconfiguredRepos && util.GetGitHubAppSecretDir() != "" && o.server.ConfigAgent != nil {
 		cfg := o.server.ConfigAgent.Config()
 		if cfg != nil {
-			if len(cfg.GetPostsubmits(repository)) == 0 && len(cfg.GetPresubmits(repository)) == 0 {
+			if len(jobutil.Postsubmits(cfg, repository)) == 0 && len(jobutil.Presubmits(cfg, repository)) == 0 {
 				l.Infof("webhook from unconfigured repository %s, returning error", repository.Link)
-				return l, "", fmt.Errorf("repository not configured: %s", repository.Link)
+				return l, "", &unconfiguredRepoError{link: repository.Link}
 			}
 		}
 	}
-	pushHook, ok := webhook.(*scm.PushHook)
+	o.server.activity.recordEvent(scm.Join(repository.Namespace, repository.Name), time.Now())
 	if ok {
-		fields["Ref"] = pushHook.Ref
-		fields["BaseRef"] = pushHook.BaseRef
-		fields["Commit.Sha"] = pushHook.Commit.Sha
-		fields["Commit.Link"] = pushHook.Commit.Link
-		fields["Commit.Author"] = pushHook.Commit.Author
-		fields["Commit.Message"] = pushHook.Commit.Message
-		fields["Commit.Committer.Name"] = pushHook.Commit.Committer.Name
-
-		l.Info("invoking Push handler")
-
-		o.server.HandlePushEvent(l, pushHook)
-		return l, "processed push hook", nil
-	}
-	prHook, ok := webhook.(*scm.PullRequestHook)
-	if ok {
-		action := prHook.Action
-		fields["Action"] = action.String()
-		pr := prHook.PullRequest
-		fields["PR.Number"] = pr.Number
-		fields["PR.Ref"] = pr.Ref
-		fields["PR.Sha"] = pr.Sha
-		fields["PR.Title"] = pr.Title
-		fields["PR.Body"] = pr.Body
-
-		l.Info("invoking PR handler")
-
-		o.server.HandlePullRequestEvent(l, prHook)
-		return l, "processed PR hook", nil
-	}
-	branchHook, ok := webhook.(*scm.BranchHook)
-	if ok {
-		action := branchHook.Action
-		ref := branchHook.Ref
-		sender := branchHook.Sender
-		fields["Action"] = action.String()
-		fields["Ref.Sha"] = ref.Sha
-		fields["Sender.Name"] = sender.Name
-
-		l.Info("invoking branch handler")
-
-		o.server.HandleBranchEvent(l, branchHook)
-		return l, "processed branch hook", nil
-	}
-	issueCommentHook, ok := webhook.(*scm.IssueCommentHook)
-	if ok {
-		action := issueCommentHook.Action
-		issue := issueCommentHook.Issue
-		comment := issueCommentHook.Comment
-		sender := issueCommentHook.Sender
-		fields["Action"] = action.String()
-		fields["Issue.Number"] = issue.Number
-		fields["Issue.Title"] = issue.Title
-		fields["Issue.Body"] = issue.Body
-		fields["Comment.Body"] = comment.Body
-		fields["Sender.Body"] = sender.Name
-		fields["Sender.Login"] = sender.Login
-		fields["Kind"] = "IssueCommentHook"
-
-		l.Info("invoking Issue Comment handler")
-
-		o.server.HandleIssueCommentEvent(l, *issueCommentHook)
-		return l, "processed issue comment hook", nil
-	}
-	prCommentHook, ok := webhook.(*scm.PullRequestCommentHook)
-	if ok {
-		action := prCommentHook.Action
-		fields["Action"] = action.String()
-		pr := prCommentHook.PullRequest
-		fields["PR.Number"] = pr.Number
-		fields["PR.Ref"] = pr.Ref
-		fields["PR.Sha"] = pr.Sha
-		fields["PR.Title"] = pr.Title
-		fields["PR.Body"] = pr.Body
-		comment := prCommentHook.Comment
-		fields["Comment.Body"] = comment.Body
-		author := comment.Author
-		fields["Author.Name"] = author.Name
-		fields["Author.Login"] = author.Login
-		fields["Author.Avatar"] = author.Avatar
-
-		l.Info("invoking PR Comment handler")
-
-		l.Info("invoking Issue Comment handler")
-
-		o.server.HandlePullRequestCommentEvent(l, *prCommentHook)
-		return l, "processed PR comment hook", nil
-	}
-	prReviewHook, ok := webhook.(*scm.ReviewHook)
-	if ok {
-		action := prReviewHook.Action
-		fields["Action"] = action.String()
-		pr := prReviewHook.PullRequest
-		fields["PR.Number"] = pr.Number
-		fields["PR.Ref"] = pr.Ref
-		fields["PR.Sha"] = pr.Sha
-		fields["PR.Title"] = pr.Title
-		fields["PR.Body"] = pr.Body
-		fields["Review.State"] = prReviewHook.Review.State
-		fields["Reviewer.Name"] = prReviewHook.Review.Author.Name
-		fields["Reviewer.Login"] = prReviewHook.Review.Author.Login
-		fields["Reviewer.Avatar"] = prReviewHook.Review.Author.Avatar
-
-		l.Info("invoking PR Review handler")
-
-		o.server.HandleReviewEvent(l, *prReviewHook)
-		return l, "processed PR review hook", nil
+		if routed, output, processed := route.process(o.server, l, webhook); processed {
+			return routed, output, nil
+		}
 	}
 	l.Debugf("unknown kind %s webhook %#v", webhook.Kind(), webhook)
 	return l, fmt.Sprintf("unknown hook %s", webhook.Kind()), nil
@@ -433,8 +542,9 @@ func (o *Options) hmacToken() string {
 	return os.Getenv("HMAC_TOKEN")
 }
 
-func (o *Options) secretFn(webhook scm.Webhook) (string, error) {
-	return o.hmacToken(), nil
+// noSecret skips the verification of the signatures of the webhooks by go-scm
+func noSecret(webhook scm.Webhook) (string, error) {
+	return "", nil
 }
 
 func (o *Options) createSCMClient() (*scm.Client, string, error) {
@@ -469,65 +579,45 @@ func (o *Options) GetBotName() string {
 	return o.botName
 }
 
+// validateToken validates the git token at startup so that missing permissions are reported
+// before the first webhook. GitHub App installation tokens are validated on first use instead.
+func (o *Options) validateToken(scmClient *scm.Client) {
+	if util.GetGitHubAppSecretDir() != "" {
+		return
+	}
+	token, err := o.createSCMToken(o.gitKind())
+	if err != nil {
+		logrus.WithError(err).Warn("failed to validate the git token")
+		return
+	}
+	util.AddAuthToSCMClient(scmClient, token, false)
+	validatedTokens.validate(scmprovider.ToClient(scmClient, o.GetBotName()), token, o.GetBotName(), false, o.server.Plugins.Config())
+}
+
 func (o *Options) createSCMToken(gitKind string) (string, error) {
-	envName := "GIT_TOKEN"
-	value := os.Getenv(envName)
-	if value == "" {
-		return value, fmt.Errorf("No token available for git kind %s at environment variable $%s", gitKind, envName)
+	value, err := gittoken.Default().Token()
+	if err != nil {
+		return value, errors.Wrapf(err, "no token available for git kind %s", gitKind)
 	}
 	return value, nil
 }
 
 func (o *Options) createHookServer() (*Server, error) {
-	configAgent := &config.Agent{}
-	pluginAgent := &plugins.ConfigAgent{}
-
-	onConfigYamlChange := func(text string) {
-		if text != "" {
-			config, err := config.LoadYAMLConfig([]byte(text))
-			if err != nil {
-				logrus.WithError(err).Error("Error processing the prow Config YAML")
-			} else {
-				logrus.Info("updating the prow core configuration")
-				configAgent.Set(config)
-			}
+	if o.configAgent == nil || o.pluginAgent == nil {
+		clientFactory := o.GetFactory()
+		kubeClient, _, err := clientFactory.CreateKubeClient()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create Kube client")
 		}
-	}
-
-	onPluginsYamlChange := func(text string) {
-		if text != "" {
-			config, err := pluginAgent.LoadYAMLConfig([]byte(text))
-			if err != nil {
-				logrus.WithError(err).Error("Error processing the prow Plugins YAML")
-			} else {
-				logrus.Info("updating the prow plugins configuration")
-				pluginAgent.Set(config)
-			}
+		o.configAgent = &config.Agent{}
+		o.settingsAgent = &settings.Agent{}
+		o.pluginAgent = &plugins.ConfigAgent{}
+		o.fileWatcher, o.configMapWatcher, err = watcher.NewConfigAgentFileWatchers(kubeClient, o.namespace, o.configFilename, o.pluginFilename, o.configAgent, o.settingsAgent, o.pluginAgent, stopper())
+		if err != nil {
+			return nil, err
 		}
 	}
-
-	clientFactory := o.GetFactory()
-	kubeClient, _, err := clientFactory.CreateKubeClient()
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create Kube client")
-	}
-
-	callbacks := []watcher.ConfigMapCallback{
-		&watcher.ConfigMapEntryCallback{
-			Name:     util.ProwConfigMapName,
-			Key:      util.ProwConfigFilename,
-			Callback: onConfigYamlChange,
-		},
-		&watcher.ConfigMapEntryCallback{
-			Name:     util.ProwPluginsConfigMapName,
-			Key:      util.ProwPluginsFilename,
-			Callback: onPluginsYamlChange,
-		},
-	}
-	o.configMapWatcher, err = watcher.NewConfigMapWatcher(kubeClient, o.namespace, callbacks, stopper())
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create ConfigMap watcher")
-	}
+	configAgent := o.configAgent
 
 	promMetrics := NewMetrics()
 
@@ -550,11 +640,15 @@ func (o *Options) createHookServer() (*Server, error) {
 		return nil, errors.Wrapf(err, "failed to parse server URL %s", o.gitServerURL)
 	}
 	server := &Server{
-		ClientFactory: clientFactory,
+		ClientFactory: o.GetFactory(),
+		Namespace:     o.namespace,
 		ConfigAgent:   configAgent,
-		Plugins:       pluginAgent,
+		Plugins:       o.pluginAgent,
 		Metrics:       promMetrics,
 		ServerURL:     serverURL,
+		Settings:      o.settingsAgent.Config,
+		TektonClient:  o.kubeClients.Tekton,
+		activity:      newActivityTracker(time.Now(), &configMapActivityStore{kubeClient: o.kubeClients.Kube, namespace: o.namespace}),
 		//TokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
 	}
 	return server, nil